@@ -0,0 +1,99 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type ApplyOptions struct {
+	Now         bool
+	ForceUnlock bool
+}
+
+var (
+	applyOptions = ApplyOptions{}
+	cmdApply     = &cobra.Command{
+		Use:   "apply -f PLAN",
+		Short: "Launch a saved launch plan",
+		Long: `Launch a plan saved with nimbus launch --plan-out exactly as it was resolved, without resolving the AMIs, instance types, and network again.
+The plan can be reviewed and approved before it is applied, the spec must not be changed after it was saved.
+A plan whose spec only selects AMIs by alias or SSM parameter and the rest by tags, without IDs or availability zones, is region-agnostic:
+applied in another region with --region, it is resolved again in that region. Plans that reference resources of their region are rejected there.`,
+		Example: `  nimbus launch --name web --instance-types 'vcpus:2' --plan-out plan.yaml
+  nimbus apply -f plan.yaml
+  nimbus apply -f plan.yaml --region eu-west-1`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return apply(ctx, applyOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdApply)
+	cmdApply.Flags().BoolVar(&applyOptions.Now, "now", false, nowFlagUsage+", only plans that replace instances are gated")
+	cmdApply.Flags().BoolVar(&applyOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+}
+
+func apply(ctx context.Context, applyOptions ApplyOptions, globalOpts GlobalOptions) (err error) {
+	if globalOpts.ConfigFile == "" {
+		return fmt.Errorf("-f must be specified with the plan to apply")
+	}
+	launchPlan, err := plans.LoadLaunchPlan(globalOpts.ConfigFile)
+	if err != nil {
+		return err
+	}
+	if launchPlan.Spec.Replace {
+		if err := gateMaintenance(ctx, "replace instances", launchPlan.Metadata.Namespace, applyOptions.Now); err != nil {
+			return err
+		}
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+	vmClient := vm.New(awsCfg)
+	unlock, err := vmClient.Lock(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, "apply", applyOptions.ForceUnlock)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	launchPlan, err = vmClient.Apply(ctx, launchPlan)
+	printPlan(launchPlan, globalOpts)
+	printLaunchFailures(launchPlan, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	if globalOpts.Output == OutputJSON || globalOpts.Output == OutputYAML {
+		return nil
+	}
+	fmt.Println(launchSummary(launchPlan))
+	if launchPlan.Spec.WaitForBootstrap {
+		fmt.Println(pretty.Table(launchPlan.Status.Timings.Prettify(), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
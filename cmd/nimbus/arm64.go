@@ -0,0 +1,77 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type ARM64AdvisorOptions struct {
+	Name string
+}
+
+var (
+	arm64AdvisorOptions = ARM64AdvisorOptions{}
+	cmdARM64Advisor     = &cobra.Command{
+		Use:   "arm64-advisor",
+		Short: "Recommend Graviton instance types and AMIs for x86_64 VMs",
+		Long: `Examine the running x86_64 VMs and recommend the arm64 (Graviton) instance type with the same vCPUs and at least the same memory,
+the expected on-demand savings, and whether the VM's AMI or AMI alias has an arm64 build.
+VMs that are ready to migrate include the launch flags to relaunch them on arm64 in the wide output.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return arm64Advisor(ctx, arm64AdvisorOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdARM64Advisor)
+	cmdARM64Advisor.Flags().StringVar(&arm64AdvisorOptions.Name, "name", "", "Name of the VMs")
+}
+
+func arm64Advisor(ctx context.Context, arm64AdvisorOptions ARM64AdvisorOptions, globalOpts GlobalOptions) error {
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	migrations, err := vmClient.ARM64Migrations(ctx, globalOpts.Namespace, arm64AdvisorOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(migrations))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(migrations))
+	default:
+		if len(migrations) == 0 {
+			fmt.Println("No running x86_64 VMs found")
+			return nil
+		}
+		fmt.Println(pretty.Table(vm.PrettifyARM64Migrations(migrations), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
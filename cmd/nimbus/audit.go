@@ -0,0 +1,83 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/trails"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+type AuditTrailOptions struct {
+	Name  string
+	Since time.Duration
+}
+
+var (
+	auditTrailOptions = AuditTrailOptions{}
+	cmdAudit          = &cobra.Command{
+		Use:   "audit",
+		Short: "Audit activity on nimbus resources",
+	}
+	cmdAuditTrail = &cobra.Command{
+		Use:   "trail",
+		Short: "Show who changed the resources of a namespace",
+		Long:  `Show who changed the resources of a namespace, and when, by looking up CloudTrail management events. CloudTrail events can take up to 15 minutes to be delivered.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return auditTrail(ctx, auditTrailOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdAudit)
+	cmdAudit.AddCommand(cmdAuditTrail)
+	cmdAuditTrail.Flags().StringVar(&auditTrailOptions.Name, "name", "", "Name of the VM, defaults to all VMs in the namespace")
+	cmdAuditTrail.Flags().DurationVar(&auditTrailOptions.Since, "since", 24*time.Hour, "Show events newer than a relative duration like 1h or 24h. CloudTrail retains 90 days of events")
+}
+
+func auditTrail(ctx context.Context, auditTrailOptions AuditTrailOptions, globalOpts GlobalOptions) error {
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	events, err := vmClient.AuditTrail(ctx, globalOpts.Namespace, auditTrailOptions.Name, auditTrailOptions.Since)
+	if err != nil {
+		return err
+	}
+
+	eventsUI := lo.Map(events, func(event trails.Event, _ int) trails.PrettyEvent { return event.Prettify() })
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(events))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(events))
+	default:
+		fmt.Println(pretty.Table(eventsUI, globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
@@ -0,0 +1,324 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/fis"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+type ChaosInterruptOptions struct {
+	Name        string
+	Count       int
+	FISRoleARN  string
+	Notice      time.Duration
+	Now         bool
+	ForceUnlock bool
+}
+
+type ChaosExperimentOptions struct {
+	Name         string
+	Fault        string
+	FISRoleARN   string
+	Count        int
+	Duration     time.Duration
+	LoadPercent  int
+	Latency      time.Duration
+	TemplateID   string
+	ExperimentID string
+	Now          bool
+	ForceUnlock  bool
+}
+
+var (
+	chaosInterruptOptions  = ChaosInterruptOptions{}
+	chaosExperimentOptions = ChaosExperimentOptions{}
+	cmdChaos               = &cobra.Command{
+		Use:   "chaos",
+		Short: "Inject failures into VMs to test how their workload handles them",
+	}
+	cmdChaosInterrupt = &cobra.Command{
+		Use:   "interrupt",
+		Short: "Interrupt spot VMs",
+		Long: `Interrupt random running spot VMs of a name to test how the workload handles spot interruptions.
+With --fis-role-arn, an AWS Fault Injection Service experiment sends the VMs a real spot interruption notice and interrupts them once --notice is over,
+the same way EC2 reclaims spot capacity. The role must trust fis.amazonaws.com and allow ec2:SendSpotInstanceInterruptions.
+Without it, the VMs are terminated right away without a notice.`,
+		Example: `  nimbus chaos interrupt --name web
+  nimbus chaos interrupt --name web --count 2 --fis-role-arn arn:aws:iam::123456789012:role/nimbus-fis --notice 5m`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return chaosInterrupt(ctx, chaosInterruptOptions, globalOpts)
+		},
+	}
+	cmdChaosCreate = &cobra.Command{
+		Use:   "create",
+		Short: "Create a fault injection experiment template for VMs",
+		Long: `Create an AWS Fault Injection Service experiment template that injects a fault into the running VMs of a name when an experiment is started from it.
+cpu-stress and network-latency run AWSFIS SSM documents on the VMs, so the VMs must be registered with SSM. instance-stop stops the VMs and starts them again after --duration.
+The role must trust fis.amazonaws.com and allow ssm:SendCommand or ec2:StopInstances and ec2:StartInstances. The template is deleted with the VMs.`,
+		Example: `  nimbus chaos create --name web --fault cpu-stress --duration 5m --load 80 --fis-role-arn arn:aws:iam::123456789012:role/nimbus-fis
+  nimbus chaos create --name web --fault network-latency --latency 300ms --count 1 --fis-role-arn arn:aws:iam::123456789012:role/nimbus-fis
+  nimbus chaos create --name web --fault instance-stop --duration 10m --fis-role-arn arn:aws:iam::123456789012:role/nimbus-fis`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return chaosCreate(ctx, chaosExperimentOptions, globalOpts)
+		},
+	}
+	cmdChaosGet = &cobra.Command{
+		Use:   "get",
+		Short: "List the fault injection experiment templates of VMs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return chaosGet(ctx, chaosExperimentOptions, globalOpts)
+		},
+	}
+	cmdChaosStart = &cobra.Command{
+		Use:     "start",
+		Short:   "Start a fault injection experiment from an experiment template",
+		Example: `  nimbus chaos start --template EXT1a2b3c4d5e6f7`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return chaosStart(ctx, chaosExperimentOptions, globalOpts)
+		},
+	}
+	cmdChaosStop = &cobra.Command{
+		Use:     "stop",
+		Short:   "Stop a running fault injection experiment",
+		Example: `  nimbus chaos stop --experiment EXP1a2b3c4d5e6f7`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return chaosStop(ctx, chaosExperimentOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdChaos)
+	cmdChaos.AddCommand(cmdChaosInterrupt)
+	cmdChaosInterrupt.Flags().StringVar(&chaosInterruptOptions.Name, "name", "", "Name of the VMs")
+	cmdChaosInterrupt.Flags().IntVar(&chaosInterruptOptions.Count, "count", 1, "Number of spot VMs to interrupt")
+	cmdChaosInterrupt.Flags().StringVar(&chaosInterruptOptions.FISRoleARN, "fis-role-arn", "", "IAM role that FIS assumes to send the spot interruptions, the VMs are terminated without a notice if it is not set")
+	cmdChaosInterrupt.Flags().DurationVar(&chaosInterruptOptions.Notice, "notice", fis.MinInterruptionNotice, "Time between the interruption notice and the interruption when --fis-role-arn is set, at least 2m")
+	cmdChaosInterrupt.Flags().BoolVar(&chaosInterruptOptions.Now, "now", false, nowFlagUsage)
+	cmdChaosInterrupt.Flags().BoolVar(&chaosInterruptOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+
+	cmdChaos.AddCommand(cmdChaosCreate, cmdChaosGet, cmdChaosStart, cmdChaosStop)
+	cmdChaosCreate.Flags().StringVar(&chaosExperimentOptions.Name, "name", "", "Name of the VMs")
+	cmdChaosCreate.Flags().StringVar(&chaosExperimentOptions.Fault, "fault", "", fmt.Sprintf("Fault to inject: %s", strings.Join(fis.Faults, ", ")))
+	cmdChaosCreate.Flags().StringVar(&chaosExperimentOptions.FISRoleARN, "fis-role-arn", "", "IAM role that FIS assumes to inject the fault")
+	cmdChaosCreate.Flags().IntVar(&chaosExperimentOptions.Count, "count", 0, "Number of running VMs that an experiment picks at random (default all)")
+	cmdChaosCreate.Flags().DurationVar(&chaosExperimentOptions.Duration, "duration", 5*time.Minute, "How long the fault lasts")
+	cmdChaosCreate.Flags().IntVar(&chaosExperimentOptions.LoadPercent, "load", fis.DefaultLoadPercent, "CPU load percent of cpu-stress")
+	cmdChaosCreate.Flags().DurationVar(&chaosExperimentOptions.Latency, "latency", fis.DefaultLatency, "Network delay of network-latency")
+	cmdChaosCreate.Flags().BoolVar(&chaosExperimentOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+	cmdChaosGet.Flags().StringVar(&chaosExperimentOptions.Name, "name", "", "Name of the VMs, defaults to all VMs in the namespace")
+	cmdChaosStart.Flags().StringVar(&chaosExperimentOptions.TemplateID, "template", "", "ID of the experiment template to start an experiment from")
+	cmdChaosStart.Flags().BoolVar(&chaosExperimentOptions.Now, "now", false, nowFlagUsage)
+	cmdChaosStart.Flags().BoolVar(&chaosExperimentOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+	cmdChaosStop.Flags().StringVar(&chaosExperimentOptions.ExperimentID, "experiment", "", "ID of the experiment to stop")
+}
+
+func chaosInterrupt(ctx context.Context, chaosInterruptOptions ChaosInterruptOptions, globalOpts GlobalOptions) (err error) {
+	if chaosInterruptOptions.Name == "" {
+		return fmt.Errorf("--name must be specified")
+	}
+	if chaosInterruptOptions.Count < 1 {
+		return fmt.Errorf("--count must be at least 1")
+	}
+	if err := gateMaintenance(ctx, "interrupt instances", globalOpts.Namespace, chaosInterruptOptions.Now); err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, chaosInterruptOptions.Name, "chaos", chaosInterruptOptions.ForceUnlock)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	interruption, err := vmClient.Interrupt(ctx, globalOpts.Namespace, chaosInterruptOptions.Name, vm.InterruptOptions{
+		Count:   chaosInterruptOptions.Count,
+		RoleARN: chaosInterruptOptions.FISRoleARN,
+		Notice:  chaosInterruptOptions.Notice,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(interruption))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(interruption))
+	default:
+		if interruption.Experiment != nil {
+			fmt.Printf("Interrupted %d spot instances with FIS experiment %s\n", len(interruption.Instances), interruption.Experiment.ID)
+		} else {
+			fmt.Printf("Terminated %d spot instances\n", len(interruption.Instances))
+		}
+		fmt.Println(pretty.Table(instances.PrettifyAll(interruption.Instances), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
+
+func chaosCreate(ctx context.Context, chaosExperimentOptions ChaosExperimentOptions, globalOpts GlobalOptions) (err error) {
+	if chaosExperimentOptions.Name == "" {
+		return fmt.Errorf("--name must be specified")
+	}
+	if chaosExperimentOptions.FISRoleARN == "" {
+		return fmt.Errorf("--fis-role-arn must be specified")
+	}
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, chaosExperimentOptions.Name, "chaos", chaosExperimentOptions.ForceUnlock)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	template, err := vmClient.CreateExperiment(ctx, globalOpts.Namespace, chaosExperimentOptions.Name, vm.ExperimentOptions{
+		Fault:       chaosExperimentOptions.Fault,
+		RoleARN:     chaosExperimentOptions.FISRoleARN,
+		Count:       chaosExperimentOptions.Count,
+		Duration:    chaosExperimentOptions.Duration,
+		LoadPercent: chaosExperimentOptions.LoadPercent,
+		Latency:     chaosExperimentOptions.Latency,
+	})
+	if err != nil {
+		return err
+	}
+	return printExperimentTemplates([]fis.ExperimentTemplate{template}, globalOpts)
+}
+
+func chaosGet(ctx context.Context, chaosExperimentOptions ChaosExperimentOptions, globalOpts GlobalOptions) error {
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	templates, err := vmClient.ListExperiments(ctx, globalOpts.Namespace, chaosExperimentOptions.Name)
+	if err != nil {
+		return err
+	}
+	return printExperimentTemplates(templates, globalOpts)
+}
+
+func chaosStart(ctx context.Context, chaosExperimentOptions ChaosExperimentOptions, globalOpts GlobalOptions) (err error) {
+	if chaosExperimentOptions.TemplateID == "" {
+		return fmt.Errorf("--template must be specified")
+	}
+	if err := gateMaintenance(ctx, "start an experiment", globalOpts.Namespace, chaosExperimentOptions.Now); err != nil {
+		return err
+	}
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	// the experiment injects the fault into the VMs of the template's name, so it is started under their lock
+	templates, err := vmClient.ListExperiments(ctx, globalOpts.Namespace, "")
+	if err != nil {
+		return err
+	}
+	template, ok := lo.Find(templates, func(template fis.ExperimentTemplate) bool { return template.ID == chaosExperimentOptions.TemplateID })
+	if !ok {
+		return fmt.Errorf("experiment template %s not found in namespace %s", chaosExperimentOptions.TemplateID, globalOpts.Namespace)
+	}
+	unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, template.Tags[tagutils.NameTagKey], "chaos", chaosExperimentOptions.ForceUnlock)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	experiment, err := vmClient.StartExperiment(ctx, globalOpts.Namespace, chaosExperimentOptions.TemplateID)
+	if err != nil {
+		return err
+	}
+	printExperiment(experiment, globalOpts)
+	return nil
+}
+
+func chaosStop(ctx context.Context, chaosExperimentOptions ChaosExperimentOptions, globalOpts GlobalOptions) error {
+	if chaosExperimentOptions.ExperimentID == "" {
+		return fmt.Errorf("--experiment must be specified")
+	}
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	experiment, err := vmClient.StopExperiment(ctx, globalOpts.Namespace, chaosExperimentOptions.ExperimentID)
+	if err != nil {
+		return err
+	}
+	printExperiment(experiment, globalOpts)
+	return nil
+}
+
+func printExperimentTemplates(templates []fis.ExperimentTemplate, globalOpts GlobalOptions) error {
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(templates))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(templates))
+	default:
+		fmt.Println(pretty.Table(lo.Map(templates, func(template fis.ExperimentTemplate, _ int) fis.PrettyExperimentTemplate {
+			return template.Prettify()
+		}), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
+
+func printExperiment(experiment fis.Experiment, globalOpts GlobalOptions) {
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(experiment))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(experiment))
+	default:
+		fmt.Printf("Experiment %s of template %s is %s\n", experiment.ID, experiment.ExperimentTemplateID, experiment.State.Status)
+	}
+}
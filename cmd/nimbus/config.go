@@ -0,0 +1,119 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bwagner5/nimbus/pkg/userconfig"
+	"github.com/spf13/cobra"
+)
+
+var (
+	cmdConfig = &cobra.Command{
+		Use:   "config",
+		Short: "Manage the persisted nimbus context",
+		Long: `Manage the persisted nimbus context stored in ~/.nimbus/config (or $NIMBUS_CONFIG). The context provides defaults for --namespace and --region.
+The region is resolved in order from --region, AWS_REGION, AWS_DEFAULT_REGION, the persisted context, and finally the AWS profile.
+The config can also define maintenance windows as cron schedules that open for a duration. Disruptive commands (stop, reboot, restart, and
+launch or apply replacing instances) are refused outside of the windows, or wait for the next window with outside: queue, unless --now is passed:
+
+  maintenance:
+    outside: refuse
+    windows:
+    - schedule: "0 2 * * sat"
+      duration: 4h
+      timezone: America/Chicago
+      namespaces: [prod]`,
+	}
+	cmdConfigUseNamespace = &cobra.Command{
+		Use:   "use-namespace NAMESPACE",
+		Short: "Set the default namespace",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return updateUserConfig(func(cfg *userconfig.Config) { cfg.Namespace = args[0] })
+		},
+	}
+	cmdConfigUseRegion = &cobra.Command{
+		Use:   "use-region REGION",
+		Short: "Set the default AWS region",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			return updateUserConfig(func(cfg *userconfig.Config) { cfg.Region = args[0] })
+		},
+	}
+	cmdConfigCurrentContext = &cobra.Command{
+		Use:   "current-context",
+		Short: "Print the persisted namespace and region",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			path, err := userconfig.DefaultPath()
+			if err != nil {
+				return err
+			}
+			cfg, err := userconfig.Load(path)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("namespace: %s\nregion: %s\n", cfg.Namespace, cfg.Region)
+			return nil
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdConfig)
+	cmdConfig.AddCommand(cmdConfigUseNamespace)
+	cmdConfig.AddCommand(cmdConfigUseRegion)
+	cmdConfig.AddCommand(cmdConfigCurrentContext)
+}
+
+func updateUserConfig(update func(*userconfig.Config)) error {
+	path, err := userconfig.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := userconfig.Load(path)
+	if err != nil {
+		return err
+	}
+	update(&cfg)
+	if err := cfg.Save(path); err != nil {
+		return err
+	}
+	fmt.Printf("Switched to namespace %q in region %q\n", cfg.Namespace, cfg.Region)
+	return nil
+}
+
+// applyUserConfig defaults the global namespace and region to the persisted context when the flags are not explicitly set.
+// The persisted region does not override a region set in the AWS_REGION or AWS_DEFAULT_REGION environment variables.
+func applyUserConfig(cmd *cobra.Command, globalOpts *GlobalOptions) error {
+	path, err := userconfig.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := userconfig.Load(path)
+	if err != nil {
+		return err
+	}
+	if flag := cmd.Flags().Lookup("namespace"); flag != nil && !flag.Changed && cfg.Namespace != "" {
+		globalOpts.Namespace = cfg.Namespace
+	}
+	if flag := cmd.Flags().Lookup("region"); flag != nil && !flag.Changed && cfg.Region != "" &&
+		os.Getenv("AWS_REGION") == "" && os.Getenv("AWS_DEFAULT_REGION") == "" {
+		globalOpts.Region = cfg.Region
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+// ConnectOptions are the options of the ssh and exec commands
+type ConnectOptions struct {
+	Name             string
+	InstanceSelector string
+	// Timeout, MaxParallel, and FailFast are only used by exec
+	Timeout     time.Duration
+	MaxParallel int
+	FailFast    bool
+}
+
+// execResultRow is a row of the summary of exec's results
+type execResultRow struct {
+	InstanceID string `table:"Instance ID"`
+	Status     string `table:"Status,status"`
+	ExitCode   string `table:"Exit Code"`
+	Duration   string `table:"Duration,right"`
+}
+
+var (
+	sshOptions  = ConnectOptions{}
+	execOptions = ConnectOptions{}
+	cmdSSH      = &cobra.Command{
+		Use:   "ssh",
+		Short: "Open a shell on a VM",
+		Long: `Open an interactive shell on a running VM through SSM Session Manager, no SSH key or inbound port is required.
+The VM must run the SSM agent with an instance profile that allows Session Manager, and the Session Manager plugin must be installed locally.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return ssh(ctx, sshOptions, globalOpts)
+		},
+	}
+	cmdExec = &cobra.Command{
+		Use:   "exec -- COMMAND [ARGS...]",
+		Short: "Run a command on VMs",
+		Long: `Run a one-shot command on running VMs through SSM Run Command and print its output.
+The command and its arguments are joined with spaces and run by sh, or PowerShell on Windows VMs, so quote them to pass a whole script.
+Commands on many VMs can be rolled out with --max-parallel and stopped at the first failure with --fail-fast, a summary of every VM's result follows the output.`,
+		Example: `  nimbus exec --name web -- uptime
+  nimbus exec --name web --instances 'id:i-0123456' -- 'journalctl -u app | tail'
+  nimbus exec --name web --max-parallel 10 --fail-fast -- 'sudo systemctl restart app'`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return execCommand(ctx, execOptions, globalOpts, strings.Join(args, " "))
+		},
+	}
+)
+
+func init() {
+	for cmd, opts := range map[*cobra.Command]*ConnectOptions{cmdSSH: &sshOptions, cmdExec: &execOptions} {
+		rootCmd.AddCommand(cmd)
+		cmd.Flags().StringVar(&opts.Name, "name", "", "Name of the VMs")
+		cmd.Flags().StringVar(&opts.InstanceSelector, "instances", "", "Instance selector to choose the VMs within the namespace. e.g. --instances 'id:i-0123456'")
+	}
+	cmdExec.Flags().DurationVar(&execOptions.Timeout, "timeout", 0, "How long the command may run before it is stopped (default 1h)")
+	cmdExec.Flags().IntVar(&execOptions.MaxParallel, "max-parallel", 0, "Most VMs that run the command at once (default all)")
+	cmdExec.Flags().BoolVar(&execOptions.FailFast, "fail-fast", false, "Stop sending the command to more VMs once it did not succeed on one, VMs that already run it finish")
+}
+
+func ssh(ctx context.Context, sshOptions ConnectOptions, globalOpts GlobalOptions) error {
+	selectorList, err := connectSelectors(sshOptions)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	return vmClient.Connect(ctx, globalOpts.Namespace, sshOptions.Name, selectorList, globalOpts.Profile)
+}
+
+func execCommand(ctx context.Context, execOptions ConnectOptions, globalOpts GlobalOptions, script string) error {
+	selectorList, err := connectSelectors(execOptions)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	if execOptions.MaxParallel < 0 {
+		return fmt.Errorf("--max-parallel must not be negative")
+	}
+	results, err := vmClient.Exec(ctx, globalOpts.Namespace, execOptions.Name, selectorList, sessions.Command{
+		Script:      script,
+		Timeout:     execOptions.Timeout,
+		MaxParallel: execOptions.MaxParallel,
+		FailFast:    execOptions.FailFast,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(results))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(results))
+	default:
+		for _, result := range results {
+			// output of several VMs is separated by a header per VM
+			if len(results) > 1 {
+				fmt.Printf("==> %s (%s, exit code %d) <==\n", result.InstanceID, result.Status, result.ExitCode)
+			}
+			fmt.Print(result.Stdout)
+			fmt.Fprint(os.Stderr, result.Stderr)
+		}
+		if len(results) > 1 {
+			fmt.Println(pretty.Table(lo.Map(results, func(result sessions.CommandResult, _ int) execResultRow {
+				return execResultRow{
+					InstanceID: result.InstanceID,
+					Status:     string(result.Status),
+					ExitCode:   strconv.Itoa(int(result.ExitCode)),
+					Duration:   pretty.Duration(result.Duration),
+				}
+			}), false))
+		}
+	}
+
+	failed := lo.Reject(results, func(result sessions.CommandResult, _ int) bool { return result.Succeeded() })
+	if len(failed) != 0 {
+		return fmt.Errorf("command did not succeed on %d of %d VMs: %s", len(failed), len(results), strings.Join(lo.Map(failed, func(result sessions.CommandResult, _ int) string {
+			return fmt.Sprintf("%s (%s, exit code %d)", result.InstanceID, result.Status, result.ExitCode)
+		}), ", "))
+	}
+	return nil
+}
+
+// connectSelectors parses the instance selectors, a name or selectors are required to choose the VMs
+func connectSelectors(connectOptions ConnectOptions) ([]instances.Selector, error) {
+	if connectOptions.Name == "" && connectOptions.InstanceSelector == "" {
+		return nil, fmt.Errorf("--name or --instances must be specified")
+	}
+	if connectOptions.InstanceSelector == "" {
+		return nil, nil
+	}
+	return instances.ParseSelectors(connectOptions.InstanceSelector)
+}
@@ -0,0 +1,87 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type ConsoleOptions struct {
+	Name             string
+	InstanceSelector string
+	Screenshot       string
+	EnableAccess     bool
+}
+
+var (
+	consoleOptions = ConsoleOptions{}
+	cmdConsole     = &cobra.Command{
+		Use:   "console",
+		Short: "Connect to a VM's serial console or take a screenshot of its console",
+		Long: `Connect the terminal to the EC2 serial console of a running VM, which works without the VM's network, SSH, or SSM agent, so VMs that
+are unreachable, e.g. with a broken network configuration or stuck in boot, can still be inspected.
+Serial console access is an account setting of the region, --enable-access enables it if it is disabled. Sessions are opened with ssh
+and a one-time key pushed with EC2 Instance Connect, logging in requires a user with a password on the VM. Only Nitro instances have serial consoles.
+With --screenshot, a JPG screenshot of the VM's console is written to the file instead, which also shows the state of Windows VMs.
+Use nimbus logs --console for the console output.`,
+		Example: `  nimbus console --name web
+  nimbus console --name web --instances 'id:i-0123456' --enable-access
+  nimbus console --name web --screenshot web.jpg`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return console(ctx, consoleOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdConsole)
+	cmdConsole.Flags().StringVar(&consoleOptions.Name, "name", "", "Name of the VM")
+	cmdConsole.Flags().StringVar(&consoleOptions.InstanceSelector, "instances", "", "Instance selector to choose the VM within the namespace. e.g. --instances 'id:i-0123456'")
+	cmdConsole.Flags().StringVar(&consoleOptions.Screenshot, "screenshot", "", "Write a JPG screenshot of the VM's console to the file instead of connecting to the serial console")
+	cmdConsole.Flags().BoolVar(&consoleOptions.EnableAccess, "enable-access", false, "Enable serial console access for the account in the region if it is disabled")
+}
+
+func console(ctx context.Context, consoleOptions ConsoleOptions, globalOpts GlobalOptions) error {
+	selectorList, err := connectSelectors(ConnectOptions{Name: consoleOptions.Name, InstanceSelector: consoleOptions.InstanceSelector})
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	if consoleOptions.Screenshot == "" {
+		return vmClient.SerialConsole(ctx, globalOpts.Namespace, consoleOptions.Name, selectorList, consoleOptions.EnableAccess)
+	}
+	instanceID, screenshot, err := vmClient.Screenshot(ctx, globalOpts.Namespace, consoleOptions.Name, selectorList)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(consoleOptions.Screenshot, screenshot, 0o644); err != nil {
+		return fmt.Errorf("failed to write screenshot: %w", err)
+	}
+	fmt.Printf("Wrote a screenshot of %s to %s\n", instanceID, consoleOptions.Screenshot)
+	return nil
+}
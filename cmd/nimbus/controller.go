@@ -0,0 +1,128 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bwagner5/nimbus/pkg/controller"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/userconfig"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+type ControllerOptions struct {
+	Manifests string
+	Interval  time.Duration
+	Events    bool
+	Once      bool
+}
+
+var (
+	controllerOptions = ControllerOptions{}
+	cmdController     = &cobra.Command{
+		Use:   "controller --manifests DIR|s3://BUCKET/PREFIX",
+		Short: "Continuously reconcile the launch plans of a directory or S3 prefix",
+		Long: `Run nimbus as a long-running daemon that keeps the launch plans of a directory or S3 prefix launched.
+Every .yaml, .yml, and .json file is a launch plan, e.g. saved with nimbus launch --plan-out, whose status is ignored. Plans without a namespace are launched in the -n namespace.
+Every interval the plans are resolved like a dry-run, and the ones that are missing instances or whose spec changed are launched:
+  - missing instances, e.g. after spot interruptions, are launched again
+  - a changed spec with replace set replaces the instances, which waits for the namespace's maintenance windows in the nimbus config
+  - a VM whose TTL expired is deleted and is not launched again until its spec changes or the controller restarts
+  - a VM that is locked by another nimbus command, e.g. an operator's launch or delete, is skipped until it is unlocked
+With --events the namespaces' EC2 events also trigger a reconciliation, the events are removed from the queues that nimbus events reads.
+Removing a plan does not delete its VM, delete it with nimbus delete.`,
+		Example: `  nimbus controller --manifests ./plans
+  nimbus controller --manifests s3://my-bucket/plans/ --interval 1m --events
+  nimbus controller --manifests ./plans --once`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return runController(ctx, controllerOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdController)
+	cmdController.Flags().StringVar(&controllerOptions.Manifests, "manifests", "", "Directory or S3 prefix, s3://bucket/prefix, of the launch plans to reconcile")
+	cmdController.Flags().DurationVar(&controllerOptions.Interval, "interval", controller.DefaultInterval, "How often the launch plans are reconciled")
+	cmdController.Flags().BoolVar(&controllerOptions.Events, "events", false, "Also reconcile when instances in the plans' namespaces are interrupted or change state")
+	cmdController.Flags().BoolVar(&controllerOptions.Once, "once", false, "Reconcile the launch plans once, print what was done, and exit")
+}
+
+func runController(ctx context.Context, controllerOptions ControllerOptions, globalOpts GlobalOptions) error {
+	if controllerOptions.Manifests == "" {
+		return fmt.Errorf("--manifests must be specified")
+	}
+	path, err := userconfig.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := userconfig.Load(path)
+	if err != nil {
+		return err
+	}
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+	source, err := controller.NewSource(controllerOptions.Manifests, s3.NewFromConfig(*awsCfg))
+	if err != nil {
+		return err
+	}
+	c := controller.New(vm.New(awsCfg), source, controller.Options{
+		Namespace:   globalOpts.Namespace,
+		Interval:    controllerOptions.Interval,
+		Events:      controllerOptions.Events,
+		Maintenance: cfg.Maintenance,
+	})
+
+	if !controllerOptions.Once {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		logging.FromContext(ctx).Info("Starting controller", "manifests", controllerOptions.Manifests, "interval", controllerOptions.Interval, "events", controllerOptions.Events)
+		return c.Run(ctx)
+	}
+
+	results, err := c.Reconcile(ctx)
+	if err != nil {
+		return err
+	}
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(results))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(results))
+	default:
+		if len(results) == 0 {
+			fmt.Printf("No launch plans in %s\n", controllerOptions.Manifests)
+			return nil
+		}
+		fmt.Println(pretty.Table(results, globalOpts.Output == OutputTableWide))
+	}
+	if failed := lo.CountBy(results, func(result controller.Result) bool { return result.Action == controller.ActionFailed }); failed != 0 {
+		return fmt.Errorf("%d of %d launch plans could not be reconciled", failed, len(results))
+	}
+	return nil
+}
@@ -16,24 +16,45 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
 	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/retry"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/bwagner5/nimbus/pkg/vm"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
 type DeleteOptions struct {
-	Name  string
-	All   bool
-	Force bool
+	Name           string
+	All            bool
+	Force          bool
+	StopFirst      bool
+	StopGrace      time.Duration
+	KeepVolumes    string
+	FinalSnapshot  bool
+	SkipStragglers bool
+	RetryAttempts  int
+	RetryMaxDelay  time.Duration
+	ForceUnlock    bool
+	Graph          string
 }
 
+const (
+	// GraphDOT prints the deletion plan's dependency graph in the Graphviz DOT language
+	GraphDOT = "dot"
+	// GraphJSON prints the deletion plan's dependency graph as JSON
+	GraphJSON = "json"
+)
+
 type DeleteUI struct {
 	Name      string
 	Namespace string
@@ -60,39 +81,181 @@ func init() {
 	cmdDelete.Flags().StringVar(&deleteOptions.Name, "name", "", "Name of the VM")
 	cmdDelete.Flags().BoolVar(&deleteOptions.All, "all", false, "Delete everything in the namespace")
 	cmdDelete.Flags().BoolVar(&deleteOptions.Force, "force", false, "Don't ask, just do it!")
+	cmdDelete.Flags().BoolVar(&deleteOptions.StopFirst, "stop-first", false, "Stop the instances and wait --stop-grace-period before terminating them, so that their volumes can be snapshotted. Asks again before terminating unless --force")
+	cmdDelete.Flags().DurationVar(&deleteOptions.StopGrace, "stop-grace-period", time.Minute, "How long to wait between stopping the instances with --stop-first and terminating them")
+	cmdDelete.Flags().StringVar(&deleteOptions.KeepVolumes, "keep-volumes", "", fmt.Sprintf("Attached volumes to keep instead of deleting them with their instances: %s for every volume except the root volumes, %s, or device names and volume IDs separated by commas. e.g. --keep-volumes /dev/sdf", plans.KeepDataVolumes, plans.KeepAllVolumes))
+	cmdDelete.Flags().BoolVar(&deleteOptions.FinalSnapshot, "final-snapshot", false, "Snapshot every volume attached to the instances before terminating them. Snapshots are tagged with the deletion plan ID and the device to restore them to, combine with --stop-first for consistent snapshots")
+	cmdDelete.Flags().BoolVar(&deleteOptions.SkipStragglers, "skip-stragglers", false, "Don't wait for instances that are stuck shutting down or stopping after they were forced to terminate, the resources they use are left for the next delete")
+	cmdDelete.Flags().IntVar(&deleteOptions.RetryAttempts, "retry-attempts", retry.DefaultBackoff.Attempts, "Attempts to delete a resource that is still in use, e.g. by the network interfaces of just terminated instances, retries back off exponentially")
+	cmdDelete.Flags().DurationVar(&deleteOptions.RetryMaxDelay, "retry-max-delay", retry.DefaultBackoff.MaxDelay, "Max delay between attempts to delete a resource that is still in use")
+	cmdDelete.Flags().BoolVar(&deleteOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+	cmdDelete.Flags().StringVar(&deleteOptions.Graph, "graph", "", fmt.Sprintf("Print the dependency graph of what would be deleted and in which order as %s or %s instead of deleting, e.g. --graph dot | dot -Tsvg > plan.svg", GraphDOT, GraphJSON))
 }
 
-func delete(ctx context.Context, deleteOptions DeleteOptions, globalOpts GlobalOptions) error {
+func delete(ctx context.Context, deleteOptions DeleteOptions, globalOpts GlobalOptions) (err error) {
+	ctx = retry.ToContext(ctx, retry.Backoff{
+		Attempts: deleteOptions.RetryAttempts,
+		Delay:    retry.DefaultBackoff.Delay,
+		MaxDelay: deleteOptions.RetryMaxDelay,
+	})
 	awsCfg, err := AWSConfig(ctx, globalOpts)
 	if err != nil {
 		return err
 	}
 
+	if deleteOptions.Graph != "" && deleteOptions.Graph != GraphDOT && deleteOptions.Graph != GraphJSON {
+		return fmt.Errorf("--graph must be %s or %s", GraphDOT, GraphJSON)
+	}
+
 	vmClient := vm.New(awsCfg)
+	// printing the graph does not change anything, so it does not take the lock
+	if deleteOptions.Graph == "" {
+		unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, deleteOptions.Name, "delete", deleteOptions.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer func() { err = errors.Join(err, unlock()) }()
+	}
 
 	deletionPlan, err := vmClient.DeletionPlan(ctx, globalOpts.Namespace, deleteOptions.Name)
 	if err != nil {
 		return err
 	}
 
+	keptVolumes, err := deletionPlan.VolumesToKeep(parseList(deleteOptions.KeepVolumes))
+	if err != nil {
+		return err
+	}
+
+	if deleteOptions.Graph != "" {
+		graph := deletionPlan.KeepVolumes(keptVolumes).Graph()
+		if deleteOptions.Graph == GraphJSON {
+			fmt.Println(pretty.EncodeJSON(graph))
+			return nil
+		}
+		fmt.Print(graph.DOT(strings.Trim(fmt.Sprintf("%s/%s", globalOpts.Namespace, deleteOptions.Name), "/")))
+		return nil
+	}
+
 	if !deleteOptions.Force {
 		fmt.Println(pretty.EncodeYAML(deletionPlan))
-		fmt.Printf("Proceed with deletion? ")
-		reader := bufio.NewReader(os.Stdin)
-		userInput, err := reader.ReadString('\n')
+		if attached := deletionPlan.AttachedVolumes(); len(attached) != 0 {
+			fmt.Println("Attached volumes:")
+			fmt.Println(pretty.Table(attached, false))
+		}
+		if len(keptVolumes) != 0 {
+			fmt.Printf("Keeping %d volumes: %s\n", len(keptVolumes), strings.Join(lo.Map(keptVolumes, func(volume plans.AttachedVolume, _ int) string { return volume.VolumeID }), ", "))
+		}
+		if attached := deletionPlan.AttachedVolumes(); deleteOptions.FinalSnapshot && len(attached) != 0 {
+			fmt.Printf("Snapshotting %d volumes before terminating their instances\n", len(attached))
+		}
+	}
+
+	if len(deletionPlan.Spec.Skipped) > 0 {
+		fmt.Println("WARNING: The following shared resources are still in use and will not be deleted:")
+		fmt.Println(pretty.Table(deletionPlan.Spec.Skipped, false))
+	}
+
+	if !deleteOptions.Force {
+		summary := deletionPlan.Summary()
+		fmt.Println(summary)
+		confirmed, err := confirmDeletion(summary, lo.CoalesceOrEmpty(deleteOptions.Name, globalOpts.Namespace))
 		if err != nil {
 			return err
 		}
-		if !strings.HasPrefix(strings.ToLower(strings.TrimSpace(userInput)), "y") {
+		if !confirmed {
 			fmt.Println("Aborting deletion...")
 			return nil
 		}
 	}
 
+	if len(keptVolumes) != 0 {
+		deletionPlan, err = vmClient.KeepVolumes(ctx, deletionPlan, keptVolumes)
+		if err != nil {
+			return err
+		}
+	}
+
+	if deleteOptions.StopFirst && len(deletionPlan.Spec.Instances) != 0 {
+		var confirmed bool
+		deletionPlan, confirmed, err = stopFirst(ctx, vmClient, deletionPlan, deleteOptions, globalOpts)
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborting deletion, the instances stay stopped...")
+			return nil
+		}
+	}
+
+	deletionPlan.Spec.SkipStragglers = deleteOptions.SkipStragglers
+	deletionPlan.Spec.FinalSnapshot = deleteOptions.FinalSnapshot
 	deletionPlan, err = vmClient.Delete(ctx, deletionPlan)
+	if globalOpts.Output == OutputJSON || globalOpts.Output == OutputYAML {
+		printPlan(deletionPlan, globalOpts)
+	}
 	if err != nil {
 		return err
 	}
 
+	if len(deletionPlan.Status.Snapshots) > 0 {
+		fmt.Printf("Created %d final snapshots tagged with %s=%s\n", len(deletionPlan.Status.Snapshots), tagutils.DeletionPlanIDTagKey, deletionPlan.Metadata.PlanID)
+	}
+	if len(deletionPlan.Status.Skipped) > 0 {
+		fmt.Println("The following resources were not deleted:")
+		fmt.Println(pretty.Table(deletionPlan.Status.Skipped, false))
+	}
 	return nil
 }
+
+// stopFirst stops the plan's instances, waits the grace period so that their volumes can be snapshotted, and then asks to confirm that the
+// volumes deleted on termination are destroyed unless the deletion is forced
+func stopFirst(ctx context.Context, vmClient vm.VMI, deletionPlan plans.DeletionPlan, deleteOptions DeleteOptions, globalOpts GlobalOptions) (plans.DeletionPlan, bool, error) {
+	deletionPlan, err := vmClient.StopForDeletion(ctx, deletionPlan)
+	if err != nil {
+		return deletionPlan, false, err
+	}
+	if deleteOptions.StopGrace > 0 {
+		fmt.Printf("Stopped %d instances, terminating them in %s. Snapshot their volumes now to keep their data\n", len(deletionPlan.Spec.Instances), deleteOptions.StopGrace)
+		select {
+		case <-ctx.Done():
+			return deletionPlan, false, ctx.Err()
+		case <-time.After(deleteOptions.StopGrace):
+		}
+	}
+	if deleteOptions.Force {
+		return deletionPlan, true, nil
+	}
+	if volumes := deletionPlan.VolumesDeletedOnTermination(); len(volumes) != 0 {
+		fmt.Println(pretty.Danger("The following volumes are deleted when their instances are terminated:"))
+		fmt.Println(pretty.Table(volumes, false))
+	}
+	confirmed, err := confirm("terminate the stopped instances", lo.CoalesceOrEmpty(deleteOptions.Name, globalOpts.Namespace), true)
+	return deletionPlan, confirmed, err
+}
+
+// confirmDeletion asks the user to confirm the deletion, a destructive plan is only confirmed by typing yes or its name
+func confirmDeletion(summary plans.Summary, name string) (bool, error) {
+	return confirm("proceed with deletion", name, summary.Destructive())
+}
+
+// stdin is shared by the prompts so that input buffered by one is not lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks the user to confirm the action. A typed confirmation only accepts yes or the name, otherwise any answer that starts with y confirms.
+func confirm(action, name string, typed bool) (bool, error) {
+	if typed {
+		fmt.Printf("Type yes or %s to %s: ", name, action)
+	} else {
+		fmt.Printf("%s%s? ", strings.ToUpper(action[:1]), action[1:])
+	}
+	userInput, err := stdin.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+	userInput = strings.TrimSpace(userInput)
+	if typed {
+		return strings.EqualFold(userInput, "yes") || userInput == name, nil
+	}
+	return strings.HasPrefix(strings.ToLower(userInput), "y"), nil
+}
@@ -0,0 +1,290 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/presets"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/keypairs"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/userdata"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// callerIPURL returns the public IP address of the caller
+	callerIPURL = "https://checkip.amazonaws.com"
+	// devGroup is the node group of the dev instance, it carries the SSH ingress rule
+	devGroup = "dev"
+	// devSSHUser is the SSH user of the default Amazon Linux AMIs
+	devSSHUser = "ec2-user"
+	// devAddressTimeout is how long to wait for the dev instance to be running with an address
+	devAddressTimeout = 5 * time.Minute
+)
+
+// DevOptions are the options of the dev command
+type DevOptions struct {
+	Name                 string
+	TTL                  time.Duration
+	InstanceTypeSelector string
+	PublicKey            string
+	IAMRole              string
+	AllowCIDR            string
+	UserData             string
+	Preset               string
+}
+
+var (
+	devOptions = DevOptions{}
+	cmdDev     = &cobra.Command{
+		Use:   "dev",
+		Short: "Launch an ephemeral dev instance",
+		Long: `Launch a single small Spot instance for development and print the SSH command to reach it.
+Your SSH public key is imported as a key pair, only your public IP is allowed to SSH, and the instance terminates itself after the TTL.`,
+		Example: `  nimbus dev
+  nimbus dev --name scratch --ttl 2h --instance-types 'vcpus:4,arch:arm64' --iam-role dev-ssm
+  nimbus dev --name notebook --preset jupyter --instance-types 'vcpus:4-8,memory:16GiB-32GiB'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return dev(ctx, devOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdDev)
+	cmdDev.Flags().StringVar(&devOptions.Name, "name", "dev", "Name of the VM")
+	cmdDev.Flags().DurationVar(&devOptions.TTL, "ttl", 8*time.Hour, "How long the instance lives before it terminates itself")
+	cmdDev.Flags().StringVar(&devOptions.InstanceTypeSelector, "instance-types", "vcpus:2,memory:4GiB-8GiB", "Instance Type Criteria of the Spot instance")
+	cmdDev.Flags().StringVar(&devOptions.PublicKey, "public-key", "", "SSH public key file to import as the instance's key pair (default ~/.ssh/id_ed25519.pub, ~/.ssh/id_ecdsa.pub, or ~/.ssh/id_rsa.pub)")
+	cmdDev.Flags().StringVar(&devOptions.IAMRole, "iam-role", "", "IAM Role of the instance, it must allow SSM Session Manager for nimbus ssh and exec, e.g. with the AmazonSSMManagedInstanceCore policy")
+	cmdDev.Flags().StringVar(&devOptions.AllowCIDR, "allow-cidr", "", "CIDR allowed to SSH to the instance (default your public IP)")
+	cmdDev.Flags().StringVar(&devOptions.UserData, "user-data", "", "Shell script User Data, a file containing it, or an http(s) URL to download it from to run at boot, e.g. to install tools")
+	cmdDev.Flags().StringVar(&devOptions.Preset, "preset", "", fmt.Sprintf("Set up a remote tool on the instance and print how to connect to it: %s", strings.Join(presets.Names(), " or ")))
+}
+
+func dev(ctx context.Context, devOptions DevOptions, globalOpts GlobalOptions) error {
+	if devOptions.TTL <= 0 {
+		return fmt.Errorf("a dev instance requires a positive ttl")
+	}
+	publicKeyPath, err := devPublicKey(devOptions.PublicKey)
+	if err != nil {
+		return err
+	}
+	allowCIDR := devOptions.AllowCIDR
+	if allowCIDR == "" {
+		allowCIDR, err = callerCIDR(ctx)
+		if err != nil {
+			return fmt.Errorf("unable to allow-list your IP, set --allow-cidr: %w", err)
+		}
+	}
+	instanceTypeSelectors, err := instancetypes.ParseSelectors(devOptions.InstanceTypeSelector)
+	if err != nil {
+		return err
+	}
+	userData, err := userdata.Load(ctx, devOptions.UserData)
+	if err != nil {
+		return err
+	}
+	var preset presets.Preset
+	var token string
+	if devOptions.Preset != "" {
+		if userData != "" {
+			return fmt.Errorf("--user-data and --preset are mutually exclusive")
+		}
+		preset, err = presets.Get(devOptions.Preset)
+		if err != nil {
+			return err
+		}
+		token, err = presets.NewToken()
+		if err != nil {
+			return err
+		}
+		userData, err = preset.UserData(token)
+		if err != nil {
+			return err
+		}
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+	vmClient := vm.New(awsCfg)
+
+	keyName, err := devKeyPair(ctx, vmClient, globalOpts.Namespace, publicKeyPath)
+	if err != nil {
+		return err
+	}
+
+	launchPlan, err := vmClient.Launch(ctx, false, plans.LaunchPlan{
+		Metadata: plans.LaunchMetadata{
+			Namespace: globalOpts.Namespace,
+			Name:      devOptions.Name,
+		},
+		Spec: plans.LaunchSpec{
+			CapacityType:          "spot",
+			IAMRole:               devOptions.IAMRole,
+			InstanceTypeSelectors: instanceTypeSelectors,
+			UserData:              userData,
+			KeyName:               keyName,
+			TTL:                   devOptions.TTL,
+			NodeGroups: []plans.NodeGroup{{
+				Name:  devGroup,
+				Count: 1,
+				IngressRules: []securitygroups.IngressRule{
+					{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: allowCIDR},
+				},
+			}},
+		},
+	})
+	printPlan(launchPlan, globalOpts)
+	if err != nil {
+		return err
+	}
+	if globalOpts.Output == OutputJSON || globalOpts.Output == OutputYAML {
+		return nil
+	}
+
+	fmt.Printf("Launched %s/%s, waiting for its address...\n", globalOpts.Namespace, devOptions.Name)
+	instance, err := waitForAddress(ctx, vmClient, globalOpts.Namespace, devOptions.Name)
+	if err != nil {
+		return err
+	}
+	address := lo.CoalesceOrEmpty(lo.FromPtr(instance.PublicIpAddress), lo.FromPtr(instance.PrivateIpAddress))
+	identityFile := strings.TrimSuffix(publicKeyPath, ".pub")
+	fmt.Printf("\n  ssh -i %s %s@%s\n\n", identityFile, devSSHUser, address)
+	if devOptions.Preset != "" {
+		instructions, err := preset.Instructions(presets.Connection{
+			Host:         fmt.Sprintf("nimbus-%s-%s", globalOpts.Namespace, devOptions.Name),
+			Address:      address,
+			User:         devSSHUser,
+			IdentityFile: identityFile,
+			Token:        token,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Println(instructions)
+	}
+	if instance.PublicIpAddress == nil {
+		fmt.Println("The instance has no public IP, connect from within its VPC or with nimbus ssh")
+	}
+	fmt.Printf("It terminates itself at %s, delete it sooner with: nimbus delete --name %s\n", time.Now().Add(devOptions.TTL).Format(time.Kitchen), devOptions.Name)
+	return nil
+}
+
+// devPublicKey returns the public key file to import, the first of the default SSH keys that exists if none is specified
+func devPublicKey(publicKeyPath string) (string, error) {
+	if publicKeyPath != "" {
+		return publicKeyPath, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	for _, keyFile := range []string{"id_ed25519.pub", "id_ecdsa.pub", "id_rsa.pub"} {
+		path := filepath.Join(home, ".ssh", keyFile)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no SSH public key found in %s, create one with ssh-keygen or set --public-key", filepath.Join(home, ".ssh"))
+}
+
+// devKeyPair returns the name of the namespace's key pair of the public key, importing it the first time.
+// The name is derived from the key's contents so that every dev instance launched with the same key shares the key pair.
+func devKeyPair(ctx context.Context, vmClient vm.AWSVM, namespace, publicKeyPath string) (string, error) {
+	publicKey, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read public key: %w", err)
+	}
+	keyName := fmt.Sprintf("dev-%x", sha256.Sum256([]byte(strings.TrimSpace(string(publicKey)))))[:16]
+	keyPairs, err := vmClient.KeyPairs(ctx, namespace, []keypairs.Selector{{Name: keyName}})
+	if err != nil {
+		return "", err
+	}
+	if len(keyPairs) != 0 {
+		return keyName, nil
+	}
+	if _, err := vmClient.ImportKeyPair(ctx, namespace, keyName, publicKeyPath); err != nil {
+		return "", err
+	}
+	return keyName, nil
+}
+
+// callerCIDR returns the caller's public IP address as a single address CIDR
+func callerCIDR(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, callerIPURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if resp.StatusCode != http.StatusOK || ip == nil {
+		return "", fmt.Errorf("unexpected response from %s: %s", callerIPURL, resp.Status)
+	}
+	if ip.To4() != nil {
+		return ip.String() + "/32", nil
+	}
+	return ip.String() + "/128", nil
+}
+
+// waitForAddress waits until the VM's instance is running with an IP address
+func waitForAddress(ctx context.Context, vmClient vm.AWSVM, namespace, name string) (instances.Instance, error) {
+	ctx, cancel := context.WithTimeout(ctx, devAddressTimeout)
+	defer cancel()
+	for {
+		vmInstances, err := vmClient.List(ctx, namespace, name)
+		if err != nil {
+			return instances.Instance{}, err
+		}
+		if instance, ok := lo.Find(vmInstances, func(instance instances.Instance) bool {
+			return instance.State != nil && instance.State.Name == ec2types.InstanceStateNameRunning && (instance.PublicIpAddress != nil || instance.PrivateIpAddress != nil)
+		}); ok {
+			return instance, nil
+		}
+		select {
+		case <-ctx.Done():
+			return instances.Instance{}, fmt.Errorf("%s/%s did not become running: %w", namespace, name, ctx.Err())
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
@@ -0,0 +1,96 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+var cmdDiff = &cobra.Command{
+	Use:   "diff -f PLAN",
+	Short: "Show what applying a launch plan would change",
+	Long: `Resolve the plan's selectors without launching anything and diff the launch template data and fleet overrides that it would launch with
+against the ones that each node group was last launched with. Removed lines are prefixed with - and added lines with +.`,
+	Example: `  nimbus diff -f plan.yaml`,
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+		return diff(ctx, globalOpts)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cmdDiff)
+}
+
+func diff(ctx context.Context, globalOpts GlobalOptions) error {
+	if globalOpts.ConfigFile == "" {
+		return fmt.Errorf("-f must be specified with the plan to diff")
+	}
+	launchPlan, err := plans.LoadLaunchPlan(globalOpts.ConfigFile)
+	if err != nil {
+		return err
+	}
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+	vmClient := vm.New(awsCfg)
+
+	planDiff, err := vmClient.Diff(ctx, launchPlan)
+	if err != nil {
+		return err
+	}
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(planDiff))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(planDiff))
+	default:
+		printPlanDiff(planDiff)
+	}
+	return nil
+}
+
+// printPlanDiff prints the field diffs of every node group
+func printPlanDiff(planDiff vm.PlanDiff) {
+	if planDiff.Action == plans.ReconcileNone {
+		fmt.Printf("%s/%s is up to date, nothing would change\n", planDiff.Namespace, planDiff.Name)
+		return
+	}
+	for _, groupDiff := range planDiff.NodeGroups {
+		header := fmt.Sprintf("%s/%s", planDiff.Namespace, planDiff.Name)
+		if groupDiff.Group != "" {
+			header = fmt.Sprintf("%s/%s", header, groupDiff.Group)
+		}
+		switch {
+		case groupDiff.Fleet == "":
+			fmt.Printf("%s has not been launched\n", header)
+		case len(groupDiff.Diffs) == 0:
+			fmt.Printf("%s matches fleet %s and launch template %s\n", header, groupDiff.Fleet, groupDiff.LaunchTemplate)
+			continue
+		default:
+			fmt.Printf("%s differs from fleet %s and launch template %s\n", header, groupDiff.Fleet, groupDiff.LaunchTemplate)
+		}
+		fmt.Print(pretty.Diff(groupDiff.Diffs))
+	}
+	fmt.Printf("Applying the plan would %s instances\n", planDiff.Action)
+}
@@ -0,0 +1,123 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/events"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type EventsOptions struct {
+	Name   string
+	PlanID string
+	Watch  bool
+	Delete bool
+}
+
+var (
+	eventsOptions = EventsOptions{}
+	cmdEvents     = &cobra.Command{
+		Use:   "events",
+		Short: "Show EC2 spot interruption, rebalance, and state change events of VMs",
+		Long: `Show the EC2 spot interruption warnings, rebalance recommendations, and state change notifications of the VMs in a namespace.
+The events are captured by an EventBridge rule that forwards them to an SQS queue of the namespace. The rule and queue are created the first time, so only events after that are shown.
+Shown events are removed from the queue, including the events of other VMs in the namespace when --name is set. Queued events are kept for 24 hours.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return showEvents(ctx, eventsOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdEvents)
+	cmdEvents.Flags().StringVar(&eventsOptions.Name, "name", "", "Name of the VM, defaults to all VMs in the namespace")
+	cmdEvents.Flags().StringVar(&eventsOptions.PlanID, "plan-id", "", "Only show the events of the instances launched by the executed plan with this ID")
+	cmdEvents.Flags().BoolVarP(&eventsOptions.Watch, "watch", "w", false, "Stream events as they happen until interrupted")
+	cmdEvents.Flags().BoolVar(&eventsOptions.Delete, "delete", false, "Delete the namespace's event rule and queue instead of showing events")
+}
+
+func showEvents(ctx context.Context, eventsOptions EventsOptions, globalOpts GlobalOptions) error {
+	if eventsOptions.PlanID != "" {
+		if _, err := plans.ParsePlanID(eventsOptions.PlanID); err != nil {
+			return err
+		}
+	}
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	if eventsOptions.Delete {
+		if err := vmClient.DeleteEvents(ctx, globalOpts.Namespace); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted the event rule and queue of namespace %s\n", globalOpts.Namespace)
+		return nil
+	}
+
+	eventsChan, err := vmClient.Events(ctx, globalOpts.Namespace, eventsOptions.Name, strings.ToUpper(eventsOptions.PlanID), eventsOptions.Watch)
+	if err != nil {
+		return err
+	}
+
+	if eventsOptions.Watch {
+		for event := range eventsChan {
+			switch globalOpts.Output {
+			case OutputJSON, OutputYAML:
+				// one event per line so the stream can be piped to tools like jq
+				line, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(line))
+			default:
+				fmt.Printf("%s  %-24s %-20s %s\n", event.Time.Local().Format(time.DateTime), event.Type, event.InstanceID, event.Detail)
+			}
+		}
+		return nil
+	}
+
+	var eventList []events.Event
+	for event := range eventsChan {
+		eventList = append(eventList, event)
+	}
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(eventList))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(eventList))
+	default:
+		if len(eventList) == 0 {
+			fmt.Println("No events")
+			return nil
+		}
+		fmt.Println(pretty.Table(eventList, globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
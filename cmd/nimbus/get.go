@@ -16,19 +16,32 @@ package main
 import (
 	"context"
 	"fmt"
+	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
 	"github.com/bwagner5/nimbus/pkg/pretty"
 	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/selectors"
 	"github.com/bwagner5/nimbus/pkg/tui"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/bwagner5/nimbus/pkg/vm"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
 )
 
 type GetOptions struct {
-	Name string `table:"Name"`
+	Name    string `table:"Name"`
+	PlanID  string
+	Filters []string
+	// RuleSelector filters the security group rules that get security-group-rules lists
+	RuleSelector string
+	// InstanceTypeSelector selects the instance types that get instance-types lists
+	InstanceTypeSelector string
 }
 
 var (
@@ -43,11 +56,59 @@ var (
 			return get(ctx, getOptions, globalOpts)
 		},
 	}
+	cmdGetSecurityGroupRules = &cobra.Command{
+		Use:   "security-group-rules",
+		Short: "List the rules of the security groups in the namespace",
+		Long: `List the ingress and egress rules of the security groups that nimbus created in the namespace, or for the plan with --name,
+to audit what traffic they allow. Rules can be narrowed with a selector of sg-id, direction, and port.`,
+		Example: `  nimbus get security-group-rules --rules 'direction:ingress,port:22'
+  nimbus get security-group-rules --rules 'sg-id:sg-0123456'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return getSecurityGroupRules(ctx, getOptions, globalOpts)
+		},
+	}
+	cmdGetSecurityGroups = &cobra.Command{
+		Use:   "security-groups",
+		Short: "List the security groups in the namespace with their rules",
+		Long: `List the security groups that nimbus created in the namespace, or for the plan with --name, with how many ingress and egress rules they have.
+The wide output adds a summary of every rule, use get security-group-rules to narrow them down.`,
+		Example: `  nimbus get security-groups -o wide
+  nimbus get security-groups --name web`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return getSecurityGroups(ctx, getOptions, globalOpts)
+		},
+	}
+	cmdGetInstanceTypes = &cobra.Command{
+		Use:   "instance-types",
+		Short: "List the instance types that an instance type selector resolves to",
+		Long: `List the instance types that an instance type selector resolves to with their vCPUs, memory, and architectures.
+The wide output adds their network and EBS bandwidth, EBS NVMe support, accelerators, and hourly Linux on-demand and lowest spot prices.`,
+		Example: `  nimbus get instance-types --instance-types 'vcpus:2-4,arch:arm64'
+  nimbus get instance-types --instance-types 'gpus:1-' -o wide`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return getInstanceTypes(ctx, getOptions, globalOpts)
+		},
+	}
 )
 
 func init() {
 	rootCmd.AddCommand(cmdGet)
 	cmdGet.Flags().StringVar(&getOptions.Name, "name", "", "Name of the VM")
+	cmdGet.Flags().StringArrayVar(&getOptions.Filters, "filter", nil, "Raw EC2 DescribeInstances filter, can be repeated. e.g. --filter 'Name=instance-type,Values=m5.large,m5.xlarge'")
+	cmdGet.Flags().StringVar(&getOptions.PlanID, "plan-id", "", "Only get the instances launched by the executed plan with this ID")
+	cmdGet.AddCommand(cmdGetSecurityGroupRules)
+	cmdGetSecurityGroupRules.Flags().StringVar(&getOptions.Name, "name", "", "Name of the plan whose security groups to list the rules of")
+	cmdGet.AddCommand(cmdGetSecurityGroups)
+	cmdGetSecurityGroups.Flags().StringVar(&getOptions.Name, "name", "", "Name of the plan whose security groups to list")
+	cmdGet.AddCommand(cmdGetInstanceTypes)
+	cmdGetInstanceTypes.Flags().StringVar(&getOptions.InstanceTypeSelector, "instance-types", "", "Instance Type Criteria e.g. --instance-types 'vcpus:2-6,arch:arm64,local-storage:100GiB-', pin or ban names and globs with 'types:m5.large,c5.*' and 'exclude:t2*,t3*'")
+	cmdGetSecurityGroupRules.Flags().StringVar(&getOptions.RuleSelector, "rules", "", "Security group rule selector of sg-id, direction, and port. e.g. --rules 'direction:ingress,port:22'")
 }
 
 func get(ctx context.Context, getOptions GetOptions, globalOpts GlobalOptions) error {
@@ -62,17 +123,25 @@ func get(ctx context.Context, getOptions GetOptions, globalOpts GlobalOptions) e
 		return tui.Launch(ctx, vmClient, "get", globalOpts.Namespace, getOptions.Name, globalOpts.Verbose)
 	}
 
-	instanceList, err := vmClient.List(ctx, globalOpts.Namespace, getOptions.Name)
+	filters, err := selectors.ParseEC2Filters(getOptions.Filters)
 	if err != nil {
 		return err
 	}
-
-	instancesUI := lo.FilterMap(instanceList, func(instance instances.Instance, _ int) (instances.PrettyInstance, bool) {
-		if instance.State.Name == ec2types.InstanceStateNameTerminated {
-			return instances.PrettyInstance{}, false
+	if getOptions.PlanID != "" {
+		if _, err := plans.ParsePlanID(getOptions.PlanID); err != nil {
+			return err
 		}
-		return instance.Prettify(), true
-	})
+		filters = append(filters, ec2types.Filter{Name: aws.String("tag:" + tagutils.PlanIDTagKey), Values: []string{strings.ToUpper(getOptions.PlanID)}})
+	}
+
+	instanceList, err := vmClient.List(ctx, globalOpts.Namespace, getOptions.Name, filters...)
+	if err != nil {
+		return err
+	}
+
+	instancesUI := instances.PrettifyAll(lo.Reject(instanceList, func(instance instances.Instance, _ int) bool {
+		return instance.State.Name == ec2types.InstanceStateNameTerminated
+	}))
 
 	switch globalOpts.Output {
 	case OutputJSON:
@@ -86,3 +155,96 @@ func get(ctx context.Context, getOptions GetOptions, globalOpts GlobalOptions) e
 	}
 	return nil
 }
+
+func getSecurityGroupRules(ctx context.Context, getOptions GetOptions, globalOpts GlobalOptions) error {
+	ruleSelectors, err := securitygroups.ParseRuleSelectors(getOptions.RuleSelector)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	rules, err := vmClient.SecurityGroupRules(ctx, globalOpts.Namespace, getOptions.Name, ruleSelectors)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(rules))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(rules))
+	default:
+		fmt.Println(pretty.Table(securitygroups.PrettifyRules(rules), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
+
+func getSecurityGroups(ctx context.Context, getOptions GetOptions, globalOpts GlobalOptions) error {
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	securityGroups, err := vmClient.SecurityGroups(ctx, globalOpts.Namespace, getOptions.Name)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(securityGroups))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(securityGroups))
+	default:
+		fmt.Println(pretty.Table(securitygroups.PrettifySecurityGroups(securityGroups), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
+
+func getInstanceTypes(ctx context.Context, getOptions GetOptions, globalOpts GlobalOptions) error {
+	if getOptions.InstanceTypeSelector == "" {
+		return fmt.Errorf("--instance-types must be specified")
+	}
+	instanceTypeSelectors, err := instancetypes.ParseSelectors(getOptions.InstanceTypeSelector)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	// prices take a Pricing API call per instance type, so they are only resolved for the outputs that show them
+	withPrices := globalOpts.Output == OutputTableWide || globalOpts.Output == OutputJSON || globalOpts.Output == OutputYAML
+	details, err := vmClient.InstanceTypes(ctx, instanceTypeSelectors, withPrices)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(details))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(details))
+	default:
+		if len(details) == 0 {
+			// the selector already parsed, so it canonicalizes
+			canonical, _ := selectors.Canonicalize(getOptions.InstanceTypeSelector)
+			fmt.Printf("No instance types match the selector %s\n", canonical)
+			return nil
+		}
+		fmt.Println(pretty.Table(vm.PrettifyInstanceTypeDetails(details), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+)
+
+type IdleOptions struct {
+	Name             string
+	Threshold        string
+	NetworkThreshold string
+	Window           string
+	Stop             bool
+	ForceUnlock      bool
+}
+
+var (
+	idleOptions = IdleOptions{}
+	cmdIdle     = &cobra.Command{
+		Use:   "idle",
+		Short: "Find idle VMs",
+		Long: `Find running VMs whose average CPU utilization, from CloudWatch, is below the threshold over the window.
+Idle VMs with little network traffic are recommended to be stopped, the others to be downsized to an instance type with fewer vCPUs.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return idle(ctx, idleOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdIdle)
+	cmdIdle.Flags().StringVar(&idleOptions.Name, "name", "", "Name of the VMs")
+	cmdIdle.Flags().StringVar(&idleOptions.Threshold, "threshold", "5%", "Average CPU utilization below which a VM is idle")
+	cmdIdle.Flags().StringVar(&idleOptions.NetworkThreshold, "network-threshold", "10KB", "Average network bytes per second below which an idle VM is recommended to be stopped rather than downsized")
+	cmdIdle.Flags().StringVar(&idleOptions.Window, "window", "7d", "How far back to average utilization, like 12h or 7d. CloudWatch retains 15 months of EC2 metrics")
+	cmdIdle.Flags().BoolVar(&idleOptions.Stop, "stop", false, "Stop the idle VMs that are recommended to be stopped")
+	cmdIdle.Flags().BoolVar(&idleOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+}
+
+func idle(ctx context.Context, idleOptions IdleOptions, globalOpts GlobalOptions) (err error) {
+	threshold, err := parsePercent(idleOptions.Threshold)
+	if err != nil {
+		return err
+	}
+	networkThreshold, err := bytesize.Parse(idleOptions.NetworkThreshold)
+	if err != nil {
+		return fmt.Errorf("invalid --network-threshold: %w", err)
+	}
+	window, err := parseWindow(idleOptions.Window)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	// finding idle VMs does not change anything, so only stopping them takes the lock
+	if idleOptions.Stop {
+		unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, idleOptions.Name, "stop", idleOptions.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer func() { err = errors.Join(err, unlock()) }()
+	}
+
+	idleInstances, err := vmClient.Idle(ctx, globalOpts.Namespace, idleOptions.Name, vm.IdleOptions{
+		CPUThreshold:     threshold,
+		NetworkThreshold: networkThreshold,
+		Window:           window,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(idleInstances))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(idleInstances))
+	default:
+		if len(idleInstances) == 0 {
+			fmt.Println("No idle VMs found")
+			return nil
+		}
+		fmt.Println(pretty.Table(vm.PrettifyIdle(idleInstances), globalOpts.Output == OutputTableWide))
+	}
+
+	if !idleOptions.Stop {
+		return nil
+	}
+	stoppable := lo.Filter(idleInstances, func(idleInstance vm.IdleInstance, _ int) bool {
+		return idleInstance.Recommendation == vm.RecommendStop
+	})
+	if len(stoppable) == 0 {
+		return nil
+	}
+	stopped, err := vmClient.Stop(ctx, globalOpts.Namespace, idleOptions.Name, lo.Map(stoppable, func(idleInstance vm.IdleInstance, _ int) instances.Selector {
+		return instances.Selector{ID: *idleInstance.Instance.InstanceId}
+	}), false)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("Stopped idle VMs", "instance-ids", lo.Map(stopped, func(instance instances.Instance, _ int) string { return *instance.InstanceId }))
+	return nil
+}
+
+// parsePercent parses a percentage like 5% or 5
+func parsePercent(percentStr string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(percentStr), "%"), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return 0, fmt.Errorf("invalid percentage %q, expected a number between 0 and 100 like 5%%", percentStr)
+	}
+	return percent, nil
+}
+
+// parseWindow parses a duration like time.ParseDuration that also accepts a whole number of days like 7d
+func parseWindow(windowStr string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(windowStr, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid window %q, expected a duration like 12h or 7d", windowStr)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		return 0, fmt.Errorf("invalid window %q, expected a duration like 12h or 7d", windowStr)
+	}
+	return window, nil
+}
@@ -0,0 +1,215 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type ImagesOptions struct {
+	Alias string
+}
+
+// ImageBuildOptions are the options of the images build command
+type ImageBuildOptions struct {
+	Name                 string
+	ImageName            string
+	Description          string
+	Script               string
+	InstanceTypeSelector string
+	AMISelector          string
+	CapacityType         string
+	IAMRole              string
+	Timeout              time.Duration
+	ImageTimeout         time.Duration
+	Keep                 bool
+}
+
+var (
+	imagesOptions     = ImagesOptions{}
+	imageBuildOptions = ImageBuildOptions{}
+	cmdImages         = &cobra.Command{
+		Use:     "images",
+		Aliases: []string{"image"},
+		Short:   "Inspect the AMIs that nimbus launches instances with and build new ones",
+		Args:    cobra.NoArgs,
+	}
+	cmdImagesResolve = &cobra.Command{
+		Use:   "resolve",
+		Short: "Print the AMI of each architecture that an alias resolves to in the current region",
+		Long: `Print the AMI of each architecture that an AMI alias resolves to in the current region, which are the AMIs that a launch with --amis 'alias:<alias>' would use.
+Instances of arm64 instance types are launched with the arm64 AMI and instances of x86_64 instance types with the x86_64 AMI.
+The ubuntu, bottlerocket, eks, and windows aliases take an optional version as <alias>@<version> or <alias>-<version>,
+bottlerocket and eks versions are Kubernetes versions.`,
+		Example: `  nimbus images resolve --alias al2023
+  nimbus images resolve --alias ubuntu@22.04
+  nimbus images resolve --alias eks-1.31`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return resolveImages(ctx, imagesOptions, globalOpts)
+		},
+	}
+	cmdImagesBuild = &cobra.Command{
+		Use:   "build [flags] [-- COMMAND [ARGS...]]",
+		Short: "Bake an AMI from a base AMI and a provisioning command or script",
+		Long: `Launch a builder instance from a base AMI that runs a provisioning command or script from its user-data, stream its output,
+create an AMI from the builder once the script exits with 0, and delete the builder when the AMI is available.
+The AMI and its snapshots are tagged with the namespace and the name of the build, so it can be launched with e.g. --amis 'tag:nimbus-Name=<name>'.
+The provisioning output is read through SSM, so the IAM role must allow SSM, e.g. with the AmazonSSMManagedInstanceCore policy.`,
+		Example: `  nimbus images build --iam-role batch-ssm --amis 'alias:al2023' -- 'dnf install -y nginx && systemctl enable nginx'
+  nimbus image build --iam-role batch-ssm --name web --image-name web-v2 --script provision.sh --amis 'alias:ubuntu@24.04'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return buildImage(ctx, imageBuildOptions, globalOpts, strings.Join(args, " "))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdImages)
+	cmdImages.AddCommand(cmdImagesResolve)
+	cmdImagesResolve.Flags().StringVar(&imagesOptions.Alias, "alias", "", fmt.Sprintf("AMI alias to resolve: %s", strings.Join(amis.Aliases(), ", ")))
+	cmdImages.AddCommand(cmdImagesBuild)
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.Name, "name", "", "Name of the builder VM that the image is tagged with (default build-<timestamp>)")
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.ImageName, "image-name", "", "Name of the AMI, it must be unique in the account and region (default <name>-<timestamp>)")
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.Description, "description", "", "Description of the AMI")
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.Script, "script", "", "File containing the provisioning script to run instead of a command, a script with a shebang runs with its interpreter")
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.InstanceTypeSelector, "instance-types", "vcpus:2,memory:4GiB-8GiB", "Instance Type Criteria of the builder, the AMI has the builder's architecture")
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.AMISelector, "amis", "", "AMI selector of the base AMI, it must run shell script user-data. e.g. --amis 'alias:al2023'")
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.CapacityType, "capacity-type", "on-demand", "Spot or On-Demand, a spot interruption fails the build")
+	cmdImagesBuild.Flags().StringVar(&imageBuildOptions.IAMRole, "iam-role", "", "IAM Role of the builder, it must allow SSM to stream the provisioning output, e.g. with the AmazonSSMManagedInstanceCore policy")
+	cmdImagesBuild.Flags().DurationVar(&imageBuildOptions.Timeout, "timeout", time.Hour, "How long provisioning may run once the builder is reachable before the build fails")
+	cmdImagesBuild.Flags().DurationVar(&imageBuildOptions.ImageTimeout, "image-timeout", 30*time.Minute, "How long the AMI may take to become available once it is created")
+	cmdImagesBuild.Flags().BoolVar(&imageBuildOptions.Keep, "keep", false, "Keep the builder VM after the build, e.g. to inspect a failed build, it still terminates itself after the timeouts")
+}
+
+func resolveImages(ctx context.Context, imagesOptions ImagesOptions, globalOpts GlobalOptions) error {
+	if imagesOptions.Alias == "" {
+		return fmt.Errorf("--alias must be specified")
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	amiList, err := vmClient.ResolveAlias(ctx, imagesOptions.Alias)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(amis.ArchitectureIDs(amiList)))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(amis.ArchitectureIDs(amiList)))
+	default:
+		if len(amiList) == 0 {
+			fmt.Printf("Alias %s does not resolve to any AMIs in %s\n", imagesOptions.Alias, awsCfg.Region)
+			return nil
+		}
+		fmt.Printf("Alias %s in %s:\n", imagesOptions.Alias, awsCfg.Region)
+		fmt.Println(pretty.Table(amis.PrettifyAll(amiList), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
+
+func buildImage(ctx context.Context, imageBuildOptions ImageBuildOptions, globalOpts GlobalOptions, command string) error {
+	script, err := runScript(imageBuildOptions.Script, command)
+	if err != nil {
+		return err
+	}
+	if imageBuildOptions.IAMRole == "" {
+		return fmt.Errorf("--iam-role must be specified, the provisioning output is read through SSM")
+	}
+	instanceTypeSelectors, err := instancetypes.ParseSelectors(imageBuildOptions.InstanceTypeSelector)
+	if err != nil {
+		return err
+	}
+	amiSelectors, err := amis.ParseSelectors(imageBuildOptions.AMISelector)
+	if err != nil {
+		return err
+	}
+	now := time.Now().Unix()
+	name := imageBuildOptions.Name
+	if name == "" {
+		name = fmt.Sprintf("build-%d", now)
+	}
+	imageName := imageBuildOptions.ImageName
+	if imageName == "" {
+		imageName = fmt.Sprintf("%s-%d", name, now)
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+	vmClient := vm.New(awsCfg)
+
+	fmt.Fprintf(os.Stderr, "Building %s on %s/%s, the provisioning output follows once the builder is reachable...\n", imageName, globalOpts.Namespace, name)
+	result, err := vmClient.BuildImage(ctx, plans.LaunchPlan{
+		Metadata: plans.LaunchMetadata{
+			Namespace: globalOpts.Namespace,
+			Name:      name,
+		},
+		Spec: plans.LaunchSpec{
+			CapacityType:          imageBuildOptions.CapacityType,
+			IAMRole:               imageBuildOptions.IAMRole,
+			InstanceTypeSelectors: instanceTypeSelectors,
+			AMISelectors:          amiSelectors,
+		},
+	}, vm.ImageBuild{
+		ImageName:   imageName,
+		Description: imageBuildOptions.Description,
+		Job: vm.Job{
+			Script:  script,
+			Timeout: imageBuildOptions.Timeout,
+			Keep:    imageBuildOptions.Keep,
+		},
+		ImageTimeout: imageBuildOptions.ImageTimeout,
+	}, os.Stdout)
+	if imageBuildOptions.Keep {
+		fmt.Fprintf(os.Stderr, "Delete the builder VM with: nimbus delete --name %s\n", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(result))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(result))
+	default:
+		fmt.Fprintf(os.Stderr, "Built %s (%s) on %s, provisioning took %s\n", result.ImageID, result.ImageName, result.InstanceID, pretty.Duration(result.Duration))
+		fmt.Fprintf(os.Stderr, "Launch it with: nimbus launch --amis 'id:%s'\n", result.ImageID)
+	}
+	return nil
+}
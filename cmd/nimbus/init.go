@@ -0,0 +1,95 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type InitOptions struct {
+	Destroy bool
+	Force   bool
+}
+
+var (
+	initOptions = InitOptions{}
+	cmdInit     = &cobra.Command{
+		Use:   "init",
+		Short: "Provision the account prerequisites of nimbus's advanced features",
+		Long: `Provision the account prerequisites that nimbus's advanced features share, in the region:
+  - the S3 bucket that jobs stage their artifacts in
+  - the EventBridge rule and SQS queue that watch and the TUI receive the namespace's EC2 events from
+  - the ` + vm.DefaultInstanceRoleName + ` IAM role, which lets VMs launched with --iam-role ` + vm.DefaultInstanceRoleName + ` be managed by SSM for ssh, exec, and --wait-for
+The AWS managed SSM documents that exec runs are checked, they do not need to be created.
+The bucket and event rule are also created on first use, init provisions everything up front, e.g. by an administrator, and is safe to run again.
+With --destroy, the prerequisites are deleted instead. The bucket is kept while it has artifacts and the role while VMs use it.`,
+		Example: `  nimbus init
+  nimbus init --namespace team-a
+  nimbus init --destroy`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return initAccount(ctx, initOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdInit)
+	cmdInit.Flags().BoolVar(&initOptions.Destroy, "destroy", false, "Delete the prerequisites that init provisioned instead")
+	cmdInit.Flags().BoolVar(&initOptions.Force, "force", false, "Don't ask before deleting the prerequisites with --destroy")
+}
+
+func initAccount(ctx context.Context, initOptions InitOptions, globalOpts GlobalOptions) error {
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	var prerequisites []vm.Prerequisite
+	if initOptions.Destroy {
+		if !initOptions.Force {
+			confirmed, err := confirm(fmt.Sprintf("delete the nimbus prerequisites of namespace %s in %s", globalOpts.Namespace, awsCfg.Region), globalOpts.Namespace, true)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				fmt.Println("Aborted")
+				return nil
+			}
+		}
+		prerequisites, err = vmClient.DestroyInit(ctx, globalOpts.Namespace)
+	} else {
+		prerequisites, err = vmClient.Init(ctx, globalOpts.Namespace)
+	}
+	// the prerequisites that were provisioned or deleted before an error are still printed
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(prerequisites))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(prerequisites))
+	default:
+		if len(prerequisites) != 0 {
+			fmt.Println(pretty.Table(prerequisites, globalOpts.Output == OutputTableWide))
+		}
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/keypairs"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type KeyPairOptions struct {
+	Name            string
+	KeyPairSelector string
+	KeyType         string
+	PublicKey       string
+	PrivateKeyFile  string
+}
+
+var (
+	keyPairOptions = KeyPairOptions{}
+	cmdKeyPairs    = &cobra.Command{
+		Use:   "keypairs",
+		Short: "Manage the EC2 key pairs of a namespace",
+		Long:  `Manage the EC2 key pairs that nimbus created or imported in a namespace. Launch with --key-name to use a key pair for SSH access.`,
+		Args:  cobra.NoArgs,
+	}
+	cmdKeyPairsGet = &cobra.Command{
+		Use:   "get",
+		Short: "List the key pairs of the namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return getKeyPairs(ctx, keyPairOptions, globalOpts)
+		},
+	}
+	cmdKeyPairsCreate = &cobra.Command{
+		Use:   "create",
+		Short: "Create a key pair and save its private key",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return createKeyPair(ctx, keyPairOptions, globalOpts)
+		},
+	}
+	cmdKeyPairsImport = &cobra.Command{
+		Use:   "import",
+		Short: "Import a local public key as a key pair",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return importKeyPair(ctx, keyPairOptions, globalOpts)
+		},
+	}
+	cmdKeyPairsDelete = &cobra.Command{
+		Use:   "delete",
+		Short: "Delete a key pair of the namespace",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return deleteKeyPair(ctx, keyPairOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdKeyPairs)
+	cmdKeyPairs.AddCommand(cmdKeyPairsGet, cmdKeyPairsCreate, cmdKeyPairsImport, cmdKeyPairsDelete)
+	cmdKeyPairsGet.Flags().StringVar(&keyPairOptions.KeyPairSelector, "key-pairs", "", "Key pair selector to filter the key pairs of the namespace. e.g. --key-pairs 'name:dev-*'")
+	for _, cmd := range []*cobra.Command{cmdKeyPairsCreate, cmdKeyPairsImport, cmdKeyPairsDelete} {
+		cmd.Flags().StringVar(&keyPairOptions.Name, "name", "", "Name of the key pair")
+	}
+	cmdKeyPairsCreate.Flags().StringVar(&keyPairOptions.KeyType, "key-type", "", "Type of the key pair: ed25519 or rsa (default ed25519)")
+	cmdKeyPairsCreate.Flags().StringVar(&keyPairOptions.PrivateKeyFile, "private-key-file", "", "File to write the private key to (default ~/.ssh/<name>.pem)")
+	cmdKeyPairsImport.Flags().StringVar(&keyPairOptions.PublicKey, "public-key", "~/.ssh/id_ed25519.pub", "OpenSSH public key file to import")
+}
+
+func getKeyPairs(ctx context.Context, keyPairOptions KeyPairOptions, globalOpts GlobalOptions) error {
+	keyPairSelectors, err := keypairs.ParseSelectors(keyPairOptions.KeyPairSelector)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	keyPairs, err := vmClient.KeyPairs(ctx, globalOpts.Namespace, keyPairSelectors)
+	if err != nil {
+		return err
+	}
+	printKeyPairs(keyPairs, globalOpts)
+	return nil
+}
+
+func createKeyPair(ctx context.Context, keyPairOptions KeyPairOptions, globalOpts GlobalOptions) error {
+	privateKeyFile := keyPairOptions.PrivateKeyFile
+	if privateKeyFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("unable to find the home directory, specify --private-key-file: %w", err)
+		}
+		privateKeyFile = filepath.Join(home, ".ssh", keyPairOptions.Name+".pem")
+	}
+	// refuse to overwrite an existing private key before the key pair is created, the private key cannot be retrieved later
+	if _, err := os.Stat(privateKeyFile); err == nil {
+		return fmt.Errorf("private key file %s already exists", privateKeyFile)
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	keyPair, err := vmClient.CreateKeyPair(ctx, globalOpts.Namespace, keyPairOptions.Name, keyPairOptions.KeyType)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(privateKeyFile), 0o700); err != nil {
+		return fmt.Errorf("unable to save the private key of key pair %s: %w", keyPairOptions.Name, err)
+	}
+	if err := os.WriteFile(privateKeyFile, []byte(keyPair.PrivateKey), 0o600); err != nil {
+		return fmt.Errorf("unable to save the private key of key pair %s: %w", keyPairOptions.Name, err)
+	}
+	logging.FromContext(ctx).Info("Saved private key", "file", privateKeyFile)
+	printKeyPairs([]keypairs.KeyPair{keyPair}, globalOpts)
+	return nil
+}
+
+func importKeyPair(ctx context.Context, keyPairOptions KeyPairOptions, globalOpts GlobalOptions) error {
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	keyPair, err := vmClient.ImportKeyPair(ctx, globalOpts.Namespace, keyPairOptions.Name, keyPairOptions.PublicKey)
+	if err != nil {
+		return err
+	}
+	printKeyPairs([]keypairs.KeyPair{keyPair}, globalOpts)
+	return nil
+}
+
+func deleteKeyPair(ctx context.Context, keyPairOptions KeyPairOptions, globalOpts GlobalOptions) error {
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	keyPair, err := vmClient.DeleteKeyPair(ctx, globalOpts.Namespace, keyPairOptions.Name)
+	if err != nil {
+		return err
+	}
+	printKeyPairs([]keypairs.KeyPair{keyPair}, globalOpts)
+	return nil
+}
+
+func printKeyPairs(keyPairs []keypairs.KeyPair, globalOpts GlobalOptions) {
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(keyPairs))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(keyPairs))
+	default:
+		fmt.Println(pretty.Table(keypairs.PrettifyAll(keyPairs), globalOpts.Output == OutputTableWide))
+	}
+}
@@ -15,30 +15,148 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"os"
+	"strings"
+	"time"
 
+	"github.com/bwagner5/nimbus/pkg/artifacts"
 	"github.com/bwagner5/nimbus/pkg/logging"
 	"github.com/bwagner5/nimbus/pkg/plans"
 	"github.com/bwagner5/nimbus/pkg/pretty"
 	"github.com/bwagner5/nimbus/pkg/providers/amis"
 	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/keypairs"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/logs"
 	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
 	"github.com/bwagner5/nimbus/pkg/providers/subnets"
 	"github.com/bwagner5/nimbus/pkg/tui"
+	"github.com/bwagner5/nimbus/pkg/userdata"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// compliancePolicyEnvVar is the default compliance policy file, e.g. set by a CI environment for every launch
+	compliancePolicyEnvVar = "NIMBUS_COMPLIANCE_POLICY"
 )
 
 type LaunchOptions struct {
-	DryRun                bool
-	Name                  string `table:"Name"`
-	CapacityType          string `table:"Capacity Type"`
-	InstanceTypeSelector  string `table:"Instance Type Selector"`
-	SubnetSelector        string `table:"Subnet Selector"`
-	AMISelector           string `table:"OS Image Selector"`
-	IAMRole               string `table:"IAM Role"`
-	SecurityGroupSelector string `table:"Security Group Selector"`
-	UserData              string
+	DryRun                bool                 `yaml:"dryRun"`
+	PlanOut               string               `yaml:"planOut"`
+	Name                  string               `table:"Name" yaml:"name"`
+	Count                 int32                `yaml:"count"`
+	Capacity              string               `yaml:"capacity"`
+	CapacityType          string               `table:"Capacity Type" yaml:"capacityType"`
+	InstanceTypeSelector  string               `table:"Instance Type Selector" yaml:"instanceTypes"`
+	SubnetSelector        string               `table:"Subnet Selector" yaml:"subnets"`
+	AMISelector           string               `table:"OS Image Selector" yaml:"amis"`
+	IAMRole               string               `table:"IAM Role" yaml:"iamRole"`
+	SecurityGroupSelector string               `table:"Security Group Selector" yaml:"securityGroups"`
+	Ingress               string               `yaml:"ingress"`
+	Allow                 string               `yaml:"allow"`
+	AllowFromMyIP         bool                 `yaml:"allowFromMyIP"`
+	UserData              string               `yaml:"userData"`
+	UserDataParts         []string             `yaml:"userDataParts"`
+	UserDataVars          []string             `yaml:"userDataVars"`
+	Artifacts             string               `yaml:"artifacts"`
+	ArtifactsMode         string               `yaml:"artifactsMode"`
+	ArtifactsBucket       string               `yaml:"artifactsBucket"`
+	ShipLogs              string               `yaml:"shipLogs"`
+	UseDefaultVPC         bool                 `yaml:"useDefaultVPC"`
+	NetworkPolicy         string               `yaml:"networkPolicy"`
+	NetworkTopology       string               `yaml:"networkTopology"`
+	VPCCIDR               string               `yaml:"vpcCIDR"`
+	SubnetPrefixLength    int                  `yaml:"subnetPrefixLength"`
+	AZCount               int                  `yaml:"azCount"`
+	AZs                   string               `yaml:"azs"`
+	IPFamily              string               `yaml:"ipFamily"`
+	Naming                string               `yaml:"naming"`
+	VolumeType            string               `yaml:"volumeType"`
+	VolumeSize            int32                `yaml:"volumeSize"`
+	VolumeIOPS            int32                `yaml:"volumeIOPS"`
+	VolumeThroughput      int32                `yaml:"volumeThroughput"`
+	BlockDeviceMappings   string               `yaml:"blockDeviceMappings"`
+	Volumes               string               `yaml:"volumes"`
+	EBSKMSKey             string               `yaml:"ebsKMSKey"`
+	KeyName               string               `yaml:"keyName"`
+	KeyPairSelector       string               `yaml:"keyPairs"`
+	MetadataHTTPTokens    string               `yaml:"metadataHTTPTokens"`
+	MetadataHopLimit      int32                `yaml:"metadataHopLimit"`
+	MetadataTags          string               `yaml:"metadataTags"`
+	NetworkInterfaces     string               `yaml:"networkInterfaces"`
+	PreferReservations    bool                 `yaml:"preferReservations"`
+	SpotAllocation        string               `yaml:"spotAllocationStrategy"`
+	SpotMaxPrice          float64              `yaml:"spotMaxPrice"`
+	OnDemandBase          int32                `yaml:"onDemandBaseCapacity"`
+	OnDemandAboveBase     string               `yaml:"onDemandPercentageAboveBase"`
+	CapacityRebalance     string               `yaml:"capacityRebalance"`
+	TerminationDelay      time.Duration        `yaml:"terminationDelay"`
+	TTL                   time.Duration        `yaml:"ttl"`
+	WaitForBootstrap      bool                 `yaml:"waitForBootstrap"`
+	Wait                  bool                 `yaml:"wait"`
+	WaitFor               string               `yaml:"waitFor"`
+	WaitTimeout           time.Duration        `yaml:"waitTimeout"`
+	Replace               bool                 `yaml:"replace"`
+	Now                   bool                 `yaml:"now"`
+	ForceUnlock           bool                 `yaml:"forceUnlock"`
+	GPUDrivers            string               `yaml:"gpuDrivers"`
+	TimingMetrics         string               `yaml:"timingMetricsNamespace"`
+	Tags                  string               `yaml:"tags"`
+	CompliancePolicy      string               `yaml:"compliancePolicy"`
+	NonInteractive        bool                 `yaml:"nonInteractive"`
+	Placements            string               `yaml:"placements"`
+	Groups                []LaunchGroupOptions `yaml:"groups"`
+}
+
+// LaunchGroupOptions define a node group in a launch manifest passed with -f, e.g.
+//
+//	name: my-cluster
+//	groups:
+//	  - name: controller
+//	    capacityType: on-demand
+//	    readinessPort: 6443
+//	    ingress:
+//	      - allow tcp:6443 from group:worker
+//	  - name: worker
+//	    capacity: 64vcpu
+//	    capacityType: spot
+//	    capacityRebalance: launch-before-terminate
+//	    instanceTypes: 'vcpus:4-8'
+//	    dependsOn: [controller]
+//	    ingress:
+//	      - allow all from group:worker
+//	    userData: |
+//	      #!/bin/bash
+//	      join {{ (index .Groups "controller").PrivateIP }}
+//
+// Unset fields default to the top-level launch options, the allocation fields only default together when none of them are set.
+// A group with dependsOn is launched after its dependencies are ready and its user-data is rendered with their metadata.
+type LaunchGroupOptions struct {
+	Name                 string   `yaml:"name"`
+	Count                int32    `yaml:"count"`
+	Capacity             string   `yaml:"capacity"`
+	CapacityType         string   `yaml:"capacityType"`
+	InstanceTypeSelector string   `yaml:"instanceTypes"`
+	AMISelector          string   `yaml:"amis"`
+	IAMRole              string   `yaml:"iamRole"`
+	UserData             string   `yaml:"userData"`
+	DependsOn            []string `yaml:"dependsOn"`
+	ReadinessPort        int32    `yaml:"readinessPort"`
+	ReadinessTimeout     string   `yaml:"readinessTimeout"`
+	Ingress              []string `yaml:"ingress"`
+	SpotAllocation       string   `yaml:"spotAllocationStrategy"`
+	SpotMaxPrice         float64  `yaml:"spotMaxPrice"`
+	OnDemandBase         int32    `yaml:"onDemandBaseCapacity"`
+	OnDemandAboveBase    string   `yaml:"onDemandPercentageAboveBase"`
+	CapacityRebalance    string   `yaml:"capacityRebalance"`
+	TerminationDelay     string   `yaml:"terminationDelay"`
 }
 
 var (
@@ -57,18 +175,81 @@ var (
 
 func init() {
 	rootCmd.AddCommand(cmdLaunch)
-	cmdLaunch.Flags().BoolVarP(&launchOptions.DryRun, "dry-run", "d", false, "Will NOT launch anything, only print the launch plan")
+	cmdLaunch.Flags().BoolVarP(&launchOptions.DryRun, "dry-run", "d", false, "Will NOT launch anything, only resolve and print the launch plan and check permissions for the resources it would create")
+	cmdLaunch.Flags().BoolVar(&launchOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+	cmdLaunch.Flags().StringVar(&launchOptions.PlanOut, "plan-out", "", "Dry-run and save the resolved launch plan to a file, YAML or JSON with a .json extension, that nimbus apply launches as it was resolved")
 	cmdLaunch.Flags().StringVar(&launchOptions.Name, "name", "", "Name of the VM")
+	cmdLaunch.Flags().Int32Var(&launchOptions.Count, "count", 0, "Number of instances to launch in one fleet request, also the default count of groups (default 1)")
+	cmdLaunch.Flags().StringVar(&launchOptions.Capacity, "capacity", "", "Target capacity in vCPUs or memory instead of a count, the fleet mixes instance sizes to reach it. e.g. --capacity 64vcpu or --capacity 256GiB")
 	cmdLaunch.Flags().StringVar(&launchOptions.CapacityType, "capacity-type", "", "Spot or On-Demand")
-	cmdLaunch.Flags().StringVar(&launchOptions.InstanceTypeSelector, "instance-types", "", "Instance Type Criteria e.g. --instance-types 'vcpus:2-6,arch:arm64,local-storage:100GiB-'")
-	cmdLaunch.Flags().StringVar(&launchOptions.IAMRole, "iam-role", "", "IAM Role")
-	cmdLaunch.Flags().StringVar(&launchOptions.UserData, "user-data", "", "User Data or a file containing User Data. e.g --user-data file://userdata.sh")
-	cmdLaunch.Flags().StringVar(&launchOptions.AMISelector, "amis", "", "AMI selector to dynamically find eligible OS Images. Selectors are AND'd together. e.g. --amis 'tag:Name=fancyOS,tag:Environment=dev' OR --amis 'id:ami-0123456'")
+	cmdLaunch.Flags().StringVar(&launchOptions.InstanceTypeSelector, "instance-types", "", "Instance Type Criteria e.g. --instance-types 'vcpus:2-6,arch:arm64,local-storage:100GiB-', pin or ban names and globs with 'types:m5.large,c5.*' and 'exclude:t2*,t3*'")
+	cmdLaunch.Flags().StringVar(&launchOptions.IAMRole, "iam-role", "", "Name or ARN of an existing IAM role that instances assume, an instance profile is created for it and deleted with the VM")
+	cmdLaunch.Flags().StringVar(&launchOptions.UserData, "user-data", "", "User Data, a file containing User Data, or an http(s) URL to download it from. e.g --user-data file://userdata.sh. "+
+		"It is rendered as a Go template with .Namespace, .Name, .Group, .Region, .Vars, and .Instance placeholders of .ID, .Type, .AZ, and .PrivateIP that shell scripts resolve at boot")
+	cmdLaunch.Flags().StringArrayVar(&launchOptions.UserDataParts, "user-data-part", nil, fmt.Sprintf("Additional user-data in the same forms as --user-data, can be repeated. The parts are combined into a MIME multi-part archive that cloud-init runs in order, which is gzipped if it is larger than %d bytes. e.g. --user-data file://cloud-config.yaml --user-data-part file://setup.sh", userdata.MaxSize))
+	cmdLaunch.Flags().StringArrayVar(&launchOptions.UserDataVars, "user-data-var", nil, "A key=value variable that user-data templates reference as {{ .Vars.key }}, can be repeated. e.g. --user-data-var role=web")
+	cmdLaunch.Flags().StringVar(&launchOptions.Artifacts, "artifacts", "", fmt.Sprintf("Local files or directories separated by commas that are staged in S3 and downloaded to %s at boot. e.g. --artifacts 'data.csv,models/'", artifacts.InputDir))
+	cmdLaunch.Flags().StringVar(&launchOptions.ArtifactsMode, "artifacts-mode", "", fmt.Sprintf("How instances download artifacts: %s (pre-signed URLs, no S3 permissions needed) or %s (aws s3 sync, the IAM role must allow the bucket) (default %s)", artifacts.ModePresigned, artifacts.ModeSync, artifacts.ModePresigned))
+	cmdLaunch.Flags().StringVar(&launchOptions.ArtifactsBucket, "artifacts-bucket", "", "S3 bucket in the region to stage artifacts in (default a nimbus bucket of the account and region)")
+	cmdLaunch.Flags().StringVar(&launchOptions.ShipLogs, "ship-logs", "", "Ship the instances' cloud-init, nimbus, and job logs to a CloudWatch Logs group with the CloudWatch agent, the group is created with an optional retention in days and the IAM role is allowed to ship to it. e.g. --ship-logs 'group:/nimbus/dev,retention:14'")
+	cmdLaunch.Flags().StringVar(&launchOptions.AMISelector, "amis", "", "AMI selector to dynamically find eligible OS Images. Selectors are AND'd together. e.g. --amis 'tag:Name=fancyOS,tag:Environment=dev' OR --amis 'id:ami-0123456'. "+
+		"Deprecated AMIs are excluded unless deprecated:true, and newest:true picks the most recent AMI of each architecture, e.g. --amis 'name:my-app-*,newest:true,creation-date:>2025-01-01'. "+
+		"Aliases resolve Amazon Linux, Ubuntu, Bottlerocket, EKS optimized, and Windows AMIs, optionally of a version, e.g. --amis 'alias:al2023' OR --amis 'alias:ubuntu@22.04'")
 	cmdLaunch.Flags().StringVar(&launchOptions.SubnetSelector, "subnets", "", "Subnet selector to dynamically find eligible subnets. Selectors are AND'd together. e.g. --subnets 'tag:Name=public,tag:Environment=dev' OR --subnets 'id:subnet-0123456'")
+	cmdLaunch.Flags().StringVar(&launchOptions.Placements, "placements", "", "Pin instances to subnets or AZs by index, one instance is launched per placement. e.g. --placements 'az:us-west-2a;az:us-west-2b;subnet:subnet-0123456'")
+	cmdLaunch.Flags().BoolVar(&launchOptions.NonInteractive, "non-interactive", false, "Do not prompt to pick subnets and security groups when selectors match more than one, use all of them")
+	cmdLaunch.Flags().BoolVar(&launchOptions.UseDefaultVPC, "use-default-vpc", false, "Launch into the account's default VPC and subnets instead of creating a new network when no subnet selector is specified")
+	cmdLaunch.Flags().StringVar(&launchOptions.NetworkPolicy, "network-policy", "", "When nimbus creates the network, share one VPC across the namespace or isolate a VPC for this name: shared or isolated (default shared)")
+	cmdLaunch.Flags().StringVar(&launchOptions.NetworkTopology, "network-topology", "", "When nimbus creates the network, launch into public subnets, or into private subnets that reach the internet through a NAT Gateway: public, private, or public-private to create both and launch into the public subnets (default public)")
+	cmdLaunch.Flags().StringVar(&launchOptions.VPCCIDR, "vpc-cidr", "", fmt.Sprintf("IPv4 CIDR block of the VPC when nimbus creates the network, public subnets are carved from its first half and private subnets from its second half (default %s)", plans.DefaultVPCCIDR))
+	cmdLaunch.Flags().IntVar(&launchOptions.SubnetPrefixLength, "subnet-prefix-length", 0, fmt.Sprintf("Prefix length of the subnets when nimbus creates the network (default %d)", plans.DefaultSubnetPrefixLength))
+	cmdLaunch.Flags().IntVar(&launchOptions.AZCount, "az-count", 0, fmt.Sprintf("Number of availability zones that the network spans when nimbus creates it (default %d)", plans.DefaultAZCount))
+	cmdLaunch.Flags().StringVar(&launchOptions.AZs, "azs", "", "Availability zone names or IDs separated by commas that the network spans when nimbus creates it, instead of --az-count. e.g. --azs 'us-east-1a,us-east-1c'")
+	cmdLaunch.Flags().StringVar(&launchOptions.IPFamily, "ip-family", "", "IP addresses of the network when nimbus creates it: ipv4, ipv6 for IPv6-only public subnets of Nitro instances, or dual-stack to also assign an IPv6 address to every instance (default ipv4)")
+	cmdLaunch.Flags().StringVar(&launchOptions.Naming, "naming", "", "Template for the names of created launch templates and security groups with the fields .Namespace, .Name, .Group, .Type, .Random, and .Hash (launch templates only). e.g. --naming '{{.Namespace}}-{{.Name}}-{{.Random}}'")
+	cmdLaunch.Flags().StringVar(&launchOptions.VolumeType, "volume-type", "", "EBS volume type of the root volume (default gp3)")
+	cmdLaunch.Flags().Int32Var(&launchOptions.VolumeSize, "volume-size", 0, "Size of the root volume in GiB (default the AMI's snapshot size)")
+	cmdLaunch.Flags().Int32Var(&launchOptions.VolumeIOPS, "volume-iops", 0, "Provisioned IOPS of a gp3, io1, or io2 root volume, gp3 supports 3000-16000 (default 3000 for gp3)")
+	cmdLaunch.Flags().Int32Var(&launchOptions.VolumeThroughput, "volume-throughput", 0, "Provisioned throughput of a gp3 root volume in MiB/s, 125-1000 and at most IOPS/4 (default 125)")
+	cmdLaunch.Flags().StringVar(&launchOptions.BlockDeviceMappings, "block-device-mappings", "", "EBS volumes to attach, sizes are GiB or a byte size like 1TiB. Device root configures the root volume. e.g. --block-device-mappings 'device:root,size:50GiB;device:/dev/sdf,size:500GiB,type:gp3,iops:6000,throughput:500,encrypted:true,kms-key:alias/data'")
+	cmdLaunch.Flags().StringVar(&launchOptions.Volumes, "volumes", "", "Standalone EBS volumes to create for every instance and attach after launch, they outlive the instances until the VM is deleted. Same format as --block-device-mappings, e.g. --volumes 'device:/dev/sdf,size:1TiB'")
+	cmdLaunch.Flags().StringVar(&launchOptions.EBSKMSKey, "ebs-kms-key", "", "KMS key that encrypts every created volume: alias/<name>, a key ARN, or a key ID. Volumes are always encrypted (default the account's default EBS key)")
+	cmdLaunch.Flags().StringVar(&launchOptions.KeyName, "key-name", "", "Name of the EC2 key pair to launch instances with for SSH access")
+	cmdLaunch.Flags().StringVar(&launchOptions.KeyPairSelector, "key-pairs", "", "Key pair selector to find the key pair to launch instances with, it must match exactly one key pair. e.g. --key-pairs 'tag:team=infra' OR --key-pairs 'id:key-0123456'")
+	cmdLaunch.Flags().StringVar(&launchOptions.MetadataHTTPTokens, "metadata-http-tokens", "", fmt.Sprintf("Whether the instance metadata service requires IMDSv2 session tokens: required or optional, which also allows IMDSv1 (default %s)", launchtemplates.DefaultHTTPTokens))
+	cmdLaunch.Flags().Int32Var(&launchOptions.MetadataHopLimit, "metadata-hop-limit", 0, fmt.Sprintf("Network hops that IMDSv2 session token responses may travel, 1-64 (default %d, which lets containers reach the metadata service)", launchtemplates.DefaultHTTPPutResponseHopLimit))
+	cmdLaunch.Flags().StringVar(&launchOptions.MetadataTags, "metadata-tags", "", fmt.Sprintf("Whether instances can read their tags from the instance metadata service: enabled or disabled (default %s)", launchtemplates.DefaultInstanceMetadataTags))
+	cmdLaunch.Flags().StringVar(&launchOptions.NetworkInterfaces, "network-interfaces", "", "Network interfaces to launch instances with instead of the default one, type is interface, efa, or efa-only and security groups are space-separated. e.g. --network-interfaces 'card:0,device:0,type:efa;card:1,device:1,type:efa-only' OR --network-interfaces 'card:0,device:0,public-ip:true'")
+	cmdLaunch.Flags().BoolVar(&launchOptions.PreferReservations, "prefer-reservations", false, "Launch on-demand instances into instance types and AZs with unused reserved instances, and into instance families with EC2 Instance Savings Plans, first. Compute Savings Plans apply to every instance type and do not change the preference")
+	cmdLaunch.Flags().StringVar(&launchOptions.SpotAllocation, "spot-allocation-strategy", "", "How EC2 Fleet picks the spot capacity pools to launch from: price-capacity-optimized, capacity-optimized, capacity-optimized-prioritized, diversified, or lowest-price (default price-capacity-optimized)")
+	cmdLaunch.Flags().Float64Var(&launchOptions.SpotMaxPrice, "spot-max-price", 0, "The most to pay for a spot instance per hour in USD, a lower price avoids expensive capacity pools at the cost of more interruptions (default the on-demand price)")
+	cmdLaunch.Flags().Int32Var(&launchOptions.OnDemandBase, "on-demand-base-capacity", 0, "Capacity of every group that is launched on-demand before the rest is split by --on-demand-percentage-above-base, in instances or the units of --capacity")
+	cmdLaunch.Flags().StringVar(&launchOptions.OnDemandAboveBase, "on-demand-percentage-above-base", "", "Percentage of the capacity above --on-demand-base-capacity that is launched on-demand, the rest is spot. e.g. --on-demand-base-capacity 1 --on-demand-percentage-above-base 25 (default all of it is --capacity-type)")
+	cmdLaunch.Flags().StringVar(&launchOptions.CapacityRebalance, "capacity-rebalance", "", "Launch a replacement when a spot instance is at an elevated risk of interruption: launch keeps the at-risk instance, launch-before-terminate terminates it after --termination-delay. The fleets keep replacing instances until the VM is deleted or replaced")
+	cmdLaunch.Flags().DurationVar(&launchOptions.TerminationDelay, "termination-delay", 0, fmt.Sprintf("How long --capacity-rebalance launch-before-terminate keeps the at-risk spot instance after launching its replacement, %s-%s (default %s)", plans.MinTerminationDelay, plans.MaxTerminationDelay, plans.MinTerminationDelay))
+	cmdLaunch.Flags().DurationVar(&launchOptions.TTL, "ttl", 0, "How long instances live before they terminate themselves, the shutdown is scheduled by shell script user-data at boot. e.g. --ttl 8h")
+	cmdLaunch.Flags().BoolVar(&launchOptions.Replace, "replace", false, "If the VM already runs instances of a different spec, launch the new spec and then terminate them. Otherwise only the launch templates of the new spec are created")
+	cmdLaunch.Flags().BoolVar(&launchOptions.Now, "now", false, nowFlagUsage+", only --replace is gated")
+	cmdLaunch.Flags().BoolVar(&launchOptions.WaitForBootstrap, "wait-for-bootstrap", false, "Wait for instances to be running, registered with SSM, and passing their group's readiness probe, and report how long each launch phase took")
+	cmdLaunch.Flags().BoolVar(&launchOptions.Wait, "wait", false, fmt.Sprintf("Wait for the launched instances to be running before returning, same as --wait-for %s", plans.WaitRunning))
+	cmdLaunch.Flags().StringVar(&launchOptions.WaitFor, "wait-for", "", fmt.Sprintf("Wait for the launched instances to be %s, %s, or %s before returning", plans.WaitRunning, plans.WaitStatusChecksPassed, plans.WaitSSMManaged))
+	cmdLaunch.Flags().DurationVar(&launchOptions.WaitTimeout, "wait-timeout", plans.DefaultWaitTimeout, "How long to wait with --wait or --wait-for before the launch fails")
+	cmdLaunch.Flags().StringVar(&launchOptions.GPUDrivers, "gpu-drivers", "", "Set up NVIDIA drivers when NVIDIA GPU instance types are selected: install (installs the driver and CUDA on Amazon Linux 2023 at boot) or dlami (launches the Deep Learning Base AMI). With --wait-for-bootstrap, nvidia-smi is checked over SSM")
+	cmdLaunch.Flags().StringVar(&launchOptions.TimingMetrics, "timing-metrics-namespace", "", "CloudWatch namespace to publish the launch phase timings to as custom metrics, e.g. --timing-metrics-namespace nimbus")
+	cmdLaunch.Flags().StringVar(&launchOptions.Tags, "tags", "", "Tags applied to the launched instances. e.g. --tags 'team=infra,cost-center=1234'")
+	cmdLaunch.Flags().StringVar(&launchOptions.CompliancePolicy, "compliance-policy", os.Getenv(compliancePolicyEnvVar), fmt.Sprintf("File containing a compliance policy that the launch plan must satisfy before anything is created. Can also be set with %s", compliancePolicyEnvVar))
+	cmdLaunch.Flags().StringVar(&launchOptions.Ingress, "ingress", "", "Semicolon separated ingress rules authorized on the security group that nimbus creates, which allows no inbound traffic otherwise. e.g. --ingress 'allow tcp:8080 from cidr:10.0.0.0/8;allow all from sg:sg-0123456'")
+	cmdLaunch.Flags().StringVar(&launchOptions.Allow, "allow", "", fmt.Sprintf("Comma separated services to allow inbound traffic to from anywhere, or only from your public IP with --allow-from-my-ip: %s. e.g. --allow ssh,https", strings.Join(securitygroups.IngressPresets(), ", ")))
+	cmdLaunch.Flags().BoolVar(&launchOptions.AllowFromMyIP, "allow-from-my-ip", false, "Only allow the --allow services from your public IP, or all inbound traffic from it if --allow is not specified")
 	cmdLaunch.Flags().StringVar(&launchOptions.SecurityGroupSelector, "security-groups", "", "Security Group selector to dynamically find eligible security groups. Selectors are AND'd together. e.g. --security-groups 'tag:Name=public,tag:Environment=dev' OR --security-groups 'id:sg-0123456'")
 }
 
-func launch(ctx context.Context, launchOptions LaunchOptions, globalOpts GlobalOptions) error {
+func launch(ctx context.Context, launchOptions LaunchOptions, globalOpts GlobalOptions) (err error) {
+	launchOptions, err = ParseConfig(globalOpts, launchOptions)
+	if err != nil {
+		return err
+	}
+
 	awsCfg, err := AWSConfig(ctx, globalOpts)
 	if err != nil {
 		return err
@@ -96,35 +277,390 @@ func launch(ctx context.Context, launchOptions LaunchOptions, globalOpts GlobalO
 	if err != nil {
 		return err
 	}
+	keyPairSelectors, err := keypairs.ParseSelectors(launchOptions.KeyPairSelector)
+	if err != nil {
+		return err
+	}
+	capacity, err := plans.ParseCapacity(launchOptions.Capacity)
+	if err != nil {
+		return err
+	}
+	placements, err := plans.ParsePlacements(launchOptions.Placements)
+	if err != nil {
+		return err
+	}
+	blockDeviceMappings, err := launchtemplates.ParseBlockDevices(launchOptions.BlockDeviceMappings)
+	if err != nil {
+		return err
+	}
+	volumes, err := launchtemplates.ParseBlockDevices(launchOptions.Volumes)
+	if err != nil {
+		return err
+	}
+	networkInterfaces, err := launchtemplates.ParseNetworkInterfaces(launchOptions.NetworkInterfaces)
+	if err != nil {
+		return err
+	}
+	waitFor, err := plans.ParseWaitCondition(launchOptions.WaitFor)
+	if err != nil {
+		return err
+	}
+	if launchOptions.Wait && waitFor == "" {
+		waitFor = plans.WaitRunning
+	}
+	userData, err := userdata.Load(ctx, append([]string{launchOptions.UserData}, launchOptions.UserDataParts...)...)
+	if err != nil {
+		return err
+	}
+	nodeGroups, err := parseNodeGroups(ctx, launchOptions.Groups)
+	if err != nil {
+		return err
+	}
+	tags, err := tagutils.ParseTags(launchOptions.Tags)
+	if err != nil {
+		return err
+	}
+	onDemandPercentageAboveBase, err := parseWholePercent(launchOptions.OnDemandAboveBase)
+	if err != nil {
+		return err
+	}
+	compliancePolicy, err := loadCompliancePolicy(launchOptions.CompliancePolicy)
+	if err != nil {
+		return err
+	}
+	shipLogs, err := logs.ParseDestination(launchOptions.ShipLogs)
+	if err != nil {
+		return err
+	}
+	ingressRules, err := launchIngressRules(ctx, launchOptions)
+	if err != nil {
+		return err
+	}
+	userDataVars, err := userdata.ParseVars(launchOptions.UserDataVars)
+	if err != nil {
+		return err
+	}
 	launchPlanInput := plans.LaunchPlan{
 		Metadata: plans.LaunchMetadata{
 			Namespace: globalOpts.Namespace,
 			Name:      launchOptions.Name,
 		},
 		Spec: plans.LaunchSpec{
+			Count:                  launchOptions.Count,
+			Capacity:               capacity,
 			CapacityType:           launchOptions.CapacityType,
 			IAMRole:                launchOptions.IAMRole,
 			InstanceTypeSelectors:  instanceTypeSelectors,
 			SubnetSelectors:        subnetSelectors,
 			AMISelectors:           amiSelectors,
 			SecurityGroupSelectors: securityGroupSelectors,
-			UserData:               launchOptions.UserData,
+			IngressRules:           ingressRules,
+			UserData:               userData,
+			UserDataVars:           userDataVars,
+			Artifacts: plans.Artifacts{
+				Inputs: parseList(launchOptions.Artifacts),
+				Mode:   launchOptions.ArtifactsMode,
+				Bucket: launchOptions.ArtifactsBucket,
+			},
+			ShipLogs:        shipLogs,
+			UseDefaultVPC:   launchOptions.UseDefaultVPC,
+			NetworkPolicy:   launchOptions.NetworkPolicy,
+			NetworkTopology: launchOptions.NetworkTopology,
+			Network: plans.NetworkSpec{
+				VPCCIDR:            launchOptions.VPCCIDR,
+				SubnetPrefixLength: launchOptions.SubnetPrefixLength,
+				AZCount:            launchOptions.AZCount,
+				AZs:                parseList(launchOptions.AZs),
+				IPFamily:           launchOptions.IPFamily,
+			},
+			Naming: launchOptions.Naming,
+			RootVolume: launchtemplates.BlockDevice{
+				VolumeType: launchOptions.VolumeType,
+				VolumeSize: launchOptions.VolumeSize,
+				IOPS:       launchOptions.VolumeIOPS,
+				Throughput: launchOptions.VolumeThroughput,
+			},
+			BlockDeviceMappings: blockDeviceMappings,
+			Volumes:             volumes,
+			EBSKMSKey:           launchOptions.EBSKMSKey,
+			KeyName:             launchOptions.KeyName,
+			KeyPairSelectors:    keyPairSelectors,
+			MetadataOptions: launchtemplates.MetadataOptions{
+				HTTPTokens:              launchOptions.MetadataHTTPTokens,
+				HTTPPutResponseHopLimit: launchOptions.MetadataHopLimit,
+				InstanceMetadataTags:    launchOptions.MetadataTags,
+			},
+			NetworkInterfaces:      networkInterfaces,
+			PreferReservations:     launchOptions.PreferReservations,
+			TTL:                    launchOptions.TTL,
+			WaitForBootstrap:       launchOptions.WaitForBootstrap,
+			Replace:                launchOptions.Replace,
+			GPUDrivers:             launchOptions.GPUDrivers,
+			TimingMetricsNamespace: launchOptions.TimingMetrics,
+			Tags:                   tags,
+			CompliancePolicy:       compliancePolicy,
+			Placements:             placements,
+			NodeGroups:             nodeGroups,
+			Wait:                   plans.Wait{For: waitFor, Timeout: launchOptions.WaitTimeout},
+			Allocation: plans.Allocation{
+				SpotAllocationStrategy:      launchOptions.SpotAllocation,
+				SpotMaxPrice:                launchOptions.SpotMaxPrice,
+				OnDemandBaseCapacity:        launchOptions.OnDemandBase,
+				OnDemandPercentageAboveBase: onDemandPercentageAboveBase,
+				CapacityRebalance:           launchOptions.CapacityRebalance,
+				TerminationDelay:            launchOptions.TerminationDelay,
+			},
 		},
 	}
 
-	launchPlan, err := vmClient.Launch(ctx, launchOptions.DryRun, launchPlanInput)
-	if err != nil {
-		if globalOpts.Verbose {
-			fmt.Println(pretty.EncodeYAML(launchPlan))
+	if !launchOptions.NonInteractive && isInteractiveTerminal() {
+		launchPlanInput, err = pickNetwork(ctx, vmClient, launchPlanInput)
+		if err != nil {
+			return err
 		}
-		return err
 	}
 
-	if globalOpts.Verbose {
-		fmt.Println(pretty.EncodeYAML(launchPlan))
+	// a saved plan is resolved by a dry-run and launched by nimbus apply
+	dryRun := launchOptions.DryRun || launchOptions.PlanOut != ""
+	if !dryRun && launchOptions.Replace {
+		if err := gateMaintenance(ctx, "replace instances", globalOpts.Namespace, launchOptions.Now); err != nil {
+			return err
+		}
+	}
+	if !dryRun {
+		unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, launchOptions.Name, "launch", launchOptions.ForceUnlock)
+		if err != nil {
+			return err
+		}
+		defer func() { err = errors.Join(err, unlock()) }()
+	}
+	launchPlan, err := vmClient.Launch(ctx, dryRun, launchPlanInput)
+	printPlan(launchPlan, globalOpts)
+	printLaunchFailures(launchPlan, globalOpts)
+	if err != nil {
+		return err
+	}
+	if launchOptions.PlanOut != "" {
+		if err := plans.SaveLaunchPlan(launchOptions.PlanOut, launchPlan); err != nil {
+			return err
+		}
 	}
 
-	fmt.Printf("Launched %s/%s\n", globalOpts.Namespace, launchOptions.Name)
+	if globalOpts.Output == OutputJSON || globalOpts.Output == OutputYAML {
+		return nil
+	}
+	if dryRun {
+		if len(launchPlan.Status.PlannedResources) != 0 {
+			fmt.Println(pretty.Table(launchPlan.Status.PlannedResources, globalOpts.Output == OutputTableWide))
+		}
+		if len(launchPlan.Status.EstimatedCost.NodeGroups) != 0 {
+			fmt.Println(pretty.Table(launchPlan.Status.EstimatedCost.Prettify(), globalOpts.Output == OutputTableWide))
+			fmt.Println(launchPlan.Status.EstimatedCost)
+		}
+		fmt.Printf("Dry-run of %s/%s, %d resources would be created\n", globalOpts.Namespace, launchOptions.Name, len(launchPlan.Status.PlannedResources))
+		if summary := reconcileSummary(launchPlan); summary != "" {
+			fmt.Println(summary)
+		}
+		fmt.Println(launchPlan.Summary())
+		if launchOptions.PlanOut != "" {
+			fmt.Printf("Saved the plan to %s, launch it with: nimbus apply -f %s\n", launchOptions.PlanOut, launchOptions.PlanOut)
+			if references := launchPlan.Spec.RegionReferences(); len(references) != 0 {
+				fmt.Printf("The plan can only be applied in %s, its spec references resources of the region. Select them by alias, SSM parameter, or tags to apply the plan in any region:\n", launchPlan.Status.Region)
+				fmt.Println(pretty.Table(references, false))
+			}
+		}
+		return nil
+	}
+	fmt.Println(launchSummary(launchPlan))
+	if launchPlan.Spec.Wait.Enabled() {
+		fmt.Printf("%d instances are %s\n", len(launchPlan.Status.Instances), launchPlan.Spec.Wait.For)
+	}
+	if launchOptions.WaitForBootstrap {
+		fmt.Println(pretty.Table(launchPlan.Status.Timings.Prettify(), globalOpts.Output == OutputTableWide))
+	}
 
 	return nil
 }
+
+// printLaunchFailures prints the failures that EC2 Fleet returned as a table, JSON and YAML output already include them in the plan's status
+func printLaunchFailures(launchPlan plans.LaunchPlan, globalOpts GlobalOptions) {
+	if len(launchPlan.Status.LaunchFailures) == 0 || globalOpts.Output == OutputJSON || globalOpts.Output == OutputYAML {
+		return
+	}
+	fmt.Printf("EC2 Fleet could not launch %d overrides:\n", len(launchPlan.Status.LaunchFailures))
+	fmt.Println(pretty.Table(launchPlan.Status.LaunchFailures, globalOpts.Output == OutputTableWide))
+}
+
+// launchSummary describes what a launch did to converge namespace/name to the spec
+func launchSummary(launchPlan plans.LaunchPlan) string {
+	name := fmt.Sprintf("%s/%s", launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
+	reconciliation := launchPlan.Status.Reconciliation
+	switch reconciliation.Action {
+	case plans.ReconcileNone:
+		return fmt.Sprintf("%s already runs the spec, nothing was launched", name)
+	case plans.ReconcileUpdate:
+		return fmt.Sprintf("Created the launch templates of the changed spec of %s and kept %d instances of an earlier spec, launch with --replace to replace them", name, len(reconciliation.OutOfDate))
+	case plans.ReconcileReplace:
+		return fmt.Sprintf("Launched %s and terminated %d instances of an earlier spec", name, len(reconciliation.OutOfDate))
+	case plans.ReconcileScale:
+		return fmt.Sprintf("Launched %d missing instances of %s", len(launchPlan.Status.Instances)-len(reconciliation.UpToDate), name)
+	}
+	return fmt.Sprintf("Launched %s", name)
+}
+
+// reconcileSummary describes how a dry-run would converge the existing instances of namespace/name, empty if it has none
+func reconcileSummary(launchPlan plans.LaunchPlan) string {
+	reconciliation := launchPlan.Status.Reconciliation
+	switch reconciliation.Action {
+	case plans.ReconcileNone:
+		return fmt.Sprintf("%d instances already run the spec, nothing would be launched", len(reconciliation.UpToDate))
+	case plans.ReconcileUpdate:
+		return fmt.Sprintf("%d instances of an earlier spec would be kept, launch with --replace to replace them", len(reconciliation.OutOfDate))
+	case plans.ReconcileReplace:
+		return fmt.Sprintf("%d instances of an earlier spec would be terminated after launching their replacements", len(reconciliation.OutOfDate))
+	case plans.ReconcileScale:
+		return fmt.Sprintf("%d instances already run the spec, only the missing instances would be launched", len(reconciliation.UpToDate))
+	}
+	return ""
+}
+
+// launchIngressRules returns the ingress rules of --ingress and of the --allow services, which are allowed from anywhere or with --allow-from-my-ip
+// only from the caller's public IP
+func launchIngressRules(ctx context.Context, launchOptions LaunchOptions) ([]securitygroups.IngressRule, error) {
+	rules, err := securitygroups.ParseIngressRules(launchOptions.Ingress)
+	if err != nil {
+		return nil, err
+	}
+	presets := parseList(launchOptions.Allow)
+	if launchOptions.AllowFromMyIP && len(presets) == 0 {
+		presets = []string{"all"}
+	}
+	if len(presets) == 0 {
+		return rules, nil
+	}
+	cidrs := []string{"0.0.0.0/0"}
+	if (plans.NetworkSpec{IPFamily: launchOptions.IPFamily}).HasIPv6() {
+		cidrs = append(cidrs, "::/0")
+	}
+	if launchOptions.AllowFromMyIP {
+		cidr, err := callerCIDR(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("unable to allow-list your IP, allow your CIDR with --ingress: %w", err)
+		}
+		cidrs = []string{cidr}
+	}
+	presetRules, err := securitygroups.PresetIngressRules(presets, cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, presetRules...), nil
+}
+
+// parseWholePercent parses an optional whole percentage like 25% or 25, nil if it is empty
+func parseWholePercent(percentStr string) (*int32, error) {
+	if percentStr == "" {
+		return nil, nil
+	}
+	percent, err := parsePercent(percentStr)
+	if err != nil {
+		return nil, err
+	}
+	if percent != math.Trunc(percent) {
+		return nil, fmt.Errorf("invalid percentage %q, expected a whole number", percentStr)
+	}
+	return lo.ToPtr(int32(percent)), nil
+}
+
+// parseList splits a flag of values separated by commas, like artifact paths or availability zones
+func parseList(listStr string) []string {
+	return lo.Compact(lo.Map(strings.Split(listStr, ","), func(value string, _ int) string { return strings.TrimSpace(value) }))
+}
+
+// loadCompliancePolicy reads a compliance policy file, an empty path is an empty policy that does not enforce anything.
+// Unknown fields are an error so that a misspelled guardrail is not silently ignored.
+func loadCompliancePolicy(path string) (plans.CompliancePolicy, error) {
+	var policy plans.CompliancePolicy
+	if path == "" {
+		return policy, nil
+	}
+	policyFile, err := os.Open(path)
+	if err != nil {
+		return policy, fmt.Errorf("unable to read compliance policy: %w", err)
+	}
+	defer policyFile.Close()
+	decoder := yaml.NewDecoder(policyFile)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&policy); err != nil {
+		return policy, fmt.Errorf("unable to parse compliance policy %s: %w", path, err)
+	}
+	return policy, nil
+}
+
+func parseNodeGroups(ctx context.Context, groupOptions []LaunchGroupOptions) ([]plans.NodeGroup, error) {
+	nodeGroups := make([]plans.NodeGroup, 0, len(groupOptions))
+	for _, groupOpts := range groupOptions {
+		userData, err := userdata.Load(ctx, groupOpts.UserData)
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %w", groupOpts.Name, err)
+		}
+		instanceTypeSelectors, err := instancetypes.ParseSelectors(groupOpts.InstanceTypeSelector)
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %w", groupOpts.Name, err)
+		}
+		amiSelectors, err := amis.ParseSelectors(groupOpts.AMISelector)
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %w", groupOpts.Name, err)
+		}
+		capacity, err := plans.ParseCapacity(groupOpts.Capacity)
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %w", groupOpts.Name, err)
+		}
+		ingressRules, err := securitygroups.ParseIngressRules(strings.Join(groupOpts.Ingress, ";"))
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %w", groupOpts.Name, err)
+		}
+		var readinessTimeout time.Duration
+		if groupOpts.ReadinessTimeout != "" {
+			readinessTimeout, err = time.ParseDuration(groupOpts.ReadinessTimeout)
+			if err != nil {
+				return nil, fmt.Errorf("node group %s: invalid readiness timeout: %w", groupOpts.Name, err)
+			}
+		}
+		onDemandPercentageAboveBase, err := parseWholePercent(groupOpts.OnDemandAboveBase)
+		if err != nil {
+			return nil, fmt.Errorf("node group %s: %w", groupOpts.Name, err)
+		}
+		var terminationDelay time.Duration
+		if groupOpts.TerminationDelay != "" {
+			terminationDelay, err = time.ParseDuration(groupOpts.TerminationDelay)
+			if err != nil {
+				return nil, fmt.Errorf("node group %s: invalid termination delay: %w", groupOpts.Name, err)
+			}
+		}
+		nodeGroups = append(nodeGroups, plans.NodeGroup{
+			Name:                  groupOpts.Name,
+			Count:                 groupOpts.Count,
+			Capacity:              capacity,
+			CapacityType:          groupOpts.CapacityType,
+			InstanceTypeSelectors: instanceTypeSelectors,
+			AMISelectors:          amiSelectors,
+			IAMRole:               groupOpts.IAMRole,
+			UserData:              userData,
+			DependsOn:             groupOpts.DependsOn,
+			ReadinessProbe: plans.ReadinessProbe{
+				Port:    groupOpts.ReadinessPort,
+				Timeout: readinessTimeout,
+			},
+			IngressRules: ingressRules,
+			Allocation: plans.Allocation{
+				SpotAllocationStrategy:      groupOpts.SpotAllocation,
+				SpotMaxPrice:                groupOpts.SpotMaxPrice,
+				OnDemandBaseCapacity:        groupOpts.OnDemandBase,
+				OnDemandPercentageAboveBase: onDemandPercentageAboveBase,
+				CapacityRebalance:           groupOpts.CapacityRebalance,
+				TerminationDelay:            terminationDelay,
+			},
+		})
+	}
+	return nodeGroups, nil
+}
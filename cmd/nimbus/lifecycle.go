@@ -0,0 +1,138 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+// LifecycleOptions are the options of the stop, start, and reboot commands
+type LifecycleOptions struct {
+	Name             string
+	InstanceSelector string
+	// Hibernate is only used by stop
+	Hibernate bool
+	// Now skips the maintenance windows of stop and reboot
+	Now         bool
+	ForceUnlock bool
+}
+
+// lifecycleAction transitions the instances of namespace/name that match the selectors
+type lifecycleAction func(vmClient vm.VMI, ctx context.Context, namespace, name string, selectorList []instances.Selector) ([]instances.Instance, error)
+
+var (
+	stopOptions   = LifecycleOptions{}
+	startOptions  = LifecycleOptions{}
+	rebootOptions = LifecycleOptions{}
+	cmdStop       = &cobra.Command{
+		Use:   "stop",
+		Short: "Stop VMs",
+		Long:  `Stop the running VMs of a name or that match the instance selectors. Stopped VMs keep their EBS volumes and can be started again.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return transition(ctx, stopOptions, globalOpts, "stop", "stop instances", func(vmClient vm.VMI, ctx context.Context, namespace, name string, selectorList []instances.Selector) ([]instances.Instance, error) {
+				return vmClient.Stop(ctx, namespace, name, selectorList, stopOptions.Hibernate)
+			})
+		},
+	}
+	cmdStart = &cobra.Command{
+		Use:   "start",
+		Short: "Start stopped VMs",
+		Long:  `Start the stopped VMs of a name or that match the instance selectors.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return transition(ctx, startOptions, globalOpts, "start", "", vm.VMI.Start)
+		},
+	}
+	cmdReboot = &cobra.Command{
+		Use:   "reboot",
+		Short: "Reboot VMs",
+		Long:  `Reboot the running VMs of a name or that match the instance selectors. Rebooted VMs keep their instance IDs, IP addresses, and instance store volumes.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return transition(ctx, rebootOptions, globalOpts, "reboot", "reboot instances", vm.VMI.Reboot)
+		},
+	}
+)
+
+func init() {
+	for cmd, opts := range map[*cobra.Command]*LifecycleOptions{cmdStop: &stopOptions, cmdStart: &startOptions, cmdReboot: &rebootOptions} {
+		rootCmd.AddCommand(cmd)
+		cmd.Flags().StringVar(&opts.Name, "name", "", "Name of the VMs")
+		cmd.Flags().StringVar(&opts.InstanceSelector, "instances", "", "Instance selector to choose the VMs within the namespace. e.g. --instances 'id:i-0123456' OR --instances 'tag:Role=worker'")
+		cmd.Flags().BoolVar(&opts.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+	}
+	cmdStop.Flags().BoolVar(&stopOptions.Now, "now", false, nowFlagUsage)
+	cmdReboot.Flags().BoolVar(&rebootOptions.Now, "now", false, nowFlagUsage)
+	cmdStop.Flags().BoolVar(&stopOptions.Hibernate, "hibernate", false, "Hibernate the VMs instead of stopping them, the VMs must have been launched with hibernation enabled")
+}
+
+// transition applies the action to the selected instances under the lock of the operation. A disruptive action is described by disruption and gated by the maintenance windows.
+// Without --name the instances may belong to any name, so the whole namespace is locked.
+func transition(ctx context.Context, lifecycleOptions LifecycleOptions, globalOpts GlobalOptions, operation, disruption string, action lifecycleAction) (err error) {
+	if lifecycleOptions.Name == "" && lifecycleOptions.InstanceSelector == "" {
+		return fmt.Errorf("--name or --instances must be specified")
+	}
+	var selectorList []instances.Selector
+	if lifecycleOptions.InstanceSelector != "" {
+		var err error
+		selectorList, err = instances.ParseSelectors(lifecycleOptions.InstanceSelector)
+		if err != nil {
+			return err
+		}
+	}
+	if disruption != "" {
+		if err := gateMaintenance(ctx, disruption, globalOpts.Namespace, lifecycleOptions.Now); err != nil {
+			return err
+		}
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, lifecycleOptions.Name, operation, lifecycleOptions.ForceUnlock)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	instanceList, err := action(vmClient, ctx, globalOpts.Namespace, lifecycleOptions.Name, selectorList)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(instanceList))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(instanceList))
+	default:
+		fmt.Println(pretty.Table(instances.PrettifyAll(instanceList), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
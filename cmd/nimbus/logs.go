@@ -0,0 +1,177 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type LogsOptions struct {
+	Name             string
+	Follow           bool
+	Since            time.Duration
+	Commands         bool
+	Console          bool
+	InstanceSelector string
+}
+
+var (
+	logsOptions = LogsOptions{}
+	cmdLogs     = &cobra.Command{
+		Use:   "logs",
+		Short: "Show the logs that a VM's instances shipped to CloudWatch Logs, the output of commands run with exec, or the instances' console output",
+		Long: `Show the cloud-init, nimbus, and job logs that the instances of a VM launched with --ship-logs shipped to their CloudWatch Logs group.
+Logs are read from the log streams of the VM, so the logs of terminated instances are shown until the log group's retention expires them.
+With --commands, the output of the commands that exec ran on the VM is read from SSM's command history instead, which keeps it for 30 days.
+With --console, the serial console output of the instances is read from EC2 instead, which shows boot and user data failures of instances
+that never became reachable or shipped any logs. EC2 keeps the latest 64 KB and only captures it every few seconds.
+Each line is prefixed with the ID of the instance it came from.`,
+		Example: `  nimbus logs --name web
+  nimbus logs --name web --follow
+  nimbus logs --name web --since 24h -o json | jq -r 'select(.Source == "job") | .Message'
+  nimbus logs --name web --commands --since 24h
+  nimbus logs --name web --commands --follow
+  nimbus logs --name web --console --instances 'id:i-0123456' --follow`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return showLogs(ctx, logsOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdLogs)
+	cmdLogs.Flags().StringVar(&logsOptions.Name, "name", "", "Name of the VM")
+	cmdLogs.Flags().BoolVar(&logsOptions.Follow, "follow", false, "Stream new logs as they are shipped until interrupted")
+	cmdLogs.Flags().DurationVar(&logsOptions.Since, "since", time.Hour, "Show logs shipped since the duration ago")
+	cmdLogs.Flags().BoolVar(&logsOptions.Commands, "commands", false, "Show the output of the commands that exec ran on the VM's instances through SSM")
+	cmdLogs.Flags().BoolVar(&logsOptions.Console, "console", false, "Show the console output of the VM's instances, --since does not apply")
+	cmdLogs.Flags().StringVar(&logsOptions.InstanceSelector, "instances", "", "Instance selector to choose the instances to show the console output of. e.g. --instances 'id:i-0123456'")
+}
+
+func showLogs(ctx context.Context, logsOptions LogsOptions, globalOpts GlobalOptions) error {
+	if logsOptions.Name == "" && !(logsOptions.Console && logsOptions.InstanceSelector != "") {
+		return fmt.Errorf("--name must be specified")
+	}
+	if logsOptions.Commands && logsOptions.Console {
+		return fmt.Errorf("--commands and --console can not be used together")
+	}
+	if logsOptions.InstanceSelector != "" && !logsOptions.Console {
+		return fmt.Errorf("--instances can only be used with --console")
+	}
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	if logsOptions.Commands {
+		return showCommandLogs(ctx, vmClient, logsOptions, globalOpts)
+	}
+	if logsOptions.Console {
+		return showConsoleOutput(ctx, vmClient, logsOptions, globalOpts)
+	}
+	eventsChan, err := vmClient.Logs(ctx, globalOpts.Namespace, logsOptions.Name, logsOptions.Since, logsOptions.Follow)
+	if err != nil {
+		return err
+	}
+	for event := range eventsChan {
+		switch globalOpts.Output {
+		case OutputJSON, OutputYAML:
+			// one event per line so the stream can be piped to tools like jq
+			line, err := json.Marshal(event)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(line))
+		default:
+			fmt.Printf("%s  %-20s %-18s %s\n", event.Time.Local().Format(time.DateTime), event.InstanceID, event.Source, event.Message)
+		}
+	}
+	return nil
+}
+
+// showCommandLogs prints the output of the VM's exec commands as their invocations complete, each line prefixed with its instance ID
+func showCommandLogs(ctx context.Context, vmClient vm.VMI, logsOptions LogsOptions, globalOpts GlobalOptions) error {
+	invocationsChan, err := vmClient.CommandLogs(ctx, globalOpts.Namespace, logsOptions.Name, logsOptions.Since, logsOptions.Follow)
+	if err != nil {
+		return err
+	}
+	for invocation := range invocationsChan {
+		switch globalOpts.Output {
+		case OutputJSON, OutputYAML:
+			line, err := json.Marshal(invocation)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(line))
+		default:
+			prefix := fmt.Sprintf("[%s] ", invocation.InstanceID)
+			fmt.Printf("%s==> %s %s (%s, exit code %d) <==\n", prefix, invocation.Requested.Local().Format(time.DateTime), invocation.CommandID, invocation.Status, invocation.ExitCode)
+			printPrefixed(os.Stdout, prefix, invocation.Stdout)
+			printPrefixed(os.Stderr, prefix, invocation.Stderr)
+		}
+	}
+	return nil
+}
+
+// showConsoleOutput prints the console output of the VM's instances as it is read, each line prefixed with its instance ID
+func showConsoleOutput(ctx context.Context, vmClient vm.VMI, logsOptions LogsOptions, globalOpts GlobalOptions) error {
+	var selectorList []instances.Selector
+	if logsOptions.InstanceSelector != "" {
+		var err error
+		if selectorList, err = instances.ParseSelectors(logsOptions.InstanceSelector); err != nil {
+			return err
+		}
+	}
+	outputChan, err := vmClient.ConsoleOutput(ctx, globalOpts.Namespace, logsOptions.Name, selectorList, logsOptions.Follow)
+	if err != nil {
+		return err
+	}
+	for output := range outputChan {
+		switch globalOpts.Output {
+		case OutputJSON, OutputYAML:
+			line, err := json.Marshal(output)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(line))
+		default:
+			printPrefixed(os.Stdout, fmt.Sprintf("[%s] ", output.InstanceID), output.Output)
+		}
+	}
+	return nil
+}
+
+// printPrefixed prints every line of the output with the prefix
+func printPrefixed(w io.Writer, prefix, output string) {
+	if output == "" {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		fmt.Fprintf(w, "%s%s\n", prefix, line)
+	}
+}
@@ -0,0 +1,68 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/maintenance"
+	"github.com/bwagner5/nimbus/pkg/userconfig"
+)
+
+// nowFlagUsage is the usage of the --now flag of disruptive commands
+const nowFlagUsage = "Run now even if the namespace is outside of its maintenance windows in the nimbus config"
+
+// forceUnlockFlagUsage is the usage of the --force-unlock flag of commands that lock the VM they change
+const forceUnlockFlagUsage = "Release the lock of the VM held by another nimbus command first, e.g. one that crashed before its lock expired"
+
+// gateMaintenance holds the disruptive action until the namespace is within one of the maintenance windows of the nimbus config.
+// Outside of the windows the action is refused, or waits for the next window to open if the config queues disruptive commands.
+// now skips the check.
+func gateMaintenance(ctx context.Context, action, namespace string, now bool) error {
+	if now {
+		return nil
+	}
+	path, err := userconfig.DefaultPath()
+	if err != nil {
+		return err
+	}
+	cfg, err := userconfig.Load(path)
+	if err != nil {
+		return err
+	}
+	status, err := cfg.Maintenance.Check(namespace, time.Now())
+	if err != nil {
+		return err
+	}
+	if status.Open {
+		return nil
+	}
+	if status.Next.IsZero() {
+		return fmt.Errorf("refusing to %s, no maintenance window of namespace %q ever opens, pass --now to %s anyway", action, namespace, action)
+	}
+	if cfg.Maintenance.Outside != maintenance.OutsideQueue {
+		return fmt.Errorf("refusing to %s outside of the maintenance windows of namespace %q, the next window opens at %s, pass --now to %s anyway",
+			action, namespace, status.Next.Format(time.RFC3339), action)
+	}
+	logging.FromContext(ctx).Info("Outside of the maintenance windows, waiting for the next window to open", "action", action, "namespace", namespace, "opens", status.Next.Format(time.RFC3339))
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Until(status.Next)):
+	}
+	return nil
+}
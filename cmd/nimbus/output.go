@@ -0,0 +1,73 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// outputFileScheme is the prefix of an output mode that writes the output to a file, e.g. -o file://result.json
+const outputFileScheme = "file://"
+
+// outputFile is the file that the output is redirected to, it is nil when the output is written to stdout
+var outputFile *os.File
+
+// redirectOutput redirects stdout to the --output-file, or the file of a -o file:// output mode, so that automation reads the output
+// without having to separate it from logs and progress indicators, which stay on stderr.
+// The output format is inferred from a .json, .yaml, or .yml extension unless -o sets it.
+func redirectOutput(cmd *cobra.Command, globalOpts *GlobalOptions) error {
+	outputChanged := cmd.Flags().Changed("output")
+	if path, ok := strings.CutPrefix(globalOpts.Output, outputFileScheme); ok {
+		if globalOpts.OutputFile != "" && globalOpts.OutputFile != path {
+			return fmt.Errorf("--output-file %s conflicts with -o %s", globalOpts.OutputFile, globalOpts.Output)
+		}
+		globalOpts.OutputFile, globalOpts.Output, outputChanged = path, OutputTableShort, false
+	}
+	if globalOpts.OutputFile == "" {
+		return nil
+	}
+	if !outputChanged {
+		switch strings.ToLower(filepath.Ext(globalOpts.OutputFile)) {
+		case ".json":
+			globalOpts.Output = OutputJSON
+		case ".yaml", ".yml":
+			globalOpts.Output = OutputYAML
+		}
+	}
+	if globalOpts.Output == OutputInteractive {
+		return fmt.Errorf("interactive output can not be written to %s", globalOpts.OutputFile)
+	}
+	file, err := os.Create(globalOpts.OutputFile)
+	if err != nil {
+		return fmt.Errorf("unable to create output file: %w", err)
+	}
+	outputFile, os.Stdout = file, file
+	return nil
+}
+
+// closeOutput closes the file that the output was redirected to, if any
+func closeOutput() error {
+	if outputFile == nil {
+		return nil
+	}
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("unable to write output file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/charmbracelet/huh"
+	"github.com/mattn/go-isatty"
+	"github.com/samber/lo"
+)
+
+// isInteractiveTerminal returns true if stdin and stdout are attached to a terminal so that prompts can be shown
+func isInteractiveTerminal() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) && isatty.IsTerminal(os.Stdout.Fd())
+}
+
+// pickNetwork prompts the user to narrow down subnets and security groups when the launch plan's selectors match more than one.
+// The selectors in the returned plan are replaced with ID selectors for the picked resources so that the launch is deterministic.
+func pickNetwork(ctx context.Context, vmClient vm.AWSVM, launchPlan plans.LaunchPlan) (plans.LaunchPlan, error) {
+	if len(launchPlan.Spec.SubnetSelectors) != 0 {
+		subnetList, err := vmClient.ResolveSubnets(ctx, launchPlan.Spec.SubnetSelectors)
+		if err != nil {
+			return launchPlan, err
+		}
+		if len(subnetList) > 1 {
+			options := lo.Map(subnetList, func(subnet subnets.Subnet, _ int) huh.Option[string] {
+				label := fmt.Sprintf("%s  %s  %s  %s", *subnet.SubnetId, lo.FromPtr(subnet.AvailabilityZone), lo.FromPtr(subnet.CidrBlock), tagutils.EC2TagsToMap(subnet.Tags)["Name"])
+				return huh.NewOption(label, *subnet.SubnetId).Selected(true)
+			})
+			picked, err := pick("Subnet selector matched multiple subnets, pick the subnets to launch into", options)
+			if err != nil {
+				return launchPlan, err
+			}
+			launchPlan.Spec.SubnetSelectors = lo.Map(picked, func(id string, _ int) subnets.Selector { return subnets.Selector{ID: id} })
+		}
+	}
+	if len(launchPlan.Spec.SecurityGroupSelectors) != 0 {
+		securityGroupList, err := vmClient.ResolveSecurityGroups(ctx, launchPlan.Spec.SecurityGroupSelectors)
+		if err != nil {
+			return launchPlan, err
+		}
+		if len(securityGroupList) > 1 {
+			options := lo.Map(securityGroupList, func(sg securitygroups.SecurityGroup, _ int) huh.Option[string] {
+				label := fmt.Sprintf("%s  %s  %s", *sg.GroupId, lo.FromPtr(sg.GroupName), lo.FromPtr(sg.VpcId))
+				return huh.NewOption(label, *sg.GroupId).Selected(true)
+			})
+			picked, err := pick("Security group selector matched multiple security groups, pick the security groups to attach", options)
+			if err != nil {
+				return launchPlan, err
+			}
+			launchPlan.Spec.SecurityGroupSelectors = lo.Map(picked, func(id string, _ int) securitygroups.Selector { return securitygroups.Selector{ID: id} })
+		}
+	}
+	return launchPlan, nil
+}
+
+// pick shows a filterable multi-select prompt with all options preselected
+func pick(title string, options []huh.Option[string]) ([]string, error) {
+	var picked []string
+	err := huh.NewForm(huh.NewGroup(
+		huh.NewMultiSelect[string]().
+			Title(title).
+			Options(options...).
+			Filterable(true).
+			Value(&picked).
+			Validate(func(selected []string) error {
+				if len(selected) == 0 {
+					return fmt.Errorf("at least one must be picked")
+				}
+				return nil
+			}),
+	)).Run()
+	return picked, err
+}
@@ -0,0 +1,110 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/bwagner5/nimbus/pkg/plugin"
+	"github.com/bwagner5/nimbus/pkg/utils/awsutils"
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var (
+	cmdPlugin = &cobra.Command{
+		Use:   "plugin",
+		Short: "Discover nimbus plugins",
+		Long:  `Any executable on the PATH named nimbus-<name> can be run as "nimbus <name>".`,
+	}
+	cmdPluginList = &cobra.Command{
+		Use:   "list",
+		Short: "List plugins found on the PATH",
+		Args:  cobra.NoArgs,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			plugins := plugin.List()
+			if len(plugins) == 0 {
+				fmt.Println("No plugins found on the PATH")
+				return nil
+			}
+			for _, p := range plugins {
+				fmt.Println(p)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdPlugin)
+	cmdPlugin.AddCommand(cmdPluginList)
+}
+
+// runPlugin executes a plugin if args do not refer to a builtin command and a matching plugin is on the PATH.
+// Global flags before the plugin name, e.g. nimbus -n dev foo, are parsed and passed to the plugin.
+// It returns false if no plugin handled the args.
+func runPlugin(ctx context.Context, args []string) bool {
+	// a copy of the global flags that stops at the first non-flag arg, the flags are shared so parsing sets globalOpts
+	flags := pflag.NewFlagSet(rootCmd.Name(), pflag.ContinueOnError)
+	flags.AddFlagSet(rootCmd.PersistentFlags())
+	flags.SetInterspersed(false)
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return false
+	}
+	args = flags.Args()
+	if len(args) == 0 {
+		return false
+	}
+	if cmd, _, err := rootCmd.Find(args); err == nil && cmd != rootCmd {
+		return false
+	}
+	path, pluginArgs, ok := plugin.Find(args)
+	if !ok {
+		return false
+	}
+	opts, err := plugin.OptionsFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	opts.Version = version
+	opts.Verbose = opts.Verbose || globalOpts.Verbose
+	opts.NoMutate = opts.NoMutate || globalOpts.NoMutate
+	opts.Namespace = lo.CoalesceOrEmpty(globalOpts.Namespace, opts.Namespace)
+	opts.Region = lo.CoalesceOrEmpty(globalOpts.Region, opts.Region)
+	opts.Profile = lo.CoalesceOrEmpty(globalOpts.Profile, opts.Profile)
+	opts.RoleARN = lo.CoalesceOrEmpty(globalOpts.RoleARN, opts.RoleARN)
+	if globalOpts.SessionTags != "" {
+		if opts.SessionTags, err = awsutils.ParseSessionTags(globalOpts.SessionTags); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if err := plugin.Exec(ctx, path, pluginArgs, opts); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.ExitCode())
+		}
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	return true
+}
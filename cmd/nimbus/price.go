@@ -0,0 +1,90 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type PriceOptions struct {
+	InstanceTypeSelector string
+}
+
+var (
+	priceOptions = PriceOptions{}
+	cmdPrice     = &cobra.Command{
+		Use:   "price",
+		Short: "Compare the on-demand and spot prices of instance types per availability zone",
+		Long: `Compare the hourly on-demand and spot prices of the instance types that an instance type selector resolves to, per availability zone, before launching them.
+On-demand prices come from the AWS Pricing API and spot prices from the current EC2 spot price history. Prices are for Linux instances.
+Monthly prices are in the wide output.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return price(ctx, priceOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdPrice)
+	cmdPrice.Flags().StringVar(&priceOptions.InstanceTypeSelector, "instance-types", "", "Instance Type Criteria e.g. --instance-types 'vcpus:2-6,arch:arm64,local-storage:100GiB-', pin or ban names and globs with 'types:m5.large,c5.*' and 'exclude:t2*,t3*'")
+}
+
+func price(ctx context.Context, priceOptions PriceOptions, globalOpts GlobalOptions) error {
+	if priceOptions.InstanceTypeSelector == "" {
+		return fmt.Errorf("--instance-types must be specified")
+	}
+	instanceTypeSelectors, err := instancetypes.ParseSelectors(priceOptions.InstanceTypeSelector)
+	if err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+
+	prices, err := vmClient.Prices(ctx, instanceTypeSelectors)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(prices))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(prices))
+	default:
+		if len(prices) == 0 {
+			// the selector already parsed, so it canonicalizes
+			canonical, _ := selectors.Canonicalize(priceOptions.InstanceTypeSelector)
+			fmt.Printf("No instance types match the selector %s\n", canonical)
+			return nil
+		}
+		fmt.Println(pretty.Table(vm.PrettifyInstanceTypePrices(prices), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
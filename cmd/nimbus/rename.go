@@ -0,0 +1,87 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type RenameOptions struct {
+	Name            string
+	To              string
+	MoveToNamespace string
+	ForceUnlock     bool
+}
+
+var (
+	renameOptions = RenameOptions{}
+	cmdRename     = &cobra.Command{
+		Use:   "rename",
+		Short: "Rename a VM or move it to another namespace",
+		Long:  `Rename a VM or move it to another namespace by rewriting the nimbus tags on the VM and its associated resources, so that get and delete work with the new name.`,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return rename(ctx, renameOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdRename)
+	cmdRename.Flags().StringVar(&renameOptions.Name, "name", "", "Name of the VM to rename")
+	cmdRename.Flags().StringVar(&renameOptions.To, "to", "", "New name of the VM, defaults to the current name")
+	cmdRename.Flags().StringVar(&renameOptions.MoveToNamespace, "move-to-namespace", "", "Namespace to move the VM to, defaults to the current namespace")
+	cmdRename.Flags().BoolVar(&renameOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+}
+
+func rename(ctx context.Context, renameOptions RenameOptions, globalOpts GlobalOptions) (err error) {
+	if renameOptions.To == "" && renameOptions.MoveToNamespace == "" {
+		return fmt.Errorf("--to or --move-to-namespace must be specified")
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, renameOptions.Name, "rename", renameOptions.ForceUnlock)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	renamed, err := vmClient.Rename(ctx, globalOpts.Namespace, renameOptions.Name, renameOptions.MoveToNamespace, renameOptions.To)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(renamed))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(renamed))
+	default:
+		fmt.Println(pretty.Table(renamed, globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
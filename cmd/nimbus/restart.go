@@ -0,0 +1,124 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+type RestartOptions struct {
+	Name             string
+	InstanceSelector string
+	Batch            int
+	Pause            time.Duration
+	StopStart        bool
+	ProbePort        int32
+	Timeout          time.Duration
+	Now              bool
+	ForceUnlock      bool
+}
+
+var (
+	restartOptions = RestartOptions{}
+	cmdRestart     = &cobra.Command{
+		Use:   "restart",
+		Short: "Restart VMs in batches",
+		Long: `Restart the running VMs of a name or that match the instance selectors a batch at a time.
+Each batch is rebooted, or stopped and started with --stop-start, and the next batch is only restarted once every instance of the batch
+passes EC2 status checks and accepts connections on --probe-port. The restart stops at the first batch that does not become healthy within --timeout.`,
+		Example: `  nimbus restart --name web
+  nimbus restart --name web --batch 2 --pause 30s --probe-port 443
+  nimbus restart --name web --stop-start --timeout 15m`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return restart(ctx, restartOptions, globalOpts)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdRestart)
+	cmdRestart.Flags().StringVar(&restartOptions.Name, "name", "", "Name of the VMs")
+	cmdRestart.Flags().StringVar(&restartOptions.InstanceSelector, "instances", "", "Instance selector to choose the VMs within the namespace. e.g. --instances 'id:i-0123456' OR --instances 'tag:Role=worker'")
+	cmdRestart.Flags().IntVar(&restartOptions.Batch, "batch", 1, "Number of VMs to restart at once")
+	cmdRestart.Flags().DurationVar(&restartOptions.Pause, "pause", 0, "Time to wait after a batch is healthy before restarting the next batch")
+	cmdRestart.Flags().BoolVar(&restartOptions.StopStart, "stop-start", false, "Stop and start the VMs instead of rebooting them, which moves them to new hardware and loses instance store volumes")
+	cmdRestart.Flags().Int32Var(&restartOptions.ProbePort, "probe-port", 0, "TCP port that must accept connections on every VM of a batch before the next batch is restarted")
+	cmdRestart.Flags().BoolVar(&restartOptions.Now, "now", false, nowFlagUsage)
+	cmdRestart.Flags().BoolVar(&restartOptions.ForceUnlock, "force-unlock", false, forceUnlockFlagUsage)
+	cmdRestart.Flags().DurationVar(&restartOptions.Timeout, "timeout", 0, "Time to wait for a batch to become healthy (default 10m)")
+}
+
+func restart(ctx context.Context, restartOptions RestartOptions, globalOpts GlobalOptions) (err error) {
+	if restartOptions.Name == "" && restartOptions.InstanceSelector == "" {
+		return fmt.Errorf("--name or --instances must be specified")
+	}
+	if restartOptions.Batch < 1 {
+		return fmt.Errorf("--batch must be at least 1")
+	}
+	var selectorList []instances.Selector
+	if restartOptions.InstanceSelector != "" {
+		var err error
+		selectorList, err = instances.ParseSelectors(restartOptions.InstanceSelector)
+		if err != nil {
+			return err
+		}
+	}
+	if err := gateMaintenance(ctx, "restart instances", globalOpts.Namespace, restartOptions.Now); err != nil {
+		return err
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+
+	vmClient := vm.New(awsCfg)
+	unlock, err := vmClient.Lock(ctx, globalOpts.Namespace, restartOptions.Name, "restart", restartOptions.ForceUnlock)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, unlock()) }()
+
+	instanceList, err := vmClient.Restart(ctx, globalOpts.Namespace, restartOptions.Name, selectorList, vm.RestartOptions{
+		BatchSize: restartOptions.Batch,
+		Pause:     restartOptions.Pause,
+		StopStart: restartOptions.StopStart,
+		Probe:     plans.ReadinessProbe{Port: restartOptions.ProbePort, Timeout: restartOptions.Timeout},
+	})
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(instanceList))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(instanceList))
+	default:
+		fmt.Println(pretty.Table(instances.PrettifyAll(instanceList), globalOpts.Output == OutputTableWide))
+	}
+	return nil
+}
@@ -22,8 +22,11 @@ import (
 
 	"dario.cat/mergo"
 	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/progress"
+	"github.com/bwagner5/nimbus/pkg/readonly"
 	"github.com/bwagner5/nimbus/pkg/tui"
+	"github.com/bwagner5/nimbus/pkg/utils/awsutils"
 	"github.com/bwagner5/nimbus/pkg/vm"
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
@@ -50,6 +53,14 @@ type GlobalOptions struct {
 	ConfigFile string
 	Region     string
 	Profile    string
+	NoMutate   bool
+	RoleARN    string
+	// SessionTags are comma separated key=value pairs
+	SessionTags string
+	// NoColor disables colored output, which is also disabled by NO_COLOR or when stdout is not a terminal
+	NoColor bool
+	// OutputFile is the file that output is written to instead of stdout
+	OutputFile string
 }
 
 type RootOptions struct {
@@ -62,6 +73,23 @@ var (
 	rootCmd    = &cobra.Command{
 		Use:     "vm",
 		Version: version,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if err := redirectOutput(cmd, &globalOpts); err != nil {
+				return err
+			}
+			pretty.SetColor(pretty.SupportsColor(globalOpts.NoColor, os.Stdout))
+			if err := applyUserConfig(cmd, &globalOpts); err != nil {
+				return err
+			}
+			// progress indicators would interleave with debug logs and redraw over the interactive UI
+			if !globalOpts.Verbose && globalOpts.Output != OutputInteractive {
+				cmd.SetContext(progress.ToContext(cmd.Context(), progress.DefaultReporter()))
+			}
+			return nil
+		},
+		PersistentPostRunE: func(_ *cobra.Command, _ []string) error {
+			return closeOutput()
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return root(cmd.Context(), globalOpts)
 		},
@@ -77,16 +105,24 @@ func main() {
 	rootCmd.PersistentFlags().BoolVar(&globalOpts.Verbose, "verbose", false, "Verbose output")
 	rootCmd.PersistentFlags().BoolVar(&globalOpts.Version, "version", false, "version")
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.Output, "output", "o", OutputTableShort,
-		fmt.Sprintf("Output mode: %v", []string{OutputTableShort, OutputTableWide, OutputYAML, OutputJSON, OutputInteractive}))
+		fmt.Sprintf("Output mode: %v, or %s<path> to write to a file in the format of its extension", []string{OutputTableShort, OutputTableWide, OutputYAML, OutputJSON, OutputInteractive}, outputFileScheme))
+	rootCmd.PersistentFlags().StringVar(&globalOpts.OutputFile, "output-file", "", "Write output to a file instead of stdout, logs stay on stderr. The format is inferred from a .json, .yaml, or .yml extension unless -o is set")
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.ConfigFile, "file", "f", "", "YAML Config File")
 
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.Namespace, "namespace", "n", "", "Logical grouping of resources. All resources are tagged with the namespace.")
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.Region, "region", "r", "", "AWS Region")
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.Profile, "profile", "p", "", "AWS CLI Profile")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.RoleARN, "role-arn", "", "IAM role to assume for all AWS API calls")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.SessionTags, "session-tags", "", "Comma separated key=value session tags applied to the --role-arn session, e.g. team=infra,ticket=OPS-123")
+	rootCmd.PersistentFlags().BoolVar(&globalOpts.NoColor, "no-color", false, fmt.Sprintf("Disable colored output. Can also be disabled with %s=1", pretty.NoColorEnvVar))
+	rootCmd.PersistentFlags().BoolVar(&globalOpts.NoMutate, "no-mutate", false, fmt.Sprintf("Read-only mode, any AWS API call that would change resources fails. Can also be enabled with %s=true", readonly.EnvVar))
 
 	rootCmd.AddCommand(&cobra.Command{Use: "completion", Hidden: true})
 	cobra.EnableCommandSorting = false
 
+	if runPlugin(context.Background(), os.Args[1:]) {
+		return
+	}
 	lo.Must0(rootCmd.Execute())
 }
 
@@ -108,6 +144,17 @@ func root(ctx context.Context, globalOpts GlobalOptions) error {
 	return nil
 }
 
+// printPlan prints an executed plan, including its status conditions, in the JSON or YAML output format.
+// Table outputs only print the plan as YAML in verbose mode.
+func printPlan(plan any, globalOpts GlobalOptions) {
+	switch {
+	case globalOpts.Output == OutputJSON:
+		fmt.Println(pretty.EncodeJSON(plan))
+	case globalOpts.Output == OutputYAML, globalOpts.Verbose:
+		fmt.Println(pretty.EncodeYAML(plan))
+	}
+}
+
 func ParseConfig[T any](globalOpts GlobalOptions, opts T) (T, error) {
 	if globalOpts.ConfigFile == "" {
 		return opts, nil
@@ -127,16 +174,16 @@ func ParseConfig[T any](globalOpts GlobalOptions, opts T) (T, error) {
 }
 
 func AWSConfig(ctx context.Context, globalOptions GlobalOptions) (*aws.Config, error) {
-	var options []func(*config.LoadOptions) error
-	if globalOptions.Region != "" {
-		options = append(options, config.WithRegion(globalOptions.Region))
-	}
-	if globalOptions.Profile != "" {
-		options = append(options, config.WithSharedConfigProfile(globalOptions.Profile))
-	}
-	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	sessionTags, err := awsutils.ParseSessionTags(globalOptions.SessionTags)
 	if err != nil {
 		return nil, err
 	}
-	return &cfg, nil
+	return awsutils.LoadConfig(ctx, awsutils.ConfigOptions{
+		Region:      globalOptions.Region,
+		Profile:     globalOptions.Profile,
+		NoMutate:    globalOptions.NoMutate || readonly.Enabled(),
+		RoleARN:     globalOptions.RoleARN,
+		SessionTags: sessionTags,
+		Version:     version,
+	})
 }
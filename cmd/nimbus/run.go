@@ -0,0 +1,172 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/artifacts"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/spf13/cobra"
+)
+
+// RunOptions are the options of the run command
+type RunOptions struct {
+	Name                 string
+	Script               string
+	InstanceTypeSelector string
+	AMISelector          string
+	CapacityType         string
+	IAMRole              string
+	Timeout              time.Duration
+	Keep                 bool
+	Artifacts            string
+	Outputs              bool
+	ArtifactsMode        string
+	ArtifactsBucket      string
+}
+
+var (
+	runOptions = RunOptions{}
+	cmdRun     = &cobra.Command{
+		Use:   "run [flags] [-- COMMAND [ARGS...]]",
+		Short: "Run a batch job on an ephemeral Spot instance",
+		Long: `Launch a single instance that runs a command or script from its user-data, stream the job's output, and delete the VM when the job exits.
+The job's output is read through SSM, so the IAM role must allow SSM, e.g. with the AmazonSSMManagedInstanceCore policy.
+nimbus exits with the job's exit code. The instance terminates itself after the timeout in case nimbus is interrupted.`,
+		Example: `  nimbus run --iam-role batch-ssm -- 'echo hello from $(hostname)'
+  nimbus run --iam-role batch-ssm --script train.sh --instance-types 'vcpus:8,arch:arm64' --timeout 4h
+  nimbus run --iam-role batch-ssm --capacity-type on-demand --keep -- make -C /opt/app test
+  nimbus run --iam-role batch-ssm --artifacts data/,process.py --outputs -- 'python3 /var/lib/nimbus/inputs/process.py > /var/lib/nimbus/outputs/report.txt'`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := logging.ToContext(cmd.Context(), logging.DefaultLogger(globalOpts.Verbose))
+			return run(ctx, runOptions, globalOpts, strings.Join(args, " "))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(cmdRun)
+	cmdRun.Flags().StringVar(&runOptions.Name, "name", "", "Name of the VM (default run-<timestamp>)")
+	cmdRun.Flags().StringVar(&runOptions.Script, "script", "", "File containing the script to run instead of a command, a script with a shebang runs with its interpreter")
+	cmdRun.Flags().StringVar(&runOptions.InstanceTypeSelector, "instance-types", "vcpus:2,memory:4GiB-8GiB", "Instance Type Criteria of the instance")
+	cmdRun.Flags().StringVar(&runOptions.AMISelector, "amis", "", "AMI selector of the instance's OS Image, it must run shell script user-data. e.g. --amis 'alias:al2023'")
+	cmdRun.Flags().StringVar(&runOptions.CapacityType, "capacity-type", "spot", "Spot or On-Demand")
+	cmdRun.Flags().StringVar(&runOptions.IAMRole, "iam-role", "", "IAM Role of the instance, it must allow SSM to stream the job's output, e.g. with the AmazonSSMManagedInstanceCore policy")
+	cmdRun.Flags().DurationVar(&runOptions.Timeout, "timeout", time.Hour, "How long the job may run once the instance is reachable before it is stopped")
+	cmdRun.Flags().StringVar(&runOptions.Artifacts, "artifacts", "", fmt.Sprintf("Local files or directories separated by commas that are staged in S3 and downloaded to %s before the job runs", artifacts.InputDir))
+	cmdRun.Flags().BoolVar(&runOptions.Outputs, "outputs", false, fmt.Sprintf("Publish the files the job writes to %s to S3 after it finishes, as %s with pre-signed URLs", artifacts.OutputDir, artifacts.OutputArchive))
+	cmdRun.Flags().StringVar(&runOptions.ArtifactsMode, "artifacts-mode", "", fmt.Sprintf("How the instance transfers artifacts: %s (pre-signed URLs, no S3 permissions needed) or %s (aws s3 sync, the IAM role must allow the bucket) (default %s)", artifacts.ModePresigned, artifacts.ModeSync, artifacts.ModePresigned))
+	cmdRun.Flags().StringVar(&runOptions.ArtifactsBucket, "artifacts-bucket", "", "S3 bucket in the region to stage artifacts in (default a nimbus bucket of the account and region)")
+	cmdRun.Flags().BoolVar(&runOptions.Keep, "keep", false, "Keep the VM after the job finishes, e.g. to inspect it, it still terminates itself after the timeout")
+}
+
+func run(ctx context.Context, runOptions RunOptions, globalOpts GlobalOptions, command string) error {
+	script, err := runScript(runOptions.Script, command)
+	if err != nil {
+		return err
+	}
+	if runOptions.IAMRole == "" {
+		return fmt.Errorf("--iam-role must be specified, the job's output is read through SSM")
+	}
+	instanceTypeSelectors, err := instancetypes.ParseSelectors(runOptions.InstanceTypeSelector)
+	if err != nil {
+		return err
+	}
+	amiSelectors, err := amis.ParseSelectors(runOptions.AMISelector)
+	if err != nil {
+		return err
+	}
+	name := runOptions.Name
+	if name == "" {
+		name = fmt.Sprintf("run-%d", time.Now().Unix())
+	}
+
+	awsCfg, err := AWSConfig(ctx, globalOpts)
+	if err != nil {
+		return err
+	}
+	vmClient := vm.New(awsCfg)
+
+	fmt.Fprintf(os.Stderr, "Running %s/%s, its output follows once the instance is reachable...\n", globalOpts.Namespace, name)
+	_, result, err := vmClient.Run(ctx, plans.LaunchPlan{
+		Metadata: plans.LaunchMetadata{
+			Namespace: globalOpts.Namespace,
+			Name:      name,
+		},
+		Spec: plans.LaunchSpec{
+			CapacityType:          runOptions.CapacityType,
+			IAMRole:               runOptions.IAMRole,
+			InstanceTypeSelectors: instanceTypeSelectors,
+			AMISelectors:          amiSelectors,
+		},
+	}, vm.Job{
+		Script:  script,
+		Timeout: runOptions.Timeout,
+		Keep:    runOptions.Keep,
+		Artifacts: artifacts.Options{
+			Inputs:  parseList(runOptions.Artifacts),
+			Outputs: runOptions.Outputs,
+			Mode:    runOptions.ArtifactsMode,
+			Bucket:  runOptions.ArtifactsBucket,
+		},
+	}, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	switch globalOpts.Output {
+	case OutputJSON:
+		fmt.Println(pretty.EncodeJSON(result))
+	case OutputYAML:
+		fmt.Println(pretty.EncodeYAML(result))
+	default:
+		fmt.Fprintf(os.Stderr, "Job on %s exited with code %d after %s\n", result.InstanceID, result.ExitCode, pretty.Duration(result.Duration))
+		if result.Outputs != "" {
+			fmt.Fprintf(os.Stderr, "Outputs were published to %s\n", result.Outputs)
+		}
+	}
+	if runOptions.Keep {
+		fmt.Fprintf(os.Stderr, "Delete the VM with: nimbus delete --name %s\n", name)
+	}
+	// the VM is already deleted, so the job's exit code becomes nimbus' exit code
+	if result.ExitCode != 0 {
+		os.Exit(result.ExitCode)
+	}
+	return nil
+}
+
+// runScript returns the contents of the script file or the command, exactly one of them is required
+func runScript(scriptPath, command string) (string, error) {
+	if (scriptPath == "") == (command == "") {
+		return "", fmt.Errorf("a command or --script must be specified, but not both")
+	}
+	if command != "" {
+		return command, nil
+	}
+	script, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return "", fmt.Errorf("unable to read script: %w", err)
+	}
+	return string(script), nil
+}
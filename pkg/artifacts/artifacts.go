@@ -0,0 +1,333 @@
+// Package artifacts stages local files in S3 for instances to download at boot, and publishes the outputs of jobs back to S3.
+//
+// Artifacts are stored under a namespace-scoped prefix of a bucket, namespace/name/, with inputs under inputs/ and outputs under outputs/.
+// They outlive the VM so that outputs can be retrieved after it is deleted.
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
+)
+
+const (
+	// ModePresigned injects pre-signed URLs into user-data, instances do not need any S3 permissions
+	ModePresigned = "presigned"
+	// ModeSync injects aws s3 sync commands into user-data, the instances' IAM role must allow the bucket
+	ModeSync = "sync"
+
+	// InputDir is where instances download their inputs to, keeping the names of the uploaded files and directories
+	InputDir = "/var/lib/nimbus/inputs"
+	// OutputDir is where jobs write the outputs to publish
+	OutputDir = "/var/lib/nimbus/outputs"
+	// OutputArchive is the name of the archive of OutputDir that is uploaded with a pre-signed URL, since a URL can only upload a single object
+	OutputArchive = "outputs.tar.gz"
+)
+
+// Watcher uploads artifacts to S3 and creates the user-data snippets that transfer them on instances
+type Watcher struct {
+	s3API      SDKS3Ops
+	presignAPI SDKS3PresignOps
+	stsAPI     SDKSTSOps
+	uploader   *manager.Uploader
+}
+
+// SDKS3Ops is an interface that combines the necessary S3 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKS3Ops interface {
+	manager.UploadAPIClient
+	GetBucketLocation(context.Context, *s3.GetBucketLocationInput, ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error)
+	CreateBucket(context.Context, *s3.CreateBucketInput, ...func(*s3.Options)) (*s3.CreateBucketOutput, error)
+	DeleteBucket(context.Context, *s3.DeleteBucketInput, ...func(*s3.Options)) (*s3.DeleteBucketOutput, error)
+}
+
+// SDKS3PresignOps is an interface that combines the necessary S3 presign client methods.
+// Presigning does not call S3, so a URL fails when it is used if the caller is not allowed the object operation.
+type SDKS3PresignOps interface {
+	PresignGetObject(context.Context, *s3.GetObjectInput, ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+	PresignPutObject(context.Context, *s3.PutObjectInput, ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error)
+}
+
+// SDKSTSOps is an interface that combines the necessary STS SDK client interfaces
+type SDKSTSOps interface {
+	GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// Options are the artifacts to stage for a VM
+type Options struct {
+	// Inputs are local files or directories that are uploaded and downloaded to InputDir on the instances
+	Inputs []string
+	// Outputs publishes the contents of OutputDir after a job finishes
+	Outputs bool
+	// Mode is presigned or sync, defaults to presigned
+	Mode string
+	// Bucket defaults to a bucket that nimbus creates for the account and region, see Bucket
+	Bucket string
+	// Expires is how long pre-signed URLs are valid
+	Expires time.Duration
+}
+
+// Artifact is a local file uploaded to S3
+type Artifact struct {
+	// Path is the local file
+	Path string
+	// Key is the object key of the file in the bucket
+	Key string
+	// Dest is where instances download the file to
+	Dest string
+	// URL is the pre-signed URL that downloads the file, it is only set in presigned mode
+	URL string `json:"-" yaml:"-"`
+}
+
+// Staging is where a VM's artifacts are stored and the shell commands that transfer them on the VM's instances
+type Staging struct {
+	Bucket string
+	// Prefix is the namespace-scoped prefix of the VM's artifacts
+	Prefix string
+	Inputs []Artifact
+	// Outputs is the S3 URI that outputs are published to, empty if outputs are not published
+	Outputs string
+	// Download downloads the inputs to InputDir, it fails if any download fails
+	Download string `json:"-" yaml:"-"`
+	// Upload publishes the contents of OutputDir, it fails if the upload fails
+	Upload string `json:"-" yaml:"-"`
+}
+
+// NewWatcher creates a new Artifact Watcher
+func NewWatcher(s3API SDKS3Ops, presignAPI SDKS3PresignOps, stsAPI SDKSTSOps) Watcher {
+	return Watcher{
+		s3API:      s3API,
+		presignAPI: presignAPI,
+		stsAPI:     stsAPI,
+		uploader:   manager.NewUploader(s3API),
+	}
+}
+
+// Stage uploads the inputs under the prefix of the namespace and name and returns the commands that transfer the artifacts on instances.
+// The default bucket is created the first time it is used.
+func (w Watcher) Stage(ctx context.Context, region, namespace, name string, opts Options) (Staging, error) {
+	if err := ValidateMode(opts.Mode); err != nil {
+		return Staging{}, err
+	}
+	mode := lo.CoalesceOrEmpty(opts.Mode, ModePresigned)
+	staging := Staging{Bucket: opts.Bucket, Prefix: Prefix(namespace, name)}
+	if staging.Bucket == "" {
+		var err error
+		if staging.Bucket, _, err = w.EnsureBucket(ctx, region); err != nil {
+			return Staging{}, err
+		}
+	}
+
+	var err error
+	staging.Inputs, err = Plan(staging.Prefix, opts.Inputs)
+	if err != nil {
+		return Staging{}, err
+	}
+	for i, artifact := range staging.Inputs {
+		if err := w.upload(ctx, staging.Bucket, artifact); err != nil {
+			return Staging{}, err
+		}
+		if mode != ModePresigned {
+			continue
+		}
+		presigned, err := w.presignAPI.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(staging.Bucket), Key: aws.String(artifact.Key)}, s3.WithPresignExpires(opts.Expires))
+		if err != nil {
+			return Staging{}, fmt.Errorf("failed to presign artifact %s: %w", artifact.Path, err)
+		}
+		staging.Inputs[i].URL = presigned.URL
+	}
+
+	if mode == ModeSync {
+		staging.Download = SyncDownload(staging.Bucket, staging.Prefix)
+	} else {
+		staging.Download = PresignedDownload(staging.Inputs)
+	}
+	if !opts.Outputs {
+		return staging, nil
+	}
+	if mode == ModeSync {
+		staging.Outputs = fmt.Sprintf("s3://%s/%soutputs/", staging.Bucket, staging.Prefix)
+		staging.Upload = SyncUpload(staging.Bucket, staging.Prefix)
+		return staging, nil
+	}
+	outputsKey := staging.Prefix + OutputArchive
+	presigned, err := w.presignAPI.PresignPutObject(ctx, &s3.PutObjectInput{Bucket: aws.String(staging.Bucket), Key: aws.String(outputsKey)}, s3.WithPresignExpires(opts.Expires))
+	if err != nil {
+		return Staging{}, fmt.Errorf("failed to presign outputs %s: %w", outputsKey, err)
+	}
+	staging.Outputs = fmt.Sprintf("s3://%s/%s", staging.Bucket, outputsKey)
+	staging.Upload = PresignedUpload(presigned.URL)
+	return staging, nil
+}
+
+// EnsureBucket returns the default artifacts bucket of the account and region, creating it if it does not exist.
+// created is true if the bucket was created by this call.
+func (w Watcher) EnsureBucket(ctx context.Context, region string) (bucket string, created bool, err error) {
+	bucket, err = w.defaultBucket(ctx, region)
+	if err != nil {
+		return "", false, err
+	}
+	_, err = w.s3API.GetBucketLocation(ctx, &s3.GetBucketLocationInput{Bucket: aws.String(bucket)})
+	if err == nil {
+		return bucket, false, nil
+	}
+	if !IsNotFound(err) {
+		return "", false, fmt.Errorf("failed to get artifacts bucket %s: %w", bucket, err)
+	}
+	input := &s3.CreateBucketInput{Bucket: aws.String(bucket)}
+	// us-east-1 is the default location of buckets and is rejected as a location constraint
+	if region != "us-east-1" {
+		input.CreateBucketConfiguration = &s3types.CreateBucketConfiguration{LocationConstraint: s3types.BucketLocationConstraint(region)}
+	}
+	if _, err := w.s3API.CreateBucket(ctx, input); err != nil {
+		return "", false, fmt.Errorf("failed to create artifacts bucket %s: %w", bucket, err)
+	}
+	return bucket, true, nil
+}
+
+// DeleteBucket deletes the default artifacts bucket of the account and region, deleted is false if it does not exist.
+// Artifacts outlive the VMs, so a bucket that still has objects is not emptied and fails with a BucketNotEmpty error, see IsBucketNotEmpty.
+func (w Watcher) DeleteBucket(ctx context.Context, region string) (bucket string, deleted bool, err error) {
+	bucket, err = w.defaultBucket(ctx, region)
+	if err != nil {
+		return "", false, err
+	}
+	if _, err := w.s3API.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(bucket)}); err != nil {
+		if IsNotFound(err) {
+			return bucket, false, nil
+		}
+		return bucket, false, fmt.Errorf("failed to delete artifacts bucket %s: %w", bucket, err)
+	}
+	return bucket, true, nil
+}
+
+// defaultBucket returns the name of the default artifacts bucket of the caller's account and the region
+func (w Watcher) defaultBucket(ctx context.Context, region string) (string, error) {
+	identity, err := w.stsAPI.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get the account of the artifacts bucket: %w", err)
+	}
+	return Bucket(aws.ToString(identity.Account), region), nil
+}
+
+// upload uploads the artifact's file to its key, large files are uploaded in parts
+func (w Watcher) upload(ctx context.Context, bucket string, artifact Artifact) error {
+	file, err := os.Open(artifact.Path)
+	if err != nil {
+		return fmt.Errorf("unable to read artifact: %w", err)
+	}
+	defer file.Close()
+	if _, err := w.uploader.Upload(ctx, &s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(artifact.Key), Body: file}); err != nil {
+		return fmt.Errorf("failed to upload artifact %s: %w", artifact.Path, err)
+	}
+	return nil
+}
+
+// IsNotFound returns true if the error is a missing bucket error
+func IsNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket"
+}
+
+// IsBucketNotEmpty returns true if the error is a delete of a bucket that still has objects
+func IsBucketNotEmpty(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "BucketNotEmpty"
+}
+
+// ValidateMode checks that the artifacts mode is supported, empty is the default presigned mode
+func ValidateMode(mode string) error {
+	if mode != "" && mode != ModePresigned && mode != ModeSync {
+		return fmt.Errorf("invalid artifacts mode %q, must be %s or %s", mode, ModePresigned, ModeSync)
+	}
+	return nil
+}
+
+// Bucket returns the name of the artifacts bucket that nimbus creates for the account and region
+func Bucket(accountID, region string) string {
+	return fmt.Sprintf("nimbus-artifacts-%s-%s", accountID, region)
+}
+
+// Prefix returns the namespace-scoped prefix of a VM's artifacts
+func Prefix(namespace, name string) string {
+	return fmt.Sprintf("%s/%s/", namespace, name)
+}
+
+// Plan returns the artifacts of the local files and directories, without uploading them.
+// Files keep their base name and the files of a directory keep their path relative to the directory's parent.
+func Plan(prefix string, paths []string) ([]Artifact, error) {
+	var artifacts []Artifact
+	for _, root := range paths {
+		root = filepath.Clean(root)
+		parent := filepath.Dir(root)
+		err := filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(parent, file)
+			if err != nil {
+				return err
+			}
+			rel = filepath.ToSlash(rel)
+			artifacts = append(artifacts, Artifact{Path: file, Key: prefix + "inputs/" + rel, Dest: path.Join(InputDir, rel)})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("unable to read artifact: %w", err)
+		}
+	}
+	duplicates := lo.FindDuplicatesBy(artifacts, func(artifact Artifact) string { return artifact.Key })
+	if len(duplicates) != 0 {
+		return nil, fmt.Errorf("artifacts %s have the same name", strings.Join(lo.Map(duplicates, func(artifact Artifact, _ int) string { return artifact.Dest }), ", "))
+	}
+	return artifacts, nil
+}
+
+// PresignedDownload returns the shell commands that download the artifacts from their pre-signed URLs
+func PresignedDownload(artifacts []Artifact) string {
+	var commands strings.Builder
+	fmt.Fprintf(&commands, "mkdir -p %s %s\n", InputDir, OutputDir)
+	for _, artifact := range artifacts {
+		fmt.Fprintf(&commands, "mkdir -p %s && curl -fsS --retry 3 -o %s %s\n", quote(path.Dir(artifact.Dest)), quote(artifact.Dest), quote(artifact.URL))
+	}
+	return commands.String()
+}
+
+// PresignedUpload returns the shell commands that archive OutputDir and upload it to the pre-signed URL
+func PresignedUpload(url string) string {
+	archive := path.Join(path.Dir(OutputDir), OutputArchive)
+	return fmt.Sprintf("tar -czf %s -C %s . && curl -fsS --retry 3 -X PUT -T %s %s\n", archive, OutputDir, archive, quote(url))
+}
+
+// SyncDownload returns the shell command that syncs the inputs under the prefix to InputDir
+func SyncDownload(bucket, prefix string) string {
+	return fmt.Sprintf("mkdir -p %s %s\naws s3 sync --only-show-errors %s %s\n", InputDir, OutputDir, quote(fmt.Sprintf("s3://%s/%sinputs/", bucket, prefix)), InputDir)
+}
+
+// SyncUpload returns the shell command that syncs OutputDir to the outputs under the prefix
+func SyncUpload(bucket, prefix string) string {
+	return fmt.Sprintf("aws s3 sync --only-show-errors %s %s\n", OutputDir, quote(fmt.Sprintf("s3://%s/%soutputs/", bucket, prefix)))
+}
+
+// quote quotes a string for a shell, single quotes in it are closed, escaped, and reopened
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
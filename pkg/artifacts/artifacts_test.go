@@ -0,0 +1,243 @@
+package artifacts_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/bwagner5/nimbus/pkg/artifacts"
+	"github.com/samber/lo"
+)
+
+func TestPlan(t *testing.T) {
+	dir := t.TempDir()
+	lo.Must0(os.MkdirAll(filepath.Join(dir, "data", "nested"), 0o755))
+	lo.Must0(os.WriteFile(filepath.Join(dir, "data", "a.csv"), []byte("a"), 0o644))
+	lo.Must0(os.WriteFile(filepath.Join(dir, "data", "nested", "b.csv"), []byte("b"), 0o644))
+	lo.Must0(os.WriteFile(filepath.Join(dir, "run.py"), []byte("print()"), 0o644))
+	lo.Must0(os.MkdirAll(filepath.Join(dir, "other"), 0o755))
+	lo.Must0(os.WriteFile(filepath.Join(dir, "other", "run.py"), []byte("print()"), 0o644))
+
+	testCases := []struct {
+		name        string
+		paths       []string
+		expected    map[string]string
+		expectError bool
+	}{
+		{
+			name:  "files and directories",
+			paths: []string{filepath.Join(dir, "data") + "/", filepath.Join(dir, "run.py")},
+			expected: map[string]string{
+				"team/job/inputs/data/a.csv":        "/var/lib/nimbus/inputs/data/a.csv",
+				"team/job/inputs/data/nested/b.csv": "/var/lib/nimbus/inputs/data/nested/b.csv",
+				"team/job/inputs/run.py":            "/var/lib/nimbus/inputs/run.py",
+			},
+		},
+		{
+			name:     "no paths",
+			expected: map[string]string{},
+		},
+		{
+			name:        "same name",
+			paths:       []string{filepath.Join(dir, "run.py"), filepath.Join(dir, "other", "run.py")},
+			expectError: true,
+		},
+		{
+			name:        "missing file",
+			paths:       []string{filepath.Join(dir, "missing")},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			planned, err := artifacts.Plan(artifacts.Prefix("team", "job"), tc.paths)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			got := lo.SliceToMap(planned, func(artifact artifacts.Artifact) (string, string) { return artifact.Key, artifact.Dest })
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %v, got %v", tc.expected, got)
+			}
+			for key, dest := range tc.expected {
+				if got[key] != dest {
+					t.Errorf("expected %s to be downloaded to %s, got %q", key, dest, got[key])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	for mode, valid := range map[string]bool{
+		"":                      true,
+		artifacts.ModePresigned: true,
+		artifacts.ModeSync:      true,
+		"copy":                  false,
+	} {
+		if err := artifacts.ValidateMode(mode); (err == nil) != valid {
+			t.Errorf("expected mode %q to be valid: %t, got %v", mode, valid, err)
+		}
+	}
+}
+
+func TestPresignedDownload(t *testing.T) {
+	download := artifacts.PresignedDownload([]artifacts.Artifact{{
+		Dest: "/var/lib/nimbus/inputs/it's.txt",
+		URL:  "https://bucket.s3.us-west-2.amazonaws.com/team/job/inputs/it%27s.txt?X-Amz-Signature=abc&X-Amz-Expires=3600",
+	}})
+	expected := `mkdir -p '/var/lib/nimbus/inputs' && curl -fsS --retry 3 -o '/var/lib/nimbus/inputs/it'\''s.txt' 'https://bucket.s3.us-west-2.amazonaws.com/team/job/inputs/it%27s.txt?X-Amz-Signature=abc&X-Amz-Expires=3600'`
+	if !strings.Contains(download, expected) {
+		t.Errorf("expected the download to contain %s, got %s", expected, download)
+	}
+}
+
+type fakeS3 struct {
+	artifacts.SDKS3Ops
+	buckets  map[string]bool
+	uploaded map[string]string
+}
+
+func (f *fakeS3) GetBucketLocation(_ context.Context, input *s3.GetBucketLocationInput, _ ...func(*s3.Options)) (*s3.GetBucketLocationOutput, error) {
+	if !f.buckets[*input.Bucket] {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchBucket"}
+	}
+	return &s3.GetBucketLocationOutput{}, nil
+}
+
+func (f *fakeS3) CreateBucket(_ context.Context, input *s3.CreateBucketInput, _ ...func(*s3.Options)) (*s3.CreateBucketOutput, error) {
+	if input.CreateBucketConfiguration == nil || input.CreateBucketConfiguration.LocationConstraint != "us-west-2" {
+		return nil, &smithy.GenericAPIError{Code: "IllegalLocationConstraintException"}
+	}
+	f.buckets[*input.Bucket] = true
+	return &s3.CreateBucketOutput{}, nil
+}
+
+func (f *fakeS3) DeleteBucket(_ context.Context, input *s3.DeleteBucketInput, _ ...func(*s3.Options)) (*s3.DeleteBucketOutput, error) {
+	if !f.buckets[*input.Bucket] {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchBucket"}
+	}
+	if len(f.uploaded) != 0 {
+		return nil, &smithy.GenericAPIError{Code: "BucketNotEmpty"}
+	}
+	delete(f.buckets, *input.Bucket)
+	return &s3.DeleteBucketOutput{}, nil
+}
+
+func (f *fakeS3) PutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if !f.buckets[*input.Bucket] {
+		return nil, &smithy.GenericAPIError{Code: "NoSuchBucket"}
+	}
+	body := new(strings.Builder)
+	lo.Must(io.Copy(body, input.Body))
+	f.uploaded[*input.Key] = body.String()
+	return &s3.PutObjectOutput{}, nil
+}
+
+type fakePresign struct{}
+
+func (fakePresign) PresignGetObject(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return &v4.PresignedHTTPRequest{URL: "https://" + *input.Bucket + ".s3.amazonaws.com/" + *input.Key + "?method=GET", Method: http.MethodGet}, nil
+}
+
+func (fakePresign) PresignPutObject(_ context.Context, input *s3.PutObjectInput, _ ...func(*s3.PresignOptions)) (*v4.PresignedHTTPRequest, error) {
+	return &v4.PresignedHTTPRequest{URL: "https://" + *input.Bucket + ".s3.amazonaws.com/" + *input.Key + "?method=PUT", Method: http.MethodPut}, nil
+}
+
+type fakeSTS struct{}
+
+func (fakeSTS) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String("123456789012")}, nil
+}
+
+func TestStage(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "input.txt")
+	lo.Must0(os.WriteFile(input, []byte("hello"), 0o644))
+
+	t.Run("presigned", func(t *testing.T) {
+		s3API := &fakeS3{buckets: map[string]bool{}, uploaded: map[string]string{}}
+		staging, err := artifacts.NewWatcher(s3API, fakePresign{}, fakeSTS{}).Stage(context.Background(), "us-west-2", "team", "job", artifacts.Options{Inputs: []string{input}, Outputs: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if staging.Bucket != "nimbus-artifacts-123456789012-us-west-2" || !s3API.buckets[staging.Bucket] {
+			t.Errorf("expected the default bucket to be created, got %s", staging.Bucket)
+		}
+		if s3API.uploaded["team/job/inputs/input.txt"] != "hello" {
+			t.Errorf("expected the input to be uploaded, got %v", s3API.uploaded)
+		}
+		if !strings.Contains(staging.Download, "team/job/inputs/input.txt?method=GET") {
+			t.Errorf("expected the download to use a pre-signed URL, got %s", staging.Download)
+		}
+		if !strings.Contains(staging.Upload, "team/job/outputs.tar.gz?method=PUT") {
+			t.Errorf("expected the upload to use a pre-signed URL, got %s", staging.Upload)
+		}
+		if staging.Outputs != "s3://nimbus-artifacts-123456789012-us-west-2/team/job/outputs.tar.gz" {
+			t.Errorf("unexpected outputs %s", staging.Outputs)
+		}
+	})
+
+	t.Run("sync", func(t *testing.T) {
+		s3API := &fakeS3{buckets: map[string]bool{"my-bucket": true}, uploaded: map[string]string{}}
+		staging, err := artifacts.NewWatcher(s3API, fakePresign{}, fakeSTS{}).Stage(context.Background(), "us-west-2", "team", "job", artifacts.Options{Inputs: []string{input}, Outputs: true, Mode: artifacts.ModeSync, Bucket: "my-bucket"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(staging.Download, "aws s3 sync --only-show-errors 's3://my-bucket/team/job/inputs/' /var/lib/nimbus/inputs") {
+			t.Errorf("expected the download to sync the inputs, got %s", staging.Download)
+		}
+		if staging.Outputs != "s3://my-bucket/team/job/outputs/" {
+			t.Errorf("unexpected outputs %s", staging.Outputs)
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		s3API := &fakeS3{buckets: map[string]bool{}, uploaded: map[string]string{}}
+		if _, err := artifacts.NewWatcher(s3API, fakePresign{}, fakeSTS{}).Stage(context.Background(), "us-west-2", "team", "job", artifacts.Options{Mode: "copy"}); err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+}
+
+func TestEnsureBucket(t *testing.T) {
+	s3API := &fakeS3{buckets: map[string]bool{}, uploaded: map[string]string{}}
+	watcher := artifacts.NewWatcher(s3API, fakePresign{}, fakeSTS{})
+	for _, expectedCreated := range []bool{true, false} {
+		bucket, created, err := watcher.EnsureBucket(context.Background(), "us-west-2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if bucket != "nimbus-artifacts-123456789012-us-west-2" || created != expectedCreated {
+			t.Errorf("expected created to be %t, got %s and %t", expectedCreated, bucket, created)
+		}
+	}
+
+	s3API.uploaded["team/job/inputs/input.txt"] = "hello"
+	if _, _, err := watcher.DeleteBucket(context.Background(), "us-west-2"); !artifacts.IsBucketNotEmpty(err) {
+		t.Errorf("expected a bucket with artifacts not to be deleted, got %v", err)
+	}
+	s3API.uploaded = map[string]string{}
+	for _, expectedDeleted := range []bool{true, false} {
+		_, deleted, err := watcher.DeleteBucket(context.Background(), "us-west-2")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if deleted != expectedDeleted {
+			t.Errorf("expected deleted to be %t, got %t", expectedDeleted, deleted)
+		}
+	}
+}
@@ -0,0 +1,297 @@
+// Package controller runs nimbus as a long-running daemon that keeps the launch plans of a directory or S3 prefix launched.
+//
+// Every manifest is a launch plan, e.g. one saved with nimbus launch --plan-out, whose status is ignored. Each reconciliation
+// resolves the manifests' specs like a dry-run launch, and launches the ones that are missing instances or whose spec changed.
+// Replacements are held until the namespace is within one of its maintenance windows, and VMs whose TTL expired are deleted.
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/maintenance"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/locks"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/samber/lo"
+)
+
+const (
+	// DefaultInterval is how often the manifests are reconciled
+	DefaultInterval = 5 * time.Minute
+
+	// ActionExpire deletes the VM of a manifest whose TTL expired
+	ActionExpire = "expire"
+	// ActionExpired does nothing since the VM of the manifest was deleted when its TTL expired, it is launched again once its spec changes
+	ActionExpired = "expired"
+	// ActionDefer holds a replacement until the namespace is within one of its maintenance windows
+	ActionDefer = "defer"
+	// ActionFailed is a manifest that could not be reconciled, it is retried at the next reconciliation
+	ActionFailed = "failed"
+	// ActionLocked skips a manifest whose VM is locked by a nimbus command, it is retried at the next reconciliation
+	ActionLocked = "locked"
+)
+
+// Options configure how the controller reconciles its manifests
+type Options struct {
+	// Namespace is the namespace of manifests that do not set their own
+	Namespace string
+	// Interval is how often the manifests are reconciled, defaults to DefaultInterval
+	Interval time.Duration
+	// Events reconciles as soon as instances of the manifests' namespaces are interrupted or change state, in addition to every Interval
+	Events bool
+	// Maintenance gates replacements to the maintenance windows of the manifests' namespaces
+	Maintenance maintenance.Config
+}
+
+// Result is what a reconciliation did for a manifest
+type Result struct {
+	Manifest  string `table:"Manifest"`
+	Namespace string `table:"Namespace"`
+	Name      string `table:"Name"`
+	// Action is create, scale, update, replace, or none like a launch, or expire, expired, defer, locked, or failed
+	Action    string `table:"Action,status"`
+	Instances int    `table:"Instances"`
+	Reason    string `table:"Reason"`
+}
+
+// Controller reconciles the manifests of a source
+type Controller struct {
+	vmClient vm.VMI
+	source   Source
+	opts     Options
+	// expired are the spec checksums of the manifests whose VM was deleted when their TTL expired by namespace/name
+	expired map[string]string
+	// watched are the namespaces whose events trigger reconciliations
+	watched map[string]bool
+}
+
+// New creates a controller of the source's manifests
+func New(vmClient vm.VMI, source Source, opts Options) *Controller {
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultInterval
+	}
+	return &Controller{
+		vmClient: vmClient,
+		source:   source,
+		opts:     opts,
+		expired:  map[string]string{},
+		watched:  map[string]bool{},
+	}
+}
+
+// Run reconciles the manifests every interval, and on the events of their namespaces if enabled, until ctx is done
+func (c *Controller) Run(ctx context.Context) error {
+	if err := c.opts.Maintenance.Validate(); err != nil {
+		return err
+	}
+	trigger := make(chan struct{}, 1)
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+	for {
+		results, err := c.Reconcile(ctx)
+		if err != nil {
+			logging.FromContext(ctx).Error("Unable to read manifests", "error", err)
+		}
+		for _, result := range results {
+			logResult(ctx, result)
+		}
+		if c.opts.Events {
+			c.watchNamespaces(ctx, results, trigger)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		case <-trigger:
+			logging.FromContext(ctx).Debug("Reconciling after an instance event")
+		}
+	}
+}
+
+// Reconcile reconciles every manifest of the source once. A manifest that fails does not stop the others, its result records the error.
+func (c *Controller) Reconcile(ctx context.Context) ([]Result, error) {
+	manifests, err := c.source.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	seen := map[string]string{}
+	results := make([]Result, 0, len(manifests))
+	for _, manifest := range manifests {
+		launchPlan := plans.LaunchPlan{Metadata: manifest.Plan.Metadata, Spec: manifest.Plan.Spec}
+		launchPlan.Metadata.Namespace = lo.CoalesceOrEmpty(launchPlan.Metadata.Namespace, c.opts.Namespace)
+		result := Result{Manifest: manifest.Path, Namespace: launchPlan.Metadata.Namespace, Name: launchPlan.Metadata.Name}
+		key := fmt.Sprintf("%s/%s", result.Namespace, result.Name)
+		switch {
+		case result.Name == "":
+			result.Action, result.Reason = ActionFailed, "the manifest has no name"
+		case seen[key] != "":
+			result.Action, result.Reason = ActionFailed, fmt.Sprintf("%s is also launched by %s", key, seen[key])
+		default:
+			seen[key] = manifest.Path
+			result = c.reconcile(ctx, launchPlan, result, now)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// reconcile converges the VM of the launch plan to its spec, or deletes it if its TTL expired
+func (c *Controller) reconcile(ctx context.Context, launchPlan plans.LaunchPlan, result Result, now time.Time) Result {
+	key := fmt.Sprintf("%s/%s", result.Namespace, result.Name)
+	checksum := launchPlan.Spec.Checksum()
+	if c.expired[key] == checksum {
+		result.Action, result.Reason = ActionExpired, "the TTL expired, change the spec to launch it again"
+		return result
+	}
+	delete(c.expired, key)
+
+	if launchPlan.Spec.TTL > 0 {
+		instanceList, err := c.vmClient.List(ctx, result.Namespace, result.Name)
+		if err != nil {
+			return failed(result, err)
+		}
+		if expiredAt, ok := expiredAt(instanceList, now); ok {
+			err := c.locked(ctx, result.Namespace, result.Name, "expire", func() error {
+				return c.expire(ctx, result.Namespace, result.Name)
+			})
+			if locks.IsHeld(err) {
+				result.Action, result.Reason = ActionLocked, err.Error()
+				return result
+			}
+			if err != nil {
+				return failed(result, err)
+			}
+			c.expired[key] = checksum
+			result.Action, result.Reason = ActionExpire, fmt.Sprintf("the TTL expired at %s", expiredAt.Format(time.RFC3339))
+			return result
+		}
+	}
+
+	resolved, err := c.vmClient.Launch(ctx, true, launchPlan)
+	if err != nil {
+		return failed(result, err)
+	}
+	result.Action = resolved.Status.Reconciliation.Action
+	result.Instances = len(resolved.Status.Instances)
+	if result.Action == plans.ReconcileNone {
+		return result
+	}
+	if result.Action == plans.ReconcileReplace {
+		status, err := c.opts.Maintenance.Check(result.Namespace, now)
+		if err != nil {
+			return failed(result, err)
+		}
+		if !status.Open {
+			result.Action = ActionDefer
+			result.Reason = lo.Ternary(status.Next.IsZero(), "no maintenance window of the namespace ever opens",
+				fmt.Sprintf("outside of the maintenance windows, the next window opens at %s", status.Next.Format(time.RFC3339)))
+			return result
+		}
+	}
+
+	var launched plans.LaunchPlan
+	err = c.locked(ctx, result.Namespace, result.Name, "controller", func() error {
+		launched, err = c.vmClient.Launch(ctx, false, launchPlan)
+		return err
+	})
+	if locks.IsHeld(err) {
+		result.Action, result.Reason = ActionLocked, err.Error()
+		return result
+	}
+	result.Instances = len(launched.Status.Instances)
+	if err != nil {
+		return failed(result, err)
+	}
+	result.Reason = fmt.Sprintf("plan %s", launched.Metadata.PlanID)
+	return result
+}
+
+// locked runs fn while holding the lock of namespace/name, so the controller does not change a VM that an operator is changing
+func (c *Controller) locked(ctx context.Context, namespace, name, operation string, fn func() error) error {
+	unlock, err := c.vmClient.Lock(ctx, namespace, name, operation, false)
+	if err != nil {
+		return err
+	}
+	return errors.Join(fn(), unlock())
+}
+
+// expire deletes every resource of namespace/name, the shared resources that are still in use are skipped
+func (c *Controller) expire(ctx context.Context, namespace, name string) error {
+	deletionPlan, err := c.vmClient.DeletionPlan(ctx, namespace, name)
+	if err != nil {
+		return err
+	}
+	_, err = c.vmClient.Delete(ctx, deletionPlan)
+	return err
+}
+
+// watchNamespaces starts to watch the events of the namespaces of the results that are not watched yet.
+// Every event sends to trigger without blocking, so events that arrive during a reconciliation trigger a single one after it.
+func (c *Controller) watchNamespaces(ctx context.Context, results []Result, trigger chan<- struct{}) {
+	for _, namespace := range lo.Uniq(lo.Map(results, func(result Result, _ int) string { return result.Namespace })) {
+		if c.watched[namespace] {
+			continue
+		}
+		eventsChan, err := c.vmClient.Events(ctx, namespace, "", "", true)
+		if err != nil {
+			logging.FromContext(ctx).Error("Unable to watch events, the namespace is only reconciled every interval", "namespace", namespace, "error", err)
+			continue
+		}
+		c.watched[namespace] = true
+		go func() {
+			for event := range eventsChan {
+				logging.FromContext(ctx).Debug("Received instance event", "namespace", namespace, "instance", event.InstanceID, "type", event.Type, "detail", event.Detail)
+				select {
+				case trigger <- struct{}{}:
+				default:
+				}
+			}
+		}()
+	}
+}
+
+// expiredAt returns the earliest expiry of the instances that is not after now, terminated instances are included since their expiry
+// is usually why they terminated
+func expiredAt(instanceList []instances.Instance, now time.Time) (time.Time, bool) {
+	var earliest time.Time
+	for _, instance := range instanceList {
+		expiresAt, ok := instance.ExpiresAt()
+		if !ok || expiresAt.After(now) {
+			continue
+		}
+		if earliest.IsZero() || expiresAt.Before(earliest) {
+			earliest = expiresAt
+		}
+	}
+	return earliest, !earliest.IsZero()
+}
+
+// failed records the error of a manifest that could not be reconciled
+func failed(result Result, err error) Result {
+	result.Action, result.Reason = ActionFailed, err.Error()
+	return result
+}
+
+// logResult logs what a reconciliation did for a manifest, manifests that are already reconciled are only logged in verbose mode
+func logResult(ctx context.Context, result Result) {
+	attrs := []any{"manifest", result.Manifest, "namespace", result.Namespace, "name", result.Name, "action", result.Action, "instances", result.Instances}
+	if result.Reason != "" {
+		attrs = append(attrs, "reason", result.Reason)
+	}
+	switch result.Action {
+	case ActionFailed:
+		logging.FromContext(ctx).Error("Unable to reconcile manifest", attrs...)
+	case ActionLocked:
+		logging.FromContext(ctx).Warn("Manifest is locked", attrs...)
+	case plans.ReconcileNone, ActionExpired:
+		logging.FromContext(ctx).Debug("Manifest is reconciled", attrs...)
+	default:
+		logging.FromContext(ctx).Info("Reconciled manifest", attrs...)
+	}
+}
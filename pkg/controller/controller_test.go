@@ -0,0 +1,144 @@
+package controller_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/controller"
+	"github.com/bwagner5/nimbus/pkg/maintenance"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/locks"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/bwagner5/nimbus/pkg/vm"
+)
+
+type fakeSource []controller.Manifest
+
+func (f fakeSource) Manifests(context.Context) ([]controller.Manifest, error) {
+	return f, nil
+}
+
+// fakeVM resolves every plan to the reconciliation action of its name and records what was launched and deleted
+type fakeVM struct {
+	vm.VMI
+	actions   map[string]string
+	instances map[string][]instances.Instance
+	locked    map[string]bool
+	launched  []string
+	deleted   []string
+}
+
+func (f *fakeVM) Launch(_ context.Context, dryRun bool, launchPlan plans.LaunchPlan) (plans.LaunchPlan, error) {
+	launchPlan.Status.Reconciliation.Action = f.actions[launchPlan.Metadata.Name]
+	if !dryRun {
+		f.launched = append(f.launched, launchPlan.Metadata.Name)
+	}
+	return launchPlan, nil
+}
+
+func (f *fakeVM) List(_ context.Context, _, name string, _ ...ec2types.Filter) ([]instances.Instance, error) {
+	return f.instances[name], nil
+}
+
+func (f *fakeVM) DeletionPlan(_ context.Context, namespace, name string) (plans.DeletionPlan, error) {
+	return plans.DeletionPlan{Metadata: plans.DeletionMetadata{Namespace: namespace, Name: name}}, nil
+}
+
+func (f *fakeVM) Delete(_ context.Context, deletionPlan plans.DeletionPlan) (plans.DeletionPlan, error) {
+	f.deleted = append(f.deleted, deletionPlan.Metadata.Name)
+	return deletionPlan, nil
+}
+
+func (f *fakeVM) Lock(_ context.Context, namespace, name, _ string, _ bool) (func() error, error) {
+	if f.locked[name] {
+		return nil, locks.HeldError{Lock: locks.Lock{Namespace: namespace, Name: name, Holder: "alice@laptop", Operation: "delete"}}
+	}
+	return func() error { return nil }, nil
+}
+
+func manifest(name string, spec plans.LaunchSpec) controller.Manifest {
+	return controller.Manifest{Path: name + ".yaml", Plan: plans.LaunchPlan{Metadata: plans.LaunchMetadata{Name: name}, Spec: spec}}
+}
+
+func expiringInstance(expiresAt time.Time) instances.Instance {
+	return instances.Instance{Instance: ec2types.Instance{
+		Tags: tagutils.MapToEC2Tags(map[string]string{tagutils.ExpiresAtTagKey: expiresAt.UTC().Format(time.RFC3339)}),
+	}}
+}
+
+func TestReconcile(t *testing.T) {
+	fake := &fakeVM{
+		actions: map[string]string{
+			"synced":   plans.ReconcileNone,
+			"missing":  plans.ReconcileScale,
+			"changed":  plans.ReconcileReplace,
+			"ttl":      plans.ReconcileNone,
+			"expiring": plans.ReconcileScale,
+			"locked":   plans.ReconcileScale,
+		},
+		locked: map[string]bool{"locked": true},
+		instances: map[string][]instances.Instance{
+			"ttl":      {expiringInstance(time.Now().Add(time.Hour))},
+			"expiring": {expiringInstance(time.Now().Add(-time.Minute))},
+		},
+	}
+	source := fakeSource{
+		manifest("synced", plans.LaunchSpec{}),
+		manifest("missing", plans.LaunchSpec{}),
+		manifest("changed", plans.LaunchSpec{Replace: true}),
+		manifest("ttl", plans.LaunchSpec{TTL: 2 * time.Hour}),
+		manifest("expiring", plans.LaunchSpec{TTL: time.Hour}),
+		manifest("", plans.LaunchSpec{}),
+		manifest("missing", plans.LaunchSpec{}),
+		manifest("locked", plans.LaunchSpec{}),
+	}
+	c := controller.New(fake, source, controller.Options{
+		Namespace: "dev",
+		// a window that never opens holds every replacement
+		Maintenance: maintenance.Config{Windows: []maintenance.Window{{Schedule: "0 0 30 2 *", Duration: time.Hour}}},
+	})
+
+	results, err := c.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []string{plans.ReconcileNone, plans.ReconcileScale, controller.ActionDefer, plans.ReconcileNone, controller.ActionExpire, controller.ActionFailed, controller.ActionFailed, controller.ActionLocked}
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+	for i, result := range results {
+		if result.Action != expected[i] {
+			t.Errorf("%s: expected action %s, got %s (%s)", result.Manifest, expected[i], result.Action, result.Reason)
+		}
+		if result.Namespace != "dev" {
+			t.Errorf("%s: expected the default namespace dev, got %q", result.Manifest, result.Namespace)
+		}
+	}
+	if len(fake.launched) != 1 || fake.launched[0] != "missing" {
+		t.Errorf("expected only missing to be launched, got %v", fake.launched)
+	}
+	if len(fake.deleted) != 1 || fake.deleted[0] != "expiring" {
+		t.Errorf("expected only expiring to be deleted, got %v", fake.deleted)
+	}
+
+	// the expired VM is not launched again until its spec changes
+	fake.instances["expiring"] = nil
+	results, err = c.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[4].Action != controller.ActionExpired {
+		t.Errorf("expected the expired manifest to stay expired, got %s", results[4].Action)
+	}
+	source[4].Plan.Spec.TTL = 2 * time.Hour
+	results, err = c.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if results[4].Action != plans.ReconcileScale {
+		t.Errorf("expected the changed manifest to be launched again, got %s", results[4].Action)
+	}
+}
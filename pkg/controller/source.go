@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bwagner5/nimbus/pkg/plans"
+)
+
+// s3Scheme is the prefix of manifest sources in S3, e.g. s3://bucket/manifests/
+const s3Scheme = "s3://"
+
+// manifestExtensions are the file extensions of manifests, other files of a source are ignored
+var manifestExtensions = []string{".yaml", ".yml", ".json"}
+
+// Manifest is a launch plan that the controller keeps launched
+type Manifest struct {
+	// Path is the file or S3 URI that the manifest was read from
+	Path string
+	Plan plans.LaunchPlan
+}
+
+// Source lists the manifests that the controller reconciles
+type Source interface {
+	Manifests(ctx context.Context) ([]Manifest, error)
+}
+
+// SDKS3Ops is an interface that combines the necessary S3 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKS3Ops interface {
+	s3.ListObjectsV2APIClient
+	manager.DownloadAPIClient
+}
+
+// DirSource reads the manifests of a directory, subdirectories are not read
+type DirSource struct {
+	Dir string
+}
+
+// S3Source reads the manifests of the objects under a prefix of a bucket
+type S3Source struct {
+	s3API  SDKS3Ops
+	Bucket string
+	Prefix string
+}
+
+// NewSource returns the source of a directory or of an S3 prefix written as s3://bucket/prefix
+func NewSource(uri string, s3API SDKS3Ops) (Source, error) {
+	if !strings.HasPrefix(uri, s3Scheme) {
+		return DirSource{Dir: uri}, nil
+	}
+	bucket, prefix, _ := strings.Cut(strings.TrimPrefix(uri, s3Scheme), "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("manifest source %q has no bucket", uri)
+	}
+	return S3Source{s3API: s3API, Bucket: bucket, Prefix: prefix}, nil
+}
+
+// Manifests reads the YAML and JSON manifests of the directory in lexical order
+func (s DirSource) Manifests(_ context.Context) ([]Manifest, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest directory: %w", err)
+	}
+	var manifests []Manifest
+	for _, entry := range entries {
+		if entry.IsDir() || !isManifest(entry.Name()) {
+			continue
+		}
+		manifestPath := filepath.Join(s.Dir, entry.Name())
+		launchPlan, err := plans.LoadLaunchPlan(manifestPath)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, Manifest{Path: manifestPath, Plan: launchPlan})
+	}
+	return manifests, nil
+}
+
+// Manifests reads the YAML and JSON manifests under the prefix in lexical order of their keys
+func (s S3Source) Manifests(ctx context.Context) ([]Manifest, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.s3API, &s3.ListObjectsV2Input{Bucket: aws.String(s.Bucket), Prefix: aws.String(s.Prefix)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list manifests: %w", err)
+		}
+		for _, object := range page.Contents {
+			if isManifest(aws.ToString(object.Key)) {
+				keys = append(keys, aws.ToString(object.Key))
+			}
+		}
+	}
+	slices.Sort(keys)
+	downloader := manager.NewDownloader(s.s3API)
+	manifests := make([]Manifest, 0, len(keys))
+	for _, key := range keys {
+		uri := fmt.Sprintf("%s%s/%s", s3Scheme, s.Bucket, key)
+		object := manager.NewWriteAtBuffer(nil)
+		if _, err := downloader.Download(ctx, object, &s3.GetObjectInput{Bucket: aws.String(s.Bucket), Key: aws.String(key)}); err != nil {
+			return nil, fmt.Errorf("failed to get manifest %s: %w", uri, err)
+		}
+		launchPlan, err := plans.DecodeLaunchPlan(object.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("unable to read plan %s: %w", uri, err)
+		}
+		manifests = append(manifests, Manifest{Path: uri, Plan: launchPlan})
+	}
+	return manifests, nil
+}
+
+// isManifest returns true if the file name has the extension of a manifest
+func isManifest(name string) bool {
+	return slices.Contains(manifestExtensions, strings.ToLower(path.Ext(name)))
+}
@@ -0,0 +1,92 @@
+package controller_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/bwagner5/nimbus/pkg/controller"
+)
+
+const manifestYAML = `Metadata:
+  Name: web
+Spec:
+  Count: 2
+`
+
+type fakeS3 struct {
+	objects map[string]string
+}
+
+func (f fakeS3) ListObjectsV2(_ context.Context, input *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	// every object is on its own page to exercise the pagination
+	var keys []string
+	for key := range f.objects {
+		if key > aws.ToString(input.ContinuationToken) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return &s3.ListObjectsV2Output{}, nil
+	}
+	first := keys[0]
+	for _, key := range keys {
+		first = min(first, key)
+	}
+	return &s3.ListObjectsV2Output{Contents: []s3types.Object{{Key: aws.String(first)}}, IsTruncated: aws.Bool(len(keys) > 1), NextContinuationToken: aws.String(first)}, nil
+}
+
+func (f fakeS3) GetObject(_ context.Context, input *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	object := f.objects[aws.ToString(input.Key)]
+	return &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(object)), ContentLength: aws.Int64(int64(len(object)))}, nil
+}
+
+func TestDirSource(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"web.yaml": manifestYAML, "README.md": "not a manifest"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	source, err := controller.NewSource(dir, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manifests, err := source.Manifests(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifests) != 1 || manifests[0].Plan.Metadata.Name != "web" || manifests[0].Plan.Spec.Count != 2 {
+		t.Errorf("expected the web manifest, got %+v", manifests)
+	}
+}
+
+func TestS3Source(t *testing.T) {
+	if _, err := controller.NewSource("s3:///manifests", nil); err == nil {
+		t.Error("expected an error for a source without a bucket")
+	}
+	source, err := controller.NewSource("s3://bucket/manifests/", fakeS3{objects: map[string]string{
+		"manifests/web.yaml":  manifestYAML,
+		"manifests/api.json":  `{"Metadata": {"Name": "api"}}`,
+		"manifests/notes.txt": "not a manifest",
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	manifests, err := source.Manifests(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifests) != 2 {
+		t.Fatalf("expected 2 manifests, got %d", len(manifests))
+	}
+	if manifests[0].Path != "s3://bucket/manifests/api.json" || manifests[1].Plan.Metadata.Name != "web" {
+		t.Errorf("expected the api and web manifests in order, got %s and %s", manifests[0].Path, manifests[1].Plan.Metadata.Name)
+	}
+}
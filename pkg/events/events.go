@@ -0,0 +1,289 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	eventbridgetypes "github.com/aws/aws-sdk-go-v2/service/eventbridge/types"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/aws/smithy-go"
+	"github.com/bwagner5/nimbus/pkg/readonly"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// Event types are the kinds of EC2 events that are captured
+	EventSpotInterruption        = "SpotInterruption"
+	EventRebalanceRecommendation = "RebalanceRecommendation"
+	EventStateChange             = "StateChange"
+
+	// queueTargetID is the ID of the queue in the targets of the rule
+	queueTargetID = "nimbus-events"
+	// messageRetention is how long captured events are kept in the queue until they are received
+	messageRetention = 24 * time.Hour
+	// maxMessages is the maximum number of messages an SQS ReceiveMessage call returns
+	maxMessages = 10
+)
+
+// detailTypes maps the EventBridge detail-type of the captured EC2 events to their event type
+var detailTypes = map[string]string{
+	"EC2 Spot Instance Interruption Warning": EventSpotInterruption,
+	"EC2 Instance Rebalance Recommendation":  EventRebalanceRecommendation,
+	"EC2 Instance State-change Notification": EventStateChange,
+}
+
+// invalidNameChars are the characters that are not allowed in EventBridge rule and SQS queue names
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+
+// Watcher captures the EC2 events of a namespace with an EventBridge rule that forwards them to an SQS queue
+type Watcher struct {
+	eventBridgeAPI SDKEventBridgeOps
+	sqsAPI         SDKSQSOps
+}
+
+// SDKEventBridgeOps is an interface that combines the necessary EventBridge SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKEventBridgeOps interface {
+	PutRule(context.Context, *eventbridge.PutRuleInput, ...func(*eventbridge.Options)) (*eventbridge.PutRuleOutput, error)
+	PutTargets(context.Context, *eventbridge.PutTargetsInput, ...func(*eventbridge.Options)) (*eventbridge.PutTargetsOutput, error)
+	RemoveTargets(context.Context, *eventbridge.RemoveTargetsInput, ...func(*eventbridge.Options)) (*eventbridge.RemoveTargetsOutput, error)
+	DeleteRule(context.Context, *eventbridge.DeleteRuleInput, ...func(*eventbridge.Options)) (*eventbridge.DeleteRuleOutput, error)
+}
+
+// SDKSQSOps is an interface that combines the necessary SQS SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKSQSOps interface {
+	CreateQueue(context.Context, *sqs.CreateQueueInput, ...func(*sqs.Options)) (*sqs.CreateQueueOutput, error)
+	GetQueueUrl(context.Context, *sqs.GetQueueUrlInput, ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error)
+	GetQueueAttributes(context.Context, *sqs.GetQueueAttributesInput, ...func(*sqs.Options)) (*sqs.GetQueueAttributesOutput, error)
+	SetQueueAttributes(context.Context, *sqs.SetQueueAttributesInput, ...func(*sqs.Options)) (*sqs.SetQueueAttributesOutput, error)
+	ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+	DeleteQueue(context.Context, *sqs.DeleteQueueInput, ...func(*sqs.Options)) (*sqs.DeleteQueueOutput, error)
+}
+
+// Event is an EC2 event of an instance
+type Event struct {
+	Time       time.Time `table:"Time"`
+	Type       string    `table:"Type"`
+	InstanceID string    `table:"Instance ID"`
+	// Detail is the interruption action of spot interruptions and the new state of state changes
+	Detail string `table:"Detail"`
+	// PlanID is the ID of the executed launch plan that launched the instance
+	PlanID string `table:"Plan ID,wide"`
+}
+
+// Queue is the SQS queue that the namespace's EC2 events are forwarded to
+type Queue struct {
+	URL string
+	Arn string
+}
+
+// NewWatcher creates a new Event Watcher
+func NewWatcher(eventBridgeAPI SDKEventBridgeOps, sqsAPI SDKSQSOps) Watcher {
+	return Watcher{
+		eventBridgeAPI: eventBridgeAPI,
+		sqsAPI:         sqsAPI,
+	}
+}
+
+// Ensure creates the namespace's queue and the rule that forwards EC2 events to it, or updates them if they exist.
+// EventBridge can not filter events by tags, so the rule forwards the events of every instance in the region.
+func (w Watcher) Ensure(ctx context.Context, namespace string) (Queue, error) {
+	name := Name(namespace)
+	tags := tagutils.NamespacedTags(namespace, "")
+	queueOut, err := w.sqsAPI.CreateQueue(ctx, &sqs.CreateQueueInput{
+		QueueName:  aws.String(name),
+		Attributes: map[string]string{string(sqstypes.QueueAttributeNameMessageRetentionPeriod): fmt.Sprint(int(messageRetention.Seconds()))},
+		Tags:       tags,
+	})
+	if err != nil {
+		return Queue{}, fmt.Errorf("failed to create event queue %s: %w", name, err)
+	}
+	queue, err := w.queue(ctx, name, lo.FromPtr(queueOut.QueueUrl))
+	if err != nil {
+		return queue, err
+	}
+
+	eventPattern, err := json.Marshal(map[string]any{
+		"source":      []string{"aws.ec2"},
+		"detail-type": slices.Sorted(maps.Keys(detailTypes)),
+	})
+	if err != nil {
+		return queue, err
+	}
+	ruleOut, err := w.eventBridgeAPI.PutRule(ctx, &eventbridge.PutRuleInput{
+		Name:         aws.String(name),
+		Description:  aws.String(fmt.Sprintf("Forwards EC2 events to the nimbus event queue of namespace %s", namespace)),
+		EventPattern: aws.String(string(eventPattern)),
+		State:        eventbridgetypes.RuleStateEnabled,
+		Tags: lo.MapToSlice(tags, func(key, value string) eventbridgetypes.Tag {
+			return eventbridgetypes.Tag{Key: aws.String(key), Value: aws.String(value)}
+		}),
+	})
+	if err != nil {
+		return queue, fmt.Errorf("failed to create event rule %s: %w", name, err)
+	}
+
+	policy, err := queuePolicy(queue.Arn, lo.FromPtr(ruleOut.RuleArn))
+	if err != nil {
+		return queue, err
+	}
+	if _, err := w.sqsAPI.SetQueueAttributes(ctx, &sqs.SetQueueAttributesInput{
+		QueueUrl:   aws.String(queue.URL),
+		Attributes: map[string]string{string(sqstypes.QueueAttributeNamePolicy): policy},
+	}); err != nil {
+		return queue, fmt.Errorf("failed to allow event rule %s to send to event queue: %w", name, err)
+	}
+	targetsOut, err := w.eventBridgeAPI.PutTargets(ctx, &eventbridge.PutTargetsInput{
+		Rule:    aws.String(name),
+		Targets: []eventbridgetypes.Target{{Id: aws.String(queueTargetID), Arn: aws.String(queue.Arn)}},
+	})
+	if err != nil {
+		return queue, fmt.Errorf("failed to add event queue to event rule %s: %w", name, err)
+	}
+	if targetsOut.FailedEntryCount != 0 {
+		return queue, fmt.Errorf("failed to add event queue to event rule %s: %s", name, lo.FromPtr(targetsOut.FailedEntries[0].ErrorMessage))
+	}
+	return queue, nil
+}
+
+// Resolve returns the namespace's existing queue without creating or updating it, e.g. to receive events in read-only mode
+func (w Watcher) Resolve(ctx context.Context, namespace string) (Queue, error) {
+	name := Name(namespace)
+	out, err := w.sqsAPI.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if err != nil {
+		return Queue{}, fmt.Errorf("failed to get event queue %s: %w", name, err)
+	}
+	return w.queue(ctx, name, lo.FromPtr(out.QueueUrl))
+}
+
+// queue returns the queue with the URL along with its ARN
+func (w Watcher) queue(ctx context.Context, name, url string) (Queue, error) {
+	queue := Queue{URL: url}
+	out, err := w.sqsAPI.GetQueueAttributes(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       aws.String(url),
+		AttributeNames: []sqstypes.QueueAttributeName{sqstypes.QueueAttributeNameQueueArn},
+	})
+	if err != nil {
+		return queue, fmt.Errorf("failed to get event queue %s: %w", name, err)
+	}
+	queue.Arn = out.Attributes[string(sqstypes.QueueAttributeNameQueueArn)]
+	return queue, nil
+}
+
+// Receive waits up to wait for events to arrive in the queue and returns them, deleting their messages from the queue.
+// Messages that are not EC2 events of the captured types are deleted without being returned.
+// In read-only mode the messages are left in the queue and are received again once their visibility timeout expires.
+func (w Watcher) Receive(ctx context.Context, queue Queue, wait time.Duration) ([]Event, error) {
+	out, err := w.sqsAPI.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+		QueueUrl:            aws.String(queue.URL),
+		MaxNumberOfMessages: maxMessages,
+		WaitTimeSeconds:     int32(wait.Seconds()),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive events: %w", err)
+	}
+	var events []Event
+	for _, message := range out.Messages {
+		if event, err := ParseEvent(lo.FromPtr(message.Body)); err == nil {
+			events = append(events, event)
+		}
+		if _, err := w.sqsAPI.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+			QueueUrl:      aws.String(queue.URL),
+			ReceiptHandle: message.ReceiptHandle,
+		}); err != nil && !errors.Is(err, readonly.ErrReadOnly) {
+			return events, fmt.Errorf("failed to delete event message %s: %w", lo.FromPtr(message.MessageId), err)
+		}
+	}
+	return events, nil
+}
+
+// Delete deletes the namespace's event rule and queue, along with the events that were not received yet
+func (w Watcher) Delete(ctx context.Context, namespace string) error {
+	name := Name(namespace)
+	if _, err := w.eventBridgeAPI.RemoveTargets(ctx, &eventbridge.RemoveTargetsInput{Rule: aws.String(name), Ids: []string{queueTargetID}}); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to remove the targets of event rule %s: %w", name, err)
+	}
+	if _, err := w.eventBridgeAPI.DeleteRule(ctx, &eventbridge.DeleteRuleInput{Name: aws.String(name)}); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete event rule %s: %w", name, err)
+	}
+	queueOut, err := w.sqsAPI.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(name)})
+	if IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get event queue %s: %w", name, err)
+	}
+	if _, err := w.sqsAPI.DeleteQueue(ctx, &sqs.DeleteQueueInput{QueueUrl: queueOut.QueueUrl}); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete event queue %s: %w", name, err)
+	}
+	return nil
+}
+
+// IsNotFound returns true if the error is a missing rule or queue error
+func IsNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && slices.Contains([]string{
+		"ResourceNotFoundException",
+		"QueueDoesNotExist",
+		"AWS.SimpleQueueService.NonExistentQueue",
+	}, apiErr.ErrorCode())
+}
+
+// ParseEvent parses an EventBridge EC2 event, other events are an error
+func ParseEvent(body string) (Event, error) {
+	var envelope struct {
+		DetailType string    `json:"detail-type"`
+		Time       time.Time `json:"time"`
+		Detail     struct {
+			InstanceID     string `json:"instance-id"`
+			InstanceAction string `json:"instance-action"`
+			State          string `json:"state"`
+		} `json:"detail"`
+	}
+	if err := json.Unmarshal([]byte(body), &envelope); err != nil {
+		return Event{}, fmt.Errorf("failed to parse event: %w", err)
+	}
+	eventType, ok := detailTypes[envelope.DetailType]
+	if !ok || envelope.Detail.InstanceID == "" {
+		return Event{}, fmt.Errorf("unsupported event %q", envelope.DetailType)
+	}
+	return Event{
+		Time:       envelope.Time,
+		Type:       eventType,
+		InstanceID: envelope.Detail.InstanceID,
+		Detail:     lo.CoalesceOrEmpty(envelope.Detail.InstanceAction, envelope.Detail.State),
+	}, nil
+}
+
+// Name returns the name of the namespace's event rule and queue
+func Name(namespace string) string {
+	return lo.Substring(invalidNameChars.ReplaceAllString(fmt.Sprintf("nimbus-%s-events", namespace), "-"), 0, 64)
+}
+
+// queuePolicy returns the queue policy that allows only the rule to send events to the queue
+func queuePolicy(queueArn, ruleArn string) (string, error) {
+	policy, err := json.Marshal(map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Sid":       "EventBridge",
+			"Effect":    "Allow",
+			"Principal": map[string]string{"Service": "events.amazonaws.com"},
+			"Action":    "sqs:SendMessage",
+			"Resource":  queueArn,
+			"Condition": map[string]any{"ArnEquals": map[string]string{"aws:SourceArn": ruleArn}},
+		}},
+	})
+	return string(policy), err
+}
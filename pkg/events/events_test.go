@@ -0,0 +1,136 @@
+package events_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/bwagner5/nimbus/pkg/events"
+	"github.com/bwagner5/nimbus/pkg/readonly"
+)
+
+func TestParseEvent(t *testing.T) {
+	type testCases struct {
+		name        string
+		body        string
+		expected    events.Event
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name: "spot interruption",
+			body: `{"detail-type":"EC2 Spot Instance Interruption Warning","source":"aws.ec2","time":"2025-01-02T03:04:05Z","detail":{"instance-id":"i-0123456","instance-action":"terminate"}}`,
+			expected: events.Event{
+				Time:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+				Type:       events.EventSpotInterruption,
+				InstanceID: "i-0123456",
+				Detail:     "terminate",
+			},
+		},
+		{
+			name: "rebalance recommendation",
+			body: `{"detail-type":"EC2 Instance Rebalance Recommendation","source":"aws.ec2","time":"2025-01-02T03:04:05Z","detail":{"instance-id":"i-0123456"}}`,
+			expected: events.Event{
+				Time:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+				Type:       events.EventRebalanceRecommendation,
+				InstanceID: "i-0123456",
+			},
+		},
+		{
+			name: "state change",
+			body: `{"detail-type":"EC2 Instance State-change Notification","source":"aws.ec2","time":"2025-01-02T03:04:05Z","detail":{"instance-id":"i-0123456","state":"stopping"}}`,
+			expected: events.Event{
+				Time:       time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+				Type:       events.EventStateChange,
+				InstanceID: "i-0123456",
+				Detail:     "stopping",
+			},
+		},
+		{name: "other event", body: `{"detail-type":"AWS API Call via CloudTrail","detail":{}}`, expectedErr: true},
+		{name: "not an event", body: `hello`, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			event, err := events.ParseEvent(tc.body)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if event != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, event)
+			}
+		})
+	}
+}
+
+func TestName(t *testing.T) {
+	if name := events.Name("team a"); name != "nimbus-team-a-events" {
+		t.Errorf("expected nimbus-team-a-events, got %s", name)
+	}
+	if name := events.Name(strings.Repeat("a", 100)); len(name) != 64 {
+		t.Errorf("expected a name of 64 characters, got %d", len(name))
+	}
+}
+
+type fakeSQS struct {
+	events.SDKSQSOps
+	messages []sqstypes.Message
+	// deleteErr is returned by DeleteMessage
+	deleteErr error
+	deleted   int
+}
+
+func (f *fakeSQS) ReceiveMessage(context.Context, *sqs.ReceiveMessageInput, ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	return &sqs.ReceiveMessageOutput{Messages: f.messages}, nil
+}
+
+func (f *fakeSQS) DeleteMessage(context.Context, *sqs.DeleteMessageInput, ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	if f.deleteErr != nil {
+		return nil, f.deleteErr
+	}
+	f.deleted++
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestReceive(t *testing.T) {
+	messages := []sqstypes.Message{
+		{MessageId: aws.String("1"), Body: aws.String(`{"detail-type":"EC2 Instance State-change Notification","detail":{"instance-id":"i-0123456","state":"stopping"}}`)},
+		{MessageId: aws.String("2"), Body: aws.String(`hello`)},
+	}
+	type testCases struct {
+		name            string
+		deleteErr       error
+		expectedDeleted int
+		expectedErr     bool
+	}
+
+	for _, tc := range []testCases{
+		{name: "deletes received messages", expectedDeleted: 2},
+		{name: "read-only mode leaves messages in the queue", deleteErr: fmt.Errorf("%w: SQS DeleteMessage is not allowed", readonly.ErrReadOnly)},
+		{name: "delete failures are returned", deleteErr: fmt.Errorf("access denied"), expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sqsAPI := &fakeSQS{messages: messages, deleteErr: tc.deleteErr}
+			received, err := events.NewWatcher(nil, sqsAPI).Receive(context.Background(), events.Queue{URL: "https://sqs"}, time.Second)
+			if (err != nil) != tc.expectedErr {
+				t.Fatalf("expected error to be %t, got %v", tc.expectedErr, err)
+			}
+			if len(received) != 1 || received[0].InstanceID != "i-0123456" {
+				t.Errorf("expected the state change of i-0123456, got %+v", received)
+			}
+			if sqsAPI.deleted != tc.expectedDeleted {
+				t.Errorf("expected %d deleted messages, got %d", tc.expectedDeleted, sqsAPI.deleted)
+			}
+		})
+	}
+}
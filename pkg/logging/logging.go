@@ -27,8 +27,13 @@ func NoOpLogger() *slog.Logger {
 	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{}))
 }
 
+// FromContext returns the logger stored in the context or a no-op logger if there is none
 func FromContext(ctx context.Context) *slog.Logger {
-	return ctx.Value(loggingCtxKey{}).(*slog.Logger)
+	logger, ok := ctx.Value(loggingCtxKey{}).(*slog.Logger)
+	if !ok {
+		return NoOpLogger()
+	}
+	return logger
 }
 
 func ToContext(ctx context.Context, logger *slog.Logger) context.Context {
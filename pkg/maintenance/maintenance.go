@@ -0,0 +1,239 @@
+package maintenance
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// OutsideRefuse refuses disruptive commands outside of the maintenance windows
+	OutsideRefuse = "refuse"
+	// OutsideQueue makes disruptive commands outside of the maintenance windows wait for the next window to open
+	OutsideQueue = "queue"
+
+	// maxSearch is how far ahead the next start of a schedule is searched, schedules that never match like "0 0 30 2 *" have no next start
+	maxSearch = 5 * 366 * 24 * time.Hour
+)
+
+// Config gates disruptive commands, which replace, restart, or stop running instances, to maintenance windows
+type Config struct {
+	Windows []Window `yaml:"windows,omitempty"`
+	// Outside is what disruptive commands do outside of the windows: refuse or queue (default refuse)
+	Outside string `yaml:"outside,omitempty"`
+}
+
+// Window is a recurring maintenance window that opens at every minute that matches its cron schedule and stays open for its duration
+type Window struct {
+	// Schedule is a cron expression with the fields minute, hour, day of month, month, and day of week. e.g. "0 2 * * sat"
+	Schedule string        `yaml:"schedule"`
+	Duration time.Duration `yaml:"duration"`
+	// Timezone is the IANA time zone that the schedule is evaluated in (default UTC)
+	Timezone string `yaml:"timezone,omitempty"`
+	// Namespaces limits the window to disruptive commands in the namespaces, all namespaces are gated if empty
+	Namespaces []string `yaml:"namespaces,omitempty"`
+}
+
+// Status is whether a namespace is within one of its maintenance windows
+type Status struct {
+	// Open is true within a window, or if no window gates the namespace
+	Open bool
+	// Gated is true if at least one window gates the namespace
+	Gated bool
+	// Next is when the next window opens if it is not open, zero if no window ever opens
+	Next time.Time
+}
+
+// Validate checks that the windows' schedules, durations, and time zones and the outside policy are valid
+func (c Config) Validate() error {
+	if c.Outside != "" && c.Outside != OutsideRefuse && c.Outside != OutsideQueue {
+		return fmt.Errorf("maintenance outside policy must be %s or %s, got %q", OutsideRefuse, OutsideQueue, c.Outside)
+	}
+	for _, window := range c.Windows {
+		if _, _, err := window.parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Check returns whether the namespace is within one of its maintenance windows at now, and when its next window opens if not
+func (c Config) Check(namespace string, now time.Time) (Status, error) {
+	if err := c.Validate(); err != nil {
+		return Status{}, err
+	}
+	status := Status{Open: true}
+	for _, window := range c.Windows {
+		if len(window.Namespaces) != 0 && !slices.Contains(window.Namespaces, namespace) {
+			continue
+		}
+		if !status.Gated {
+			status = Status{Gated: true}
+		}
+		schedule, location, _ := window.parse()
+		localNow := now.In(location)
+		// the window is open if it started within its duration before now
+		if start := schedule.Next(localNow.Add(-window.Duration)); !start.IsZero() && !start.After(localNow) {
+			return Status{Open: true, Gated: true}, nil
+		}
+		if next := schedule.Next(localNow); !next.IsZero() && (status.Next.IsZero() || next.Before(status.Next)) {
+			status.Next = next
+		}
+	}
+	return status, nil
+}
+
+// parse parses the window's schedule and time zone
+func (w Window) parse() (Schedule, *time.Location, error) {
+	schedule, err := ParseSchedule(w.Schedule)
+	if err != nil {
+		return Schedule{}, nil, err
+	}
+	if w.Duration <= 0 {
+		return Schedule{}, nil, fmt.Errorf("maintenance window %q must have a positive duration", w.Schedule)
+	}
+	location, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		return Schedule{}, nil, fmt.Errorf("invalid time zone %q of maintenance window %q: %w", w.Timezone, w.Schedule, err)
+	}
+	return schedule, location, nil
+}
+
+// Schedule is a parsed cron expression, every field is a bit set of the values it matches
+type Schedule struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek uint64
+	// anyDayOfMonth and anyDayOfWeek are true if the fields are *, cron matches a day if either day field matches when both are restricted
+	anyDayOfMonth, anyDayOfWeek bool
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteField     = field{name: "minute", min: 0, max: 59}
+	hourField       = field{name: "hour", min: 0, max: 23}
+	dayOfMonthField = field{name: "day of month", min: 1, max: 31}
+	monthField      = field{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	// day of week 7 is also sunday
+	dayOfWeekField = field{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+// ParseSchedule parses a cron expression with the fields minute, hour, day of month, month, and day of week.
+// Fields are *, values, ranges, or lists of them with optional steps, e.g. "*/15 9-17 * * mon-fri" or "0 2 1,15 * *".
+// Months and days of week can also be their three letter names.
+func ParseSchedule(expr string) (Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return Schedule{}, fmt.Errorf("cron expression %q must have 5 fields: minute hour day-of-month month day-of-week", expr)
+	}
+	var schedule Schedule
+	var err error
+	for i, target := range []struct {
+		field field
+		bits  *uint64
+	}{
+		{minuteField, &schedule.minutes},
+		{hourField, &schedule.hours},
+		{dayOfMonthField, &schedule.daysOfMonth},
+		{monthField, &schedule.months},
+		{dayOfWeekField, &schedule.daysOfWeek},
+	} {
+		if *target.bits, err = target.field.parse(fields[i]); err != nil {
+			return Schedule{}, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+		}
+	}
+	// sunday is 0 and 7
+	if schedule.daysOfWeek&(1<<7) != 0 {
+		schedule.daysOfWeek |= 1
+	}
+	schedule.anyDayOfMonth = fields[2] == "*"
+	schedule.anyDayOfWeek = fields[4] == "*"
+	return schedule, nil
+}
+
+// parse parses a field of a cron expression into the bit set of the values it matches
+func (f field) parse(value string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(value, ",") {
+		rangeExpr, stepExpr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepExpr); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q of %s", stepExpr, f.name)
+			}
+		}
+		start, end := f.min, f.max
+		if rangeExpr != "*" {
+			startExpr, endExpr, isRange := strings.Cut(rangeExpr, "-")
+			var err error
+			if start, err = f.value(startExpr); err != nil {
+				return 0, err
+			}
+			end = start
+			if isRange {
+				if end, err = f.value(endExpr); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// a value with a step like 5/15 starts at the value
+				end = f.max
+			}
+			if start > end {
+				return 0, fmt.Errorf("invalid range %q of %s", rangeExpr, f.name)
+			}
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name of the field
+func (f field) value(expr string) (int, error) {
+	if i := slices.Index(f.names, strings.ToLower(expr)); i != -1 {
+		return i + f.min, nil
+	}
+	v, err := strconv.Atoi(expr)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("invalid %s %q, must be %d-%d", f.name, expr, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next returns the first minute after t that matches the schedule in t's location, zero if none does within 5 years
+func (s Schedule) Next(t time.Time) time.Time {
+	next := t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(maxSearch)
+	for next.Before(limit) {
+		switch {
+		case s.months&(1<<int(next.Month())) == 0:
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, next.Location())
+		case !s.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, next.Location())
+		case s.hours&(1<<next.Hour()) == 0:
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour()+1, 0, 0, 0, next.Location())
+		case s.minutes&(1<<next.Minute()) == 0:
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// matchesDay returns whether the day of t matches the day of month and day of week fields
+func (s Schedule) matchesDay(t time.Time) bool {
+	dayOfMonth := s.daysOfMonth&(1<<t.Day()) != 0
+	dayOfWeek := s.daysOfWeek&(1<<int(t.Weekday())) != 0
+	if s.anyDayOfMonth || s.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
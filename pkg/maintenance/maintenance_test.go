@@ -0,0 +1,137 @@
+package maintenance_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/maintenance"
+)
+
+func TestScheduleNext(t *testing.T) {
+	// a wednesday
+	start := time.Date(2024, time.May, 15, 10, 30, 0, 0, time.UTC)
+	testCases := []struct {
+		schedule    string
+		expected    time.Time
+		expectError bool
+	}{
+		{schedule: "* * * * *", expected: time.Date(2024, time.May, 15, 10, 31, 0, 0, time.UTC)},
+		{schedule: "*/15 * * * *", expected: time.Date(2024, time.May, 15, 10, 45, 0, 0, time.UTC)},
+		{schedule: "0 2 * * sat", expected: time.Date(2024, time.May, 18, 2, 0, 0, 0, time.UTC)},
+		{schedule: "0 2 * * 6", expected: time.Date(2024, time.May, 18, 2, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 * * 7", expected: time.Date(2024, time.May, 19, 0, 0, 0, 0, time.UTC)},
+		{schedule: "30 9-17 * * MON-FRI", expected: time.Date(2024, time.May, 15, 11, 30, 0, 0, time.UTC)},
+		{schedule: "0 3 1,15 * *", expected: time.Date(2024, time.June, 1, 3, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 1 jan *", expected: time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		// either day field matches when both are restricted
+		{schedule: "0 0 1 * fri", expected: time.Date(2024, time.May, 17, 0, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 29 2 *", expected: time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{schedule: "0 0 30 2 *", expected: time.Time{}},
+		{schedule: "0 2 * *", expectError: true},
+		{schedule: "60 * * * *", expectError: true},
+		{schedule: "0 5-2 * * *", expectError: true},
+		{schedule: "*/0 * * * *", expectError: true},
+		{schedule: "0 0 * * someday", expectError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.schedule, func(t *testing.T) {
+			schedule, err := maintenance.ParseSchedule(tc.schedule)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if next := schedule.Next(start); !next.Equal(tc.expected) {
+				t.Errorf("expected %s, got %s", tc.expected, next)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	saturdayWindow := maintenance.Window{Schedule: "0 2 * * sat", Duration: 4 * time.Hour}
+	testCases := []struct {
+		name        string
+		config      maintenance.Config
+		namespace   string
+		now         time.Time
+		expected    maintenance.Status
+		expectError bool
+	}{
+		{
+			name:     "no windows",
+			now:      time.Date(2024, time.May, 15, 10, 30, 0, 0, time.UTC),
+			expected: maintenance.Status{Open: true},
+		},
+		{
+			name:     "within a window",
+			config:   maintenance.Config{Windows: []maintenance.Window{saturdayWindow}},
+			now:      time.Date(2024, time.May, 18, 5, 59, 0, 0, time.UTC),
+			expected: maintenance.Status{Open: true, Gated: true},
+		},
+		{
+			name:     "after a window",
+			config:   maintenance.Config{Windows: []maintenance.Window{saturdayWindow}},
+			now:      time.Date(2024, time.May, 18, 6, 0, 0, 0, time.UTC),
+			expected: maintenance.Status{Gated: true, Next: time.Date(2024, time.May, 25, 2, 0, 0, 0, time.UTC)},
+		},
+		{
+			name: "earliest next window",
+			config: maintenance.Config{Windows: []maintenance.Window{
+				saturdayWindow,
+				{Schedule: "0 22 * * thu", Duration: time.Hour},
+			}},
+			now:      time.Date(2024, time.May, 15, 10, 30, 0, 0, time.UTC),
+			expected: maintenance.Status{Gated: true, Next: time.Date(2024, time.May, 16, 22, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:      "window of another namespace",
+			config:    maintenance.Config{Windows: []maintenance.Window{{Schedule: "0 2 * * sat", Duration: time.Hour, Namespaces: []string{"prod"}}}},
+			namespace: "dev",
+			now:       time.Date(2024, time.May, 15, 10, 30, 0, 0, time.UTC),
+			expected:  maintenance.Status{Open: true},
+		},
+		{
+			name:     "time zone",
+			config:   maintenance.Config{Windows: []maintenance.Window{{Schedule: "0 2 * * sat", Duration: time.Hour, Timezone: "America/Chicago"}}},
+			now:      time.Date(2024, time.May, 18, 7, 30, 0, 0, time.UTC),
+			expected: maintenance.Status{Open: true, Gated: true},
+		},
+		{
+			name:        "invalid outside policy",
+			config:      maintenance.Config{Windows: []maintenance.Window{saturdayWindow}, Outside: "wait"},
+			expectError: true,
+		},
+		{
+			name:        "missing duration",
+			config:      maintenance.Config{Windows: []maintenance.Window{{Schedule: "0 2 * * sat"}}},
+			expectError: true,
+		},
+		{
+			name:        "invalid time zone",
+			config:      maintenance.Config{Windows: []maintenance.Window{{Schedule: "0 2 * * sat", Duration: time.Hour, Timezone: "Mars/Olympus"}}},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			status, err := tc.config.Check(tc.namespace, tc.now)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if status.Open != tc.expected.Open || status.Gated != tc.expected.Gated || !status.Next.Equal(tc.expected.Next) {
+				t.Errorf("expected %+v, got %+v", tc.expected, status)
+			}
+		})
+	}
+}
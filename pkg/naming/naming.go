@@ -0,0 +1,112 @@
+// Package naming renders the names of AWS resources that nimbus creates from a user customizable template.
+//
+// Tags identify nimbus resources, so names are only for humans and can be changed freely,
+// but every AWS resource type has its own constraints on which names are valid.
+package naming
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// ResourceType is the type of AWS resource that is being named
+type ResourceType string
+
+const (
+	LaunchTemplate ResourceType = "launch-template"
+	SecurityGroup  ResourceType = "security-group"
+
+	// DefaultTemplate names resources namespace/name or namespace/name/group for resources of a node group,
+	// suffixed with the spec hash for resources that have one
+	DefaultTemplate = "{{.Namespace}}/{{.Name}}{{with .Group}}/{{.}}{{end}}{{with .Hash}}-{{.}}{{end}}"
+
+	randomSuffixLength = 6
+	randomAlphabet     = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+var (
+	launchTemplateNameRegex = regexp.MustCompile(`^[a-zA-Z0-9().\-/_]{3,128}$`)
+	securityGroupNameRegex  = regexp.MustCompile(`^[a-zA-Z0-9 ._\-:/()#,@\[\]+=&;{}!$*]{1,255}$`)
+)
+
+// Fields are available to naming templates, e.g. "{{.Namespace}}-{{.Name}}-{{.Random}}"
+type Fields struct {
+	Namespace string
+	Name      string
+	// Group is the node group name, it is empty for plans without node groups
+	Group string
+	// Type is the resource type being named, e.g. launch-template
+	Type ResourceType
+	// Random is a random lowercase alphanumeric suffix that is generated for each rendered name
+	Random string
+	// Hash is a hash of the resource's spec for resources that are reused only if their spec matches, e.g. launch templates.
+	// It is empty for other resource types.
+	Hash string
+}
+
+// Strategy names resources by rendering a text/template with Fields
+type Strategy struct {
+	template *template.Template
+}
+
+// NewStrategy parses the naming template, an empty template uses DefaultTemplate
+func NewStrategy(namingTemplate string) (Strategy, error) {
+	if namingTemplate == "" {
+		namingTemplate = DefaultTemplate
+	}
+	tmpl, err := template.New("naming").Option("missingkey=error").Parse(namingTemplate)
+	if err != nil {
+		return Strategy{}, fmt.Errorf("invalid naming template: %w", err)
+	}
+	return Strategy{template: tmpl}, nil
+}
+
+// Name renders a name for the resource type and validates it against the resource type's constraints
+func (s Strategy) Name(resourceType ResourceType, fields Fields) (string, error) {
+	fields.Type = resourceType
+	if fields.Random == "" {
+		fields.Random = randomSuffix()
+	}
+	var name bytes.Buffer
+	if err := s.template.Execute(&name, fields); err != nil {
+		return "", fmt.Errorf("failed to render %s name: %w", resourceType, err)
+	}
+	if err := Validate(resourceType, name.String()); err != nil {
+		return "", err
+	}
+	return name.String(), nil
+}
+
+// Validate returns an error if the name is not valid for the resource type
+func Validate(resourceType ResourceType, name string) error {
+	switch resourceType {
+	case LaunchTemplate:
+		if !launchTemplateNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid launch template name %q, must be 3-128 characters of a-z, A-Z, 0-9, and ().-/_", name)
+		}
+	case SecurityGroup:
+		if !securityGroupNameRegex.MatchString(name) {
+			return fmt.Errorf("invalid security group name %q, must be 1-255 characters of a-z, A-Z, 0-9, spaces, and ._-:/()#,@[]+=&;{}!$*", name)
+		}
+		if strings.HasPrefix(strings.ToLower(name), "sg-") {
+			return fmt.Errorf("invalid security group name %q, must not start with sg-", name)
+		}
+	default:
+		return fmt.Errorf("unknown resource type %s", resourceType)
+	}
+	return nil
+}
+
+func randomSuffix() string {
+	suffix := make([]byte, randomSuffixLength)
+	// crypto/rand.Read never returns an error
+	_, _ = rand.Read(suffix)
+	for i, b := range suffix {
+		suffix[i] = randomAlphabet[int(b)%len(randomAlphabet)]
+	}
+	return string(suffix)
+}
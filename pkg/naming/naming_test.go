@@ -0,0 +1,123 @@
+package naming_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/naming"
+)
+
+func TestStrategyName(t *testing.T) {
+	type testCases struct {
+		name         string
+		template     string
+		resourceType naming.ResourceType
+		fields       naming.Fields
+		expected     string
+		expectedErr  bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:         "default template",
+			resourceType: naming.LaunchTemplate,
+			fields:       naming.Fields{Namespace: "dev", Name: "web"},
+			expected:     "dev/web",
+		},
+		{
+			name:         "default template with group",
+			resourceType: naming.SecurityGroup,
+			fields:       naming.Fields{Namespace: "dev", Name: "web", Group: "worker"},
+			expected:     "dev/web/worker",
+		},
+		{
+			name:         "default template with hash",
+			resourceType: naming.LaunchTemplate,
+			fields:       naming.Fields{Namespace: "dev", Name: "web", Group: "worker", Hash: "0123456789"},
+			expected:     "dev/web/worker-0123456789",
+		},
+		{
+			name:         "custom template with type and random suffix",
+			template:     "{{.Namespace}}-{{.Name}}-{{.Type}}-{{.Random}}",
+			resourceType: naming.LaunchTemplate,
+			fields:       naming.Fields{Namespace: "dev", Name: "web", Random: "abc123"},
+			expected:     "dev-web-launch-template-abc123",
+		},
+		{
+			name:         "launch template names do not allow spaces",
+			template:     "{{.Namespace}} {{.Name}}",
+			resourceType: naming.LaunchTemplate,
+			fields:       naming.Fields{Namespace: "dev", Name: "web"},
+			expectedErr:  true,
+		},
+		{
+			name:         "security group names allow spaces",
+			template:     "{{.Namespace}} {{.Name}}",
+			resourceType: naming.SecurityGroup,
+			fields:       naming.Fields{Namespace: "dev", Name: "web"},
+			expected:     "dev web",
+		},
+		{
+			name:         "security group names must not start with sg-",
+			template:     "sg-{{.Name}}",
+			resourceType: naming.SecurityGroup,
+			fields:       naming.Fields{Namespace: "dev", Name: "web"},
+			expectedErr:  true,
+		},
+		{
+			name:         "launch template names are at least 3 characters",
+			template:     "{{.Name}}",
+			resourceType: naming.LaunchTemplate,
+			fields:       naming.Fields{Name: "a"},
+			expectedErr:  true,
+		},
+		{
+			name:         "unknown field",
+			template:     "{{.Owner}}",
+			resourceType: naming.LaunchTemplate,
+			fields:       naming.Fields{Namespace: "dev", Name: "web"},
+			expectedErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			strategy, err := naming.NewStrategy(tc.template)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			name, err := strategy.Name(tc.resourceType, tc.fields)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got name %q", name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, name)
+			}
+		})
+	}
+}
+
+func TestStrategyNameRandomSuffix(t *testing.T) {
+	strategy, err := naming.NewStrategy("{{.Name}}-{{.Random}}")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	first, err := strategy.Name(naming.LaunchTemplate, naming.Fields{Name: "web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, err := strategy.Name(naming.LaunchTemplate, naming.Fields{Name: "web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !regexp.MustCompile(`^web-[a-z0-9]{6}$`).MatchString(first) {
+		t.Errorf("unexpected random name %q", first)
+	}
+	if first == second {
+		t.Errorf("expected different random suffixes, got %q twice", first)
+	}
+}
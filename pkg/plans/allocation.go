@@ -0,0 +1,88 @@
+package plans
+
+import (
+	"fmt"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/samber/lo"
+)
+
+const (
+	// MinTerminationDelay and MaxTerminationDelay bound how long a launch-before-terminate fleet keeps a spot instance after launching its replacement
+	MinTerminationDelay = 2 * time.Minute
+	MaxTerminationDelay = 2 * time.Hour
+)
+
+// Allocation tunes how EC2 Fleet allocates the capacity of every node group between spot and on-demand and across capacity pools.
+// The zero value launches all of a group's capacity as its capacity type with the price-capacity-optimized spot allocation strategy.
+type Allocation struct {
+	// SpotAllocationStrategy is price-capacity-optimized, capacity-optimized, capacity-optimized-prioritized, diversified, or lowest-price,
+	// defaults to price-capacity-optimized
+	SpotAllocationStrategy string
+	// SpotMaxPrice is the most that a spot instance is launched for per hour in USD, zero is capped at the on-demand price.
+	// A lower max price avoids expensive capacity pools at the cost of more interruptions.
+	SpotMaxPrice float64
+	// OnDemandBaseCapacity is the capacity of every node group that is launched on-demand before the rest is split by OnDemandPercentageAboveBase.
+	// It counts instances, or the vCPUs or memory of node groups with a Capacity.
+	OnDemandBaseCapacity int32
+	// OnDemandPercentageAboveBase is the percentage of the capacity above OnDemandBaseCapacity that is launched on-demand, rounded up,
+	// and the rest is launched as spot. If it is nil, the capacity above the base is launched as the node group's capacity type.
+	OnDemandPercentageAboveBase *int32
+	// CapacityRebalance is launch or launch-before-terminate and launches a replacement spot instance when EC2 signals that a spot instance
+	// is at an elevated risk of interruption. launch leaves the at-risk instance running, launch-before-terminate terminates it after TerminationDelay.
+	// It launches maintain fleets instead of instant fleets, which keep replacing instances until the VM is deleted or replaced.
+	CapacityRebalance string
+	// TerminationDelay is how long a launch-before-terminate fleet keeps the at-risk spot instance running after launching its replacement,
+	// between MinTerminationDelay and MaxTerminationDelay, defaults to MinTerminationDelay
+	TerminationDelay time.Duration
+}
+
+// Validate returns an error if EC2 Fleet would reject the allocation for a node group of the capacity type
+func (a Allocation) Validate(capacityType string) error {
+	if a.SpotAllocationStrategy != "" && !lo.Contains(ec2types.SpotAllocationStrategy("").Values(), ec2types.SpotAllocationStrategy(a.SpotAllocationStrategy)) {
+		return fmt.Errorf("invalid spot allocation strategy %q, must be one of %v", a.SpotAllocationStrategy, ec2types.SpotAllocationStrategy("").Values())
+	}
+	if a.SpotMaxPrice < 0 {
+		return fmt.Errorf("spot max price must not be negative, got %g", a.SpotMaxPrice)
+	}
+	if a.OnDemandBaseCapacity < 0 {
+		return fmt.Errorf("on-demand base capacity must not be negative, got %d", a.OnDemandBaseCapacity)
+	}
+	if percentage := a.OnDemandPercentageAboveBase; percentage != nil && (*percentage < 0 || *percentage > 100) {
+		return fmt.Errorf("on-demand percentage above base must be between 0 and 100, got %d", *percentage)
+	}
+	if a.CapacityRebalance != "" && !lo.Contains(ec2types.FleetReplacementStrategy("").Values(), ec2types.FleetReplacementStrategy(a.CapacityRebalance)) {
+		return fmt.Errorf("invalid capacity rebalance replacement strategy %q, must be one of %v", a.CapacityRebalance, ec2types.FleetReplacementStrategy("").Values())
+	}
+	if a.TerminationDelay != 0 && a.CapacityRebalance != string(ec2types.FleetReplacementStrategyLaunchBeforeTerminate) {
+		return fmt.Errorf("a termination delay requires the %s capacity rebalance replacement strategy", ec2types.FleetReplacementStrategyLaunchBeforeTerminate)
+	}
+	if a.TerminationDelay != 0 && (a.TerminationDelay < MinTerminationDelay || a.TerminationDelay > MaxTerminationDelay) {
+		return fmt.Errorf("termination delay must be between %s and %s, got %s", MinTerminationDelay, MaxTerminationDelay, a.TerminationDelay)
+	}
+	if a.launchesSpot(capacityType) {
+		return nil
+	}
+	if a.SpotMaxPrice != 0 || a.CapacityRebalance != "" {
+		return fmt.Errorf("spot max price and capacity rebalance only apply to spot capacity, launch a spot capacity type or an on-demand percentage below 100")
+	}
+	return nil
+}
+
+// EffectiveTerminationDelay returns the termination delay of a launch-before-terminate fleet, defaulted if it is not set
+func (a Allocation) EffectiveTerminationDelay() time.Duration {
+	if a.CapacityRebalance != string(ec2types.FleetReplacementStrategyLaunchBeforeTerminate) {
+		return 0
+	}
+	return lo.CoalesceOrEmpty(a.TerminationDelay, MinTerminationDelay)
+}
+
+// launchesSpot is true if a node group of the capacity type may launch spot capacity with the allocation
+func (a Allocation) launchesSpot(capacityType string) bool {
+	if a.OnDemandPercentageAboveBase != nil {
+		return *a.OnDemandPercentageAboveBase < 100
+	}
+	return ec2utils.NormalizeCapacityType(capacityType) == string(ec2types.DefaultTargetCapacityTypeSpot)
+}
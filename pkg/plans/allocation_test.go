@@ -0,0 +1,48 @@
+package plans_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/samber/lo"
+)
+
+func TestAllocationValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		allocation   plans.Allocation
+		capacityType string
+		wantErr      bool
+	}{
+		{name: "zero value", capacityType: "on-demand"},
+		{name: "spot strategy", allocation: plans.Allocation{SpotAllocationStrategy: "capacity-optimized"}, capacityType: "spot"},
+		{name: "invalid spot strategy", allocation: plans.Allocation{SpotAllocationStrategy: "cheapest"}, capacityType: "spot", wantErr: true},
+		{name: "negative max price", allocation: plans.Allocation{SpotMaxPrice: -1}, capacityType: "spot", wantErr: true},
+		{name: "max price of on-demand", allocation: plans.Allocation{SpotMaxPrice: 0.1}, capacityType: "on-demand", wantErr: true},
+		{name: "max price of spot above base", allocation: plans.Allocation{SpotMaxPrice: 0.1, OnDemandPercentageAboveBase: lo.ToPtr[int32](50)}, capacityType: "on-demand"},
+		{name: "percentage above 100", allocation: plans.Allocation{OnDemandPercentageAboveBase: lo.ToPtr[int32](101)}, wantErr: true},
+		{name: "negative base", allocation: plans.Allocation{OnDemandBaseCapacity: -1}, wantErr: true},
+		{name: "rebalance", allocation: plans.Allocation{CapacityRebalance: "launch"}, capacityType: "spot"},
+		{name: "rebalance of on-demand", allocation: plans.Allocation{CapacityRebalance: "launch"}, capacityType: "on-demand", wantErr: true},
+		{name: "invalid rebalance", allocation: plans.Allocation{CapacityRebalance: "replace"}, capacityType: "spot", wantErr: true},
+		{name: "termination delay", allocation: plans.Allocation{CapacityRebalance: "launch-before-terminate", TerminationDelay: 10 * time.Minute}, capacityType: "spot"},
+		{name: "termination delay of launch", allocation: plans.Allocation{CapacityRebalance: "launch", TerminationDelay: 10 * time.Minute}, capacityType: "spot", wantErr: true},
+		{name: "short termination delay", allocation: plans.Allocation{CapacityRebalance: "launch-before-terminate", TerminationDelay: time.Minute}, capacityType: "spot", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.allocation.Validate(tc.capacityType); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() = %v, want error %t", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllocationEffectiveTerminationDelay(t *testing.T) {
+	if delay := (plans.Allocation{CapacityRebalance: "launch-before-terminate"}).EffectiveTerminationDelay(); delay != plans.MinTerminationDelay {
+		t.Errorf("expected the default termination delay %s, got %s", plans.MinTerminationDelay, delay)
+	}
+	if delay := (plans.Allocation{CapacityRebalance: "launch"}).EffectiveTerminationDelay(); delay != 0 {
+		t.Errorf("expected no termination delay for launch, got %s", delay)
+	}
+}
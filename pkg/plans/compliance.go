@@ -0,0 +1,129 @@
+package plans
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/samber/lo"
+)
+
+// Compliance rules that a Violation can be reported for
+const (
+	RuleRequiredTags      = "required-tags"
+	RuleIMDSv2            = "imdsv2"
+	RuleEncryption        = "encryption"
+	RuleAllowedRegions    = "allowed-regions"
+	RuleDisallowPublicIPs = "disallow-public-ips"
+)
+
+// CompliancePolicy are organization guardrails that a launch plan must satisfy, e.g. loaded from a file shared by CI jobs:
+//
+//	requiredTags: [team, cost-center]
+//	requireIMDSv2: true
+//	requireEncryption: true
+//	allowedRegions: [us-west-2, us-east-1]
+//	disallowPublicIPs: true
+type CompliancePolicy struct {
+	// RequiredTags are tag keys that must be in the spec's Tags
+	RequiredTags []string `yaml:"requiredTags"`
+	// RequireIMDSv2 requires the instances to only allow IMDSv2 requests, either by the spec's metadata options or by every AMI
+	RequireIMDSv2 bool `yaml:"requireIMDSv2"`
+	// RequireEncryption requires every volume to be encrypted
+	RequireEncryption bool `yaml:"requireEncryption"`
+	// AllowedRegions are the regions that plans may launch into, any region is allowed if it is empty
+	AllowedRegions []string `yaml:"allowedRegions"`
+	// DisallowPublicIPs rejects subnets that assign public IPs to launched instances
+	DisallowPublicIPs bool `yaml:"disallowPublicIPs"`
+}
+
+// Violation is a machine-readable description of how a plan does not comply with a CompliancePolicy
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+// ComplianceError is returned when a plan has compliance violations
+type ComplianceError struct {
+	Violations []Violation
+}
+
+func (e ComplianceError) Error() string {
+	return fmt.Sprintf("launch plan has %d compliance violations: %s", len(e.Violations), strings.Join(lo.Map(e.Violations, func(violation Violation, _ int) string {
+		return fmt.Sprintf("%s: %s", violation.Rule, violation.Message)
+	}), "; "))
+}
+
+// IsEmpty returns true if the policy does not enforce anything
+func (p CompliancePolicy) IsEmpty() bool {
+	return len(p.RequiredTags) == 0 && !p.RequireIMDSv2 && !p.RequireEncryption && len(p.AllowedRegions) == 0 && !p.DisallowPublicIPs
+}
+
+// Evaluate returns the violations of the policy by the plan launching into the region.
+// The AMIs of the plan's node group statuses must be resolved. launchSubnets are the subnets that instances will be launched into,
+// or nil if nimbus will create the network, whose subnets assign public IPs.
+func (p CompliancePolicy) Evaluate(launchPlan LaunchPlan, region string, launchSubnets []subnets.Subnet) []Violation {
+	var violations []Violation
+	if len(p.AllowedRegions) != 0 && !lo.Contains(p.AllowedRegions, region) {
+		violations = append(violations, Violation{
+			Rule:    RuleAllowedRegions,
+			Message: fmt.Sprintf("region %s is not one of the allowed regions %v", region, p.AllowedRegions),
+		})
+	}
+	for _, key := range p.RequiredTags {
+		if _, ok := launchPlan.Spec.Tags[key]; !ok {
+			violations = append(violations, Violation{
+				Rule:    RuleRequiredTags,
+				Message: fmt.Sprintf("tag %s is required", key),
+			})
+		}
+	}
+	if p.RequireEncryption {
+		if !launchPlan.Spec.RootVolume.IsEncrypted() {
+			violations = append(violations, Violation{
+				Rule:    RuleEncryption,
+				Message: "the root volume is not encrypted",
+			})
+		}
+		for _, mapping := range append(slices.Clone(launchPlan.Spec.BlockDeviceMappings), launchPlan.Spec.Volumes...) {
+			if !mapping.IsEncrypted() {
+				violations = append(violations, Violation{
+					Rule:    RuleEncryption,
+					Message: fmt.Sprintf("the %s volume is not encrypted", mapping.DeviceName),
+				})
+			}
+		}
+	}
+	// instances only allow IMDSv2 if either their launch template or their AMI requires it
+	if p.RequireIMDSv2 && !launchPlan.Spec.MetadataOptions.RequiresIMDSv2() {
+		for _, group := range launchPlan.Status.NodeGroups {
+			for _, ami := range group.AMIs {
+				if ami.ImdsSupport != ec2types.ImdsSupportValuesV20 {
+					violations = append(violations, Violation{
+						Rule:    RuleIMDSv2,
+						Message: fmt.Sprintf("AMI %s does not require IMDSv2 and the metadata options allow IMDSv1", lo.FromPtr(ami.ImageId)),
+					})
+				}
+			}
+		}
+	}
+	if p.DisallowPublicIPs {
+		if launchSubnets == nil {
+			violations = append(violations, Violation{
+				Rule:    RuleDisallowPublicIPs,
+				Message: "the network created by nimbus assigns public IPs, specify subnets that do not",
+			})
+		}
+		for _, subnet := range launchSubnets {
+			if lo.FromPtr(subnet.MapPublicIpOnLaunch) {
+				violations = append(violations, Violation{
+					Rule:    RuleDisallowPublicIPs,
+					Message: fmt.Sprintf("subnet %s assigns public IPs", lo.FromPtr(subnet.SubnetId)),
+				})
+			}
+		}
+	}
+	return violations
+}
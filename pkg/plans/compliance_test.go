@@ -0,0 +1,118 @@
+package plans_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/samber/lo"
+)
+
+func TestCompliancePolicyEvaluate(t *testing.T) {
+	type testCases struct {
+		name          string
+		policy        plans.CompliancePolicy
+		spec          plans.LaunchSpec
+		amiList       []amis.AMI
+		region        string
+		launchSubnets []subnets.Subnet
+		expected      []string
+	}
+
+	privateSubnet := subnets.Subnet{Subnet: ec2types.Subnet{SubnetId: aws.String("subnet-1"), MapPublicIpOnLaunch: aws.Bool(false)}}
+	publicSubnet := subnets.Subnet{Subnet: ec2types.Subnet{SubnetId: aws.String("subnet-2"), MapPublicIpOnLaunch: aws.Bool(true)}}
+
+	for _, tc := range []testCases{
+		{
+			name:          "empty policy",
+			region:        "us-west-2",
+			launchSubnets: []subnets.Subnet{publicSubnet},
+		},
+		{
+			name:          "compliant",
+			policy:        plans.CompliancePolicy{RequiredTags: []string{"team"}, RequireIMDSv2: true, RequireEncryption: true, AllowedRegions: []string{"us-west-2"}, DisallowPublicIPs: true},
+			spec:          plans.LaunchSpec{Tags: map[string]string{"team": "infra"}},
+			amiList:       []amis.AMI{{Image: ec2types.Image{ImageId: aws.String("ami-1"), ImdsSupport: ec2types.ImdsSupportValuesV20}}},
+			region:        "us-west-2",
+			launchSubnets: []subnets.Subnet{privateSubnet},
+		},
+		{
+			name:     "region not allowed",
+			policy:   plans.CompliancePolicy{AllowedRegions: []string{"us-west-2"}},
+			region:   "eu-west-1",
+			expected: []string{plans.RuleAllowedRegions},
+		},
+		{
+			name:     "missing tags",
+			policy:   plans.CompliancePolicy{RequiredTags: []string{"team", "cost-center"}},
+			spec:     plans.LaunchSpec{Tags: map[string]string{"team": "infra"}},
+			expected: []string{plans.RuleRequiredTags},
+		},
+		{
+			name:     "unencrypted root volume",
+			policy:   plans.CompliancePolicy{RequireEncryption: true},
+			spec:     plans.LaunchSpec{RootVolume: launchtemplates.BlockDevice{Encrypted: aws.Bool(false)}},
+			expected: []string{plans.RuleEncryption},
+		},
+		{
+			name:   "unencrypted data volume",
+			policy: plans.CompliancePolicy{RequireEncryption: true},
+			spec: plans.LaunchSpec{BlockDeviceMappings: []launchtemplates.BlockDevice{
+				{DeviceName: "/dev/sdf", VolumeSize: 100},
+				{DeviceName: "/dev/sdg", VolumeSize: 100, Encrypted: aws.Bool(false)},
+			}},
+			expected: []string{plans.RuleEncryption},
+		},
+		{
+			name:   "unencrypted standalone volume",
+			policy: plans.CompliancePolicy{RequireEncryption: true},
+			spec: plans.LaunchSpec{Volumes: []launchtemplates.BlockDevice{
+				{DeviceName: "/dev/sdf", VolumeSize: 100, Encrypted: aws.Bool(false)},
+			}},
+			expected: []string{plans.RuleEncryption},
+		},
+		{
+			name:     "AMI without IMDSv2",
+			policy:   plans.CompliancePolicy{RequireIMDSv2: true},
+			spec:     plans.LaunchSpec{MetadataOptions: launchtemplates.MetadataOptions{HTTPTokens: "optional"}},
+			amiList:  []amis.AMI{{Image: ec2types.Image{ImageId: aws.String("ami-1")}}},
+			expected: []string{plans.RuleIMDSv2},
+		},
+		{
+			name:    "AMI without IMDSv2 launched with IMDSv2 required",
+			policy:  plans.CompliancePolicy{RequireIMDSv2: true},
+			amiList: []amis.AMI{{Image: ec2types.Image{ImageId: aws.String("ami-1")}}},
+		},
+		{
+			name:          "public subnet",
+			policy:        plans.CompliancePolicy{DisallowPublicIPs: true},
+			launchSubnets: []subnets.Subnet{privateSubnet, publicSubnet},
+			expected:      []string{plans.RuleDisallowPublicIPs},
+		},
+		{
+			name:     "created network",
+			policy:   plans.CompliancePolicy{DisallowPublicIPs: true},
+			expected: []string{plans.RuleDisallowPublicIPs},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			launchPlan := plans.LaunchPlan{
+				Spec:   tc.spec,
+				Status: plans.LaunchStatus{NodeGroups: []plans.NodeGroupStatus{{AMIs: tc.amiList}}},
+			}
+			violations := tc.policy.Evaluate(launchPlan, tc.region, tc.launchSubnets)
+			rules := lo.Map(violations, func(violation plans.Violation, _ int) string { return violation.Rule })
+			if len(rules) == 0 && len(tc.expected) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected violations of %v, got %v", tc.expected, violations)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package plans
+
+import (
+	"time"
+)
+
+// ConditionType is an observation about the execution of a plan
+type ConditionType string
+
+// ConditionStatus is True, False, or Unknown while the condition is still being worked on
+type ConditionStatus string
+
+const (
+	ConditionTrue    ConditionStatus = "True"
+	ConditionFalse   ConditionStatus = "False"
+	ConditionUnknown ConditionStatus = "Unknown"
+
+	// Launch conditions
+
+	// ConditionAMIsResolved is true when every node group's AMIs and instance types are resolved
+	ConditionAMIsResolved ConditionType = "AMIsResolved"
+	// ConditionNetworkReady is true when the VPC, subnets, and security groups to launch into are resolved or created
+	ConditionNetworkReady ConditionType = "NetworkReady"
+	// ConditionFleetLaunched is true when the fleets of every node group were created
+	ConditionFleetLaunched ConditionType = "FleetLaunched"
+	// ConditionInstancesRunning is true when every launched instance is running
+	ConditionInstancesRunning ConditionType = "InstancesRunning"
+
+	// Deletion conditions
+
+	// ConditionExperimentsDeleted is true when every FIS experiment template of the plan is deleted and its experiments are stopped
+	ConditionExperimentsDeleted ConditionType = "ExperimentsDeleted"
+	// ConditionFleetsDeleted is true when every active fleet of the plan is deleted
+	ConditionFleetsDeleted ConditionType = "FleetsDeleted"
+	// ConditionSnapshotsCreated is true when a final snapshot of every volume attached to the instances of the plan was started
+	ConditionSnapshotsCreated ConditionType = "SnapshotsCreated"
+	// ConditionInstancesTerminated is true when every instance of the plan is terminated
+	ConditionInstancesTerminated ConditionType = "InstancesTerminated"
+	// ConditionVolumesDeleted is true when every standalone volume of the plan is deleted
+	ConditionVolumesDeleted ConditionType = "VolumesDeleted"
+	// ConditionSecurityGroupsDeleted is true when every security group of the plan is deleted
+	ConditionSecurityGroupsDeleted ConditionType = "SecurityGroupsDeleted"
+	// ConditionNetworkDeleted is true when the NAT gateways, Elastic IPs, internet gateways, route tables, subnets, and VPCs of the plan are deleted
+	ConditionNetworkDeleted ConditionType = "NetworkDeleted"
+	// ConditionLaunchTemplatesDeleted is true when every launch template of the plan is deleted or skipped
+	ConditionLaunchTemplatesDeleted ConditionType = "LaunchTemplatesDeleted"
+	// ConditionInstanceProfilesDeleted is true when every instance profile that nimbus created for the plan is deleted
+	ConditionInstanceProfilesDeleted ConditionType = "InstanceProfilesDeleted"
+)
+
+// Condition records the state of one step of a plan's execution
+type Condition struct {
+	Type   ConditionType
+	Status ConditionStatus
+	// LastTransitionTime is when the Status last changed
+	LastTransitionTime time.Time
+	// Message is a human readable description of the condition, like the error that made it False
+	Message string
+}
+
+// Conditions are the conditions of a plan's status in the order they were first set
+type Conditions []Condition
+
+// Set updates or adds the condition. LastTransitionTime only changes when the status changes.
+func (c *Conditions) Set(conditionType ConditionType, status ConditionStatus, message string) {
+	for i, condition := range *c {
+		if condition.Type != conditionType {
+			continue
+		}
+		if condition.Status != status {
+			(*c)[i].LastTransitionTime = time.Now()
+		}
+		(*c)[i].Status = status
+		(*c)[i].Message = message
+		return
+	}
+	*c = append(*c, Condition{
+		Type:               conditionType,
+		Status:             status,
+		LastTransitionTime: time.Now(),
+		Message:            message,
+	})
+}
+
+// Get returns the condition of the type
+func (c Conditions) Get(conditionType ConditionType) (Condition, bool) {
+	for _, condition := range c {
+		if condition.Type == conditionType {
+			return condition, true
+		}
+	}
+	return Condition{}, false
+}
+
+// IsTrue returns true if the condition of the type is set and True
+func (c Conditions) IsTrue(conditionType ConditionType) bool {
+	condition, ok := c.Get(conditionType)
+	return ok && condition.Status == ConditionTrue
+}
+
+// FailInProgress sets every Unknown condition to False with the error as the message.
+// Plan execution sets a condition to Unknown when it starts a step, so the failed step is the one still in progress.
+func (c *Conditions) FailInProgress(err error) {
+	for _, condition := range *c {
+		if condition.Status == ConditionUnknown {
+			c.Set(condition.Type, ConditionFalse, err.Error())
+		}
+	}
+}
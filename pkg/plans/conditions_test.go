@@ -0,0 +1,38 @@
+package plans_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+)
+
+func TestConditions(t *testing.T) {
+	var conditions plans.Conditions
+	conditions.Set(plans.ConditionAMIsResolved, plans.ConditionUnknown, "Resolving AMIs")
+	conditions.Set(plans.ConditionAMIsResolved, plans.ConditionTrue, "Resolved AMIs")
+	resolved, ok := conditions.Get(plans.ConditionAMIsResolved)
+	if !ok || !conditions.IsTrue(plans.ConditionAMIsResolved) {
+		t.Fatalf("expected AMIsResolved to be True, got %+v", conditions)
+	}
+
+	conditions.Set(plans.ConditionAMIsResolved, plans.ConditionTrue, "Resolved AMIs again")
+	if updated, _ := conditions.Get(plans.ConditionAMIsResolved); !updated.LastTransitionTime.Equal(resolved.LastTransitionTime) || updated.Message != "Resolved AMIs again" {
+		t.Errorf("expected the message to change without a transition, got %+v", updated)
+	}
+
+	conditions.Set(plans.ConditionNetworkReady, plans.ConditionUnknown, "Resolving network")
+	conditions.FailInProgress(errors.New("no subnets"))
+	if len(conditions) != 2 {
+		t.Fatalf("expected 2 conditions, got %d", len(conditions))
+	}
+	if network, _ := conditions.Get(plans.ConditionNetworkReady); network.Status != plans.ConditionFalse || network.Message != "no subnets" {
+		t.Errorf("expected NetworkReady to be False with the error message, got %+v", network)
+	}
+	if !conditions.IsTrue(plans.ConditionAMIsResolved) {
+		t.Errorf("expected AMIsResolved to stay True")
+	}
+	if conditions.IsTrue(plans.ConditionFleetLaunched) {
+		t.Errorf("expected an unset condition not to be True")
+	}
+}
@@ -0,0 +1,115 @@
+package plans
+
+import (
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/samber/lo"
+)
+
+// EstimatedCost is an estimate of what the plan's instances and EBS volumes cost to run.
+// Prices are in the currency of the region's price list, USD or CNY in the China regions.
+type EstimatedCost struct {
+	// NodeGroups are the estimates of each node group
+	NodeGroups []GroupCost
+	// Hourly is the cost of every priced node group per hour
+	Hourly float64
+	// Monthly is the cost of every priced node group over a month of 730 hours
+	Monthly float64
+	// Incomplete is true if the price of a node group's instances or volumes is unknown, the group is left out of the totals
+	Incomplete bool
+	// Existing is what the instances that namespace/name already runs cost, the launch's cost delta is the difference to Hourly
+	Existing InstancesCost
+}
+
+// InstancesCost is the hourly cost of running instances, priced by their instance type and at the spot price of their availability zone for spot instances.
+// The cost of their volumes is not included.
+type InstancesCost struct {
+	Hourly float64
+	// Incomplete is true if the price of an instance is unknown, the instance is left out of Hourly
+	Incomplete bool
+}
+
+// GroupCost is the estimated cost of a node group, assuming every instance is its cheapest instance type
+type GroupCost struct {
+	Name         string
+	CapacityType string
+	// InstanceType is the cheapest instance type per unit of capacity that the estimate assumes, empty if none has a price
+	InstanceType string
+	// Instances is the number of instances, for a target capacity the number of InstanceType instances that reach it
+	Instances int32
+	// InstancePrice is the hourly price of one instance, the lowest spot price of any availability zone for spot
+	InstancePrice float64
+	// VolumeGiB is the size of the EBS volumes of one instance, including standalone volumes
+	VolumeGiB int32
+	// VolumePrice is the hourly storage price of the EBS volumes of one instance, provisioned IOPS and throughput are not included
+	VolumePrice float64
+	// Hourly is the cost of all of the group's instances and volumes per hour
+	Hourly float64
+	// Monthly is the cost of all of the group's instances and volumes over a month of 730 hours
+	Monthly float64
+	// Priced is false if the price of the instances or the volumes is unknown
+	Priced bool
+}
+
+// PrettyGroupCost is the table representation of a GroupCost
+type PrettyGroupCost struct {
+	Name          string `table:"Group"`
+	CapacityType  string `table:"Capacity-Type"`
+	InstanceType  string `table:"Instance-Type"`
+	Instances     int32  `table:"Instances"`
+	InstancePrice string `table:"Instance-Hourly,wide"`
+	Volume        string `table:"Volume,wide"`
+	VolumePrice   string `table:"Volume-Hourly,wide"`
+	Hourly        string `table:"Hourly"`
+	Monthly       string `table:"Monthly"`
+}
+
+// Add adds the estimate of a node group, its cost is only added to the totals if it is priced
+func (c *EstimatedCost) Add(group GroupCost) {
+	c.NodeGroups = append(c.NodeGroups, group)
+	if !group.Priced {
+		c.Incomplete = true
+		return
+	}
+	c.Hourly += group.Hourly
+	c.Monthly += group.Monthly
+}
+
+// Prettify returns the table representation of the node group estimates
+func (c EstimatedCost) Prettify() []PrettyGroupCost {
+	return lo.Map(c.NodeGroups, func(group GroupCost, _ int) PrettyGroupCost {
+		prettyCost := PrettyGroupCost{
+			Name:          lo.CoalesceOrEmpty(group.Name, "default"),
+			CapacityType:  lo.CoalesceOrEmpty(group.CapacityType, "on-demand"),
+			InstanceType:  lo.CoalesceOrEmpty(group.InstanceType, "unknown"),
+			Instances:     group.Instances,
+			InstancePrice: "unknown",
+			Volume:        pretty.Bytes(bytesize.ByteSize(group.VolumeGiB) << 30),
+			VolumePrice:   "unknown",
+			Hourly:        "unknown",
+			Monthly:       "unknown",
+		}
+		if group.InstancePrice != 0 {
+			prettyCost.InstancePrice = fmt.Sprintf("$%.4f", group.InstancePrice)
+		}
+		if group.VolumePrice != 0 || group.Priced {
+			prettyCost.VolumePrice = fmt.Sprintf("$%.4f", group.VolumePrice)
+		}
+		if group.Priced {
+			prettyCost.Hourly = fmt.Sprintf("$%.4f", group.Hourly)
+			prettyCost.Monthly = fmt.Sprintf("$%.2f", group.Monthly)
+		}
+		return prettyCost
+	})
+}
+
+// String summarizes the totals of the estimate
+func (c EstimatedCost) String() string {
+	summary := fmt.Sprintf("Estimated cost: $%.4f per hour, $%.2f per month", c.Hourly, c.Monthly)
+	if c.Incomplete {
+		summary += ", not including node groups with unknown prices"
+	}
+	return summary
+}
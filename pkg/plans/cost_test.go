@@ -0,0 +1,31 @@
+package plans_test
+
+import (
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+)
+
+func TestEstimatedCost(t *testing.T) {
+	var estimate plans.EstimatedCost
+	estimate.Add(plans.GroupCost{Name: "web", CapacityType: "spot", InstanceType: "m7g.large", Instances: 2, InstancePrice: 0.03, Hourly: 0.06, Monthly: 43.8, Priced: true})
+	estimate.Add(plans.GroupCost{Name: "db", InstanceType: "r7g.large", Instances: 1, InstancePrice: 0.1, Hourly: 0.1, Monthly: 73, Priced: true})
+	if estimate.Incomplete {
+		t.Errorf("expected a complete estimate when every group is priced")
+	}
+	if estimate.Hourly != 0.16 || estimate.Monthly != 116.8 {
+		t.Errorf("expected $0.16 per hour and $116.80 per month, got %v and %v", estimate.Hourly, estimate.Monthly)
+	}
+
+	estimate.Add(plans.GroupCost{Name: "gpu", CapacityType: "spot", Instances: 1})
+	if !estimate.Incomplete || estimate.Hourly != 0.16 {
+		t.Errorf("expected an unpriced group to mark the estimate incomplete without changing the totals, got %+v", estimate)
+	}
+	pretty := estimate.Prettify()
+	if pretty[1].CapacityType != "on-demand" || pretty[1].Monthly != "$73.00" {
+		t.Errorf("expected the db group to be on-demand for $73.00 per month, got %+v", pretty[1])
+	}
+	if pretty[2].InstanceType != "unknown" || pretty[2].Hourly != "unknown" {
+		t.Errorf("expected the gpu group to have unknown prices, got %+v", pretty[2])
+	}
+}
@@ -0,0 +1,205 @@
+package plans
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/eips"
+	"github.com/bwagner5/nimbus/pkg/providers/fis"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
+	"github.com/bwagner5/nimbus/pkg/providers/routetables"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
+	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// deletionSteps are the steps that vm.Delete deletes the resource types in, the types of a step are deleted concurrently
+var deletionSteps = map[string]int{
+	"Fleet":                     1,
+	"ExperimentTemplate":        1,
+	"NATGateway":                1,
+	"Instance":                  2,
+	"LaunchTemplate":            2,
+	"Volume":                    3,
+	"SecurityGroup":             3,
+	"ElasticIP":                 3,
+	"InternetGateway":           4,
+	"EgressOnlyInternetGateway": 4,
+	"RouteTable":                4,
+	"Subnet":                    5,
+	"VPC":                       6,
+	"InstanceProfile":           7,
+}
+
+// DeletionGraph is the dependency graph of a DeletionPlan, an edge from a resource to another means that it is deleted before the other
+type DeletionGraph struct {
+	Nodes []GraphNode
+	Edges []GraphEdge
+}
+
+// GraphNode is a resource of a DeletionGraph
+type GraphNode struct {
+	ID   string `table:"ID"`
+	Type string `table:"Type"`
+	// Step is the order that the resource is deleted in, resources of the same step are deleted concurrently. Skipped resources have no step.
+	Step int `table:"Step"`
+	// Skipped resources matched the plan but are not deleted, e.g. shared network resources that are still in use
+	Skipped bool   `table:"Skipped"`
+	Reason  string `table:"Reason"`
+}
+
+// GraphEdge is a dependency of a DeletionGraph, From is deleted before To
+type GraphEdge struct {
+	From string `table:"From"`
+	To   string `table:"To"`
+}
+
+// Graph returns the dependency graph of the plan's resources, including the skipped ones so that it shows what stays in place and why
+func (p DeletionPlan) Graph() DeletionGraph {
+	var graph DeletionGraph
+	addNodes := func(resourceType string, ids []string) {
+		for _, id := range ids {
+			graph.Nodes = append(graph.Nodes, GraphNode{ID: id, Type: resourceType, Step: deletionSteps[resourceType]})
+		}
+	}
+	spec := p.Spec
+	addNodes("Fleet", lo.Map(spec.Fleets, func(fleet fleets.Fleet, _ int) string { return lo.FromPtr(fleet.FleetId) }))
+	addNodes("ExperimentTemplate", lo.Map(spec.ExperimentTemplates, func(template fis.ExperimentTemplate, _ int) string { return template.ID }))
+	addNodes("NATGateway", lo.Map(spec.NATGateways, func(natGateway natgws.NATGateway, _ int) string { return lo.FromPtr(natGateway.NatGatewayId) }))
+	addNodes("Instance", lo.Map(spec.Instances, func(instance instances.Instance, _ int) string { return lo.FromPtr(instance.InstanceId) }))
+	addNodes("LaunchTemplate", lo.Map(spec.LaunchTemplates, func(launchTemplate launchtemplates.LaunchTemplate, _ int) string {
+		return lo.FromPtr(launchTemplate.LaunchTemplateId)
+	}))
+	addNodes("Volume", lo.Map(spec.Volumes, func(volume volumes.Volume, _ int) string { return lo.FromPtr(volume.VolumeId) }))
+	addNodes("SecurityGroup", lo.Map(spec.SecurityGroups, func(securityGroup securitygroups.SecurityGroup, _ int) string {
+		return lo.FromPtr(securityGroup.GroupId)
+	}))
+	addNodes("ElasticIP", lo.Map(spec.ElasticIPs, func(eip eips.ElasticIP, _ int) string { return lo.FromPtr(eip.AllocationId) }))
+	addNodes("InternetGateway", lo.Map(spec.InternetGateways, func(igw igws.InternetGateway, _ int) string { return lo.FromPtr(igw.InternetGatewayId) }))
+	addNodes("EgressOnlyInternetGateway", lo.Map(spec.EgressOnlyInternetGateways, func(eigw igws.EgressOnlyInternetGateway, _ int) string {
+		return lo.FromPtr(eigw.EgressOnlyInternetGatewayId)
+	}))
+	addNodes("RouteTable", lo.Map(spec.RouteTables, func(routeTable routetables.RouteTable, _ int) string { return lo.FromPtr(routeTable.RouteTableId) }))
+	addNodes("Subnet", lo.Map(spec.Subnets, func(subnet subnets.Subnet, _ int) string { return lo.FromPtr(subnet.SubnetId) }))
+	addNodes("VPC", lo.Map(spec.VPCs, func(vpc vpcs.VPC, _ int) string { return lo.FromPtr(vpc.VpcId) }))
+	addNodes("InstanceProfile", lo.Map(spec.InstanceProfiles, func(profile instanceprofiles.InstanceProfile, _ int) string { return profile.InstanceProfileName }))
+	for _, skipped := range spec.Skipped {
+		graph.Nodes = append(graph.Nodes, GraphNode{ID: skipped.ID, Type: skipped.Type, Skipped: true, Reason: skipped.Reason})
+	}
+
+	// edges are only added between resources of the graph, a skipped resource is still a dependency of the deleted ones that use it
+	nodes := lo.SliceToMap(graph.Nodes, func(node GraphNode) (string, bool) { return node.ID, true })
+	addEdge := func(from, to string) {
+		edge := GraphEdge{From: from, To: to}
+		if from != "" && to != "" && nodes[from] && nodes[to] && !slices.Contains(graph.Edges, edge) {
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+	profiles := lo.SliceToMap(spec.InstanceProfiles, func(profile instanceprofiles.InstanceProfile) (string, string) {
+		return profile.Arn, profile.InstanceProfileName
+	})
+	for _, fleet := range spec.Fleets {
+		for _, launchTemplate := range spec.LaunchTemplates {
+			if fleet.ReferencesLaunchTemplate(lo.FromPtr(launchTemplate.LaunchTemplateId)) {
+				addEdge(lo.FromPtr(fleet.FleetId), lo.FromPtr(launchTemplate.LaunchTemplateId))
+			}
+		}
+	}
+	for _, instance := range spec.Instances {
+		instanceID := lo.FromPtr(instance.InstanceId)
+		addEdge(tagutils.EC2TagsToMap(instance.Tags)[fleets.FleetIDTagKey], instanceID)
+		addEdge(instanceID, lo.FromPtr(instance.SubnetId))
+		for _, group := range instance.SecurityGroups {
+			addEdge(instanceID, lo.FromPtr(group.GroupId))
+		}
+		if instance.IamInstanceProfile != nil {
+			addEdge(instanceID, profiles[lo.FromPtr(instance.IamInstanceProfile.Arn)])
+		}
+	}
+	for _, volume := range p.AttachedVolumes() {
+		addEdge(volume.InstanceID, volume.VolumeID)
+	}
+	for _, eip := range spec.ElasticIPs {
+		addEdge(lo.FromPtr(eip.InstanceId), lo.FromPtr(eip.AllocationId))
+	}
+	for _, natGateway := range spec.NATGateways {
+		natGatewayID := lo.FromPtr(natGateway.NatGatewayId)
+		for _, allocationID := range natGateway.AllocationIDs() {
+			addEdge(natGatewayID, allocationID)
+		}
+		addEdge(natGatewayID, lo.FromPtr(natGateway.SubnetId))
+		// NAT Gateways hold public addresses in the VPC, so its internet gateways are only detached once they are deleted
+		for _, igw := range spec.InternetGateways {
+			if slices.ContainsFunc(igw.Attachments, func(attachment ec2types.InternetGatewayAttachment) bool {
+				return lo.FromPtr(attachment.VpcId) == lo.FromPtr(natGateway.VpcId)
+			}) {
+				addEdge(natGatewayID, lo.FromPtr(igw.InternetGatewayId))
+			}
+		}
+	}
+	for _, securityGroup := range spec.SecurityGroups {
+		addEdge(lo.FromPtr(securityGroup.GroupId), lo.FromPtr(securityGroup.VpcId))
+	}
+	for _, igw := range spec.InternetGateways {
+		for _, attachment := range igw.Attachments {
+			addEdge(lo.FromPtr(igw.InternetGatewayId), lo.FromPtr(attachment.VpcId))
+		}
+	}
+	for _, eigw := range spec.EgressOnlyInternetGateways {
+		for _, attachment := range eigw.Attachments {
+			addEdge(lo.FromPtr(eigw.EgressOnlyInternetGatewayId), lo.FromPtr(attachment.VpcId))
+		}
+	}
+	for _, routeTable := range spec.RouteTables {
+		for _, association := range routeTable.Associations {
+			addEdge(lo.FromPtr(routeTable.RouteTableId), lo.FromPtr(association.SubnetId))
+		}
+		addEdge(lo.FromPtr(routeTable.RouteTableId), lo.FromPtr(routeTable.VpcId))
+	}
+	for _, subnet := range spec.Subnets {
+		addEdge(lo.FromPtr(subnet.SubnetId), lo.FromPtr(subnet.VpcId))
+	}
+	return graph
+}
+
+// DOT formats the graph in the Graphviz DOT language, e.g. to render it with `dot -Tsvg`.
+// The resources of a step are clustered together, and skipped resources are dashed and labeled with why they are kept.
+func (g DeletionGraph) DOT(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n", strconv.Quote(name))
+	b.WriteString("  rankdir=LR;\n  node [shape=box];\n")
+	steps := lo.Uniq(lo.Map(g.Nodes, func(node GraphNode, _ int) int { return node.Step }))
+	slices.Sort(steps)
+	for _, step := range steps {
+		if step == 0 {
+			b.WriteString("  subgraph cluster_skipped {\n    label=\"skipped\";\n    style=dashed;\n")
+		} else {
+			fmt.Fprintf(&b, "  subgraph cluster_step%d {\n    label=\"step %d\";\n", step, step)
+		}
+		for _, node := range lo.Filter(g.Nodes, func(node GraphNode, _ int) bool { return node.Step == step }) {
+			label := fmt.Sprintf("%s\n%s", node.Type, node.ID)
+			if node.Skipped {
+				fmt.Fprintf(&b, "    %s [label=%s, style=dashed];\n", strconv.Quote(node.ID), strconv.Quote(fmt.Sprintf("%s\n%s", label, node.Reason)))
+				continue
+			}
+			fmt.Fprintf(&b, "    %s [label=%s];\n", strconv.Quote(node.ID), strconv.Quote(label))
+		}
+		b.WriteString("  }\n")
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s;\n", strconv.Quote(edge.From), strconv.Quote(edge.To))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
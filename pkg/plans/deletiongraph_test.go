@@ -0,0 +1,57 @@
+package plans_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/samber/lo"
+)
+
+func TestDeletionGraph(t *testing.T) {
+	deletionPlan := testDeletionPlan()
+	deletionPlan.Spec.Fleets = []fleets.Fleet{{FleetData: ec2types.FleetData{FleetId: aws.String("fleet-1")}}}
+	deletionPlan.Spec.Instances[0].Tags = []ec2types.Tag{{Key: aws.String("aws:ec2:fleet-id"), Value: aws.String("fleet-1")}}
+	deletionPlan.Spec.Instances[0].SubnetId = aws.String("subnet-1")
+	deletionPlan.Spec.Instances[0].SecurityGroups = []ec2types.GroupIdentifier{{GroupId: aws.String("sg-1")}}
+	deletionPlan.Spec.SecurityGroups = []securitygroups.SecurityGroup{{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-1"), VpcId: aws.String("vpc-shared")}}}
+	deletionPlan.Spec.Subnets = []subnets.Subnet{{Subnet: ec2types.Subnet{SubnetId: aws.String("subnet-1"), VpcId: aws.String("vpc-shared")}}}
+	deletionPlan.Spec.Skipped = []plans.SkippedResource{{ID: "vpc-shared", Type: "VPC", Reason: "in use by i-2"}}
+
+	graph := deletionPlan.Graph()
+	steps := lo.SliceToMap(graph.Nodes, func(node plans.GraphNode) (string, int) { return node.ID, node.Step })
+	for id, step := range map[string]int{"fleet-1": 1, "i-1": 2, "sg-1": 3, "vol-standalone": 3, "subnet-1": 5, "vpc-shared": 0} {
+		if steps[id] != step {
+			t.Errorf("expected %s to be deleted in step %d, got %d", id, step, steps[id])
+		}
+	}
+	for _, edge := range []plans.GraphEdge{
+		{From: "fleet-1", To: "i-1"},
+		{From: "i-1", To: "subnet-1"},
+		{From: "i-1", To: "sg-1"},
+		{From: "i-1", To: "vol-standalone"},
+		{From: "sg-1", To: "vpc-shared"},
+		{From: "subnet-1", To: "vpc-shared"},
+	} {
+		if !slices.Contains(graph.Edges, edge) {
+			t.Errorf("expected edge %s -> %s, got %+v", edge.From, edge.To, graph.Edges)
+		}
+	}
+	// volumes that are deleted on termination are not resources of the plan
+	if slices.ContainsFunc(graph.Edges, func(edge plans.GraphEdge) bool { return edge.To == "vol-root" }) {
+		t.Errorf("expected no edge to the root volume, got %+v", graph.Edges)
+	}
+
+	dot := graph.DOT("dev/web")
+	for _, expected := range []string{`digraph "dev/web" {`, `"fleet-1" -> "i-1";`, `label="step 2";`, `"vpc-shared" [label="VPC\nvpc-shared\nin use by i-2", style=dashed];`} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("expected the DOT graph to contain %s, got:\n%s", expected, dot)
+		}
+	}
+}
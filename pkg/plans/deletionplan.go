@@ -1,13 +1,24 @@
 package plans
 
 import (
+	"fmt"
+	"slices"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/eips"
+	"github.com/bwagner5/nimbus/pkg/providers/fis"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
 	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
 	"github.com/bwagner5/nimbus/pkg/providers/instances"
 	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
 	"github.com/bwagner5/nimbus/pkg/providers/routetables"
 	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
 	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
 	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/samber/lo"
 )
 
 type DeletionPlan struct {
@@ -19,25 +30,163 @@ type DeletionPlan struct {
 type DeletionMetadata struct {
 	Namespace string
 	Name      string
+	// PlanID is set by Delete. It is a ULID that identifies the execution of the plan in log lines.
+	PlanID string
 }
 
 type DeletionSpec struct {
 	VPCs             []vpcs.VPC
 	Subnets          []subnets.Subnet
 	InternetGateways []igws.InternetGateway
-	RouteTables      []routetables.RouteTable
-	SecurityGroups   []securitygroups.SecurityGroup
-	LaunchTemplates  []launchtemplates.LaunchTemplate
+	// EgressOnlyInternetGateways route the IPv6 traffic of the private subnets of IPv6 networks
+	EgressOnlyInternetGateways []igws.EgressOnlyInternetGateway
+	RouteTables                []routetables.RouteTable
+	NATGateways                []natgws.NATGateway
+	ElasticIPs                 []eips.ElasticIP
+	SecurityGroups             []securitygroups.SecurityGroup
+	LaunchTemplates            []launchtemplates.LaunchTemplate
+	// Fleets are the active fleets of the plan, they are deleted first so that maintain and request fleets do not replace the terminated instances
+	Fleets           []fleets.Fleet
 	Instances        []instances.Instance
+	Volumes          []volumes.Volume
+	InstanceProfiles []instanceprofiles.InstanceProfile
+	// ExperimentTemplates are the FIS experiment templates of the plan, their running experiments are stopped before they are deleted
+	ExperimentTemplates []fis.ExperimentTemplate
+	// Skipped lists resources that matched the plan but are excluded because they are still in use outside of the plan
+	Skipped []SkippedResource
+	// SkipStragglers completes the deletion without waiting for instances that are still shutting down or stopping after they were forced to
+	// terminate. The resources that depend on them are left in place and deleted by running the deletion again once they terminate.
+	SkipStragglers bool
+	// FinalSnapshot snapshots every volume attached to the instances before they are terminated. The snapshots are tagged with the
+	// namespace, name, device, and the ID of the executed deletion plan, so that the volumes can be restored from them later.
+	FinalSnapshot bool
 }
 
 type DeletionStatus struct {
 	// Deletion status maps a resource-id to a bool representing that the resource has been deleted.
-	VPCs             map[string]bool
-	Subnets          map[string]bool
-	InternetGateways map[string]bool
-	RouteTables      map[string]bool
-	SecurityGroups   map[string]bool
-	Instances        map[string]bool
-	LaunchTemplates  map[string]bool
+	VPCs                       map[string]bool
+	Subnets                    map[string]bool
+	InternetGateways           map[string]bool
+	EgressOnlyInternetGateways map[string]bool
+	RouteTables                map[string]bool
+	NATGateways                map[string]bool
+	// ElasticIPs is keyed by the allocation ID
+	ElasticIPs      map[string]bool
+	SecurityGroups  map[string]bool
+	Fleets          map[string]bool
+	Instances       map[string]bool
+	LaunchTemplates map[string]bool
+	Volumes         map[string]bool
+	// InstanceProfiles is keyed by the instance profile name
+	InstanceProfiles    map[string]bool
+	ExperimentTemplates map[string]bool
+	// Skipped lists resources that were intentionally left in place and why
+	Skipped []SkippedResource
+	// Snapshots maps the volume-id of every volume with a final snapshot to the snapshot-id
+	Snapshots map[string]string
+	// EstimatedCost is what the running instances of the plan cost, which their termination saves
+	EstimatedCost InstancesCost
+	// Conditions record the progress of the deletion, the steps are ExperimentsDeleted, FleetsDeleted, SnapshotsCreated, InstancesTerminated, VolumesDeleted, SecurityGroupsDeleted, NetworkDeleted, LaunchTemplatesDeleted, and InstanceProfilesDeleted
+	Conditions Conditions
+}
+
+// SkippedResource is a resource in a DeletionPlan that was not deleted
+type SkippedResource struct {
+	ID     string `table:"ID"`
+	Type   string `table:"Type"`
+	Reason string `table:"Reason"`
+}
+
+const (
+	// KeepDataVolumes keeps every attached volume except the root volumes of the instances
+	KeepDataVolumes = "data"
+	// KeepAllVolumes keeps every attached volume
+	KeepAllVolumes = "all"
+)
+
+// AttachedVolume is an EBS volume attached to an instance of a DeletionPlan
+type AttachedVolume struct {
+	InstanceID string `table:"Instance"`
+	DeviceName string `table:"Device"`
+	VolumeID   string `table:"Volume"`
+	Root       bool   `table:"Root"`
+	// Standalone volumes are not deleted on termination but by the plan once the instances are terminated
+	Standalone bool `table:"Standalone"`
+	// DeleteOnTermination is true if EC2 deletes the volume when the instance is terminated
+	DeleteOnTermination bool `table:"Delete-On-Termination"`
+}
+
+// AttachedVolumes returns the EBS volumes attached to the plan's instances
+func (p DeletionPlan) AttachedVolumes() []AttachedVolume {
+	standalone := lo.SliceToMap(p.Spec.Volumes, func(volume volumes.Volume) (string, bool) { return lo.FromPtr(volume.VolumeId), true })
+	var attached []AttachedVolume
+	for _, instance := range p.Spec.Instances {
+		for _, mapping := range instance.BlockDeviceMappings {
+			if mapping.Ebs == nil {
+				continue
+			}
+			attached = append(attached, AttachedVolume{
+				InstanceID:          lo.FromPtr(instance.InstanceId),
+				DeviceName:          lo.FromPtr(mapping.DeviceName),
+				VolumeID:            lo.FromPtr(mapping.Ebs.VolumeId),
+				Root:                mapping.DeviceName != nil && lo.FromPtr(mapping.DeviceName) == lo.FromPtr(instance.RootDeviceName),
+				Standalone:          standalone[lo.FromPtr(mapping.Ebs.VolumeId)],
+				DeleteOnTermination: lo.FromPtr(mapping.Ebs.DeleteOnTermination),
+			})
+		}
+	}
+	return attached
+}
+
+// VolumesDeletedOnTermination returns the EBS volumes attached to the plan's instances that are deleted with them, e.g. their root volumes.
+// The plan's standalone Volumes are not included.
+func (p DeletionPlan) VolumesDeletedOnTermination() []AttachedVolume {
+	return lo.Filter(p.AttachedVolumes(), func(volume AttachedVolume, _ int) bool { return volume.DeleteOnTermination && !volume.Standalone })
+}
+
+// VolumesToKeep returns the attached volumes that would be deleted by the plan and that keep selects: data for every volume except the
+// root volumes, all for every volume, or the device names and volume IDs of the volumes.
+// An error is returned if a device name or volume ID does not match an attached volume.
+func (p DeletionPlan) VolumesToKeep(keep []string) ([]AttachedVolume, error) {
+	deleted := lo.Filter(p.AttachedVolumes(), func(volume AttachedVolume, _ int) bool { return volume.DeleteOnTermination || volume.Standalone })
+	var kept []AttachedVolume
+	for _, k := range keep {
+		var matches []AttachedVolume
+		switch k {
+		case KeepDataVolumes:
+			matches = lo.Filter(deleted, func(volume AttachedVolume, _ int) bool { return !volume.Root })
+		case KeepAllVolumes:
+			matches = deleted
+		default:
+			matches = lo.Filter(deleted, func(volume AttachedVolume, _ int) bool { return volume.DeviceName == k || volume.VolumeID == k })
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no attached volume that would be deleted has the device name or volume ID %s", k)
+			}
+		}
+		kept = append(kept, matches...)
+	}
+	return lo.UniqBy(kept, func(volume AttachedVolume) string { return volume.VolumeID }), nil
+}
+
+// KeepVolumes returns the plan without the deletion of the kept volumes. Their instances' block device mappings no longer delete them on
+// termination, and kept standalone volumes are skipped. The DeleteOnTermination attribute of the instances is changed by vm.KeepVolumes.
+func (p DeletionPlan) KeepVolumes(kept []AttachedVolume) DeletionPlan {
+	keptIDs := lo.SliceToMap(kept, func(volume AttachedVolume) (string, bool) { return volume.VolumeID, true })
+	p.Spec.Instances = lo.Map(p.Spec.Instances, func(instance instances.Instance, _ int) instances.Instance {
+		instance.BlockDeviceMappings = lo.Map(instance.BlockDeviceMappings, func(mapping ec2types.InstanceBlockDeviceMapping, _ int) ec2types.InstanceBlockDeviceMapping {
+			if mapping.Ebs != nil && keptIDs[lo.FromPtr(mapping.Ebs.VolumeId)] {
+				ebs := *mapping.Ebs
+				ebs.DeleteOnTermination = lo.ToPtr(false)
+				mapping.Ebs = &ebs
+			}
+			return mapping
+		})
+		return instance
+	})
+	var skipped []volumes.Volume
+	p.Spec.Volumes, skipped = lo.FilterReject(p.Spec.Volumes, func(volume volumes.Volume, _ int) bool { return !keptIDs[lo.FromPtr(volume.VolumeId)] })
+	p.Spec.Skipped = append(slices.Clone(p.Spec.Skipped), lo.Map(skipped, func(volume volumes.Volume, _ int) SkippedResource {
+		return SkippedResource{ID: lo.FromPtr(volume.VolumeId), Type: "Volume", Reason: "kept instead of deleted"}
+	})...)
+	return p
 }
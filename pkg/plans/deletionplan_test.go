@@ -0,0 +1,96 @@
+package plans_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
+	"github.com/samber/lo"
+)
+
+func testDeletionPlan() plans.DeletionPlan {
+	return plans.DeletionPlan{Spec: plans.DeletionSpec{
+		Instances: []instances.Instance{{Instance: ec2types.Instance{
+			InstanceId:     aws.String("i-1"),
+			RootDeviceName: aws.String("/dev/xvda"),
+			BlockDeviceMappings: []ec2types.InstanceBlockDeviceMapping{
+				{DeviceName: aws.String("/dev/xvda"), Ebs: &ec2types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-root"), DeleteOnTermination: aws.Bool(true)}},
+				{DeviceName: aws.String("/dev/sdf"), Ebs: &ec2types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-data"), DeleteOnTermination: aws.Bool(true)}},
+				{DeviceName: aws.String("/dev/sdg"), Ebs: &ec2types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-kept"), DeleteOnTermination: aws.Bool(false)}},
+				{DeviceName: aws.String("/dev/sdh"), Ebs: &ec2types.EbsInstanceBlockDevice{VolumeId: aws.String("vol-standalone"), DeleteOnTermination: aws.Bool(false)}},
+			},
+		}}},
+		Volumes: []volumes.Volume{{Volume: ec2types.Volume{VolumeId: aws.String("vol-standalone")}}},
+	}}
+}
+
+func TestAttachedVolumes(t *testing.T) {
+	attached := testDeletionPlan().AttachedVolumes()
+	expected := []plans.AttachedVolume{
+		{InstanceID: "i-1", DeviceName: "/dev/xvda", VolumeID: "vol-root", Root: true, DeleteOnTermination: true},
+		{InstanceID: "i-1", DeviceName: "/dev/sdf", VolumeID: "vol-data", DeleteOnTermination: true},
+		{InstanceID: "i-1", DeviceName: "/dev/sdg", VolumeID: "vol-kept"},
+		{InstanceID: "i-1", DeviceName: "/dev/sdh", VolumeID: "vol-standalone", Standalone: true},
+	}
+	if !slices.Equal(attached, expected) {
+		t.Errorf("expected %+v, got %+v", expected, attached)
+	}
+	terminated := lo.Map(testDeletionPlan().VolumesDeletedOnTermination(), func(volume plans.AttachedVolume, _ int) string { return volume.VolumeID })
+	if !slices.Equal(terminated, []string{"vol-root", "vol-data"}) {
+		t.Errorf("expected the root and data volumes to be deleted on termination, got %v", terminated)
+	}
+}
+
+func TestVolumesToKeep(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		keep        []string
+		expected    []string
+		expectedErr bool
+	}{
+		{name: "none"},
+		{name: "data", keep: []string{plans.KeepDataVolumes}, expected: []string{"vol-data", "vol-standalone"}},
+		{name: "all", keep: []string{plans.KeepAllVolumes}, expected: []string{"vol-root", "vol-data", "vol-standalone"}},
+		{name: "device and volume ID", keep: []string{"/dev/sdf", "vol-data", "vol-root"}, expected: []string{"vol-data", "vol-root"}},
+		{name: "volume that is not deleted", keep: []string{"vol-kept"}, expectedErr: true},
+		{name: "unknown device", keep: []string{"/dev/sdz"}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			kept, err := testDeletionPlan().VolumesToKeep(tc.keep)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ids := lo.Map(kept, func(volume plans.AttachedVolume, _ int) string { return volume.VolumeID }); !slices.Equal(ids, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, ids)
+			}
+		})
+	}
+}
+
+func TestKeepVolumes(t *testing.T) {
+	deletionPlan := testDeletionPlan()
+	kept, err := deletionPlan.VolumesToKeep([]string{plans.KeepDataVolumes})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keptPlan := deletionPlan.KeepVolumes(kept)
+	if terminated := keptPlan.VolumesDeletedOnTermination(); len(terminated) != 1 || terminated[0].VolumeID != "vol-root" {
+		t.Errorf("expected only the root volume to be deleted on termination, got %+v", terminated)
+	}
+	if len(keptPlan.Spec.Volumes) != 0 || len(keptPlan.Spec.Skipped) != 1 || keptPlan.Spec.Skipped[0].ID != "vol-standalone" {
+		t.Errorf("expected the standalone volume to be skipped, got %+v and %+v", keptPlan.Spec.Volumes, keptPlan.Spec.Skipped)
+	}
+	if len(deletionPlan.VolumesDeletedOnTermination()) != 2 {
+		t.Errorf("expected the original plan to be unchanged")
+	}
+}
@@ -1,17 +1,74 @@
 package plans
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/artifacts"
+	"github.com/bwagner5/nimbus/pkg/bytesize"
 	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
 	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
 	"github.com/bwagner5/nimbus/pkg/providers/instances"
 	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/keypairs"
+	"github.com/bwagner5/nimbus/pkg/providers/kmskeys"
 	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/logs"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
+	"github.com/bwagner5/nimbus/pkg/providers/reservations"
 	"github.com/bwagner5/nimbus/pkg/providers/routetables"
 	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
 	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
 	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/samber/lo"
 )
 
+const (
+	// EBSKMSKeyDefault encrypts volumes with the account's default EBS key
+	EBSKMSKeyDefault = "default"
+
+	// checksumLength is the number of hex characters of a spec checksum
+	checksumLength = 10
+
+	// NetworkPolicyShared creates one network per namespace that is reused by every name in the namespace
+	NetworkPolicyShared = "shared"
+	// NetworkPolicyIsolated creates a network for each name that is deleted with the name
+	NetworkPolicyIsolated = "isolated"
+
+	// NetworkTopologyPublic creates public subnets and launches instances with public IPs into them
+	NetworkTopologyPublic = "public"
+	// NetworkTopologyPrivate also creates private subnets that reach the internet through a NAT Gateway and launches instances into them
+	NetworkTopologyPrivate = "private"
+	// NetworkTopologyPublicPrivate creates the network of NetworkTopologyPrivate but launches instances into its public subnets
+	NetworkTopologyPublicPrivate = "public-private"
+
+	// GPUDriversInstall installs the NVIDIA driver and CUDA toolkit at boot with a user-data fragment, it requires Amazon Linux 2023 AMIs
+	GPUDriversInstall = "install"
+	// GPUDriversDLAMI launches the Deep Learning Base AMI, which has the NVIDIA driver and CUDA toolkit preinstalled
+	GPUDriversDLAMI = "dlami"
+)
+
+// Artifacts are local files staged in S3 under the namespace-scoped prefix of the plan, see the artifacts package
+type Artifacts struct {
+	// Inputs are local files or directories that instances download to /var/lib/nimbus/inputs
+	Inputs []string
+	// Mode is presigned, which injects pre-signed URLs into the user-data, or sync, which injects aws s3 sync commands
+	// that require the instances' IAM role to allow the bucket. Defaults to presigned.
+	Mode string
+	// Bucket is the bucket to stage the artifacts in, defaults to a bucket that nimbus creates for the account and region
+	Bucket string
+}
+
 type LaunchPlan struct {
 	Metadata LaunchMetadata
 	Spec     LaunchSpec
@@ -21,16 +78,349 @@ type LaunchPlan struct {
 type LaunchMetadata struct {
 	Namespace string
 	Name      string
+	// Generation is set by Launch. It is the generation of the running instances of namespace/name if they were launched with the same spec,
+	// otherwise it is incremented so that instances from earlier launches are reported as out of date.
+	Generation int64
+	// PlanID is set by Launch. It is a ULID that identifies the execution of the plan in the tags of launched instances, log lines, and events.
+	PlanID string
 }
 
 type LaunchSpec struct {
+	// Count is the number of instances to launch in a single fleet request, defaults to 1.
+	// It is the default count of node groups that do not specify their own.
+	Count int32
+	// Capacity is the target capacity in vCPUs or memory that a fleet mixes instance sizes to reach, instead of Count instances.
+	// It is the default capacity of node groups that do not specify their own count or capacity.
+	Capacity               Capacity
 	CapacityType           string
 	InstanceTypeSelectors  []instancetypes.Selector
 	SubnetSelectors        []subnets.Selector
 	SecurityGroupSelectors []securitygroups.Selector
 	AMISelectors           []amis.Selector
-	IAMRole                string
-	UserData               string
+	// IngressRules are authorized on the security group that nimbus creates for the plan, which has no ingress rules otherwise.
+	// They can not be used with SecurityGroupSelectors or reference node groups, see NodeGroup.IngressRules.
+	IngressRules []securitygroups.IngressRule
+	// IAMRole is the name or ARN of an existing role that instances assume, nimbus creates an instance profile for it
+	IAMRole string
+	// UserData is rendered as a template with the plan's metadata, see userdata.Metadata
+	UserData string
+	// UserDataVars are the variables that user-data templates reference as .Vars
+	UserDataVars map[string]string
+	// Artifacts are local files that are staged in S3 and downloaded by the instances at boot, before the rest of the user-data runs
+	Artifacts Artifacts
+	// ShipLogs installs the CloudWatch agent at boot to ship the instances' cloud-init, nimbus, and job logs to a log group.
+	// The log group is created if it does not exist and the instances' IAM role, which is required, is allowed to ship to it.
+	ShipLogs logs.Destination
+	// KeyName is the name of the EC2 key pair that instances are launched with for SSH access
+	KeyName string
+	// KeyPairSelectors select the key pair that instances are launched with instead of KeyName, they must match exactly one key pair
+	KeyPairSelectors []keypairs.Selector
+	// MetadataOptions configure the instance metadata service of the instances, they default to requiring IMDSv2
+	MetadataOptions launchtemplates.MetadataOptions
+	// NetworkInterfaces replace the instances' default network interface, e.g. to attach Elastic Fabric Adapters (EFA) on every
+	// network card of ML and HPC instance types, associate public IPs, or use different security groups per interface
+	NetworkInterfaces []launchtemplates.NetworkInterface
+	// PreferReservations launches on-demand instances into instance types and availability zones with unused reserved instances,
+	// and into instance families with EC2 Instance Savings Plans, first, so that committed spend is used before paying on-demand rates.
+	// Compute Savings Plans apply to every instance type, so they do not change the preference.
+	PreferReservations bool
+	// Allocation tunes how the fleets allocate their capacity, e.g. an on-demand base with spot above it.
+	// It is the default allocation of node groups that do not specify their own.
+	Allocation Allocation
+	// TTL is how long instances live before they terminate themselves, zero does not expire.
+	// A shutdown is scheduled by the user-data at boot and instances are launched with the terminate shutdown behavior,
+	// so it only applies to shell script user-data. Instances are tagged with their expiry.
+	TTL time.Duration
+	// Replace terminates the instances of namespace/name that were launched with an earlier spec once their replacements are launched.
+	// Otherwise a launch with a changed spec only creates the launch templates of the spec and keeps the existing instances.
+	// It does not change the spec's checksum.
+	Replace bool
+	// WaitForBootstrap waits after the launch for instances to be running, registered with SSM, and passing their node group's readiness probe,
+	// and records how long each took in the status timings
+	WaitForBootstrap bool
+	// GPUDrivers is install or dlami and sets up NVIDIA drivers on node groups with NVIDIA GPU instance types, empty leaves the AMIs as they are.
+	// dlami replaces the AMI selectors of those node groups. With WaitForBootstrap, instances are verified to run nvidia-smi over SSM.
+	GPUDrivers string
+	// TimingMetricsNamespace is the CloudWatch namespace that the launch's phase timings are published to as custom metrics, empty does not publish them
+	TimingMetricsNamespace string
+	// UseDefaultVPC launches into the account's default VPC and subnets instead of creating network infrastructure when no SubnetSelectors are specified
+	UseDefaultVPC bool
+	// NetworkPolicy is shared or isolated and determines whether a created network is reused by other names in the namespace.
+	// Defaults to shared. It only applies when the network is created by nimbus.
+	NetworkPolicy string
+	// NetworkTopology is public, private, or public-private and determines which subnets a network created by nimbus has
+	// and which of them instances are launched into. Defaults to public. Private subnets are added to an existing public network when needed.
+	NetworkTopology string
+	// Network is the layout of the VPC, subnets, and availability zones of a network created by nimbus
+	Network NetworkSpec
+	// Naming is a text/template that names the launch templates and security groups created for the plan,
+	// e.g. "{{.Namespace}}-{{.Name}}-{{.Random}}". Defaults to namespace/name or namespace/name/group for node groups,
+	// and launch template names are suffixed with a hash of their spec.
+	Naming string
+	// RootVolume configures the root EBS volume of every instance. The device name is taken from the AMIs,
+	// and the volume type defaults to gp3 instead of the AMI's volume type.
+	RootVolume launchtemplates.BlockDevice
+	// BlockDeviceMappings are EBS volumes attached to every instance in addition to the root volume.
+	// A mapping with the device name "root" configures the root volume instead of RootVolume.
+	BlockDeviceMappings []launchtemplates.BlockDevice
+	// Volumes are standalone EBS volumes that are created in the availability zone of every instance and attached at their DeviceName.
+	// Unlike BlockDeviceMappings, they are not deleted when instances terminate but by the deletion plan.
+	Volumes []launchtemplates.BlockDevice
+	// EBSKMSKey is the KMS key that encrypts every volume created for the plan: an alias prefixed with alias/, a key ARN, or a key ID.
+	// Volumes are encrypted by default, with the account's default EBS key if EBSKMSKey is empty or "default".
+	EBSKMSKey string
+	// Tags are applied to the launched instances in addition to the tags that nimbus manages
+	Tags map[string]string
+	// CompliancePolicy is checked against the plan before any resources are created
+	CompliancePolicy CompliancePolicy
+	// Placements pins instances to subnets or availability zones by index, i.e. Placements[0] is where instance 0 is launched.
+	// Each placement is launched as its own fleet so that distribution is deterministic.
+	Placements []Placement
+	// NodeGroups launches multiple named groups of instances that share the plan's network, e.g. a controller and workers.
+	// If no NodeGroups are specified, the plan launches Count instances from the LaunchSpec.
+	NodeGroups []NodeGroup
+	// Wait blocks the launch until the launched instances are running, pass their status checks, or are managed by SSM.
+	// Like Replace, it only affects how the spec is launched.
+	Wait Wait
+}
+
+// NodeGroup is a named group of instances within a plan with its own launch template and fleet.
+// Unset fields default to the corresponding LaunchSpec field.
+type NodeGroup struct {
+	Name                  string
+	Count                 int32
+	Capacity              Capacity
+	CapacityType          string
+	InstanceTypeSelectors []instancetypes.Selector
+	AMISelectors          []amis.Selector
+	IAMRole               string
+	// UserData is rendered as a template with the plan's metadata and the metadata of the DependsOn groups if DependsOn is specified
+	UserData string
+	// DependsOn are the names of node groups that must be launched and ready before this group is launched
+	DependsOn []string
+	// ReadinessProbe determines when the group is ready for its dependents to launch
+	ReadinessProbe ReadinessProbe
+	// IngressRules are authorized on a security group created for the node group.
+	// If any node group has IngressRules, every node group gets its own security group so rules can reference other groups.
+	IngressRules []securitygroups.IngressRule
+	// Allocation tunes how the group's fleet allocates its capacity, e.g. spot workers that rebalance next to on-demand controllers
+	Allocation Allocation
+}
+
+// ReadinessProbe is checked against a node group's instances before launching dependent groups.
+// Instances are always required to pass EC2 status checks.
+type ReadinessProbe struct {
+	// Port is an optional TCP port that must accept connections on every instance
+	Port int32
+	// Timeout is how long to wait for the group to become ready
+	Timeout time.Duration
+}
+
+// Checksum returns a short checksum of the spec that changes whenever the spec changes, except for Replace and Wait which only affect how the spec is launched
+func (s LaunchSpec) Checksum() string {
+	s.Replace = false
+	s.Wait = Wait{}
+	// LaunchSpec only contains JSON encodable types, and maps are encoded with sorted keys
+	specJSON, _ := json.Marshal(s)
+	checksum := sha256.Sum256(specJSON)
+	return hex.EncodeToString(checksum[:])[:checksumLength]
+}
+
+// EffectiveNodeGroups returns the plan's node groups with unset fields defaulted from the LaunchSpec.
+// A plan without node groups returns a single unnamed group that launches Count instances.
+func (s LaunchSpec) EffectiveNodeGroups() []NodeGroup {
+	if len(s.NodeGroups) == 0 {
+		return []NodeGroup{{
+			Count:                 lo.Ternary(s.Count == 0, 1, s.Count),
+			Capacity:              s.Capacity,
+			CapacityType:          s.CapacityType,
+			Allocation:            s.Allocation,
+			InstanceTypeSelectors: s.InstanceTypeSelectors,
+			AMISelectors:          s.AMISelectors,
+			IAMRole:               s.IAMRole,
+			UserData:              s.UserData,
+		}}
+	}
+	groups := make([]NodeGroup, 0, len(s.NodeGroups))
+	for _, group := range s.NodeGroups {
+		if group.Count == 0 && group.Capacity.Value == 0 {
+			group.Count = lo.Ternary(s.Count == 0, 1, s.Count)
+			group.Capacity = s.Capacity
+		}
+		if group.CapacityType == "" {
+			group.CapacityType = s.CapacityType
+		}
+		if group.Allocation == (Allocation{}) {
+			group.Allocation = s.Allocation
+		}
+		if len(group.InstanceTypeSelectors) == 0 {
+			group.InstanceTypeSelectors = s.InstanceTypeSelectors
+		}
+		if len(group.AMISelectors) == 0 {
+			group.AMISelectors = s.AMISelectors
+		}
+		if group.IAMRole == "" {
+			group.IAMRole = s.IAMRole
+		}
+		if group.UserData == "" {
+			group.UserData = s.UserData
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// ValidateNodeGroups checks that node groups are uniquely named and compatible with the rest of the LaunchSpec
+func (s LaunchSpec) ValidateNodeGroups() error {
+	if len(s.NodeGroups) == 0 {
+		return nil
+	}
+	if len(s.Placements) != 0 {
+		return fmt.Errorf("placements are not supported with node groups")
+	}
+	names := map[string]bool{}
+	for i, group := range s.NodeGroups {
+		if group.Name == "" {
+			return fmt.Errorf("node group %d does not have a name", i)
+		}
+		if names[group.Name] {
+			return fmt.Errorf("node group %s is specified more than once", group.Name)
+		}
+		if group.Count < 0 {
+			return fmt.Errorf("node group %s has a negative count", group.Name)
+		}
+		if group.Count != 0 && group.Capacity.Value != 0 {
+			return fmt.Errorf("node group %s specifies both a count and a capacity", group.Name)
+		}
+		names[group.Name] = true
+	}
+	for _, group := range s.NodeGroups {
+		for _, dependency := range group.DependsOn {
+			if !names[dependency] {
+				return fmt.Errorf("node group %s depends on unknown node group %s", group.Name, dependency)
+			}
+		}
+		for _, rule := range group.IngressRules {
+			if rule.Group != "" && !names[rule.Group] {
+				return fmt.Errorf("node group %s has an ingress rule from unknown node group %s", group.Name, rule.Group)
+			}
+		}
+	}
+	return nil
+}
+
+// OrderNodeGroups returns the node groups in launch order so that every group comes after the groups it depends on.
+// Groups without dependencies between them keep their relative order. Dependency cycles are an error.
+func OrderNodeGroups(groups []NodeGroup) ([]NodeGroup, error) {
+	ordered := make([]NodeGroup, 0, len(groups))
+	launched := map[string]bool{}
+	for len(ordered) < len(groups) {
+		progressed := false
+		for _, group := range groups {
+			if launched[group.Name] {
+				continue
+			}
+			if lo.EveryBy(group.DependsOn, func(dependency string) bool { return launched[dependency] }) {
+				ordered = append(ordered, group)
+				launched[group.Name] = true
+				progressed = true
+			}
+		}
+		if !progressed {
+			pending := lo.FilterMap(groups, func(group NodeGroup, _ int) (string, bool) { return group.Name, !launched[group.Name] })
+			return nil, fmt.Errorf("node groups have a dependency cycle: %v", pending)
+		}
+	}
+	return ordered, nil
+}
+
+// Capacity is a fleet's target capacity
+type Capacity struct {
+	Value int32
+	// Unit is instances, vcpu, or memory-mib, defaults to instances
+	Unit string
+}
+
+// ParseCapacity parses a target capacity: a number of instances like 4, vCPUs like 64vcpu, or memory like 256GiB,
+// which is rounded up to whole MiB. An empty string is no capacity.
+func ParseCapacity(capacityStr string) (Capacity, error) {
+	capacityStr = strings.TrimSpace(capacityStr)
+	if capacityStr == "" {
+		return Capacity{}, nil
+	}
+	if vcpus, ok := strings.CutSuffix(strings.TrimSuffix(strings.ToLower(capacityStr), "s"), "vcpu"); ok {
+		value, err := strconv.ParseInt(strings.TrimSpace(vcpus), 10, 32)
+		if err != nil || value <= 0 {
+			return Capacity{}, fmt.Errorf("invalid capacity %q, expected a positive number of vCPUs like 64vcpu", capacityStr)
+		}
+		return Capacity{Value: int32(value), Unit: fleets.CapacityUnitVCPU}, nil
+	}
+	if instances, err := strconv.ParseInt(capacityStr, 10, 32); err == nil {
+		if instances <= 0 {
+			return Capacity{}, fmt.Errorf("invalid capacity %q, expected a positive number of instances", capacityStr)
+		}
+		return Capacity{Value: int32(instances), Unit: fleets.CapacityUnitInstances}, nil
+	}
+	memory, err := bytesize.Parse(capacityStr)
+	if err != nil {
+		return Capacity{}, fmt.Errorf("invalid capacity %q, expected instances like 4, vCPUs like 64vcpu, or memory like 256GiB", capacityStr)
+	}
+	mib := math.Ceil(memory.Mebibytes())
+	if mib <= 0 || mib > math.MaxInt32 {
+		return Capacity{}, fmt.Errorf("invalid capacity %q, memory must be between 1MiB and %dMiB", capacityStr, math.MaxInt32)
+	}
+	return Capacity{Value: int32(mib), Unit: fleets.CapacityUnitMemoryMiB}, nil
+}
+
+// Placement constrains where a single instance is launched.
+// SubnetID and AvailabilityZone are AND'd if both are specified.
+type Placement struct {
+	SubnetID         string
+	AvailabilityZone string
+}
+
+// ParsePlacements parses a placement map where each selector term is the placement of the instance at that index
+//
+// Example:
+//
+//	"az:us-west-2a;az:us-west-2b;subnet:subnet-0123456"
+//
+// places instance 0 in us-west-2a, instance 1 in us-west-2b, and instance 2 in subnet-0123456
+func ParsePlacements(placementStr string) ([]Placement, error) {
+	terms, err := selectors.ParseSelectorsTokens(placementStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse placements: %w", err)
+	}
+	placements := make([]Placement, 0, len(terms))
+	for i, term := range terms {
+		if len(term.Tags) != 0 {
+			return nil, fmt.Errorf("invalid placement %d: tags are not supported", i)
+		}
+		placement := Placement{}
+		for k, v := range term.KeyVals {
+			switch k {
+			case "subnet", "subnet-id":
+				placement.SubnetID = v
+			case "az", "zone":
+				placement.AvailabilityZone = v
+			default:
+				return nil, fmt.Errorf("invalid placement key for instance %d: %s", i, k)
+			}
+		}
+		placements = append(placements, placement)
+	}
+	return placements, nil
+}
+
+// Matches returns true if the subnet satisfies the placement constraints
+func (p Placement) Matches(subnet subnets.Subnet) bool {
+	if p.SubnetID != "" && p.SubnetID != *subnet.SubnetId {
+		return false
+	}
+	if p.AvailabilityZone != "" && p.AvailabilityZone != *subnet.AvailabilityZone {
+		return false
+	}
+	return true
 }
 
 type LaunchStatus struct {
@@ -38,9 +428,93 @@ type LaunchStatus struct {
 	Subnets         []subnets.Subnet
 	RouteTables     []routetables.RouteTable
 	InternetGateway igws.InternetGateway
-	SecurityGroups  []securitygroups.SecurityGroup
-	AMIs            []amis.AMI
-	InstanceTypes   []instancetypes.InstanceType
-	Instances       []instances.Instance
-	LaunchTemplate  launchtemplates.LaunchTemplate
+	// NATGateway routes the internet traffic of private subnets, it is only created for the private and public-private network topologies
+	NATGateway natgws.NATGateway
+	// EgressOnlyInternetGateway routes the IPv6 internet traffic of private subnets, it is only created for dual-stack networks with private subnets
+	EgressOnlyInternetGateway igws.EgressOnlyInternetGateway
+	SecurityGroups            []securitygroups.SecurityGroup
+	AMIs                      []amis.AMI
+	// ArchitectureAMIs maps each architecture that instances are launched with to the ID of its AMI, e.g. an alias resolves to an AMI per architecture
+	ArchitectureAMIs map[string]string
+	InstanceTypes    []instancetypes.InstanceType
+	Instances        []instances.Instance
+	// LaunchFailures are the errors that EC2 Fleet returned for the overrides it could not launch, e.g. insufficient capacity in a zone.
+	// They are recorded for every node group, a fleet can return failures and still launch its target capacity from other overrides.
+	LaunchFailures []fleets.LaunchFailure
+	LaunchTemplate launchtemplates.LaunchTemplate
+	// LaunchTemplateVersion is the version of the LaunchTemplate that instances are launched from
+	LaunchTemplateVersion int64
+	InstanceProfile       instanceprofiles.InstanceProfile
+	// Role is the resolved IAM role of the instances
+	Role instanceprofiles.Role
+	// NodeGroups is the per-group status of a plan with node groups.
+	// Instances includes the instances of every group, while AMIs, ArchitectureAMIs, InstanceTypes, LaunchTemplate, LaunchTemplateVersion, InstanceProfile, and Role
+	// are only set for plans without node groups.
+	NodeGroups []NodeGroupStatus
+	// Conditions record the progress of the launch, the steps are AMIsResolved, NetworkReady, FleetLaunched, and InstancesRunning
+	Conditions Conditions
+	// EBSKMSKey is the resolved EBSKMSKey of the spec, it is empty when volumes are encrypted with the account's default EBS key
+	EBSKMSKey kmskeys.Key
+	// BlockDeviceMappings are the spec's block device mappings with their KMS keys resolved to ARNs
+	BlockDeviceMappings []launchtemplates.BlockDevice
+	// Volumes are the standalone volumes created and attached to the launched instances
+	Volumes []volumes.Volume
+	// KeyPair is the resolved key pair of the spec, it is empty when instances are launched without one
+	KeyPair keypairs.KeyPair
+	// Reservations is the unused reserved instance capacity, it is only resolved when the spec prefers reservations
+	Reservations reservations.Coverage
+	// Reconciliation is how the launch converged the existing instances of namespace/name to the spec
+	Reconciliation Reconciliation
+	// Artifacts is where the spec's artifacts were staged, it is empty if the spec has none
+	Artifacts artifacts.Staging
+	// LogGroup is the log group that instances ship their logs to, it is empty if the spec does not ship logs
+	LogGroup logs.LogGroup
+	// EstimatedCost is what the plan's instances and volumes cost to run, it is only estimated for dry-runs and verbose launches
+	EstimatedCost EstimatedCost
+	// Timings are the durations of the launch's phases. Running, SSMReady, and ProbePassed are only recorded when the spec waits for bootstrap.
+	Timings Timings
+	// Violations are the ways the plan does not comply with the spec's CompliancePolicy, nothing is launched if there are any
+	Violations []Violation
+	// DryRun is true if the plan was resolved without creating anything, a dry-run plan can be saved and applied later
+	DryRun bool
+	// Region is the region the plan was resolved and launched in
+	Region string
+	// PlannedResources are the resources that a dry-run launch would have created
+	PlannedResources []PlannedResource
+	// SpecChecksum is the checksum of the spec that was launched, instances are tagged with it along with the plan generation
+	SpecChecksum string
+}
+
+// NodeGroupStatus is the resolved and launched resources of a single node group
+type NodeGroupStatus struct {
+	Name string
+	AMIs []amis.AMI
+	// ArchitectureAMIs maps each architecture that the group's instances are launched with to the ID of its AMI
+	ArchitectureAMIs map[string]string
+	InstanceTypes    []instancetypes.InstanceType
+	LaunchTemplate   launchtemplates.LaunchTemplate
+	// LaunchTemplateVersion is the version of the LaunchTemplate that the group's instances are launched from,
+	// a launch template gets a new version when a changed spec is launched with a name that has no spec hash
+	LaunchTemplateVersion int64
+	Instances             []instances.Instance
+	// LaunchFailures are the errors that EC2 Fleet returned for the overrides of the group that it could not launch
+	LaunchFailures []fleets.LaunchFailure
+	// SecurityGroup is the node group's own security group, only created when the plan has ingress rules
+	SecurityGroup securitygroups.SecurityGroup
+	// Role is the resolved IAM role of the node group, it is empty when instances are launched without one
+	Role instanceprofiles.Role
+	// InstanceProfile is the instance profile of the Role that nimbus created for the plan
+	InstanceProfile instanceprofiles.InstanceProfile
+	// Ready is true once the group passed its readiness probe. Only groups with dependents are probed.
+	Ready bool
+	// GPUDriversVerified is true once nvidia-smi ran on every NVIDIA GPU instance of the group, it is only checked when waiting for bootstrap
+	GPUDriversVerified bool
+}
+
+// PlannedResource is a resource that a dry-run launch would have created
+type PlannedResource struct {
+	Type string `table:"Type"`
+	Name string `table:"Name"`
+	// PermissionCheck is the result of EC2's DryRun check of the create call: permitted, unchecked, or the error
+	PermissionCheck string `table:"Permission Check,status"`
 }
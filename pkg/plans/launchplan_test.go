@@ -0,0 +1,157 @@
+package plans_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	"github.com/samber/lo"
+)
+
+func TestOrderNodeGroups(t *testing.T) {
+	type testCases struct {
+		name        string
+		groups      []plans.NodeGroup
+		expected    []string
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:     "no dependencies keeps order",
+			groups:   []plans.NodeGroup{{Name: "a"}, {Name: "b"}},
+			expected: []string{"a", "b"},
+		},
+		{
+			name:     "dependency is launched first",
+			groups:   []plans.NodeGroup{{Name: "worker", DependsOn: []string{"controller"}}, {Name: "controller"}},
+			expected: []string{"controller", "worker"},
+		},
+		{
+			name: "transitive dependencies",
+			groups: []plans.NodeGroup{
+				{Name: "app", DependsOn: []string{"db", "cache"}},
+				{Name: "cache", DependsOn: []string{"db"}},
+				{Name: "db"},
+			},
+			expected: []string{"db", "cache", "app"},
+		},
+		{
+			name:        "cycle",
+			groups:      []plans.NodeGroup{{Name: "a", DependsOn: []string{"b"}}, {Name: "b", DependsOn: []string{"a"}}},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ordered, err := plans.OrderNodeGroups(tc.groups)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			names := lo.Map(ordered, func(group plans.NodeGroup, _ int) string { return group.Name })
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestEffectiveNodeGroupsCount(t *testing.T) {
+	type testCases struct {
+		name     string
+		spec     plans.LaunchSpec
+		expected []int32
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:     "defaults to 1",
+			spec:     plans.LaunchSpec{},
+			expected: []int32{1},
+		},
+		{
+			name:     "spec count",
+			spec:     plans.LaunchSpec{Count: 3},
+			expected: []int32{3},
+		},
+		{
+			name:     "groups default to the spec count",
+			spec:     plans.LaunchSpec{Count: 3, NodeGroups: []plans.NodeGroup{{Name: "a"}, {Name: "b", Count: 5}}},
+			expected: []int32{3, 5},
+		},
+		{
+			name:     "groups default to 1",
+			spec:     plans.LaunchSpec{NodeGroups: []plans.NodeGroup{{Name: "a"}}},
+			expected: []int32{1},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			counts := lo.Map(tc.spec.EffectiveNodeGroups(), func(group plans.NodeGroup, _ int) int32 { return group.Count })
+			if !reflect.DeepEqual(counts, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, counts)
+			}
+		})
+	}
+}
+
+func TestEffectiveNodeGroupsAllocation(t *testing.T) {
+	rebalance := plans.Allocation{CapacityRebalance: "launch"}
+	spec := plans.LaunchSpec{
+		CapacityType: "spot",
+		Allocation:   rebalance,
+		NodeGroups: []plans.NodeGroup{
+			{Name: "worker"},
+			{Name: "controller", CapacityType: "on-demand", Allocation: plans.Allocation{OnDemandBaseCapacity: 1}},
+		},
+	}
+	groups := spec.EffectiveNodeGroups()
+	if groups[0].Allocation != rebalance {
+		t.Errorf("expected the worker to default to the spec's allocation, got %+v", groups[0].Allocation)
+	}
+	if groups[1].Allocation != (plans.Allocation{OnDemandBaseCapacity: 1}) {
+		t.Errorf("expected the controller to keep its own allocation, got %+v", groups[1].Allocation)
+	}
+}
+
+func TestParseCapacity(t *testing.T) {
+	type testCases struct {
+		name        string
+		capacity    string
+		expected    plans.Capacity
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{name: "empty", capacity: "", expected: plans.Capacity{}},
+		{name: "instances", capacity: "4", expected: plans.Capacity{Value: 4, Unit: fleets.CapacityUnitInstances}},
+		{name: "vcpus", capacity: "64vcpu", expected: plans.Capacity{Value: 64, Unit: fleets.CapacityUnitVCPU}},
+		{name: "plural vcpus", capacity: "64 vCPUs", expected: plans.Capacity{Value: 64, Unit: fleets.CapacityUnitVCPU}},
+		{name: "memory", capacity: "256GiB", expected: plans.Capacity{Value: 262144, Unit: fleets.CapacityUnitMemoryMiB}},
+		{name: "memory rounds up to MiB", capacity: "1.5KiB", expected: plans.Capacity{Value: 1, Unit: fleets.CapacityUnitMemoryMiB}},
+		{name: "zero instances", capacity: "0", expectedErr: true},
+		{name: "invalid vcpus", capacity: "manyvcpu", expectedErr: true},
+		{name: "invalid", capacity: "lots", expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			capacity, err := plans.ParseCapacity(tc.capacity)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if capacity != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, capacity)
+			}
+		})
+	}
+}
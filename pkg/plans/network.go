@@ -0,0 +1,155 @@
+package plans
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// DefaultVPCCIDR is the CIDR block of VPCs created by nimbus
+	DefaultVPCCIDR = "10.0.0.0/16"
+	// DefaultSubnetPrefixLength is the prefix length of subnets created by nimbus
+	DefaultSubnetPrefixLength = 24
+	// DefaultAZCount is the number of availability zones that a network created by nimbus spans
+	DefaultAZCount = 3
+
+	// IPFamilyIPv4 networks only have IPv4 addresses
+	IPFamilyIPv4 = "ipv4"
+	// IPFamilyIPv6 networks have IPv6-only subnets, the VPC still has an IPv4 CIDR block because EC2 requires one
+	IPFamilyIPv6 = "ipv6"
+	// IPFamilyDualStack networks assign an IPv4 and an IPv6 address to every instance
+	IPFamilyDualStack = "dual-stack"
+
+	// VPCs can be between /16 and /28, and so can subnets within them
+	minPrefixLength = 16
+	maxPrefixLength = 28
+	// Amazon-provided IPv6 CIDR blocks of VPCs are /56 and the IPv6 CIDR blocks of subnets are /64
+	vpcIPv6PrefixLength    = 56
+	subnetIPv6PrefixLength = 64
+)
+
+// NetworkSpec is the layout of a network created by nimbus. It does not apply to networks that already exist.
+//
+// The VPC CIDR is split into subnets of the subnet prefix length. The public subnets are carved from the start of the first half
+// of the VPC CIDR and the private subnets from the start of the second half, one of each per availability zone, so they never overlap.
+// The IPv6 CIDR blocks of subnets are laid out the same way in the Amazon-provided IPv6 CIDR block of the VPC.
+type NetworkSpec struct {
+	// VPCCIDR is the IPv4 CIDR block of the VPC, defaults to DefaultVPCCIDR
+	VPCCIDR string
+	// SubnetPrefixLength is the prefix length of every subnet, defaults to DefaultSubnetPrefixLength
+	SubnetPrefixLength int
+	// AZCount is the number of availability zones of the region that the network spans, defaults to DefaultAZCount
+	AZCount int
+	// AZs are the names or IDs of the availability zones that the network spans, e.g. us-east-1a or use1-az1. They take precedence over AZCount.
+	AZs []string
+	// IPFamily is ipv4, ipv6, or dual-stack, defaults to ipv4
+	IPFamily string
+}
+
+// WithDefaults returns the network spec with its unset fields defaulted
+func (n NetworkSpec) WithDefaults() NetworkSpec {
+	n.VPCCIDR = lo.CoalesceOrEmpty(n.VPCCIDR, DefaultVPCCIDR)
+	n.SubnetPrefixLength = lo.CoalesceOrEmpty(n.SubnetPrefixLength, DefaultSubnetPrefixLength)
+	n.AZCount = lo.CoalesceOrEmpty(n.AZCount, DefaultAZCount)
+	n.IPFamily = lo.CoalesceOrEmpty(n.IPFamily, IPFamilyIPv4)
+	if len(n.AZs) != 0 {
+		n.AZCount = len(n.AZs)
+	}
+	return n
+}
+
+// Validate returns an error if the network spec can not be laid out, i.e. the VPC CIDR is invalid
+// or does not fit a public and a private subnet for every availability zone
+func (n NetworkSpec) Validate() error {
+	n = n.WithDefaults()
+	if !lo.Contains([]string{IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack}, n.IPFamily) {
+		return fmt.Errorf("invalid IP family %q, must be %s, %s, or %s", n.IPFamily, IPFamilyIPv4, IPFamilyIPv6, IPFamilyDualStack)
+	}
+	vpcPrefix, err := netip.ParsePrefix(n.VPCCIDR)
+	if err != nil {
+		return fmt.Errorf("invalid VPC CIDR %q: %w", n.VPCCIDR, err)
+	}
+	if !vpcPrefix.Addr().Is4() {
+		return fmt.Errorf("invalid VPC CIDR %q, it must be an IPv4 CIDR", n.VPCCIDR)
+	}
+	if vpcPrefix.Masked() != vpcPrefix {
+		return fmt.Errorf("invalid VPC CIDR %q, the host bits must be zero, e.g. %s", n.VPCCIDR, vpcPrefix.Masked())
+	}
+	if vpcPrefix.Bits() < minPrefixLength || vpcPrefix.Bits() > maxPrefixLength {
+		return fmt.Errorf("invalid VPC CIDR %q, the prefix length must be between /%d and /%d", n.VPCCIDR, minPrefixLength, maxPrefixLength)
+	}
+	if n.SubnetPrefixLength <= vpcPrefix.Bits() || n.SubnetPrefixLength > maxPrefixLength {
+		return fmt.Errorf("invalid subnet prefix length /%d, it must be longer than the VPC's /%d and at most /%d", n.SubnetPrefixLength, vpcPrefix.Bits(), maxPrefixLength)
+	}
+	if n.AZCount < 1 {
+		return fmt.Errorf("invalid AZ count %d, the network must span at least 1 availability zone", n.AZCount)
+	}
+	if duplicates := lo.FindDuplicates(n.AZs); len(duplicates) != 0 {
+		return fmt.Errorf("availability zones %v are listed more than once", duplicates)
+	}
+	if perHalf := n.subnetsPerHalf(vpcPrefix); n.AZCount > perHalf {
+		return fmt.Errorf("the VPC CIDR %s only fits %d /%d subnets of each of public and private subnets, %d availability zones need %d",
+			n.VPCCIDR, perHalf, n.SubnetPrefixLength, n.AZCount, n.AZCount)
+	}
+	return nil
+}
+
+// SubnetCIDR returns the CIDR block of the public or private subnet of the index-th availability zone
+func (n NetworkSpec) SubnetCIDR(index int, private bool) (string, error) {
+	if err := n.Validate(); err != nil {
+		return "", err
+	}
+	n = n.WithDefaults()
+	vpcPrefix := netip.MustParsePrefix(n.VPCCIDR)
+	perHalf := n.subnetsPerHalf(vpcPrefix)
+	if index < 0 || index >= perHalf {
+		return "", fmt.Errorf("the VPC CIDR %s only fits %d /%d subnets of each of public and private subnets, got subnet %d", n.VPCCIDR, perHalf, n.SubnetPrefixLength, index)
+	}
+	block := index
+	if private {
+		block += perHalf
+	}
+	// subnets are at most /28, so every block offset fits in the host bits of the 32-bit address
+	base := vpcPrefix.Addr().As4()
+	address := uint32(base[0])<<24 | uint32(base[1])<<16 | uint32(base[2])<<8 | uint32(base[3])
+	address += uint32(block) << (32 - n.SubnetPrefixLength)
+	subnetAddr := netip.AddrFrom4([4]byte{byte(address >> 24), byte(address >> 16), byte(address >> 8), byte(address)})
+	return netip.PrefixFrom(subnetAddr, n.SubnetPrefixLength).String(), nil
+}
+
+// HasIPv6 returns true if the subnets of the network have IPv6 CIDR blocks
+func (n NetworkSpec) HasIPv6() bool {
+	return n.IPFamily == IPFamilyIPv6 || n.IPFamily == IPFamilyDualStack
+}
+
+// IPv6SubnetCIDR returns the IPv6 CIDR block of the public or private subnet of the index-th availability zone
+// in the Amazon-provided /56 IPv6 CIDR block of a VPC
+func IPv6SubnetCIDR(vpcIPv6CIDR string, index int, private bool) (string, error) {
+	vpcPrefix, err := netip.ParsePrefix(vpcIPv6CIDR)
+	if err != nil {
+		return "", fmt.Errorf("invalid VPC IPv6 CIDR %q: %w", vpcIPv6CIDR, err)
+	}
+	if !vpcPrefix.Addr().Is6() || vpcPrefix.Bits() != vpcIPv6PrefixLength {
+		return "", fmt.Errorf("invalid VPC IPv6 CIDR %q, it must be an IPv6 /%d", vpcIPv6CIDR, vpcIPv6PrefixLength)
+	}
+	perHalf := 1 << (subnetIPv6PrefixLength - vpcIPv6PrefixLength - 1)
+	if index < 0 || index >= perHalf {
+		return "", fmt.Errorf("the VPC IPv6 CIDR %s only fits %d /%d subnets of each of public and private subnets, got subnet %d",
+			vpcIPv6CIDR, perHalf, subnetIPv6PrefixLength, index)
+	}
+	block := index
+	if private {
+		block += perHalf
+	}
+	// the subnet ID of a /64 in a /56 is the 8th byte of the address
+	address := vpcPrefix.Masked().Addr().As16()
+	address[7] = byte(block)
+	return netip.PrefixFrom(netip.AddrFrom16(address), subnetIPv6PrefixLength).String(), nil
+}
+
+// subnetsPerHalf returns how many subnets of the subnet prefix length fit in half of the VPC CIDR
+func (n NetworkSpec) subnetsPerHalf(vpcPrefix netip.Prefix) int {
+	return 1 << (n.SubnetPrefixLength - vpcPrefix.Bits() - 1)
+}
@@ -0,0 +1,113 @@
+package plans_test
+
+import (
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+)
+
+func TestNetworkSpecValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		network     plans.NetworkSpec
+		expectedErr bool
+	}{
+		{name: "defaults", network: plans.NetworkSpec{}},
+		{name: "small VPC", network: plans.NetworkSpec{VPCCIDR: "172.16.0.0/24", SubnetPrefixLength: 27, AZCount: 4}},
+		{name: "explicit AZs", network: plans.NetworkSpec{AZs: []string{"us-east-1a", "use1-az2"}}},
+		{name: "invalid CIDR", network: plans.NetworkSpec{VPCCIDR: "10.0.0.0"}, expectedErr: true},
+		{name: "IPv6 CIDR", network: plans.NetworkSpec{VPCCIDR: "2600:1f18::/56"}, expectedErr: true},
+		{name: "host bits set", network: plans.NetworkSpec{VPCCIDR: "10.0.1.0/16"}, expectedErr: true},
+		{name: "VPC too large", network: plans.NetworkSpec{VPCCIDR: "10.0.0.0/8"}, expectedErr: true},
+		{name: "subnet larger than VPC", network: plans.NetworkSpec{VPCCIDR: "10.0.0.0/24", SubnetPrefixLength: 16}, expectedErr: true},
+		{name: "subnet too small", network: plans.NetworkSpec{SubnetPrefixLength: 29}, expectedErr: true},
+		{name: "too many AZs", network: plans.NetworkSpec{VPCCIDR: "10.0.0.0/24", SubnetPrefixLength: 26, AZCount: 3}, expectedErr: true},
+		{name: "negative AZ count", network: plans.NetworkSpec{AZCount: -1}, expectedErr: true},
+		{name: "duplicate AZs", network: plans.NetworkSpec{AZs: []string{"us-east-1a", "us-east-1a"}}, expectedErr: true},
+		{name: "dual-stack", network: plans.NetworkSpec{IPFamily: plans.IPFamilyDualStack}},
+		{name: "invalid IP family", network: plans.NetworkSpec{IPFamily: "ipv5"}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.network.Validate()
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestNetworkSpecSubnetCIDR(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		network     plans.NetworkSpec
+		index       int
+		private     bool
+		expected    string
+		expectedErr bool
+	}{
+		{name: "first public subnet", network: plans.NetworkSpec{}, index: 0, expected: "10.0.0.0/24"},
+		{name: "third public subnet", network: plans.NetworkSpec{}, index: 2, expected: "10.0.2.0/24"},
+		{name: "first private subnet", network: plans.NetworkSpec{}, index: 0, private: true, expected: "10.0.128.0/24"},
+		{name: "larger subnets", network: plans.NetworkSpec{VPCCIDR: "10.1.0.0/16", SubnetPrefixLength: 20}, index: 1, private: true, expected: "10.1.144.0/20"},
+		{name: "small VPC", network: plans.NetworkSpec{VPCCIDR: "172.16.0.0/24", SubnetPrefixLength: 27}, index: 3, private: true, expected: "172.16.0.224/27"},
+		{name: "subnet crosses an octet", network: plans.NetworkSpec{VPCCIDR: "192.168.0.0/20", SubnetPrefixLength: 26}, index: 5, expected: "192.168.1.64/26"},
+		{name: "index past the first half", network: plans.NetworkSpec{VPCCIDR: "172.16.0.0/24", SubnetPrefixLength: 26}, index: 2, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cidr, err := tc.network.SubnetCIDR(tc.index, tc.private)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cidr != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, cidr)
+			}
+		})
+	}
+}
+
+func TestIPv6SubnetCIDR(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		vpcCIDR     string
+		index       int
+		private     bool
+		expected    string
+		expectedErr bool
+	}{
+		{name: "first public subnet", vpcCIDR: "2600:1f18:abc:de00::/56", index: 0, expected: "2600:1f18:abc:de00::/64"},
+		{name: "second public subnet", vpcCIDR: "2600:1f18:abc:de00::/56", index: 1, expected: "2600:1f18:abc:de01::/64"},
+		{name: "first private subnet", vpcCIDR: "2600:1f18:abc:de00::/56", index: 0, private: true, expected: "2600:1f18:abc:de80::/64"},
+		{name: "last private subnet", vpcCIDR: "2600:1f18:abc:de00::/56", index: 127, private: true, expected: "2600:1f18:abc:deff::/64"},
+		{name: "index past the first half", vpcCIDR: "2600:1f18:abc:de00::/56", index: 128, expectedErr: true},
+		{name: "IPv4 CIDR", vpcCIDR: "10.0.0.0/16", expectedErr: true},
+		{name: "not a /56", vpcCIDR: "2600:1f18:abc::/48", expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cidr, err := plans.IPv6SubnetCIDR(tc.vpcCIDR, tc.index, tc.private)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if cidr != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, cidr)
+			}
+		})
+	}
+}
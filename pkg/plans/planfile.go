@@ -0,0 +1,56 @@
+package plans
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"gopkg.in/yaml.v3"
+)
+
+// SaveLaunchPlan writes the plan to a file so that it can be reviewed and applied later.
+// Files with a .json extension are written as JSON, any other file as YAML.
+func SaveLaunchPlan(path string, launchPlan LaunchPlan) error {
+	encoded := pretty.EncodeYAML(launchPlan)
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		encoded = pretty.EncodeJSON(launchPlan)
+	}
+	if err := os.WriteFile(path, []byte(encoded), 0o600); err != nil {
+		return fmt.Errorf("unable to save plan: %w", err)
+	}
+	return nil
+}
+
+// LoadLaunchPlan reads a plan saved by SaveLaunchPlan or printed with -o yaml or -o json
+func LoadLaunchPlan(path string) (LaunchPlan, error) {
+	planBytes, err := os.ReadFile(path)
+	if err != nil {
+		return LaunchPlan{}, fmt.Errorf("unable to read plan: %w", err)
+	}
+	launchPlan, err := DecodeLaunchPlan(planBytes)
+	if err != nil {
+		return LaunchPlan{}, fmt.Errorf("unable to read plan %s: %w", path, err)
+	}
+	return launchPlan, nil
+}
+
+// DecodeLaunchPlan decodes a plan encoded as JSON or as the YAML of its JSON encoding, which keeps the field names of the JSON encoding
+func DecodeLaunchPlan(planBytes []byte) (LaunchPlan, error) {
+	// JSON is YAML, so both are converted to JSON to decode them with the same field names
+	var planObj any
+	if err := yaml.Unmarshal(planBytes, &planObj); err != nil {
+		return LaunchPlan{}, err
+	}
+	planJSON, err := json.Marshal(planObj)
+	if err != nil {
+		return LaunchPlan{}, err
+	}
+	var launchPlan LaunchPlan
+	if err := json.Unmarshal(planJSON, &launchPlan); err != nil {
+		return LaunchPlan{}, err
+	}
+	return launchPlan, nil
+}
@@ -0,0 +1,69 @@
+package plans_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/samber/lo"
+)
+
+func TestSaveLaunchPlan(t *testing.T) {
+	instanceTypeSelectors := lo.Must(instancetypes.ParseSelectors("vcpus:2-4,memory:4GiB-8GiB,arch:arm64"))
+	launchPlan := plans.LaunchPlan{
+		Metadata: plans.LaunchMetadata{Namespace: "team", Name: "web"},
+		Spec: plans.LaunchSpec{
+			Count:                 2,
+			CapacityType:          "spot",
+			InstanceTypeSelectors: instanceTypeSelectors,
+			TTL:                   90 * time.Minute,
+			Tags:                  map[string]string{"cost-center": "1234"},
+		},
+		Status: plans.LaunchStatus{
+			Subnets: []subnets.Subnet{{Subnet: ec2types.Subnet{SubnetId: aws.String("subnet-0123456"), AvailabilityZone: aws.String("us-west-2a")}}},
+			AMIs: []amis.AMI{{Image: ec2types.Image{
+				ImageId:      aws.String("ami-0123456"),
+				Architecture: ec2types.ArchitectureValuesArm64,
+				CreationDate: aws.String("2025-01-02T03:04:05.000Z"),
+			}}},
+			DryRun: true,
+			Region: "us-west-2",
+		},
+	}
+	launchPlan.Status.SpecChecksum = launchPlan.Spec.Checksum()
+
+	for _, file := range []string{"plan.yaml", "plan.json"} {
+		t.Run(file, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), file)
+			if err := plans.SaveLaunchPlan(path, launchPlan); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			loaded, err := plans.LoadLaunchPlan(path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(loaded, launchPlan) {
+				t.Errorf("expected %+v, got %+v", launchPlan, loaded)
+			}
+			if loaded.Spec.Checksum() != launchPlan.Status.SpecChecksum {
+				t.Errorf("expected the checksum of the loaded spec to be %s, got %s", launchPlan.Status.SpecChecksum, loaded.Spec.Checksum())
+			}
+		})
+	}
+}
+
+func TestDecodeLaunchPlan(t *testing.T) {
+	if _, err := plans.DecodeLaunchPlan([]byte("Spec: [")); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+	if _, err := plans.DecodeLaunchPlan([]byte("Spec:\n  Count: many\n")); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+}
@@ -0,0 +1,56 @@
+package plans
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// planIDAlphabet is Crockford's base32 alphabet that ULIDs are encoded in, it leaves out I, L, O, and U
+	planIDAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+	// planIDLength is the length of an encoded ULID, 26 characters of 5 bits encode its 128 bits
+	planIDLength = 26
+)
+
+// NewPlanID returns a ULID that identifies an executed plan in resource tags, log lines, and events.
+// ULIDs start with the millisecond they were created at, so plan IDs sort in the order the plans were executed.
+func NewPlanID() string {
+	var id [16]byte
+	binary.BigEndian.PutUint64(id[:8], uint64(time.Now().UnixMilli())<<16)
+	// the 80 random bits follow the 48 bit timestamp, crypto/rand never returns an error
+	_, _ = rand.Read(id[6:])
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var encoded [planIDLength]byte
+	for i := planIDLength - 1; i >= 0; i-- {
+		encoded[i] = planIDAlphabet[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(encoded[:])
+}
+
+// ParsePlanID returns the time that a plan ID was created at, or an error if it is not a ULID
+func ParsePlanID(planID string) (time.Time, error) {
+	if len(planID) != planIDLength {
+		return time.Time{}, fmt.Errorf("invalid plan ID %q, expected a %d character ULID", planID, planIDLength)
+	}
+	// the first character only encodes 3 bits since the 26 characters encode 130 bits
+	if planID[0] > '7' {
+		return time.Time{}, fmt.Errorf("invalid plan ID %q, it is larger than the largest ULID", planID)
+	}
+	var millis uint64
+	for i, c := range strings.ToUpper(planID) {
+		value := strings.IndexRune(planIDAlphabet, c)
+		if value < 0 {
+			return time.Time{}, fmt.Errorf("invalid plan ID %q, %q is not a ULID character", planID, c)
+		}
+		// the first 10 characters encode the 48 bit millisecond timestamp
+		if i < 10 {
+			millis = millis<<5 | uint64(value)
+		}
+	}
+	return time.UnixMilli(int64(millis)), nil
+}
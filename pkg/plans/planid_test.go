@@ -0,0 +1,59 @@
+package plans_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+)
+
+func TestNewPlanID(t *testing.T) {
+	var planIDs []string
+	for range 3 {
+		planIDs = append(planIDs, plans.NewPlanID())
+		time.Sleep(2 * time.Millisecond)
+	}
+	if !sort.StringsAreSorted(planIDs) {
+		t.Errorf("expected plan IDs to sort in the order they were created, got %v", planIDs)
+	}
+	for _, planID := range planIDs {
+		created, err := plans.ParsePlanID(planID)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if time.Since(created) > time.Minute || time.Since(created) < 0 {
+			t.Errorf("expected plan ID %s to be created now, got %s", planID, created)
+		}
+	}
+}
+
+func TestParsePlanID(t *testing.T) {
+	for _, tc := range []struct {
+		planID      string
+		expected    time.Time
+		expectedErr bool
+	}{
+		{planID: "01ARZ3NDEKTSV4RRFFQ69G5FAV", expected: time.UnixMilli(1469922850259)},
+		{planID: "01arz3ndektsv4rrffq69g5fav", expected: time.UnixMilli(1469922850259)},
+		{planID: "01ARZ3NDEK", expectedErr: true},
+		{planID: "01ARZ3NDEKTSV4RRFFQ69G5FAU", expectedErr: true},
+		{planID: "81ARZ3NDEKTSV4RRFFQ69G5FAV", expectedErr: true},
+	} {
+		t.Run(tc.planID, func(t *testing.T) {
+			created, err := plans.ParsePlanID(tc.planID)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !created.Equal(tc.expected) {
+				t.Errorf("expected %s, got %s", tc.expected, created)
+			}
+		})
+	}
+}
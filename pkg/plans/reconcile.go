@@ -0,0 +1,88 @@
+package plans
+
+import (
+	"strconv"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// Reconcile actions of a launch, decided by the instances that namespace/name already has
+const (
+	// ReconcileCreate launches the plan since namespace/name has no instances
+	ReconcileCreate = "create"
+	// ReconcileNone does nothing since every instance of the plan is already running the spec
+	ReconcileNone = "none"
+	// ReconcileScale launches the instances of the spec that are missing, e.g. after spot interruptions
+	ReconcileScale = "scale"
+	// ReconcileUpdate creates the launch templates of a changed spec and keeps the instances of the earlier spec
+	ReconcileUpdate = "update"
+	// ReconcileReplace launches the instances of a changed spec and then terminates the instances of the earlier spec
+	ReconcileReplace = "replace"
+)
+
+// Reconciliation is how a launch converges the existing instances of namespace/name to the spec
+type Reconciliation struct {
+	// Action is create, none, scale, update, or replace
+	Action string
+	// UpToDate are the existing instances that were launched with the spec
+	UpToDate []instances.Instance
+	// OutOfDate are the existing instances that were launched with an earlier spec, replace terminates them
+	OutOfDate []instances.Instance
+	// Missing is the number of instances that each node group is missing by node group name, the implicit node group has no name.
+	// A node group with a target capacity is missing its whole capacity if it has no up to date instances.
+	Missing map[string]int32
+	// MissingPlacements are the indexes of the spec's placements that have no up to date instance
+	MissingPlacements []int
+}
+
+// Reconcile decides the action of a launch of the spec's node groups from the existing instances of namespace/name.
+// Terminated and shutting-down instances are ignored, stopped instances still count as existing.
+func Reconcile(spec LaunchSpec, nodeGroups []NodeGroup, existing []instances.Instance) Reconciliation {
+	live := lo.Filter(existing, func(instance instances.Instance, _ int) bool {
+		return instance.State == nil || (instance.State.Name != ec2types.InstanceStateNameTerminated && instance.State.Name != ec2types.InstanceStateNameShuttingDown)
+	})
+	checksum := spec.Checksum()
+	reconciliation := Reconciliation{Missing: map[string]int32{}}
+	reconciliation.UpToDate, reconciliation.OutOfDate = lo.FilterReject(live, func(instance instances.Instance, _ int) bool {
+		return instance.SpecChecksum() == checksum
+	})
+
+	for _, group := range nodeGroups {
+		groupInstances := int32(lo.CountBy(reconciliation.UpToDate, func(instance instances.Instance) bool {
+			return tagutils.EC2TagsToMap(instance.Tags)[tagutils.GroupTagKey] == group.Name
+		}))
+		switch {
+		case len(spec.Placements) != 0:
+			for i := range spec.Placements {
+				placed := lo.ContainsBy(reconciliation.UpToDate, func(instance instances.Instance) bool {
+					return tagutils.EC2TagsToMap(instance.Tags)[tagutils.IndexTagKey] == strconv.Itoa(i)
+				})
+				if !placed {
+					reconciliation.MissingPlacements = append(reconciliation.MissingPlacements, i)
+				}
+			}
+			reconciliation.Missing[group.Name] = int32(len(reconciliation.MissingPlacements))
+		case group.Capacity.Value != 0:
+			reconciliation.Missing[group.Name] = lo.Ternary(groupInstances == 0, group.Capacity.Value, 0)
+		default:
+			reconciliation.Missing[group.Name] = max(group.Count-groupInstances, 0)
+		}
+	}
+
+	switch {
+	case len(live) == 0:
+		reconciliation.Action = ReconcileCreate
+	case len(reconciliation.OutOfDate) != 0 && spec.Replace:
+		reconciliation.Action = ReconcileReplace
+	case len(reconciliation.OutOfDate) != 0:
+		reconciliation.Action = ReconcileUpdate
+	case lo.SomeBy(lo.Values(reconciliation.Missing), func(missing int32) bool { return missing > 0 }):
+		reconciliation.Action = ReconcileScale
+	default:
+		reconciliation.Action = ReconcileNone
+	}
+	return reconciliation
+}
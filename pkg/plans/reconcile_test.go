@@ -0,0 +1,137 @@
+package plans_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+)
+
+func testInstance(id, checksum string, state ec2types.InstanceStateName, tags map[string]string) instances.Instance {
+	instanceTags := []ec2types.Tag{{Key: aws.String(tagutils.SpecChecksumTagKey), Value: aws.String(checksum)}}
+	for k, v := range tags {
+		instanceTags = append(instanceTags, ec2types.Tag{Key: aws.String(k), Value: aws.String(v)})
+	}
+	return instances.Instance{Instance: ec2types.Instance{
+		InstanceId: aws.String(id),
+		State:      &ec2types.InstanceState{Name: state},
+		Tags:       instanceTags,
+	}}
+}
+
+func TestReconcile(t *testing.T) {
+	spec := plans.LaunchSpec{Count: 2, CapacityType: "spot"}
+	checksum := spec.Checksum()
+	groupSpec := plans.LaunchSpec{NodeGroups: []plans.NodeGroup{{Name: "controller"}, {Name: "worker", Count: 3}}}
+	groupChecksum := groupSpec.Checksum()
+	placementSpec := plans.LaunchSpec{Placements: []plans.Placement{{AvailabilityZone: "us-west-2a"}, {AvailabilityZone: "us-west-2b"}}}
+	placementChecksum := placementSpec.Checksum()
+
+	type testCases struct {
+		name              string
+		spec              plans.LaunchSpec
+		existing          []instances.Instance
+		expectedAction    string
+		expectedMissing   map[string]int32
+		expectedPlacement []int
+	}
+	for _, tc := range []testCases{
+		{
+			name:            "nothing exists",
+			spec:            spec,
+			expectedAction:  plans.ReconcileCreate,
+			expectedMissing: map[string]int32{"": 2},
+		},
+		{
+			name: "terminated instances are ignored",
+			spec: spec,
+			existing: []instances.Instance{
+				testInstance("i-1", checksum, ec2types.InstanceStateNameTerminated, nil),
+				testInstance("i-2", checksum, ec2types.InstanceStateNameShuttingDown, nil),
+			},
+			expectedAction:  plans.ReconcileCreate,
+			expectedMissing: map[string]int32{"": 2},
+		},
+		{
+			name: "up to date",
+			spec: spec,
+			existing: []instances.Instance{
+				testInstance("i-1", checksum, ec2types.InstanceStateNameRunning, nil),
+				testInstance("i-2", checksum, ec2types.InstanceStateNameStopped, nil),
+			},
+			expectedAction:  plans.ReconcileNone,
+			expectedMissing: map[string]int32{"": 0},
+		},
+		{
+			name:            "missing instance",
+			spec:            spec,
+			existing:        []instances.Instance{testInstance("i-1", checksum, ec2types.InstanceStateNameRunning, nil)},
+			expectedAction:  plans.ReconcileScale,
+			expectedMissing: map[string]int32{"": 1},
+		},
+		{
+			name:            "changed spec",
+			spec:            spec,
+			existing:        []instances.Instance{testInstance("i-1", "0123456789", ec2types.InstanceStateNameRunning, nil)},
+			expectedAction:  plans.ReconcileUpdate,
+			expectedMissing: map[string]int32{"": 2},
+		},
+		{
+			name:            "changed spec with replace",
+			spec:            plans.LaunchSpec{Count: 2, CapacityType: "spot", Replace: true},
+			existing:        []instances.Instance{testInstance("i-1", "0123456789", ec2types.InstanceStateNameRunning, nil)},
+			expectedAction:  plans.ReconcileReplace,
+			expectedMissing: map[string]int32{"": 2},
+		},
+		{
+			name: "node group is missing instances",
+			spec: groupSpec,
+			existing: []instances.Instance{
+				testInstance("i-1", groupChecksum, ec2types.InstanceStateNameRunning, map[string]string{tagutils.GroupTagKey: "controller"}),
+				testInstance("i-2", groupChecksum, ec2types.InstanceStateNameRunning, map[string]string{tagutils.GroupTagKey: "worker"}),
+			},
+			expectedAction:  plans.ReconcileScale,
+			expectedMissing: map[string]int32{"controller": 0, "worker": 2},
+		},
+		{
+			name:              "missing placement",
+			spec:              placementSpec,
+			existing:          []instances.Instance{testInstance("i-1", placementChecksum, ec2types.InstanceStateNameRunning, map[string]string{tagutils.IndexTagKey: "1"})},
+			expectedAction:    plans.ReconcileScale,
+			expectedMissing:   map[string]int32{"": 1},
+			expectedPlacement: []int{0},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			reconciliation := plans.Reconcile(tc.spec, tc.spec.EffectiveNodeGroups(), tc.existing)
+			if reconciliation.Action != tc.expectedAction {
+				t.Errorf("expected action %s, got %s", tc.expectedAction, reconciliation.Action)
+			}
+			for group, missing := range tc.expectedMissing {
+				if reconciliation.Missing[group] != missing {
+					t.Errorf("expected group %q to miss %d instances, got %d", group, missing, reconciliation.Missing[group])
+				}
+			}
+			if len(reconciliation.MissingPlacements) != len(tc.expectedPlacement) {
+				t.Errorf("expected missing placements %v, got %v", tc.expectedPlacement, reconciliation.MissingPlacements)
+			}
+		})
+	}
+}
+
+func TestChecksumIgnoresReplace(t *testing.T) {
+	spec := plans.LaunchSpec{Count: 2}
+	replaced := spec
+	replaced.Replace = true
+	if spec.Checksum() != replaced.Checksum() {
+		t.Errorf("expected the checksum not to change with replace, got %s and %s", spec.Checksum(), replaced.Checksum())
+	}
+	waited := spec
+	waited.Wait = plans.Wait{For: plans.WaitRunning}
+	if spec.Checksum() != waited.Checksum() {
+		t.Errorf("expected the checksum not to change with wait, got %s and %s", spec.Checksum(), waited.Checksum())
+	}
+}
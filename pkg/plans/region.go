@@ -0,0 +1,94 @@
+package plans
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+)
+
+// RegionReference is a field of a spec that refers to a resource that only exists in one region
+type RegionReference struct {
+	Field string `table:"Field"`
+	Value string `table:"Value"`
+}
+
+// String formats the reference as field=value
+func (r RegionReference) String() string {
+	return fmt.Sprintf("%s=%s", r.Field, r.Value)
+}
+
+// RegionReferences returns the fields of the spec that refer to resources of a single region: the IDs of AMIs, subnets, VPCs, security groups,
+// and key pairs, availability zones, and KMS keys that are not aliases. A spec without them is region-agnostic, e.g. with alias or SSM AMI
+// selectors and tag selectors, and can be launched in any region.
+func (s LaunchSpec) RegionReferences() []RegionReference {
+	var references []RegionReference
+	add := func(field, value string) {
+		if value != "" {
+			references = append(references, RegionReference{Field: field, Value: value})
+		}
+	}
+	addAMIs := func(field string, selectors []amis.Selector) {
+		for i, selector := range selectors {
+			add(fmt.Sprintf("%s[%d].ID", field, i), selector.ID)
+		}
+	}
+	addIngressRules := func(field string, rules []securitygroups.IngressRule) {
+		for i, rule := range rules {
+			add(fmt.Sprintf("%s[%d].SecurityGroupID", field, i), rule.SecurityGroupID)
+		}
+	}
+	addKMSKey := func(field, keyID string) {
+		if !isRegionalKMSKey(keyID) {
+			return
+		}
+		add(field, keyID)
+	}
+	addBlockDevices := func(field string, blockDevices []launchtemplates.BlockDevice) {
+		for i, blockDevice := range blockDevices {
+			addKMSKey(fmt.Sprintf("%s[%d].KMSKeyID", field, i), blockDevice.KMSKeyID)
+		}
+	}
+
+	addAMIs("AMISelectors", s.AMISelectors)
+	for i, selector := range s.SubnetSelectors {
+		add(fmt.Sprintf("SubnetSelectors[%d].ID", i), selector.ID)
+		add(fmt.Sprintf("SubnetSelectors[%d].VPCID", i), selector.VPCID)
+	}
+	for i, selector := range s.SecurityGroupSelectors {
+		add(fmt.Sprintf("SecurityGroupSelectors[%d].ID", i), selector.ID)
+		add(fmt.Sprintf("SecurityGroupSelectors[%d].VPCID", i), selector.VPCID)
+	}
+	for i, selector := range s.KeyPairSelectors {
+		add(fmt.Sprintf("KeyPairSelectors[%d].ID", i), selector.ID)
+	}
+	addIngressRules("IngressRules", s.IngressRules)
+	for i, placement := range s.Placements {
+		add(fmt.Sprintf("Placements[%d].SubnetID", i), placement.SubnetID)
+		add(fmt.Sprintf("Placements[%d].AvailabilityZone", i), placement.AvailabilityZone)
+	}
+	for i, zone := range s.Network.AZs {
+		add(fmt.Sprintf("Network.AZs[%d]", i), zone)
+	}
+	for i, networkInterface := range s.NetworkInterfaces {
+		for j, securityGroupID := range networkInterface.SecurityGroupIDs {
+			add(fmt.Sprintf("NetworkInterfaces[%d].SecurityGroupIDs[%d]", i, j), securityGroupID)
+		}
+	}
+	addKMSKey("EBSKMSKey", s.EBSKMSKey)
+	addKMSKey("RootVolume.KMSKeyID", s.RootVolume.KMSKeyID)
+	addBlockDevices("BlockDeviceMappings", s.BlockDeviceMappings)
+	addBlockDevices("Volumes", s.Volumes)
+	for i, group := range s.NodeGroups {
+		addAMIs(fmt.Sprintf("NodeGroups[%d].AMISelectors", i), group.AMISelectors)
+		addIngressRules(fmt.Sprintf("NodeGroups[%d].IngressRules", i), group.IngressRules)
+	}
+	return references
+}
+
+// isRegionalKMSKey returns true if the KMS key is a key ID or ARN, which only exist in one region, rather than an alias or the default key
+func isRegionalKMSKey(keyID string) bool {
+	return keyID != "" && keyID != "default" && !strings.HasPrefix(keyID, "alias/")
+}
@@ -0,0 +1,57 @@
+package plans_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/samber/lo"
+)
+
+func TestRegionReferences(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		spec     plans.LaunchSpec
+		expected []string
+	}{
+		{
+			name: "region-agnostic",
+			spec: plans.LaunchSpec{
+				AMISelectors:    []amis.Selector{{Alias: "al2023"}, {SSM: "/aws/service/ami-amazon-linux-latest/al2023-ami-kernel-default-x86_64"}},
+				SubnetSelectors: []subnets.Selector{{Tags: map[string]string{"team": "infra"}}},
+				EBSKMSKey:       "alias/ebs",
+			},
+		},
+		{
+			name: "IDs and zones",
+			spec: plans.LaunchSpec{
+				AMISelectors:        []amis.Selector{{ID: "ami-123"}},
+				SubnetSelectors:     []subnets.Selector{{ID: "subnet-123"}},
+				Placements:          []plans.Placement{{AvailabilityZone: "us-west-2a"}},
+				Network:             plans.NetworkSpec{AZs: []string{"usw2-az1"}},
+				EBSKMSKey:           "arn:aws:kms:us-west-2:111122223333:key/1234",
+				BlockDeviceMappings: []launchtemplates.BlockDevice{{DeviceName: "/dev/sdf", KMSKeyID: "alias/data"}, {DeviceName: "/dev/sdg", KMSKeyID: "1234"}},
+				NodeGroups:          []plans.NodeGroup{{Name: "workers", AMISelectors: []amis.Selector{{ID: "ami-456"}}}},
+			},
+			expected: []string{
+				"AMISelectors[0].ID=ami-123",
+				"SubnetSelectors[0].ID=subnet-123",
+				"Placements[0].AvailabilityZone=us-west-2a",
+				"Network.AZs[0]=usw2-az1",
+				"EBSKMSKey=arn:aws:kms:us-west-2:111122223333:key/1234",
+				"BlockDeviceMappings[1].KMSKeyID=1234",
+				"NodeGroups[0].AMISelectors[0].ID=ami-456",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			references := lo.Map(tc.spec.RegionReferences(), func(reference plans.RegionReference, _ int) string { return reference.String() })
+			if !slices.Equal(references, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, references)
+			}
+		})
+	}
+}
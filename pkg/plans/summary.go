@@ -0,0 +1,88 @@
+package plans
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/samber/lo"
+)
+
+// Summary is the footer of a printed plan: how many resources it creates and deletes, how it changes the hourly cost, and what it does that
+// can not be undone
+type Summary struct {
+	Create int
+	Delete int
+	// HourlyCostDelta is how much more, or less if it is negative, the resources of namespace/name cost per hour once the plan is executed
+	HourlyCostDelta float64
+	// CostIncomplete is true if the price of some instances or volumes is unknown, they are left out of HourlyCostDelta
+	CostIncomplete bool
+	// Irreversible describes the actions of the plan that can not be undone, e.g. terminating instances
+	Irreversible []string
+}
+
+// Summary summarizes what the launch plan creates and what it terminates when it replaces the instances of an earlier spec
+func (p LaunchPlan) Summary() Summary {
+	reconciliation := p.Status.Reconciliation
+	summary := Summary{
+		Create:         len(p.Status.PlannedResources),
+		CostIncomplete: p.Status.EstimatedCost.Incomplete || p.Status.EstimatedCost.Existing.Incomplete,
+	}
+	switch reconciliation.Action {
+	case ReconcileNone, ReconcileUpdate:
+		// no instances are launched or terminated
+		summary.CostIncomplete = false
+	default:
+		if len(p.Status.EstimatedCost.NodeGroups) == 0 {
+			summary.CostIncomplete = true
+		}
+		summary.HourlyCostDelta = p.Status.EstimatedCost.Hourly - p.Status.EstimatedCost.Existing.Hourly
+	}
+	if reconciliation.Action == ReconcileReplace {
+		summary.Delete = len(reconciliation.OutOfDate)
+		summary.Irreversible = append(summary.Irreversible, fmt.Sprintf("terminates %d instances of an earlier spec", len(reconciliation.OutOfDate)))
+	}
+	return summary
+}
+
+// Summary summarizes what the deletion plan deletes, the resources that are skipped are not counted
+func (p DeletionPlan) Summary() Summary {
+	spec := p.Spec
+	summary := Summary{
+		Delete: len(spec.VPCs) + len(spec.Subnets) + len(spec.InternetGateways) + len(spec.EgressOnlyInternetGateways) + len(spec.RouteTables) +
+			len(spec.NATGateways) + len(spec.ElasticIPs) + len(spec.SecurityGroups) + len(spec.LaunchTemplates) + len(spec.Fleets) +
+			len(spec.Instances) + len(spec.Volumes) + len(spec.InstanceProfiles) + len(spec.ExperimentTemplates),
+		HourlyCostDelta: -p.Status.EstimatedCost.Hourly,
+		CostIncomplete:  p.Status.EstimatedCost.Incomplete,
+	}
+	if len(spec.Instances) != 0 {
+		summary.Irreversible = append(summary.Irreversible, fmt.Sprintf("terminates %d instances, the data of their instance store volumes and of the volumes that are deleted on termination is lost", len(spec.Instances)))
+	}
+	if len(spec.Volumes) != 0 {
+		summary.Irreversible = append(summary.Irreversible, fmt.Sprintf("deletes %d EBS volumes and their data", len(spec.Volumes)))
+	}
+	if len(spec.ElasticIPs) != 0 {
+		summary.Irreversible = append(summary.Irreversible, fmt.Sprintf("releases %d Elastic IPs, their addresses may not be allocated again", len(spec.ElasticIPs)))
+	}
+	return summary
+}
+
+// Destructive is true if the plan does something that can not be undone, destructive plans are confirmed by typing yes or the plan's name
+func (s Summary) Destructive() bool {
+	return len(s.Irreversible) != 0
+}
+
+// String formats the summary as a footer, the irreversible actions are highlighted when color is enabled
+func (s Summary) String() string {
+	sign := lo.Ternary(s.HourlyCostDelta < 0, "-", "+")
+	footer := fmt.Sprintf("Summary: %d to create, %d to delete, %s$%.4f per hour", s.Create, s.Delete, sign, math.Abs(s.HourlyCostDelta))
+	if s.CostIncomplete {
+		footer += ", not including resources with unknown prices"
+	}
+	lines := []string{footer}
+	for _, action := range s.Irreversible {
+		lines = append(lines, pretty.Danger("Irreversible: "+action))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,112 @@
+package plans_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/eips"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
+	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+)
+
+func TestLaunchPlanSummary(t *testing.T) {
+	for _, tc := range []struct {
+		name                string
+		status              plans.LaunchStatus
+		expectedCreate      int
+		expectedDelete      int
+		expectedDelta       float64
+		expectedIncomplete  bool
+		expectedDestructive bool
+	}{
+		{
+			name: "create",
+			status: plans.LaunchStatus{
+				Reconciliation:   plans.Reconciliation{Action: plans.ReconcileCreate},
+				PlannedResources: []plans.PlannedResource{{Type: "vpc"}, {Type: "instance"}},
+				EstimatedCost:    plans.EstimatedCost{NodeGroups: []plans.GroupCost{{Priced: true}}, Hourly: 0.2},
+			},
+			expectedCreate: 2,
+			expectedDelta:  0.2,
+		},
+		{
+			name: "replace",
+			status: plans.LaunchStatus{
+				Reconciliation:   plans.Reconciliation{Action: plans.ReconcileReplace, OutOfDate: []instances.Instance{{}, {}}},
+				PlannedResources: []plans.PlannedResource{{Type: "instance"}},
+				EstimatedCost: plans.EstimatedCost{
+					NodeGroups: []plans.GroupCost{{Priced: true}},
+					Hourly:     0.1,
+					Existing:   plans.InstancesCost{Hourly: 0.3, Incomplete: true},
+				},
+			},
+			expectedCreate:      1,
+			expectedDelete:      2,
+			expectedDelta:       -0.2,
+			expectedIncomplete:  true,
+			expectedDestructive: true,
+		},
+		{
+			name: "update",
+			status: plans.LaunchStatus{
+				Reconciliation: plans.Reconciliation{Action: plans.ReconcileUpdate, OutOfDate: []instances.Instance{{}}},
+			},
+		},
+		{
+			name: "unknown cost",
+			status: plans.LaunchStatus{
+				Reconciliation:   plans.Reconciliation{Action: plans.ReconcileCreate},
+				PlannedResources: []plans.PlannedResource{{Type: "instance"}},
+			},
+			expectedCreate:     1,
+			expectedIncomplete: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			summary := plans.LaunchPlan{Status: tc.status}.Summary()
+			if summary.Create != tc.expectedCreate || summary.Delete != tc.expectedDelete {
+				t.Errorf("expected %d to create and %d to delete, got %d and %d", tc.expectedCreate, tc.expectedDelete, summary.Create, summary.Delete)
+			}
+			if math.Abs(summary.HourlyCostDelta-tc.expectedDelta) > 1e-9 {
+				t.Errorf("expected a cost delta of %v, got %v", tc.expectedDelta, summary.HourlyCostDelta)
+			}
+			if summary.CostIncomplete != tc.expectedIncomplete {
+				t.Errorf("expected incomplete cost %v, got %v", tc.expectedIncomplete, summary.CostIncomplete)
+			}
+			if summary.Destructive() != tc.expectedDestructive {
+				t.Errorf("expected destructive %v, got %v", tc.expectedDestructive, summary.Destructive())
+			}
+		})
+	}
+}
+
+func TestDeletionPlanSummary(t *testing.T) {
+	summary := plans.DeletionPlan{
+		Spec: plans.DeletionSpec{
+			VPCs:       []vpcs.VPC{{}},
+			Instances:  []instances.Instance{{}, {}},
+			Volumes:    []volumes.Volume{{}},
+			ElasticIPs: []eips.ElasticIP{{}},
+			Skipped:    []plans.SkippedResource{{ID: "sg-123"}},
+		},
+		Status: plans.DeletionStatus{EstimatedCost: plans.InstancesCost{Hourly: 0.25}},
+	}.Summary()
+	if summary.Create != 0 || summary.Delete != 5 {
+		t.Errorf("expected 0 to create and 5 to delete, got %d and %d", summary.Create, summary.Delete)
+	}
+	if !summary.Destructive() || len(summary.Irreversible) != 3 {
+		t.Errorf("expected terminating instances, deleting volumes, and releasing Elastic IPs to be irreversible, got %v", summary.Irreversible)
+	}
+	footer := summary.String()
+	if !strings.HasPrefix(footer, "Summary: 0 to create, 5 to delete, -$0.2500 per hour\n") {
+		t.Errorf("unexpected footer %q", footer)
+	}
+
+	empty := plans.DeletionPlan{}.Summary()
+	if empty.Destructive() || !strings.Contains(empty.String(), "+$0.0000 per hour") {
+		t.Errorf("expected an empty deletion plan to not be destructive or change the cost, got %q", empty.String())
+	}
+}
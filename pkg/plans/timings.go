@@ -0,0 +1,76 @@
+package plans
+
+import (
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/samber/lo"
+)
+
+// Phase is a step of a launch whose duration is recorded
+type Phase string
+
+const (
+	// PhaseResolution is the resolution of AMIs, instance types, keys, and reservations
+	PhaseResolution Phase = "Resolution"
+	// PhaseNetwork is the resolution or creation of the VPC, subnets, and security groups
+	PhaseNetwork Phase = "Network"
+	// PhaseFleet is the creation of the launch templates and fleets of every node group
+	PhaseFleet Phase = "Fleet"
+	// PhaseRunning is the wait for every launched instance to be running
+	PhaseRunning Phase = "Running"
+	// PhaseSSMReady is the wait for every launched instance to register with SSM
+	PhaseSSMReady Phase = "SSMReady"
+	// PhaseGPUReady is the wait for the NVIDIA drivers of every GPU instance to run nvidia-smi
+	PhaseGPUReady Phase = "GPUReady"
+	// PhaseProbePassed is the wait for every node group with a readiness probe to pass it
+	PhaseProbePassed Phase = "ProbePassed"
+)
+
+// PhaseTiming is when a phase of a launch started and how long it took
+type PhaseTiming struct {
+	Phase    Phase
+	Started  time.Time
+	Duration time.Duration
+}
+
+// Timings are the timings of a launch's phases in the order they ran
+type Timings []PhaseTiming
+
+// PrettyPhaseTiming is the table representation of a PhaseTiming
+type PrettyPhaseTiming struct {
+	Phase    string `table:"Phase"`
+	Duration string `table:"Duration,right"`
+	// Elapsed is the time since the launch started when the phase finished
+	Elapsed string `table:"Elapsed,right"`
+}
+
+// Record adds the timing of a phase that started at started and finished at finished
+func (t *Timings) Record(phase Phase, started, finished time.Time) {
+	*t = append(*t, PhaseTiming{Phase: phase, Started: started, Duration: finished.Sub(started)})
+}
+
+// Get returns the timing of the phase, false if it was not recorded
+func (t Timings) Get(phase Phase) (PhaseTiming, bool) {
+	return lo.Find(t, func(timing PhaseTiming) bool { return timing.Phase == phase })
+}
+
+// Total is the time from the start of the first phase to the end of the last one
+func (t Timings) Total() time.Duration {
+	if len(t) == 0 {
+		return 0
+	}
+	last := t[len(t)-1]
+	return last.Started.Add(last.Duration).Sub(t[0].Started)
+}
+
+// Prettify returns the table representation of the timings
+func (t Timings) Prettify() []PrettyPhaseTiming {
+	return lo.Map(t, func(timing PhaseTiming, _ int) PrettyPhaseTiming {
+		return PrettyPhaseTiming{
+			Phase:    string(timing.Phase),
+			Duration: pretty.Duration(timing.Duration),
+			Elapsed:  pretty.Duration(timing.Started.Add(timing.Duration).Sub(t[0].Started)),
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package plans_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+)
+
+func TestTimings(t *testing.T) {
+	var timings plans.Timings
+	if total := timings.Total(); total != 0 {
+		t.Errorf("expected no total without timings, got %s", total)
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	timings.Record(plans.PhaseResolution, start, start.Add(2*time.Second))
+	timings.Record(plans.PhaseNetwork, start.Add(2*time.Second), start.Add(5*time.Second))
+	timings.Record(plans.PhaseFleet, start.Add(5*time.Second), start.Add(15*time.Second))
+
+	network, ok := timings.Get(plans.PhaseNetwork)
+	if !ok || network.Duration != 3*time.Second {
+		t.Errorf("expected Network to take 3s, got %+v", network)
+	}
+	if _, ok := timings.Get(plans.PhaseSSMReady); ok {
+		t.Errorf("expected SSMReady to not be recorded")
+	}
+	if total := timings.Total(); total != 15*time.Second {
+		t.Errorf("expected a total of 15s, got %s", total)
+	}
+	pretty := timings.Prettify()
+	if pretty[2].Duration != "10s" || pretty[2].Elapsed != "15s" {
+		t.Errorf("expected Fleet to take 10s and finish 15s into the launch, got %+v", pretty[2])
+	}
+}
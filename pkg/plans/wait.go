@@ -0,0 +1,52 @@
+package plans
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+// WaitCondition is the state that a launch waits for its instances to reach before it returns
+type WaitCondition string
+
+const (
+	// WaitRunning waits for the instances to be running
+	WaitRunning WaitCondition = "running"
+	// WaitStatusChecksPassed waits for the instances to pass their EC2 system and instance status checks
+	WaitStatusChecksPassed WaitCondition = "status-checks-passed"
+	// WaitSSMManaged waits for the instances to register with SSM, which is when sessions and commands can reach them
+	WaitSSMManaged WaitCondition = "ssm-managed"
+
+	// DefaultWaitTimeout is how long a launch waits for its condition when the wait does not specify a timeout
+	DefaultWaitTimeout = 10 * time.Minute
+)
+
+// WaitConditions are the conditions that a launch can wait for
+var WaitConditions = []WaitCondition{WaitRunning, WaitStatusChecksPassed, WaitSSMManaged}
+
+// Wait blocks a launch until its instances reach the condition or the timeout expires
+type Wait struct {
+	// For is the condition to wait for, a launch returns once its fleets are created if it is empty
+	For WaitCondition
+	// Timeout is how long to wait for the condition, defaults to DefaultWaitTimeout
+	Timeout time.Duration
+}
+
+// ParseWaitCondition parses a wait condition, the empty string does not wait
+func ParseWaitCondition(condition string) (WaitCondition, error) {
+	if condition != "" && !lo.Contains(WaitConditions, WaitCondition(condition)) {
+		return "", fmt.Errorf("invalid wait condition %q, must be one of %v", condition, WaitConditions)
+	}
+	return WaitCondition(condition), nil
+}
+
+// Enabled returns true if the launch waits for a condition
+func (w Wait) Enabled() bool {
+	return w.For != ""
+}
+
+// EffectiveTimeout returns the timeout of the wait, defaulted if it is not set
+func (w Wait) EffectiveTimeout() time.Duration {
+	return lo.CoalesceOrEmpty(w.Timeout, DefaultWaitTimeout)
+}
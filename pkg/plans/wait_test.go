@@ -0,0 +1,40 @@
+package plans_test
+
+import (
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+)
+
+func TestParseWaitCondition(t *testing.T) {
+	for _, tc := range []struct {
+		condition   string
+		expected    plans.WaitCondition
+		expectedErr bool
+	}{
+		{condition: ""},
+		{condition: "running", expected: plans.WaitRunning},
+		{condition: "status-checks-passed", expected: plans.WaitStatusChecksPassed},
+		{condition: "ssm-managed", expected: plans.WaitSSMManaged},
+		{condition: "ready", expectedErr: true},
+	} {
+		t.Run(tc.condition, func(t *testing.T) {
+			condition, err := plans.ParseWaitCondition(tc.condition)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if condition != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, condition)
+			}
+		})
+	}
+	if timeout := (plans.Wait{For: plans.WaitRunning}).EffectiveTimeout(); timeout != plans.DefaultWaitTimeout {
+		t.Errorf("expected the default timeout %s, got %s", plans.DefaultWaitTimeout, timeout)
+	}
+}
@@ -0,0 +1,173 @@
+// Package plugin is a small SDK for building nimbus plugins.
+//
+// A plugin is any executable on the PATH named nimbus-<name>. Running `nimbus <name> [args]` executes the plugin with the remaining args.
+// Multi-word plugins are supported by joining the words with dashes, e.g. `nimbus foo bar` runs nimbus-foo-bar.
+// Plugins can use this package to construct the same client facade nimbus uses, with the user's persisted context applied.
+package plugin
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/bwagner5/nimbus/pkg/readonly"
+	"github.com/bwagner5/nimbus/pkg/userconfig"
+	"github.com/bwagner5/nimbus/pkg/utils/awsutils"
+	"github.com/bwagner5/nimbus/pkg/vm"
+)
+
+const (
+	// Prefix is the executable name prefix used to discover plugins on the PATH
+	Prefix = "nimbus-"
+
+	NamespaceEnvVar = "NIMBUS_NAMESPACE"
+	RegionEnvVar    = "NIMBUS_REGION"
+	ProfileEnvVar   = "NIMBUS_PROFILE"
+	VerboseEnvVar   = "NIMBUS_VERBOSE"
+	// RoleARNEnvVar is the role that AWS API calls are made with
+	RoleARNEnvVar = "NIMBUS_ROLE_ARN"
+	// SessionTagsEnvVar are comma separated key=value session tags applied when assuming RoleARNEnvVar
+	SessionTagsEnvVar = "NIMBUS_SESSION_TAGS"
+	// VersionEnvVar is the version of the nimbus CLI that executed the plugin
+	VersionEnvVar = "NIMBUS_VERSION"
+)
+
+// Options are the global options available to a plugin
+type Options struct {
+	Namespace string
+	Region    string
+	Profile   string
+	Verbose   bool
+	// NoMutate makes the client fail any API call that would change resources
+	NoMutate bool
+	// RoleARN is an optional IAM role that is assumed for all AWS API calls
+	RoleARN string
+	// SessionTags are applied to the role session when RoleARN is assumed
+	SessionTags map[string]string
+	// Version is reported in the user-agent of AWS API calls as nimbus/<version>
+	Version string
+}
+
+// OptionsFromEnv returns the plugin Options from NIMBUS_* env vars, falling back to the persisted user context
+func OptionsFromEnv() (Options, error) {
+	opts := Options{
+		Namespace: os.Getenv(NamespaceEnvVar),
+		Region:    os.Getenv(RegionEnvVar),
+		Profile:   os.Getenv(ProfileEnvVar),
+		RoleARN:   os.Getenv(RoleARNEnvVar),
+		Version:   os.Getenv(VersionEnvVar),
+	}
+	opts.Verbose, _ = strconv.ParseBool(os.Getenv(VerboseEnvVar))
+	opts.NoMutate = readonly.Enabled()
+	sessionTags, err := awsutils.ParseSessionTags(os.Getenv(SessionTagsEnvVar))
+	if err != nil {
+		return opts, err
+	}
+	opts.SessionTags = sessionTags
+	path, err := userconfig.DefaultPath()
+	if err != nil {
+		return opts, err
+	}
+	cfg, err := userconfig.Load(path)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Namespace == "" {
+		opts.Namespace = cfg.Namespace
+	}
+	if opts.Region == "" {
+		opts.Region = cfg.Region
+	}
+	return opts, nil
+}
+
+// AWSConfig loads the default AWS config with the plugin Options applied, the same way nimbus loads it
+func AWSConfig(ctx context.Context, opts Options) (*aws.Config, error) {
+	return awsutils.LoadConfig(ctx, awsutils.ConfigOptions{
+		Region:      opts.Region,
+		Profile:     opts.Profile,
+		NoMutate:    opts.NoMutate,
+		RoleARN:     opts.RoleARN,
+		SessionTags: opts.SessionTags,
+		Version:     opts.Version,
+	})
+}
+
+// NewClient returns the nimbus client facade configured from the plugin Options
+func NewClient(ctx context.Context, opts Options) (vm.AWSVM, error) {
+	awsCfg, err := AWSConfig(ctx, opts)
+	if err != nil {
+		return vm.AWSVM{}, err
+	}
+	return vm.New(awsCfg), nil
+}
+
+// Find returns the path of the plugin executable that matches the longest prefix of args and the remaining args to pass to it.
+// args should not include the nimbus binary name. Args starting with "-" end the plugin name.
+func Find(args []string) (string, []string, bool) {
+	var nameParts []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "-") {
+			break
+		}
+		nameParts = append(nameParts, arg)
+	}
+	for i := len(nameParts); i > 0; i-- {
+		path, err := exec.LookPath(Prefix + strings.Join(nameParts[:i], "-"))
+		if err == nil {
+			return path, args[i:], true
+		}
+	}
+	return "", nil, false
+}
+
+// List returns the names of all plugins discovered on the PATH. Plugins shadowed by earlier PATH entries are only listed once.
+func List() []string {
+	seen := map[string]bool{}
+	var plugins []string
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), Prefix) || seen[entry.Name()] {
+				continue
+			}
+			if _, err := exec.LookPath(filepath.Join(dir, entry.Name())); err != nil {
+				continue
+			}
+			seen[entry.Name()] = true
+			plugins = append(plugins, strings.TrimPrefix(entry.Name(), Prefix))
+		}
+	}
+	return plugins
+}
+
+// Exec runs the plugin executable, connected to the current process' stdio, with the global options passed as NIMBUS_* env vars
+func Exec(ctx context.Context, path string, args []string, opts Options) error {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = os.Environ()
+	for k, v := range map[string]string{
+		NamespaceEnvVar:   opts.Namespace,
+		RegionEnvVar:      opts.Region,
+		ProfileEnvVar:     opts.Profile,
+		VerboseEnvVar:     strconv.FormatBool(opts.Verbose),
+		readonly.EnvVar:   strconv.FormatBool(opts.NoMutate),
+		RoleARNEnvVar:     opts.RoleARN,
+		SessionTagsEnvVar: awsutils.FormatSessionTags(opts.SessionTags),
+		VersionEnvVar:     opts.Version,
+	} {
+		if v != "" {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	return cmd.Run()
+}
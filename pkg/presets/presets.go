@@ -0,0 +1,151 @@
+package presets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/samber/lo"
+)
+
+const (
+	// VSCode prepares the instance for the VS Code Remote - SSH extension
+	VSCode = "vscode"
+	// Jupyter runs JupyterLab on the instance's loopback interface, reached through an SSH port forward
+	Jupyter = "jupyter"
+
+	// jupyterPort is the port of JupyterLab on the instance and of its local forward
+	jupyterPort = 8888
+)
+
+// Preset is an opinionated setup of a dev instance for a remote tool
+type Preset struct {
+	Name string
+	// userData is a text/template of the shell script user-data with the fields .Token and .Port
+	userData string
+	// instructions is a text/template of how to connect with the fields of Connection and .Port
+	instructions string
+	// port is the port the tool listens on the instance's loopback interface, it is only reachable through an SSH port forward
+	port int
+}
+
+// Connection is how the launched instance is reached over SSH
+type Connection struct {
+	// Host is a short name for the instance in the SSH config
+	Host         string
+	Address      string
+	User         string
+	IdentityFile string
+	// Token authenticates to the tool, it is generated by NewToken and passed to UserData
+	Token string
+}
+
+var presets = map[string]Preset{
+	VSCode: {
+		Name: VSCode,
+		userData: `#!/bin/bash
+set -euo pipefail
+dnf install -y git tar gzip make gcc
+`,
+		instructions: `Add the instance to your ~/.ssh/config:
+
+  Host {{.Host}}
+    HostName {{.Address}}
+    User {{.User}}
+    IdentityFile {{.IdentityFile}}
+
+Then open it in VS Code:
+
+  code --remote ssh-remote+{{.Host}} /home/{{.User}}
+`,
+	},
+	Jupyter: {
+		Name: Jupyter,
+		port: jupyterPort,
+		userData: `#!/bin/bash
+set -euo pipefail
+dnf install -y python3-pip git
+sudo -u ec2-user -H pip3 install --user jupyterlab
+cat > /etc/systemd/system/jupyter.service <<EOF
+[Unit]
+Description=JupyterLab
+After=network-online.target
+
+[Service]
+User=ec2-user
+WorkingDirectory=/home/ec2-user
+ExecStart=/home/ec2-user/.local/bin/jupyter lab --ip 127.0.0.1 --port {{.Port}} --no-browser --IdentityProvider.token={{.Token}}
+Restart=always
+
+[Install]
+WantedBy=multi-user.target
+EOF
+systemctl daemon-reload
+systemctl enable --now jupyter
+`,
+		instructions: `JupyterLab is installed at boot and ready in a few minutes. Forward its port:
+
+  ssh -i {{.IdentityFile}} -N -L {{.Port}}:127.0.0.1:{{.Port}} {{.User}}@{{.Address}}
+
+Then open http://127.0.0.1:{{.Port}}/lab?token={{.Token}}
+`,
+	},
+}
+
+// Get returns the preset with the name
+func Get(name string) (Preset, error) {
+	preset, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("unknown preset %q, must be one of %s", name, strings.Join(Names(), ", "))
+	}
+	return preset, nil
+}
+
+// Names returns the names of every preset in alphabetical order
+func Names() []string {
+	names := lo.Keys(presets)
+	slices.Sort(names)
+	return names
+}
+
+// NewToken returns a random token that authenticates to a preset's tool
+func NewToken() (string, error) {
+	token := make([]byte, 24)
+	if _, err := rand.Read(token); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// UserData returns the shell script user-data that sets up the preset's tool with the token
+func (p Preset) UserData(token string) (string, error) {
+	return p.render("user-data", p.userData, map[string]any{"Token": token, "Port": p.port})
+}
+
+// Instructions returns how to connect to the preset's tool on the launched instance
+func (p Preset) Instructions(connection Connection) (string, error) {
+	return p.render("instructions", p.instructions, map[string]any{
+		"Host":         connection.Host,
+		"Address":      connection.Address,
+		"User":         connection.User,
+		"IdentityFile": connection.IdentityFile,
+		"Token":        connection.Token,
+		"Port":         p.port,
+	})
+}
+
+func (p Preset) render(name, text string, data map[string]any) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s %s: %w", p.Name, name, err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("failed to render %s %s: %w", p.Name, name, err)
+	}
+	return rendered.String(), nil
+}
@@ -0,0 +1,55 @@
+package presets_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/presets"
+)
+
+func TestGet(t *testing.T) {
+	for _, name := range presets.Names() {
+		t.Run(name, func(t *testing.T) {
+			preset, err := presets.Get(name)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			userData, err := preset.UserData("secret")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !strings.HasPrefix(userData, "#!/bin/bash\n") {
+				t.Errorf("expected shell script user-data, got %s", userData)
+			}
+			if _, err := preset.Instructions(presets.Connection{Host: "nimbus-dev", Address: "192.0.2.1", User: "ec2-user", IdentityFile: "~/.ssh/id_ed25519", Token: "secret"}); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	if _, err := presets.Get("emacs"); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+}
+
+func TestJupyter(t *testing.T) {
+	preset, err := presets.Get(presets.Jupyter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	userData, err := preset.UserData("secret")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(userData, "--ip 127.0.0.1 --port 8888 --no-browser --IdentityProvider.token=secret") {
+		t.Errorf("expected JupyterLab to listen on the loopback interface with the token, got %s", userData)
+	}
+	instructions, err := preset.Instructions(presets.Connection{Address: "192.0.2.1", User: "ec2-user", IdentityFile: "~/.ssh/id_ed25519", Token: "secret"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, expected := range []string{"-L 8888:127.0.0.1:8888 ec2-user@192.0.2.1", "http://127.0.0.1:8888/lab?token=secret"} {
+		if !strings.Contains(instructions, expected) {
+			t.Errorf("expected the instructions to contain %q, got %s", expected, instructions)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package pretty
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	// NoColorEnvVar disables colored output when it is set to a non-empty value, see https://no-color.org
+	NoColorEnvVar = "NO_COLOR"
+
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorReset  = "\x1b[0m"
+)
+
+// colorEnabled is whether output is colorized, it is off until the CLI decides that its output supports color
+var colorEnabled bool
+
+// SetColor enables or disables colorized output
+func SetColor(enabled bool) {
+	colorEnabled = enabled
+}
+
+// ColorEnabled returns true if output is colorized
+func ColorEnabled() bool {
+	return colorEnabled
+}
+
+// SupportsColor returns true if the file is a terminal and color was not disabled with noColor or the NO_COLOR environment variable
+func SupportsColor(noColor bool, f *os.File) bool {
+	return !noColor && os.Getenv(NoColorEnvVar) == "" && isatty.IsTerminal(f.Fd())
+}
+
+var (
+	healthyStatuses = []string{"running", "available", "active", "completed", "success", "succeeded", "synced", "permitted", "true"}
+	pendingStatuses = []string{"pending", "stopping", "shutting-down", "in-progress", "inprogress", "initiating", "submitted", "modifying", "outofdate", "unknown"}
+	failedStatuses  = []string{"failed", "error", "timedout", "timed-out", "cancelled", "impaired", "false"}
+)
+
+// Status colorizes a status when color is enabled: healthy statuses like running are green, pending ones are yellow, and failed ones are red.
+// Statuses are matched case-insensitively, and other statuses like stopped are not colorized.
+func Status(status string) string {
+	normalized := strings.ToLower(status)
+	for _, s := range healthyStatuses {
+		if normalized == s {
+			return colorize(colorGreen, status)
+		}
+	}
+	for _, s := range pendingStatuses {
+		if normalized == s {
+			return colorize(colorYellow, status)
+		}
+	}
+	for _, s := range failedStatuses {
+		if normalized == s {
+			return colorize(colorRed, status)
+		}
+	}
+	return status
+}
+
+// colorize wraps s in the ANSI color if color is enabled
+func colorize(color string, s string) string {
+	if !colorEnabled || s == "" {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// Danger colorizes a warning about an action that can not be undone red when color is enabled
+func Danger(s string) string {
+	return colorize(colorRed, s)
+}
@@ -0,0 +1,76 @@
+package pretty
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldDiff is a field whose live value differs from its desired value.
+// Values with multiple lines, e.g. user-data, are diffed line by line.
+type FieldDiff struct {
+	Field   string
+	Live    string
+	Desired string
+}
+
+// Diff renders the field diffs with the removed live lines prefixed with - and the added desired lines prefixed with +.
+// If color is enabled, removed lines are red and added lines are green.
+func Diff(diffs []FieldDiff) string {
+	var out strings.Builder
+	for _, diff := range diffs {
+		fmt.Fprintf(&out, "%s:\n", diff.Field)
+		for _, line := range LineDiff(diff.Live, diff.Desired) {
+			if strings.HasPrefix(line, "-") {
+				line = colorize(colorRed, line)
+			} else {
+				line = colorize(colorGreen, line)
+			}
+			fmt.Fprintf(&out, "  %s\n", line)
+		}
+	}
+	return out.String()
+}
+
+// LineDiff returns the lines that are removed from live prefixed with "- " and the lines that are added by desired prefixed with "+ ".
+// Lines that both have in common are left out. The lines are matched by their longest common subsequence, so a removed line
+// is listed before the line that replaces it.
+func LineDiff(live, desired string) []string {
+	liveLines, desiredLines := splitLines(live), splitLines(desired)
+	// common[i][j] is the length of the longest common subsequence of liveLines[i:] and desiredLines[j:]
+	common := make([][]int, len(liveLines)+1)
+	for i := range common {
+		common[i] = make([]int, len(desiredLines)+1)
+	}
+	for i := len(liveLines) - 1; i >= 0; i-- {
+		for j := len(desiredLines) - 1; j >= 0; j-- {
+			if liveLines[i] == desiredLines[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	var diff []string
+	i, j := 0, 0
+	for i < len(liveLines) || j < len(desiredLines) {
+		switch {
+		case i < len(liveLines) && j < len(desiredLines) && liveLines[i] == desiredLines[j]:
+			i, j = i+1, j+1
+		case j == len(desiredLines) || (i < len(liveLines) && common[i+1][j] >= common[i][j+1]):
+			diff = append(diff, "- "+liveLines[i])
+			i++
+		default:
+			diff = append(diff, "+ "+desiredLines[j])
+			j++
+		}
+	}
+	return diff
+}
+
+// splitLines returns the lines of s, an empty string has no lines
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
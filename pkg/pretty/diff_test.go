@@ -0,0 +1,31 @@
+package pretty_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/pretty"
+)
+
+func TestLineDiff(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		live     string
+		desired  string
+		expected []string
+	}{
+		{name: "same", live: "a\nb", desired: "a\nb"},
+		{name: "empty", live: "", desired: ""},
+		{name: "added", live: "", desired: "a\nb", expected: []string{"+ a", "+ b"}},
+		{name: "removed", live: "a\nb", desired: "", expected: []string{"- a", "- b"}},
+		{name: "replaced line", live: "a\nb\nc", desired: "a\nx\nc", expected: []string{"- b", "+ x"}},
+		{name: "inserted line", live: "a\nc", desired: "a\nb\nc", expected: []string{"+ b"}},
+		{name: "trailing newline", live: "a\n", desired: "a", expected: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if diff := pretty.LineDiff(tc.live, tc.desired); !slices.Equal(diff, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package pretty
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+)
+
+// Duration formats a duration with its two largest units so that durations of a column line up, e.g. 350ms, 2.5s, 3m05s, 2h07m, or 4d03h
+func Duration(d time.Duration) string {
+	if d < 0 {
+		return "-" + Duration(-d)
+	}
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return strconv.FormatFloat(d.Round(100*time.Millisecond).Seconds(), 'f', -1, 64) + "s"
+	case d < time.Hour:
+		d = d.Round(time.Second)
+		return fmt.Sprintf("%dm%02ds", int(d/time.Minute), int(d%time.Minute/time.Second))
+	case d < 24*time.Hour:
+		d = d.Round(time.Minute)
+		return fmt.Sprintf("%dh%02dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	}
+	d = d.Round(time.Hour)
+	return fmt.Sprintf("%dd%02dh", int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour))
+}
+
+// binaryUnits are the base-2 units that byte sizes are formatted in, from the largest to the smallest
+var binaryUnits = []struct {
+	unit   bytesize.Unit
+	suffix string
+}{
+	{bytesize.Tebibyte, "TiB"},
+	{bytesize.Gibibyte, "GiB"},
+	{bytesize.Mebibyte, "MiB"},
+	{bytesize.Kibibyte, "KiB"},
+}
+
+// Bytes formats a byte size in the largest base-2 unit that it is at least 1 of with up to one decimal, e.g. 512 MiB or 1.5 GiB
+func Bytes(size bytesize.ByteSize) string {
+	for _, u := range binaryUnits {
+		if value := size.As(u.unit); value >= 1 {
+			return strings.TrimSuffix(strconv.FormatFloat(value, 'f', 1, 64), ".0") + " " + u.suffix
+		}
+	}
+	return fmt.Sprintf("%d B", int64(size))
+}
+
+// HourlyPrice formats an hourly price in US dollars with the precision of the AWS price lists, e.g. $0.0960
+func HourlyPrice(price float64) string {
+	return fmt.Sprintf("$%.4f", price)
+}
+
+// MonthlyPrice formats a monthly price in US dollars to the cent, e.g. $70.08
+func MonthlyPrice(price float64) string {
+	return fmt.Sprintf("$%.2f", price)
+}
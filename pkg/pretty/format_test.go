@@ -0,0 +1,101 @@
+package pretty_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+)
+
+func TestDuration(t *testing.T) {
+	for _, tc := range []struct {
+		duration time.Duration
+		expected string
+	}{
+		{duration: 0, expected: "0s"},
+		{duration: 350 * time.Millisecond, expected: "350ms"},
+		{duration: 2500 * time.Millisecond, expected: "2.5s"},
+		{duration: 10 * time.Second, expected: "10s"},
+		{duration: 3*time.Minute + 5*time.Second, expected: "3m05s"},
+		{duration: 2*time.Hour + 7*time.Minute + 20*time.Second, expected: "2h07m"},
+		{duration: 4*24*time.Hour + 3*time.Hour, expected: "4d03h"},
+		{duration: -90 * time.Second, expected: "-1m30s"},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			if formatted := pretty.Duration(tc.duration); formatted != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, formatted)
+			}
+		})
+	}
+}
+
+func TestBytes(t *testing.T) {
+	for _, tc := range []struct {
+		size     bytesize.ByteSize
+		expected string
+	}{
+		{size: 0, expected: "0 B"},
+		{size: 512, expected: "512 B"},
+		{size: 512 << 20, expected: "512 MiB"},
+		{size: 1536 << 20, expected: "1.5 GiB"},
+		{size: 16 << 30, expected: "16 GiB"},
+		{size: 2 << 40, expected: "2 TiB"},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			if formatted := pretty.Bytes(tc.size); formatted != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, formatted)
+			}
+		})
+	}
+}
+
+func TestStatus(t *testing.T) {
+	if status := pretty.Status("running"); status != "running" {
+		t.Errorf("expected no color when color is disabled, got %q", status)
+	}
+	pretty.SetColor(true)
+	defer pretty.SetColor(false)
+	for _, tc := range []struct {
+		status   string
+		expected string
+	}{
+		{status: "running", expected: "\x1b[32mrunning\x1b[0m"},
+		{status: "Pending", expected: "\x1b[33mPending\x1b[0m"},
+		{status: "Failed", expected: "\x1b[31mFailed\x1b[0m"},
+		{status: "stopped", expected: "stopped"},
+	} {
+		t.Run(tc.status, func(t *testing.T) {
+			if status := pretty.Status(tc.status); status != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, status)
+			}
+		})
+	}
+}
+
+func TestTableStatusColumn(t *testing.T) {
+	type row struct {
+		Name   string `table:"Name"`
+		Status string `table:"Status,status"`
+		Age    string `table:"Age,wide,right"`
+	}
+	pretty.SetColor(true)
+	defer pretty.SetColor(false)
+	table := pretty.Table([]row{{Name: "web", Status: "running", Age: "5m02s"}}, false)
+	if !strings.Contains(table, "\x1b[32mrunning\x1b[0m") {
+		t.Errorf("expected the status column to be colorized, got %q", table)
+	}
+	if strings.Contains(table, "web\x1b") || strings.Contains(table, "AGE") {
+		t.Errorf("expected only the status column without the wide age column, got %q", table)
+	}
+}
+
+func TestPrices(t *testing.T) {
+	if formatted := pretty.HourlyPrice(0.096); formatted != "$0.0960" {
+		t.Errorf("expected $0.0960, got %s", formatted)
+	}
+	if formatted := pretty.MonthlyPrice(0.096 * 730); formatted != "$70.08" {
+		t.Errorf("expected $70.08, got %s", formatted)
+	}
+}
@@ -4,11 +4,13 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"reflect"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/table"
 	"github.com/olekukonko/tablewriter"
+	"github.com/samber/lo"
 	"gopkg.in/yaml.v2"
 )
 
@@ -52,6 +54,8 @@ func EncodeYAML(data any) string {
 // Table takes any struct data and prints it in a table format
 // The struct fields must have a `table` tag with the column name
 // An optional `wide` tag can be added to the `table` tag to only show the column in wide mode
+// An optional `status` tag colorizes the column's values with Status when color is enabled
+// An optional `right` tag right aligns the column, e.g. to line up durations
 // Example:
 //
 //	type MyStruct struct {
@@ -74,6 +78,17 @@ func EncodeYAML(data any) string {
 // test1       test2
 func Table[T any](data []T, wide bool) string {
 	headers, rows := HeadersAndRows(data, wide)
+	var columns []tableColumn
+	if len(data) != 0 {
+		columns = tableColumns(reflect.Indirect(reflect.ValueOf(data[0])).Type(), wide)
+	}
+	for _, row := range rows {
+		for i, column := range columns {
+			if column.status {
+				row[i] = Status(row[i])
+			}
+		}
+	}
 	out := bytes.Buffer{}
 	table := tablewriter.NewWriter(&out)
 	table.SetHeader(headers)
@@ -81,6 +96,11 @@ func Table[T any](data []T, wide bool) string {
 	table.SetAutoFormatHeaders(true)
 	table.SetHeaderAlignment(tablewriter.ALIGN_LEFT)
 	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	if len(columns) != 0 {
+		table.SetColumnAlignment(lo.Map(columns, func(column tableColumn, _ int) int {
+			return lo.Ternary(column.right, tablewriter.ALIGN_RIGHT, tablewriter.ALIGN_LEFT)
+		}))
+	}
 	table.SetCenterSeparator("")
 	table.SetColumnSeparator("")
 	table.SetRowSeparator("")
@@ -98,28 +118,46 @@ func HeadersAndRows[T any](data []T, wide bool) ([]string, [][]string) {
 	var headers []string
 	var rows [][]string
 	for _, dataRow := range data {
-		var row []string
-		// clear headers each time so we only keep one set
-		headers = []string{}
 		reflectStruct := reflect.Indirect(reflect.ValueOf(dataRow))
-		for i := 0; i < reflectStruct.NumField(); i++ {
-			typeField := reflectStruct.Type().Field(i)
-			tag := typeField.Tag.Get("table")
-			if tag == "" {
-				continue
-			}
-			subtags := strings.Split(tag, ",")
-			if len(subtags) > 1 && subtags[1] == "wide" && !wide {
-				continue
-			}
-			headers = append(headers, subtags[0])
-			row = append(row, reflect.ValueOf(dataRow).Field(i).String())
-		}
-		rows = append(rows, row)
+		columns := tableColumns(reflectStruct.Type(), wide)
+		headers = lo.Map(columns, func(column tableColumn, _ int) string { return column.header })
+		rows = append(rows, lo.Map(columns, func(column tableColumn, _ int) string {
+			return fmt.Sprint(reflectStruct.Field(column.field).Interface())
+		}))
 	}
 	return headers, rows
 }
 
+// tableColumn is a struct field with a `table` tag
+type tableColumn struct {
+	field  int
+	header string
+	status bool
+	right  bool
+}
+
+// tableColumns returns the columns of the struct type's tagged fields, wide columns are only returned in wide mode
+func tableColumns(structType reflect.Type, wide bool) []tableColumn {
+	var columns []tableColumn
+	for i := 0; i < structType.NumField(); i++ {
+		tag := structType.Field(i).Tag.Get("table")
+		if tag == "" {
+			continue
+		}
+		subtags := strings.Split(tag, ",")
+		if lo.Contains(subtags[1:], "wide") && !wide {
+			continue
+		}
+		columns = append(columns, tableColumn{
+			field:  i,
+			header: subtags[0],
+			status: lo.Contains(subtags[1:], "status"),
+			right:  lo.Contains(subtags[1:], "right"),
+		})
+	}
+	return columns
+}
+
 func HeadersAndRowToStruct(headers []table.Column, row []string, result any) error {
 	typeOfT := reflect.TypeOf(result).Elem()
 	if typeOfT.Kind() != reflect.Struct {
@@ -135,7 +173,7 @@ func HeadersAndRowToStruct(headers []table.Column, row []string, result any) err
 	for i, header := range headers {
 		for j := 0; j < typeOfT.NumField(); j++ {
 			field := typeOfT.Field(j)
-			if field.Tag.Get("table") == header.Title {
+			if strings.Split(field.Tag.Get("table"), ",")[0] == header.Title {
 				fieldValue := valueOfT.Field(j)
 				if fieldValue.CanSet() {
 					fieldValue.SetString(row[i])
@@ -0,0 +1,110 @@
+package progress
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	// refreshInterval is how often an indicator redraws its line
+	refreshInterval = time.Second
+	// barWidth is the number of cells in the progress bar
+	barWidth = 20
+	// maxEstimatedFraction caps the bar of a wait that runs past its expected duration so that it never looks finished before it is
+	maxEstimatedFraction = 0.95
+)
+
+type progressCtxKey struct{}
+
+// Reporter draws progress indicators for long waits on a terminal.
+// A nil Reporter, which FromContext returns when progress is not enabled, draws nothing.
+type Reporter struct {
+	out io.Writer
+	// mu serializes the indicators of concurrent waits that share the terminal line
+	mu sync.Mutex
+}
+
+// NewReporter returns a Reporter that draws its indicators on out
+func NewReporter(out io.Writer) *Reporter {
+	return &Reporter{out: out}
+}
+
+// DefaultReporter returns a Reporter that draws on stderr if it is a terminal, otherwise it returns nil so that piped and logged output stays clean
+func DefaultReporter() *Reporter {
+	if !isatty.IsTerminal(os.Stderr.Fd()) {
+		return nil
+	}
+	return NewReporter(os.Stderr)
+}
+
+// FromContext returns the reporter stored in the context or nil if there is none
+func FromContext(ctx context.Context) *Reporter {
+	reporter, _ := ctx.Value(progressCtxKey{}).(*Reporter)
+	return reporter
+}
+
+func ToContext(ctx context.Context, reporter *Reporter) context.Context {
+	return context.WithValue(ctx, progressCtxKey{}, reporter)
+}
+
+// Track draws an indicator for label with the elapsed time and an estimate of the time remaining based on how long the wait is expected to take.
+// The returned func stops the indicator and must be called when the wait returns.
+func (r *Reporter) Track(label string, expected time.Duration) func() {
+	if r == nil {
+		return func() {}
+	}
+	start := time.Now()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(refreshInterval)
+		defer ticker.Stop()
+		for {
+			r.draw("\r\x1b[K" + Line(label, time.Since(start), expected))
+			select {
+			case <-stop:
+				r.draw(fmt.Sprintf("\r\x1b[K%s took %s\n", label, pretty.Duration(time.Since(start).Round(time.Second))))
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			<-stopped
+		})
+	}
+}
+
+func (r *Reporter) draw(s string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fmt.Fprint(r.out, s)
+}
+
+// Line formats the progress of a wait, e.g. "Creating NAT Gateway [#####...............] 30s elapsed, ~1m30s remaining".
+// Once a wait runs past its expected duration the bar stops short of full and the estimate says that it is taking longer than expected.
+func Line(label string, elapsed time.Duration, expected time.Duration) string {
+	if expected <= 0 {
+		return fmt.Sprintf("%s %s elapsed", label, pretty.Duration(elapsed))
+	}
+	fraction := min(float64(elapsed)/float64(expected), maxEstimatedFraction)
+	filled := int(fraction * barWidth)
+	bar := strings.Repeat("#", filled) + strings.Repeat(".", barWidth-filled)
+	estimate := "taking longer than expected"
+	if remaining := expected - elapsed; remaining > 0 {
+		estimate = fmt.Sprintf("~%s remaining", pretty.Duration(remaining.Round(time.Second)))
+	}
+	return fmt.Sprintf("%s [%s] %s elapsed, %s", label, bar, pretty.Duration(elapsed.Round(time.Second)), estimate)
+}
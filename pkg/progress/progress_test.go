@@ -0,0 +1,45 @@
+package progress_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/progress"
+)
+
+func TestLine(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		elapsed  time.Duration
+		expected time.Duration
+		line     string
+	}{
+		{name: "started", elapsed: 0, expected: 2 * time.Minute, line: "Creating NAT Gateway [....................] 0s elapsed, ~2m00s remaining"},
+		{name: "halfway", elapsed: time.Minute, expected: 2 * time.Minute, line: "Creating NAT Gateway [##########..........] 1m00s elapsed, ~1m00s remaining"},
+		{name: "overdue", elapsed: 3 * time.Minute, expected: 2 * time.Minute, line: "Creating NAT Gateway [###################.] 3m00s elapsed, taking longer than expected"},
+		{name: "no estimate", elapsed: 5 * time.Second, line: "Creating NAT Gateway 5s elapsed"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if line := progress.Line("Creating NAT Gateway", tc.elapsed, tc.expected); line != tc.line {
+				t.Errorf("expected %q, got %q", tc.line, line)
+			}
+		})
+	}
+}
+
+func TestTrack(t *testing.T) {
+	// the reporter of a context without one draws nothing
+	progress.FromContext(context.Background()).Track("Waiting", time.Minute)()
+
+	var out bytes.Buffer
+	ctx := progress.ToContext(context.Background(), progress.NewReporter(&out))
+	done := progress.FromContext(ctx).Track("Waiting", time.Minute)
+	done()
+	done()
+	if !strings.Contains(out.String(), "Waiting [") || !strings.HasSuffix(out.String(), "Waiting took 0s\n") {
+		t.Errorf("expected the indicator to be drawn and finished once, got %q", out.String())
+	}
+}
@@ -3,6 +3,11 @@ package amis
 import (
 	"context"
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -10,9 +15,15 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
 
+const (
+	// AliasDLAMIGPU is the Deep Learning Base AMI on Amazon Linux 2023 with the NVIDIA driver and CUDA toolkit preinstalled
+	AliasDLAMIGPU = "dlami-gpu"
+)
+
 var (
 	aliases = map[string][]string{
 		"al2023": {
@@ -27,9 +38,87 @@ var (
 			"/aws/service/ami-amazon-linux-latest/amzn2-ami-hvm-arm64-gp2",
 			"/aws/service/ami-amazon-linux-latest/amzn2-ami-hvm-x86_64-gp2",
 		},
+		AliasDLAMIGPU: {
+			"/aws/service/deeplearning/ami/arm64/base-oss-nvidia-driver-gpu-amazon-linux-2023/latest/ami-id",
+			"/aws/service/deeplearning/ami/x86_64/base-oss-nvidia-driver-gpu-amazon-linux-2023/latest/ami-id",
+		},
+	}
+	// aliasFamilies are the aliases that are published in several versions, selected with alias:<name>@<version> or alias:<name>-<version>
+	aliasFamilies = map[string]aliasFamily{
+		"ubuntu": {
+			defaultVersion: "24.04",
+			version:        regexp.MustCompile(`^\d{2}\.\d{2}$`),
+			paths: func(version string) []string {
+				// Canonical publishes the AMIs of releases since 24.04 with gp3 root volumes
+				volumeType := lo.Ternary(version < "24.04", "ebs-gp2", "ebs-gp3")
+				return lo.Map([]string{"arm64", "amd64"}, func(arch string, _ int) string {
+					return fmt.Sprintf("/aws/service/canonical/ubuntu/server/%s/stable/current/%s/hvm/%s/ami-id", version, arch, volumeType)
+				})
+			},
+		},
+		// bottlerocket versions are the Kubernetes version of its aws-k8s variant
+		"bottlerocket": {
+			defaultVersion: "1.31",
+			version:        regexp.MustCompile(`^1\.\d+$`),
+			paths: func(version string) []string {
+				return lo.Map([]string{"arm64", "x86_64"}, func(arch string, _ int) string {
+					return fmt.Sprintf("/aws/service/bottlerocket/aws-k8s-%s/%s/latest/image_id", version, arch)
+				})
+			},
+		},
+		// eks versions are the Kubernetes version of the EKS optimized Amazon Linux 2023 AMI
+		"eks": {
+			defaultVersion: "1.31",
+			version:        regexp.MustCompile(`^1\.\d+$`),
+			paths: func(version string) []string {
+				return lo.Map([]string{"arm64", "x86_64"}, func(arch string, _ int) string {
+					return fmt.Sprintf("/aws/service/eks/optimized-ami/%s/amazon-linux-2023/%s/standard/recommended/image_id", version, arch)
+				})
+			},
+		},
+		// windows is only published for x86_64
+		"windows": {
+			defaultVersion: "2022",
+			version:        regexp.MustCompile(`^\d{4}$`),
+			paths: func(version string) []string {
+				return []string{fmt.Sprintf("/aws/service/ami-windows-latest/Windows_Server-%s-English-Full-Base", version)}
+			},
+		},
 	}
+	// aliasNamePrefixes identify the alias of an Amazon owned AMI by the prefix of its name, more specific prefixes first
+	aliasNamePrefixes = []struct {
+		prefix string
+		alias  string
+	}{
+		{prefix: "al2023-ami-minimal-", alias: "al2023-minimal"},
+		{prefix: "al2023-ami-", alias: "al2023"},
+		{prefix: "amzn2-ami-hvm-", alias: "al2"},
+		{prefix: "Deep Learning Base OSS Nvidia Driver GPU AMI (Amazon Linux 2023)", alias: AliasDLAMIGPU},
+	}
+	// aliasNamePatterns identify the versioned alias of an Amazon owned AMI by its name, the version is the pattern's submatch
+	aliasNamePatterns = []struct {
+		pattern *regexp.Regexp
+		family  string
+	}{
+		{pattern: regexp.MustCompile(`^bottlerocket-aws-k8s-(1\.\d+)-`), family: "bottlerocket"},
+		{pattern: regexp.MustCompile(`^amazon-eks-node-al2023-(?:arm64|x86_64)-standard-(1\.\d+)-`), family: "eks"},
+		{pattern: regexp.MustCompile(`^Windows_Server-(\d{4})-English-Full-Base-`), family: "windows"},
+	}
+	// x86NameTokens are the ways AMI names spell the x86_64 architecture
+	x86NameTokens = []string{"x86_64", "amd64"}
+	// launchArchitectures are the architectures that fleets launch instances of, in the order that their AMIs are chosen
+	launchArchitectures = []ec2types.ArchitectureValues{ec2types.ArchitectureValuesArm64, ec2types.ArchitectureValuesX8664}
 )
 
+// aliasFamily is an alias that is published in several versions
+type aliasFamily struct {
+	// defaultVersion is resolved if the alias has no version
+	defaultVersion string
+	version        *regexp.Regexp
+	// paths returns the SSM parameters of the version's AMIs, one per architecture
+	paths func(version string) []string
+}
+
 type Selector struct {
 	Tags         map[string]string
 	Name         string
@@ -38,6 +127,15 @@ type Selector struct {
 	SSM          string
 	Alias        string
 	Architecture string
+	// Newest only keeps the most recently created AMI of each architecture, so that a name pattern resolves to one AMI per architecture
+	Newest bool
+	// Deprecated includes AMIs whose deprecation time has passed, they are excluded unless the selector term selects an ID
+	Deprecated bool
+	// CreationDate is an EC2 creation-date filter of the AMIs, wildcards are supported, e.g. 2025-01-*
+	CreationDate string
+	// CreatedAfter and CreatedBefore bound when the AMIs were created, parsed from creation-date:>2025-01-01 and creation-date:<2025-01-01
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
 }
 
 // Watcher discovers AMIs based on selectors
@@ -50,6 +148,7 @@ type Watcher struct {
 // AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
 type SDKImageOps interface {
 	ec2.DescribeImagesAPIClient
+	CreateImage(context.Context, *ec2.CreateImageInput, ...func(*ec2.Options)) (*ec2.CreateImageOutput, error)
 }
 
 type SDKSSMOps interface {
@@ -62,6 +161,16 @@ type AMI struct {
 	ec2types.Image
 }
 
+// PrettyAMI represents an AMI for UI elements like the static and TUI tables
+type PrettyAMI struct {
+	Architecture string `table:"Architecture"`
+	ID           string `table:"ID"`
+	Name         string `table:"Name"`
+	Alias        string `table:"Alias,wide"`
+	Owner        string `table:"Owner,wide"`
+	Created      string `table:"Created,wide"`
+}
+
 // ParseSelectors parses a string of selectors into a slice of Selector structs
 func ParseSelectors(selectorStr string) ([]Selector, error) {
 	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
@@ -90,11 +199,28 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 				amiSelector.SSM = v
 			case "architecture":
 				amiSelector.Architecture = v
+			case "newest":
+				newest, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid ami selector newest:%s, expected true or false", v)
+				}
+				amiSelector.Newest = newest
+			case "deprecated":
+				deprecated, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid ami selector deprecated:%s, expected true or false", v)
+				}
+				amiSelector.Deprecated = deprecated
+			case "creation-date":
+				if err := amiSelector.parseCreationDate(v); err != nil {
+					return nil, err
+				}
 			case "alias":
-				if _, ok := aliases[v]; !ok {
-					return nil, fmt.Errorf("invalid ami alias: %s", v)
+				alias, _, err := resolveAlias(v)
+				if err != nil {
+					return nil, err
 				}
-				amiSelector.Alias = v
+				amiSelector.Alias = alias
 			default:
 				return nil, fmt.Errorf("invalid ami selector key: %s", k)
 			}
@@ -104,6 +230,29 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 	return amiSelectors, nil
 }
 
+// parseCreationDate parses a creation date criterion: >date or <date bound the creation date, and anything else is an EC2 creation-date filter.
+// Dates are RFC 3339 timestamps or days like 2025-01-31, which start at midnight UTC.
+func (s *Selector) parseCreationDate(value string) error {
+	operator := value[:min(1, len(value))]
+	if operator != ">" && operator != "<" {
+		s.CreationDate = value
+		return nil
+	}
+	date, err := time.Parse(time.RFC3339, value[1:])
+	if err != nil {
+		date, err = time.Parse(time.DateOnly, value[1:])
+	}
+	if err != nil {
+		return fmt.Errorf("invalid ami selector creation-date:%s, expected a date like %s2025-01-31 or an RFC 3339 timestamp", value, operator)
+	}
+	if operator == ">" {
+		s.CreatedAfter = date
+	} else {
+		s.CreatedBefore = date
+	}
+	return nil
+}
+
 // NewWatcher creates a new AMI Watcher
 func NewWatcher(imageAPI SDKImageOps, ssmAPI SDKSSMOps) Watcher {
 	return Watcher{
@@ -116,18 +265,23 @@ func NewWatcher(imageAPI SDKImageOps, ssmAPI SDKSSMOps) Watcher {
 // Multiple calls to EC2 may be sent to resolve the selectors
 func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]AMI, error) {
 	var amis []AMI
-	// resolveAMIDetails is used to store the AMI IDs from SSM that should be Described later
-	var resolveAMIDetails []string
 	// run through each selector's filterset and retrieve the AMIs
 	for i, filters := range filterSets(selectors) {
+		term := selectors[i]
+		// resolveAMIDetails is used to store the AMI IDs from SSM that should be Described later
+		var resolveAMIDetails []string
 		// if an SSM AMI alias is specific, then resolve the AMI ID and add to the resolveAMIDetails to be resolved later
 		// Currently, an SSM path can only return one AMI ID
 		var paths []string
-		if selectors[i].Alias != "" {
-			paths = append(paths, aliases[selectors[i].Alias]...)
+		if term.Alias != "" {
+			_, aliasPaths, err := resolveAlias(term.Alias)
+			if err != nil {
+				return amis, err
+			}
+			paths = append(paths, aliasPaths...)
 		}
-		if selectors[i].SSM != "" {
-			paths = append(paths, selectors[i].SSM)
+		if term.SSM != "" {
+			paths = append(paths, term.SSM)
 		}
 		if len(paths) != 0 {
 			pathOut, err := w.ssmAPI.GetParameters(ctx, &ssm.GetParametersInput{
@@ -137,6 +291,10 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]AMI, erro
 				return amis, err
 			}
 
+			// SSM returns parameters that do not exist as invalid, e.g. the parameters of an alias version that was never published
+			if term.Alias != "" && len(pathOut.Parameters) == 0 {
+				return amis, fmt.Errorf("ami alias %s is not published in the region", term.Alias)
+			}
 			if len(pathOut.Parameters) != 0 {
 				resolveAMIDetails = append(resolveAMIDetails,
 					lo.Map(pathOut.Parameters, func(param ssmtypes.Parameter, _ int) string { return *param.Value })...)
@@ -144,61 +302,195 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]AMI, erro
 		}
 		// if there are no filters in this selector term and no AMI IDs to resolve from SSM, then return an error
 		// We have to account for the default owner-alias=self,amazon filter, so we need to check if there are more than one filter
-		if len(filters) <= 1 && len(resolveAMIDetails) == 0 {
+		if len(filters) <= 1 && len(paths) == 0 {
 			return amis, fmt.Errorf("no selectors provided for AMI selector")
 		}
-		// describe the AMIs based on the selector's filterset
-		pager := ec2.NewDescribeImagesPaginator(w.imageAPI, &ec2.DescribeImagesInput{
-			Filters: filters,
-		})
-		for pager.HasMorePages() {
-			page, err := pager.NextPage(ctx)
+		var termAMIs []AMI
+		// describe the AMIs based on the selector's filterset, unless the term only resolves SSM parameters
+		if len(filters) > 1 {
+			images, err := w.describeImages(ctx, &ec2.DescribeImagesInput{Filters: filters, IncludeDeprecated: lo.Ternary(term.Deprecated, aws.Bool(true), nil)})
 			if err != nil {
-				return nil, fmt.Errorf("failed to describe security groups: %w", err)
+				return nil, err
 			}
-
-			amis = append(amis, lo.Map(page.Images, func(sdkAMI ec2types.Image, _ int) AMI {
-				return AMI{sdkAMI}
-			})...)
+			termAMIs = images
 		}
 		// if there are AMI IDs to resolve from SSM, then describe them now
 		if len(resolveAMIDetails) != 0 {
-			amiCandidates := make([]AMI, 0, len(resolveAMIDetails))
-			pager := ec2.NewDescribeImagesPaginator(w.imageAPI, &ec2.DescribeImagesInput{
-				ImageIds: resolveAMIDetails,
-			})
-			for pager.HasMorePages() {
-				page, err := pager.NextPage(ctx)
-				if err != nil {
-					return nil, fmt.Errorf("failed to describe security groups: %w", err)
-				}
-
-				amiCandidates = append(amiCandidates, lo.Map(page.Images, func(sdkAMI ec2types.Image, _ int) AMI {
-					return AMI{sdkAMI}
-				})...)
+			amiCandidates, err := w.describeImages(ctx, &ec2.DescribeImagesInput{ImageIds: resolveAMIDetails})
+			if err != nil {
+				return nil, err
 			}
-			// if there were no filters in this selector term, then add all the AMIs from SSM
-			if len(filters) == 0 {
-				amis = append(amis, amiCandidates...)
+			if len(filters) <= 1 {
+				// if there were no filters in this selector term, then add all the AMIs from SSM
+				termAMIs = amiCandidates
 			} else {
 				// if there were filters in this selector term, then intersect the AMIs from SSM with the AMIs from the filters
-				amiIDs := lo.Map(amis, func(ami AMI, _ int) string { return *ami.ImageId })
-				amiCandidateIDs := lo.Map(amiCandidates, func(ami AMI, _ int) string { return *ami.ImageId })
-				filteredAMIs := lo.Intersect(amiIDs, amiCandidateIDs)
-				amis = lo.Map(filteredAMIs, func(id string, _ int) AMI {
-					for _, ami := range amiCandidates {
-						if *ami.ImageId == id {
-							return ami
-						}
-					}
-					return AMI{}
+				termAMIs = lo.Filter(amiCandidates, func(candidate AMI, _ int) bool {
+					return lo.ContainsBy(termAMIs, func(ami AMI) bool { return lo.FromPtr(ami.ImageId) == lo.FromPtr(candidate.ImageId) })
 				})
 			}
 		}
+		amis = append(amis, term.filter(termAMIs, time.Now())...)
 	}
 	return amis, nil
 }
 
+// describeImages describes every page of AMIs of the input
+func (w Watcher) describeImages(ctx context.Context, input *ec2.DescribeImagesInput) ([]AMI, error) {
+	var amis []AMI
+	pager := ec2.NewDescribeImagesPaginator(w.imageAPI, input)
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe images: %w", err)
+		}
+		amis = append(amis, lo.Map(page.Images, func(sdkAMI ec2types.Image, _ int) AMI {
+			return AMI{sdkAMI}
+		})...)
+	}
+	return amis, nil
+}
+
+// filter applies the parts of the selector term that EC2 can not filter by to the AMIs it described: deprecated AMIs are excluded
+// unless the term includes them or selects an ID, the creation date bounds are checked, and with Newest only the most recent AMI
+// of each architecture is kept
+func (s Selector) filter(amiList []AMI, now time.Time) []AMI {
+	amiList = lo.Filter(amiList, func(ami AMI, _ int) bool {
+		if !s.Deprecated && s.ID == "" && ami.IsDeprecated(now) {
+			return false
+		}
+		created, ok := ami.CreatedAt()
+		if !s.CreatedAfter.IsZero() && (!ok || !created.After(s.CreatedAfter)) {
+			return false
+		}
+		if !s.CreatedBefore.IsZero() && (!ok || !created.Before(s.CreatedBefore)) {
+			return false
+		}
+		return true
+	})
+	if !s.Newest {
+		return amiList
+	}
+	var newest []AMI
+	for _, architectureAMIs := range lo.GroupBy(amiList, func(ami AMI) ec2types.ArchitectureValues { return ami.Architecture }) {
+		newest = append(newest, lo.MaxBy(architectureAMIs, func(a, b AMI) bool { return lo.FromPtr(a.CreationDate) > lo.FromPtr(b.CreationDate) }))
+	}
+	// architectures are in a map, so the AMIs are ordered by architecture to be deterministic
+	slices.SortFunc(newest, func(a, b AMI) int { return strings.Compare(string(a.Architecture), string(b.Architecture)) })
+	return newest
+}
+
+// IsDeprecated is true if the AMI's deprecation time has passed
+func (a AMI) IsDeprecated(now time.Time) bool {
+	deprecatedAt, err := time.Parse(time.RFC3339, lo.FromPtr(a.DeprecationTime))
+	return err == nil && !deprecatedAt.After(now)
+}
+
+// CreatedAt returns when the AMI was created, false if EC2 did not return its creation date
+func (a AMI) CreatedAt() (time.Time, bool) {
+	created, err := time.Parse(time.RFC3339, lo.FromPtr(a.CreationDate))
+	return created, err == nil
+}
+
+// CreateImage creates an AMI from the instance with the name and returns its ID, the AMI and its snapshots are tagged with the tags.
+// EC2 reboots the instance so that its file systems are consistent, and the AMI is pending until its snapshots complete.
+func (w Watcher) CreateImage(ctx context.Context, instanceID, name, description string, tags map[string]string) (string, error) {
+	out, err := w.imageAPI.CreateImage(ctx, &ec2.CreateImageInput{
+		InstanceId:  aws.String(instanceID),
+		Name:        aws.String(name),
+		Description: lo.EmptyableToPtr(description),
+		TagSpecifications: []ec2types.TagSpecification{
+			{ResourceType: ec2types.ResourceTypeImage, Tags: tagutils.MapToEC2Tags(tags)},
+			{ResourceType: ec2types.ResourceTypeSnapshot, Tags: tagutils.MapToEC2Tags(tags)},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to create image %s from instance %s: %w", name, instanceID, err)
+	}
+	return lo.FromPtr(out.ImageId), nil
+}
+
+// WaitForAvailable waits until the AMI's snapshots completed and instances can be launched from it
+func (w Watcher) WaitForAvailable(ctx context.Context, imageID string, timeout time.Duration) error {
+	waiter := ec2.NewImageAvailableWaiter(w.imageAPI)
+	if err := waiter.Wait(ctx, &ec2.DescribeImagesInput{ImageIds: []string{imageID}}, timeout); err != nil {
+		return fmt.Errorf("image %s did not become available: %w", imageID, err)
+	}
+	return nil
+}
+
+// ResolveAlias returns the AMIs that the alias resolves to in the current region, one per architecture
+func (w Watcher) ResolveAlias(ctx context.Context, alias string) ([]AMI, error) {
+	alias, _, err := resolveAlias(alias)
+	if err != nil {
+		return nil, err
+	}
+	return w.Resolve(ctx, []Selector{{Alias: alias}})
+}
+
+// Aliases returns the names of the AMI aliases in sorted order, aliases that are published in several versions are listed as <name>[@version]
+func Aliases() []string {
+	names := append(lo.Keys(aliases), lo.Map(lo.Keys(aliasFamilies), func(name string, _ int) string { return name + "[@version]" })...)
+	slices.Sort(names)
+	return names
+}
+
+// resolveAlias returns the canonical name of the alias and the SSM parameters of its AMIs.
+// Versioned aliases are canonically name@version, name-version is accepted too and no version resolves the alias's default version.
+func resolveAlias(alias string) (string, []string, error) {
+	if paths, ok := aliases[alias]; ok {
+		return alias, paths, nil
+	}
+	name, version, versioned := strings.Cut(alias, "@")
+	if !versioned {
+		for familyName := range aliasFamilies {
+			if familyVersion, ok := strings.CutPrefix(alias, familyName+"-"); ok {
+				name, version = familyName, familyVersion
+			}
+		}
+	}
+	family, ok := aliasFamilies[name]
+	if !ok {
+		return "", nil, fmt.Errorf("invalid ami alias %s, must be one of %s", alias, strings.Join(Aliases(), ", "))
+	}
+	version = lo.CoalesceOrEmpty(version, family.defaultVersion)
+	if !family.version.MatchString(version) {
+		return "", nil, fmt.Errorf("invalid ami alias %s, %s versions must match %s", alias, name, family.version)
+	}
+	return name + "@" + version, family.paths(version), nil
+}
+
+// PerArchitecture returns the AMI that instances of each architecture are launched with, which is the first AMI of the architecture.
+// AMIs of other architectures, e.g. i386, are not launched.
+func PerArchitecture(amiList []AMI) []AMI {
+	return lo.FilterMap(launchArchitectures, func(architecture ec2types.ArchitectureValues, _ int) (AMI, bool) {
+		return lo.Find(amiList, func(ami AMI) bool { return ami.Architecture == architecture })
+	})
+}
+
+// ArchitectureIDs maps the architectures that instances are launched with to the ID of their AMI
+func ArchitectureIDs(amiList []AMI) map[string]string {
+	return lo.SliceToMap(PerArchitecture(amiList), func(ami AMI) (string, string) { return string(ami.Architecture), lo.FromPtr(ami.ImageId) })
+}
+
+// Prettify converts the AMI into a PrettyAMI
+func (a AMI) Prettify() PrettyAMI {
+	alias, _ := a.Alias()
+	return PrettyAMI{
+		Architecture: string(a.Architecture),
+		ID:           lo.FromPtr(a.ImageId),
+		Name:         lo.FromPtr(a.Name),
+		Alias:        alias,
+		Owner:        lo.CoalesceOrEmpty(lo.FromPtr(a.ImageOwnerAlias), lo.FromPtr(a.OwnerId)),
+		Created:      lo.FromPtr(a.CreationDate),
+	}
+}
+
+// PrettifyAll converts AMIs into PrettyAMIs
+func PrettifyAll(amiList []AMI) []PrettyAMI {
+	return lo.Map(amiList, func(ami AMI, _ int) PrettyAMI { return ami.Prettify() })
+}
+
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
 // Each filter is executed as a separate list call.
 // Terms within a Selector are AND'd and between Selectors are OR'd
@@ -236,9 +528,72 @@ func filterSets(selectorList []Selector) [][]ec2types.Filter {
 				Values: []string{term.Architecture},
 			})
 		}
+		if term.CreationDate != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("creation-date"),
+				Values: []string{term.CreationDate},
+			})
+		}
 
 		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
 		filterResult = append(filterResult, filters)
 	}
 	return filterResult
 }
+
+// Alias returns the alias that the Amazon owned AMI is published under, or false if it is not an alias AMI
+func (a AMI) Alias() (string, bool) {
+	if lo.FromPtr(a.ImageOwnerAlias) != "amazon" {
+		return "", false
+	}
+	for _, aliasNamePrefix := range aliasNamePrefixes {
+		if strings.HasPrefix(lo.FromPtr(a.Name), aliasNamePrefix.prefix) {
+			return aliasNamePrefix.alias, true
+		}
+	}
+	for _, aliasNamePattern := range aliasNamePatterns {
+		if match := aliasNamePattern.pattern.FindStringSubmatch(lo.FromPtr(a.Name)); match != nil {
+			return aliasNamePattern.family + "@" + match[1], true
+		}
+	}
+	return "", false
+}
+
+// ARM64VariantName returns the name of the arm64 build of an x86_64 AMI by replacing the last architecture in its name,
+// e.g. al2023-ami-2023.6.20250107.0-kernel-6.1-x86_64 becomes al2023-ami-2023.6.20250107.0-kernel-6.1-arm64.
+// It returns false if the name does not contain an x86_64 architecture.
+func ARM64VariantName(name string) (string, bool) {
+	index, token := -1, ""
+	for _, x86NameToken := range x86NameTokens {
+		if i := strings.LastIndex(name, x86NameToken); i > index {
+			index, token = i, x86NameToken
+		}
+	}
+	if index == -1 {
+		return "", false
+	}
+	return name[:index] + "arm64" + name[index+len(token):], true
+}
+
+// ResolveARM64Variant returns the arm64 AMI that was published with the x86_64 AMI by the same owner, or false if there is none
+func (w Watcher) ResolveARM64Variant(ctx context.Context, ami AMI) (AMI, bool, error) {
+	name, ok := ARM64VariantName(lo.FromPtr(ami.Name))
+	if !ok {
+		return AMI{}, false, nil
+	}
+	variants, err := w.Resolve(ctx, []Selector{{
+		Name:         name,
+		OwnerID:      lo.FromPtr(ami.ImageOwnerAlias),
+		Architecture: string(ec2types.ArchitectureValuesArm64),
+	}})
+	if err != nil {
+		return AMI{}, false, err
+	}
+	variants = lo.Filter(variants, func(variant AMI, _ int) bool {
+		return lo.FromPtr(variant.OwnerId) == lo.FromPtr(ami.OwnerId)
+	})
+	if len(variants) == 0 {
+		return AMI{}, false, nil
+	}
+	return variants[0], true, nil
+}
@@ -0,0 +1,281 @@
+package amis_test
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/samber/lo"
+)
+
+func TestARM64VariantName(t *testing.T) {
+	type testCases struct {
+		name       string
+		amiName    string
+		expected   string
+		expectedOK bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:       "al2023",
+			amiName:    "al2023-ami-2023.6.20250107.0-kernel-6.1-x86_64",
+			expected:   "al2023-ami-2023.6.20250107.0-kernel-6.1-arm64",
+			expectedOK: true,
+		},
+		{
+			name:       "al2 keeps the volume type suffix",
+			amiName:    "amzn2-ami-hvm-2.0.20250108.0-x86_64-gp2",
+			expected:   "amzn2-ami-hvm-2.0.20250108.0-arm64-gp2",
+			expectedOK: true,
+		},
+		{
+			name:       "ubuntu amd64",
+			amiName:    "ubuntu/images/hvm-ssd-gp3/ubuntu-noble-24.04-amd64-server-20250115",
+			expected:   "ubuntu/images/hvm-ssd-gp3/ubuntu-noble-24.04-arm64-server-20250115",
+			expectedOK: true,
+		},
+		{name: "no architecture", amiName: "my-golden-image-2025"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			name, ok := amis.ARM64VariantName(tc.amiName)
+			if ok != tc.expectedOK || name != tc.expected {
+				t.Errorf("expected %q, %t, got %q, %t", tc.expected, tc.expectedOK, name, ok)
+			}
+		})
+	}
+}
+
+func TestAlias(t *testing.T) {
+	type testCases struct {
+		name       string
+		ami        ec2types.Image
+		expected   string
+		expectedOK bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:       "al2023 minimal",
+			ami:        ec2types.Image{Name: aws.String("al2023-ami-minimal-2023.6.20250107.0-kernel-6.1-x86_64"), ImageOwnerAlias: aws.String("amazon")},
+			expected:   "al2023-minimal",
+			expectedOK: true,
+		},
+		{
+			name:       "al2023",
+			ami:        ec2types.Image{Name: aws.String("al2023-ami-2023.6.20250107.0-kernel-6.1-x86_64"), ImageOwnerAlias: aws.String("amazon")},
+			expected:   "al2023",
+			expectedOK: true,
+		},
+		{
+			name:       "dlami gpu",
+			ami:        ec2types.Image{Name: aws.String("Deep Learning Base OSS Nvidia Driver GPU AMI (Amazon Linux 2023) 20250110"), ImageOwnerAlias: aws.String("amazon")},
+			expected:   amis.AliasDLAMIGPU,
+			expectedOK: true,
+		},
+		{
+			name:       "bottlerocket",
+			ami:        ec2types.Image{Name: aws.String("bottlerocket-aws-k8s-1.31-x86_64-v1.26.1-943d9a41"), ImageOwnerAlias: aws.String("amazon")},
+			expected:   "bottlerocket@1.31",
+			expectedOK: true,
+		},
+		{
+			name:       "eks",
+			ami:        ec2types.Image{Name: aws.String("amazon-eks-node-al2023-arm64-standard-1.30-v20241024"), ImageOwnerAlias: aws.String("amazon")},
+			expected:   "eks@1.30",
+			expectedOK: true,
+		},
+		{
+			name:       "windows",
+			ami:        ec2types.Image{Name: aws.String("Windows_Server-2022-English-Full-Base-2025.01.15"), ImageOwnerAlias: aws.String("amazon")},
+			expected:   "windows@2022",
+			expectedOK: true,
+		},
+		{
+			name: "not owned by amazon",
+			ami:  ec2types.Image{Name: aws.String("al2023-ami-2023.6.20250107.0-kernel-6.1-x86_64")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			alias, ok := amis.AMI{Image: tc.ami}.Alias()
+			if ok != tc.expectedOK || alias != tc.expected {
+				t.Errorf("expected %q, %t, got %q, %t", tc.expected, tc.expectedOK, alias, ok)
+			}
+		})
+	}
+}
+
+func TestArchitectureIDs(t *testing.T) {
+	amiList := []amis.AMI{
+		{Image: ec2types.Image{ImageId: aws.String("ami-x86"), Architecture: ec2types.ArchitectureValuesX8664}},
+		{Image: ec2types.Image{ImageId: aws.String("ami-arm64"), Architecture: ec2types.ArchitectureValuesArm64}},
+		{Image: ec2types.Image{ImageId: aws.String("ami-arm64-older"), Architecture: ec2types.ArchitectureValuesArm64}},
+		{Image: ec2types.Image{ImageId: aws.String("ami-i386"), Architecture: ec2types.ArchitectureValuesI386}},
+	}
+	perArchitecture := amis.PerArchitecture(amiList)
+	if len(perArchitecture) != 2 || *perArchitecture[0].ImageId != "ami-arm64" || *perArchitecture[1].ImageId != "ami-x86" {
+		t.Errorf("expected the first arm64 and x86_64 AMIs, got %+v", perArchitecture)
+	}
+	expected := map[string]string{"arm64": "ami-arm64", "x86_64": "ami-x86"}
+	if ids := amis.ArchitectureIDs(amiList); !maps.Equal(ids, expected) {
+		t.Errorf("expected %v, got %v", expected, ids)
+	}
+}
+
+type fakeEC2 struct {
+	amis.SDKImageOps
+	images      []ec2types.Image
+	input       *ec2.DescribeImagesInput
+	createInput *ec2.CreateImageInput
+}
+
+func (f *fakeEC2) DescribeImages(_ context.Context, input *ec2.DescribeImagesInput, _ ...func(*ec2.Options)) (*ec2.DescribeImagesOutput, error) {
+	f.input = input
+	return &ec2.DescribeImagesOutput{Images: f.images}, nil
+}
+
+func (f *fakeEC2) CreateImage(_ context.Context, input *ec2.CreateImageInput, _ ...func(*ec2.Options)) (*ec2.CreateImageOutput, error) {
+	f.createInput = input
+	return &ec2.CreateImageOutput{ImageId: aws.String("ami-baked")}, nil
+}
+
+func TestCreateImage(t *testing.T) {
+	fake := &fakeEC2{}
+	imageID, err := amis.NewWatcher(fake, nil).CreateImage(context.Background(), "i-builder", "web-v2", "", map[string]string{"nimbus-Name": "web"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if imageID != "ami-baked" || *fake.createInput.InstanceId != "i-builder" || *fake.createInput.Name != "web-v2" || fake.createInput.Description != nil {
+		t.Errorf("expected ami-baked created from i-builder without a description, got %s from %+v", imageID, fake.createInput)
+	}
+	resourceTypes := lo.Map(fake.createInput.TagSpecifications, func(spec ec2types.TagSpecification, _ int) ec2types.ResourceType { return spec.ResourceType })
+	if !slices.Equal(resourceTypes, []ec2types.ResourceType{ec2types.ResourceTypeImage, ec2types.ResourceTypeSnapshot}) {
+		t.Errorf("expected the image and its snapshots to be tagged, got %v", resourceTypes)
+	}
+}
+
+func TestParseSelectorsCreationDate(t *testing.T) {
+	selectors, err := amis.ParseSelectors("name:my-app-*,newest:true,creation-date:>2025-01-01;name:other-*,deprecated:true,creation-date:2025-02-*")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !selectors[0].Newest || !selectors[0].CreatedAfter.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected the newest AMI created after 2025-01-01, got %+v", selectors[0])
+	}
+	if !selectors[1].Deprecated || selectors[1].CreationDate != "2025-02-*" {
+		t.Errorf("expected deprecated AMIs created in February 2025, got %+v", selectors[1])
+	}
+	for _, selectorStr := range []string{"name:my-app-*,newest:yes", "name:my-app-*,creation-date:>yesterday"} {
+		if _, err := amis.ParseSelectors(selectorStr); err == nil {
+			t.Errorf("expected an error for %s", selectorStr)
+		}
+	}
+}
+
+func TestResolveNewest(t *testing.T) {
+	image := func(id string, arch ec2types.ArchitectureValues, created, deprecated string) ec2types.Image {
+		return ec2types.Image{ImageId: aws.String(id), Architecture: arch, CreationDate: aws.String(created), DeprecationTime: lo.EmptyableToPtr(deprecated)}
+	}
+	fake := &fakeEC2{images: []ec2types.Image{
+		image("ami-x86-old", ec2types.ArchitectureValuesX8664, "2025-01-10T00:00:00.000Z", ""),
+		image("ami-x86-deprecated", ec2types.ArchitectureValuesX8664, "2025-03-10T00:00:00.000Z", "2025-03-11T00:00:00.000Z"),
+		image("ami-x86", ec2types.ArchitectureValuesX8664, "2025-02-10T00:00:00.000Z", ""),
+		image("ami-arm64", ec2types.ArchitectureValuesArm64, "2025-02-01T00:00:00.000Z", ""),
+		image("ami-arm64-ancient", ec2types.ArchitectureValuesArm64, "2024-02-01T00:00:00.000Z", ""),
+	}}
+	watcher := amis.NewWatcher(fake, nil)
+
+	selectors, err := amis.ParseSelectors("name:my-app-*,newest:true,creation-date:>2025-01-01")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	amiList, err := watcher.Resolve(context.Background(), selectors)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ids := lo.Map(amiList, func(ami amis.AMI, _ int) string { return *ami.ImageId })
+	if !slices.Equal(ids, []string{"ami-arm64", "ami-x86"}) {
+		t.Errorf("expected the newest AMI of each architecture that is not deprecated, got %v", ids)
+	}
+
+	selectors[0].Deprecated = true
+	amiList, err = watcher.Resolve(context.Background(), selectors)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(amiList) != 2 || *amiList[1].ImageId != "ami-x86-deprecated" || !lo.FromPtr(fake.input.IncludeDeprecated) {
+		t.Errorf("expected deprecated AMIs to be included, got %+v", amiList)
+	}
+}
+
+// fakeSSM returns the parameters that it has and the rest as invalid
+type fakeSSM map[string]string
+
+func (f fakeSSM) GetParameters(_ context.Context, input *ssm.GetParametersInput, _ ...func(*ssm.Options)) (*ssm.GetParametersOutput, error) {
+	out := &ssm.GetParametersOutput{}
+	for _, name := range input.Names {
+		if value, ok := f[name]; ok {
+			out.Parameters = append(out.Parameters, ssmtypes.Parameter{Name: aws.String(name), Value: aws.String(value)})
+		} else {
+			out.InvalidParameters = append(out.InvalidParameters, name)
+		}
+	}
+	return out, nil
+}
+
+func TestParseSelectorsAlias(t *testing.T) {
+	for selectorStr, expected := range map[string]string{
+		"alias:al2023":        "al2023",
+		"alias:ubuntu":        "ubuntu@24.04",
+		"alias:ubuntu-22.04":  "ubuntu@22.04",
+		"alias:ubuntu@22.04":  "ubuntu@22.04",
+		"alias:bottlerocket":  "bottlerocket@1.31",
+		"alias:eks-1.30":      "eks@1.30",
+		"alias:windows-2019":  "windows@2019",
+		"alias:windows@2022":  "windows@2022",
+		"alias:dlami-gpu":     amis.AliasDLAMIGPU,
+		"alias:eks@1.31,id:x": "eks@1.31",
+	} {
+		selectors, err := amis.ParseSelectors(selectorStr)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", selectorStr, err)
+		}
+		if selectors[0].Alias != expected {
+			t.Errorf("%s: expected alias %s, got %s", selectorStr, expected, selectors[0].Alias)
+		}
+	}
+	for _, selectorStr := range []string{"alias:debian", "alias:ubuntu@jammy", "alias:eks@2.0", "alias:windows-22", "alias:al2023@2023"} {
+		if _, err := amis.ParseSelectors(selectorStr); err == nil {
+			t.Errorf("expected an error for %s", selectorStr)
+		}
+	}
+}
+
+func TestResolveAlias(t *testing.T) {
+	fake := &fakeEC2{images: []ec2types.Image{
+		{ImageId: aws.String("ami-ubuntu-arm64"), Architecture: ec2types.ArchitectureValuesArm64},
+		{ImageId: aws.String("ami-ubuntu-amd64"), Architecture: ec2types.ArchitectureValuesX8664},
+	}}
+	watcher := amis.NewWatcher(fake, fakeSSM{
+		"/aws/service/canonical/ubuntu/server/22.04/stable/current/arm64/hvm/ebs-gp2/ami-id": "ami-ubuntu-arm64",
+		"/aws/service/canonical/ubuntu/server/22.04/stable/current/amd64/hvm/ebs-gp2/ami-id": "ami-ubuntu-amd64",
+	})
+	amiList, err := watcher.ResolveAlias(context.Background(), "ubuntu-22.04")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(amiList) != 2 || !slices.Equal(fake.input.ImageIds, []string{"ami-ubuntu-arm64", "ami-ubuntu-amd64"}) {
+		t.Errorf("expected the AMIs of the ubuntu 22.04 parameters, got %+v", fake.input.ImageIds)
+	}
+	if _, err := watcher.ResolveAlias(context.Background(), "ubuntu@18.04"); err == nil {
+		t.Errorf("expected an error for an alias version that is not published")
+	}
+}
@@ -0,0 +1,93 @@
+package eips
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/retry"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/samber/lo"
+)
+
+// Watcher discovers Elastic IPs based on selectors
+type Watcher struct {
+	ec2API SDKEIPOps
+}
+
+// SDKEIPOps is an interface that combines the necessary EC2 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKEIPOps interface {
+	DescribeAddresses(context.Context, *ec2.DescribeAddressesInput, ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error)
+	ReleaseAddress(context.Context, *ec2.ReleaseAddressInput, ...func(*ec2.Options)) (*ec2.ReleaseAddressOutput, error)
+}
+
+// Selector is a struct that represents an Elastic IP selector
+type Selector struct {
+	Tags map[string]string
+	// ID is the allocation ID of the Elastic IP
+	ID string
+}
+
+// ElasticIP represents an AWS Elastic IP address
+// This is not the AWS SDK Address type, but a wrapper around it so that we can add additional data
+type ElasticIP struct {
+	ec2types.Address
+}
+
+// NewWatcher creates a new Elastic IP Watcher
+func NewWatcher(ec2API SDKEIPOps) Watcher {
+	return Watcher{
+		ec2API: ec2API,
+	}
+}
+
+// Resolve returns a list of Elastic IPs that match the provided selectors
+// Multiple calls to EC2 may be sent to resolve the selectors
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]ElasticIP, error) {
+	var eips []ElasticIP
+	for _, filters := range filterSets(selectors) {
+		// DescribeAddresses is not paginated
+		out, err := w.ec2API.DescribeAddresses(ctx, &ec2.DescribeAddressesInput{
+			Filters: filters,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe Elastic IPs: %w", err)
+		}
+		eips = append(eips, lo.Map(out.Addresses, func(address ec2types.Address, _ int) ElasticIP { return ElasticIP{address} })...)
+	}
+	return eips, nil
+}
+
+// Release releases the Elastic IP's allocation, it must not be associated with a network interface
+func (w Watcher) Release(ctx context.Context, allocationID string) error {
+	if err := retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.ec2API.ReleaseAddress(ctx, &ec2.ReleaseAddressInput{AllocationId: aws.String(allocationID)})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to release Elastic IP %s: %w", allocationID, err)
+	}
+	return nil
+}
+
+// filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
+// Each filter is executed as a separate list call.
+// Terms within a Selector are AND'd and between Selectors are OR'd
+func filterSets(selectorList []Selector) [][]ec2types.Filter {
+	var filterResult [][]ec2types.Filter
+	for _, term := range selectorList {
+		filters := []ec2types.Filter{}
+		if term.ID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("allocation-id"),
+				Values: []string{term.ID},
+			})
+		}
+		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
+		filterResult = append(filterResult, filters)
+	}
+	return filterResult
+}
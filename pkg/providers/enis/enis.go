@@ -0,0 +1,192 @@
+package enis
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/samber/lo"
+)
+
+const (
+	// releasePollInterval is how often network interfaces are described while waiting for them to be released
+	releasePollInterval = 5 * time.Second
+)
+
+// Watcher discovers elastic network interfaces based on selectors
+type Watcher struct {
+	eniAPI SDKENIOps
+}
+
+// SDKENIOps is an interface that combines the necessary EC2 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKENIOps interface {
+	ec2.DescribeNetworkInterfacesAPIClient
+}
+
+// Selector is a struct that represents a network interface selector
+type Selector struct {
+	Tags            map[string]string
+	ID              string
+	SubnetID        string
+	SecurityGroupID string
+	VPCID           string
+	// InstanceID selects network interfaces attached to the instance
+	InstanceID string
+	// Status is one of: available | associated | attaching | in-use | detaching
+	Status string
+}
+
+// NetworkInterface represents an AWS Elastic Network Interface
+// This is not the AWS SDK NetworkInterface type, but a wrapper around it so that we can add additional data
+type NetworkInterface struct {
+	ec2types.NetworkInterface
+}
+
+// ParseSelectors parses a string of selectors into a slice of Selector structs
+func ParseSelectors(selectorStr string) ([]Selector, error) {
+	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse network interface selectors: %w", err)
+	}
+	eniSelectors := make([]Selector, 0, len(selectors))
+	for _, selector := range selectors {
+		eniSelector := Selector{
+			Tags: selector.Tags,
+		}
+		for k, v := range selector.KeyVals {
+			switch k {
+			case "id":
+				eniSelector.ID = v
+			case "subnet-id":
+				eniSelector.SubnetID = v
+			case "security-group-id":
+				eniSelector.SecurityGroupID = v
+			case "vpc-id":
+				eniSelector.VPCID = v
+			case "instance-id":
+				eniSelector.InstanceID = v
+			case "status":
+				eniSelector.Status = v
+			default:
+				return nil, fmt.Errorf("invalid network interface selector key: %s", k)
+			}
+		}
+		eniSelectors = append(eniSelectors, eniSelector)
+	}
+	return eniSelectors, nil
+}
+
+// NewWatcher creates a new Network Interface Watcher
+func NewWatcher(eniAPI SDKENIOps) Watcher {
+	return Watcher{
+		eniAPI: eniAPI,
+	}
+}
+
+// Resolve returns a list of network interfaces that match the provided selectors
+// Multiple calls to EC2 may be sent to resolve the selectors
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]NetworkInterface, error) {
+	var networkInterfaces []NetworkInterface
+	for _, filters := range filterSets(selectors) {
+		pager := ec2.NewDescribeNetworkInterfacesPaginator(w.eniAPI, &ec2.DescribeNetworkInterfacesInput{
+			Filters: filters,
+		})
+		for pager.HasMorePages() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe network interfaces: %w", err)
+			}
+			networkInterfaces = append(networkInterfaces, lo.Map(page.NetworkInterfaces, func(sdkENI ec2types.NetworkInterface, _ int) NetworkInterface {
+				return NetworkInterface{sdkENI}
+			})...)
+		}
+	}
+	return lo.UniqBy(networkInterfaces, func(eni NetworkInterface) string { return lo.FromPtr(eni.NetworkInterfaceId) }), nil
+}
+
+// WaitForRelease polls until none of the network interfaces matching the selectors are attached to one of the instanceIDs or the timeout elapses.
+// Network interfaces of terminated instances linger for a short period and block deletion of their subnet and security groups.
+// The network interfaces that are still attached when the timeout elapses are returned rather than an error so that the caller can decide how to proceed.
+func (w Watcher) WaitForRelease(ctx context.Context, selectors []Selector, instanceIDs []string, timeout time.Duration) ([]NetworkInterface, error) {
+	if len(selectors) == 0 || len(instanceIDs) == 0 {
+		return nil, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(releasePollInterval)
+	defer ticker.Stop()
+	for {
+		networkInterfaces, err := w.Resolve(ctx, selectors)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, nil
+			}
+			return nil, err
+		}
+		pending := lo.Filter(networkInterfaces, func(eni NetworkInterface, _ int) bool {
+			return eni.Attachment != nil && lo.Contains(instanceIDs, lo.FromPtr(eni.Attachment.InstanceId))
+		})
+		if len(pending) == 0 {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return pending, nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
+// Each filter is executed as a separate list call.
+// Terms within a Selector are AND'd and between Selectors are OR'd
+func filterSets(selectorList []Selector) [][]ec2types.Filter {
+	var filterResult [][]ec2types.Filter
+	for _, term := range selectorList {
+		filters := []ec2types.Filter{}
+		if term.ID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("network-interface-id"),
+				Values: []string{term.ID},
+			})
+		}
+		if term.SubnetID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("subnet-id"),
+				Values: []string{term.SubnetID},
+			})
+		}
+		if term.SecurityGroupID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("group-id"),
+				Values: []string{term.SecurityGroupID},
+			})
+		}
+		if term.VPCID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("vpc-id"),
+				Values: []string{term.VPCID},
+			})
+		}
+		if term.InstanceID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("attachment.instance-id"),
+				Values: []string{term.InstanceID},
+			})
+		}
+		if term.Status != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("status"),
+				Values: []string{term.Status},
+			})
+		}
+		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
+		filterResult = append(filterResult, filters)
+	}
+	return filterResult
+}
@@ -0,0 +1,251 @@
+package fis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsfis "github.com/aws/aws-sdk-go-v2/service/fis"
+	fistypes "github.com/aws/aws-sdk-go-v2/service/fis/types"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// FaultCPUStress loads the CPUs of the instances with an SSM command
+	FaultCPUStress = "cpu-stress"
+	// FaultNetworkLatency delays the network traffic of the instances with an SSM command
+	FaultNetworkLatency = "network-latency"
+	// FaultInstanceStop stops the instances and starts them again once the experiment's duration is over
+	FaultInstanceStop = "instance-stop"
+
+	// ActionSendCommand runs an SSM document on the instances, it requires the instances to be registered with SSM
+	ActionSendCommand = "aws:ssm:send-command"
+	// ActionStopInstances stops the instances and optionally starts them again after a duration
+	ActionStopInstances = "aws:ec2:stop-instances"
+
+	// DefaultLoadPercent is the CPU load of the cpu-stress fault
+	DefaultLoadPercent = 100
+	// DefaultLatency is the delay of the network-latency fault
+	DefaultLatency = 200 * time.Millisecond
+
+	resourceTypeInstance   = "aws:ec2:instance"
+	cpuStressDocument      = "AWSFIS-Run-CPU-Stress"
+	networkLatencyDocument = "AWSFIS-Run-Network-Latency"
+	instancesTarget        = "Instances"
+)
+
+// Faults are the faults that nimbus creates experiment templates for
+var Faults = []string{FaultCPUStress, FaultNetworkLatency, FaultInstanceStop}
+
+// ExperimentTemplateOptions configure an experiment template that injects a fault into the running instances with the target tags
+type ExperimentTemplateOptions struct {
+	// Fault is cpu-stress, network-latency, or instance-stop
+	Fault string
+	// Region of the instances
+	Region string
+	// RoleARN is the IAM role that FIS assumes to run the experiment. It must allow ssm:SendCommand for the SSM faults or ec2:StopInstances
+	// and ec2:StartInstances for instance-stop on the instances
+	RoleARN string
+	// TargetTags select the instances, only instances that are running when an experiment starts are targeted
+	TargetTags map[string]string
+	// Count is how many of the selected instances an experiment picks at random, 0 targets all of them
+	Count int
+	// Duration is how long the fault lasts
+	Duration time.Duration
+	// LoadPercent is the CPU load of cpu-stress, defaults to DefaultLoadPercent
+	LoadPercent int
+	// Latency is the delay of network-latency, defaults to DefaultLatency
+	Latency time.Duration
+	// Tags are applied to the experiment template and the experiments started from it
+	Tags map[string]string
+}
+
+// PrettyExperimentTemplate is the table representation of an ExperimentTemplate
+type PrettyExperimentTemplate struct {
+	ID          string `table:"ID"`
+	Name        string `table:"Name"`
+	Fault       string `table:"Fault"`
+	Description string `table:"Description,wide"`
+}
+
+// Prettify returns the table representation of the experiment template
+func (t ExperimentTemplate) Prettify() PrettyExperimentTemplate {
+	return PrettyExperimentTemplate{
+		ID:          t.ID,
+		Name:        t.Tags["Name"],
+		Fault:       t.Tags[tagutils.FaultTagKey],
+		Description: t.Description,
+	}
+}
+
+// IsDone returns true if the experiment completed, stopped, or failed
+func (e Experiment) IsDone() bool {
+	return lo.Contains([]string{StatusCompleted, StatusStopped, StatusFailed}, e.State.Status)
+}
+
+// CreateExperimentTemplate creates an experiment template that injects the fault into the instances with the target tags.
+// The template is tagged with its fault so that it can be told apart from the other templates of the instances.
+func (w Watcher) CreateExperimentTemplate(ctx context.Context, opts ExperimentTemplateOptions) (ExperimentTemplate, error) {
+	if opts.Duration <= 0 {
+		return ExperimentTemplate{}, fmt.Errorf("the duration of the fault must be positive, got %s", opts.Duration)
+	}
+	if len(opts.TargetTags) == 0 {
+		return ExperimentTemplate{}, fmt.Errorf("no target tags to select the instances of the experiment with")
+	}
+	action, err := w.faultAction(ctx, opts)
+	if err != nil {
+		return ExperimentTemplate{}, err
+	}
+	selectionMode := "ALL"
+	if opts.Count > 0 {
+		selectionMode = fmt.Sprintf("COUNT(%d)", opts.Count)
+	}
+	tags := lo.Assign(opts.Tags, map[string]string{tagutils.FaultTagKey: opts.Fault})
+	out, err := w.fisAPI.CreateExperimentTemplate(ctx, &awsfis.CreateExperimentTemplateInput{
+		ClientToken:    aws.String(clientToken()),
+		Description:    aws.String(fmt.Sprintf("nimbus %s of %s", opts.Fault, tags["Name"])),
+		RoleArn:        aws.String(opts.RoleARN),
+		StopConditions: []fistypes.CreateExperimentTemplateStopConditionInput{{Source: aws.String("none")}},
+		Targets: map[string]fistypes.CreateExperimentTemplateTargetInput{
+			instancesTarget: {
+				ResourceType:  aws.String(resourceTypeInstance),
+				ResourceTags:  opts.TargetTags,
+				Filters:       []fistypes.ExperimentTemplateTargetInputFilter{{Path: aws.String("State.Name"), Values: []string{"running"}}},
+				SelectionMode: aws.String(selectionMode),
+			},
+		},
+		Actions: map[string]fistypes.CreateExperimentTemplateActionInput{opts.Fault: action},
+		Tags:    tags,
+	})
+	if err != nil {
+		return ExperimentTemplate{}, fmt.Errorf("failed to create experiment template: %w", err)
+	}
+	template := lo.FromPtr(out.ExperimentTemplate)
+	return newExperimentTemplate(template.Id, template.Description, template.Tags), nil
+}
+
+// StartExperiment starts an experiment from the experiment template, the experiment is tagged with the template's tags
+func (w Watcher) StartExperiment(ctx context.Context, template ExperimentTemplate) (Experiment, error) {
+	out, err := w.fisAPI.StartExperiment(ctx, &awsfis.StartExperimentInput{
+		ClientToken:          aws.String(clientToken()),
+		ExperimentTemplateId: aws.String(template.ID),
+		Tags:                 template.Tags,
+	})
+	if err != nil {
+		return Experiment{}, fmt.Errorf("failed to start experiment of template %s: %w", template.ID, err)
+	}
+	started := lo.FromPtr(out.Experiment)
+	experiment := newExperiment(started.Id, started.ExperimentTemplateId, started.State, started.Tags)
+	experiment.ExperimentTemplateID = lo.CoalesceOrEmpty(experiment.ExperimentTemplateID, template.ID)
+	return experiment, nil
+}
+
+// GetExperiment returns the experiment
+func (w Watcher) GetExperiment(ctx context.Context, experimentID string) (Experiment, error) {
+	out, err := w.fisAPI.GetExperiment(ctx, &awsfis.GetExperimentInput{Id: aws.String(experimentID)})
+	if err != nil {
+		return Experiment{}, fmt.Errorf("failed to get experiment %s: %w", experimentID, err)
+	}
+	experiment := lo.FromPtr(out.Experiment)
+	return newExperiment(experiment.Id, experiment.ExperimentTemplateId, experiment.State, experiment.Tags), nil
+}
+
+// StopExperiment stops the experiment, an experiment that is already done is returned as it is
+func (w Watcher) StopExperiment(ctx context.Context, experimentID string) (Experiment, error) {
+	experiment, err := w.GetExperiment(ctx, experimentID)
+	if err != nil || experiment.IsDone() {
+		return experiment, err
+	}
+	out, err := w.fisAPI.StopExperiment(ctx, &awsfis.StopExperimentInput{Id: aws.String(experimentID)})
+	if err != nil {
+		return Experiment{}, fmt.Errorf("failed to stop experiment %s: %w", experimentID, err)
+	}
+	stopped := lo.FromPtr(out.Experiment)
+	return newExperiment(stopped.Id, stopped.ExperimentTemplateId, stopped.State, stopped.Tags), nil
+}
+
+// ResolveExperimentTemplates returns the experiment templates that have all of the tags
+func (w Watcher) ResolveExperimentTemplates(ctx context.Context, tags map[string]string) ([]ExperimentTemplate, error) {
+	var templates []ExperimentTemplate
+	paginator := awsfis.NewListExperimentTemplatesPaginator(w.fisAPI, &awsfis.ListExperimentTemplatesInput{MaxResults: aws.Int32(100)})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list experiment templates: %w", err)
+		}
+		// FIS can not filter templates by tags, so they are filtered here
+		for _, template := range out.ExperimentTemplates {
+			if lo.Every(lo.Entries(template.Tags), lo.Entries(tags)) {
+				templates = append(templates, newExperimentTemplate(template.Id, template.Description, template.Tags))
+			}
+		}
+	}
+	return templates, nil
+}
+
+// ResolveExperiments returns the experiments that were started from the experiment template
+func (w Watcher) ResolveExperiments(ctx context.Context, templateID string) ([]Experiment, error) {
+	var experiments []Experiment
+	paginator := awsfis.NewListExperimentsPaginator(w.fisAPI, &awsfis.ListExperimentsInput{ExperimentTemplateId: aws.String(templateID), MaxResults: aws.Int32(100)})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list experiments of template %s: %w", templateID, err)
+		}
+		experiments = append(experiments, lo.Map(out.Experiments, func(summary fistypes.ExperimentSummary, _ int) Experiment {
+			return newExperiment(summary.Id, summary.ExperimentTemplateId, summary.State, summary.Tags)
+		})...)
+	}
+	return experiments, nil
+}
+
+// faultAction returns the experiment template action that injects the fault of opts into the instances target
+func (w Watcher) faultAction(ctx context.Context, opts ExperimentTemplateOptions) (fistypes.CreateExperimentTemplateActionInput, error) {
+	targets := map[string]string{instancesTarget: instancesTarget}
+	switch opts.Fault {
+	case FaultInstanceStop:
+		return fistypes.CreateExperimentTemplateActionInput{
+			ActionId:   aws.String(ActionStopInstances),
+			Parameters: map[string]string{"startInstancesAfterDuration": isoDuration(opts.Duration)},
+			Targets:    targets,
+		}, nil
+	case FaultCPUStress, FaultNetworkLatency:
+		documentParameters := map[string]string{
+			"DurationSeconds":     strconv.Itoa(int(opts.Duration.Seconds())),
+			"InstallDependencies": "True",
+		}
+		document := cpuStressDocument
+		if opts.Fault == FaultCPUStress {
+			if opts.LoadPercent < 0 || opts.LoadPercent > 100 {
+				return fistypes.CreateExperimentTemplateActionInput{}, fmt.Errorf("the CPU load must be between 0 and 100 percent, got %d", opts.LoadPercent)
+			}
+			documentParameters["LoadPercent"] = strconv.Itoa(lo.CoalesceOrEmpty(opts.LoadPercent, DefaultLoadPercent))
+		} else {
+			document = networkLatencyDocument
+			documentParameters["DelayMilliseconds"] = strconv.FormatInt(lo.CoalesceOrEmpty(opts.Latency, DefaultLatency).Milliseconds(), 10)
+		}
+		encodedParameters, err := json.Marshal(documentParameters)
+		if err != nil {
+			return fistypes.CreateExperimentTemplateActionInput{}, err
+		}
+		partition, _, err := w.callerAccount(ctx)
+		if err != nil {
+			return fistypes.CreateExperimentTemplateActionInput{}, err
+		}
+		return fistypes.CreateExperimentTemplateActionInput{
+			ActionId: aws.String(ActionSendCommand),
+			Parameters: map[string]string{
+				// the AWSFIS documents are owned by AWS, so their ARNs have no account
+				"documentArn":        fmt.Sprintf("arn:%s:ssm:%s::document/%s", partition, opts.Region, document),
+				"documentParameters": string(encodedParameters),
+				"duration":           isoDuration(opts.Duration),
+			},
+			Targets: targets,
+		}, nil
+	}
+	return fistypes.CreateExperimentTemplateActionInput{}, fmt.Errorf("invalid fault %q, must be one of %v", opts.Fault, Faults)
+}
@@ -0,0 +1,268 @@
+// Package fis runs AWS Fault Injection Service experiments against the instances of a VM to test how its workload handles failures.
+//
+// Every experiment is started from an experiment template that nimbus creates for it and deletes once the experiment is done.
+package fis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsfis "github.com/aws/aws-sdk-go-v2/service/fis"
+	fistypes "github.com/aws/aws-sdk-go-v2/service/fis/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
+)
+
+const (
+	// ActionSendSpotInstanceInterruptions sends spot instances an interruption notice and interrupts them once the notice is over
+	ActionSendSpotInstanceInterruptions = "aws:ec2:send-spot-instance-interruptions"
+
+	// MinInterruptionNotice is the shortest interruption notice that FIS sends, which is also the notice of a real interruption
+	MinInterruptionNotice = 2 * time.Minute
+
+	// Experiment statuses that an experiment does not leave
+	StatusCompleted = "completed"
+	StatusStopped   = "stopped"
+	StatusFailed    = "failed"
+
+	resourceTypeSpotInstance = "aws:ec2:spot-instance"
+	experimentPollInterval   = 5 * time.Second
+)
+
+// Watcher runs FIS experiments
+type Watcher struct {
+	fisAPI SDKFISOps
+	stsAPI SDKSTSOps
+}
+
+// SDKFISOps is an interface that combines the necessary FIS SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKFISOps interface {
+	awsfis.ListExperimentTemplatesAPIClient
+	awsfis.ListExperimentsAPIClient
+	CreateExperimentTemplate(context.Context, *awsfis.CreateExperimentTemplateInput, ...func(*awsfis.Options)) (*awsfis.CreateExperimentTemplateOutput, error)
+	DeleteExperimentTemplate(context.Context, *awsfis.DeleteExperimentTemplateInput, ...func(*awsfis.Options)) (*awsfis.DeleteExperimentTemplateOutput, error)
+	StartExperiment(context.Context, *awsfis.StartExperimentInput, ...func(*awsfis.Options)) (*awsfis.StartExperimentOutput, error)
+	GetExperiment(context.Context, *awsfis.GetExperimentInput, ...func(*awsfis.Options)) (*awsfis.GetExperimentOutput, error)
+	StopExperiment(context.Context, *awsfis.StopExperimentInput, ...func(*awsfis.Options)) (*awsfis.StopExperimentOutput, error)
+}
+
+// SDKSTSOps is the STS SDK client method that resolves the account of the instances that experiments target
+type SDKSTSOps interface {
+	GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+// ExperimentTemplate is a FIS experiment template
+type ExperimentTemplate struct {
+	ID          string            `json:"id"`
+	Description string            `json:"description"`
+	Tags        map[string]string `json:"tags"`
+}
+
+// ExperimentState is the status of an experiment and why it has it
+type ExperimentState struct {
+	// Status is one of: pending | initiating | running | completed | stopping | stopped | failed
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Experiment is a run of a FIS experiment template
+type Experiment struct {
+	ID                   string            `json:"id"`
+	ExperimentTemplateID string            `json:"experimentTemplateId"`
+	State                ExperimentState   `json:"state"`
+	Tags                 map[string]string `json:"tags,omitempty"`
+}
+
+// InterruptOptions configure an experiment that interrupts spot instances
+type InterruptOptions struct {
+	// Region of the instances
+	Region      string
+	InstanceIDs []string
+	// RoleARN is the IAM role that FIS assumes to run the experiment, it must allow ec2:SendSpotInstanceInterruptions on the instances
+	RoleARN string
+	// Notice is how long the instances are notified before they are interrupted, at least MinInterruptionNotice
+	Notice time.Duration
+	// Tags are applied to the experiment template and the experiment
+	Tags map[string]string
+}
+
+// NewWatcher creates a new FIS Watcher
+func NewWatcher(fisAPI SDKFISOps, stsAPI SDKSTSOps) Watcher {
+	return Watcher{
+		fisAPI: fisAPI,
+		stsAPI: stsAPI,
+	}
+}
+
+// InterruptSpotInstances starts an experiment that sends the spot instances an interruption notice and interrupts them once the notice is over,
+// the same way that EC2 reclaims spot capacity. The experiment template is deleted if the experiment can not be started,
+// otherwise it is deleted with DeleteExperimentTemplate once the experiment is done.
+func (w Watcher) InterruptSpotInstances(ctx context.Context, opts InterruptOptions) (Experiment, error) {
+	notice := lo.CoalesceOrEmpty(opts.Notice, MinInterruptionNotice)
+	if notice < MinInterruptionNotice {
+		return Experiment{}, fmt.Errorf("the interruption notice must be at least %s, got %s", MinInterruptionNotice, notice)
+	}
+	instanceARNs, err := w.instanceARNs(ctx, opts.Region, opts.InstanceIDs)
+	if err != nil {
+		return Experiment{}, err
+	}
+	return w.startExperiment(ctx, &awsfis.CreateExperimentTemplateInput{
+		Description:    aws.String(fmt.Sprintf("nimbus spot interruption of %s", strings.Join(opts.InstanceIDs, ", "))),
+		RoleArn:        aws.String(opts.RoleARN),
+		StopConditions: []fistypes.CreateExperimentTemplateStopConditionInput{{Source: aws.String("none")}},
+		Targets: map[string]fistypes.CreateExperimentTemplateTargetInput{
+			"SpotInstances": {ResourceType: aws.String(resourceTypeSpotInstance), ResourceArns: instanceARNs, SelectionMode: aws.String("ALL")},
+		},
+		Actions: map[string]fistypes.CreateExperimentTemplateActionInput{
+			// the notice is taken in whole minutes
+			"interrupt": {
+				ActionId:   aws.String(ActionSendSpotInstanceInterruptions),
+				Parameters: map[string]string{"durationBeforeInterruption": isoDuration(notice.Round(time.Minute))},
+				Targets:    map[string]string{"SpotInstances": "SpotInstances"},
+			},
+		},
+		Tags: opts.Tags,
+	})
+}
+
+// WaitForExperiment waits until the experiment is done and returns it, an experiment that did not complete is returned with an error
+func (w Watcher) WaitForExperiment(ctx context.Context, experimentID string, timeout time.Duration) (Experiment, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(experimentPollInterval)
+	defer ticker.Stop()
+	for {
+		experiment, err := w.GetExperiment(ctx, experimentID)
+		if err != nil {
+			return Experiment{}, err
+		}
+		switch experiment.State.Status {
+		case StatusCompleted:
+			return experiment, nil
+		case StatusStopped, StatusFailed:
+			return experiment, fmt.Errorf("experiment %s %s: %s", experimentID, experiment.State.Status, experiment.State.Reason)
+		}
+		select {
+		case <-ctx.Done():
+			return experiment, fmt.Errorf("experiment %s is still %s: %w", experimentID, experiment.State.Status, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeleteExperimentTemplate deletes the experiment template, a template that is already deleted is not an error
+func (w Watcher) DeleteExperimentTemplate(ctx context.Context, templateID string) error {
+	if _, err := w.fisAPI.DeleteExperimentTemplate(ctx, &awsfis.DeleteExperimentTemplateInput{Id: aws.String(templateID)}); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete experiment template %s: %w", templateID, err)
+	}
+	return nil
+}
+
+// startExperiment creates the experiment template and starts an experiment from it
+func (w Watcher) startExperiment(ctx context.Context, input *awsfis.CreateExperimentTemplateInput) (Experiment, error) {
+	input.ClientToken = aws.String(clientToken())
+	templateOut, err := w.fisAPI.CreateExperimentTemplate(ctx, input)
+	if err != nil {
+		return Experiment{}, fmt.Errorf("failed to create experiment template: %w", err)
+	}
+	templateID := aws.ToString(templateOut.ExperimentTemplate.Id)
+	experimentOut, err := w.fisAPI.StartExperiment(ctx, &awsfis.StartExperimentInput{
+		ClientToken:          aws.String(clientToken()),
+		ExperimentTemplateId: aws.String(templateID),
+		Tags:                 input.Tags,
+	})
+	if err != nil {
+		// the template is only useful for this experiment
+		if deleteErr := w.DeleteExperimentTemplate(ctx, templateID); deleteErr != nil {
+			return Experiment{}, fmt.Errorf("failed to start experiment: %w, %w", err, deleteErr)
+		}
+		return Experiment{}, fmt.Errorf("failed to start experiment: %w", err)
+	}
+	started := lo.FromPtr(experimentOut.Experiment)
+	experiment := newExperiment(started.Id, started.ExperimentTemplateId, started.State, started.Tags)
+	experiment.ExperimentTemplateID = lo.CoalesceOrEmpty(experiment.ExperimentTemplateID, templateID)
+	return experiment, nil
+}
+
+// instanceARNs returns the ARNs of the instances in the region of the caller's account
+func (w Watcher) instanceARNs(ctx context.Context, region string, instanceIDs []string) ([]string, error) {
+	partition, account, err := w.callerAccount(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lo.Map(instanceIDs, func(instanceID string, _ int) string {
+		return fmt.Sprintf("arn:%s:ec2:%s:%s:instance/%s", partition, region, account, instanceID)
+	}), nil
+}
+
+// callerAccount returns the partition and account of the caller, which are the partition and account of the resources that experiments target
+func (w Watcher) callerAccount(ctx context.Context) (string, string, error) {
+	identity, err := w.stsAPI.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to get the account of the instances: %w", err)
+	}
+	// the partition is the second field of the caller's ARN, e.g. arn:aws-cn:sts::123456789012:assumed-role/...
+	partition := "aws"
+	if fields := strings.Split(aws.ToString(identity.Arn), ":"); len(fields) > 1 && fields[1] != "" {
+		partition = fields[1]
+	}
+	return partition, aws.ToString(identity.Account), nil
+}
+
+// isoDuration formats the duration as an ISO 8601 duration in whole minutes, or whole seconds if it is not a whole number of minutes,
+// which is how FIS action parameters take durations
+func isoDuration(d time.Duration) string {
+	if d%time.Minute != 0 {
+		return fmt.Sprintf("PT%dS", int(d.Round(time.Second).Seconds()))
+	}
+	return fmt.Sprintf("PT%dM", int(d.Minutes()))
+}
+
+// clientToken returns a unique token that makes a create call idempotent
+func clientToken() string {
+	token := make([]byte, 16)
+	_, _ = rand.Read(token)
+	return hex.EncodeToString(token)
+}
+
+// IsNotFound returns true if the error is a missing experiment or experiment template error
+func IsNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ResourceNotFoundException"
+}
+
+// IsAccessDenied returns true if the error is a missing permission to call FIS
+func IsAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException"
+}
+
+// newExperimentTemplate converts the fields of a FIS SDK experiment template or experiment template summary
+func newExperimentTemplate(id, description *string, tags map[string]string) ExperimentTemplate {
+	return ExperimentTemplate{
+		ID:          aws.ToString(id),
+		Description: aws.ToString(description),
+		Tags:        tags,
+	}
+}
+
+// newExperiment converts the fields of a FIS SDK experiment or experiment summary
+func newExperiment(id, templateID *string, state *fistypes.ExperimentState, tags map[string]string) Experiment {
+	experiment := Experiment{
+		ID:                   aws.ToString(id),
+		ExperimentTemplateID: aws.ToString(templateID),
+		Tags:                 tags,
+	}
+	if state != nil {
+		experiment.State = ExperimentState{Status: string(state.Status), Reason: aws.ToString(state.Reason)}
+	}
+	return experiment
+}
@@ -0,0 +1,293 @@
+package fis_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsfis "github.com/aws/aws-sdk-go-v2/service/fis"
+	fistypes "github.com/aws/aws-sdk-go-v2/service/fis/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/smithy-go"
+	"github.com/bwagner5/nimbus/pkg/providers/fis"
+	"github.com/samber/lo"
+)
+
+type fakeFIS struct {
+	fis.SDKFISOps
+	templates   map[string]awsfis.CreateExperimentTemplateInput
+	startErr    error
+	experiments []fis.Experiment
+	// templatePages are returned by ListExperimentTemplates a page at a time
+	templatePages [][]fis.ExperimentTemplate
+	stopped       []string
+}
+
+func (f *fakeFIS) CreateExperimentTemplate(_ context.Context, input *awsfis.CreateExperimentTemplateInput, _ ...func(*awsfis.Options)) (*awsfis.CreateExperimentTemplateOutput, error) {
+	f.templates["EXT1"] = *input
+	return &awsfis.CreateExperimentTemplateOutput{ExperimentTemplate: &fistypes.ExperimentTemplate{Id: aws.String("EXT1"), Tags: input.Tags}}, nil
+}
+
+func (f *fakeFIS) DeleteExperimentTemplate(_ context.Context, input *awsfis.DeleteExperimentTemplateInput, _ ...func(*awsfis.Options)) (*awsfis.DeleteExperimentTemplateOutput, error) {
+	if _, ok := f.templates[*input.Id]; !ok {
+		return nil, &smithy.GenericAPIError{Code: "ResourceNotFoundException"}
+	}
+	delete(f.templates, *input.Id)
+	return &awsfis.DeleteExperimentTemplateOutput{}, nil
+}
+
+func (f *fakeFIS) StartExperiment(_ context.Context, input *awsfis.StartExperimentInput, _ ...func(*awsfis.Options)) (*awsfis.StartExperimentOutput, error) {
+	if f.startErr != nil {
+		return nil, f.startErr
+	}
+	return &awsfis.StartExperimentOutput{Experiment: &fistypes.Experiment{Id: aws.String("EXP1"), ExperimentTemplateId: input.ExperimentTemplateId, State: &fistypes.ExperimentState{Status: fistypes.ExperimentStatusInitiating}}}, nil
+}
+
+func (f *fakeFIS) GetExperiment(_ context.Context, _ *awsfis.GetExperimentInput, _ ...func(*awsfis.Options)) (*awsfis.GetExperimentOutput, error) {
+	experiment := f.experiments[0]
+	if len(f.experiments) > 1 {
+		f.experiments = f.experiments[1:]
+	}
+	return &awsfis.GetExperimentOutput{Experiment: &fistypes.Experiment{
+		Id:    aws.String(experiment.ID),
+		State: &fistypes.ExperimentState{Status: fistypes.ExperimentStatus(experiment.State.Status), Reason: aws.String(experiment.State.Reason)},
+	}}, nil
+}
+
+func (f *fakeFIS) StopExperiment(_ context.Context, input *awsfis.StopExperimentInput, _ ...func(*awsfis.Options)) (*awsfis.StopExperimentOutput, error) {
+	f.stopped = append(f.stopped, *input.Id)
+	return &awsfis.StopExperimentOutput{Experiment: &fistypes.Experiment{Id: input.Id, State: &fistypes.ExperimentState{Status: fistypes.ExperimentStatusStopping}}}, nil
+}
+
+func (f *fakeFIS) ListExperimentTemplates(_ context.Context, input *awsfis.ListExperimentTemplatesInput, _ ...func(*awsfis.Options)) (*awsfis.ListExperimentTemplatesOutput, error) {
+	page := 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(*input.NextToken)
+	}
+	out := &awsfis.ListExperimentTemplatesOutput{ExperimentTemplates: lo.Map(f.templatePages[page], func(template fis.ExperimentTemplate, _ int) fistypes.ExperimentTemplateSummary {
+		return fistypes.ExperimentTemplateSummary{Id: aws.String(template.ID), Tags: template.Tags}
+	})}
+	if page+1 < len(f.templatePages) {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+type fakeSTS struct{}
+
+func (fakeSTS) GetCallerIdentity(context.Context, *sts.GetCallerIdentityInput, ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String("123456789012"), Arn: aws.String("arn:aws-cn:sts::123456789012:assumed-role/admin/me")}, nil
+}
+
+func TestInterruptSpotInstances(t *testing.T) {
+	t.Run("targets the instances", func(t *testing.T) {
+		fisAPI := &fakeFIS{templates: map[string]awsfis.CreateExperimentTemplateInput{}}
+		experiment, err := fis.NewWatcher(fisAPI, fakeSTS{}).InterruptSpotInstances(context.Background(), fis.InterruptOptions{
+			Region:      "cn-north-1",
+			InstanceIDs: []string{"i-123"},
+			RoleARN:     "arn:aws-cn:iam::123456789012:role/fis",
+			Notice:      5 * time.Minute,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if experiment.ID != "EXP1" || experiment.ExperimentTemplateID != "EXT1" {
+			t.Errorf("expected experiment EXP1 of template EXT1, got %+v", experiment)
+		}
+		template := fisAPI.templates["EXT1"]
+		if arns := template.Targets["SpotInstances"].ResourceArns; len(arns) != 1 || arns[0] != "arn:aws-cn:ec2:cn-north-1:123456789012:instance/i-123" {
+			t.Errorf("unexpected target ARNs %v", arns)
+		}
+		action := template.Actions["interrupt"]
+		if aws.ToString(action.ActionId) != fis.ActionSendSpotInstanceInterruptions || action.Parameters["durationBeforeInterruption"] != "PT5M" {
+			t.Errorf("unexpected action %+v", action)
+		}
+		if aws.ToString(template.ClientToken) == "" || aws.ToString(template.RoleArn) != "arn:aws-cn:iam::123456789012:role/fis" {
+			t.Errorf("expected a client token and the role, got %+v", template)
+		}
+	})
+	t.Run("deletes the template if the experiment does not start", func(t *testing.T) {
+		fisAPI := &fakeFIS{templates: map[string]awsfis.CreateExperimentTemplateInput{}, startErr: errors.New("access denied")}
+		if _, err := fis.NewWatcher(fisAPI, fakeSTS{}).InterruptSpotInstances(context.Background(), fis.InterruptOptions{InstanceIDs: []string{"i-123"}}); err == nil {
+			t.Errorf("expected an error, got none")
+		}
+		if len(fisAPI.templates) != 0 {
+			t.Errorf("expected the template to be deleted, got %v", fisAPI.templates)
+		}
+	})
+	t.Run("notice is too short", func(t *testing.T) {
+		fisAPI := &fakeFIS{templates: map[string]awsfis.CreateExperimentTemplateInput{}}
+		if _, err := fis.NewWatcher(fisAPI, fakeSTS{}).InterruptSpotInstances(context.Background(), fis.InterruptOptions{InstanceIDs: []string{"i-123"}, Notice: time.Minute}); err == nil {
+			t.Errorf("expected an error, got none")
+		}
+	})
+}
+
+func TestWaitForExperiment(t *testing.T) {
+	testCases := []struct {
+		name        string
+		statuses    []string
+		expectError bool
+	}{
+		{name: "completed", statuses: []string{fis.StatusCompleted}},
+		{name: "failed", statuses: []string{fis.StatusFailed}, expectError: true},
+		{name: "stopped", statuses: []string{fis.StatusStopped}, expectError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fisAPI := &fakeFIS{}
+			for _, status := range tc.statuses {
+				fisAPI.experiments = append(fisAPI.experiments, fis.Experiment{ID: "EXP1", State: fis.ExperimentState{Status: status}})
+			}
+			experiment, err := fis.NewWatcher(fisAPI, fakeSTS{}).WaitForExperiment(context.Background(), "EXP1", time.Minute)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if experiment.State.Status != fis.StatusCompleted {
+				t.Errorf("expected the experiment to be completed, got %s", experiment.State.Status)
+			}
+		})
+	}
+}
+
+func TestCreateExperimentTemplate(t *testing.T) {
+	targetTags := map[string]string{"nimbus-Namespace": "default", "nimbus-Name": "web"}
+	testCases := []struct {
+		name                string
+		opts                fis.ExperimentTemplateOptions
+		expectError         bool
+		expectActionID      string
+		expectParameters    map[string]string
+		expectDocParams     map[string]string
+		expectSelectionMode string
+	}{
+		{
+			name:                "cpu stress of every instance",
+			opts:                fis.ExperimentTemplateOptions{Fault: fis.FaultCPUStress, Duration: 90 * time.Second, LoadPercent: 80},
+			expectActionID:      fis.ActionSendCommand,
+			expectParameters:    map[string]string{"documentArn": "arn:aws-cn:ssm:cn-north-1::document/AWSFIS-Run-CPU-Stress", "duration": "PT90S"},
+			expectDocParams:     map[string]string{"DurationSeconds": "90", "LoadPercent": "80", "InstallDependencies": "True"},
+			expectSelectionMode: "ALL",
+		},
+		{
+			name:                "network latency of some instances",
+			opts:                fis.ExperimentTemplateOptions{Fault: fis.FaultNetworkLatency, Duration: 5 * time.Minute, Count: 2},
+			expectActionID:      fis.ActionSendCommand,
+			expectParameters:    map[string]string{"documentArn": "arn:aws-cn:ssm:cn-north-1::document/AWSFIS-Run-Network-Latency", "duration": "PT5M"},
+			expectDocParams:     map[string]string{"DurationSeconds": "300", "DelayMilliseconds": "200", "InstallDependencies": "True"},
+			expectSelectionMode: "COUNT(2)",
+		},
+		{
+			name:                "instance stop",
+			opts:                fis.ExperimentTemplateOptions{Fault: fis.FaultInstanceStop, Duration: 10 * time.Minute},
+			expectActionID:      fis.ActionStopInstances,
+			expectParameters:    map[string]string{"startInstancesAfterDuration": "PT10M"},
+			expectSelectionMode: "ALL",
+		},
+		{
+			name:        "invalid fault",
+			opts:        fis.ExperimentTemplateOptions{Fault: "meteor", Duration: time.Minute},
+			expectError: true,
+		},
+		{
+			name:        "invalid load",
+			opts:        fis.ExperimentTemplateOptions{Fault: fis.FaultCPUStress, Duration: time.Minute, LoadPercent: 150},
+			expectError: true,
+		},
+		{
+			name:        "no duration",
+			opts:        fis.ExperimentTemplateOptions{Fault: fis.FaultInstanceStop},
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fisAPI := &fakeFIS{templates: map[string]awsfis.CreateExperimentTemplateInput{}}
+			tc.opts.Region = "cn-north-1"
+			tc.opts.TargetTags = targetTags
+			template, err := fis.NewWatcher(fisAPI, fakeSTS{}).CreateExperimentTemplate(context.Background(), tc.opts)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			input := fisAPI.templates[template.ID]
+			if input.Tags["nimbus-Fault"] != tc.opts.Fault {
+				t.Errorf("expected the template to be tagged with its fault, got %v", input.Tags)
+			}
+			target := input.Targets["Instances"]
+			if aws.ToString(target.SelectionMode) != tc.expectSelectionMode || target.ResourceTags["nimbus-Name"] != "web" {
+				t.Errorf("unexpected target %+v", target)
+			}
+			action := input.Actions[tc.opts.Fault]
+			if aws.ToString(action.ActionId) != tc.expectActionID {
+				t.Errorf("expected action %s, got %s", tc.expectActionID, aws.ToString(action.ActionId))
+			}
+			for key, value := range tc.expectParameters {
+				if action.Parameters[key] != value {
+					t.Errorf("expected parameter %s to be %s, got %s", key, value, action.Parameters[key])
+				}
+			}
+			if tc.expectDocParams == nil {
+				return
+			}
+			var docParams map[string]string
+			if err := json.Unmarshal([]byte(action.Parameters["documentParameters"]), &docParams); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for key, value := range tc.expectDocParams {
+				if docParams[key] != value {
+					t.Errorf("expected document parameter %s to be %s, got %s", key, value, docParams[key])
+				}
+			}
+		})
+	}
+}
+
+func TestResolveExperimentTemplates(t *testing.T) {
+	fisAPI := &fakeFIS{templatePages: [][]fis.ExperimentTemplate{
+		{
+			{ID: "EXT1", Tags: map[string]string{"nimbus-Namespace": "default", "nimbus-Name": "web"}},
+			{ID: "EXT2", Tags: map[string]string{"nimbus-Namespace": "other", "nimbus-Name": "web"}},
+		},
+		{
+			{ID: "EXT3", Tags: map[string]string{"nimbus-Namespace": "default", "nimbus-Name": "db"}},
+			{ID: "EXT4"},
+		},
+	}}
+	templates, err := fis.NewWatcher(fisAPI, fakeSTS{}).ResolveExperimentTemplates(context.Background(), map[string]string{"nimbus-Namespace": "default"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(templates) != 2 || templates[0].ID != "EXT1" || templates[1].ID != "EXT3" {
+		t.Errorf("expected templates EXT1 and EXT3, got %+v", templates)
+	}
+}
+
+func TestStopExperiment(t *testing.T) {
+	for _, status := range []string{"running", fis.StatusCompleted} {
+		t.Run(status, func(t *testing.T) {
+			fisAPI := &fakeFIS{experiments: []fis.Experiment{{ID: "EXP1", State: fis.ExperimentState{Status: status}}}}
+			if _, err := fis.NewWatcher(fisAPI, fakeSTS{}).StopExperiment(context.Background(), "EXP1"); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if expectStopped := status == "running"; expectStopped != (len(fisAPI.stopped) == 1) {
+				t.Errorf("expected stopped to be %t, got %v", expectStopped, fisAPI.stopped)
+			}
+		})
+	}
+}
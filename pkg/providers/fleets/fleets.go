@@ -1,8 +1,15 @@
 package fleets
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
@@ -10,13 +17,36 @@ import (
 	"github.com/bwagner5/nimbus/pkg/providers/amis"
 	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
 	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/reservations"
 	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/awsutils"
 	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
 
+const (
+	// CapacityUnitInstances counts target capacity in instances
+	CapacityUnitInstances = "instances"
+	// CapacityUnitVCPU counts target capacity in vCPUs, each instance type is weighted by its vCPUs
+	CapacityUnitVCPU = "vcpu"
+	// CapacityUnitMemoryMiB counts target capacity in MiB of memory, each instance type is weighted by its memory
+	CapacityUnitMemoryMiB = "memory-mib"
+
+	// FleetIDTagKey is the tag that EC2 Fleet adds to the instances that it launches
+	FleetIDTagKey = "aws:ec2:fleet-id"
+
+	// MaxOverrides is the most Fleet Launch Template Overrides that EC2 Fleet accepts across all of a fleet's launch template configs
+	MaxOverrides = 300
+
+	// DefaultFulfillmentTimeout is how long WaitForInstances waits for a maintain fleet to fulfill its target capacity
+	DefaultFulfillmentTimeout = 5 * time.Minute
+	// fulfillmentPollInterval is how often a maintain fleet is described while waiting for it to fulfill its target capacity
+	fulfillmentPollInterval = 5 * time.Second
+)
+
 // Watcher discovers fleets based on selectors
 type Watcher struct {
 	fleetAPI SDKFleetsOps
@@ -28,23 +58,92 @@ type SDKFleetsOps interface {
 	CreateFleet(context.Context, *ec2.CreateFleetInput, ...func(*ec2.Options)) (*ec2.CreateFleetOutput, error)
 	DescribeFleets(context.Context, *ec2.DescribeFleetsInput, ...func(*ec2.Options)) (*ec2.DescribeFleetsOutput, error)
 	DeleteFleets(context.Context, *ec2.DeleteFleetsInput, ...func(*ec2.Options)) (*ec2.DeleteFleetsOutput, error)
+	DescribeFleetInstances(context.Context, *ec2.DescribeFleetInstancesInput, ...func(*ec2.Options)) (*ec2.DescribeFleetInstancesOutput, error)
 }
 
 // Selector is a struct that represents an fleet selector
 type Selector struct {
 	Tags map[string]string
 	ID   string
+	// Type is one of: instant | maintain | request
+	Type string
+	// State is one of: submitted | active | deleted | failed | deleted-running | deleted-terminating | modifying
+	State string
 }
 
 type CreateFleetOptions struct {
 	Name           string
 	Namespace      string
 	LaunchTemplate launchtemplates.LaunchTemplate
-	Subnets        []subnets.Subnet
-	AMIs           []amis.AMI
-	InstanceTypes  []instancetypes.InstanceType
-	IAMRole        string
-	CapacityType   string
+	// LaunchTemplateVersion is the version of the LaunchTemplate to launch, defaults to $Latest
+	LaunchTemplateVersion int64
+	Subnets               []subnets.Subnet
+	AMIs                  []amis.AMI
+	InstanceTypes         []instancetypes.InstanceType
+	IAMRole               string
+	CapacityType          string
+	// TargetCapacity is the amount of CapacityUnit to launch, defaults to 1
+	TargetCapacity int32
+	// CapacityUnit is instances, vcpu, or memory-mib, defaults to instances.
+	// Overrides are weighted by the vCPUs or memory of their instance type so that Fleet can mix sizes to reach the target.
+	CapacityUnit string
+	// Tags are additional tags applied to the fleet and launched instances
+	Tags map[string]string
+	// Reservations is the unused reserved capacity. If it is not empty, on-demand capacity is launched with the prioritized allocation strategy
+	// and overrides that the reservations cover are prioritized over the others, which are launched only if the covered ones have no capacity.
+	Reservations reservations.Coverage
+	// SpotAllocationStrategy is how EC2 Fleet picks the spot capacity pools to launch from, defaults to price-capacity-optimized
+	SpotAllocationStrategy string
+	// SpotMaxPrice is the most that a spot instance is launched for per hour, zero is capped at the on-demand price.
+	// The max price of weighted overrides is divided by their weight since EC2 Fleet caps the price per unit of capacity.
+	SpotMaxPrice float64
+	// OnDemandBaseCapacity is the target capacity that is launched on-demand before the rest is split by OnDemandPercentageAboveBase
+	OnDemandBaseCapacity int32
+	// OnDemandPercentageAboveBase is the percentage of the target capacity above OnDemandBaseCapacity that is launched on-demand,
+	// the rest is launched as spot. If it is nil, the capacity above the base is launched as the CapacityType.
+	OnDemandPercentageAboveBase *int32
+	// CapacityRebalance is launch or launch-before-terminate and creates a maintain fleet that launches a replacement spot instance
+	// when EC2 signals that one of its spot instances is at an elevated risk of interruption. Empty creates an instant fleet.
+	CapacityRebalance string
+	// TerminationDelay is how long a launch-before-terminate fleet keeps a spot instance running after launching its replacement
+	TerminationDelay time.Duration
+	// DryRun only checks whether the caller is permitted to create the fleet, EC2 returns a DryRunOperation error if it is
+	DryRun bool
+}
+
+// LaunchFailure is an error that EC2 Fleet returned for one of its overrides, e.g. InsufficientInstanceCapacity in an availability zone.
+// An instant fleet can launch some of its target capacity and still return failures for the overrides that it could not launch.
+type LaunchFailure struct {
+	ErrorCode        string `table:"Error Code,status"`
+	Message          string `table:"Message"`
+	InstanceType     string `table:"Instance Type"`
+	SubnetID         string `table:"Subnet"`
+	AvailabilityZone string `table:"Zone"`
+	// Lifecycle is spot or on-demand
+	Lifecycle string `table:"Lifecycle"`
+}
+
+// NoInstancesLaunchedError is returned when a fleet launched no instances, Failures are the reasons that EC2 Fleet returned
+type NoInstancesLaunchedError struct {
+	FleetID  string
+	Failures []LaunchFailure
+}
+
+// Error returns the distinct error codes and messages of the failures
+func (e NoInstancesLaunchedError) Error() string {
+	reasons := lo.Uniq(lo.Map(e.Failures, func(failure LaunchFailure, _ int) string {
+		return fmt.Sprintf("%s: %s", failure.ErrorCode, failure.Message)
+	}))
+	if len(reasons) == 0 {
+		return fmt.Sprintf("fleet %s launched no instances", e.FleetID)
+	}
+	return fmt.Sprintf("fleet %s launched no instances: %s", e.FleetID, strings.Join(reasons, "; "))
+}
+
+// IsNoInstancesLaunched returns true if the error is a NoInstancesLaunchedError
+func IsNoInstancesLaunched(err error) bool {
+	var noInstancesErr NoInstancesLaunchedError
+	return errors.As(err, &noInstancesErr)
 }
 
 // Fleet represents an Amazon EC2 Fleet
@@ -68,6 +167,10 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 			switch k {
 			case "id":
 				fleetSelector.ID = v
+			case "type":
+				fleetSelector.Type = v
+			case "state":
+				fleetSelector.State = v
 			default:
 				return nil, fmt.Errorf("invalid fleet selector key: %s", k)
 			}
@@ -104,51 +207,257 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Fleet, er
 	return fleets, nil
 }
 
-func (w Watcher) CreateFleet(ctx context.Context, createOpts CreateFleetOptions) (string, error) {
+// ResolveLaunchTemplateReferences returns the maintain and request fleets that are still active and launch capacity from the launch templates,
+// keyed by launch template ID. The fleets are described once, regardless of the number of launch templates.
+// Instant fleets are not returned since they do not hold a reference to the launch template after the fleet request completes.
+func (w Watcher) ResolveLaunchTemplateReferences(ctx context.Context, launchTemplateIDs []string) (map[string][]Fleet, error) {
+	references := map[string][]Fleet{}
+	if len(launchTemplateIDs) == 0 {
+		return references, nil
+	}
+	fleets, err := w.Resolve(ctx, []Selector{
+		{Type: string(ec2types.FleetTypeMaintain)},
+		{Type: string(ec2types.FleetTypeRequest)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, fleet := range lo.Filter(fleets, func(fleet Fleet, _ int) bool { return fleet.IsActive() }) {
+		for _, launchTemplateID := range launchTemplateIDs {
+			if fleet.ReferencesLaunchTemplate(launchTemplateID) {
+				references[launchTemplateID] = append(references[launchTemplateID], fleet)
+			}
+		}
+	}
+	return references, nil
+}
+
+// CreateFleet creates an instant fleet and returns its ID along with the failures of the overrides that it could not launch.
+// A NoInstancesLaunchedError is returned if the fleet launched no instances at all.
+// With CapacityRebalance, a maintain fleet is created instead, which launches its instances after it is created, see WaitForInstances.
+func (w Watcher) CreateFleet(ctx context.Context, createOpts CreateFleetOptions) (string, []LaunchFailure, error) {
+	targetCapacity := createOpts.TargetCapacity
+	if targetCapacity == 0 {
+		targetCapacity = 1
+	}
+	launchTemplateConfigs, err := w.launchTemplateConfigs(createOpts.LaunchTemplate, createOpts)
+	if err != nil {
+		return "", nil, err
+	}
+	tags := tagutils.MapToEC2Tags(lo.Assign(createOpts.Tags, tagutils.NamespacedTags(createOpts.Namespace, createOpts.Name)))
+	targetCapacitySpecification := &ec2types.TargetCapacitySpecificationRequest{
+		TotalTargetCapacity:       aws.Int32(targetCapacity),
+		DefaultTargetCapacityType: ec2types.DefaultTargetCapacityType(ec2utils.NormalizeCapacityType(createOpts.CapacityType)),
+	}
+	if createOpts.splitsCapacity() {
+		onDemand, spot := createOpts.targetCapacities(targetCapacity)
+		targetCapacitySpecification.OnDemandTargetCapacity = aws.Int32(onDemand)
+		targetCapacitySpecification.SpotTargetCapacity = aws.Int32(spot)
+	}
+	spotOptions := &ec2types.SpotOptionsRequest{
+		AllocationStrategy: ec2types.SpotAllocationStrategy(lo.CoalesceOrEmpty(createOpts.SpotAllocationStrategy, string(ec2types.SpotAllocationStrategyPriceCapacityOptimized))),
+	}
+	if createOpts.CapacityRebalance != "" {
+		spotOptions.MaintenanceStrategies = &ec2types.FleetSpotMaintenanceStrategiesRequest{
+			CapacityRebalance: &ec2types.FleetSpotCapacityRebalanceRequest{
+				ReplacementStrategy: ec2types.FleetReplacementStrategy(createOpts.CapacityRebalance),
+				TerminationDelay:    lo.Ternary(createOpts.TerminationDelay > 0, aws.Int32(int32(createOpts.TerminationDelay.Seconds())), nil),
+			},
+		}
+	}
 	fleetOutput, err := w.fleetAPI.CreateFleet(ctx, &ec2.CreateFleetInput{
-		Type:                  ec2types.FleetTypeInstant,
-		LaunchTemplateConfigs: w.launchTemplateConfigs(createOpts.LaunchTemplate, createOpts),
-		TargetCapacitySpecification: &ec2types.TargetCapacitySpecificationRequest{
-			TotalTargetCapacity:       aws.Int32(1),
-			DefaultTargetCapacityType: ec2types.DefaultTargetCapacityType(ec2utils.NormalizeCapacityType(createOpts.CapacityType)),
-		},
+		Type:                        lo.Ternary(createOpts.CapacityRebalance != "", ec2types.FleetTypeMaintain, ec2types.FleetTypeInstant),
+		DryRun:                      lo.Ternary(createOpts.DryRun, aws.Bool(true), nil),
+		LaunchTemplateConfigs:       launchTemplateConfigs,
+		TargetCapacitySpecification: targetCapacitySpecification,
 		OnDemandOptions: &ec2types.OnDemandOptionsRequest{
-			AllocationStrategy: ec2types.FleetOnDemandAllocationStrategyLowestPrice,
-		},
-		SpotOptions: &ec2types.SpotOptionsRequest{
-			AllocationStrategy: ec2types.SpotAllocationStrategyPriceCapacityOptimized,
+			AllocationStrategy: lo.Ternary(createOpts.prioritizeReservations(), ec2types.FleetOnDemandAllocationStrategyPrioritized, ec2types.FleetOnDemandAllocationStrategyLowestPrice),
 		},
+		SpotOptions: spotOptions,
 		TagSpecifications: []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeFleet,
-				Tags:         tagutils.EC2NamespacedTags(createOpts.Namespace, createOpts.Name),
+				Tags:         tags,
 			},
 			{
 				ResourceType: ec2types.ResourceTypeInstance,
-				Tags:         tagutils.EC2NamespacedTags(createOpts.Namespace, createOpts.Name),
+				Tags:         tags,
 			},
 		},
 	})
 	if err != nil {
-		return "", err
+		return "", nil, err
+	}
+	fleetID := lo.FromPtr(fleetOutput.FleetId)
+	if createOpts.CapacityRebalance != "" {
+		return fleetID, nil, nil
 	}
-	return *fleetOutput.FleetId, nil
+	failures := launchFailures(fleetOutput.Errors)
+	launched := lo.SumBy(fleetOutput.Instances, func(instance ec2types.CreateFleetInstance) int { return len(instance.InstanceIds) })
+	if launched == 0 {
+		return fleetID, failures, NoInstancesLaunchedError{FleetID: fleetID, Failures: failures}
+	}
+	return fleetID, failures, nil
 }
 
-func (w Watcher) DeleteFleet(ctx context.Context, fleetID string) error {
-	out, err := w.fleetAPI.DeleteFleets(ctx, &ec2.DeleteFleetsInput{
-		FleetIds: []string{fleetID},
+// WaitForInstances polls a maintain fleet until it fulfilled its target capacity, failed to, or the timeout elapses, and returns the IDs of its instances.
+// Unlike an instant fleet, a maintain fleet launches its instances after it is created and DescribeFleets does not return them.
+// A NoInstancesLaunchedError is returned if the fleet has no instances by then.
+func (w Watcher) WaitForInstances(ctx context.Context, fleetID string, timeout time.Duration) ([]string, error) {
+	if err := w.waitForFulfillment(ctx, fleetID, timeout); err != nil {
+		return nil, err
+	}
+	var instanceIDs []string
+	input := &ec2.DescribeFleetInstancesInput{FleetId: aws.String(fleetID)}
+	for {
+		out, err := w.fleetAPI.DescribeFleetInstances(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe instances of fleet %s: %w", fleetID, err)
+		}
+		instanceIDs = append(instanceIDs, lo.FilterMap(out.ActiveInstances, func(instance ec2types.ActiveInstance, _ int) (string, bool) {
+			return lo.FromPtr(instance.InstanceId), instance.InstanceId != nil
+		})...)
+		if out.NextToken == nil {
+			break
+		}
+		input.NextToken = out.NextToken
+	}
+	if len(instanceIDs) == 0 {
+		return nil, NoInstancesLaunchedError{FleetID: fleetID}
+	}
+	return instanceIDs, nil
+}
+
+// waitForFulfillment polls the fleet until its activity status is fulfilled or error, or the timeout elapses.
+// A fleet that only launched part of its target capacity is not an error, like an instant fleet that could not launch all of it.
+func (w Watcher) waitForFulfillment(ctx context.Context, fleetID string, timeout time.Duration) error {
+	pollCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(fulfillmentPollInterval)
+	defer ticker.Stop()
+	for {
+		fleetList, err := w.Resolve(pollCtx, []Selector{{ID: fleetID}})
+		if err != nil {
+			if pollCtx.Err() != nil && ctx.Err() == nil {
+				return nil
+			}
+			return err
+		}
+		if len(fleetList) != 0 && lo.Contains([]ec2types.FleetActivityStatus{ec2types.FleetActivityStatusFulfilled, ec2types.FleetActivityStatusError}, fleetList[0].ActivityStatus) {
+			return nil
+		}
+		select {
+		case <-pollCtx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// launchFailures converts the errors of a CreateFleet response to LaunchFailures
+func launchFailures(fleetErrors []ec2types.CreateFleetError) []LaunchFailure {
+	if len(fleetErrors) == 0 {
+		return nil
+	}
+	return lo.Map(fleetErrors, func(fleetError ec2types.CreateFleetError, _ int) LaunchFailure {
+		failure := LaunchFailure{
+			ErrorCode: lo.FromPtr(fleetError.ErrorCode),
+			Message:   lo.FromPtr(fleetError.ErrorMessage),
+			Lifecycle: string(fleetError.Lifecycle),
+		}
+		if fleetError.LaunchTemplateAndOverrides != nil && fleetError.LaunchTemplateAndOverrides.Overrides != nil {
+			overrides := fleetError.LaunchTemplateAndOverrides.Overrides
+			failure.InstanceType = string(overrides.InstanceType)
+			failure.SubnetID = lo.FromPtr(overrides.SubnetId)
+			failure.AvailabilityZone = lo.FromPtr(overrides.AvailabilityZone)
+		}
+		return failure
 	})
-	if err != nil {
+}
+
+// DeleteFleet deletes the fleet and terminates its instances, EC2 does not support deleting instant fleets without terminating their instances
+func (w Watcher) DeleteFleet(ctx context.Context, fleetID string) error {
+	var out *ec2.DeleteFleetsOutput
+	if err := retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		var err error
+		out, err = w.fleetAPI.DeleteFleets(ctx, &ec2.DeleteFleetsInput{
+			FleetIds:           []string{fleetID},
+			TerminateInstances: aws.Bool(true),
+		})
+		return err
+	}); err != nil {
 		return err
 	}
 	if len(out.UnsuccessfulFleetDeletions) > 0 {
-		return fmt.Errorf("code: %s, %s", out.UnsuccessfulFleetDeletions[0].Error.Code, *out.UnsuccessfulFleetDeletions[0].Error.Message)
+		return fmt.Errorf("code: %s, %s, RequestID: %s", out.UnsuccessfulFleetDeletions[0].Error.Code, *out.UnsuccessfulFleetDeletions[0].Error.Message, awsutils.RequestID(out.ResultMetadata))
 	}
 	return nil
 }
 
-func (w Watcher) launchTemplateConfigs(launchTemplate launchtemplates.LaunchTemplate, createOpts CreateFleetOptions) []ec2types.FleetLaunchTemplateConfigRequest {
+// launchTemplateConfigs groups the overrides of the options by AMI, each AMI's overrides share a launch template config
+func (w Watcher) launchTemplateConfigs(launchTemplate launchtemplates.LaunchTemplate, createOpts CreateFleetOptions) ([]ec2types.FleetLaunchTemplateConfigRequest, error) {
+	launchTemplateVersion := "$Latest"
+	if createOpts.LaunchTemplateVersion != 0 {
+		launchTemplateVersion = strconv.FormatInt(createOpts.LaunchTemplateVersion, 10)
+	}
+	overrides, _, err := Overrides(createOpts)
+	if err != nil {
+		return nil, err
+	}
+	var configs []ec2types.FleetLaunchTemplateConfigRequest
+	for _, imageID := range lo.Uniq(lo.Map(overrides, func(override Override, _ int) string { return override.ImageID })) {
+		configs = append(configs, ec2types.FleetLaunchTemplateConfigRequest{
+			LaunchTemplateSpecification: &ec2types.FleetLaunchTemplateSpecificationRequest{
+				LaunchTemplateId: aws.String(*launchTemplate.LaunchTemplateId),
+				Version:          aws.String(launchTemplateVersion),
+			},
+			Overrides: lo.FilterMap(overrides, func(override Override, _ int) (ec2types.FleetLaunchTemplateOverridesRequest, bool) {
+				return ec2types.FleetLaunchTemplateOverridesRequest{
+					ImageId:          aws.String(override.ImageID),
+					SubnetId:         aws.String(override.SubnetID),
+					InstanceType:     ec2types.InstanceType(override.InstanceType),
+					Priority:         override.Priority,
+					WeightedCapacity: override.WeightedCapacity,
+					MaxPrice:         lo.Ternary(override.MaxPrice != nil, aws.String(strconv.FormatFloat(lo.FromPtr(override.MaxPrice), 'f', -1, 64)), nil),
+				}, override.ImageID == imageID
+			}),
+		})
+	}
+	return configs, nil
+}
+
+// Override is a Fleet Launch Template Override, a combination of instance type, subnet, and AMI that a fleet may launch
+type Override struct {
+	InstanceType     string
+	SubnetID         string
+	ImageID          string
+	Priority         *float64
+	WeightedCapacity *float64
+	// MaxPrice is the most that EC2 Fleet launches a spot instance of the override for per unit of capacity per hour, nil is the on-demand price
+	MaxPrice *float64
+}
+
+// String returns the override as a single line, e.g. "m5.large subnet-1 ami-1 priority=0 weight=2".
+// A weight of 1 is left out since it is the weight of overrides that are not weighted.
+func (o Override) String() string {
+	s := fmt.Sprintf("%s %s %s", o.InstanceType, o.SubnetID, o.ImageID)
+	if o.Priority != nil {
+		s += fmt.Sprintf(" priority=%s", strconv.FormatFloat(*o.Priority, 'f', -1, 64))
+	}
+	if o.WeightedCapacity != nil && *o.WeightedCapacity != 1 {
+		s += fmt.Sprintf(" weight=%s", strconv.FormatFloat(*o.WeightedCapacity, 'f', -1, 64))
+	}
+	if o.MaxPrice != nil {
+		s += fmt.Sprintf(" max-price=%s", strconv.FormatFloat(*o.MaxPrice, 'f', -1, 64))
+	}
+	return s
+}
+
+// Overrides returns the Fleet Launch Template Overrides that a fleet created with the options launches from.
+// Every instance type is launchable in every subnet, so broad selectors multiply into more than the MaxOverrides that EC2 Fleet accepts.
+// Instance types are then dropped until the overrides fit, least preferred first, and are returned so that callers can tell what was left out.
+// An error is returned if the subnets alone exceed MaxOverrides, since no instance type could be launched in all of them.
+func Overrides(createOpts CreateFleetOptions) ([]Override, []string, error) {
 
 	// LaunchTemplateConfigs are Fleet's way of specifying launch parameters for things like subnets, AMI, security groups, user-data, etc.
 	// The parameters are spread between LaunchTemplates and Fleet Launch Template Overrides.
@@ -165,42 +474,143 @@ func (w Watcher) launchTemplateConfigs(launchTemplate launchtemplates.LaunchTemp
 	//   - ami-2 in subnet-1 w/ security-group-1 and security-group-2 AND user-data-1 on fleet-type-2 and fleet-type-3
 	//   - ami-2 in subnet-2 w/ security-group-1 and security-group-2 AND user-data-1 on fleet-type-2 and fleet-type-3
 
-	var amiArchs []amis.AMI
-	if arm64AMI, ok := lo.Find(createOpts.AMIs, func(ami amis.AMI) bool { return ami.Architecture == ec2types.ArchitectureValuesArm64 }); ok {
-		amiArchs = append(amiArchs, arm64AMI)
-	}
-	if x86AMI, ok := lo.Find(createOpts.AMIs, func(ami amis.AMI) bool { return ami.Architecture == ec2types.ArchitectureValuesX8664 }); ok {
-		amiArchs = append(amiArchs, x86AMI)
+	launchable := map[string]amis.AMI{}
+	var instanceTypes []instancetypes.InstanceType
+	for _, ami := range amis.PerArchitecture(createOpts.AMIs) {
+		for _, instanceType := range createOpts.InstanceTypes {
+			if _, ok := launchable[string(instanceType.InstanceType)]; ok {
+				continue
+			}
+			if slices.Contains(instanceType.ProcessorInfo.SupportedArchitectures, ec2types.ArchitectureType(ami.Architecture)) {
+				launchable[string(instanceType.InstanceType)] = ami
+				instanceTypes = append(instanceTypes, instanceType)
+			}
+		}
 	}
 
-	var launchTemplateConfigs []ec2types.FleetLaunchTemplateConfigRequest
-	for _, ami := range amiArchs {
-		supportedInstanceTypesForArch := lo.Filter(createOpts.InstanceTypes, func(instanceType instancetypes.InstanceType, _ int) bool {
-			_, ok := lo.Find(instanceType.ProcessorInfo.SupportedArchitectures, func(arch ec2types.ArchitectureType) bool {
-				return string(arch) == string(ami.Architecture)
-			})
-			return ok
+	var dropped []string
+	if len(instanceTypes)*len(createOpts.Subnets) > MaxOverrides {
+		if len(createOpts.Subnets) > MaxOverrides {
+			return nil, nil, fmt.Errorf("%d subnets exceed the %d overrides that an EC2 Fleet accepts, select fewer subnets", len(createOpts.Subnets), MaxOverrides)
+		}
+		// every kept instance type is launchable in every subnet, so the subnets' flexibility is kept at the expense of instance types
+		kept := MaxOverrides / len(createOpts.Subnets)
+		prioritized := createOpts.prioritizeInstanceTypes(instanceTypes)
+		dropped = lo.Map(prioritized[kept:], func(instanceType instancetypes.InstanceType, _ int) string { return string(instanceType.InstanceType) })
+		instanceTypes = lo.Filter(instanceTypes, func(instanceType instancetypes.InstanceType, _ int) bool {
+			return !slices.Contains(dropped, string(instanceType.InstanceType))
 		})
+	}
 
-		for _, instanceType := range supportedInstanceTypesForArch {
+	var overrides []Override
+	for _, ami := range amis.PerArchitecture(createOpts.AMIs) {
+		for _, instanceType := range instanceTypes {
+			if lo.FromPtr(launchable[string(instanceType.InstanceType)].ImageId) != lo.FromPtr(ami.ImageId) {
+				continue
+			}
 			for _, subnet := range createOpts.Subnets {
-				launchTemplateConfigs = append(launchTemplateConfigs, ec2types.FleetLaunchTemplateConfigRequest{
-					LaunchTemplateSpecification: &ec2types.FleetLaunchTemplateSpecificationRequest{
-						LaunchTemplateId: aws.String(*launchTemplate.LaunchTemplateId),
-						Version:          aws.String("$Latest"),
-					},
-					Overrides: []ec2types.FleetLaunchTemplateOverridesRequest{
-						{
-							ImageId:      ami.ImageId,
-							SubnetId:     subnet.SubnetId,
-							InstanceType: instanceType.InstanceType,
-						},
-					},
+				var priority *float64
+				if createOpts.prioritizeReservations() {
+					priority = aws.Float64(lo.Ternary(createOpts.Reservations.Covers(string(instanceType.InstanceType), lo.FromPtr(subnet.AvailabilityZone)), 0.0, 1.0))
+				}
+				overrides = append(overrides, Override{
+					InstanceType:     string(instanceType.InstanceType),
+					SubnetID:         lo.FromPtr(subnet.SubnetId),
+					ImageID:          lo.FromPtr(ami.ImageId),
+					Priority:         priority,
+					WeightedCapacity: Weight(createOpts.CapacityUnit, instanceType),
+					MaxPrice:         createOpts.maxPrice(instanceType),
 				})
 			}
 		}
 	}
-	return launchTemplateConfigs
+	return overrides, dropped, nil
+}
+
+// prioritizeInstanceTypes orders the instance types from the most to the least preferred to keep when the overrides are capped:
+// the ones that unused reservations cover, then the cheapest per unit of capacity for the capacity type, then the ones without a known price.
+// Instance types that are otherwise equal keep the order they were selected in.
+func (o CreateFleetOptions) prioritizeInstanceTypes(instanceTypes []instancetypes.InstanceType) []instancetypes.InstanceType {
+	covered := func(instanceType instancetypes.InstanceType) bool {
+		return o.prioritizeReservations() && lo.SomeBy(o.Subnets, func(subnet subnets.Subnet) bool {
+			return o.Reservations.Covers(string(instanceType.InstanceType), lo.FromPtr(subnet.AvailabilityZone))
+		})
+	}
+	prioritized := slices.Clone(instanceTypes)
+	slices.SortStableFunc(prioritized, func(a, b instancetypes.InstanceType) int {
+		if aCovered, bCovered := covered(a), covered(b); aCovered != bCovered {
+			return lo.Ternary(aCovered, -1, 1)
+		}
+		aPrice, aOK := o.unitPrice(a)
+		bPrice, bOK := o.unitPrice(b)
+		if aOK != bOK {
+			return lo.Ternary(aOK, -1, 1)
+		}
+		return cmp.Compare(aPrice, bPrice)
+	})
+	return prioritized
+}
+
+// unitPrice returns the hourly price of the instance type per unit of its weighted capacity, the spot price if only spot capacity is launched.
+// Prices are only known if the instance types were resolved with pricing.
+func (o CreateFleetOptions) unitPrice(instanceType instancetypes.InstanceType) (float64, bool) {
+	price := instanceType.OndemandPricePerHour
+	if ec2utils.NormalizeCapacityType(o.CapacityType) == string(ec2types.DefaultTargetCapacityTypeSpot) && instanceType.SpotPrice != nil {
+		price = instanceType.SpotPrice
+	}
+	if price == nil {
+		return 0, false
+	}
+	if weight := Weight(o.CapacityUnit, instanceType); weight != nil && *weight != 0 {
+		return *price / *weight, true
+	}
+	return *price, true
+}
+
+// Weight returns the weighted capacity of an instance type in the capacity unit, nil for instances since every instance counts as 1
+func Weight(capacityUnit string, instanceType instancetypes.InstanceType) *float64 {
+	switch {
+	case capacityUnit == CapacityUnitVCPU && instanceType.VCpuInfo != nil:
+		return aws.Float64(float64(lo.FromPtr(instanceType.VCpuInfo.DefaultVCpus)))
+	case capacityUnit == CapacityUnitMemoryMiB && instanceType.MemoryInfo != nil:
+		return aws.Float64(float64(lo.FromPtr(instanceType.MemoryInfo.SizeInMiB)))
+	}
+	return nil
+}
+
+// maxPrice returns the spot max price of the instance type per unit of its weighted capacity, nil if the options have no max price
+func (o CreateFleetOptions) maxPrice(instanceType instancetypes.InstanceType) *float64 {
+	if o.SpotMaxPrice == 0 {
+		return nil
+	}
+	if weight := Weight(o.CapacityUnit, instanceType); weight != nil && *weight != 0 {
+		return aws.Float64(o.SpotMaxPrice / *weight)
+	}
+	return aws.Float64(o.SpotMaxPrice)
+}
+
+// splitsCapacity is true when the target capacity is split between on-demand and spot instead of launched as the capacity type
+func (o CreateFleetOptions) splitsCapacity() bool {
+	return o.OnDemandBaseCapacity != 0 || o.OnDemandPercentageAboveBase != nil
+}
+
+// targetCapacities splits the target capacity into its on-demand and spot capacity: the on-demand base capacity,
+// and then the on-demand percentage of the rest rounded up. Without a split, all of it is the capacity type's.
+func (o CreateFleetOptions) targetCapacities(targetCapacity int32) (int32, int32) {
+	spotCapacityType := ec2utils.NormalizeCapacityType(o.CapacityType) == string(ec2types.DefaultTargetCapacityTypeSpot)
+	if !o.splitsCapacity() {
+		return lo.Ternary(spotCapacityType, 0, targetCapacity), lo.Ternary(spotCapacityType, targetCapacity, 0)
+	}
+	base := min(o.OnDemandBaseCapacity, targetCapacity)
+	percentage := lo.FromPtrOr(o.OnDemandPercentageAboveBase, lo.Ternary[int32](spotCapacityType, 0, 100))
+	onDemand := base + int32(math.Ceil(float64(targetCapacity-base)*float64(percentage)/100))
+	return onDemand, targetCapacity - onDemand
+}
+
+// prioritizeReservations is true when on-demand capacity may be launched and there is unused reserved capacity to prefer
+func (o CreateFleetOptions) prioritizeReservations() bool {
+	onDemand, _ := o.targetCapacities(max(o.TargetCapacity, 1))
+	return onDemand != 0 && !o.Reservations.Empty()
 }
 
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
@@ -210,8 +620,54 @@ func filterSets(selectorList []Selector) [][]ec2types.Filter {
 	var filterResult [][]ec2types.Filter
 	for _, term := range selectorList {
 		filters := []ec2types.Filter{}
+		if term.Type != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("type"),
+				Values: []string{term.Type},
+			})
+		}
+		if term.State != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("fleet-state"),
+				Values: []string{term.State},
+			})
+		}
 		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
 		filterResult = append(filterResult, filters)
 	}
 	return filterResult
 }
+
+// IsActive returns true if the fleet has not been deleted and has not failed
+func (f Fleet) IsActive() bool {
+	return lo.Contains([]ec2types.FleetStateCode{
+		ec2types.FleetStateCodeSubmitted,
+		ec2types.FleetStateCodeActive,
+		ec2types.FleetStateCodeModifying,
+	}, f.FleetState)
+}
+
+// ReferencesLaunchTemplate returns true if any of the fleet's launch template configs use the launch template
+func (f Fleet) ReferencesLaunchTemplate(launchTemplateID string) bool {
+	_, ok := lo.Find(f.LaunchTemplateConfigs, func(config ec2types.FleetLaunchTemplateConfig) bool {
+		return config.LaunchTemplateSpecification != nil && lo.FromPtr(config.LaunchTemplateSpecification.LaunchTemplateId) == launchTemplateID
+	})
+	return ok
+}
+
+// Overrides returns the Fleet Launch Template Overrides of the fleet's launch template configs
+func (f Fleet) Overrides() []Override {
+	var overrides []Override
+	for _, config := range f.LaunchTemplateConfigs {
+		for _, override := range config.Overrides {
+			overrides = append(overrides, Override{
+				InstanceType:     string(override.InstanceType),
+				SubnetID:         lo.FromPtr(override.SubnetId),
+				ImageID:          lo.FromPtr(override.ImageId),
+				Priority:         override.Priority,
+				WeightedCapacity: override.WeightedCapacity,
+			})
+		}
+	}
+	return overrides
+}
@@ -0,0 +1,340 @@
+package fleets_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/amazon-ec2-instance-selector/v3/pkg/instancetypes"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	nimbusinstancetypes "github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/reservations"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/samber/lo"
+)
+
+type fakeEC2 struct {
+	fleets.SDKFleetsOps
+	output *ec2.CreateFleetOutput
+	input  *ec2.CreateFleetInput
+}
+
+func (f *fakeEC2) CreateFleet(_ context.Context, input *ec2.CreateFleetInput, _ ...func(*ec2.Options)) (*ec2.CreateFleetOutput, error) {
+	f.input = input
+	return f.output, nil
+}
+
+func TestCreateFleetFailures(t *testing.T) {
+	insufficientCapacity := ec2types.CreateFleetError{
+		ErrorCode:    aws.String("InsufficientInstanceCapacity"),
+		ErrorMessage: aws.String("We currently do not have sufficient m5.large capacity"),
+		Lifecycle:    ec2types.InstanceLifecycleOnDemand,
+		LaunchTemplateAndOverrides: &ec2types.LaunchTemplateAndOverridesResponse{
+			Overrides: &ec2types.FleetLaunchTemplateOverrides{
+				InstanceType:     ec2types.InstanceTypeM5Large,
+				SubnetId:         aws.String("subnet-1"),
+				AvailabilityZone: aws.String("us-west-2a"),
+			},
+		},
+	}
+	failure := fleets.LaunchFailure{
+		ErrorCode:        "InsufficientInstanceCapacity",
+		Message:          "We currently do not have sufficient m5.large capacity",
+		InstanceType:     "m5.large",
+		SubnetID:         "subnet-1",
+		AvailabilityZone: "us-west-2a",
+		Lifecycle:        "on-demand",
+	}
+	createOpts := fleets.CreateFleetOptions{
+		Name:           "test",
+		Namespace:      "dev",
+		LaunchTemplate: launchtemplates.LaunchTemplate{LaunchTemplate: ec2types.LaunchTemplate{LaunchTemplateId: aws.String("lt-1")}},
+	}
+
+	for _, tc := range []struct {
+		name         string
+		output       *ec2.CreateFleetOutput
+		wantFailures []fleets.LaunchFailure
+		wantErr      bool
+	}{
+		{
+			name: "launched",
+			output: &ec2.CreateFleetOutput{
+				FleetId:   aws.String("fleet-1"),
+				Instances: []ec2types.CreateFleetInstance{{InstanceIds: []string{"i-1"}}},
+			},
+		},
+		{
+			name: "partially launched",
+			output: &ec2.CreateFleetOutput{
+				FleetId:   aws.String("fleet-1"),
+				Instances: []ec2types.CreateFleetInstance{{InstanceIds: []string{"i-1"}}},
+				Errors:    []ec2types.CreateFleetError{insufficientCapacity},
+			},
+			wantFailures: []fleets.LaunchFailure{failure},
+		},
+		{
+			name: "nothing launched",
+			output: &ec2.CreateFleetOutput{
+				FleetId: aws.String("fleet-1"),
+				Errors:  []ec2types.CreateFleetError{insufficientCapacity, insufficientCapacity},
+			},
+			wantFailures: []fleets.LaunchFailure{failure, failure},
+			wantErr:      true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fleetID, failures, err := fleets.NewWatcher(&fakeEC2{output: tc.output}).CreateFleet(context.Background(), createOpts)
+			if fleetID != "fleet-1" {
+				t.Errorf("fleet ID = %q, want fleet-1", fleetID)
+			}
+			if !reflect.DeepEqual(failures, tc.wantFailures) {
+				t.Errorf("failures = %+v, want %+v", failures, tc.wantFailures)
+			}
+			if fleets.IsNoInstancesLaunched(err) != tc.wantErr {
+				t.Fatalf("err = %v, want NoInstancesLaunchedError %t", err, tc.wantErr)
+			}
+			if tc.wantErr && strings.Count(err.Error(), "InsufficientInstanceCapacity") != 1 {
+				t.Errorf("err = %q, want the failure reason once", err)
+			}
+		})
+	}
+}
+
+func TestIsNoInstancesLaunched(t *testing.T) {
+	if fleets.IsNoInstancesLaunched(errors.New("failed")) {
+		t.Error("a generic error is not a NoInstancesLaunchedError")
+	}
+	if !fleets.IsNoInstancesLaunched(errors.Join(errors.New("node group web"), fleets.NoInstancesLaunchedError{FleetID: "fleet-1"})) {
+		t.Error("a wrapped NoInstancesLaunchedError is not detected")
+	}
+}
+
+func instanceType(name string, arch ec2types.ArchitectureType, price *float64) nimbusinstancetypes.InstanceType {
+	return nimbusinstancetypes.InstanceType{Details: instancetypes.Details{
+		InstanceTypeInfo: ec2types.InstanceTypeInfo{
+			InstanceType:  ec2types.InstanceType(name),
+			ProcessorInfo: &ec2types.ProcessorInfo{SupportedArchitectures: []ec2types.ArchitectureType{arch}},
+		},
+		OndemandPricePerHour: price,
+	}}
+}
+
+func subnetList(n int) []subnets.Subnet {
+	return lo.Times(n, func(i int) subnets.Subnet {
+		return subnets.Subnet{Subnet: ec2types.Subnet{SubnetId: aws.String(fmt.Sprintf("subnet-%d", i)), AvailabilityZone: aws.String(fmt.Sprintf("zone-%d", i))}}
+	})
+}
+
+func TestOverridesCapped(t *testing.T) {
+	amiList := []amis.AMI{
+		{Image: ec2types.Image{ImageId: aws.String("ami-x86"), Architecture: ec2types.ArchitectureValuesX8664}},
+		{Image: ec2types.Image{ImageId: aws.String("ami-arm"), Architecture: ec2types.ArchitectureValuesArm64}},
+	}
+	// 100 instance types in 4 subnets are 400 overrides, the most expensive ones are dropped unless a reservation covers them
+	instanceTypes := lo.Times(100, func(i int) nimbusinstancetypes.InstanceType {
+		arch := lo.Ternary(i%2 == 0, ec2types.ArchitectureTypeX8664, ec2types.ArchitectureTypeArm64)
+		return instanceType(fmt.Sprintf("type%d.large", i), arch, aws.Float64(float64(100-i)))
+	})
+	instanceTypes = append(instanceTypes, instanceType("unpriced.large", ec2types.ArchitectureTypeX8664, nil))
+	createOpts := fleets.CreateFleetOptions{
+		LaunchTemplate: launchtemplates.LaunchTemplate{LaunchTemplate: ec2types.LaunchTemplate{LaunchTemplateId: aws.String("lt-1")}},
+		AMIs:           amiList,
+		InstanceTypes:  instanceTypes,
+		Subnets:        subnetList(4),
+		Reservations:   reservations.Coverage{Zonal: map[string]map[string]int32{"type0.large": {"zone-0": 1}}},
+	}
+
+	overrides, dropped, err := fleets.Overrides(createOpts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(overrides) != fleets.MaxOverrides {
+		t.Errorf("expected %d overrides, got %d", fleets.MaxOverrides, len(overrides))
+	}
+	if len(dropped) != 26 {
+		t.Errorf("expected 26 dropped instance types, got %d", len(dropped))
+	}
+	for _, name := range []string{"type0.large", "type99.large"} {
+		if slices.Contains(dropped, name) {
+			t.Errorf("expected %s to be kept, dropped %v", name, dropped)
+		}
+	}
+	for _, name := range []string{"type1.large", "unpriced.large"} {
+		if !slices.Contains(dropped, name) {
+			t.Errorf("expected %s to be dropped, dropped %v", name, dropped)
+		}
+	}
+
+	fake := &fakeEC2{output: &ec2.CreateFleetOutput{FleetId: aws.String("fleet-1"), Instances: []ec2types.CreateFleetInstance{{InstanceIds: []string{"i-1"}}}}}
+	if _, _, err := fleets.NewWatcher(fake).CreateFleet(context.Background(), createOpts); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fake.input.LaunchTemplateConfigs) != 2 {
+		t.Fatalf("expected a launch template config per AMI, got %d", len(fake.input.LaunchTemplateConfigs))
+	}
+	for _, config := range fake.input.LaunchTemplateConfigs {
+		images := lo.Uniq(lo.Map(config.Overrides, func(override ec2types.FleetLaunchTemplateOverridesRequest, _ int) string {
+			return lo.FromPtr(override.ImageId)
+		}))
+		if len(images) != 1 {
+			t.Errorf("expected the overrides of a config to share an AMI, got %v", images)
+		}
+	}
+
+	createOpts.Subnets = subnetList(fleets.MaxOverrides + 1)
+	if _, _, err := fleets.Overrides(createOpts); err == nil {
+		t.Error("expected an error when the subnets exceed the max overrides")
+	}
+}
+
+func TestCreateFleetAllocation(t *testing.T) {
+	createOpts := fleets.CreateFleetOptions{
+		LaunchTemplate: launchtemplates.LaunchTemplate{LaunchTemplate: ec2types.LaunchTemplate{LaunchTemplateId: aws.String("lt-1")}},
+		AMIs:           []amis.AMI{{Image: ec2types.Image{ImageId: aws.String("ami-x86"), Architecture: ec2types.ArchitectureValuesX8664}}},
+		InstanceTypes:  []nimbusinstancetypes.InstanceType{instanceType("m5.large", ec2types.ArchitectureTypeX8664, nil)},
+		Subnets:        subnetList(1),
+		CapacityType:   "spot",
+		TargetCapacity: 10,
+	}
+
+	for _, tc := range []struct {
+		name         string
+		opts         func(fleets.CreateFleetOptions) fleets.CreateFleetOptions
+		wantOnDemand *int32
+		wantSpot     *int32
+	}{
+		{
+			name: "capacity type",
+			opts: func(o fleets.CreateFleetOptions) fleets.CreateFleetOptions { return o },
+		},
+		{
+			name: "on-demand base",
+			opts: func(o fleets.CreateFleetOptions) fleets.CreateFleetOptions {
+				o.OnDemandBaseCapacity = 2
+				return o
+			},
+			wantOnDemand: aws.Int32(2),
+			wantSpot:     aws.Int32(8),
+		},
+		{
+			name: "on-demand percentage above base rounds up",
+			opts: func(o fleets.CreateFleetOptions) fleets.CreateFleetOptions {
+				o.OnDemandBaseCapacity = 1
+				o.OnDemandPercentageAboveBase = aws.Int32(25)
+				return o
+			},
+			wantOnDemand: aws.Int32(4),
+			wantSpot:     aws.Int32(6),
+		},
+		{
+			name: "base above the target capacity",
+			opts: func(o fleets.CreateFleetOptions) fleets.CreateFleetOptions {
+				o.OnDemandBaseCapacity = 20
+				return o
+			},
+			wantOnDemand: aws.Int32(10),
+			wantSpot:     aws.Int32(0),
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := &fakeEC2{output: &ec2.CreateFleetOutput{FleetId: aws.String("fleet-1"), Instances: []ec2types.CreateFleetInstance{{InstanceIds: []string{"i-1"}}}}}
+			if _, _, err := fleets.NewWatcher(fake).CreateFleet(context.Background(), tc.opts(createOpts)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			spec := fake.input.TargetCapacitySpecification
+			if !reflect.DeepEqual(spec.OnDemandTargetCapacity, tc.wantOnDemand) || !reflect.DeepEqual(spec.SpotTargetCapacity, tc.wantSpot) {
+				t.Errorf("on-demand/spot target capacity = %v/%v, want %v/%v", lo.FromPtr(spec.OnDemandTargetCapacity), lo.FromPtr(spec.SpotTargetCapacity),
+					lo.FromPtr(tc.wantOnDemand), lo.FromPtr(tc.wantSpot))
+			}
+			if fake.input.Type != ec2types.FleetTypeInstant {
+				t.Errorf("type = %s, want instant", fake.input.Type)
+			}
+		})
+	}
+
+	t.Run("spot options", func(t *testing.T) {
+		opts := createOpts
+		opts.SpotAllocationStrategy = string(ec2types.SpotAllocationStrategyCapacityOptimized)
+		opts.SpotMaxPrice = 0.5
+		opts.CapacityUnit = fleets.CapacityUnitVCPU
+		opts.InstanceTypes = []nimbusinstancetypes.InstanceType{instanceType("m5.large", ec2types.ArchitectureTypeX8664, nil)}
+		opts.InstanceTypes[0].VCpuInfo = &ec2types.VCpuInfo{DefaultVCpus: aws.Int32(2)}
+		opts.CapacityRebalance = string(ec2types.FleetReplacementStrategyLaunchBeforeTerminate)
+		opts.TerminationDelay = 5 * time.Minute
+		fake := &fakeEC2{output: &ec2.CreateFleetOutput{FleetId: aws.String("fleet-1")}}
+		// a maintain fleet launches its instances after it is created, so launching none yet is not an error
+		if _, _, err := fleets.NewWatcher(fake).CreateFleet(context.Background(), opts); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if fake.input.Type != ec2types.FleetTypeMaintain {
+			t.Errorf("type = %s, want maintain", fake.input.Type)
+		}
+		if strategy := fake.input.SpotOptions.AllocationStrategy; strategy != ec2types.SpotAllocationStrategyCapacityOptimized {
+			t.Errorf("spot allocation strategy = %s, want capacity-optimized", strategy)
+		}
+		rebalance := fake.input.SpotOptions.MaintenanceStrategies.CapacityRebalance
+		if rebalance.ReplacementStrategy != ec2types.FleetReplacementStrategyLaunchBeforeTerminate || lo.FromPtr(rebalance.TerminationDelay) != 300 {
+			t.Errorf("capacity rebalance = %s after %ds, want launch-before-terminate after 300s", rebalance.ReplacementStrategy, lo.FromPtr(rebalance.TerminationDelay))
+		}
+		// the max price per instance is divided by the override's weight of 2 vCPUs
+		if maxPrice := lo.FromPtr(fake.input.LaunchTemplateConfigs[0].Overrides[0].MaxPrice); maxPrice != "0.25" {
+			t.Errorf("override max price = %s, want 0.25", maxPrice)
+		}
+	})
+}
+
+type fakeDescribeFleets struct {
+	fleets.SDKFleetsOps
+	fleets []ec2types.FleetData
+	calls  int
+}
+
+func (f *fakeDescribeFleets) DescribeFleets(_ context.Context, input *ec2.DescribeFleetsInput, _ ...func(*ec2.Options)) (*ec2.DescribeFleetsOutput, error) {
+	f.calls++
+	fleetType := input.Filters[0].Values[0]
+	return &ec2.DescribeFleetsOutput{Fleets: lo.Filter(f.fleets, func(fleet ec2types.FleetData, _ int) bool { return string(fleet.Type) == fleetType })}, nil
+}
+
+func TestResolveLaunchTemplateReferences(t *testing.T) {
+	fleetData := func(id string, fleetType ec2types.FleetType, state ec2types.FleetStateCode, launchTemplateIDs ...string) ec2types.FleetData {
+		return ec2types.FleetData{
+			FleetId:    aws.String(id),
+			Type:       fleetType,
+			FleetState: state,
+			LaunchTemplateConfigs: lo.Map(launchTemplateIDs, func(id string, _ int) ec2types.FleetLaunchTemplateConfig {
+				return ec2types.FleetLaunchTemplateConfig{LaunchTemplateSpecification: &ec2types.FleetLaunchTemplateSpecification{LaunchTemplateId: aws.String(id)}}
+			}),
+		}
+	}
+	fake := &fakeDescribeFleets{fleets: []ec2types.FleetData{
+		fleetData("fleet-1", ec2types.FleetTypeMaintain, ec2types.FleetStateCodeActive, "lt-1", "lt-2"),
+		fleetData("fleet-2", ec2types.FleetTypeRequest, ec2types.FleetStateCodeSubmitted, "lt-2"),
+		fleetData("fleet-3", ec2types.FleetTypeMaintain, ec2types.FleetStateCodeDeleted, "lt-3"),
+	}}
+	references, err := fleets.NewWatcher(fake).ResolveLaunchTemplateReferences(context.Background(), []string{"lt-1", "lt-2", "lt-3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	got := lo.MapValues(references, func(referencing []fleets.Fleet, _ string) []string {
+		return lo.Map(referencing, func(fleet fleets.Fleet, _ int) string { return *fleet.FleetId })
+	})
+	want := map[string][]string{"lt-1": {"fleet-1"}, "lt-2": {"fleet-1", "fleet-2"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ResolveLaunchTemplateReferences() = %v, want %v", got, want)
+	}
+	// one DescribeFleets call per fleet type, not per launch template
+	if fake.calls != 2 {
+		t.Errorf("DescribeFleets called %d times, want 2", fake.calls)
+	}
+}
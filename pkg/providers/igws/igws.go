@@ -9,7 +9,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
@@ -27,6 +29,9 @@ type SDKIGWOps interface {
 	DeleteInternetGateway(context.Context, *ec2.DeleteInternetGatewayInput, ...func(*ec2.Options)) (*ec2.DeleteInternetGatewayOutput, error)
 	AttachInternetGateway(context.Context, *ec2.AttachInternetGatewayInput, ...func(*ec2.Options)) (*ec2.AttachInternetGatewayOutput, error)
 	DetachInternetGateway(context.Context, *ec2.DetachInternetGatewayInput, ...func(*ec2.Options)) (*ec2.DetachInternetGatewayOutput, error)
+	ec2.DescribeEgressOnlyInternetGatewaysAPIClient
+	CreateEgressOnlyInternetGateway(context.Context, *ec2.CreateEgressOnlyInternetGatewayInput, ...func(*ec2.Options)) (*ec2.CreateEgressOnlyInternetGatewayOutput, error)
+	DeleteEgressOnlyInternetGateway(context.Context, *ec2.DeleteEgressOnlyInternetGatewayInput, ...func(*ec2.Options)) (*ec2.DeleteEgressOnlyInternetGatewayOutput, error)
 }
 
 // Selector is a struct that represents an Internet Gateway selector
@@ -42,6 +47,11 @@ type InternetGateway struct {
 	ec2types.InternetGateway
 }
 
+// EgressOnlyInternetGateway represents an AWS Egress-Only Internet Gateway, which lets IPv6 traffic of private subnets out but not in
+type EgressOnlyInternetGateway struct {
+	ec2types.EgressOnlyInternetGateway
+}
+
 // ParseSelectors parses a string of selectors into a slice of Selector structs
 func ParseSelectors(selectorStr string) ([]Selector, error) {
 	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
@@ -95,12 +105,13 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]InternetG
 	return igws, nil
 }
 
-func (w Watcher) Create(ctx context.Context, namespace, name string, vpc vpcs.VPC) (*InternetGateway, error) {
+// Create creates an internet gateway tagged with the namespaced tags and the additional tags and attaches it to the VPC
+func (w Watcher) Create(ctx context.Context, namespace, name string, vpc vpcs.VPC, tags map[string]string) (*InternetGateway, error) {
 	igwOut, err := w.ec2API.CreateInternetGateway(ctx, &ec2.CreateInternetGatewayInput{
 		TagSpecifications: []types.TagSpecification{
 			{
 				ResourceType: types.ResourceTypeInternetGateway,
-				Tags:         tagutils.EC2NamespacedTags(namespace, name),
+				Tags:         tagutils.MapToEC2Tags(lo.Assign(tags, tagutils.NamespacedTags(namespace, name))),
 			},
 		},
 	})
@@ -118,18 +129,71 @@ func (w Watcher) Create(ctx context.Context, namespace, name string, vpc vpcs.VP
 
 func (w Watcher) Delete(ctx context.Context, igw InternetGateway) error {
 	for _, attachment := range igw.Attachments {
-		_, err := w.ec2API.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
+		// detaching fails while addresses of terminated instances or deleted NAT Gateways are still mapped in the VPC
+		if err := retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+			_, err := w.ec2API.DetachInternetGateway(ctx, &ec2.DetachInternetGatewayInput{
+				InternetGatewayId: igw.InternetGatewayId,
+				VpcId:             attachment.VpcId,
+			})
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.ec2API.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{
 			InternetGatewayId: igw.InternetGatewayId,
-			VpcId:             attachment.VpcId,
 		})
+		return err
+	})
+}
+
+// ResolveEgressOnly returns the Egress-Only Internet Gateways that have all of the tags and, if vpcID is not empty, are attached to the VPC
+func (w Watcher) ResolveEgressOnly(ctx context.Context, tags map[string]string, vpcID string) ([]EgressOnlyInternetGateway, error) {
+	var eigws []EgressOnlyInternetGateway
+	pager := ec2.NewDescribeEgressOnlyInternetGatewaysPaginator(w.ec2API, &ec2.DescribeEgressOnlyInternetGatewaysInput{
+		Filters: selectors.TagsToEC2Filters(tags),
+	})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
 		if err != nil {
-			return err
+			return nil, fmt.Errorf("failed to describe Egress-Only Internet Gateways: %w", err)
+		}
+		// Egress-Only Internet Gateways can not be filtered by their VPC, so they are filtered here
+		for _, eigw := range page.EgressOnlyInternetGateways {
+			if vpcID == "" || lo.SomeBy(eigw.Attachments, func(attachment ec2types.InternetGatewayAttachment) bool { return lo.FromPtr(attachment.VpcId) == vpcID }) {
+				eigws = append(eigws, EgressOnlyInternetGateway{eigw})
+			}
 		}
 	}
-	_, err := w.ec2API.DeleteInternetGateway(ctx, &ec2.DeleteInternetGatewayInput{
-		InternetGatewayId: igw.InternetGatewayId,
+	return eigws, nil
+}
+
+// CreateEgressOnly creates an Egress-Only Internet Gateway in the VPC tagged with the namespaced tags and the additional tags
+func (w Watcher) CreateEgressOnly(ctx context.Context, namespace, name string, vpc vpcs.VPC, tags map[string]string) (*EgressOnlyInternetGateway, error) {
+	eigwOut, err := w.ec2API.CreateEgressOnlyInternetGateway(ctx, &ec2.CreateEgressOnlyInternetGatewayInput{
+		VpcId: vpc.VpcId,
+		TagSpecifications: []types.TagSpecification{
+			{
+				ResourceType: types.ResourceTypeEgressOnlyInternetGateway,
+				Tags:         tagutils.MapToEC2Tags(lo.Assign(tags, tagutils.NamespacedTags(namespace, name))),
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Egress-Only Internet Gateway: %w", err)
+	}
+	return &EgressOnlyInternetGateway{*eigwOut.EgressOnlyInternetGateway}, nil
+}
+
+// DeleteEgressOnly deletes the Egress-Only Internet Gateway, it is detached from its VPC when it is deleted
+func (w Watcher) DeleteEgressOnly(ctx context.Context, eigw EgressOnlyInternetGateway) error {
+	return retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.ec2API.DeleteEgressOnlyInternetGateway(ctx, &ec2.DeleteEgressOnlyInternetGatewayInput{
+			EgressOnlyInternetGatewayId: eigw.EgressOnlyInternetGatewayId,
+		})
+		return err
 	})
-	return err
 }
 
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
@@ -0,0 +1,337 @@
+package instanceprofiles
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+	"github.com/bwagner5/nimbus/pkg/retry"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// pathPrefix is the IAM path of every instance profile that nimbus creates, followed by the namespace and name
+	pathPrefix = "/nimbus/"
+	// maxNameLength is the maximum length of an IAM instance profile name
+	maxNameLength = 128
+	// ec2TrustPolicy allows EC2 instances to assume a role
+	ec2TrustPolicy = `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"Service":"ec2.amazonaws.com"},"Action":"sts:AssumeRole"}]}`
+
+	errCodeNoSuchEntity        = "NoSuchEntity"
+	errCodeEntityAlreadyExists = "EntityAlreadyExists"
+	errCodeDeleteConflict      = "DeleteConflict"
+)
+
+// invalidNameChars are the characters that are not allowed in IAM instance profile names
+var invalidNameChars = regexp.MustCompile(`[^\w+=,.@-]`)
+
+// Watcher resolves IAM roles and manages the instance profiles that nimbus creates for them
+type Watcher struct {
+	iamAPI SDKIAMOps
+}
+
+// SDKIAMOps is an interface that combines the necessary IAM SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKIAMOps interface {
+	GetRole(context.Context, *iam.GetRoleInput, ...func(*iam.Options)) (*iam.GetRoleOutput, error)
+	GetInstanceProfile(context.Context, *iam.GetInstanceProfileInput, ...func(*iam.Options)) (*iam.GetInstanceProfileOutput, error)
+	ListInstanceProfiles(context.Context, *iam.ListInstanceProfilesInput, ...func(*iam.Options)) (*iam.ListInstanceProfilesOutput, error)
+	CreateInstanceProfile(context.Context, *iam.CreateInstanceProfileInput, ...func(*iam.Options)) (*iam.CreateInstanceProfileOutput, error)
+	AddRoleToInstanceProfile(context.Context, *iam.AddRoleToInstanceProfileInput, ...func(*iam.Options)) (*iam.AddRoleToInstanceProfileOutput, error)
+	RemoveRoleFromInstanceProfile(context.Context, *iam.RemoveRoleFromInstanceProfileInput, ...func(*iam.Options)) (*iam.RemoveRoleFromInstanceProfileOutput, error)
+	DeleteInstanceProfile(context.Context, *iam.DeleteInstanceProfileInput, ...func(*iam.Options)) (*iam.DeleteInstanceProfileOutput, error)
+	PutRolePolicy(context.Context, *iam.PutRolePolicyInput, ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error)
+	DeleteRolePolicy(context.Context, *iam.DeleteRolePolicyInput, ...func(*iam.Options)) (*iam.DeleteRolePolicyOutput, error)
+	CreateRole(context.Context, *iam.CreateRoleInput, ...func(*iam.Options)) (*iam.CreateRoleOutput, error)
+	AttachRolePolicy(context.Context, *iam.AttachRolePolicyInput, ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error)
+	DetachRolePolicy(context.Context, *iam.DetachRolePolicyInput, ...func(*iam.Options)) (*iam.DetachRolePolicyOutput, error)
+	DeleteRole(context.Context, *iam.DeleteRoleInput, ...func(*iam.Options)) (*iam.DeleteRoleOutput, error)
+}
+
+// Role is an IAM role
+type Role struct {
+	RoleName string `table:"Name"`
+	RoleID   string
+	Arn      string `table:"ARN"`
+	Path     string
+}
+
+// InstanceProfile is an IAM instance profile, the container of the role that EC2 instances assume
+type InstanceProfile struct {
+	InstanceProfileName string `table:"Name"`
+	InstanceProfileID   string
+	Arn                 string `table:"ARN"`
+	Path                string
+	CreateDate          time.Time
+	Roles               []Role
+}
+
+// NewWatcher creates a new Instance Profile Watcher
+func NewWatcher(iamAPI SDKIAMOps) Watcher {
+	return Watcher{
+		iamAPI: iamAPI,
+	}
+}
+
+// ResolveRole returns the existing role with the name or ARN
+func (w Watcher) ResolveRole(ctx context.Context, role string) (Role, error) {
+	// role ARNs are arn:aws:iam::<account>:role/<path><name>
+	roleName := role[strings.LastIndex(role, "/")+1:]
+	out, err := w.iamAPI.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if IsNotFound(err) {
+		return Role{}, fmt.Errorf("IAM role %s does not exist", role)
+	}
+	if err != nil {
+		return Role{}, fmt.Errorf("failed to get IAM role %s: %w", role, err)
+	}
+	return newRole(lo.FromPtr(out.Role)), nil
+}
+
+// EnsureRole returns the role with the name that EC2 instances can assume, creating it under the nimbus path if it does not exist.
+// The AWS managed policies with the names are attached to it whether it was created or not, so a partially created role is repaired.
+// created is true if the role was created by this call.
+func (w Watcher) EnsureRole(ctx context.Context, roleName, description string, managedPolicies []string, tags map[string]string) (role Role, created bool, err error) {
+	out, err := w.iamAPI.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	switch {
+	case err == nil:
+		role = newRole(lo.FromPtr(out.Role))
+	case IsNotFound(err):
+		createOut, err := w.iamAPI.CreateRole(ctx, &iam.CreateRoleInput{
+			RoleName:                 aws.String(roleName),
+			Path:                     aws.String(pathPrefix),
+			AssumeRolePolicyDocument: aws.String(ec2TrustPolicy),
+			Description:              lo.EmptyableToPtr(description),
+			Tags:                     iamTags(tags),
+		})
+		if err != nil {
+			return Role{}, false, fmt.Errorf("failed to create IAM role %s: %w", roleName, err)
+		}
+		role, created = newRole(lo.FromPtr(createOut.Role)), true
+	default:
+		return Role{}, false, fmt.Errorf("failed to get IAM role %s: %w", roleName, err)
+	}
+	for _, policy := range managedPolicies {
+		if _, err := w.iamAPI.AttachRolePolicy(ctx, &iam.AttachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(ManagedPolicyArn(role.Arn, policy)),
+		}); err != nil {
+			return role, created, fmt.Errorf("failed to attach policy %s to IAM role %s: %w", policy, roleName, err)
+		}
+	}
+	return role, created, nil
+}
+
+// DeleteRole detaches the AWS managed policies with the names from the role and deletes it, false if it does not exist.
+// Roles that are still in instance profiles, e.g. of VMs that use them, can not be deleted and fail with a delete conflict, see IsDeleteConflict.
+func (w Watcher) DeleteRole(ctx context.Context, roleName string, managedPolicies []string) (bool, error) {
+	out, err := w.iamAPI.GetRole(ctx, &iam.GetRoleInput{RoleName: aws.String(roleName)})
+	if IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get IAM role %s: %w", roleName, err)
+	}
+	for _, policy := range managedPolicies {
+		if _, err := w.iamAPI.DetachRolePolicy(ctx, &iam.DetachRolePolicyInput{
+			RoleName:  aws.String(roleName),
+			PolicyArn: aws.String(ManagedPolicyArn(lo.FromPtr(out.Role.Arn), policy)),
+		}); err != nil && !IsNotFound(err) {
+			return false, fmt.Errorf("failed to detach policy %s from IAM role %s: %w", policy, roleName, err)
+		}
+	}
+	if _, err := w.iamAPI.DeleteRole(ctx, &iam.DeleteRoleInput{RoleName: aws.String(roleName)}); err != nil {
+		return false, fmt.Errorf("failed to delete IAM role %s: %w", roleName, err)
+	}
+	return true, nil
+}
+
+// ManagedPolicyArn returns the ARN of the AWS managed policy with the name in the partition of the role ARN
+func ManagedPolicyArn(roleArn, policyName string) string {
+	partition := "aws"
+	if parts := strings.Split(roleArn, ":"); len(parts) > 1 && parts[1] != "" {
+		partition = parts[1]
+	}
+	return fmt.Sprintf("arn:%s:iam::aws:policy/%s", partition, policyName)
+}
+
+// Get returns the instance profile of the role for the namespace and name, false if it was not created yet
+func (w Watcher) Get(ctx context.Context, namespace, name string, role Role) (InstanceProfile, bool, error) {
+	out, err := w.iamAPI.GetInstanceProfile(ctx, &iam.GetInstanceProfileInput{InstanceProfileName: aws.String(ProfileName(namespace, name, role.RoleName))})
+	if IsNotFound(err) {
+		return InstanceProfile{}, false, nil
+	}
+	if err != nil {
+		return InstanceProfile{}, false, fmt.Errorf("failed to get instance profile: %w", err)
+	}
+	return newInstanceProfile(lo.FromPtr(out.InstanceProfile)), true, nil
+}
+
+// Ensure returns the instance profile of the role for the namespace and name, creating it with the role if it does not exist.
+// created is true if the instance profile or its role was added by this call, EC2 may not accept it for a few seconds after.
+func (w Watcher) Ensure(ctx context.Context, namespace, name string, role Role) (profile InstanceProfile, created bool, err error) {
+	profileName := ProfileName(namespace, name, role.RoleName)
+	profile, found, err := w.Get(ctx, namespace, name, role)
+	if err != nil {
+		return InstanceProfile{}, false, err
+	}
+	if !found {
+		out, err := w.iamAPI.CreateInstanceProfile(ctx, &iam.CreateInstanceProfileInput{
+			InstanceProfileName: aws.String(profileName),
+			Path:                aws.String(Path(namespace, name)),
+			Tags:                iamTags(tagutils.NamespacedTags(namespace, name)),
+		})
+		// A concurrent launch may have created the instance profile first, which is safe to share
+		if IsAlreadyExists(err) {
+			profile, _, err = w.Get(ctx, namespace, name, role)
+		} else if err == nil {
+			profile = newInstanceProfile(lo.FromPtr(out.InstanceProfile))
+		}
+		if err != nil {
+			return InstanceProfile{}, false, fmt.Errorf("failed to create instance profile %s: %w", profileName, err)
+		}
+		created = true
+	}
+	if lo.ContainsBy(profile.Roles, func(profileRole Role) bool { return profileRole.RoleName == role.RoleName }) {
+		return profile, created, nil
+	}
+	// the role is added separately from the creation, so a failed launch may have left the instance profile without it
+	if _, err := w.iamAPI.AddRoleToInstanceProfile(ctx, &iam.AddRoleToInstanceProfileInput{
+		InstanceProfileName: aws.String(profileName),
+		RoleName:            aws.String(role.RoleName),
+	}); err != nil && !IsAlreadyExists(err) {
+		return profile, created, fmt.Errorf("failed to add role %s to instance profile %s: %w", role.RoleName, profileName, err)
+	}
+	profile.Roles = append(profile.Roles, role)
+	return profile, true, nil
+}
+
+// Resolve returns the instance profiles that nimbus created for the namespace and name, every one of the namespace if name is empty
+func (w Watcher) Resolve(ctx context.Context, namespace, name string) ([]InstanceProfile, error) {
+	var profiles []InstanceProfile
+	pager := iam.NewListInstanceProfilesPaginator(w.iamAPI, &iam.ListInstanceProfilesInput{PathPrefix: aws.String(Path(namespace, name))})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list instance profiles: %w", err)
+		}
+		profiles = append(profiles, lo.Map(page.InstanceProfiles, func(profile iamtypes.InstanceProfile, _ int) InstanceProfile {
+			return newInstanceProfile(profile)
+		})...)
+	}
+	return profiles, nil
+}
+
+// PutPolicy creates or replaces the inline policy of the instance profile's roles, which is named after the instance profile.
+// Roles are shared by every VM that uses them, so each VM's permissions are a separate policy that is deleted with its instance profile.
+func (w Watcher) PutPolicy(ctx context.Context, profile InstanceProfile, document string) error {
+	for _, role := range profile.Roles {
+		if _, err := w.iamAPI.PutRolePolicy(ctx, &iam.PutRolePolicyInput{
+			RoleName:       aws.String(role.RoleName),
+			PolicyName:     aws.String(profile.InstanceProfileName),
+			PolicyDocument: aws.String(document),
+		}); err != nil {
+			return fmt.Errorf("failed to put policy %s on role %s: %w", profile.InstanceProfileName, role.RoleName, err)
+		}
+	}
+	return nil
+}
+
+// Delete removes the roles and their inline policy of the instance profile, see PutPolicy, and deletes it. The roles themselves are not deleted.
+func (w Watcher) Delete(ctx context.Context, profile InstanceProfile) error {
+	for _, role := range profile.Roles {
+		if _, err := w.iamAPI.DeleteRolePolicy(ctx, &iam.DeleteRolePolicyInput{
+			RoleName:   aws.String(role.RoleName),
+			PolicyName: aws.String(profile.InstanceProfileName),
+		}); err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to delete policy %s of role %s: %w", profile.InstanceProfileName, role.RoleName, err)
+		}
+		if _, err := w.iamAPI.RemoveRoleFromInstanceProfile(ctx, &iam.RemoveRoleFromInstanceProfileInput{
+			InstanceProfileName: aws.String(profile.InstanceProfileName),
+			RoleName:            aws.String(role.RoleName),
+		}); err != nil && !IsNotFound(err) {
+			return fmt.Errorf("failed to remove role %s from instance profile %s: %w", role.RoleName, profile.InstanceProfileName, err)
+		}
+	}
+	// IAM is eventually consistent, the profile can still conflict with its removed roles for a moment
+	if err := retry.Do(ctx, IsDeleteConflict, func() error {
+		_, err := w.iamAPI.DeleteInstanceProfile(ctx, &iam.DeleteInstanceProfileInput{
+			InstanceProfileName: aws.String(profile.InstanceProfileName),
+		})
+		return err
+	}); err != nil && !IsNotFound(err) {
+		return fmt.Errorf("failed to delete instance profile %s: %w", profile.InstanceProfileName, err)
+	}
+	return nil
+}
+
+// ProfileName returns the name of the instance profile of the role for the namespace and name.
+// Instance profile names are unique in the account, so the name includes all three and is shortened with a hash if it is too long.
+func ProfileName(namespace, name, roleName string) string {
+	profileName := invalidNameChars.ReplaceAllString(strings.Join(lo.Compact([]string{namespace, name, roleName}), "-"), "-")
+	if len(profileName) <= maxNameLength {
+		return profileName
+	}
+	hash := sha256.Sum256([]byte(profileName))
+	return fmt.Sprintf("%s-%x", profileName[:maxNameLength-9], hash[:4])
+}
+
+// Path returns the IAM path of the instance profiles of the namespace and name, the namespace's path if name is empty
+func Path(namespace, name string) string {
+	if name == "" {
+		return fmt.Sprintf("%s%s/", pathPrefix, namespace)
+	}
+	return fmt.Sprintf("%s%s/%s/", pathPrefix, namespace, name)
+}
+
+// IsNotFound returns true if the error is an IAM NoSuchEntity error
+func IsNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == errCodeNoSuchEntity
+}
+
+// IsDeleteConflict returns true if the error is an IAM DeleteConflict error, e.g. when a role is still attached to an instance profile
+func IsDeleteConflict(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == errCodeDeleteConflict
+}
+
+// IsAlreadyExists returns true if the error is an IAM EntityAlreadyExists error
+func IsAlreadyExists(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == errCodeEntityAlreadyExists
+}
+
+func newRole(role iamtypes.Role) Role {
+	return Role{
+		RoleName: lo.FromPtr(role.RoleName),
+		RoleID:   lo.FromPtr(role.RoleId),
+		Arn:      lo.FromPtr(role.Arn),
+		Path:     lo.FromPtr(role.Path),
+	}
+}
+
+func newInstanceProfile(profile iamtypes.InstanceProfile) InstanceProfile {
+	return InstanceProfile{
+		InstanceProfileName: lo.FromPtr(profile.InstanceProfileName),
+		InstanceProfileID:   lo.FromPtr(profile.InstanceProfileId),
+		Arn:                 lo.FromPtr(profile.Arn),
+		Path:                lo.FromPtr(profile.Path),
+		CreateDate:          lo.FromPtr(profile.CreateDate),
+		Roles:               lo.Map(profile.Roles, func(role iamtypes.Role, _ int) Role { return newRole(role) }),
+	}
+}
+
+func iamTags(tags map[string]string) []iamtypes.Tag {
+	return lo.MapToSlice(tags, func(key, value string) iamtypes.Tag {
+		return iamtypes.Tag{Key: aws.String(key), Value: aws.String(value)}
+	})
+}
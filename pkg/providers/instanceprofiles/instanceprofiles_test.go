@@ -0,0 +1,263 @@
+package instanceprofiles_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	iamtypes "github.com/aws/aws-sdk-go-v2/service/iam/types"
+	"github.com/aws/smithy-go"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
+	"github.com/samber/lo"
+)
+
+func TestProfileName(t *testing.T) {
+	type testCases struct {
+		name      string
+		namespace string
+		vmName    string
+		roleName  string
+		expected  string
+		// hashed names only have the expected prefix followed by the hash
+		hashed bool
+	}
+
+	for _, tc := range []testCases{
+		{name: "joined", namespace: "default", vmName: "web", roleName: "web-role", expected: "default-web-web-role"},
+		{name: "invalid characters are replaced", namespace: "team a", vmName: "web/1", roleName: "role", expected: "team-a-web-1-role"},
+		{
+			name:      "long names are shortened with a hash",
+			namespace: "default",
+			vmName:    strings.Repeat("a", 120),
+			roleName:  "role",
+			expected:  "default-" + strings.Repeat("a", 111) + "-",
+			hashed:    true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			profileName := instanceprofiles.ProfileName(tc.namespace, tc.vmName, tc.roleName)
+			if tc.hashed {
+				if len(profileName) != 128 || !strings.HasPrefix(profileName, tc.expected) {
+					t.Errorf("expected 128 characters starting with %s, got %s", tc.expected, profileName)
+				}
+				return
+			}
+			if profileName != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, profileName)
+			}
+		})
+	}
+}
+
+func TestPath(t *testing.T) {
+	if path := instanceprofiles.Path("default", "web"); path != "/nimbus/default/web/" {
+		t.Errorf("expected /nimbus/default/web/, got %s", path)
+	}
+	if path := instanceprofiles.Path("default", ""); path != "/nimbus/default/" {
+		t.Errorf("expected /nimbus/default/, got %s", path)
+	}
+}
+
+type fakeIAM struct {
+	instanceprofiles.SDKIAMOps
+	profiles map[string]*instanceprofiles.InstanceProfile
+	created  int
+	// policies are the inline policy documents by role and policy name
+	policies map[string]string
+	roles    map[string]*instanceprofiles.Role
+	// attached are the managed policy ARNs attached to roles by role and policy ARN
+	attached map[string]bool
+}
+
+// errNoSuchEntity is the error IAM returns for entities that do not exist
+var errNoSuchEntity = &smithy.GenericAPIError{Code: "NoSuchEntity"}
+
+func sdkRole(role instanceprofiles.Role) iamtypes.Role {
+	return iamtypes.Role{RoleName: aws.String(role.RoleName), Arn: aws.String(role.Arn), Path: aws.String(role.Path)}
+}
+
+func sdkInstanceProfile(profile instanceprofiles.InstanceProfile) *iamtypes.InstanceProfile {
+	return &iamtypes.InstanceProfile{
+		InstanceProfileName: aws.String(profile.InstanceProfileName),
+		Path:                aws.String(profile.Path),
+		Roles:               lo.Map(profile.Roles, func(role instanceprofiles.Role, _ int) iamtypes.Role { return sdkRole(role) }),
+	}
+}
+
+func (f *fakeIAM) GetInstanceProfile(_ context.Context, input *iam.GetInstanceProfileInput, _ ...func(*iam.Options)) (*iam.GetInstanceProfileOutput, error) {
+	profile, ok := f.profiles[*input.InstanceProfileName]
+	if !ok {
+		return nil, errNoSuchEntity
+	}
+	return &iam.GetInstanceProfileOutput{InstanceProfile: sdkInstanceProfile(*profile)}, nil
+}
+
+func (f *fakeIAM) CreateInstanceProfile(_ context.Context, input *iam.CreateInstanceProfileInput, _ ...func(*iam.Options)) (*iam.CreateInstanceProfileOutput, error) {
+	f.created++
+	profile := &instanceprofiles.InstanceProfile{InstanceProfileName: *input.InstanceProfileName, Path: *input.Path}
+	f.profiles[*input.InstanceProfileName] = profile
+	return &iam.CreateInstanceProfileOutput{InstanceProfile: sdkInstanceProfile(*profile)}, nil
+}
+
+func (f *fakeIAM) AddRoleToInstanceProfile(_ context.Context, input *iam.AddRoleToInstanceProfileInput, _ ...func(*iam.Options)) (*iam.AddRoleToInstanceProfileOutput, error) {
+	profile := f.profiles[*input.InstanceProfileName]
+	profile.Roles = append(profile.Roles, instanceprofiles.Role{RoleName: *input.RoleName})
+	return &iam.AddRoleToInstanceProfileOutput{}, nil
+}
+
+func (f *fakeIAM) PutRolePolicy(_ context.Context, input *iam.PutRolePolicyInput, _ ...func(*iam.Options)) (*iam.PutRolePolicyOutput, error) {
+	f.policies[*input.RoleName+"/"+*input.PolicyName] = *input.PolicyDocument
+	return &iam.PutRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) DeleteRolePolicy(_ context.Context, input *iam.DeleteRolePolicyInput, _ ...func(*iam.Options)) (*iam.DeleteRolePolicyOutput, error) {
+	if _, ok := f.policies[*input.RoleName+"/"+*input.PolicyName]; !ok {
+		return nil, errNoSuchEntity
+	}
+	delete(f.policies, *input.RoleName+"/"+*input.PolicyName)
+	return &iam.DeleteRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) RemoveRoleFromInstanceProfile(context.Context, *iam.RemoveRoleFromInstanceProfileInput, ...func(*iam.Options)) (*iam.RemoveRoleFromInstanceProfileOutput, error) {
+	return &iam.RemoveRoleFromInstanceProfileOutput{}, nil
+}
+
+func (f *fakeIAM) DeleteInstanceProfile(_ context.Context, input *iam.DeleteInstanceProfileInput, _ ...func(*iam.Options)) (*iam.DeleteInstanceProfileOutput, error) {
+	delete(f.profiles, *input.InstanceProfileName)
+	return &iam.DeleteInstanceProfileOutput{}, nil
+}
+
+func TestPutPolicy(t *testing.T) {
+	profile := instanceprofiles.InstanceProfile{InstanceProfileName: "default-web-web-role", Roles: []instanceprofiles.Role{{RoleName: "web-role"}}}
+	iamAPI := &fakeIAM{profiles: map[string]*instanceprofiles.InstanceProfile{profile.InstanceProfileName: &profile}, policies: map[string]string{}}
+	watcher := instanceprofiles.NewWatcher(iamAPI)
+	if err := watcher.PutPolicy(context.Background(), profile, `{"Version":"2012-10-17"}`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if iamAPI.policies["web-role/default-web-web-role"] != `{"Version":"2012-10-17"}` {
+		t.Errorf("expected the role to have a policy named after the instance profile, got %v", iamAPI.policies)
+	}
+	if err := watcher.Delete(context.Background(), profile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(iamAPI.policies) != 0 || len(iamAPI.profiles) != 0 {
+		t.Errorf("expected the policy to be deleted with the instance profile, got %v", iamAPI.policies)
+	}
+	// roles of instance profiles that never had a policy are removed as well
+	if err := watcher.Delete(context.Background(), profile); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestEnsure(t *testing.T) {
+	role := instanceprofiles.Role{RoleName: "web-role"}
+	profileName := instanceprofiles.ProfileName("default", "web", role.RoleName)
+
+	type testCases struct {
+		name            string
+		profiles        map[string]*instanceprofiles.InstanceProfile
+		expectedCreated bool
+		expectedCreates int
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:            "creates the instance profile with the role",
+			profiles:        map[string]*instanceprofiles.InstanceProfile{},
+			expectedCreated: true,
+			expectedCreates: 1,
+		},
+		{
+			name: "reuses the instance profile with the role",
+			profiles: map[string]*instanceprofiles.InstanceProfile{
+				profileName: {InstanceProfileName: profileName, Roles: []instanceprofiles.Role{role}},
+			},
+			expectedCreated: false,
+		},
+		{
+			name: "adds the role to an instance profile without it",
+			profiles: map[string]*instanceprofiles.InstanceProfile{
+				profileName: {InstanceProfileName: profileName},
+			},
+			expectedCreated: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			iamAPI := &fakeIAM{profiles: tc.profiles}
+			profile, created, err := instanceprofiles.NewWatcher(iamAPI).Ensure(context.Background(), "default", "web", role)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if created != tc.expectedCreated {
+				t.Errorf("expected created to be %t, got %t", tc.expectedCreated, created)
+			}
+			if iamAPI.created != tc.expectedCreates {
+				t.Errorf("expected %d instance profiles to be created, got %d", tc.expectedCreates, iamAPI.created)
+			}
+			if len(profile.Roles) != 1 || profile.Roles[0].RoleName != role.RoleName {
+				t.Errorf("expected the instance profile to have role %s, got %v", role.RoleName, profile.Roles)
+			}
+		})
+	}
+}
+
+func (f *fakeIAM) GetRole(_ context.Context, input *iam.GetRoleInput, _ ...func(*iam.Options)) (*iam.GetRoleOutput, error) {
+	role, ok := f.roles[*input.RoleName]
+	if !ok {
+		return nil, errNoSuchEntity
+	}
+	return &iam.GetRoleOutput{Role: lo.ToPtr(sdkRole(*role))}, nil
+}
+
+func (f *fakeIAM) CreateRole(_ context.Context, input *iam.CreateRoleInput, _ ...func(*iam.Options)) (*iam.CreateRoleOutput, error) {
+	role := &instanceprofiles.Role{RoleName: *input.RoleName, Path: *input.Path, Arn: "arn:aws-cn:iam::123456789012:role" + *input.Path + *input.RoleName}
+	f.roles[*input.RoleName] = role
+	return &iam.CreateRoleOutput{Role: lo.ToPtr(sdkRole(*role))}, nil
+}
+
+func (f *fakeIAM) AttachRolePolicy(_ context.Context, input *iam.AttachRolePolicyInput, _ ...func(*iam.Options)) (*iam.AttachRolePolicyOutput, error) {
+	f.attached[*input.RoleName+"/"+*input.PolicyArn] = true
+	return &iam.AttachRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) DetachRolePolicy(_ context.Context, input *iam.DetachRolePolicyInput, _ ...func(*iam.Options)) (*iam.DetachRolePolicyOutput, error) {
+	delete(f.attached, *input.RoleName+"/"+*input.PolicyArn)
+	return &iam.DetachRolePolicyOutput{}, nil
+}
+
+func (f *fakeIAM) DeleteRole(_ context.Context, input *iam.DeleteRoleInput, _ ...func(*iam.Options)) (*iam.DeleteRoleOutput, error) {
+	delete(f.roles, *input.RoleName)
+	return &iam.DeleteRoleOutput{}, nil
+}
+
+func TestEnsureRole(t *testing.T) {
+	iamAPI := &fakeIAM{roles: map[string]*instanceprofiles.Role{}, attached: map[string]bool{}}
+	watcher := instanceprofiles.NewWatcher(iamAPI)
+	policies := []string{"AmazonSSMManagedInstanceCore"}
+	for _, expectedCreated := range []bool{true, false} {
+		role, created, err := watcher.EnsureRole(context.Background(), "nimbus-role", "", policies, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if created != expectedCreated || role.Path != "/nimbus/" {
+			t.Errorf("expected created to be %t with the nimbus path, got %t and %s", expectedCreated, created, role.Path)
+		}
+	}
+	if !iamAPI.attached["nimbus-role/arn:aws-cn:iam::aws:policy/AmazonSSMManagedInstanceCore"] {
+		t.Errorf("expected the managed policy of the role's partition to be attached, got %v", iamAPI.attached)
+	}
+	for _, expectedDeleted := range []bool{true, false} {
+		deleted, err := watcher.DeleteRole(context.Background(), "nimbus-role", policies)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if deleted != expectedDeleted {
+			t.Errorf("expected deleted to be %t, got %t", expectedDeleted, deleted)
+		}
+	}
+	if len(iamAPI.attached) != 0 {
+		t.Errorf("expected the managed policy to be detached, got %v", iamAPI.attached)
+	}
+}
@@ -2,18 +2,30 @@ package instances
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/pretty"
 	"github.com/bwagner5/nimbus/pkg/selectors"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
 
+const (
+	// SyncStatusSynced means the instance was launched with the latest plan generation of its name
+	SyncStatusSynced = "Synced"
+	// SyncStatusOutOfDate means a newer plan generation was launched for the instance's name
+	SyncStatusOutOfDate = "OutOfDate"
+	// SyncStatusUnknown means the instance was launched without a plan generation
+	SyncStatusUnknown = "Unknown"
+)
+
 // Watcher discovers instances based on selectors
 type Watcher struct {
 	instanceAPI SDKInstancesOps
@@ -23,7 +35,16 @@ type Watcher struct {
 // AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
 type SDKInstancesOps interface {
 	ec2.DescribeInstancesAPIClient
+	ec2.DescribeInstanceStatusAPIClient
 	TerminateInstances(context.Context, *ec2.TerminateInstancesInput, ...func(*ec2.Options)) (*ec2.TerminateInstancesOutput, error)
+	StopInstances(context.Context, *ec2.StopInstancesInput, ...func(*ec2.Options)) (*ec2.StopInstancesOutput, error)
+	StartInstances(context.Context, *ec2.StartInstancesInput, ...func(*ec2.Options)) (*ec2.StartInstancesOutput, error)
+	RebootInstances(context.Context, *ec2.RebootInstancesInput, ...func(*ec2.Options)) (*ec2.RebootInstancesOutput, error)
+	ModifyInstanceAttribute(context.Context, *ec2.ModifyInstanceAttributeInput, ...func(*ec2.Options)) (*ec2.ModifyInstanceAttributeOutput, error)
+	GetConsoleOutput(context.Context, *ec2.GetConsoleOutputInput, ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error)
+	GetConsoleScreenshot(context.Context, *ec2.GetConsoleScreenshotInput, ...func(*ec2.Options)) (*ec2.GetConsoleScreenshotOutput, error)
+	GetSerialConsoleAccessStatus(context.Context, *ec2.GetSerialConsoleAccessStatusInput, ...func(*ec2.Options)) (*ec2.GetSerialConsoleAccessStatusOutput, error)
+	EnableSerialConsoleAccess(context.Context, *ec2.EnableSerialConsoleAccessInput, ...func(*ec2.Options)) (*ec2.EnableSerialConsoleAccessOutput, error)
 }
 
 // Selector is a struct that represents an instance selector
@@ -31,7 +52,12 @@ type Selector struct {
 	Tags map[string]string
 	ID   string
 	// State is one of: pending | running | shutting-down | terminated | stopping | stopped
-	State string
+	State           string
+	VPCID           string
+	SubnetID        string
+	SecurityGroupID string
+	// Filters are raw EC2 DescribeInstances filters that are passed through and AND'd with the other terms
+	Filters []ec2types.Filter
 }
 
 // Instance represents an Amazon EC2 Instance
@@ -40,17 +66,28 @@ type Instance struct {
 	ec2types.Instance
 }
 
+// ConsoleOutput is the serial console output of an instance, e.g. the kernel and cloud-init messages of its boot
+type ConsoleOutput struct {
+	InstanceID string
+	// Timestamp is when the output was last updated, EC2 only updates it periodically
+	Timestamp time.Time
+	Output    string
+}
+
 // PrettyInstance represents an instance for UI elements like the static and TUI tables
 type PrettyInstance struct {
 	Name         string `table:"Name"`
-	Status       string `table:"Status"`
+	Status       string `table:"Status,status"`
 	IAMRole      string `table:"Role"`
-	Age          string `table:"Age"`
+	Age          string `table:"Age,right"`
 	Arch         string `table:"Arch"`
 	InstanceType string `table:"Instance-Type"`
 	Zone         string `table:"Zone"`
 	CapacityType string `table:"Capacity-Type"`
 	InstanceID   string `table:"ID"`
+	// Synced is Synced if the instance was launched with the latest plan generation of its name, otherwise OutOfDate or Unknown
+	Synced string `table:"Synced,status"`
+	PlanID string `table:"Plan-ID,wide"`
 }
 
 // ParseSelectors parses a string of selectors into a slice of Selector structs
@@ -68,6 +105,12 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 			switch k {
 			case "id":
 				instanceSelector.ID = v
+			case "vpc-id":
+				instanceSelector.VPCID = v
+			case "subnet-id":
+				instanceSelector.SubnetID = v
+			case "security-group-id":
+				instanceSelector.SecurityGroupID = v
 			default:
 				return nil, fmt.Errorf("invalid instance selector key: %s", k)
 			}
@@ -107,21 +150,196 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Instance,
 	return instances, nil
 }
 
-func (w Watcher) TerminateInstance(ctx context.Context, instanceID string) error {
-	_, err := w.instanceAPI.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: []string{instanceID}})
+// TerminateInstances terminates the instances in a single call, it does not wait for them to be terminated
+func (w Watcher) TerminateInstances(ctx context.Context, instanceIDs []string) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	if _, err := w.instanceAPI.TerminateInstances(ctx, &ec2.TerminateInstancesInput{InstanceIds: instanceIDs}); err != nil {
+		return fmt.Errorf("failed to terminate instances %s: %w", strings.Join(instanceIDs, ", "), err)
+	}
+	return nil
+}
+
+// ForceStopInstances force stops the instances in a single call, which skips flushing their file system caches and stops instances
+// that are stuck in stopping. It does not wait for them to be stopped.
+func (w Watcher) ForceStopInstances(ctx context.Context, instanceIDs []string) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	if _, err := w.instanceAPI.StopInstances(ctx, &ec2.StopInstancesInput{InstanceIds: instanceIDs, Force: aws.Bool(true)}); err != nil {
+		return fmt.Errorf("failed to force stop instances %s: %w", strings.Join(instanceIDs, ", "), err)
+	}
+	return nil
+}
+
+// SetDeleteOnTermination sets whether the EBS volume attached to the instance as the device is deleted when the instance is terminated
+func (w Watcher) SetDeleteOnTermination(ctx context.Context, instanceID, deviceName string, deleteOnTermination bool) error {
+	if _, err := w.instanceAPI.ModifyInstanceAttribute(ctx, &ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(instanceID),
+		BlockDeviceMappings: []ec2types.InstanceBlockDeviceMappingSpecification{{
+			DeviceName: aws.String(deviceName),
+			Ebs:        &ec2types.EbsInstanceBlockDeviceSpecification{DeleteOnTermination: aws.Bool(deleteOnTermination)},
+		}},
+	}); err != nil {
+		return fmt.Errorf("failed to set delete on termination of %s on instance %s: %w", deviceName, instanceID, err)
+	}
+	return nil
+}
+
+// ResolveStragglers returns the instances that are still shutting down or stopping, an instance that stays in either state is stuck
+func (w Watcher) ResolveStragglers(ctx context.Context, instanceIDs []string) ([]Instance, error) {
+	if len(instanceIDs) == 0 {
+		return nil, nil
+	}
+	return w.Resolve(ctx, []Selector{{Filters: []ec2types.Filter{
+		{Name: aws.String("instance-id"), Values: instanceIDs},
+		{Name: aws.String("instance-state-name"), Values: []string{string(ec2types.InstanceStateNameShuttingDown), string(ec2types.InstanceStateNameStopping)}},
+	}}})
+}
+
+// StopInstance stops the instance, hibernating it instead if hibernate is true.
+// Hibernation requires the instance to have been launched with hibernation enabled.
+func (w Watcher) StopInstance(ctx context.Context, instanceID string, hibernate bool) error {
+	if _, err := w.instanceAPI.StopInstances(ctx, &ec2.StopInstancesInput{
+		InstanceIds: []string{instanceID},
+		Hibernate:   aws.Bool(hibernate),
+	}); err != nil {
+		return fmt.Errorf("failed to stop instance %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+// StartInstance starts a stopped instance
+func (w Watcher) StartInstance(ctx context.Context, instanceID string) error {
+	if _, err := w.instanceAPI.StartInstances(ctx, &ec2.StartInstancesInput{InstanceIds: []string{instanceID}}); err != nil {
+		return fmt.Errorf("failed to start instance %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+// RebootInstance reboots a running instance, the instance keeps its ID, IP addresses, and instance store volumes
+func (w Watcher) RebootInstance(ctx context.Context, instanceID string) error {
+	if _, err := w.instanceAPI.RebootInstances(ctx, &ec2.RebootInstancesInput{InstanceIds: []string{instanceID}}); err != nil {
+		return fmt.Errorf("failed to reboot instance %s: %w", instanceID, err)
+	}
+	return nil
+}
+
+// ConsoleOutput returns the most recent console output of the instance, up to the last 64 KB.
+// The output is empty if the instance has not written any yet or EC2 has not captured it.
+func (w Watcher) ConsoleOutput(ctx context.Context, instanceID string) (ConsoleOutput, error) {
+	out, err := w.instanceAPI.GetConsoleOutput(ctx, &ec2.GetConsoleOutputInput{
+		InstanceId: aws.String(instanceID),
+		Latest:     aws.Bool(true),
+	})
 	if err != nil {
-		return err
-	}
-	// wait for instance to go into terminated
-	// this is required for other resources to delete cleanly
-	for range time.NewTicker(2 * time.Second).C {
-		termiantedInstances, err := w.Resolve(ctx, []Selector{{ID: instanceID, State: "terminated"}})
-		if err != nil {
-			return err
-		}
-		if len(termiantedInstances) > 0 {
-			break
-		}
+		return ConsoleOutput{}, fmt.Errorf("failed to get console output of instance %s: %w", instanceID, err)
+	}
+	output, err := base64.StdEncoding.DecodeString(lo.FromPtr(out.Output))
+	if err != nil {
+		return ConsoleOutput{}, fmt.Errorf("failed to decode console output of instance %s: %w", instanceID, err)
+	}
+	return ConsoleOutput{InstanceID: instanceID, Timestamp: lo.FromPtr(out.Timestamp), Output: string(output)}, nil
+}
+
+// Screenshot returns a JPG screenshot of the instance's console, which shows the state of instances without console output like Windows.
+// The instance is woken up first in case its display is asleep.
+func (w Watcher) Screenshot(ctx context.Context, instanceID string) ([]byte, error) {
+	out, err := w.instanceAPI.GetConsoleScreenshot(ctx, &ec2.GetConsoleScreenshotInput{
+		InstanceId: aws.String(instanceID),
+		WakeUp:     aws.Bool(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get console screenshot of instance %s: %w", instanceID, err)
+	}
+	screenshot, err := base64.StdEncoding.DecodeString(lo.FromPtr(out.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode console screenshot of instance %s: %w", instanceID, err)
+	}
+	return screenshot, nil
+}
+
+// SerialConsoleAccess returns true if the account allows connecting to the serial consoles of its instances in the region
+func (w Watcher) SerialConsoleAccess(ctx context.Context) (bool, error) {
+	out, err := w.instanceAPI.GetSerialConsoleAccessStatus(ctx, &ec2.GetSerialConsoleAccessStatusInput{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get serial console access status: %w", err)
+	}
+	return lo.FromPtr(out.SerialConsoleAccessEnabled), nil
+}
+
+// EnableSerialConsoleAccess allows the account to connect to the serial consoles of its instances in the region
+func (w Watcher) EnableSerialConsoleAccess(ctx context.Context) error {
+	if _, err := w.instanceAPI.EnableSerialConsoleAccess(ctx, &ec2.EnableSerialConsoleAccessInput{}); err != nil {
+		return fmt.Errorf("failed to enable serial console access: %w", err)
+	}
+	return nil
+}
+
+// consoleOutputAnchorSize is how much of the end of the previous console output is searched for in the current output
+const consoleOutputAnchorSize = 256
+
+// NewConsoleOutput returns the part of the current console output that follows the previous output.
+// The console output is a window of the latest output, so the previous output may have scrolled out of it. The end of the previous
+// output is found in the current output, and all of the current output is new if it is not found.
+func NewConsoleOutput(previous, current string) string {
+	if previous == "" {
+		return current
+	}
+	anchor := previous[max(len(previous)-consoleOutputAnchorSize, 0):]
+	index := strings.LastIndex(current, anchor)
+	if index == -1 {
+		return current
+	}
+	return current[index+len(anchor):]
+}
+
+// WaitForRunning waits until the instances are running
+func (w Watcher) WaitForRunning(ctx context.Context, instanceIDs []string, timeout time.Duration) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	waiter := ec2.NewInstanceRunningWaiter(w.instanceAPI)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: instanceIDs}, timeout); err != nil {
+		return fmt.Errorf("instances %s did not start running: %w", strings.Join(instanceIDs, ", "), err)
+	}
+	return nil
+}
+
+// WaitForStopped waits until the instances are stopped
+func (w Watcher) WaitForStopped(ctx context.Context, instanceIDs []string, timeout time.Duration) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	waiter := ec2.NewInstanceStoppedWaiter(w.instanceAPI)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: instanceIDs}, timeout); err != nil {
+		return fmt.Errorf("instances %s did not stop: %w", strings.Join(instanceIDs, ", "), err)
+	}
+	return nil
+}
+
+// WaitForTerminated waits until the instances are terminated
+// Terminated instances have released their network interfaces and attachments, which other resources need to delete cleanly
+func (w Watcher) WaitForTerminated(ctx context.Context, instanceIDs []string, timeout time.Duration) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	waiter := ec2.NewInstanceTerminatedWaiter(w.instanceAPI)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstancesInput{InstanceIds: instanceIDs}, timeout); err != nil {
+		return fmt.Errorf("instances %s did not terminate: %w", strings.Join(instanceIDs, ", "), err)
+	}
+	return nil
+}
+
+// WaitForStatusOK waits until the instances are running and have passed their EC2 instance and system status checks
+func (w Watcher) WaitForStatusOK(ctx context.Context, instanceIDs []string, timeout time.Duration) error {
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	waiter := ec2.NewInstanceStatusOkWaiter(w.instanceAPI)
+	if err := waiter.Wait(ctx, &ec2.DescribeInstanceStatusInput{InstanceIds: instanceIDs}, timeout); err != nil {
+		return fmt.Errorf("instances %s did not pass status checks: %w", strings.Join(instanceIDs, ", "), err)
 	}
 	return nil
 }
@@ -145,7 +363,26 @@ func filterSets(selectorList []Selector) [][]ec2types.Filter {
 				Values: []string{term.State},
 			})
 		}
+		if term.VPCID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("vpc-id"),
+				Values: []string{term.VPCID},
+			})
+		}
+		if term.SubnetID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("subnet-id"),
+				Values: []string{term.SubnetID},
+			})
+		}
+		if term.SecurityGroupID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("instance.group-id"),
+				Values: []string{term.SecurityGroupID},
+			})
+		}
 		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
+		filters = append(filters, term.Filters...)
 		filterResult = append(filterResult, filters)
 	}
 	return filterResult
@@ -160,12 +397,13 @@ func (i Instance) Prettify() PrettyInstance {
 		Name:         tagutils.EC2TagsToMap(i.Tags)["Name"],
 		Status:       string(i.State.Name),
 		IAMRole:      instanceProfileID,
-		Age:          time.Since(lo.FromPtr(i.LaunchTime)).Truncate(time.Second).String(),
+		Age:          pretty.Duration(time.Since(lo.FromPtr(i.LaunchTime))),
 		Arch:         string(i.Architecture),
 		InstanceType: string(i.InstanceType),
 		Zone:         lo.FromPtr(i.Placement.AvailabilityZone),
 		CapacityType: string(i.InstanceLifecycle),
 		InstanceID:   lo.FromPtr(i.InstanceId),
+		PlanID:       i.PlanID(),
 	}
 }
 
@@ -176,3 +414,60 @@ func (i Instance) Name() string {
 func (i Instance) Namespace() string {
 	return tagutils.EC2TagsToMap(i.Tags)[tagutils.NamespaceTagKey]
 }
+
+// Generation returns the plan generation that the instance was launched with or 0 if it was not recorded
+func (i Instance) Generation() int64 {
+	generation, _ := strconv.ParseInt(tagutils.EC2TagsToMap(i.Tags)[tagutils.GenerationTagKey], 10, 64)
+	return generation
+}
+
+// PlanID returns the ID of the executed launch plan that launched the instance or an empty string if it was not recorded
+func (i Instance) PlanID() string {
+	return tagutils.EC2TagsToMap(i.Tags)[tagutils.PlanIDTagKey]
+}
+
+// SpecChecksum returns the checksum of the plan spec that the instance was launched with
+func (i Instance) SpecChecksum() string {
+	return tagutils.EC2TagsToMap(i.Tags)[tagutils.SpecChecksumTagKey]
+}
+
+// ExpiresAt returns when the TTL of the instance expires and false if it was launched without a TTL
+func (i Instance) ExpiresAt() (time.Time, bool) {
+	expiresAt, err := time.Parse(time.RFC3339, tagutils.EC2TagsToMap(i.Tags)[tagutils.ExpiresAtTagKey])
+	return expiresAt, err == nil
+}
+
+// SyncStatuses returns whether each instance, by instance ID, was launched with the latest plan generation of its namespace/name.
+// Terminated instances are not considered when finding the latest generation.
+func SyncStatuses(instanceList []Instance) map[string]string {
+	latestGenerations := map[string]int64{}
+	for _, instance := range instanceList {
+		if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
+			continue
+		}
+		key := instance.Namespace() + "/" + instance.Name()
+		latestGenerations[key] = max(latestGenerations[key], instance.Generation())
+	}
+	statuses := make(map[string]string, len(instanceList))
+	for _, instance := range instanceList {
+		switch {
+		case instance.Generation() == 0:
+			statuses[lo.FromPtr(instance.InstanceId)] = SyncStatusUnknown
+		case instance.Generation() < latestGenerations[instance.Namespace()+"/"+instance.Name()]:
+			statuses[lo.FromPtr(instance.InstanceId)] = SyncStatusOutOfDate
+		default:
+			statuses[lo.FromPtr(instance.InstanceId)] = SyncStatusSynced
+		}
+	}
+	return statuses
+}
+
+// PrettifyAll prettifies the instances with their sync status
+func PrettifyAll(instanceList []Instance) []PrettyInstance {
+	statuses := SyncStatuses(instanceList)
+	return lo.Map(instanceList, func(instance Instance, _ int) PrettyInstance {
+		prettyInstance := instance.Prettify()
+		prettyInstance.Synced = statuses[lo.FromPtr(instance.InstanceId)]
+		return prettyInstance
+	})
+}
@@ -0,0 +1,105 @@
+package instances_test
+
+import (
+	"context"
+	"encoding/base64"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+)
+
+func TestSyncStatuses(t *testing.T) {
+	instance := func(id, name string, generation int64, state ec2types.InstanceStateName) instances.Instance {
+		tags := tagutils.NamespacedTags("dev", name)
+		if generation != 0 {
+			tags[tagutils.GenerationTagKey] = strconv.FormatInt(generation, 10)
+		}
+		return instances.Instance{Instance: ec2types.Instance{
+			InstanceId: aws.String(id),
+			State:      &ec2types.InstanceState{Name: state},
+			Tags:       tagutils.MapToEC2Tags(tags),
+		}}
+	}
+
+	statuses := instances.SyncStatuses([]instances.Instance{
+		instance("i-old", "web", 1, ec2types.InstanceStateNameRunning),
+		instance("i-new", "web", 2, ec2types.InstanceStateNameRunning),
+		instance("i-db", "db", 1, ec2types.InstanceStateNameRunning),
+		instance("i-terminated", "db", 2, ec2types.InstanceStateNameTerminated),
+		instance("i-legacy", "db", 0, ec2types.InstanceStateNameRunning),
+	})
+	expected := map[string]string{
+		"i-old":        instances.SyncStatusOutOfDate,
+		"i-new":        instances.SyncStatusSynced,
+		"i-db":         instances.SyncStatusSynced,
+		"i-terminated": instances.SyncStatusSynced,
+		"i-legacy":     instances.SyncStatusUnknown,
+	}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected %v, got %v", expected, statuses)
+	}
+}
+
+type fakeEC2 struct {
+	instances.SDKInstancesOps
+	output     string
+	screenshot string
+}
+
+func (f fakeEC2) GetConsoleOutput(_ context.Context, input *ec2.GetConsoleOutputInput, _ ...func(*ec2.Options)) (*ec2.GetConsoleOutputOutput, error) {
+	return &ec2.GetConsoleOutputOutput{InstanceId: input.InstanceId, Output: aws.String(base64.StdEncoding.EncodeToString([]byte(f.output)))}, nil
+}
+
+func (f fakeEC2) GetConsoleScreenshot(_ context.Context, input *ec2.GetConsoleScreenshotInput, _ ...func(*ec2.Options)) (*ec2.GetConsoleScreenshotOutput, error) {
+	return &ec2.GetConsoleScreenshotOutput{InstanceId: input.InstanceId, ImageData: aws.String(base64.StdEncoding.EncodeToString([]byte(f.screenshot)))}, nil
+}
+
+func TestConsoleOutput(t *testing.T) {
+	output, err := instances.NewWatcher(fakeEC2{output: "cloud-init finished\n"}).ConsoleOutput(context.Background(), "i-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if output.InstanceID != "i-1" || output.Output != "cloud-init finished\n" {
+		t.Errorf("expected the decoded output of i-1, got %+v", output)
+	}
+}
+
+func TestScreenshot(t *testing.T) {
+	screenshot, err := instances.NewWatcher(fakeEC2{screenshot: "jpg"}).Screenshot(context.Background(), "i-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(screenshot) != "jpg" {
+		t.Errorf("expected the decoded screenshot, got %q", screenshot)
+	}
+}
+
+func TestNewConsoleOutput(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	for _, tc := range []struct {
+		name     string
+		previous string
+		current  string
+		expected string
+	}{
+		{name: "first read", current: "boot\n", expected: "boot\n"},
+		{name: "unchanged", previous: "boot\n", current: "boot\n"},
+		{name: "appended", previous: "boot\n", current: "boot\nlogin\n", expected: "login\n"},
+		{name: "scrolled", previous: "kernel\n" + long + "boot\n", current: long + "boot\nlogin\n", expected: "login\n"},
+		{name: "scrolled past the end of the previous output", previous: long + "boot\n", current: "login\n", expected: "login\n"},
+		{name: "rebooted", previous: "boot\nlogin\n", current: "kernel\n", expected: "kernel\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if output := instances.NewConsoleOutput(tc.previous, tc.current); output != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, output)
+			}
+		})
+	}
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"regexp"
 	"strconv"
 	"strings"
+	"unicode"
 
 	"github.com/aws/amazon-ec2-instance-selector/v3/pkg/bytequantity"
 	"github.com/aws/amazon-ec2-instance-selector/v3/pkg/instancetypes"
@@ -53,6 +55,76 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]InstanceT
 	return lo.UniqBy(allInstanceTypes, func(instanceType InstanceType) string { return string(instanceType.InstanceType) }), nil
 }
 
+// OnDemandPrice returns the hourly on-demand price of the instance type in the watcher's region from the AWS Pricing API
+func (w Watcher) OnDemandPrice(ctx context.Context, instanceType string) (float64, error) {
+	price, err := w.instanceSelector.EC2Pricing.GetOnDemandInstanceTypeCost(ctx, ec2types.InstanceType(instanceType))
+	if err != nil {
+		return 0, fmt.Errorf("failed to get on-demand price of %s: %w", instanceType, err)
+	}
+	return price, nil
+}
+
+// Class returns the instance class, e.g. m for m7g.large or inf for inf2.xlarge
+func (i InstanceType) Class() string {
+	family := i.Family()
+	return strings.TrimSuffix(family, strings.TrimLeftFunc(family, unicode.IsLetter))
+}
+
+// Family returns the instance family, e.g. m7g for m7g.large
+func (i InstanceType) Family() string {
+	family, _, _ := strings.Cut(string(i.InstanceType), ".")
+	return family
+}
+
+// HasNVIDIAGPU is true if the instance type has NVIDIA GPUs
+func (i InstanceType) HasNVIDIAGPU() bool {
+	if i.GpuInfo == nil {
+		return false
+	}
+	return lo.SomeBy(i.GpuInfo.Gpus, func(gpu ec2types.GpuDeviceInfo) bool {
+		return strings.EqualFold(lo.FromPtr(gpu.Manufacturer), "NVIDIA")
+	})
+}
+
+// EquivalentSelector selects instance types of the architecture with the same vCPUs and between the same and twice the memory of the instance type
+func (i InstanceType) EquivalentSelector(arch ec2types.ArchitectureType) Selector {
+	vcpus := lo.FromPtr(i.VCpuInfo.DefaultVCpus)
+	memory := uint64(lo.FromPtr(i.MemoryInfo.SizeInMiB))
+	return Selector{Filters: selector.Filters{
+		CPUArchitecture: lo.ToPtr(arch),
+		VCpusRange:      &selector.Int32RangeFilter{LowerBound: vcpus, UpperBound: vcpus},
+		MemoryRange: &selector.ByteQuantityRangeFilter{
+			LowerBound: bytequantity.FromMiB(memory),
+			UpperBound: bytequantity.FromMiB(2 * memory),
+		},
+	}}
+}
+
+// BestEquivalent returns the candidate that is the closest replacement of the instance type.
+// Candidates of the same class are preferred, then those with the least memory, then the cheapest by the hourly prices.
+// Candidates without a known price are only chosen if no candidate of the same class and memory has one.
+func (i InstanceType) BestEquivalent(candidates []InstanceType, prices map[string]float64) (InstanceType, bool) {
+	if len(candidates) == 0 {
+		return InstanceType{}, false
+	}
+	sameClass := lo.Filter(candidates, func(candidate InstanceType, _ int) bool { return candidate.Class() == i.Class() })
+	if len(sameClass) != 0 {
+		candidates = sameClass
+	}
+	return lo.MinBy(candidates, func(a, b InstanceType) bool {
+		aMemory, bMemory := lo.FromPtr(a.MemoryInfo.SizeInMiB), lo.FromPtr(b.MemoryInfo.SizeInMiB)
+		if aMemory != bMemory {
+			return aMemory < bMemory
+		}
+		aPrice, aOK := prices[string(a.InstanceType)]
+		bPrice, bOK := prices[string(b.InstanceType)]
+		if aOK != bOK {
+			return aOK
+		}
+		return aPrice < bPrice
+	}), true
+}
+
 // ParseSelectors parses a string of selectors into a slice of Selector structs
 func ParseSelectors(selectorStr string) ([]Selector, error) {
 	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
@@ -149,6 +221,18 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 					LowerBound: lowerBound,
 					UpperBound: upperBound,
 				}
+			case "types":
+				allowList, err := globsToRegexp(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid types selector, %w", err)
+				}
+				instanceTypeSelector.AllowList = allowList
+			case "exclude":
+				denyList, err := globsToRegexp(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid exclude selector, %w", err)
+				}
+				instanceTypeSelector.DenyList = denyList
 			default:
 				return nil, fmt.Errorf("invalid instance type selector key: %s", k)
 			}
@@ -158,6 +242,26 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 	return instanceTypeSelectors, nil
 }
 
+// globsToRegexp converts a comma separated list of instance type names and globs into a regexp that matches any of them in full.
+// A * matches any characters and a ? matches a single character, e.g. "t2*,m5.large" matches t2.micro and m5.large but not m5.large2.
+func globsToRegexp(globsStr string) (*regexp.Regexp, error) {
+	var patterns []string
+	for _, glob := range strings.Split(globsStr, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == "" {
+			continue
+		}
+		pattern := regexp.QuoteMeta(glob)
+		pattern = strings.ReplaceAll(pattern, `\*`, ".*")
+		pattern = strings.ReplaceAll(pattern, `\?`, ".")
+		patterns = append(patterns, pattern)
+	}
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no instance types")
+	}
+	return regexp.Compile(fmt.Sprintf("^(?:%s)$", strings.Join(patterns, "|")))
+}
+
 // parseStringRange parses selector ranges into string tokens
 //
 // Selector ranges can be in the following forms:
@@ -0,0 +1,123 @@
+package instancetypes_test
+
+import (
+	"testing"
+
+	"github.com/aws/amazon-ec2-instance-selector/v3/pkg/instancetypes"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	nimbusinstancetypes "github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+)
+
+func instanceType(name string, memoryMiB int64) nimbusinstancetypes.InstanceType {
+	return nimbusinstancetypes.InstanceType{Details: instancetypes.Details{InstanceTypeInfo: ec2types.InstanceTypeInfo{
+		InstanceType: ec2types.InstanceType(name),
+		MemoryInfo:   &ec2types.MemoryInfo{SizeInMiB: aws.Int64(memoryMiB)},
+	}}}
+}
+
+func TestClass(t *testing.T) {
+	for name, expected := range map[string]string{
+		"m7g.large":    "m",
+		"c6gn.xlarge":  "c",
+		"inf2.xlarge":  "inf",
+		"u-6tb1.metal": "u",
+	} {
+		if class := instanceType(name, 0).Class(); class != expected {
+			t.Errorf("expected class %q of %s, got %q", expected, name, class)
+		}
+	}
+}
+
+func TestHasNVIDIAGPU(t *testing.T) {
+	withGPU := func(name, manufacturer string) nimbusinstancetypes.InstanceType {
+		it := instanceType(name, 0)
+		it.GpuInfo = &ec2types.GpuInfo{Gpus: []ec2types.GpuDeviceInfo{{Manufacturer: aws.String(manufacturer)}}}
+		return it
+	}
+	for _, tc := range []struct {
+		instanceType nimbusinstancetypes.InstanceType
+		expected     bool
+	}{
+		{instanceType: withGPU("g5.xlarge", "NVIDIA"), expected: true},
+		{instanceType: withGPU("g4ad.xlarge", "AMD")},
+		{instanceType: instanceType("m7g.large", 0)},
+	} {
+		if got := tc.instanceType.HasNVIDIAGPU(); got != tc.expected {
+			t.Errorf("expected %s to have an NVIDIA GPU %t, got %t", tc.instanceType.InstanceType, tc.expected, got)
+		}
+	}
+}
+
+func TestBestEquivalent(t *testing.T) {
+	type testCases struct {
+		name       string
+		candidates []nimbusinstancetypes.InstanceType
+		prices     map[string]float64
+		expected   string
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:       "no candidates",
+			candidates: nil,
+		},
+		{
+			name:       "prefers the same class",
+			candidates: []nimbusinstancetypes.InstanceType{instanceType("r7g.large", 8192), instanceType("m7g.large", 8192)},
+			prices:     map[string]float64{"r7g.large": 0.01, "m7g.large": 0.0816},
+			expected:   "m7g.large",
+		},
+		{
+			name:       "prefers the least memory",
+			candidates: []nimbusinstancetypes.InstanceType{instanceType("m7g.xlarge", 16384), instanceType("m6g.large", 8192)},
+			prices:     map[string]float64{"m7g.xlarge": 0.01, "m6g.large": 0.077},
+			expected:   "m6g.large",
+		},
+		{
+			name:       "prefers the cheapest",
+			candidates: []nimbusinstancetypes.InstanceType{instanceType("m7g.large", 8192), instanceType("m6g.large", 8192)},
+			prices:     map[string]float64{"m7g.large": 0.0816, "m6g.large": 0.077},
+			expected:   "m6g.large",
+		},
+		{
+			name:       "prefers a known price",
+			candidates: []nimbusinstancetypes.InstanceType{instanceType("m6g.large", 8192), instanceType("m7g.large", 8192)},
+			prices:     map[string]float64{"m7g.large": 0.0816},
+			expected:   "m7g.large",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			best, ok := instanceType("m5.large", 8192).BestEquivalent(tc.candidates, tc.prices)
+			if ok != (tc.expected != "") || string(best.InstanceType) != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, best.InstanceType)
+			}
+		})
+	}
+}
+
+func TestParseSelectorsNames(t *testing.T) {
+	selectors, err := nimbusinstancetypes.ParseSelectors("vcpus:2,exclude:t2*,t3*;types:m5.large,c5.?large")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(selectors) != 2 {
+		t.Fatalf("expected 2 selectors, got %d", len(selectors))
+	}
+	if selectors[0].DenyList == nil || selectors[0].VCpusRange == nil {
+		t.Fatalf("expected the first selector to have vcpus and an exclude list")
+	}
+	for name, excluded := range map[string]bool{"t2.micro": true, "t3a.large": true, "m5.large": false, "at2.large": false} {
+		if selectors[0].DenyList.MatchString(name) != excluded {
+			t.Errorf("expected %s to be excluded %t", name, excluded)
+		}
+	}
+	for name, allowed := range map[string]bool{"m5.large": true, "c5.xlarge": true, "m5.large2": false, "c5.large": false, "m5a.large": false} {
+		if selectors[1].AllowList.MatchString(name) != allowed {
+			t.Errorf("expected %s to be allowed %t", name, allowed)
+		}
+	}
+	if _, err := nimbusinstancetypes.ParseSelectors("exclude:"); err == nil {
+		t.Error("expected an error for an empty exclude list")
+	}
+}
@@ -0,0 +1,278 @@
+package keypairs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/retry"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// publicKeyTypes are the OpenSSH public key formats that EC2 can import
+var publicKeyTypes = []string{"ssh-rsa", "ssh-ed25519", "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "ecdsa-sha2-nistp521"}
+
+// Watcher discovers key pairs based on selectors
+type Watcher struct {
+	keyPairAPI SDKKeyPairOps
+}
+
+// SDKKeyPairOps is an interface that combines the necessary EC2 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKKeyPairOps interface {
+	DescribeKeyPairs(context.Context, *ec2.DescribeKeyPairsInput, ...func(*ec2.Options)) (*ec2.DescribeKeyPairsOutput, error)
+	CreateKeyPair(context.Context, *ec2.CreateKeyPairInput, ...func(*ec2.Options)) (*ec2.CreateKeyPairOutput, error)
+	ImportKeyPair(context.Context, *ec2.ImportKeyPairInput, ...func(*ec2.Options)) (*ec2.ImportKeyPairOutput, error)
+	DeleteKeyPair(context.Context, *ec2.DeleteKeyPairInput, ...func(*ec2.Options)) (*ec2.DeleteKeyPairOutput, error)
+}
+
+// Selector is a struct that represents a key pair selector
+type Selector struct {
+	Tags map[string]string
+	Name string
+	ID   string
+}
+
+// CreateKeyPairOptions are the parameters used to create or import a nimbus key pair
+type CreateKeyPairOptions struct {
+	// Name is the AWS name of the key pair, which instances are launched with
+	Name      string
+	Namespace string
+	// KeyType is rsa or ed25519 for created key pairs, defaults to ed25519. Imported key pairs take the type of the public key.
+	KeyType string
+}
+
+// KeyPair represents an Amazon EC2 key pair
+// This is not the AWS SDK KeyPairInfo type, but a wrapper around it so that we can add additional data
+type KeyPair struct {
+	ec2types.KeyPairInfo
+	// PrivateKey is the unencrypted PEM encoded private key of a created key pair.
+	// EC2 only returns it when the key pair is created, so it is empty for resolved and imported key pairs.
+	PrivateKey string `json:"-" yaml:"-"`
+}
+
+// PrettyKeyPair represents a key pair for UI elements like the static and TUI tables
+type PrettyKeyPair struct {
+	Name        string `table:"Name"`
+	ID          string `table:"ID"`
+	Type        string `table:"Type"`
+	Namespace   string `table:"Namespace"`
+	Fingerprint string `table:"Fingerprint,wide"`
+	Created     string `table:"Created,wide"`
+}
+
+// ParseSelectors parses a string of selectors into a slice of Selector structs
+func ParseSelectors(selectorStr string) ([]Selector, error) {
+	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key pair selectors: %w", err)
+	}
+	keyPairSelectors := make([]Selector, 0, len(selectors))
+	for _, selector := range selectors {
+		keyPairSelector := Selector{
+			Tags: selector.Tags,
+		}
+		for k, v := range selector.KeyVals {
+			switch k {
+			case "id":
+				keyPairSelector.ID = v
+			case "name":
+				keyPairSelector.Name = v
+			default:
+				return nil, fmt.Errorf("invalid key pair selector key: %s", k)
+			}
+		}
+		keyPairSelectors = append(keyPairSelectors, keyPairSelector)
+	}
+	return keyPairSelectors, nil
+}
+
+// NewWatcher creates a new KeyPair Watcher
+func NewWatcher(keyPairAPI SDKKeyPairOps) Watcher {
+	return Watcher{
+		keyPairAPI: keyPairAPI,
+	}
+}
+
+// Resolve returns a list of key pairs that match the provided selectors
+// Multiple calls to EC2 may be sent to resolve the selectors
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]KeyPair, error) {
+	var keyPairs []KeyPair
+	for _, filters := range filterSets(selectors) {
+		// DescribeKeyPairs is not paginated
+		out, err := w.keyPairAPI.DescribeKeyPairs(ctx, &ec2.DescribeKeyPairsInput{
+			Filters: filters,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe key pairs: %w", err)
+		}
+		keyPairs = append(keyPairs, lo.Map(out.KeyPairs, func(sdkKeyPair ec2types.KeyPairInfo, _ int) KeyPair {
+			return KeyPair{KeyPairInfo: sdkKeyPair}
+		})...)
+	}
+	return lo.UniqBy(keyPairs, func(keyPair KeyPair) string { return lo.FromPtr(keyPair.KeyPairId) }), nil
+}
+
+// Create creates a key pair and returns it with its private key, which EC2 does not return again
+func (w Watcher) Create(ctx context.Context, createOpts CreateKeyPairOptions) (KeyPair, error) {
+	keyType := lo.CoalesceOrEmpty(createOpts.KeyType, string(ec2types.KeyTypeEd25519))
+	if !lo.Contains(ec2types.KeyTypeEd25519.Values(), ec2types.KeyType(keyType)) {
+		return KeyPair{}, fmt.Errorf("invalid key type %q, must be one of %v", keyType, ec2types.KeyTypeEd25519.Values())
+	}
+	out, err := w.keyPairAPI.CreateKeyPair(ctx, &ec2.CreateKeyPairInput{
+		KeyName:           aws.String(createOpts.Name),
+		KeyType:           ec2types.KeyType(keyType),
+		KeyFormat:         ec2types.KeyFormatPem,
+		TagSpecifications: tagSpecifications(createOpts.Namespace),
+	})
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("failed to create key pair %s: %w", createOpts.Name, err)
+	}
+	return KeyPair{
+		KeyPairInfo: ec2types.KeyPairInfo{
+			KeyName:        out.KeyName,
+			KeyPairId:      out.KeyPairId,
+			KeyFingerprint: out.KeyFingerprint,
+			KeyType:        ec2types.KeyType(keyType),
+			Tags:           out.Tags,
+		},
+		PrivateKey: lo.FromPtr(out.KeyMaterial),
+	}, nil
+}
+
+// Import imports an OpenSSH public key as a key pair
+func (w Watcher) Import(ctx context.Context, createOpts CreateKeyPairOptions, publicKey []byte) (KeyPair, error) {
+	if err := ValidatePublicKey(publicKey); err != nil {
+		return KeyPair{}, err
+	}
+	out, err := w.keyPairAPI.ImportKeyPair(ctx, &ec2.ImportKeyPairInput{
+		KeyName:           aws.String(createOpts.Name),
+		PublicKeyMaterial: publicKey,
+		TagSpecifications: tagSpecifications(createOpts.Namespace),
+	})
+	if err != nil {
+		return KeyPair{}, fmt.Errorf("failed to import key pair %s: %w", createOpts.Name, err)
+	}
+	return KeyPair{KeyPairInfo: ec2types.KeyPairInfo{
+		KeyName:        out.KeyName,
+		KeyPairId:      out.KeyPairId,
+		KeyFingerprint: out.KeyFingerprint,
+		Tags:           out.Tags,
+	}}, nil
+}
+
+// ImportFile imports a local OpenSSH public key file like ~/.ssh/id_ed25519.pub as a key pair
+func (w Watcher) ImportFile(ctx context.Context, createOpts CreateKeyPairOptions, publicKeyPath string) (KeyPair, error) {
+	publicKey, err := ReadPublicKey(publicKeyPath)
+	if err != nil {
+		return KeyPair{}, err
+	}
+	return w.Import(ctx, createOpts, publicKey)
+}
+
+// Delete deletes the key pair, instances that were launched with it keep the public key in their authorized keys
+func (w Watcher) Delete(ctx context.Context, keyPairID string) error {
+	if err := retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.keyPairAPI.DeleteKeyPair(ctx, &ec2.DeleteKeyPairInput{KeyPairId: aws.String(keyPairID)})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to delete key pair %s: %w", keyPairID, err)
+	}
+	return nil
+}
+
+// ReadPublicKey reads an OpenSSH public key file, a leading ~ is expanded to the home directory.
+// Private keys are refused so they are never sent to AWS by mistake.
+func ReadPublicKey(publicKeyPath string) ([]byte, error) {
+	if rest, ok := strings.CutPrefix(publicKeyPath, "~"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("unable to expand %s: %w", publicKeyPath, err)
+		}
+		publicKeyPath = filepath.Join(home, rest)
+	}
+	publicKey, err := os.ReadFile(publicKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read public key: %w", err)
+	}
+	if err := ValidatePublicKey(publicKey); err != nil {
+		return nil, fmt.Errorf("%s: %w", publicKeyPath, err)
+	}
+	return publicKey, nil
+}
+
+// ValidatePublicKey checks that the key is a single line OpenSSH public key of a type that EC2 supports
+func ValidatePublicKey(publicKey []byte) error {
+	key := strings.TrimSpace(string(publicKey))
+	if strings.Contains(key, "PRIVATE KEY") {
+		return fmt.Errorf("expected a public key, but found a private key")
+	}
+	fields := strings.Fields(key)
+	if len(fields) < 2 || strings.Contains(key, "\n") {
+		return fmt.Errorf("expected a single line OpenSSH public key, e.g. ssh-ed25519 AAAA... user@host")
+	}
+	if !lo.Contains(publicKeyTypes, fields[0]) {
+		return fmt.Errorf("unsupported public key type %s, must be one of %v", fields[0], publicKeyTypes)
+	}
+	return nil
+}
+
+// Prettify converts the key pair into a PrettyKeyPair
+func (k KeyPair) Prettify() PrettyKeyPair {
+	tags := tagutils.EC2TagsToMap(k.Tags)
+	return PrettyKeyPair{
+		Name:        lo.FromPtr(k.KeyName),
+		ID:          lo.FromPtr(k.KeyPairId),
+		Type:        string(k.KeyType),
+		Namespace:   tags[tagutils.NamespaceTagKey],
+		Fingerprint: lo.FromPtr(k.KeyFingerprint),
+		Created:     lo.Ternary(k.CreateTime == nil, "", lo.FromPtr(k.CreateTime).String()),
+	}
+}
+
+// PrettifyAll converts key pairs into PrettyKeyPairs
+func PrettifyAll(keyPairs []KeyPair) []PrettyKeyPair {
+	return lo.Map(keyPairs, func(keyPair KeyPair, _ int) PrettyKeyPair { return keyPair.Prettify() })
+}
+
+// tagSpecifications tag a key pair as created by nimbus in the namespace.
+// Key pairs are not tagged with a name since they are shared by the names of the namespace and outlive their instances.
+func tagSpecifications(namespace string) []ec2types.TagSpecification {
+	return []ec2types.TagSpecification{{
+		ResourceType: ec2types.ResourceTypeKeyPair,
+		Tags:         tagutils.EC2NamespacedTags(namespace, ""),
+	}}
+}
+
+// filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
+// Each filter is executed as a separate list call.
+// Terms within a Selector are AND'd and between Selectors are OR'd
+func filterSets(selectorList []Selector) [][]ec2types.Filter {
+	var filterResult [][]ec2types.Filter
+	for _, term := range selectorList {
+		filters := []ec2types.Filter{}
+		if term.ID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("key-pair-id"),
+				Values: []string{term.ID},
+			})
+		}
+		if term.Name != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("key-name"),
+				Values: []string{term.Name},
+			})
+		}
+		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
+		filterResult = append(filterResult, filters)
+	}
+	return filterResult
+}
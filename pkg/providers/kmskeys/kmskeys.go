@@ -0,0 +1,80 @@
+package kmskeys
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/samber/lo"
+)
+
+// Watcher discovers KMS keys based on selectors
+type Watcher struct {
+	kmsAPI SDKKMSOps
+}
+
+// SDKKMSOps is an interface that combines the necessary KMS SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKKMSOps interface {
+	DescribeKey(context.Context, *kms.DescribeKeyInput, ...func(*kms.Options)) (*kms.DescribeKeyOutput, error)
+}
+
+// Selector is a struct that represents a KMS key selector
+type Selector struct {
+	// KeyID is a key ID, key ARN, alias name prefixed with alias/, or alias ARN
+	KeyID string
+}
+
+// Key represents an AWS KMS key
+// This is not the AWS SDK KeyMetadata type, but a wrapper around it so that we can add additional methods
+type Key struct {
+	kmstypes.KeyMetadata
+}
+
+// NewWatcher creates a new KMS key Watcher
+func NewWatcher(kmsAPI SDKKMSOps) Watcher {
+	return Watcher{
+		kmsAPI: kmsAPI,
+	}
+}
+
+// Resolve returns the keys that the selectors refer to
+// KMS does not support filters, so every selector is a DescribeKey call
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Key, error) {
+	var keys []Key
+	for _, selector := range selectors {
+		keyID := strings.TrimSpace(selector.KeyID)
+		if keyID == "" {
+			continue
+		}
+		out, err := w.kmsAPI.DescribeKey(ctx, &kms.DescribeKeyInput{KeyId: aws.String(keyID)})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe kms key %s: %w", keyID, err)
+		}
+		keys = append(keys, Key{KeyMetadata: lo.FromPtr(out.KeyMetadata)})
+	}
+	return keys, nil
+}
+
+// ValidateForEBS returns an error if EBS in the region cannot encrypt volumes with the key.
+// EBS only supports enabled symmetric encryption keys in the same region as the volume.
+func (k Key) ValidateForEBS(region string) error {
+	keyARN, err := arn.Parse(lo.FromPtr(k.Arn))
+	if err != nil {
+		return fmt.Errorf("kms key %s has an invalid ARN: %w", lo.FromPtr(k.KeyId), err)
+	}
+	if keyARN.Region != region {
+		return fmt.Errorf("kms key %s is in %s, but volumes are created in %s", lo.FromPtr(k.Arn), keyARN.Region, region)
+	}
+	if k.KeyState != kmstypes.KeyStateEnabled {
+		return fmt.Errorf("kms key %s is %s, it must be Enabled", lo.FromPtr(k.Arn), k.KeyState)
+	}
+	if k.KeyUsage != kmstypes.KeyUsageTypeEncryptDecrypt || k.KeySpec != kmstypes.KeySpecSymmetricDefault {
+		return fmt.Errorf("kms key %s is a %s %s key, EBS requires a %s %s key", lo.FromPtr(k.Arn), k.KeySpec, k.KeyUsage, kmstypes.KeySpecSymmetricDefault, kmstypes.KeyUsageTypeEncryptDecrypt)
+	}
+	return nil
+}
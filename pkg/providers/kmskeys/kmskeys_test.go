@@ -0,0 +1,48 @@
+package kmskeys_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	kmstypes "github.com/aws/aws-sdk-go-v2/service/kms/types"
+	"github.com/bwagner5/nimbus/pkg/providers/kmskeys"
+)
+
+func TestValidateForEBS(t *testing.T) {
+	type testCases struct {
+		name        string
+		key         kmstypes.KeyMetadata
+		expectedErr bool
+	}
+
+	usable := kmstypes.KeyMetadata{
+		KeyId:    aws.String("1234abcd-12ab-34cd-56ef-1234567890ab"),
+		Arn:      aws.String("arn:aws:kms:us-west-2:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab"),
+		KeyState: kmstypes.KeyStateEnabled,
+		KeyUsage: kmstypes.KeyUsageTypeEncryptDecrypt,
+		KeySpec:  kmstypes.KeySpecSymmetricDefault,
+	}
+	otherRegion := usable
+	otherRegion.Arn = aws.String("arn:aws:kms:us-east-1:111122223333:key/1234abcd-12ab-34cd-56ef-1234567890ab")
+	disabled := usable
+	disabled.KeyState = kmstypes.KeyStateDisabled
+	asymmetric := usable
+	asymmetric.KeySpec = kmstypes.KeySpecRsa2048
+
+	for _, tc := range []testCases{
+		{name: "usable", key: usable},
+		{name: "other region", key: otherRegion, expectedErr: true},
+		{name: "disabled", key: disabled, expectedErr: true},
+		{name: "asymmetric", key: asymmetric, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := kmskeys.Key{KeyMetadata: tc.key}.ValidateForEBS("us-west-2")
+			if tc.expectedErr && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
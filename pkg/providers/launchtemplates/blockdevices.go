@@ -0,0 +1,226 @@
+package launchtemplates
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/samber/lo"
+)
+
+const (
+	// DefaultVolumeType is used for every nimbus volume that does not specify a type.
+	// gp3 has a baseline of 3000 IOPS and 125 MiB/s regardless of size and is cheaper per GiB than gp2.
+	DefaultVolumeType = ec2types.VolumeTypeGp3
+
+	gp3MinIOPS       = 3000
+	gp3MaxIOPS       = 16000
+	gp3MaxIOPSPerGiB = 500
+	// gp3 throughput is in MiB/s
+	gp3MinThroughput        = 125
+	gp3MaxThroughput        = 1000
+	gp3MaxThroughputPerIOPS = 0.25
+
+	// RootDevice is the device name of a block device mapping that configures the AMI's root volume, whatever its device name is
+	RootDevice = "root"
+)
+
+// BlockDevice is an EBS volume that is attached to the instances launched from a launch template
+type BlockDevice struct {
+	// DeviceName is the device the volume is attached as, e.g. /dev/xvda
+	DeviceName string
+	// VolumeType is the EBS volume type, defaults to gp3
+	VolumeType string
+	// VolumeSize is the size of the volume in GiB, the root volume defaults to the size of the AMI's snapshot
+	VolumeSize int32
+	// IOPS is the provisioned IOPS of gp3, io1, and io2 volumes. gp3 defaults to 3000.
+	IOPS int32
+	// Throughput is the provisioned throughput of gp3 volumes in MiB/s, defaults to 125
+	Throughput int32
+	// Encrypted defaults to true, volumes are encrypted with the account's default EBS key if KMSKeyID is empty
+	Encrypted *bool
+	// KMSKeyID is the KMS key that encrypts the volume
+	KMSKeyID string
+}
+
+// ParseBlockDevices parses block device mappings separated by semicolons. Sizes are a number of GiB or a byte size like 100GiB or 1TiB,
+// which is rounded up to whole GiB.
+//
+// Example:
+//
+//	"device:root,size:50GiB;device:/dev/sdf,size:500GiB,type:gp3,iops:6000,throughput:500,kms-key:alias/data"
+//
+// resizes the root volume to 50 GiB and attaches a 500 GiB data volume as /dev/sdf
+func ParseBlockDevices(blockDeviceStr string) ([]BlockDevice, error) {
+	terms, err := selectors.ParseSelectorsTokens(blockDeviceStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse block device mappings: %w", err)
+	}
+	blockDevices := make([]BlockDevice, 0, len(terms))
+	for _, term := range terms {
+		if len(term.Tags) != 0 {
+			return nil, fmt.Errorf("invalid block device mapping: tags are not supported")
+		}
+		blockDevice := BlockDevice{}
+		for k, v := range term.KeyVals {
+			switch k {
+			case "device", "device-name":
+				blockDevice.DeviceName = v
+			case "size":
+				blockDevice.VolumeSize, err = parseVolumeSize(v)
+			case "type":
+				blockDevice.VolumeType = v
+			case "iops":
+				blockDevice.IOPS, err = parseInt32(v)
+			case "throughput":
+				blockDevice.Throughput, err = parseInt32(v)
+			case "encrypted":
+				var encrypted bool
+				encrypted, err = strconv.ParseBool(v)
+				blockDevice.Encrypted = aws.Bool(encrypted)
+			case "kms-key":
+				blockDevice.KMSKeyID = v
+			default:
+				return nil, fmt.Errorf("invalid block device mapping key: %s", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid block device mapping %s: %w", k, err)
+			}
+		}
+		blockDevices = append(blockDevices, blockDevice)
+	}
+	return blockDevices, nil
+}
+
+// ValidateBlockDevices checks every block device and that each device name is mapped once.
+// Volumes other than the root volume are created empty, so they must have a size.
+func ValidateBlockDevices(blockDevices []BlockDevice) error {
+	deviceNames := map[string]bool{}
+	for _, blockDevice := range blockDevices {
+		if blockDevice.DeviceName == "" {
+			return fmt.Errorf("block device mappings must have a device name, e.g. device:/dev/sdf or device:%s", RootDevice)
+		}
+		if deviceNames[blockDevice.DeviceName] {
+			return fmt.Errorf("device %s is mapped more than once", blockDevice.DeviceName)
+		}
+		deviceNames[blockDevice.DeviceName] = true
+		if blockDevice.DeviceName != RootDevice && blockDevice.VolumeSize == 0 {
+			return fmt.Errorf("device %s must have a size", blockDevice.DeviceName)
+		}
+		if err := blockDevice.Validate(); err != nil {
+			return fmt.Errorf("device %s: %w", blockDevice.DeviceName, err)
+		}
+	}
+	return nil
+}
+
+// parseVolumeSize parses a number of GiB or a byte size, rounded up to whole GiB since EBS volumes are sized in GiB
+func parseVolumeSize(size string) (int32, error) {
+	if gib, err := strconv.ParseInt(size, 10, 32); err == nil {
+		return int32(gib), nil
+	}
+	byteSize, err := bytesize.Parse(size)
+	if err != nil {
+		return 0, err
+	}
+	gib := math.Ceil(byteSize.Gibibytes())
+	if gib > math.MaxInt32 {
+		return 0, fmt.Errorf("%s is too large", size)
+	}
+	return int32(gib), nil
+}
+
+func parseInt32(value string) (int32, error) {
+	n, err := strconv.ParseInt(value, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("expected a number, got %q", value)
+	}
+	return int32(n), nil
+}
+
+// IsEncrypted returns true unless encryption was explicitly disabled
+func (b BlockDevice) IsEncrypted() bool {
+	return b.Encrypted == nil || *b.Encrypted
+}
+
+// Validate returns an error if the volume's IOPS and throughput are not supported by its volume type
+func (b BlockDevice) Validate() error {
+	volumeType := ec2types.VolumeType(lo.CoalesceOrEmpty(b.VolumeType, string(DefaultVolumeType)))
+	if !lo.Contains(volumeType.Values(), volumeType) {
+		return fmt.Errorf("invalid volume type %q, must be one of %v", b.VolumeType, volumeType.Values())
+	}
+	if b.VolumeSize < 0 || b.IOPS < 0 || b.Throughput < 0 {
+		return fmt.Errorf("volume size, IOPS, and throughput must not be negative")
+	}
+	if b.KMSKeyID != "" && !b.IsEncrypted() {
+		return fmt.Errorf("a kms key cannot be specified for an unencrypted volume")
+	}
+	if b.Throughput != 0 && volumeType != ec2types.VolumeTypeGp3 {
+		return fmt.Errorf("throughput can only be specified for gp3 volumes, not %s", volumeType)
+	}
+	switch volumeType {
+	case ec2types.VolumeTypeGp3:
+		iops := lo.Ternary(b.IOPS == 0, int32(gp3MinIOPS), b.IOPS)
+		throughput := lo.Ternary(b.Throughput == 0, int32(gp3MinThroughput), b.Throughput)
+		if iops < gp3MinIOPS || iops > gp3MaxIOPS {
+			return fmt.Errorf("gp3 IOPS must be between %d and %d, got %d", gp3MinIOPS, gp3MaxIOPS, iops)
+		}
+		if throughput < gp3MinThroughput || throughput > gp3MaxThroughput {
+			return fmt.Errorf("gp3 throughput must be between %d and %d MiB/s, got %d", gp3MinThroughput, gp3MaxThroughput, throughput)
+		}
+		if float64(throughput) > float64(iops)*gp3MaxThroughputPerIOPS {
+			return fmt.Errorf("gp3 throughput of %d MiB/s requires at least %d IOPS, got %d", throughput, int32(float64(throughput)/gp3MaxThroughputPerIOPS), iops)
+		}
+		if b.VolumeSize != 0 && iops > b.VolumeSize*gp3MaxIOPSPerGiB {
+			return fmt.Errorf("gp3 IOPS of %d requires a volume of at least %d GiB, got %d GiB", iops, (iops+gp3MaxIOPSPerGiB-1)/gp3MaxIOPSPerGiB, b.VolumeSize)
+		}
+	case ec2types.VolumeTypeIo1, ec2types.VolumeTypeIo2:
+		if b.IOPS == 0 {
+			return fmt.Errorf("IOPS must be specified for %s volumes", volumeType)
+		}
+	default:
+		if b.IOPS != 0 {
+			return fmt.Errorf("IOPS can only be specified for gp3, io1, and io2 volumes, not %s", volumeType)
+		}
+	}
+	return nil
+}
+
+// blockDeviceMapping converts the block device to a launch template block device mapping, unset values are left to EC2's defaults
+func (b BlockDevice) blockDeviceMapping() ec2types.LaunchTemplateBlockDeviceMappingRequest {
+	return ec2types.LaunchTemplateBlockDeviceMappingRequest{
+		DeviceName: aws.String(b.DeviceName),
+		Ebs: &ec2types.LaunchTemplateEbsBlockDeviceRequest{
+			VolumeType:          ec2types.VolumeType(lo.CoalesceOrEmpty(b.VolumeType, string(DefaultVolumeType))),
+			VolumeSize:          lo.Ternary(b.VolumeSize == 0, nil, aws.Int32(b.VolumeSize)),
+			Iops:                lo.Ternary(b.IOPS == 0, nil, aws.Int32(b.IOPS)),
+			Throughput:          lo.Ternary(b.Throughput == 0, nil, aws.Int32(b.Throughput)),
+			DeleteOnTermination: aws.Bool(true),
+			Encrypted:           aws.Bool(b.IsEncrypted()),
+			KmsKeyId:            lo.Ternary(b.KMSKeyID == "", nil, aws.String(b.KMSKeyID)),
+		},
+	}
+}
+
+// blockDeviceFromMapping returns the block device of a launch template's mapping, the inverse of blockDeviceMapping
+func blockDeviceFromMapping(mapping ec2types.LaunchTemplateBlockDeviceMapping) BlockDevice {
+	ebs := lo.FromPtr(mapping.Ebs)
+	return BlockDevice{
+		DeviceName: lo.FromPtr(mapping.DeviceName),
+		VolumeType: string(ebs.VolumeType),
+		VolumeSize: lo.FromPtr(ebs.VolumeSize),
+		IOPS:       lo.FromPtr(ebs.Iops),
+		Throughput: lo.FromPtr(ebs.Throughput),
+		Encrypted:  ebs.Encrypted,
+		KMSKeyID:   lo.FromPtr(ebs.KmsKeyId),
+	}
+}
+
+// String is a stable representation of the block device that is included in the launch template spec hash
+func (b BlockDevice) String() string {
+	return fmt.Sprintf("%s:%s:%d:%d:%d:%t:%s", b.DeviceName, lo.CoalesceOrEmpty(b.VolumeType, string(DefaultVolumeType)), b.VolumeSize, b.IOPS, b.Throughput, b.IsEncrypted(), b.KMSKeyID)
+}
@@ -2,14 +2,23 @@ package launchtemplates
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/pretty"
 	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
@@ -26,14 +35,56 @@ type SDKLaunchTemplatesOps interface {
 	ec2.DescribeLaunchTemplateVersionsAPIClient
 	CreateLaunchTemplate(context.Context, *ec2.CreateLaunchTemplateInput, ...func(*ec2.Options)) (*ec2.CreateLaunchTemplateOutput, error)
 	DeleteLaunchTemplate(context.Context, *ec2.DeleteLaunchTemplateInput, ...func(*ec2.Options)) (*ec2.DeleteLaunchTemplateOutput, error)
-	// CreateLaunchTemplateVersion(context.Context, *ec2.CreateLaunchTemplateVersionInput, ...func(*ec2.Options)) (*ec2.CreateLaunchTemplateVersionOutput, error)
+	CreateLaunchTemplateVersion(context.Context, *ec2.CreateLaunchTemplateVersionInput, ...func(*ec2.Options)) (*ec2.CreateLaunchTemplateVersionOutput, error)
 }
 
+const (
+	// VersionLatest selects the most recently created version of a launch template
+	VersionLatest = "$Latest"
+	// VersionDefault selects the version marked as the default of a launch template
+	VersionDefault = "$Default"
+	// SpecHashLength is the number of hex characters of a launch template spec hash
+	SpecHashLength = 10
+)
+
 // Selector is a struct that represents an launchTemplate selector
 type Selector struct {
 	Tags map[string]string
 	ID   string
 	Name string
+	// Version is a launch template version number, $Latest, or $Default.
+	// If Version is empty, all versions of the matched launch templates are resolved.
+	Version string
+	// DefaultOnly only resolves the default version of the matched launch templates
+	DefaultOnly bool
+}
+
+// CreateLaunchTemplateOptions are the parameters used to create a nimbus launch template
+type CreateLaunchTemplateOptions struct {
+	// LaunchTemplateName is the AWS name of the launch template, defaults to namespace/name-hash or namespace/name/group-hash
+	LaunchTemplateName string
+	Namespace          string
+	Name               string
+	// Group is the optional node group the launch template is created for.
+	// Grouped launch templates are tagged with the group.
+	Group          string
+	UserData       string
+	SecurityGroups []securitygroups.SecurityGroup
+	// BlockDevices are the EBS volumes of the launch template, e.g. the root volume
+	BlockDevices []BlockDevice
+	// KeyName is the optional key pair that instances are launched with
+	KeyName string
+	// InstanceProfileArn is the optional IAM instance profile that instances are launched with
+	InstanceProfileArn string
+	// ShutdownBehavior is stop or terminate and determines what happens when an instance shuts itself down, defaults to stop
+	ShutdownBehavior string
+	// MetadataOptions configure the instance metadata service, defaults to IMDSv2-required
+	MetadataOptions MetadataOptions
+	// NetworkInterfaces replace the default primary network interface, e.g. to attach EFAs.
+	// The SecurityGroups are the security groups of the interfaces that do not have their own.
+	NetworkInterfaces []NetworkInterface
+	// DryRun only checks whether the caller is permitted to create the launch template, EC2 returns a DryRunOperation error if it is
+	DryRun bool
 }
 
 // LaunchTemplate represents an Amazon EC2 LaunchTemplate
@@ -62,15 +113,48 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 			switch k {
 			case "id":
 				launchTemplateSelector.ID = v
+			case "name":
+				launchTemplateSelector.Name = v
+			case "version":
+				version, err := normalizeVersion(v)
+				if err != nil {
+					return nil, err
+				}
+				launchTemplateSelector.Version = version
+			case "default-only":
+				defaultOnly, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid launchTemplate default-only selector value %q: %w", v, err)
+				}
+				launchTemplateSelector.DefaultOnly = defaultOnly
 			default:
 				return nil, fmt.Errorf("invalid launchTemplate selector key: %s", k)
 			}
 		}
+		if launchTemplateSelector.DefaultOnly && launchTemplateSelector.Version != "" {
+			return nil, fmt.Errorf("cannot have both version and default-only in the same launchTemplate selector term")
+		}
 		launchTemplateSelectors = append(launchTemplateSelectors, launchTemplateSelector)
 	}
 	return launchTemplateSelectors, nil
 }
 
+// normalizeVersion validates a launch template version selector value and converts it to the form EC2 expects.
+// Accepted values are a positive version number, "latest" or "$Latest", and "default" or "$Default".
+func normalizeVersion(version string) (string, error) {
+	switch strings.ToLower(strings.TrimPrefix(strings.TrimSpace(version), "$")) {
+	case "latest":
+		return VersionLatest, nil
+	case "default":
+		return VersionDefault, nil
+	}
+	versionNum, err := strconv.ParseInt(strings.TrimSpace(version), 10, 64)
+	if err != nil || versionNum < 1 {
+		return "", fmt.Errorf("invalid launchTemplate version selector %q, expected a version number, latest, or default", version)
+	}
+	return strconv.FormatInt(versionNum, 10), nil
+}
+
 // NewWatcher creates a new LaunchTemplate Watcher
 func NewWatcher(launchTemplateAPI SDKLaunchTemplatesOps) Watcher {
 	return Watcher{
@@ -93,7 +177,7 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]LaunchTem
 				return nil, fmt.Errorf("failed to describe launch templates: %w", err)
 			}
 			for _, lt := range page.LaunchTemplates {
-				ltVersions, err := w.resolveLaunchTemplateVersions(ctx, *lt.LaunchTemplateId)
+				ltVersions, err := w.resolveLaunchTemplateVersions(ctx, *lt.LaunchTemplateId, selectors[i])
 				if err != nil {
 					return nil, err
 				}
@@ -104,11 +188,23 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]LaunchTem
 	return launchTemplates, nil
 }
 
-func (w Watcher) resolveLaunchTemplateVersions(ctx context.Context, launchTemplateID string) ([]LaunchTemplateVersion, error) {
+// resolveLaunchTemplateVersions describes the versions of a launch template that match the selector's version criteria.
+// Version and DefaultOnly narrow the result, otherwise every version is returned.
+func (w Watcher) resolveLaunchTemplateVersions(ctx context.Context, launchTemplateID string, selector Selector) ([]LaunchTemplateVersion, error) {
 	var launchTemplateVersions []LaunchTemplateVersion
-	pager := ec2.NewDescribeLaunchTemplateVersionsPaginator(w.launchTemplateAPI, &ec2.DescribeLaunchTemplateVersionsInput{
+	input := &ec2.DescribeLaunchTemplateVersionsInput{
 		LaunchTemplateId: aws.String(launchTemplateID),
-	})
+	}
+	if selector.Version != "" {
+		input.Versions = []string{selector.Version}
+	}
+	if selector.DefaultOnly {
+		input.Filters = []ec2types.Filter{{
+			Name:   aws.String("is-default-version"),
+			Values: []string{"true"},
+		}}
+	}
+	pager := ec2.NewDescribeLaunchTemplateVersionsPaginator(w.launchTemplateAPI, input)
 	for pager.HasMorePages() {
 		page, err := pager.NextPage(ctx)
 		if err != nil {
@@ -121,17 +217,32 @@ func (w Watcher) resolveLaunchTemplateVersions(ctx context.Context, launchTempla
 	return launchTemplateVersions, nil
 }
 
-func (w Watcher) CreateLaunchTemplate(ctx context.Context, namespace string, name string, userData string, securityGroups []securitygroups.SecurityGroup) (string, error) {
+// CreateLaunchTemplate creates a launch template with the data of the options and returns its ID.
+// Its first version is described by the spec hash of the options.
+func (w Watcher) CreateLaunchTemplate(ctx context.Context, createOpts CreateLaunchTemplateOptions) (string, error) {
+	specHash := SpecHash(createOpts)
+	name := createOpts.LaunchTemplateName
+	if name == "" {
+		name = fmt.Sprintf("%s/%s", createOpts.Namespace, createOpts.Name)
+		if createOpts.Group != "" {
+			name = fmt.Sprintf("%s/%s", name, createOpts.Group)
+		}
+		name = fmt.Sprintf("%s-%s", name, specHash)
+	}
+	tags := tagutils.NamespacedTags(createOpts.Namespace, createOpts.Name)
+	tags[tagutils.SpecHashTagKey] = specHash
+	if createOpts.Group != "" {
+		tags[tagutils.GroupTagKey] = createOpts.Group
+	}
 	out, err := w.launchTemplateAPI.CreateLaunchTemplate(ctx, &ec2.CreateLaunchTemplateInput{
-		LaunchTemplateName: aws.String(fmt.Sprintf("%s/%s", namespace, name)),
-		LaunchTemplateData: &ec2types.RequestLaunchTemplateData{
-			UserData:         aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
-			SecurityGroupIds: lo.Map(securityGroups, func(sg securitygroups.SecurityGroup, _ int) string { return *sg.GroupId }),
-		},
+		LaunchTemplateName: aws.String(name),
+		VersionDescription: aws.String(specHash),
+		DryRun:             lo.Ternary(createOpts.DryRun, aws.Bool(true), nil),
+		LaunchTemplateData: launchTemplateData(createOpts),
 		TagSpecifications: []ec2types.TagSpecification{
 			{
 				ResourceType: ec2types.ResourceTypeLaunchTemplate,
-				Tags:         tagutils.EC2NamespacedTags(namespace, name),
+				Tags:         tagutils.MapToEC2Tags(tags),
 			},
 		},
 	})
@@ -141,12 +252,131 @@ func (w Watcher) CreateLaunchTemplate(ctx context.Context, namespace string, nam
 	return *out.LaunchTemplate.LaunchTemplateId, nil
 }
 
-func (w Watcher) DeleteLaunchTemplate(ctx context.Context, launchTemplateID string) error {
-	_, err := w.launchTemplateAPI.DeleteLaunchTemplate(ctx, &ec2.DeleteLaunchTemplateInput{LaunchTemplateId: &launchTemplateID})
+// CreateLaunchTemplateVersion creates a version of the launch template with the data of the options, described by their spec hash,
+// unless the data of its $Latest version is the same. It returns the version with the data and whether it was created.
+// The launch template's tags are not changed.
+func (w Watcher) CreateLaunchTemplateVersion(ctx context.Context, launchTemplateID string, createOpts CreateLaunchTemplateOptions) (LaunchTemplateVersion, bool, error) {
+	latest, err := w.resolveLaunchTemplateVersions(ctx, launchTemplateID, Selector{Version: VersionLatest})
 	if err != nil {
-		return err
+		return LaunchTemplateVersion{}, false, err
+	}
+	if len(latest) == 1 && latest[0].LaunchTemplateData != nil && len(Diff(*latest[0].LaunchTemplateData, createOpts)) == 0 {
+		return latest[0], false, nil
+	}
+	out, err := w.launchTemplateAPI.CreateLaunchTemplateVersion(ctx, &ec2.CreateLaunchTemplateVersionInput{
+		LaunchTemplateId:   aws.String(launchTemplateID),
+		VersionDescription: aws.String(SpecHash(createOpts)),
+		DryRun:             lo.Ternary(createOpts.DryRun, aws.Bool(true), nil),
+		LaunchTemplateData: launchTemplateData(createOpts),
+	})
+	if err != nil {
+		return LaunchTemplateVersion{}, false, fmt.Errorf("failed to create a version of launch template %s: %w", launchTemplateID, err)
 	}
-	return nil
+	return LaunchTemplateVersion{*out.LaunchTemplateVersion}, true, nil
+}
+
+// launchTemplateData returns the launch template data of the options
+func launchTemplateData(createOpts CreateLaunchTemplateOptions) *ec2types.RequestLaunchTemplateData {
+	data := &ec2types.RequestLaunchTemplateData{
+		UserData:                          aws.String(base64.StdEncoding.EncodeToString([]byte(createOpts.UserData))),
+		KeyName:                           lo.Ternary(createOpts.KeyName == "", nil, aws.String(createOpts.KeyName)),
+		InstanceInitiatedShutdownBehavior: ec2types.ShutdownBehavior(createOpts.ShutdownBehavior),
+		IamInstanceProfile: lo.Ternary(createOpts.InstanceProfileArn == "", nil, &ec2types.LaunchTemplateIamInstanceProfileSpecificationRequest{
+			Arn: aws.String(createOpts.InstanceProfileArn),
+		}),
+		SecurityGroupIds: lo.Map(createOpts.SecurityGroups, func(sg securitygroups.SecurityGroup, _ int) string { return *sg.GroupId }),
+		BlockDeviceMappings: lo.Map(createOpts.BlockDevices, func(blockDevice BlockDevice, _ int) ec2types.LaunchTemplateBlockDeviceMappingRequest {
+			return blockDevice.blockDeviceMapping()
+		}),
+		MetadataOptions: createOpts.MetadataOptions.metadataOptions(),
+	}
+	// security groups are specified per interface when the launch template has network interfaces
+	if len(createOpts.NetworkInterfaces) != 0 {
+		data.NetworkInterfaces = lo.Map(createOpts.NetworkInterfaces, func(networkInterface NetworkInterface, _ int) ec2types.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest {
+			return networkInterface.networkInterfaceSpecification(data.SecurityGroupIds)
+		})
+		data.SecurityGroupIds = nil
+	}
+	return data
+}
+
+// Diff returns the fields of the launch template data that differ from the data that the options create:
+// user-data, key-name, shutdown-behavior, instance-profile, security-groups, block-devices, metadata-options, and network-interfaces
+func Diff(data ec2types.ResponseLaunchTemplateData, createOpts CreateLaunchTemplateOptions) []string {
+	return lo.Map(FieldDiffs(data, createOpts), func(diff pretty.FieldDiff, _ int) string { return diff.Field })
+}
+
+// FieldDiffs returns the fields of the launch template data that differ from the data that the options create with their live and desired values.
+// Security groups and block devices have a line per group or device.
+func FieldDiffs(data ec2types.ResponseLaunchTemplateData, createOpts CreateLaunchTemplateOptions) []pretty.FieldDiff {
+	liveUserData := lo.FromPtr(data.UserData)
+	if decoded, err := base64.StdEncoding.DecodeString(liveUserData); err == nil {
+		liveUserData = string(decoded)
+	}
+	securityGroupIDs := lo.Map(createOpts.SecurityGroups, func(sg securitygroups.SecurityGroup, _ int) string { return lo.FromPtr(sg.GroupId) })
+	currentSecurityGroupIDs := slices.Clone(data.SecurityGroupIds)
+	slices.Sort(securityGroupIDs)
+	slices.Sort(currentSecurityGroupIDs)
+	current := lo.Map(data.BlockDeviceMappings, func(mapping ec2types.LaunchTemplateBlockDeviceMapping, _ int) string {
+		return blockDeviceFromMapping(mapping).String()
+	})
+	desired := lo.Map(createOpts.BlockDevices, func(blockDevice BlockDevice, _ int) string { return blockDevice.String() })
+	currentInterfaces := lo.Map(data.NetworkInterfaces, func(specification ec2types.LaunchTemplateInstanceNetworkInterfaceSpecification, _ int) string {
+		return networkInterfaceFromSpecification(specification).String()
+	})
+	desiredInterfaces := lo.Map(createOpts.NetworkInterfaces, func(networkInterface NetworkInterface, _ int) string {
+		networkInterface.SecurityGroupIDs = lo.Ternary(len(networkInterface.SecurityGroupIDs) != 0, networkInterface.SecurityGroupIDs, securityGroupIDs)
+		return networkInterface.String()
+	})
+	// the security groups of a launch template with network interfaces are the interfaces' security groups
+	if len(createOpts.NetworkInterfaces) != 0 {
+		securityGroupIDs = nil
+	}
+
+	fields := []pretty.FieldDiff{
+		{Field: "user-data", Live: liveUserData, Desired: createOpts.UserData},
+		{Field: "key-name", Live: lo.FromPtr(data.KeyName), Desired: createOpts.KeyName},
+		{Field: "shutdown-behavior", Live: string(data.InstanceInitiatedShutdownBehavior), Desired: createOpts.ShutdownBehavior},
+		{Field: "instance-profile", Live: lo.FromPtr(lo.FromPtr(data.IamInstanceProfile).Arn), Desired: createOpts.InstanceProfileArn},
+		{Field: "security-groups", Live: strings.Join(currentSecurityGroupIDs, "\n"), Desired: strings.Join(securityGroupIDs, "\n")},
+		{Field: "block-devices", Live: strings.Join(current, "\n"), Desired: strings.Join(desired, "\n")},
+		{Field: "metadata-options", Live: metadataOptionsFromResponse(data.MetadataOptions).String(), Desired: createOpts.MetadataOptions.String()},
+		{Field: "network-interfaces", Live: strings.Join(currentInterfaces, "\n"), Desired: strings.Join(desiredInterfaces, "\n")},
+	}
+	return lo.Filter(fields, func(field pretty.FieldDiff, _ int) bool { return field.Live != field.Desired })
+}
+
+// SpecHash returns a short hash of the launch template data that CreateLaunchTemplate creates from the options.
+// Launch templates are tagged with the hash so that a launch only reuses a launch template with identical data.
+func SpecHash(createOpts CreateLaunchTemplateOptions) string {
+	securityGroupIDs := lo.Map(createOpts.SecurityGroups, func(sg securitygroups.SecurityGroup, _ int) string { return lo.FromPtr(sg.GroupId) })
+	sort.Strings(securityGroupIDs)
+	blockDevices := lo.Map(createOpts.BlockDevices, func(blockDevice BlockDevice, _ int) string { return blockDevice.String() })
+	fields := []string{createOpts.Namespace, createOpts.Name, createOpts.Group, createOpts.UserData, strings.Join(securityGroupIDs, ","), strings.Join(blockDevices, ","), createOpts.KeyName}
+	// optional fields are only hashed when set so that the hashes of existing launch templates do not change
+	for _, field := range []string{createOpts.ShutdownBehavior, createOpts.InstanceProfileArn} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	// the metadata options are always hashed with their defaults since every launch template is created with them
+	fields = append(fields, createOpts.MetadataOptions.String())
+	if len(createOpts.NetworkInterfaces) != 0 {
+		fields = append(fields, strings.Join(lo.Map(createOpts.NetworkInterfaces, func(networkInterface NetworkInterface, _ int) string { return networkInterface.String() }), ","))
+	}
+	hash := sha256.New()
+	for _, field := range fields {
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:SpecHashLength]
+}
+
+func (w Watcher) DeleteLaunchTemplate(ctx context.Context, launchTemplateID string) error {
+	return retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.launchTemplateAPI.DeleteLaunchTemplate(ctx, &ec2.DeleteLaunchTemplateInput{LaunchTemplateId: &launchTemplateID})
+		return err
+	})
 }
 
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
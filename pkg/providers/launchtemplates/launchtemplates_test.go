@@ -0,0 +1,521 @@
+package launchtemplates_test
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+)
+
+func TestParseSelectors(t *testing.T) {
+	type testCases struct {
+		selectorStr string
+		expected    []launchtemplates.Selector
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			selectorStr: "name:dev/web",
+			expected:    []launchtemplates.Selector{{Name: "dev/web"}},
+		},
+		{
+			selectorStr: "name:dev/web,version:3",
+			expected:    []launchtemplates.Selector{{Name: "dev/web", Version: "3"}},
+		},
+		{
+			selectorStr: "id:lt-123,version:latest",
+			expected:    []launchtemplates.Selector{{ID: "lt-123", Version: launchtemplates.VersionLatest}},
+		},
+		{
+			selectorStr: "id:lt-123,version:$Default",
+			expected:    []launchtemplates.Selector{{ID: "lt-123", Version: launchtemplates.VersionDefault}},
+		},
+		{
+			selectorStr: "name:dev/web,default-only:true;id:lt-123",
+			expected:    []launchtemplates.Selector{{Name: "dev/web", DefaultOnly: true}, {ID: "lt-123"}},
+		},
+		{
+			selectorStr: "name:dev/web,version:0",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "name:dev/web,version:newest",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "name:dev/web,version:2,default-only:true",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "owner:self",
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.selectorStr, func(t *testing.T) {
+			parsedSelectors, err := launchtemplates.ParseSelectors(tc.selectorStr)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(parsedSelectors) != len(tc.expected) {
+				t.Fatalf("expected %d selectors, got %d", len(tc.expected), len(parsedSelectors))
+			}
+			for i, expected := range tc.expected {
+				if parsedSelectors[i].ID != expected.ID {
+					t.Errorf("expected id %q, got %q", expected.ID, parsedSelectors[i].ID)
+				}
+				if parsedSelectors[i].Name != expected.Name {
+					t.Errorf("expected name %q, got %q", expected.Name, parsedSelectors[i].Name)
+				}
+				if parsedSelectors[i].Version != expected.Version {
+					t.Errorf("expected version %q, got %q", expected.Version, parsedSelectors[i].Version)
+				}
+				if parsedSelectors[i].DefaultOnly != expected.DefaultOnly {
+					t.Errorf("expected default-only %t, got %t", expected.DefaultOnly, parsedSelectors[i].DefaultOnly)
+				}
+			}
+		})
+	}
+}
+
+func TestSpecHash(t *testing.T) {
+	base := launchtemplates.CreateLaunchTemplateOptions{
+		Namespace: "dev",
+		Name:      "web",
+		UserData:  "#!/bin/bash",
+		SecurityGroups: []securitygroups.SecurityGroup{
+			{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-1")}},
+			{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-2")}},
+		},
+	}
+	reordered := base
+	reordered.SecurityGroups = []securitygroups.SecurityGroup{base.SecurityGroups[1], base.SecurityGroups[0]}
+	differentUserData := base
+	differentUserData.UserData = "#!/bin/bash\necho hi"
+	differentGroup := base
+	differentGroup.Group = "worker"
+	differentRootVolume := base
+	differentRootVolume.BlockDevices = []launchtemplates.BlockDevice{{DeviceName: "/dev/xvda", IOPS: 6000}}
+	differentKeyName := base
+	differentKeyName.KeyName = "dev"
+
+	hash := launchtemplates.SpecHash(base)
+	if len(hash) != launchtemplates.SpecHashLength {
+		t.Errorf("expected a hash of length %d, got %q", launchtemplates.SpecHashLength, hash)
+	}
+	if launchtemplates.SpecHash(reordered) != hash {
+		t.Errorf("expected security group order not to change the hash")
+	}
+	if launchtemplates.SpecHash(differentUserData) == hash {
+		t.Errorf("expected different user data to change the hash")
+	}
+	if launchtemplates.SpecHash(differentGroup) == hash {
+		t.Errorf("expected a different group to change the hash")
+	}
+	if launchtemplates.SpecHash(differentRootVolume) == hash {
+		t.Errorf("expected a different root volume to change the hash")
+	}
+	if launchtemplates.SpecHash(differentKeyName) == hash {
+		t.Errorf("expected a different key name to change the hash")
+	}
+}
+
+func TestBlockDeviceValidate(t *testing.T) {
+	type testCases struct {
+		name        string
+		blockDevice launchtemplates.BlockDevice
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{name: "defaults to gp3", blockDevice: launchtemplates.BlockDevice{}},
+		{name: "gp3 tuned", blockDevice: launchtemplates.BlockDevice{VolumeType: "gp3", VolumeSize: 100, IOPS: 6000, Throughput: 500}},
+		{name: "gp3 throughput without iops", blockDevice: launchtemplates.BlockDevice{Throughput: 750}},
+		{name: "gp3 throughput exceeds default iops", blockDevice: launchtemplates.BlockDevice{Throughput: 1000}, expectedErr: true},
+		{name: "gp3 iops too low", blockDevice: launchtemplates.BlockDevice{IOPS: 2000}, expectedErr: true},
+		{name: "gp3 iops too high", blockDevice: launchtemplates.BlockDevice{IOPS: 20000}, expectedErr: true},
+		{name: "gp3 throughput too high", blockDevice: launchtemplates.BlockDevice{IOPS: 16000, Throughput: 1200}, expectedErr: true},
+		{name: "gp3 iops exceed volume size", blockDevice: launchtemplates.BlockDevice{VolumeSize: 8, IOPS: 5000}, expectedErr: true},
+		{name: "gp2 with throughput", blockDevice: launchtemplates.BlockDevice{VolumeType: "gp2", Throughput: 250}, expectedErr: true},
+		{name: "gp2 with iops", blockDevice: launchtemplates.BlockDevice{VolumeType: "gp2", IOPS: 3000}, expectedErr: true},
+		{name: "io2 with iops", blockDevice: launchtemplates.BlockDevice{VolumeType: "io2", IOPS: 10000}},
+		{name: "io2 without iops", blockDevice: launchtemplates.BlockDevice{VolumeType: "io2"}, expectedErr: true},
+		{name: "unknown volume type", blockDevice: launchtemplates.BlockDevice{VolumeType: "gp9"}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.blockDevice.Validate()
+			if tc.expectedErr && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestParseBlockDevices(t *testing.T) {
+	type testCases struct {
+		blockDeviceStr string
+		expected       []launchtemplates.BlockDevice
+		expectedErr    bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			blockDeviceStr: "device:root,size:50",
+			expected:       []launchtemplates.BlockDevice{{DeviceName: launchtemplates.RootDevice, VolumeSize: 50}},
+		},
+		{
+			blockDeviceStr: "device:/dev/sdf,size:1TiB,type:io2,iops:10000;device:/dev/sdg,size:100GB,encrypted:false",
+			expected: []launchtemplates.BlockDevice{
+				{DeviceName: "/dev/sdf", VolumeSize: 1024, VolumeType: "io2", IOPS: 10000},
+				{DeviceName: "/dev/sdg", VolumeSize: 94, Encrypted: aws.Bool(false)},
+			},
+		},
+		{
+			blockDeviceStr: "device:/dev/sdf,size:500GiB,throughput:500,iops:6000,kms-key:arn:aws:kms:us-west-2:111122223333:key/1234",
+			expected:       []launchtemplates.BlockDevice{{DeviceName: "/dev/sdf", VolumeSize: 500, Throughput: 500, IOPS: 6000, KMSKeyID: "arn:aws:kms:us-west-2:111122223333:key/1234"}},
+		},
+		{
+			blockDeviceStr: "device:/dev/sdf,size:lots",
+			expectedErr:    true,
+		},
+		{
+			blockDeviceStr: "device:/dev/sdf,iops:fast",
+			expectedErr:    true,
+		},
+		{
+			blockDeviceStr: "device:/dev/sdf,snapshot:snap-123",
+			expectedErr:    true,
+		},
+	} {
+		t.Run(tc.blockDeviceStr, func(t *testing.T) {
+			blockDevices, err := launchtemplates.ParseBlockDevices(tc.blockDeviceStr)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(blockDevices) != len(tc.expected) {
+				t.Fatalf("expected %d block devices, got %d", len(tc.expected), len(blockDevices))
+			}
+			for i, expected := range tc.expected {
+				if blockDevices[i].String() != expected.String() {
+					t.Errorf("expected block device %s, got %s", expected, blockDevices[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateBlockDevices(t *testing.T) {
+	type testCases struct {
+		name         string
+		blockDevices []launchtemplates.BlockDevice
+		expectedErr  bool
+	}
+
+	for _, tc := range []testCases{
+		{name: "root without size", blockDevices: []launchtemplates.BlockDevice{{DeviceName: launchtemplates.RootDevice}}},
+		{name: "data volume", blockDevices: []launchtemplates.BlockDevice{{DeviceName: "/dev/sdf", VolumeSize: 100}}},
+		{name: "data volume without size", blockDevices: []launchtemplates.BlockDevice{{DeviceName: "/dev/sdf"}}, expectedErr: true},
+		{name: "missing device name", blockDevices: []launchtemplates.BlockDevice{{VolumeSize: 100}}, expectedErr: true},
+		{name: "duplicate device", blockDevices: []launchtemplates.BlockDevice{{DeviceName: "/dev/sdf", VolumeSize: 100}, {DeviceName: "/dev/sdf", VolumeSize: 200}}, expectedErr: true},
+		{name: "invalid volume", blockDevices: []launchtemplates.BlockDevice{{DeviceName: "/dev/sdf", VolumeSize: 100, VolumeType: "gp2", IOPS: 3000}}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := launchtemplates.ValidateBlockDevices(tc.blockDevices)
+			if tc.expectedErr && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDiff(t *testing.T) {
+	createOpts := launchtemplates.CreateLaunchTemplateOptions{
+		UserData:         "#!/bin/bash",
+		ShutdownBehavior: "terminate",
+		SecurityGroups: []securitygroups.SecurityGroup{
+			{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-1")}},
+			{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-2")}},
+		},
+		BlockDevices: []launchtemplates.BlockDevice{{DeviceName: "/dev/xvda", VolumeSize: 100}},
+	}
+	data := func() ec2types.ResponseLaunchTemplateData {
+		return ec2types.ResponseLaunchTemplateData{
+			UserData:                          aws.String(base64.StdEncoding.EncodeToString([]byte("#!/bin/bash"))),
+			InstanceInitiatedShutdownBehavior: ec2types.ShutdownBehaviorTerminate,
+			SecurityGroupIds:                  []string{"sg-2", "sg-1"},
+			BlockDeviceMappings: []ec2types.LaunchTemplateBlockDeviceMapping{{
+				DeviceName: aws.String("/dev/xvda"),
+				Ebs:        &ec2types.LaunchTemplateEbsBlockDevice{VolumeType: ec2types.VolumeTypeGp3, VolumeSize: aws.Int32(100), Encrypted: aws.Bool(true)},
+			}},
+			MetadataOptions: imdsv2(),
+		}
+	}
+	testCases := []struct {
+		name     string
+		data     func(*ec2types.ResponseLaunchTemplateData)
+		expected []string
+	}{
+		{name: "same", data: func(*ec2types.ResponseLaunchTemplateData) {}},
+		{name: "user data", data: func(d *ec2types.ResponseLaunchTemplateData) { d.UserData = aws.String("") }, expected: []string{"user-data"}},
+		{name: "key name", data: func(d *ec2types.ResponseLaunchTemplateData) { d.KeyName = aws.String("dev") }, expected: []string{"key-name"}},
+		{name: "security groups", data: func(d *ec2types.ResponseLaunchTemplateData) { d.SecurityGroupIds = []string{"sg-1"} }, expected: []string{"security-groups"}},
+		{name: "block devices", data: func(d *ec2types.ResponseLaunchTemplateData) { d.BlockDeviceMappings[0].Ebs.VolumeSize = aws.Int32(50) }, expected: []string{"block-devices"}},
+		{
+			name: "instance profile and shutdown behavior",
+			data: func(d *ec2types.ResponseLaunchTemplateData) {
+				d.InstanceInitiatedShutdownBehavior = ec2types.ShutdownBehaviorStop
+				d.IamInstanceProfile = &ec2types.LaunchTemplateIamInstanceProfileSpecification{Arn: aws.String("arn")}
+			},
+			expected: []string{"shutdown-behavior", "instance-profile"},
+		},
+		{name: "metadata options", data: func(d *ec2types.ResponseLaunchTemplateData) { d.MetadataOptions = nil }, expected: []string{"metadata-options"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := data()
+			tc.data(&d)
+			if diff := launchtemplates.Diff(d, createOpts); !slices.Equal(diff, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, diff)
+			}
+		})
+	}
+}
+
+// imdsv2 returns the metadata options of a launch template created with the default MetadataOptions
+func imdsv2() *ec2types.LaunchTemplateInstanceMetadataOptions {
+	return &ec2types.LaunchTemplateInstanceMetadataOptions{
+		HttpTokens:              ec2types.LaunchTemplateHttpTokensStateRequired,
+		HttpPutResponseHopLimit: aws.Int32(2),
+		InstanceMetadataTags:    ec2types.LaunchTemplateInstanceMetadataTagsStateDisabled,
+	}
+}
+
+func TestValidateMetadataOptions(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		options     launchtemplates.MetadataOptions
+		expectedErr bool
+	}{
+		{name: "defaults"},
+		{name: "optional tokens", options: launchtemplates.MetadataOptions{HTTPTokens: "optional", HTTPPutResponseHopLimit: 1, InstanceMetadataTags: "enabled"}},
+		{name: "invalid tokens", options: launchtemplates.MetadataOptions{HTTPTokens: "v2"}, expectedErr: true},
+		{name: "hop limit too large", options: launchtemplates.MetadataOptions{HTTPPutResponseHopLimit: 65}, expectedErr: true},
+		{name: "invalid tags", options: launchtemplates.MetadataOptions{InstanceMetadataTags: "on"}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.options.Validate()
+			if tc.expectedErr && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+	if !(launchtemplates.MetadataOptions{}).RequiresIMDSv2() {
+		t.Errorf("expected the default metadata options to require IMDSv2")
+	}
+}
+
+func TestParseNetworkInterfaces(t *testing.T) {
+	for _, tc := range []struct {
+		networkInterfaceStr string
+		expected            []launchtemplates.NetworkInterface
+		expectedErr         bool
+	}{
+		{
+			networkInterfaceStr: "card:0,device:0,type:efa;card:1,device:1,type:efa-only,security-groups:sg-2 sg-1",
+			expected: []launchtemplates.NetworkInterface{
+				{InterfaceType: launchtemplates.InterfaceTypeEFA},
+				{NetworkCardIndex: 1, DeviceIndex: 1, InterfaceType: launchtemplates.InterfaceTypeEFAOnly, SecurityGroupIDs: []string{"sg-1", "sg-2"}},
+			},
+		},
+		{
+			networkInterfaceStr: "device:0,public-ip:false",
+			expected:            []launchtemplates.NetworkInterface{{AssociatePublicIP: aws.Bool(false)}},
+		},
+		{networkInterfaceStr: "card:first", expectedErr: true},
+		{networkInterfaceStr: "public-ip:maybe", expectedErr: true},
+		{networkInterfaceStr: "subnet:subnet-123", expectedErr: true},
+	} {
+		t.Run(tc.networkInterfaceStr, func(t *testing.T) {
+			networkInterfaces, err := launchtemplates.ParseNetworkInterfaces(tc.networkInterfaceStr)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(networkInterfaces) != len(tc.expected) {
+				t.Fatalf("expected %d network interfaces, got %d", len(tc.expected), len(networkInterfaces))
+			}
+			for i, expected := range tc.expected {
+				if networkInterfaces[i].String() != expected.String() {
+					t.Errorf("expected network interface %s, got %s", expected, networkInterfaces[i])
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNetworkInterfaces(t *testing.T) {
+	for _, tc := range []struct {
+		name              string
+		networkInterfaces []launchtemplates.NetworkInterface
+		expectedErr       bool
+	}{
+		{name: "none"},
+		{name: "efa", networkInterfaces: []launchtemplates.NetworkInterface{{InterfaceType: "efa"}, {NetworkCardIndex: 1, DeviceIndex: 1, InterfaceType: "efa-only"}}},
+		{name: "public ip", networkInterfaces: []launchtemplates.NetworkInterface{{AssociatePublicIP: aws.Bool(true)}}},
+		{name: "missing primary", networkInterfaces: []launchtemplates.NetworkInterface{{NetworkCardIndex: 1, DeviceIndex: 1}}, expectedErr: true},
+		{name: "efa-only primary", networkInterfaces: []launchtemplates.NetworkInterface{{InterfaceType: "efa-only"}}, expectedErr: true},
+		{name: "invalid type", networkInterfaces: []launchtemplates.NetworkInterface{{InterfaceType: "ena"}}, expectedErr: true},
+		{name: "duplicate device", networkInterfaces: []launchtemplates.NetworkInterface{{}, {}}, expectedErr: true},
+		{name: "negative index", networkInterfaces: []launchtemplates.NetworkInterface{{}, {DeviceIndex: -1}}, expectedErr: true},
+		{name: "public ip on secondary", networkInterfaces: []launchtemplates.NetworkInterface{{}, {DeviceIndex: 1, AssociatePublicIP: aws.Bool(false)}}, expectedErr: true},
+		{name: "public ip with multiple interfaces", networkInterfaces: []launchtemplates.NetworkInterface{{AssociatePublicIP: aws.Bool(true)}, {DeviceIndex: 1}}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := launchtemplates.ValidateNetworkInterfaces(tc.networkInterfaces)
+			if tc.expectedErr && err == nil {
+				t.Errorf("expected an error, got none")
+			}
+			if !tc.expectedErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestDiffNetworkInterfaces(t *testing.T) {
+	createOpts := launchtemplates.CreateLaunchTemplateOptions{
+		SecurityGroups: []securitygroups.SecurityGroup{{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-1")}}},
+		NetworkInterfaces: []launchtemplates.NetworkInterface{
+			{InterfaceType: launchtemplates.InterfaceTypeEFA},
+			{NetworkCardIndex: 1, DeviceIndex: 1, InterfaceType: launchtemplates.InterfaceTypeEFAOnly, SecurityGroupIDs: []string{"sg-2"}},
+		},
+	}
+	data := func() ec2types.ResponseLaunchTemplateData {
+		return ec2types.ResponseLaunchTemplateData{
+			MetadataOptions: imdsv2(),
+			NetworkInterfaces: []ec2types.LaunchTemplateInstanceNetworkInterfaceSpecification{
+				{NetworkCardIndex: aws.Int32(0), DeviceIndex: aws.Int32(0), InterfaceType: aws.String("efa"), Groups: []string{"sg-1"}},
+				{NetworkCardIndex: aws.Int32(1), DeviceIndex: aws.Int32(1), InterfaceType: aws.String("efa-only"), Groups: []string{"sg-2"}},
+			},
+		}
+	}
+	testCases := []struct {
+		name     string
+		data     func(*ec2types.ResponseLaunchTemplateData)
+		expected []string
+	}{
+		{name: "same", data: func(*ec2types.ResponseLaunchTemplateData) {}},
+		{name: "interface type", data: func(d *ec2types.ResponseLaunchTemplateData) {
+			d.NetworkInterfaces[0].InterfaceType = aws.String("interface")
+		}, expected: []string{"network-interfaces"}},
+		{name: "security groups", data: func(d *ec2types.ResponseLaunchTemplateData) { d.NetworkInterfaces[0].Groups = []string{"sg-2"} }, expected: []string{"network-interfaces"}},
+		{
+			name: "default network interface",
+			data: func(d *ec2types.ResponseLaunchTemplateData) {
+				d.NetworkInterfaces = nil
+				d.SecurityGroupIds = []string{"sg-1"}
+			},
+			expected: []string{"security-groups", "network-interfaces"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d := data()
+			tc.data(&d)
+			if diff := launchtemplates.Diff(d, createOpts); !slices.Equal(diff, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, diff)
+			}
+		})
+	}
+}
+
+type fakeEC2 struct {
+	launchtemplates.SDKLaunchTemplatesOps
+	latest  ec2types.LaunchTemplateVersion
+	created []*ec2.CreateLaunchTemplateVersionInput
+}
+
+func (f *fakeEC2) DescribeLaunchTemplateVersions(_ context.Context, _ *ec2.DescribeLaunchTemplateVersionsInput, _ ...func(*ec2.Options)) (*ec2.DescribeLaunchTemplateVersionsOutput, error) {
+	return &ec2.DescribeLaunchTemplateVersionsOutput{LaunchTemplateVersions: []ec2types.LaunchTemplateVersion{f.latest}}, nil
+}
+
+func (f *fakeEC2) CreateLaunchTemplateVersion(_ context.Context, input *ec2.CreateLaunchTemplateVersionInput, _ ...func(*ec2.Options)) (*ec2.CreateLaunchTemplateVersionOutput, error) {
+	f.created = append(f.created, input)
+	version := ec2types.LaunchTemplateVersion{
+		LaunchTemplateId:   input.LaunchTemplateId,
+		VersionNumber:      aws.Int64(aws.ToInt64(f.latest.VersionNumber) + 1),
+		VersionDescription: input.VersionDescription,
+	}
+	return &ec2.CreateLaunchTemplateVersionOutput{LaunchTemplateVersion: &version}, nil
+}
+
+func TestCreateLaunchTemplateVersion(t *testing.T) {
+	createOpts := launchtemplates.CreateLaunchTemplateOptions{UserData: "#!/bin/bash", ShutdownBehavior: "terminate"}
+	latest := ec2types.LaunchTemplateVersion{
+		LaunchTemplateId: aws.String("lt-1"),
+		VersionNumber:    aws.Int64(2),
+		LaunchTemplateData: &ec2types.ResponseLaunchTemplateData{
+			UserData:                          aws.String(base64.StdEncoding.EncodeToString([]byte("#!/bin/bash"))),
+			InstanceInitiatedShutdownBehavior: ec2types.ShutdownBehaviorTerminate,
+			MetadataOptions:                   imdsv2(),
+		},
+	}
+
+	t.Run("reuses the latest version with the same data", func(t *testing.T) {
+		ec2API := &fakeEC2{latest: latest}
+		version, created, err := launchtemplates.NewWatcher(ec2API).CreateLaunchTemplateVersion(context.Background(), "lt-1", createOpts)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if created || len(ec2API.created) != 0 || aws.ToInt64(version.VersionNumber) != 2 {
+			t.Errorf("expected version 2 to be reused, got version %d", aws.ToInt64(version.VersionNumber))
+		}
+	})
+	t.Run("creates a version with changed data", func(t *testing.T) {
+		ec2API := &fakeEC2{latest: latest}
+		changed := createOpts
+		changed.UserData = "#!/bin/bash\necho hi"
+		version, created, err := launchtemplates.NewWatcher(ec2API).CreateLaunchTemplateVersion(context.Background(), "lt-1", changed)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !created || len(ec2API.created) != 1 || aws.ToInt64(version.VersionNumber) != 3 {
+			t.Fatalf("expected version 3 to be created, got version %d", aws.ToInt64(version.VersionNumber))
+		}
+		if description := aws.ToString(ec2API.created[0].VersionDescription); description != launchtemplates.SpecHash(changed) {
+			t.Errorf("expected the version to be described by the spec hash, got %q", description)
+		}
+	})
+}
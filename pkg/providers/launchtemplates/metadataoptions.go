@@ -0,0 +1,91 @@
+package launchtemplates
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
+)
+
+const (
+	// DefaultHTTPTokens only allows IMDSv2 requests, which need a session token, so that instances are hardened against SSRF by default
+	DefaultHTTPTokens = string(ec2types.LaunchTemplateHttpTokensStateRequired)
+	// DefaultHTTPPutResponseHopLimit lets containers on the instances reach the metadata service, the EC2 default of 1 does not with IMDSv2
+	DefaultHTTPPutResponseHopLimit = 2
+	// DefaultInstanceMetadataTags keeps the instances' tags out of the metadata service
+	DefaultInstanceMetadataTags = string(ec2types.LaunchTemplateInstanceMetadataTagsStateDisabled)
+
+	maxHTTPPutResponseHopLimit = 64
+)
+
+// MetadataOptions configure the instance metadata service (IMDS) of the instances launched from a launch template.
+// Unset fields default to IMDSv2 with a hop limit of 2 and without instance tags.
+type MetadataOptions struct {
+	// HTTPTokens is required, which only allows IMDSv2 requests with a session token, or optional, which also allows IMDSv1 requests
+	HTTPTokens string
+	// HTTPPutResponseHopLimit is how many network hops the session token response of IMDSv2 may travel, 1-64
+	HTTPPutResponseHopLimit int32
+	// InstanceMetadataTags is enabled or disabled and determines whether instances can read their tags from the metadata service
+	InstanceMetadataTags string
+}
+
+// WithDefaults returns the options with their unset fields defaulted
+func (o MetadataOptions) WithDefaults() MetadataOptions {
+	return MetadataOptions{
+		HTTPTokens:              lo.CoalesceOrEmpty(o.HTTPTokens, DefaultHTTPTokens),
+		HTTPPutResponseHopLimit: lo.CoalesceOrEmpty(o.HTTPPutResponseHopLimit, DefaultHTTPPutResponseHopLimit),
+		InstanceMetadataTags:    lo.CoalesceOrEmpty(o.InstanceMetadataTags, DefaultInstanceMetadataTags),
+	}
+}
+
+// Validate returns an error if a field is set to a value that EC2 does not accept
+func (o MetadataOptions) Validate() error {
+	if o.HTTPTokens != "" && !lo.Contains(ec2types.LaunchTemplateHttpTokensState("").Values(), ec2types.LaunchTemplateHttpTokensState(o.HTTPTokens)) {
+		return fmt.Errorf("invalid metadata http tokens %q, must be required or optional", o.HTTPTokens)
+	}
+	if o.HTTPPutResponseHopLimit < 0 || o.HTTPPutResponseHopLimit > maxHTTPPutResponseHopLimit {
+		return fmt.Errorf("invalid metadata hop limit %d, must be 1-%d", o.HTTPPutResponseHopLimit, maxHTTPPutResponseHopLimit)
+	}
+	if o.InstanceMetadataTags != "" && !lo.Contains(ec2types.LaunchTemplateInstanceMetadataTagsState("").Values(), ec2types.LaunchTemplateInstanceMetadataTagsState(o.InstanceMetadataTags)) {
+		return fmt.Errorf("invalid instance metadata tags %q, must be enabled or disabled", o.InstanceMetadataTags)
+	}
+	return nil
+}
+
+// RequiresIMDSv2 returns true if the instances only allow IMDSv2 requests
+func (o MetadataOptions) RequiresIMDSv2() bool {
+	return o.WithDefaults().HTTPTokens == DefaultHTTPTokens
+}
+
+// String formats the options with their defaults, e.g. tokens:required,hop-limit:2,tags:disabled
+func (o MetadataOptions) String() string {
+	o = o.WithDefaults()
+	return fmt.Sprintf("tokens:%s,hop-limit:%d,tags:%s", o.HTTPTokens, o.HTTPPutResponseHopLimit, o.InstanceMetadataTags)
+}
+
+// metadataOptions returns the launch template metadata options request of the options with their defaults
+func (o MetadataOptions) metadataOptions() *ec2types.LaunchTemplateInstanceMetadataOptionsRequest {
+	o = o.WithDefaults()
+	return &ec2types.LaunchTemplateInstanceMetadataOptionsRequest{
+		HttpTokens:              ec2types.LaunchTemplateHttpTokensState(o.HTTPTokens),
+		HttpPutResponseHopLimit: aws.Int32(o.HTTPPutResponseHopLimit),
+		InstanceMetadataTags:    ec2types.LaunchTemplateInstanceMetadataTagsState(o.InstanceMetadataTags),
+	}
+}
+
+// metadataOptionsFromResponse returns the options of a launch template's metadata options, nil options are the EC2 defaults
+func metadataOptionsFromResponse(options *ec2types.LaunchTemplateInstanceMetadataOptions) MetadataOptions {
+	if options == nil {
+		return MetadataOptions{
+			HTTPTokens:              string(ec2types.LaunchTemplateHttpTokensStateOptional),
+			HTTPPutResponseHopLimit: 1,
+			InstanceMetadataTags:    DefaultInstanceMetadataTags,
+		}
+	}
+	return MetadataOptions{
+		HTTPTokens:              string(options.HttpTokens),
+		HTTPPutResponseHopLimit: lo.FromPtr(options.HttpPutResponseHopLimit),
+		InstanceMetadataTags:    string(options.InstanceMetadataTags),
+	}
+}
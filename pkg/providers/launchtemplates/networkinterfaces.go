@@ -0,0 +1,154 @@
+package launchtemplates
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/samber/lo"
+)
+
+const (
+	// InterfaceTypeENA is a regular network interface, the default
+	InterfaceTypeENA = "interface"
+	// InterfaceTypeEFA is an Elastic Fabric Adapter that also carries IP traffic, e.g. for the primary interface of ML and HPC instances
+	InterfaceTypeEFA = "efa"
+	// InterfaceTypeEFAOnly is an Elastic Fabric Adapter without an IP address that only carries EFA traffic, it can not be the primary interface
+	InterfaceTypeEFAOnly = "efa-only"
+)
+
+// NetworkInterface is a network interface of the instances launched from a launch template.
+// The primary interface, device 0 of network card 0, is launched into the subnet that the fleet chooses, the others into the same subnet.
+type NetworkInterface struct {
+	// NetworkCardIndex is the network card of instance types with multiple network cards, e.g. the 32 of a p5.48xlarge
+	NetworkCardIndex int32
+	// DeviceIndex is the position of the interface on the instance, the primary interface is 0
+	DeviceIndex int32
+	// InterfaceType is interface, efa, or efa-only, defaults to interface
+	InterfaceType string
+	// AssociatePublicIP overrides whether the primary interface is assigned a public IP, the subnet decides if it is nil.
+	// EC2 only assigns public IPs to instances with a single network interface.
+	AssociatePublicIP *bool
+	// SecurityGroupIDs are the security groups of the interface, defaults to the plan's security groups
+	SecurityGroupIDs []string
+}
+
+// ParseNetworkInterfaces parses network interfaces separated by semicolons, security groups are separated by spaces.
+//
+// Example:
+//
+//	"card:0,device:0,type:efa;card:1,device:1,type:efa-only,security-groups:sg-1 sg-2"
+//
+// launches instances with an EFA as the primary interface and an EFA-only interface on the second network card
+func ParseNetworkInterfaces(networkInterfaceStr string) ([]NetworkInterface, error) {
+	terms, err := selectors.ParseSelectorsTokens(networkInterfaceStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse network interfaces: %w", err)
+	}
+	networkInterfaces := make([]NetworkInterface, 0, len(terms))
+	for _, term := range terms {
+		if len(term.Tags) != 0 {
+			return nil, fmt.Errorf("invalid network interface: tags are not supported")
+		}
+		networkInterface := NetworkInterface{}
+		for k, v := range term.KeyVals {
+			switch k {
+			case "card", "network-card":
+				networkInterface.NetworkCardIndex, err = parseInt32(v)
+			case "device", "device-index":
+				networkInterface.DeviceIndex, err = parseInt32(v)
+			case "type":
+				networkInterface.InterfaceType = v
+			case "public-ip":
+				var publicIP bool
+				publicIP, err = strconv.ParseBool(v)
+				networkInterface.AssociatePublicIP = &publicIP
+			case "security-groups", "sg-ids":
+				networkInterface.SecurityGroupIDs = strings.Fields(v)
+			default:
+				return nil, fmt.Errorf("invalid network interface key: %s", k)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid network interface %s: %w", k, err)
+			}
+		}
+		networkInterfaces = append(networkInterfaces, networkInterface)
+	}
+	return networkInterfaces, nil
+}
+
+// ValidateNetworkInterfaces returns an error if the network interfaces can not be launched together.
+// Instances need a primary interface that is not efa-only, and each network card and device index pair can only be used once.
+func ValidateNetworkInterfaces(networkInterfaces []NetworkInterface) error {
+	if len(networkInterfaces) == 0 {
+		return nil
+	}
+	positions := map[[2]int32]bool{}
+	for _, networkInterface := range networkInterfaces {
+		interfaceType := lo.CoalesceOrEmpty(networkInterface.InterfaceType, InterfaceTypeENA)
+		if !lo.Contains([]string{InterfaceTypeENA, InterfaceTypeEFA, InterfaceTypeEFAOnly}, interfaceType) {
+			return fmt.Errorf("invalid network interface type %q, must be %s, %s, or %s", networkInterface.InterfaceType, InterfaceTypeENA, InterfaceTypeEFA, InterfaceTypeEFAOnly)
+		}
+		if networkInterface.NetworkCardIndex < 0 || networkInterface.DeviceIndex < 0 {
+			return fmt.Errorf("network card and device indexes must not be negative")
+		}
+		position := [2]int32{networkInterface.NetworkCardIndex, networkInterface.DeviceIndex}
+		if positions[position] {
+			return fmt.Errorf("network card %d device %d has more than one network interface", position[0], position[1])
+		}
+		positions[position] = true
+		if networkInterface.isPrimary() && interfaceType == InterfaceTypeEFAOnly {
+			return fmt.Errorf("the primary network interface can not be %s", InterfaceTypeEFAOnly)
+		}
+		if networkInterface.AssociatePublicIP != nil && !networkInterface.isPrimary() {
+			return fmt.Errorf("only the primary network interface can override public IP assignment")
+		}
+	}
+	if !positions[[2]int32{0, 0}] {
+		return fmt.Errorf("network interfaces must include the primary interface, device 0 of network card 0")
+	}
+	if len(networkInterfaces) > 1 && lo.SomeBy(networkInterfaces, func(networkInterface NetworkInterface) bool { return lo.FromPtr(networkInterface.AssociatePublicIP) }) {
+		return fmt.Errorf("public IPs can not be assigned to instances with more than one network interface")
+	}
+	return nil
+}
+
+// isPrimary returns true if the interface is the primary interface of the instance
+func (n NetworkInterface) isPrimary() bool {
+	return n.NetworkCardIndex == 0 && n.DeviceIndex == 0
+}
+
+// String is a stable representation of the network interface that is included in the launch template spec hash
+func (n NetworkInterface) String() string {
+	securityGroupIDs := slices.Clone(n.SecurityGroupIDs)
+	slices.Sort(securityGroupIDs)
+	publicIP := lo.Ternary(n.AssociatePublicIP == nil, "subnet", strconv.FormatBool(lo.FromPtr(n.AssociatePublicIP)))
+	return fmt.Sprintf("%d:%d:%s:%s:%s", n.NetworkCardIndex, n.DeviceIndex, lo.CoalesceOrEmpty(n.InterfaceType, InterfaceTypeENA), publicIP, strings.Join(securityGroupIDs, " "))
+}
+
+// networkInterfaceSpecification returns the launch template request of the interface, it defaults to the security groups
+func (n NetworkInterface) networkInterfaceSpecification(securityGroupIDs []string) ec2types.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest {
+	return ec2types.LaunchTemplateInstanceNetworkInterfaceSpecificationRequest{
+		NetworkCardIndex:         aws.Int32(n.NetworkCardIndex),
+		DeviceIndex:              aws.Int32(n.DeviceIndex),
+		InterfaceType:            aws.String(lo.CoalesceOrEmpty(n.InterfaceType, InterfaceTypeENA)),
+		AssociatePublicIpAddress: n.AssociatePublicIP,
+		Groups:                   lo.Ternary(len(n.SecurityGroupIDs) != 0, n.SecurityGroupIDs, securityGroupIDs),
+		DeleteOnTermination:      aws.Bool(true),
+	}
+}
+
+// networkInterfaceFromSpecification returns the network interface of a launch template's network interface specification
+func networkInterfaceFromSpecification(specification ec2types.LaunchTemplateInstanceNetworkInterfaceSpecification) NetworkInterface {
+	return NetworkInterface{
+		NetworkCardIndex:  lo.FromPtr(specification.NetworkCardIndex),
+		DeviceIndex:       lo.FromPtr(specification.DeviceIndex),
+		InterfaceType:     lo.FromPtr(specification.InterfaceType),
+		AssociatePublicIP: specification.AssociatePublicIpAddress,
+		SecurityGroupIDs:  specification.Groups,
+	}
+}
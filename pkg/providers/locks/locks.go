@@ -0,0 +1,415 @@
+package locks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// parameterPrefix is the SSM parameter path that the locks of every namespace/name are stored under
+	parameterPrefix = "/nimbus/locks"
+	// takeoverPrefix is the SSM parameter path of the markers that let a single operation take over a stale lock
+	takeoverPrefix = "/nimbus/lock-takeovers"
+	// emptySegment is the path segment of the empty namespace or name, SSM parameter paths cannot have empty segments.
+	// Commands that change every VM of a namespace lock its empty name, which excludes the locks of every name in the namespace.
+	// A namespace or name that is the segment itself can not be locked, since its lock would be the lock of the empty one.
+	emptySegment = "-"
+	// DefaultLease is how long a lock is held without being renewed, a lock whose lease expired is stale and can be taken over
+	DefaultLease = 10 * time.Minute
+)
+
+// Watcher acquires and releases advisory locks of namespace/name.
+// A lock is an SSM parameter that is only created if it does not exist, so only one operator can hold it at a time.
+// The lock of a namespace, whose name is empty, and the locks of the names in it exclude each other.
+// Locks are advisory: they only keep nimbus commands that acquire them from running concurrently.
+type Watcher struct {
+	ssmAPI SDKSSMOps
+}
+
+// SDKSSMOps is an interface that combines the necessary SSM SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKSSMOps interface {
+	ssm.GetParametersByPathAPIClient
+	GetParameter(context.Context, *ssm.GetParameterInput, ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+	PutParameter(context.Context, *ssm.PutParameterInput, ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	DeleteParameter(context.Context, *ssm.DeleteParameterInput, ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error)
+}
+
+// Lock is a held lock of namespace/name
+type Lock struct {
+	Namespace string `table:"Namespace"`
+	Name      string `table:"Name"`
+	// ID identifies the acquisition, so a lock is only renewed and released by its holder
+	ID string `table:"ID,wide"`
+	// Holder is the user and host that acquired the lock
+	Holder string `table:"Holder"`
+	// Operation is the command that holds the lock, e.g. launch or delete
+	Operation  string    `table:"Operation"`
+	AcquiredAt time.Time `table:"Acquired"`
+	ExpiresAt  time.Time `table:"Expires"`
+}
+
+// HeldError is returned when the lock of namespace/name is held by another operation
+type HeldError struct {
+	Lock Lock
+}
+
+func (e HeldError) Error() string {
+	return fmt.Sprintf("%s/%s is locked by %s for %s since %s until %s", e.Lock.Namespace, e.Lock.Name, e.Lock.Holder, e.Lock.Operation,
+		e.Lock.AcquiredAt.Local().Format(time.DateTime), e.Lock.ExpiresAt.Local().Format(time.DateTime))
+}
+
+// IsHeld returns true if the error is a HeldError
+func IsHeld(err error) bool {
+	var heldErr HeldError
+	return errors.As(err, &heldErr)
+}
+
+// LostError is returned when a lock is renewed after it was forced to unlock or its lease expired,
+// so another operation may have acquired it since
+type LostError struct {
+	Lock Lock
+	// Reason is why the lock is no longer held
+	Reason string
+}
+
+func (e LostError) Error() string {
+	return fmt.Sprintf("the lock of %s/%s is no longer held, %s", e.Lock.Namespace, e.Lock.Name, e.Reason)
+}
+
+// IsLost returns true if the error is a LostError
+func IsLost(err error) bool {
+	var lostErr LostError
+	return errors.As(err, &lostErr)
+}
+
+// NewWatcher creates a new Lock Watcher
+func NewWatcher(ssmAPI SDKSSMOps) Watcher {
+	return Watcher{
+		ssmAPI: ssmAPI,
+	}
+}
+
+// Acquire locks namespace/name for the operation for the lease. A stale lock, whose lease expired, is taken over.
+// An empty name locks the whole namespace, which fails while any name in it is locked, and a name can not be locked while its namespace is.
+// A HeldError is returned if another operation holds the lock.
+func (w Watcher) Acquire(ctx context.Context, namespace, name, operation string, lease time.Duration) (Lock, error) {
+	if err := validate(namespace, name); err != nil {
+		return Lock{}, err
+	}
+	lock, err := w.acquire(ctx, namespace, name, operation, lease)
+	if err != nil {
+		return Lock{}, err
+	}
+	// Both a namespace lock and a name lock are put before the other is checked,
+	// so of two concurrent acquisitions at least one sees the other and backs off
+	conflict, found, err := w.conflict(ctx, lock)
+	if err == nil && !found {
+		return lock, nil
+	}
+	if err == nil {
+		err = HeldError{Lock: conflict}
+	}
+	if releaseErr := w.Release(ctx, lock); releaseErr != nil {
+		return Lock{}, errors.Join(err, releaseErr)
+	}
+	return Lock{}, err
+}
+
+// acquire puts the lock of namespace/name, taking over a stale lock, without checking the locks of its namespace or names
+func (w Watcher) acquire(ctx context.Context, namespace, name, operation string, lease time.Duration) (Lock, error) {
+	existing, found, err := w.Get(ctx, namespace, name)
+	if err != nil {
+		return Lock{}, err
+	}
+	now := time.Now()
+	if found && existing.ExpiresAt.After(now) {
+		return Lock{}, HeldError{Lock: existing}
+	}
+	if found {
+		// the holder of a stale lock was interrupted before it released it
+		releaseTakeover, err := w.takeOver(ctx, existing)
+		if err != nil {
+			return Lock{}, err
+		}
+		// the marker is only released once the new lock is put, so that a late takeover of the same stale lock finds the new lock
+		defer releaseTakeover()
+	}
+	id, err := newID()
+	if err != nil {
+		return Lock{}, err
+	}
+	lock := Lock{
+		Namespace:  namespace,
+		Name:       name,
+		ID:         id,
+		Holder:     Holder(),
+		Operation:  operation,
+		AcquiredAt: now,
+		ExpiresAt:  now.Add(lease),
+	}
+	if err := w.put(ctx, lock, false); err != nil {
+		var exists *ssmtypes.ParameterAlreadyExists
+		if !errors.As(err, &exists) {
+			return Lock{}, err
+		}
+		// another operation acquired the lock since it was checked
+		existing, found, err := w.Get(ctx, namespace, name)
+		if err != nil {
+			return Lock{}, err
+		}
+		if !found {
+			return Lock{}, fmt.Errorf("failed to acquire the lock of %s/%s, it was released while it was acquired, try again", namespace, name)
+		}
+		return Lock{}, HeldError{Lock: existing}
+	}
+	return lock, nil
+}
+
+// takeOver deletes a stale lock so that it can be acquired again and returns the func that ends the takeover.
+// Concurrent acquisitions may have read the same stale lock, so only the one that creates the takeover marker of the stale lock's ID
+// deletes it, and only if it is still the lock that was read. Otherwise a late acquisition would delete the lock that another one just acquired.
+func (w Watcher) takeOver(ctx context.Context, stale Lock) (func(), error) {
+	if _, err := w.ssmAPI.PutParameter(ctx, &ssm.PutParameterInput{
+		Name:        aws.String(takeoverParameterName(stale)),
+		Value:       aws.String(Holder()),
+		Type:        ssmtypes.ParameterTypeString,
+		Description: aws.String(fmt.Sprintf("nimbus takeover of the stale lock of %s/%s", stale.Namespace, stale.Name)),
+		Overwrite:   aws.Bool(false),
+	}); err != nil {
+		var exists *ssmtypes.ParameterAlreadyExists
+		if errors.As(err, &exists) {
+			return nil, fmt.Errorf("failed to acquire the lock of %s/%s, another operation is taking over its stale lock, try again", stale.Namespace, stale.Name)
+		}
+		return nil, fmt.Errorf("failed to take over the stale lock of %s/%s: %w", stale.Namespace, stale.Name, err)
+	}
+	releaseTakeover := func() { _ = w.deleteParameter(ctx, takeoverParameterName(stale)) }
+	current, found, err := w.Get(ctx, stale.Namespace, stale.Name)
+	if err != nil {
+		releaseTakeover()
+		return nil, err
+	}
+	if found && current.ID != stale.ID {
+		releaseTakeover()
+		return nil, HeldError{Lock: current}
+	}
+	if found {
+		if err := w.delete(ctx, stale.Namespace, stale.Name); err != nil {
+			releaseTakeover()
+			return nil, err
+		}
+	}
+	return releaseTakeover, nil
+}
+
+// conflict returns a held lock that excludes the lock: the lock of its namespace for a name lock,
+// or the lock of any name in its namespace for a namespace lock
+func (w Watcher) conflict(ctx context.Context, lock Lock) (Lock, bool, error) {
+	now := time.Now()
+	if lock.Name != "" {
+		namespaceLock, found, err := w.Get(ctx, lock.Namespace, "")
+		if err != nil || !found || !namespaceLock.ExpiresAt.After(now) {
+			return Lock{}, false, err
+		}
+		return namespaceLock, true, nil
+	}
+	nameLocks, err := w.list(ctx, lock.Namespace)
+	if err != nil {
+		return Lock{}, false, err
+	}
+	nameLock, found := lo.Find(nameLocks, func(nameLock Lock) bool { return nameLock.Name != "" && nameLock.ExpiresAt.After(now) })
+	return nameLock, found, nil
+}
+
+// Renew extends the lease of a held lock from now.
+// A LostError is returned if the lock is no longer held by the acquisition, renewing it again fails the same way.
+func (w Watcher) Renew(ctx context.Context, lock Lock, lease time.Duration) (Lock, error) {
+	existing, found, err := w.Get(ctx, lock.Namespace, lock.Name)
+	if err != nil {
+		return lock, err
+	}
+	if !found || existing.ID != lock.ID {
+		return lock, LostError{Lock: lock, Reason: "it was forced to unlock"}
+	}
+	// a stale lock may be taken over at any time, renewing it could overwrite the lock of the operation that took it over
+	if !existing.ExpiresAt.After(time.Now()) {
+		return lock, LostError{Lock: lock, Reason: "its lease expired"}
+	}
+	lock.ExpiresAt = time.Now().Add(lease)
+	if err := w.put(ctx, lock, true); err != nil {
+		return lock, err
+	}
+	return lock, nil
+}
+
+// Release unlocks namespace/name if the lock is still held by the acquisition, a lock that was taken over is kept
+func (w Watcher) Release(ctx context.Context, lock Lock) error {
+	existing, found, err := w.Get(ctx, lock.Namespace, lock.Name)
+	if err != nil {
+		return err
+	}
+	if !found || existing.ID != lock.ID {
+		return nil
+	}
+	return w.delete(ctx, lock.Namespace, lock.Name)
+}
+
+// ForceUnlock releases the lock of namespace/name regardless of its holder and returns it, false if namespace/name was not locked
+func (w Watcher) ForceUnlock(ctx context.Context, namespace, name string) (Lock, bool, error) {
+	if err := validate(namespace, name); err != nil {
+		return Lock{}, false, err
+	}
+	existing, found, err := w.Get(ctx, namespace, name)
+	if err != nil || !found {
+		return existing, found, err
+	}
+	if err := w.delete(ctx, namespace, name); err != nil {
+		return existing, false, err
+	}
+	// a takeover that was interrupted would otherwise keep the next takeover of the same lock from starting
+	if err := w.deleteParameter(ctx, takeoverParameterName(existing)); err != nil {
+		return existing, true, err
+	}
+	return existing, true, nil
+}
+
+// Get returns the lock of namespace/name and false if it is not locked
+func (w Watcher) Get(ctx context.Context, namespace, name string) (Lock, bool, error) {
+	out, err := w.ssmAPI.GetParameter(ctx, &ssm.GetParameterInput{Name: aws.String(ParameterName(namespace, name))})
+	if err != nil {
+		var notFound *ssmtypes.ParameterNotFound
+		if errors.As(err, &notFound) {
+			return Lock{}, false, nil
+		}
+		return Lock{}, false, fmt.Errorf("failed to get the lock of %s/%s: %w", namespace, name, err)
+	}
+	var lock Lock
+	if err := json.Unmarshal([]byte(lo.FromPtr(out.Parameter.Value)), &lock); err != nil {
+		return Lock{}, false, fmt.Errorf("failed to decode the lock of %s/%s: %w", namespace, name, err)
+	}
+	return lock, true, nil
+}
+
+// list returns the locks of the namespace, including the lock of the namespace itself
+func (w Watcher) list(ctx context.Context, namespace string) ([]Lock, error) {
+	var locks []Lock
+	paginator := ssm.NewGetParametersByPathPaginator(w.ssmAPI, &ssm.GetParametersByPathInput{
+		Path: aws.String(fmt.Sprintf("%s/%s", parameterPrefix, lo.CoalesceOrEmpty(namespace, emptySegment))),
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list the locks of namespace %s: %w", namespace, err)
+		}
+		for _, parameter := range out.Parameters {
+			var lock Lock
+			if err := json.Unmarshal([]byte(lo.FromPtr(parameter.Value)), &lock); err != nil {
+				return nil, fmt.Errorf("failed to decode lock %s: %w", lo.FromPtr(parameter.Name), err)
+			}
+			locks = append(locks, lock)
+		}
+	}
+	return locks, nil
+}
+
+// put stores the lock, it fails with ParameterAlreadyExists if namespace/name is locked and overwrite is false
+func (w Watcher) put(ctx context.Context, lock Lock, overwrite bool) error {
+	value, err := json.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	input := &ssm.PutParameterInput{
+		Name:        aws.String(ParameterName(lock.Namespace, lock.Name)),
+		Value:       aws.String(string(value)),
+		Type:        ssmtypes.ParameterTypeString,
+		Description: aws.String(fmt.Sprintf("nimbus lock of %s/%s", lock.Namespace, lock.Name)),
+		Overwrite:   aws.Bool(overwrite),
+	}
+	// SSM does not accept tags when a parameter is overwritten
+	if !overwrite {
+		input.Tags = lo.MapToSlice(tagutils.NamespacedTags(lock.Namespace, lock.Name), func(key, value string) ssmtypes.Tag {
+			return ssmtypes.Tag{Key: aws.String(key), Value: aws.String(value)}
+		})
+	}
+	if _, err := w.ssmAPI.PutParameter(ctx, input); err != nil {
+		var exists *ssmtypes.ParameterAlreadyExists
+		if errors.As(err, &exists) {
+			return err
+		}
+		return fmt.Errorf("failed to put the lock of %s/%s: %w", lock.Namespace, lock.Name, err)
+	}
+	return nil
+}
+
+// delete removes the lock of namespace/name, a lock that was already removed is not an error
+func (w Watcher) delete(ctx context.Context, namespace, name string) error {
+	if err := w.deleteParameter(ctx, ParameterName(namespace, name)); err != nil {
+		return fmt.Errorf("failed to delete the lock of %s/%s: %w", namespace, name, err)
+	}
+	return nil
+}
+
+// deleteParameter removes the SSM parameter, a parameter that was already removed is not an error
+func (w Watcher) deleteParameter(ctx context.Context, parameterName string) error {
+	if _, err := w.ssmAPI.DeleteParameter(ctx, &ssm.DeleteParameterInput{Name: aws.String(parameterName)}); err != nil {
+		var notFound *ssmtypes.ParameterNotFound
+		if errors.As(err, &notFound) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// validate checks that the lock of namespace/name is not the lock of an empty namespace or name
+func validate(namespace, name string) error {
+	if namespace == emptySegment || name == emptySegment {
+		return fmt.Errorf("%s/%s can not be locked, %q is reserved for empty namespaces and names", namespace, name, emptySegment)
+	}
+	return nil
+}
+
+// ParameterName returns the name of the SSM parameter that stores the lock of namespace/name, e.g. /nimbus/locks/dev/web
+func ParameterName(namespace, name string) string {
+	return fmt.Sprintf("%s/%s/%s", parameterPrefix, lo.CoalesceOrEmpty(namespace, emptySegment), lo.CoalesceOrEmpty(name, emptySegment))
+}
+
+// takeoverParameterName returns the name of the SSM parameter that marks the takeover of the stale lock, e.g. /nimbus/lock-takeovers/dev/web/<id>
+func takeoverParameterName(stale Lock) string {
+	return fmt.Sprintf("%s/%s/%s/%s", takeoverPrefix, lo.CoalesceOrEmpty(stale.Namespace, emptySegment), lo.CoalesceOrEmpty(stale.Name, emptySegment), stale.ID)
+}
+
+// Holder returns the user and host that locks are acquired by, e.g. alice@laptop
+func Holder() string {
+	username := "unknown"
+	if current, err := user.Current(); err == nil {
+		username = current.Username
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s@%s", username, hostname)
+}
+
+// newID returns a random ID of a lock acquisition
+func newID() (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate lock ID: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
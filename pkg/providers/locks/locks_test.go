@@ -0,0 +1,240 @@
+package locks_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/bwagner5/nimbus/pkg/providers/locks"
+)
+
+type fakeSSM struct {
+	parameters map[string]string
+	// beforeWrite runs once before the next put or delete, to interleave another operation with the one under test
+	beforeWrite func()
+}
+
+func (f *fakeSSM) write() {
+	if beforeWrite := f.beforeWrite; beforeWrite != nil {
+		f.beforeWrite = nil
+		beforeWrite()
+	}
+}
+
+func (f *fakeSSM) GetParametersByPath(_ context.Context, input *ssm.GetParametersByPathInput, _ ...func(*ssm.Options)) (*ssm.GetParametersByPathOutput, error) {
+	var out ssm.GetParametersByPathOutput
+	for name, value := range f.parameters {
+		// only the parameters right under the path, like SSM without Recursive
+		if rest, ok := strings.CutPrefix(name, *input.Path+"/"); ok && !strings.Contains(rest, "/") {
+			out.Parameters = append(out.Parameters, ssmtypes.Parameter{Name: aws.String(name), Value: aws.String(value)})
+		}
+	}
+	return &out, nil
+}
+
+func (f *fakeSSM) GetParameter(_ context.Context, input *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	value, ok := f.parameters[*input.Name]
+	if !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Name: input.Name, Value: aws.String(value)}}, nil
+}
+
+func (f *fakeSSM) PutParameter(_ context.Context, input *ssm.PutParameterInput, _ ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	f.write()
+	if _, ok := f.parameters[*input.Name]; ok && !aws.ToBool(input.Overwrite) {
+		return nil, &ssmtypes.ParameterAlreadyExists{}
+	}
+	f.parameters[*input.Name] = *input.Value
+	return &ssm.PutParameterOutput{}, nil
+}
+
+func (f *fakeSSM) DeleteParameter(_ context.Context, input *ssm.DeleteParameterInput, _ ...func(*ssm.Options)) (*ssm.DeleteParameterOutput, error) {
+	f.write()
+	if _, ok := f.parameters[*input.Name]; !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	delete(f.parameters, *input.Name)
+	return &ssm.DeleteParameterOutput{}, nil
+}
+
+func TestAcquire(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSSM{parameters: map[string]string{}}
+	watcher := locks.NewWatcher(fake)
+
+	lock, err := watcher.Acquire(ctx, "dev", "web", "launch", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fake.parameters["/nimbus/locks/dev/web"]; !ok {
+		t.Fatalf("expected the lock parameter, got %v", fake.parameters)
+	}
+	if _, err := watcher.Acquire(ctx, "dev", "web", "delete", time.Minute); !locks.IsHeld(err) {
+		t.Fatalf("expected the lock to be held, got %v", err)
+	}
+	if _, err := watcher.Acquire(ctx, "dev", "api", "delete", time.Minute); err != nil {
+		t.Fatalf("expected other names to be unlocked, got %v", err)
+	}
+	if _, err := watcher.Renew(ctx, lock, time.Hour); err != nil {
+		t.Fatalf("unexpected error renewing the lock: %v", err)
+	}
+	if err := watcher.Release(ctx, lock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, found, _ := watcher.Get(ctx, "dev", "web"); found {
+		t.Fatal("expected the lock to be released")
+	}
+
+	// a stale lock is taken over, and its holder neither renews nor releases the new lock
+	stale, err := watcher.Acquire(ctx, "", "web", "launch", -time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	current, err := watcher.Acquire(ctx, "", "web", "delete", time.Minute)
+	if err != nil {
+		t.Fatalf("expected the stale lock to be taken over, got %v", err)
+	}
+	if _, err := watcher.Renew(ctx, stale, time.Minute); !locks.IsLost(err) {
+		t.Errorf("expected the stale lock to be lost, got %v", err)
+	}
+	if err := watcher.Release(ctx, stale); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	existing, found, err := watcher.Get(ctx, "", "web")
+	if err != nil || !found || existing.ID != current.ID {
+		t.Fatalf("expected the current lock to be kept, got %+v %t %v", existing, found, err)
+	}
+
+	forced, unlocked, err := watcher.ForceUnlock(ctx, "", "web")
+	if err != nil || !unlocked || forced.Operation != "delete" {
+		t.Fatalf("expected the delete lock to be forced to unlock, got %+v %t %v", forced, unlocked, err)
+	}
+	if _, unlocked, _ := watcher.ForceUnlock(ctx, "", "web"); unlocked {
+		t.Error("expected nothing to unlock")
+	}
+}
+
+func TestAcquireNamespace(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSSM{parameters: map[string]string{}}
+	watcher := locks.NewWatcher(fake)
+
+	nameLock, err := watcher.Acquire(ctx, "dev", "web", "launch", time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := watcher.Acquire(ctx, "dev", "", "delete", time.Minute); !locks.IsHeld(err) {
+		t.Fatalf("expected the namespace not to be locked while a name in it is, got %v", err)
+	}
+	if _, found, _ := watcher.Get(ctx, "dev", ""); found {
+		t.Error("expected the namespace lock to be released after it conflicted")
+	}
+	if _, err := watcher.Acquire(ctx, "prod", "", "delete", time.Minute); err != nil {
+		t.Fatalf("expected other namespaces to be unlocked, got %v", err)
+	}
+	if err := watcher.Release(ctx, nameLock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// stale name locks do not keep the namespace from being locked
+	if _, err := watcher.Acquire(ctx, "dev", "api", "launch", -time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	namespaceLock, err := watcher.Acquire(ctx, "dev", "", "delete", time.Minute)
+	if err != nil {
+		t.Fatalf("expected the namespace to be locked, got %v", err)
+	}
+	if _, err := watcher.Acquire(ctx, "dev", "web", "launch", time.Minute); !locks.IsHeld(err) {
+		t.Fatalf("expected a name not to be locked while its namespace is, got %v", err)
+	}
+	if _, found, _ := watcher.Get(ctx, "dev", "web"); found {
+		t.Error("expected the name lock to be released after it conflicted")
+	}
+	if err := watcher.Release(ctx, namespaceLock); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := watcher.Acquire(ctx, "dev", "web", "launch", time.Minute); err != nil {
+		t.Fatalf("expected the name to be locked once the namespace is released, got %v", err)
+	}
+}
+
+func TestAcquireStaleRace(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSSM{parameters: map[string]string{}}
+	watcher := locks.NewWatcher(fake)
+	if _, err := watcher.Acquire(ctx, "dev", "web", "launch", -time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// another operation takes over the stale lock after this one read it and before it changes anything
+	var other locks.Lock
+	fake.beforeWrite = func() {
+		var err error
+		if other, err = watcher.Acquire(ctx, "dev", "web", "delete", time.Minute); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if _, err := watcher.Acquire(ctx, "dev", "web", "launch", time.Minute); !locks.IsHeld(err) {
+		t.Fatalf("expected the lock to be held by the operation that took it over, got %v", err)
+	}
+	existing, found, err := watcher.Get(ctx, "dev", "web")
+	if err != nil || !found || existing.ID != other.ID {
+		t.Fatalf("expected the lock that took over the stale lock to be kept, got %+v %t %v", existing, found, err)
+	}
+	if len(fake.parameters) != 1 {
+		t.Errorf("expected only the lock to be left, got %v", fake.parameters)
+	}
+
+	// a stale lock that another operation is taking over is not deleted
+	stale, err := watcher.Acquire(ctx, "dev", "api", "launch", -time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	fake.parameters["/nimbus/lock-takeovers/dev/api/"+stale.ID] = "alice@laptop"
+	if _, err := watcher.Acquire(ctx, "dev", "api", "delete", time.Minute); err == nil {
+		t.Fatal("expected the stale lock not to be taken over twice")
+	}
+	if existing, found, _ := watcher.Get(ctx, "dev", "api"); !found || existing.ID != stale.ID {
+		t.Errorf("expected the stale lock to be kept, got %+v", existing)
+	}
+	if _, _, err := watcher.ForceUnlock(ctx, "dev", "api"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := fake.parameters["/nimbus/lock-takeovers/dev/api/"+stale.ID]; ok {
+		t.Error("expected the takeover to be forced to unlock with the stale lock")
+	}
+}
+
+func TestParameterName(t *testing.T) {
+	if name := locks.ParameterName("", "web"); name != "/nimbus/locks/-/web" {
+		t.Errorf("expected the empty namespace to have a path segment, got %s", name)
+	}
+}
+
+func TestAcquireEmptySegment(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeSSM{parameters: map[string]string{}}
+	watcher := locks.NewWatcher(fake)
+
+	// the lock of the name - would be the lock of the whole namespace
+	if _, err := watcher.Acquire(ctx, "dev", "-", "launch", time.Minute); err == nil {
+		t.Error("expected the name - not to be locked")
+	}
+	if _, err := watcher.Acquire(ctx, "-", "web", "launch", time.Minute); err == nil {
+		t.Error("expected the namespace - not to be locked")
+	}
+	if _, err := watcher.Acquire(ctx, "dev", "", "delete", time.Minute); err != nil {
+		t.Fatalf("expected the namespace to be locked, got %v", err)
+	}
+	if _, _, err := watcher.ForceUnlock(ctx, "dev", "-"); err == nil {
+		t.Error("expected the name - not to be forced to unlock")
+	}
+	if _, found, _ := watcher.Get(ctx, "dev", ""); !found {
+		t.Error("expected the namespace lock to be kept")
+	}
+}
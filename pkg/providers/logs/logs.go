@@ -0,0 +1,271 @@
+// Package logs ships the logs of instances to CloudWatch Logs with the CloudWatch agent and reads them back.
+//
+// Instances ship to the log streams namespace/name/<instance-id>/<source>, so the streams of a VM share a prefix
+// and the events of its instances can be read after they are terminated.
+package logs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cloudwatchlogstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
+)
+
+const (
+	// Log sources are the files that the CloudWatch agent ships from every instance
+	SourceCloudInit = "cloud-init-output"
+	SourceNimbus    = "nimbus"
+	SourceJob       = "job"
+
+	// AgentConfigPath is where the user-data writes the CloudWatch agent's configuration
+	AgentConfigPath = "/opt/aws/amazon-cloudwatch-agent/etc/nimbus.json"
+)
+
+// sourceFiles are the files of the log sources, the CloudWatch agent ships every file matching a wildcard to the same stream
+var sourceFiles = map[string]string{
+	SourceCloudInit: "/var/log/cloud-init-output.log",
+	SourceNimbus:    "/var/log/nimbus-*.log",
+	SourceJob:       "/var/log/nimbus-job/output",
+}
+
+// retentionDays are the number of days that CloudWatch Logs accepts as the retention of a log group
+var retentionDays = []int32{1, 3, 5, 7, 14, 30, 60, 90, 120, 150, 180, 365, 400, 545, 731, 1096, 1827, 2192, 2557, 2922, 3288, 3653}
+
+// logGroupName matches the names that CloudWatch Logs allows for log groups
+var logGroupName = regexp.MustCompile(`^[\w./#-]{1,512}$`)
+
+// Watcher creates the log groups that instances ship their logs to and reads the shipped events
+type Watcher struct {
+	logsAPI SDKLogsOps
+}
+
+// SDKLogsOps is an interface that combines the necessary CloudWatch Logs SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKLogsOps interface {
+	cloudwatchlogs.DescribeLogGroupsAPIClient
+	cloudwatchlogs.FilterLogEventsAPIClient
+	CreateLogGroup(context.Context, *cloudwatchlogs.CreateLogGroupInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error)
+	PutRetentionPolicy(context.Context, *cloudwatchlogs.PutRetentionPolicyInput, ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error)
+}
+
+// LogGroup is a CloudWatch Logs group
+type LogGroup struct {
+	LogGroupName    string `json:"logGroupName" table:"Name"`
+	Arn             string `json:"arn" table:"ARN"`
+	RetentionInDays int32  `json:"retentionInDays,omitempty" table:"Retention Days"`
+}
+
+// Destination is the log group that instances ship their logs to
+type Destination struct {
+	// LogGroup is created if it does not exist, empty does not ship logs
+	LogGroup string
+	// RetentionDays is how long a created log group keeps events, zero keeps them forever. The retention of an existing log group is not changed.
+	RetentionDays int32
+}
+
+// Event is a log line shipped from an instance
+type Event struct {
+	Time       time.Time
+	InstanceID string
+	// Source is the log source of the line, e.g. cloud-init-output
+	Source  string
+	Message string
+	// ID is unique in the log group
+	ID string
+}
+
+// NewWatcher creates a new Logs Watcher
+func NewWatcher(logsAPI SDKLogsOps) Watcher {
+	return Watcher{
+		logsAPI: logsAPI,
+	}
+}
+
+// Get returns the log group with the name, false if it does not exist
+func (w Watcher) Get(ctx context.Context, name string) (LogGroup, bool, error) {
+	paginator := cloudwatchlogs.NewDescribeLogGroupsPaginator(w.logsAPI, &cloudwatchlogs.DescribeLogGroupsInput{LogGroupNamePrefix: aws.String(name)})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return LogGroup{}, false, fmt.Errorf("failed to describe log groups: %w", err)
+		}
+		// the prefix also matches longer names
+		if group, ok := lo.Find(out.LogGroups, func(group cloudwatchlogstypes.LogGroup) bool { return aws.ToString(group.LogGroupName) == name }); ok {
+			return newLogGroup(group), true, nil
+		}
+	}
+	return LogGroup{}, false, nil
+}
+
+// Ensure returns the destination's log group, creating it with the tags and retention if it does not exist
+func (w Watcher) Ensure(ctx context.Context, destination Destination, tags map[string]string) (LogGroup, error) {
+	group, found, err := w.Get(ctx, destination.LogGroup)
+	if err != nil || found {
+		return group, err
+	}
+	// A concurrent launch may have created the log group first, which is safe to share
+	if _, err := w.logsAPI.CreateLogGroup(ctx, &cloudwatchlogs.CreateLogGroupInput{LogGroupName: aws.String(destination.LogGroup), Tags: tags}); err != nil && !IsAlreadyExists(err) {
+		return LogGroup{}, fmt.Errorf("failed to create log group %s: %w", destination.LogGroup, err)
+	}
+	if destination.RetentionDays != 0 {
+		if _, err := w.logsAPI.PutRetentionPolicy(ctx, &cloudwatchlogs.PutRetentionPolicyInput{LogGroupName: aws.String(destination.LogGroup), RetentionInDays: aws.Int32(destination.RetentionDays)}); err != nil {
+			return LogGroup{}, fmt.Errorf("failed to set the retention of log group %s: %w", destination.LogGroup, err)
+		}
+	}
+	group, found, err = w.Get(ctx, destination.LogGroup)
+	if err != nil {
+		return LogGroup{}, err
+	}
+	if !found {
+		return LogGroup{}, fmt.Errorf("log group %s was created but could not be found", destination.LogGroup)
+	}
+	return group, nil
+}
+
+// Events returns the events of the log group's streams with the prefix from the start time on, in the order they happened
+func (w Watcher) Events(ctx context.Context, logGroup, streamPrefix string, start time.Time) ([]Event, error) {
+	var events []Event
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(w.logsAPI, &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:        aws.String(logGroup),
+		LogStreamNamePrefix: aws.String(streamPrefix),
+		StartTime:           aws.Int64(start.UnixMilli()),
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read log events of %s: %w", logGroup, err)
+		}
+		for _, event := range out.Events {
+			instanceID, source := parseStreamName(strings.TrimPrefix(aws.ToString(event.LogStreamName), streamPrefix))
+			events = append(events, Event{
+				Time:       time.UnixMilli(aws.ToInt64(event.Timestamp)),
+				InstanceID: instanceID,
+				Source:     source,
+				Message:    strings.TrimRight(aws.ToString(event.Message), "\n"),
+				ID:         aws.ToString(event.EventId),
+			})
+		}
+	}
+	// pages are not guaranteed to be in order across streams
+	slices.SortStableFunc(events, func(a, b Event) int { return a.Time.Compare(b.Time) })
+	return events, nil
+}
+
+// ParseDestination parses a log shipping destination like "group:/nimbus/dev,retention:14", retention is in days
+func ParseDestination(destination string) (Destination, error) {
+	var parsed Destination
+	if strings.TrimSpace(destination) == "" {
+		return parsed, nil
+	}
+	for _, field := range strings.Split(destination, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), ":")
+		if !ok {
+			return Destination{}, fmt.Errorf("invalid log destination %q, expected key:value fields like group:/nimbus/dev", field)
+		}
+		switch key {
+		case "group":
+			if !logGroupName.MatchString(value) {
+				return Destination{}, fmt.Errorf("invalid log group name %q", value)
+			}
+			parsed.LogGroup = value
+		case "retention":
+			days, err := strconv.ParseInt(strings.TrimSuffix(value, "d"), 10, 32)
+			if err != nil || !slices.Contains(retentionDays, int32(days)) {
+				return Destination{}, fmt.Errorf("invalid log retention %q, must be one of %v days", value, retentionDays)
+			}
+			parsed.RetentionDays = int32(days)
+		default:
+			return Destination{}, fmt.Errorf("unknown log destination field %q, must be group or retention", key)
+		}
+	}
+	if parsed.LogGroup == "" {
+		return Destination{}, fmt.Errorf("log destination %q does not have a group", destination)
+	}
+	return parsed, nil
+}
+
+// StreamPrefix returns the prefix of the log streams of the namespace and name's instances
+func StreamPrefix(namespace, name string) string {
+	return fmt.Sprintf("%s/%s/", namespace, name)
+}
+
+// AgentConfig returns the CloudWatch agent configuration that ships the log sources of the namespace and name's instances to the log group
+func AgentConfig(logGroup, namespace, name string) string {
+	type collect struct {
+		FilePath      string `json:"file_path"`
+		LogGroupName  string `json:"log_group_name"`
+		LogStreamName string `json:"log_stream_name"`
+	}
+	sources := lo.Keys(sourceFiles)
+	slices.Sort(sources)
+	collectList := lo.Map(sources, func(source string, _ int) collect {
+		return collect{
+			FilePath:     sourceFiles[source],
+			LogGroupName: logGroup,
+			// the agent replaces {instance_id} with the ID of the instance it runs on
+			LogStreamName: StreamPrefix(namespace, name) + "{instance_id}/" + source,
+		}
+	})
+	config := map[string]any{
+		"agent": map[string]any{"run_as_user": "root"},
+		"logs": map[string]any{
+			"logs_collected": map[string]any{
+				"files": map[string]any{"collect_list": collectList},
+			},
+		},
+	}
+	return string(lo.Must(json.Marshal(config)))
+}
+
+// Policy returns the IAM policy document that allows the CloudWatch agent to ship to the log group with the ARN
+func Policy(logGroupArn string) string {
+	// the ARNs of DescribeLogGroups are suffixed with :*, which matches the group's streams but not the group itself
+	groupArn := strings.TrimSuffix(logGroupArn, ":*")
+	policy := map[string]any{
+		"Version": "2012-10-17",
+		"Statement": []map[string]any{{
+			"Effect":   "Allow",
+			"Action":   []string{"logs:CreateLogStream", "logs:DescribeLogStreams", "logs:PutLogEvents"},
+			"Resource": []string{groupArn, groupArn + ":*"},
+		}},
+	}
+	return string(lo.Must(json.Marshal(policy)))
+}
+
+// parseStreamName returns the instance ID and source of a stream name without its namespace/name prefix
+func parseStreamName(streamName string) (string, string) {
+	instanceID, source, _ := strings.Cut(streamName, "/")
+	return instanceID, source
+}
+
+// IsNotFound returns true if the error is a missing log group error
+func IsNotFound(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ResourceNotFoundException"
+}
+
+// IsAlreadyExists returns true if the error is an existing log group error
+func IsAlreadyExists(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "ResourceAlreadyExistsException"
+}
+
+// newLogGroup converts a CloudWatch Logs SDK log group
+func newLogGroup(group cloudwatchlogstypes.LogGroup) LogGroup {
+	return LogGroup{
+		LogGroupName:    aws.ToString(group.LogGroupName),
+		Arn:             aws.ToString(group.Arn),
+		RetentionInDays: aws.ToInt32(group.RetentionInDays),
+	}
+}
@@ -0,0 +1,187 @@
+package logs_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cloudwatchlogstypes "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	"github.com/aws/smithy-go"
+	"github.com/bwagner5/nimbus/pkg/providers/logs"
+)
+
+func TestParseDestination(t *testing.T) {
+	testCases := []struct {
+		destination string
+		expected    logs.Destination
+		expectError bool
+	}{
+		{destination: "", expected: logs.Destination{}},
+		{destination: "group:/nimbus/dev", expected: logs.Destination{LogGroup: "/nimbus/dev"}},
+		{destination: "group:/nimbus/dev, retention:14", expected: logs.Destination{LogGroup: "/nimbus/dev", RetentionDays: 14}},
+		{destination: "retention:7d,group:dev", expected: logs.Destination{LogGroup: "dev", RetentionDays: 7}},
+		{destination: "retention:14", expectError: true},
+		{destination: "group:/nimbus/dev,retention:15", expectError: true},
+		{destination: "group:/nimbus/dev*", expectError: true},
+		{destination: "/nimbus/dev", expectError: true},
+		{destination: "stream:web", expectError: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.destination, func(t *testing.T) {
+			destination, err := logs.ParseDestination(tc.destination)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if destination != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, destination)
+			}
+		})
+	}
+}
+
+func TestAgentConfig(t *testing.T) {
+	var config struct {
+		Logs struct {
+			LogsCollected struct {
+				Files struct {
+					CollectList []struct {
+						FilePath      string `json:"file_path"`
+						LogGroupName  string `json:"log_group_name"`
+						LogStreamName string `json:"log_stream_name"`
+					} `json:"collect_list"`
+				} `json:"files"`
+			} `json:"logs_collected"`
+		} `json:"logs"`
+	}
+	if err := json.Unmarshal([]byte(logs.AgentConfig("/nimbus/dev", "default", "web")), &config); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	streams := map[string]string{}
+	for _, collect := range config.Logs.LogsCollected.Files.CollectList {
+		if collect.LogGroupName != "/nimbus/dev" {
+			t.Errorf("expected %s to ship to /nimbus/dev, got %s", collect.FilePath, collect.LogGroupName)
+		}
+		streams[collect.FilePath] = collect.LogStreamName
+	}
+	if stream := streams["/var/log/cloud-init-output.log"]; stream != "default/web/{instance_id}/cloud-init-output" {
+		t.Errorf("unexpected cloud-init stream %q", stream)
+	}
+	if stream := streams["/var/log/nimbus-job/output"]; stream != "default/web/{instance_id}/job" {
+		t.Errorf("unexpected job stream %q", stream)
+	}
+}
+
+func TestPolicy(t *testing.T) {
+	policy := logs.Policy("arn:aws:logs:us-west-2:123456789012:log-group:/nimbus/dev:*")
+	expected := `"Resource":["arn:aws:logs:us-west-2:123456789012:log-group:/nimbus/dev","arn:aws:logs:us-west-2:123456789012:log-group:/nimbus/dev:*"]`
+	if !strings.Contains(policy, expected) {
+		t.Errorf("expected the policy to contain %s, got %s", expected, policy)
+	}
+}
+
+type fakeLogs struct {
+	logs.SDKLogsOps
+	groups    map[string]*cloudwatchlogstypes.LogGroup
+	events    []cloudwatchlogstypes.FilteredLogEvent
+	created   int
+	pageSize  int
+	lastInput cloudwatchlogs.FilterLogEventsInput
+}
+
+func (f *fakeLogs) DescribeLogGroups(_ context.Context, input *cloudwatchlogs.DescribeLogGroupsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.DescribeLogGroupsOutput, error) {
+	var out cloudwatchlogs.DescribeLogGroupsOutput
+	for name, group := range f.groups {
+		if strings.HasPrefix(name, aws.ToString(input.LogGroupNamePrefix)) {
+			out.LogGroups = append(out.LogGroups, *group)
+		}
+	}
+	return &out, nil
+}
+
+func (f *fakeLogs) CreateLogGroup(_ context.Context, input *cloudwatchlogs.CreateLogGroupInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.CreateLogGroupOutput, error) {
+	name := aws.ToString(input.LogGroupName)
+	if _, ok := f.groups[name]; ok {
+		return nil, &smithy.GenericAPIError{Code: "ResourceAlreadyExistsException"}
+	}
+	f.created++
+	f.groups[name] = &cloudwatchlogstypes.LogGroup{LogGroupName: input.LogGroupName, Arn: aws.String("arn:aws:logs:us-west-2:123456789012:log-group:" + name + ":*")}
+	return &cloudwatchlogs.CreateLogGroupOutput{}, nil
+}
+
+func (f *fakeLogs) PutRetentionPolicy(_ context.Context, input *cloudwatchlogs.PutRetentionPolicyInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.PutRetentionPolicyOutput, error) {
+	f.groups[aws.ToString(input.LogGroupName)].RetentionInDays = input.RetentionInDays
+	return &cloudwatchlogs.PutRetentionPolicyOutput{}, nil
+}
+
+func (f *fakeLogs) FilterLogEvents(_ context.Context, input *cloudwatchlogs.FilterLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	f.lastInput = *input
+	var out cloudwatchlogs.FilterLogEventsOutput
+	start := len(aws.ToString(input.NextToken))
+	end := min(start+f.pageSize, len(f.events))
+	out.Events = f.events[start:end]
+	if end < len(f.events) {
+		out.NextToken = aws.String(strings.Repeat("x", end))
+	}
+	return &out, nil
+}
+
+func TestEnsure(t *testing.T) {
+	t.Run("creates the log group with the retention", func(t *testing.T) {
+		logsAPI := &fakeLogs{groups: map[string]*cloudwatchlogstypes.LogGroup{"/nimbus/dev-old": {LogGroupName: aws.String("/nimbus/dev-old")}}}
+		group, err := logs.NewWatcher(logsAPI).Ensure(context.Background(), logs.Destination{LogGroup: "/nimbus/dev", RetentionDays: 14}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if logsAPI.created != 1 || group.LogGroupName != "/nimbus/dev" || group.RetentionInDays != 14 || group.Arn == "" {
+			t.Errorf("expected the log group to be created with a retention of 14 days, got %+v", group)
+		}
+	})
+	t.Run("reuses the log group", func(t *testing.T) {
+		logsAPI := &fakeLogs{groups: map[string]*cloudwatchlogstypes.LogGroup{"/nimbus/dev": {LogGroupName: aws.String("/nimbus/dev"), Arn: aws.String("arn")}}}
+		group, err := logs.NewWatcher(logsAPI).Ensure(context.Background(), logs.Destination{LogGroup: "/nimbus/dev", RetentionDays: 14}, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if logsAPI.created != 0 || group.RetentionInDays != 0 {
+			t.Errorf("expected the existing log group to be unchanged, got %+v", group)
+		}
+	})
+}
+
+func TestEvents(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	logsAPI := &fakeLogs{pageSize: 2, events: []cloudwatchlogstypes.FilteredLogEvent{
+		{EventId: aws.String("1"), LogStreamName: aws.String("default/web/i-1/cloud-init-output"), Timestamp: aws.Int64(start.Add(2 * time.Second).UnixMilli()), Message: aws.String("second\n")},
+		{EventId: aws.String("2"), LogStreamName: aws.String("default/web/i-2/job"), Timestamp: aws.Int64(start.Add(time.Second).UnixMilli()), Message: aws.String("first")},
+		{EventId: aws.String("3"), LogStreamName: aws.String("default/web/i-1/nimbus"), Timestamp: aws.Int64(start.Add(3 * time.Second).UnixMilli()), Message: aws.String("third")},
+	}}
+	events, err := logs.NewWatcher(logsAPI).Events(context.Background(), "/nimbus/dev", logs.StreamPrefix("default", "web"), start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if aws.ToString(logsAPI.lastInput.LogStreamNamePrefix) != "default/web/" || aws.ToInt64(logsAPI.lastInput.StartTime) != start.UnixMilli() {
+		t.Errorf("expected the events of default/web/ since %d, got %+v", start.UnixMilli(), logsAPI.lastInput)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events across pages, got %d", len(events))
+	}
+	for i, expected := range []logs.Event{
+		{InstanceID: "i-2", Source: logs.SourceJob, Message: "first"},
+		{InstanceID: "i-1", Source: logs.SourceCloudInit, Message: "second"},
+		{InstanceID: "i-1", Source: logs.SourceNimbus, Message: "third"},
+	} {
+		if events[i].InstanceID != expected.InstanceID || events[i].Source != expected.Source || events[i].Message != expected.Message {
+			t.Errorf("expected event %d to be %+v, got %+v", i, expected, events[i])
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cloudwatchtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/samber/lo"
+)
+
+const (
+	ec2Namespace         = "AWS/EC2"
+	metricCPUUtilization = "CPUUtilization"
+	metricNetworkIn      = "NetworkIn"
+	metricNetworkOut     = "NetworkOut"
+
+	// maxDatapoints is the maximum number of datapoints a GetMetricStatistics call returns
+	maxDatapoints = 1440
+	// maxMetricData is the maximum number of datapoints a PutMetricData call accepts
+	maxMetricData = 1000
+	// minPeriod is the granularity of EC2 basic monitoring
+	minPeriod = 5 * time.Minute
+	// CloudWatch aggregates datapoints older than 15 days to 5 minutes and older than 63 days to 1 hour,
+	// so windows that reach back further need periods that are multiples of those
+	fiveMinuteRetention = 15 * 24 * time.Hour
+	hourlyRetention     = 63 * 24 * time.Hour
+)
+
+// Watcher reads the CloudWatch metrics of EC2 instances
+type Watcher struct {
+	cloudWatchAPI SDKCloudWatchOps
+}
+
+// SDKCloudWatchOps is an interface that combines the necessary CloudWatch SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKCloudWatchOps interface {
+	GetMetricStatistics(context.Context, *cloudwatch.GetMetricStatisticsInput, ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricStatisticsOutput, error)
+	PutMetricData(context.Context, *cloudwatch.PutMetricDataInput, ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error)
+}
+
+// Dimension is a name/value pair that is part of the identity of a metric, e.g. InstanceId=i-0123456
+type Dimension struct {
+	Name  string
+	Value string
+}
+
+// Selector is a struct that represents an instance's metrics over a time window
+type Selector struct {
+	InstanceID string
+	StartTime  time.Time
+	EndTime    time.Time
+}
+
+// Utilization summarizes the CPU and network usage of an instance over a time window
+type Utilization struct {
+	InstanceID string
+	// CPUAverage and CPUMaximum are percentages of the instance's vCPUs
+	CPUAverage float64
+	CPUMaximum float64
+	// NetworkAverage is the average of bytes received and sent per second
+	NetworkAverage float64
+	// Datapoints is the number of CPU datapoints, it is 0 if the instance has no metrics in the window
+	Datapoints int
+}
+
+// Duration is a duration that is published as a custom metric in seconds
+type Duration struct {
+	MetricName string
+	Dimensions []Dimension
+	Value      time.Duration
+	Timestamp  time.Time
+}
+
+// NewWatcher creates a new CloudWatch metrics Watcher
+func NewWatcher(cloudWatchAPI SDKCloudWatchOps) Watcher {
+	return Watcher{
+		cloudWatchAPI: cloudWatchAPI,
+	}
+}
+
+// Resolve returns the utilization of every selected instance over its selector's time window
+// Three calls to CloudWatch are sent per selector, one for CPU and one for each network direction.
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Utilization, error) {
+	var utilizations []Utilization
+	for _, selector := range selectors {
+		period := Period(selector.EndTime.Sub(selector.StartTime))
+		cpu, err := w.datapoints(ctx, selector, metricCPUUtilization, period, cloudwatchtypes.StatisticAverage, cloudwatchtypes.StatisticMaximum)
+		if err != nil {
+			return nil, err
+		}
+		var network []cloudwatchtypes.Datapoint
+		for _, metricName := range []string{metricNetworkIn, metricNetworkOut} {
+			datapoints, err := w.datapoints(ctx, selector, metricName, period, cloudwatchtypes.StatisticSum)
+			if err != nil {
+				return nil, err
+			}
+			network = append(network, datapoints...)
+		}
+		utilization := Summarize(cpu, network, period)
+		utilization.InstanceID = selector.InstanceID
+		utilizations = append(utilizations, utilization)
+	}
+	return utilizations, nil
+}
+
+// PutDurations publishes the durations as custom metrics of the namespace with the Seconds unit
+// One call to CloudWatch is sent per 1000 durations.
+func (w Watcher) PutDurations(ctx context.Context, namespace string, durations []Duration) error {
+	metricData := lo.Map(durations, func(duration Duration, _ int) cloudwatchtypes.MetricDatum {
+		return cloudwatchtypes.MetricDatum{
+			MetricName: aws.String(duration.MetricName),
+			Dimensions: sdkDimensions(duration.Dimensions),
+			Value:      aws.Float64(duration.Value.Seconds()),
+			Unit:       cloudwatchtypes.StandardUnitSeconds,
+			Timestamp:  lo.Ternary(duration.Timestamp.IsZero(), nil, aws.Time(duration.Timestamp)),
+		}
+	})
+	for _, chunk := range lo.Chunk(metricData, maxMetricData) {
+		if _, err := w.cloudWatchAPI.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{Namespace: aws.String(namespace), MetricData: chunk}); err != nil {
+			return fmt.Errorf("failed to put metrics to namespace %s: %w", namespace, err)
+		}
+	}
+	return nil
+}
+
+func (w Watcher) datapoints(ctx context.Context, selector Selector, metricName string, period time.Duration, statistics ...cloudwatchtypes.Statistic) ([]cloudwatchtypes.Datapoint, error) {
+	out, err := w.cloudWatchAPI.GetMetricStatistics(ctx, &cloudwatch.GetMetricStatisticsInput{
+		Namespace:  aws.String(ec2Namespace),
+		MetricName: aws.String(metricName),
+		Dimensions: sdkDimensions([]Dimension{{Name: "InstanceId", Value: selector.InstanceID}}),
+		StartTime:  aws.Time(selector.StartTime),
+		EndTime:    aws.Time(selector.EndTime),
+		Period:     aws.Int32(int32(period.Seconds())),
+		Statistics: statistics,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s of instance %s: %w", metricName, selector.InstanceID, err)
+	}
+	return out.Datapoints, nil
+}
+
+func sdkDimensions(dimensions []Dimension) []cloudwatchtypes.Dimension {
+	return lo.Map(dimensions, func(dimension Dimension, _ int) cloudwatchtypes.Dimension {
+		return cloudwatchtypes.Dimension{Name: aws.String(dimension.Name), Value: aws.String(dimension.Value)}
+	})
+}
+
+// Period returns the smallest period, at least the basic monitoring granularity, that covers the window in a single call
+// and is a multiple of the granularity CloudWatch retains for the oldest datapoints of the window.
+func Period(window time.Duration) time.Duration {
+	granularity := time.Minute
+	switch {
+	case window > hourlyRetention:
+		granularity = time.Hour
+	case window > fiveMinuteRetention:
+		granularity = 5 * time.Minute
+	}
+	period := (window/maxDatapoints + granularity - 1).Truncate(granularity)
+	return max(period, minPeriod)
+}
+
+// Summarize computes the utilization from CPU datapoints with Average and Maximum statistics and network datapoints with the Sum statistic
+func Summarize(cpu, network []cloudwatchtypes.Datapoint, period time.Duration) Utilization {
+	if len(cpu) == 0 {
+		return Utilization{}
+	}
+	utilization := Utilization{
+		CPUAverage: lo.SumBy(cpu, func(datapoint cloudwatchtypes.Datapoint) float64 { return lo.FromPtr(datapoint.Average) }) / float64(len(cpu)),
+		CPUMaximum: lo.Max(lo.Map(cpu, func(datapoint cloudwatchtypes.Datapoint, _ int) float64 { return lo.FromPtr(datapoint.Maximum) })),
+		Datapoints: len(cpu),
+	}
+	// network datapoints are bytes per period for each direction, the CPU datapoints count how many periods were observed
+	utilization.NetworkAverage = lo.SumBy(network, func(datapoint cloudwatchtypes.Datapoint) float64 { return lo.FromPtr(datapoint.Sum) }) / (float64(len(cpu)) * period.Seconds())
+	return utilization
+}
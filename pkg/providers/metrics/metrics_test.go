@@ -0,0 +1,131 @@
+package metrics_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	cloudwatchtypes "github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/bwagner5/nimbus/pkg/providers/metrics"
+)
+
+func TestPeriod(t *testing.T) {
+	type testCases struct {
+		name     string
+		window   time.Duration
+		expected time.Duration
+	}
+
+	for _, tc := range []testCases{
+		{name: "short window uses basic monitoring granularity", window: time.Hour, expected: 5 * time.Minute},
+		{name: "week", window: 7 * 24 * time.Hour, expected: 7 * time.Minute},
+		{name: "partial minutes round up", window: 8*24*time.Hour + time.Hour, expected: 9 * time.Minute},
+		{name: "older than 15 days rounds to 5 minutes", window: 16*24*time.Hour + time.Hour, expected: 20 * time.Minute},
+		{name: "older than 63 days rounds to hours", window: 90 * 24 * time.Hour, expected: 2 * time.Hour},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if period := metrics.Period(tc.window); period != tc.expected {
+				t.Errorf("expected period %s, got %s", tc.expected, period)
+			}
+		})
+	}
+}
+
+type fakeCloudWatch struct {
+	datapoints map[string][]cloudwatchtypes.Datapoint
+	puts       *[]*cloudwatch.PutMetricDataInput
+}
+
+func (f fakeCloudWatch) GetMetricStatistics(_ context.Context, input *cloudwatch.GetMetricStatisticsInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.GetMetricStatisticsOutput, error) {
+	return &cloudwatch.GetMetricStatisticsOutput{Datapoints: f.datapoints[*input.MetricName]}, nil
+}
+
+func (f fakeCloudWatch) PutMetricData(_ context.Context, input *cloudwatch.PutMetricDataInput, _ ...func(*cloudwatch.Options)) (*cloudwatch.PutMetricDataOutput, error) {
+	*f.puts = append(*f.puts, input)
+	return &cloudwatch.PutMetricDataOutput{}, nil
+}
+
+func TestResolve(t *testing.T) {
+	type testCases struct {
+		name     string
+		cloud    fakeCloudWatch
+		expected metrics.Utilization
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:     "no datapoints",
+			cloud:    fakeCloudWatch{},
+			expected: metrics.Utilization{InstanceID: "i-123"},
+		},
+		{
+			name: "averages cpu and network",
+			cloud: fakeCloudWatch{datapoints: map[string][]cloudwatchtypes.Datapoint{
+				"CPUUtilization": {{Average: aws.Float64(2), Maximum: aws.Float64(10)}, {Average: aws.Float64(4), Maximum: aws.Float64(30)}},
+				"NetworkIn":      {{Sum: aws.Float64(300)}, {Sum: aws.Float64(300)}},
+				"NetworkOut":     {{Sum: aws.Float64(600)}},
+			}},
+			// 1200 bytes over 2 five minute periods
+			expected: metrics.Utilization{InstanceID: "i-123", CPUAverage: 3, CPUMaximum: 30, NetworkAverage: 2, Datapoints: 2},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			end := time.Now()
+			utilizations, err := metrics.NewWatcher(tc.cloud).Resolve(context.Background(), []metrics.Selector{
+				{InstanceID: "i-123", StartTime: end.Add(-time.Hour), EndTime: end},
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(utilizations) != 1 {
+				t.Fatalf("expected 1 utilization, got %d", len(utilizations))
+			}
+			got := utilizations[0]
+			if got.InstanceID != tc.expected.InstanceID || got.Datapoints != tc.expected.Datapoints || got.CPUMaximum != tc.expected.CPUMaximum ||
+				math.Abs(got.CPUAverage-tc.expected.CPUAverage) > 1e-9 || math.Abs(got.NetworkAverage-tc.expected.NetworkAverage) > 1e-9 {
+				t.Errorf("expected %+v, got %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPutDurations(t *testing.T) {
+	type testCases struct {
+		name          string
+		durations     int
+		expectedCalls int
+	}
+
+	for _, tc := range []testCases{
+		{name: "no durations", durations: 0, expectedCalls: 0},
+		{name: "single call", durations: 3, expectedCalls: 1},
+		{name: "batches of 1000", durations: 2500, expectedCalls: 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var puts []*cloudwatch.PutMetricDataInput
+			durations := make([]metrics.Duration, tc.durations)
+			for i := range durations {
+				durations[i] = metrics.Duration{MetricName: "LaunchDuration", Value: 1500 * time.Millisecond}
+			}
+			if err := metrics.NewWatcher(fakeCloudWatch{puts: &puts}).PutDurations(context.Background(), "nimbus", durations); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(puts) != tc.expectedCalls {
+				t.Fatalf("expected %d calls, got %d", tc.expectedCalls, len(puts))
+			}
+			for _, put := range puts {
+				if *put.Namespace != "nimbus" {
+					t.Errorf("expected namespace nimbus, got %s", *put.Namespace)
+				}
+				for _, datum := range put.MetricData {
+					if *datum.Value != 1.5 || datum.Unit != cloudwatchtypes.StandardUnitSeconds {
+						t.Errorf("expected 1.5 Seconds, got %v %s", *datum.Value, datum.Unit)
+					}
+				}
+			}
+		})
+	}
+}
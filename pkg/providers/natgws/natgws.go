@@ -10,7 +10,9 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
@@ -26,6 +28,7 @@ type SDKIGWOps interface {
 	ec2.DescribeNatGatewaysAPIClient
 	CreateNatGateway(context.Context, *ec2.CreateNatGatewayInput, ...func(*ec2.Options)) (*ec2.CreateNatGatewayOutput, error)
 	AllocateAddress(context.Context, *ec2.AllocateAddressInput, ...func(*ec2.Options)) (*ec2.AllocateAddressOutput, error)
+	DeleteNatGateway(context.Context, *ec2.DeleteNatGatewayInput, ...func(*ec2.Options)) (*ec2.DeleteNatGatewayOutput, error)
 }
 
 // Selector is a struct that represents a NAT Gateway selector
@@ -94,23 +97,31 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]NATGatewa
 	return natgws, nil
 }
 
-func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList []subnets.Subnet) (*NATGateway, error) {
-	privateSubnets := lo.Filter(subnetsList, func(subnet subnets.Subnet, _ int) bool { return !*subnet.MapPublicIpOnLaunch })
+// Create creates a NAT Gateway with a new Elastic IP in the first public subnet for the private subnets of subnetsList to reach the internet through,
+// and waits until it is available. Both are tagged with the namespaced tags and the additional tags.
+// No NAT Gateway is created if subnetsList has no private subnets.
+func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList []subnets.Subnet, tags map[string]string) (*NATGateway, error) {
+	privateSubnets := lo.Reject(subnetsList, func(subnet subnets.Subnet, _ int) bool { return subnet.IsPublic() })
 	// do not create a NATGW if there are no private subnets
 	if len(privateSubnets) == 0 {
 		return nil, nil
 	}
-	publicSubnets := lo.Filter(subnetsList, func(subnet subnets.Subnet, _ int) bool { return *subnet.MapPublicIpOnLaunch })
+	// NAT Gateways translate to the IPv4 address of their Elastic IP, so they can not be created in IPv6-only subnets
+	publicSubnets := lo.Filter(subnetsList, func(subnet subnets.Subnet, _ int) bool { return subnet.IsPublic() && subnet.CidrBlock != nil })
+	if len(publicSubnets) == 0 {
+		return nil, fmt.Errorf("no public IPv4 subnet to create a NAT Gateway in")
+	}
+	ec2Tags := tagutils.MapToEC2Tags(lo.Assign(tags, tagutils.NamespacedTags(namespace, name)))
 	eipOut, err := w.ec2API.AllocateAddress(ctx, &ec2.AllocateAddressInput{
 		TagSpecifications: []types.TagSpecification{
 			{
 				ResourceType: types.ResourceTypeElasticIp,
-				Tags:         tagutils.EC2NamespacedTags(namespace, name),
+				Tags:         ec2Tags,
 			},
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to allocate an Elastic IP for the NAT Gateway: %w", err)
 	}
 	natGWOut, err := w.ec2API.CreateNatGateway(ctx, &ec2.CreateNatGatewayInput{
 		AllocationId: eipOut.AllocationId,
@@ -118,20 +129,47 @@ func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList
 		TagSpecifications: []types.TagSpecification{
 			{
 				ResourceType: types.ResourceTypeNatgateway,
-				Tags:         tagutils.EC2NamespacedTags(namespace, name),
+				Tags:         ec2Tags,
 			},
 		},
 	})
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to create NAT Gateway: %w", err)
 	}
 	waiter := ec2.NewNatGatewayAvailableWaiter(w.ec2API)
 	if err := waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{*natGWOut.NatGateway.NatGatewayId}}, 5*time.Minute); err != nil {
-		return &NATGateway{*natGWOut.NatGateway}, err
+		return &NATGateway{*natGWOut.NatGateway}, fmt.Errorf("failed waiting for NAT Gateway %s to be available: %w", *natGWOut.NatGateway.NatGatewayId, err)
 	}
 	return &NATGateway{*natGWOut.NatGateway}, nil
 }
 
+// Delete deletes the NAT Gateway and waits until it is deleted, which is when its Elastic IP is disassociated and can be released
+func (w Watcher) Delete(ctx context.Context, natGatewayID string) error {
+	if err := retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.ec2API.DeleteNatGateway(ctx, &ec2.DeleteNatGatewayInput{NatGatewayId: aws.String(natGatewayID)})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to delete NAT Gateway %s: %w", natGatewayID, err)
+	}
+	waiter := ec2.NewNatGatewayDeletedWaiter(w.ec2API)
+	if err := waiter.Wait(ctx, &ec2.DescribeNatGatewaysInput{NatGatewayIds: []string{natGatewayID}}, 10*time.Minute); err != nil {
+		return fmt.Errorf("failed waiting for NAT Gateway %s to be deleted: %w", natGatewayID, err)
+	}
+	return nil
+}
+
+// IsDeleted returns true if the NAT Gateway is deleted or being deleted
+func (n NATGateway) IsDeleted() bool {
+	return n.State == ec2types.NatGatewayStateDeleting || n.State == ec2types.NatGatewayStateDeleted
+}
+
+// AllocationIDs returns the allocation IDs of the NAT Gateway's Elastic IPs
+func (n NATGateway) AllocationIDs() []string {
+	return lo.FilterMap(n.NatGatewayAddresses, func(address ec2types.NatGatewayAddress, _ int) (string, bool) {
+		return lo.FromPtr(address.AllocationId), address.AllocationId != nil
+	})
+}
+
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
 // Each filter is executed as a separate list call.
 // Terms within a Selector are AND'd and between Selectors are OR'd
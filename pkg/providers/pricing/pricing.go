@@ -0,0 +1,211 @@
+package pricing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	awspricing "github.com/aws/aws-sdk-go-v2/service/pricing"
+	pricingtypes "github.com/aws/aws-sdk-go-v2/service/pricing/types"
+	"github.com/samber/lo"
+)
+
+const (
+	// spotProductDescription is the spot product of Linux instances in a default VPC or any other VPC
+	spotProductDescription = "Linux/UNIX"
+	// maxSpotInstanceTypes is the number of instance types that are looked up per DescribeSpotPriceHistory call
+	maxSpotInstanceTypes = 100
+)
+
+// Watcher discovers the on-demand and spot prices of instance types
+type Watcher struct {
+	region     string
+	pricingAPI SDKPricingOps
+	spotAPI    SDKSpotPriceOps
+}
+
+// SDKPricingOps is an interface that combines the necessary Pricing SDK client interfaces
+type SDKPricingOps interface {
+	GetProducts(context.Context, *awspricing.GetProductsInput, ...func(*awspricing.Options)) (*awspricing.GetProductsOutput, error)
+}
+
+// SDKSpotPriceOps is an interface that combines the necessary EC2 SDK client interfaces
+type SDKSpotPriceOps interface {
+	DescribeSpotPriceHistory(context.Context, *ec2.DescribeSpotPriceHistoryInput, ...func(*ec2.Options)) (*ec2.DescribeSpotPriceHistoryOutput, error)
+}
+
+// SpotPrices are the current hourly spot prices of instance types by instance type and availability zone
+type SpotPrices map[string]map[string]float64
+
+// NewWatcher creates a new Pricing Watcher for the region.
+// The pricing API client must be created for the pricing endpoint region of the partition, see Region.
+func NewWatcher(region string, pricingAPI SDKPricingOps, spotAPI SDKSpotPriceOps) Watcher {
+	return Watcher{
+		region:     region,
+		pricingAPI: pricingAPI,
+		spotAPI:    spotAPI,
+	}
+}
+
+// Region returns the region of the Pricing API endpoint that serves the prices of the region.
+// The Pricing API is only available in a few regions, but each endpoint serves the prices of every region in its partition.
+func Region(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "cn-northwest-1"
+	}
+	return "us-east-1"
+}
+
+// OnDemand returns the hourly on-demand prices of the instance types in the watcher's region from the Pricing API.
+// Prices are for Linux with shared tenancy and no pre-installed software. Instance types without a price are left out.
+func (w Watcher) OnDemand(ctx context.Context, instanceTypes []string) (map[string]float64, error) {
+	prices := map[string]float64{}
+	for _, instanceType := range lo.Uniq(instanceTypes) {
+		out, err := w.pricingAPI.GetProducts(ctx, &awspricing.GetProductsInput{
+			ServiceCode: aws.String("AmazonEC2"),
+			Filters:     onDemandFilters(w.region, instanceType),
+			MaxResults:  aws.Int32(1),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get on-demand price of %s: %w", instanceType, err)
+		}
+		if len(out.PriceList) == 0 {
+			continue
+		}
+		price, err := ParseOnDemandPrice(out.PriceList[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get on-demand price of %s: %w", instanceType, err)
+		}
+		prices[instanceType] = price
+	}
+	return prices, nil
+}
+
+// Spot returns the current hourly Linux spot prices of the instance types in every availability zone they are offered in
+func (w Watcher) Spot(ctx context.Context, instanceTypes []string) (SpotPrices, error) {
+	prices := SpotPrices{}
+	// a start time of now only returns the current price of each instance type and availability zone
+	startTime := time.Now()
+	for _, chunk := range lo.Chunk(lo.Uniq(instanceTypes), maxSpotInstanceTypes) {
+		timestamps := map[string]time.Time{}
+		paginator := ec2.NewDescribeSpotPriceHistoryPaginator(w.spotAPI, &ec2.DescribeSpotPriceHistoryInput{
+			InstanceTypes:       lo.Map(chunk, func(instanceType string, _ int) ec2types.InstanceType { return ec2types.InstanceType(instanceType) }),
+			ProductDescriptions: []string{spotProductDescription},
+			StartTime:           aws.Time(startTime),
+		})
+		for paginator.HasMorePages() {
+			out, err := paginator.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get spot prices: %w", err)
+			}
+			for _, spotPrice := range out.SpotPriceHistory {
+				price, err := strconv.ParseFloat(lo.FromPtr(spotPrice.SpotPrice), 64)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse spot price %q of %s: %w", lo.FromPtr(spotPrice.SpotPrice), spotPrice.InstanceType, err)
+				}
+				instanceType, zone := string(spotPrice.InstanceType), lo.FromPtr(spotPrice.AvailabilityZone)
+				// keep the latest price if the history has more than one price of the instance type and zone
+				key := instanceType + "/" + zone
+				if timestamp, ok := timestamps[key]; ok && timestamp.After(lo.FromPtr(spotPrice.Timestamp)) {
+					continue
+				}
+				timestamps[key] = lo.FromPtr(spotPrice.Timestamp)
+				if prices[instanceType] == nil {
+					prices[instanceType] = map[string]float64{}
+				}
+				prices[instanceType][zone] = price
+			}
+		}
+	}
+	return prices, nil
+}
+
+// EBS returns the monthly on-demand storage prices per GiB of the EBS volume types in the watcher's region.
+// Volume types without a price are left out.
+func (w Watcher) EBS(ctx context.Context, volumeTypes []string) (map[string]float64, error) {
+	prices := map[string]float64{}
+	for _, volumeType := range lo.Uniq(volumeTypes) {
+		out, err := w.pricingAPI.GetProducts(ctx, &awspricing.GetProductsInput{
+			ServiceCode: aws.String("AmazonEC2"),
+			Filters:     ebsFilters(w.region, volumeType),
+			MaxResults:  aws.Int32(1),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get EBS price of %s: %w", volumeType, err)
+		}
+		if len(out.PriceList) == 0 {
+			continue
+		}
+		price, err := ParseOnDemandPrice(out.PriceList[0])
+		if err != nil {
+			return nil, fmt.Errorf("failed to get EBS price of %s: %w", volumeType, err)
+		}
+		prices[volumeType] = price
+	}
+	return prices, nil
+}
+
+// ParseOnDemandPrice parses the price per unit of a Pricing API product price list, i.e. hourly for instances and per GB-month for EBS volumes
+func ParseOnDemandPrice(priceList string) (float64, error) {
+	var product struct {
+		Terms struct {
+			OnDemand map[string]struct {
+				PriceDimensions map[string]struct {
+					PricePerUnit map[string]string `json:"pricePerUnit"`
+				} `json:"priceDimensions"`
+			} `json:"OnDemand"`
+		} `json:"terms"`
+	}
+	if err := json.Unmarshal([]byte(priceList), &product); err != nil {
+		return 0, fmt.Errorf("failed to parse price list: %w", err)
+	}
+	for _, term := range product.Terms.OnDemand {
+		for _, dimension := range term.PriceDimensions {
+			// prices are in USD, except in the China regions where they are in CNY
+			price, ok := dimension.PricePerUnit["USD"]
+			if !ok {
+				price, ok = dimension.PricePerUnit["CNY"]
+			}
+			if !ok {
+				continue
+			}
+			return strconv.ParseFloat(price, 64)
+		}
+	}
+	return 0, fmt.Errorf("price list has no on-demand price")
+}
+
+// onDemandFilters select the on-demand product of Linux instances of the instance type with shared tenancy in the region
+func onDemandFilters(region, instanceType string) []pricingtypes.Filter {
+	return termMatchFilters(map[string]string{
+		"regionCode":      region,
+		"instanceType":    instanceType,
+		"operatingSystem": "Linux",
+		"tenancy":         "Shared",
+		"preInstalledSw":  "NA",
+		"capacitystatus":  "Used",
+		"licenseModel":    "No License required",
+	})
+}
+
+// ebsFilters select the storage product of the EBS volume type in the region
+func ebsFilters(region, volumeType string) []pricingtypes.Filter {
+	return termMatchFilters(map[string]string{
+		"regionCode":    region,
+		"productFamily": "Storage",
+		"volumeApiName": volumeType,
+	})
+}
+
+// termMatchFilters converts product attributes into filters that match products with exactly those attribute values
+func termMatchFilters(attributes map[string]string) []pricingtypes.Filter {
+	return lo.MapToSlice(attributes, func(field, value string) pricingtypes.Filter {
+		return pricingtypes.Filter{Type: pricingtypes.FilterTypeTermMatch, Field: aws.String(field), Value: aws.String(value)}
+	})
+}
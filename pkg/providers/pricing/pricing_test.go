@@ -0,0 +1,137 @@
+package pricing_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	awspricing "github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/bwagner5/nimbus/pkg/providers/pricing"
+)
+
+func TestParseOnDemandPrice(t *testing.T) {
+	testCases := []struct {
+		name        string
+		priceList   string
+		expected    float64
+		expectError bool
+	}{
+		{
+			name:      "USD",
+			priceList: `{"product":{"attributes":{"instanceType":"m7g.large"}},"terms":{"OnDemand":{"ABC.JRTCKXETXF":{"priceDimensions":{"ABC.JRTCKXETXF.6YS6EN2CT7":{"unit":"Hrs","pricePerUnit":{"USD":"0.0816000000"}}}}}}}`,
+			expected:  0.0816,
+		},
+		{
+			name:      "CNY",
+			priceList: `{"terms":{"OnDemand":{"ABC.JRTCKXETXF":{"priceDimensions":{"ABC.JRTCKXETXF.6YS6EN2CT7":{"unit":"Hrs","pricePerUnit":{"CNY":"0.6350000000"}}}}}}}`,
+			expected:  0.635,
+		},
+		{
+			name:        "no on-demand terms",
+			priceList:   `{"terms":{"Reserved":{}}}`,
+			expectError: true,
+		},
+		{
+			name:        "invalid JSON",
+			priceList:   `{`,
+			expectError: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			price, err := pricing.ParseOnDemandPrice(tc.priceList)
+			if tc.expectError {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if price != tc.expected {
+				t.Errorf("expected %v, got %v", tc.expected, price)
+			}
+		})
+	}
+}
+
+func TestRegion(t *testing.T) {
+	for region, expected := range map[string]string{
+		"us-west-2":      "us-east-1",
+		"eu-central-1":   "us-east-1",
+		"cn-north-1":     "cn-northwest-1",
+		"cn-northwest-1": "cn-northwest-1",
+	} {
+		if got := pricing.Region(region); got != expected {
+			t.Errorf("expected the pricing region of %s to be %s, got %s", region, expected, got)
+		}
+	}
+}
+
+type fakeSpotPrices struct {
+	history []ec2types.SpotPrice
+}
+
+func (f fakeSpotPrices) DescribeSpotPriceHistory(context.Context, *ec2.DescribeSpotPriceHistoryInput, ...func(*ec2.Options)) (*ec2.DescribeSpotPriceHistoryOutput, error) {
+	return &ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: f.history}, nil
+}
+
+type fakePricing struct {
+	priceLists map[string]string
+}
+
+// GetProducts returns the price list of the product whose instanceType or volumeApiName filter matches
+func (f fakePricing) GetProducts(_ context.Context, input *awspricing.GetProductsInput, _ ...func(*awspricing.Options)) (*awspricing.GetProductsOutput, error) {
+	for _, filter := range input.Filters {
+		if priceList, ok := f.priceLists[aws.ToString(filter.Value)]; ok && (aws.ToString(filter.Field) == "instanceType" || aws.ToString(filter.Field) == "volumeApiName") {
+			return &awspricing.GetProductsOutput{PriceList: []string{priceList}}, nil
+		}
+	}
+	return &awspricing.GetProductsOutput{}, nil
+}
+
+func TestSpot(t *testing.T) {
+	now := time.Now()
+	watcher := pricing.NewWatcher("us-west-2", fakePricing{}, fakeSpotPrices{history: []ec2types.SpotPrice{
+		{InstanceType: "m7g.large", AvailabilityZone: aws.String("us-west-2a"), SpotPrice: aws.String("0.0300"), Timestamp: aws.Time(now)},
+		{InstanceType: "m7g.large", AvailabilityZone: aws.String("us-west-2a"), SpotPrice: aws.String("0.0400"), Timestamp: aws.Time(now.Add(-time.Hour))},
+		{InstanceType: "m7g.large", AvailabilityZone: aws.String("us-west-2b"), SpotPrice: aws.String("0.0350"), Timestamp: aws.Time(now)},
+	}})
+	prices, err := watcher.Spot(context.Background(), []string{"m7g.large"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prices["m7g.large"]) != 2 {
+		t.Fatalf("expected spot prices in 2 availability zones, got %v", prices)
+	}
+	if prices["m7g.large"]["us-west-2a"] != 0.03 {
+		t.Errorf("expected the latest spot price of us-west-2a to be 0.03, got %v", prices["m7g.large"]["us-west-2a"])
+	}
+	if prices["m7g.large"]["us-west-2b"] != 0.035 {
+		t.Errorf("expected the spot price of us-west-2b to be 0.035, got %v", prices["m7g.large"]["us-west-2b"])
+	}
+	onDemand, err := watcher.OnDemand(context.Background(), []string{"m7g.large"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(onDemand) != 0 {
+		t.Errorf("expected no on-demand prices without a price list, got %v", onDemand)
+	}
+}
+
+func TestEBS(t *testing.T) {
+	watcher := pricing.NewWatcher("us-west-2", fakePricing{priceLists: map[string]string{
+		"gp3": `{"terms":{"OnDemand":{"ABC.JRTCKXETXF":{"priceDimensions":{"ABC.JRTCKXETXF.6YS6EN2CT7":{"unit":"GB-Mo","pricePerUnit":{"USD":"0.0800000000"}}}}}}}`,
+	}}, fakeSpotPrices{})
+	prices, err := watcher.EBS(context.Background(), []string{"gp3", "gp3", "io2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(prices) != 1 || prices["gp3"] != 0.08 {
+		t.Errorf("expected only a gp3 price of 0.08, got %v", prices)
+	}
+}
@@ -0,0 +1,205 @@
+package reservations
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/samber/lo"
+)
+
+// normalizationFactors are the units that size flexible reserved instances are normalized to within an instance family
+// https://docs.aws.amazon.com/AWSEC2/latest/UserGuide/apply_ri.html#ri-normalization-factor
+var normalizationFactors = map[string]float64{
+	"nano":   0.25,
+	"micro":  0.5,
+	"small":  1,
+	"medium": 2,
+	"large":  4,
+	"xlarge": 8,
+}
+
+// Watcher discovers reserved instances based on selectors
+type Watcher struct {
+	reservationAPI SDKReservationsOps
+}
+
+// SDKReservationsOps is an interface that combines the necessary EC2 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKReservationsOps interface {
+	DescribeReservedInstances(context.Context, *ec2.DescribeReservedInstancesInput, ...func(*ec2.Options)) (*ec2.DescribeReservedInstancesOutput, error)
+}
+
+// Selector is a struct that represents a reserved instances selector
+type Selector struct {
+	Tags map[string]string
+	ID   string
+	// State is one of: payment-pending | active | payment-failed | retired | queued | queued-deleted
+	State string
+}
+
+// Reservation represents Amazon EC2 reserved instances
+// This is not the AWS SDK ReservedInstances type, but a wrapper around it so that we can add additional data
+type Reservation struct {
+	ec2types.ReservedInstances
+}
+
+// Coverage is the reserved capacity that running instances do not use, so launching into it is already paid for.
+// Only Linux/UNIX reservations with default tenancy are considered, which are the ones that are size flexible.
+// EC2 Instance Savings Plans cover the instance families they commit to. Their utilization is not known when launching,
+// so a family is covered as long as it has a commitment, even if running instances already use all of it.
+type Coverage struct {
+	// Families are the unused normalized units of regional reservations by instance family,
+	// or by instance type for sizes without a normalization factor like metal
+	Families map[string]float64
+	// Zonal is the number of unused zonal reservations by instance type and availability zone
+	Zonal map[string]map[string]int32
+	// SavingsPlans are the hourly commitments of the region's active EC2 Instance Savings Plans by instance family.
+	// Compute Savings Plans apply to every instance type, so they do not make any instance type preferable.
+	SavingsPlans map[string]float64
+}
+
+// NewWatcher creates a new Reservation Watcher
+func NewWatcher(reservationAPI SDKReservationsOps) Watcher {
+	return Watcher{
+		reservationAPI: reservationAPI,
+	}
+}
+
+// Resolve returns a list of reserved instances that match the provided selectors
+// Multiple calls to EC2 may be sent to resolve the selectors
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Reservation, error) {
+	var reservations []Reservation
+	for i, filters := range filterSets(selectors) {
+		// DescribeReservedInstances is not paginated
+		out, err := w.reservationAPI.DescribeReservedInstances(ctx, &ec2.DescribeReservedInstancesInput{
+			Filters:              filters,
+			ReservedInstancesIds: lo.Ternary(selectors[i].ID == "", nil, []string{selectors[i].ID}),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe reserved instances: %w", err)
+		}
+		reservations = append(reservations, lo.Map(out.ReservedInstances, func(sdkReservation ec2types.ReservedInstances, _ int) Reservation {
+			return Reservation{sdkReservation}
+		})...)
+	}
+	return lo.UniqBy(reservations, func(reservation Reservation) string { return lo.FromPtr(reservation.ReservedInstancesId) }), nil
+}
+
+// Coverage returns the capacity of the active reservations that is not used by the running instances.
+// Running instances use zonal reservations of their instance type and availability zone first, then regional reservations of their family.
+func (w Watcher) Coverage(ctx context.Context, runningInstances []instances.Instance) (Coverage, error) {
+	reservations, err := w.Resolve(ctx, []Selector{{State: string(ec2types.ReservedInstanceStateActive)}})
+	if err != nil {
+		return Coverage{}, err
+	}
+	return UnusedCoverage(reservations, runningInstances), nil
+}
+
+// UnusedCoverage subtracts the on-demand running instances from the reservations
+func UnusedCoverage(reservations []Reservation, runningInstances []instances.Instance) Coverage {
+	coverage := Coverage{Families: map[string]float64{}, Zonal: map[string]map[string]int32{}}
+	for _, reservation := range reservations {
+		if !reservation.applies() {
+			continue
+		}
+		instanceType := string(reservation.InstanceType)
+		count := lo.FromPtr(reservation.InstanceCount)
+		if reservation.Scope == ec2types.ScopeAvailabilityZone {
+			if coverage.Zonal[instanceType] == nil {
+				coverage.Zonal[instanceType] = map[string]int32{}
+			}
+			coverage.Zonal[instanceType][lo.FromPtr(reservation.AvailabilityZone)] += count
+			continue
+		}
+		family, units := NormalizedUnits(instanceType)
+		coverage.Families[family] += units * float64(count)
+	}
+	for _, instance := range runningInstances {
+		// reservations only apply to on-demand Linux instances with default tenancy
+		if instance.InstanceLifecycle != "" || instance.Platform != "" || (instance.Placement != nil && instance.Placement.Tenancy != ec2types.TenancyDefault) {
+			continue
+		}
+		instanceType := string(instance.InstanceType)
+		zone := lo.FromPtr(lo.FromPtr(instance.Placement).AvailabilityZone)
+		if coverage.Zonal[instanceType][zone] > 0 {
+			coverage.Zonal[instanceType][zone]--
+			continue
+		}
+		family, units := NormalizedUnits(instanceType)
+		if _, ok := coverage.Families[family]; ok {
+			coverage.Families[family] = max(coverage.Families[family]-units, 0)
+		}
+	}
+	return coverage
+}
+
+// Covers is true when an instance of the instance type launched in the availability zone would be billed at the reserved rate,
+// or at the rate of an EC2 Instance Savings Plan of its family
+func (c Coverage) Covers(instanceType, zone string) bool {
+	if c.Zonal[instanceType][zone] > 0 {
+		return true
+	}
+	if family, _, _ := strings.Cut(instanceType, "."); c.SavingsPlans[family] > 0 {
+		return true
+	}
+	family, units := NormalizedUnits(instanceType)
+	return c.Families[family] >= units
+}
+
+// Empty is true when there is no unused reserved capacity and no EC2 Instance Savings Plans
+func (c Coverage) Empty() bool {
+	return !lo.SomeBy(lo.Values(c.Families), func(units float64) bool { return units > 0 }) &&
+		!lo.SomeBy(lo.Values(c.SavingsPlans), func(commitment float64) bool { return commitment > 0 }) &&
+		!lo.SomeBy(lo.Values(c.Zonal), func(zones map[string]int32) bool {
+			return lo.SomeBy(lo.Values(zones), func(count int32) bool { return count > 0 })
+		})
+}
+
+// NormalizedUnits returns the instance family and normalization factor of the instance type.
+// Sizes without a normalization factor, like metal, are not size flexible and return the instance type itself with 1 unit.
+func NormalizedUnits(instanceType string) (string, float64) {
+	family, size, ok := strings.Cut(instanceType, ".")
+	if !ok {
+		return instanceType, 1
+	}
+	if factor, ok := normalizationFactors[size]; ok {
+		return family, factor
+	}
+	if multiple, ok := strings.CutSuffix(size, "xlarge"); ok {
+		if n, err := strconv.Atoi(multiple); err == nil {
+			return family, float64(n) * normalizationFactors["xlarge"]
+		}
+	}
+	return instanceType, 1
+}
+
+// applies is true for Linux/UNIX reservations with default tenancy, other platforms and tenancies have different matching rules
+func (r Reservation) applies() bool {
+	return strings.HasPrefix(string(r.ProductDescription), "Linux/UNIX") && r.InstanceTenancy == ec2types.TenancyDefault
+}
+
+// filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
+// Each filter is executed as a separate list call.
+// Terms within a Selector are AND'd and between Selectors are OR'd
+func filterSets(selectorList []Selector) [][]ec2types.Filter {
+	var filterResult [][]ec2types.Filter
+	for _, term := range selectorList {
+		filters := []ec2types.Filter{}
+		if term.State != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("state"),
+				Values: []string{term.State},
+			})
+		}
+		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
+		filterResult = append(filterResult, filters)
+	}
+	return filterResult
+}
@@ -0,0 +1,159 @@
+package reservations_test
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/reservations"
+)
+
+func TestNormalizedUnits(t *testing.T) {
+	type testCases struct {
+		name           string
+		instanceType   string
+		expectedFamily string
+		expectedUnits  float64
+	}
+
+	for _, tc := range []testCases{
+		{name: "large", instanceType: "m5.large", expectedFamily: "m5", expectedUnits: 4},
+		{name: "multiple of xlarge", instanceType: "c6g.12xlarge", expectedFamily: "c6g", expectedUnits: 96},
+		{name: "nano", instanceType: "t3.nano", expectedFamily: "t3", expectedUnits: 0.25},
+		{name: "metal is not size flexible", instanceType: "m5.metal", expectedFamily: "m5.metal", expectedUnits: 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			family, units := reservations.NormalizedUnits(tc.instanceType)
+			if family != tc.expectedFamily || units != tc.expectedUnits {
+				t.Errorf("expected %s with %v units, got %s with %v units", tc.expectedFamily, tc.expectedUnits, family, units)
+			}
+		})
+	}
+}
+
+func TestUnusedCoverage(t *testing.T) {
+	regional := func(instanceType string, count int32) reservations.Reservation {
+		return reservations.Reservation{ReservedInstances: ec2types.ReservedInstances{
+			InstanceType:       ec2types.InstanceType(instanceType),
+			InstanceCount:      aws.Int32(count),
+			InstanceTenancy:    ec2types.TenancyDefault,
+			ProductDescription: ec2types.RIProductDescription("Linux/UNIX"),
+			Scope:              ec2types.ScopeRegional,
+		}}
+	}
+	zonal := func(instanceType, zone string, count int32) reservations.Reservation {
+		reservation := regional(instanceType, count)
+		reservation.Scope = ec2types.ScopeAvailabilityZone
+		reservation.AvailabilityZone = aws.String(zone)
+		return reservation
+	}
+	running := func(instanceType, zone string) instances.Instance {
+		return instances.Instance{Instance: ec2types.Instance{
+			InstanceType: ec2types.InstanceType(instanceType),
+			Placement:    &ec2types.Placement{AvailabilityZone: aws.String(zone), Tenancy: ec2types.TenancyDefault},
+		}}
+	}
+
+	type testCases struct {
+		name         string
+		reservations []reservations.Reservation
+		instances    []instances.Instance
+		instanceType string
+		zone         string
+		expected     bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:         "no reservations",
+			instanceType: "m5.large",
+			zone:         "us-west-2a",
+			expected:     false,
+		},
+		{
+			name:         "regional reservation covers a smaller size of the family",
+			reservations: []reservations.Reservation{regional("m5.xlarge", 1)},
+			instanceType: "m5.large",
+			zone:         "us-west-2a",
+			expected:     true,
+		},
+		{
+			name:         "regional reservation does not cover a larger size",
+			reservations: []reservations.Reservation{regional("m5.large", 1)},
+			instanceType: "m5.xlarge",
+			zone:         "us-west-2a",
+			expected:     false,
+		},
+		{
+			name:         "running instances use the reservation",
+			reservations: []reservations.Reservation{regional("m5.xlarge", 1)},
+			instances:    []instances.Instance{running("m5.large", "us-west-2a"), running("m5.large", "us-west-2b")},
+			instanceType: "m5.large",
+			zone:         "us-west-2a",
+			expected:     false,
+		},
+		{
+			name:         "spot instances do not use reservations",
+			reservations: []reservations.Reservation{regional("m5.large", 1)},
+			instances: []instances.Instance{func() instances.Instance {
+				instance := running("m5.large", "us-west-2a")
+				instance.InstanceLifecycle = ec2types.InstanceLifecycleTypeSpot
+				return instance
+			}()},
+			instanceType: "m5.large",
+			zone:         "us-west-2a",
+			expected:     true,
+		},
+		{
+			name:         "zonal reservation only covers its zone",
+			reservations: []reservations.Reservation{zonal("m5.large", "us-west-2a", 1)},
+			instanceType: "m5.large",
+			zone:         "us-west-2b",
+			expected:     false,
+		},
+		{
+			name:         "running instances use zonal reservations before regional ones",
+			reservations: []reservations.Reservation{zonal("m5.large", "us-west-2a", 1), regional("m5.large", 1)},
+			instances:    []instances.Instance{running("m5.large", "us-west-2a")},
+			instanceType: "m5.large",
+			zone:         "us-west-2b",
+			expected:     true,
+		},
+		{
+			name: "windows reservations are ignored",
+			reservations: []reservations.Reservation{func() reservations.Reservation {
+				reservation := regional("m5.large", 1)
+				reservation.ProductDescription = ec2types.RIProductDescription("Windows")
+				return reservation
+			}()},
+			instanceType: "m5.large",
+			zone:         "us-west-2a",
+			expected:     false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			coverage := reservations.UnusedCoverage(tc.reservations, tc.instances)
+			if covers := coverage.Covers(tc.instanceType, tc.zone); covers != tc.expected {
+				t.Errorf("expected covers to be %t, got %t", tc.expected, covers)
+			}
+		})
+	}
+}
+
+func TestCoversSavingsPlans(t *testing.T) {
+	coverage := reservations.Coverage{SavingsPlans: map[string]float64{"m5": 1.5, "c5": 0}}
+	if coverage.Empty() {
+		t.Errorf("expected a savings plan commitment not to be empty")
+	}
+	for instanceType, expected := range map[string]bool{
+		"m5.large":  true,
+		"m5.metal":  true,
+		"m5d.large": false,
+		"c5.large":  false,
+	} {
+		if covers := coverage.Covers(instanceType, "us-west-2a"); covers != expected {
+			t.Errorf("expected %s covers to be %t, got %t", instanceType, expected, covers)
+		}
+	}
+}
@@ -12,7 +12,9 @@ import (
 	"github.com/bwagner5/nimbus/pkg/providers/igws"
 	"github.com/bwagner5/nimbus/pkg/providers/natgws"
 	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
@@ -100,21 +102,28 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]RouteTabl
 	return routeTables, nil
 }
 
-// Create creates a public and/or a private subnet based on the subnets, Internet Gateway, and NAT Gateway passed in.
-// If subnetsList contains a subnet with MapPublicIpOnLaunch set to true, then Create will create 1 public route table
-// If subnetsList does NOT contain a subnet with MapPublicIpOnLaunch set to true, then Create will create 1 private route table
-// At most, 2 route tables will be created if subnetsList contains a subnet with MapPublicIpOnLaunch set to true and another set to false.
+// Create creates a public and/or a private subnet based on the subnets, Internet Gateway, NAT Gateway, and Egress-Only Internet Gateway passed in.
+// If subnetsList contains a public subnet, then Create will create 1 public route table
+// If subnetsList does NOT contain a public subnet, then Create will create 1 private route table
+// At most, 2 route tables will be created if subnetsList contains a public subnet and a private subnet.
+//
+// If the subnets have IPv6 CIDR blocks, the public route table routes IPv6 traffic to the Internet Gateway
+// and the private route table routes it to the Egress-Only Internet Gateway.
+//
+// The route tables are tagged with the namespaced tags and the additional tags.
 //
 // Public Route Table is the first return and Private Route Table is the second return.
-func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList []subnets.Subnet, igw *igws.InternetGateway, natgw *natgws.NATGateway) (*RouteTable, *RouteTable, error) {
-	privateSubnets := lo.Filter(subnetsList, func(subnet subnets.Subnet, _ int) bool { return !*subnet.MapPublicIpOnLaunch })
-	publicSubnets := lo.Filter(subnetsList, func(subnet subnets.Subnet, _ int) bool { return *subnet.MapPublicIpOnLaunch })
+func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList []subnets.Subnet, igw *igws.InternetGateway, natgw *natgws.NATGateway,
+	eigw *igws.EgressOnlyInternetGateway, tags map[string]string) (*RouteTable, *RouteTable, error) {
+	privateSubnets := lo.Reject(subnetsList, func(subnet subnets.Subnet, _ int) bool { return subnet.IsPublic() })
+	publicSubnets := lo.Filter(subnetsList, func(subnet subnets.Subnet, _ int) bool { return subnet.IsPublic() })
+	hasIPv6 := lo.SomeBy(subnetsList, func(subnet subnets.Subnet) bool { return subnet.IPv6CIDR() != "" })
 	if len(subnetsList) == 0 {
 		return nil, nil, fmt.Errorf("no subnets received")
 	}
 	// PUBLIC SUBNET RESOURCES
 	var publicRouteTable *RouteTable
-	publicRawTags := tagutils.NamespacedTags(namespace, name)
+	publicRawTags := lo.Assign(tags, tagutils.NamespacedTags(namespace, name))
 	publicRawTags["Name"] = fmt.Sprintf("%s-PUBLIC", publicRawTags["Name"])
 	publicTags := tagutils.MapToEC2Tags(publicRawTags)
 	var publicRouteTableOut *ec2.CreateRouteTableOutput
@@ -143,6 +152,15 @@ func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList
 					return nil, nil, err
 				}
 			}
+			if igw != nil && hasIPv6 {
+				if _, err := w.routeTableAPI.CreateRoute(ctx, &ec2.CreateRouteInput{
+					RouteTableId:             publicRouteTable.RouteTableId,
+					DestinationIpv6CidrBlock: aws.String("::/0"),
+					GatewayId:                igw.InternetGatewayId,
+				}); err != nil {
+					return nil, nil, err
+				}
+			}
 		}
 		if _, err := w.routeTableAPI.AssociateRouteTable(ctx, &ec2.AssociateRouteTableInput{
 			RouteTableId: publicRouteTableOut.RouteTable.RouteTableId,
@@ -154,7 +172,7 @@ func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList
 
 	// PRIVATE SUBNET RESOURCES
 	var privateRouteTable *RouteTable
-	privateRawTags := tagutils.NamespacedTags(namespace, name)
+	privateRawTags := lo.Assign(tags, tagutils.NamespacedTags(namespace, name))
 	privateRawTags["Name"] = fmt.Sprintf("%s-PRIVATE", privateRawTags["Name"])
 	privateTags := tagutils.MapToEC2Tags(privateRawTags)
 	var privateRouteTableOut *ec2.CreateRouteTableOutput
@@ -178,7 +196,16 @@ func (w Watcher) Create(ctx context.Context, namespace, name string, subnetsList
 				if _, err := w.routeTableAPI.CreateRoute(ctx, &ec2.CreateRouteInput{
 					RouteTableId:         privateRouteTable.RouteTableId,
 					DestinationCidrBlock: aws.String("0.0.0.0/0"),
-					GatewayId:            natgw.NatGatewayId,
+					NatGatewayId:         natgw.NatGatewayId,
+				}); err != nil {
+					return nil, nil, err
+				}
+			}
+			if eigw != nil && hasIPv6 {
+				if _, err := w.routeTableAPI.CreateRoute(ctx, &ec2.CreateRouteInput{
+					RouteTableId:                privateRouteTable.RouteTableId,
+					DestinationIpv6CidrBlock:    aws.String("::/0"),
+					EgressOnlyInternetGatewayId: eigw.EgressOnlyInternetGatewayId,
 				}); err != nil {
 					return nil, nil, err
 				}
@@ -198,8 +225,9 @@ func (w Watcher) Delete(ctx context.Context, routeTable RouteTable) error {
 	for _, route := range routeTable.Routes {
 		if route.GatewayId != nil && strings.HasPrefix(*route.GatewayId, "igw-") {
 			if _, err := w.routeTableAPI.DeleteRoute(ctx, &ec2.DeleteRouteInput{
-				RouteTableId:         routeTable.RouteTableId,
-				DestinationCidrBlock: route.DestinationCidrBlock,
+				RouteTableId:             routeTable.RouteTableId,
+				DestinationCidrBlock:     route.DestinationCidrBlock,
+				DestinationIpv6CidrBlock: route.DestinationIpv6CidrBlock,
 			}); err != nil {
 				return err
 			}
@@ -210,10 +238,10 @@ func (w Watcher) Delete(ctx context.Context, routeTable RouteTable) error {
 			return err
 		}
 	}
-	if _, err := w.routeTableAPI.DeleteRouteTable(ctx, &ec2.DeleteRouteTableInput{RouteTableId: routeTable.RouteTableId}); err != nil {
+	return retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.routeTableAPI.DeleteRouteTable(ctx, &ec2.DeleteRouteTableInput{RouteTableId: routeTable.RouteTableId})
 		return err
-	}
-	return nil
+	})
 }
 
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
@@ -0,0 +1,85 @@
+package savingsplans
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awssavingsplans "github.com/aws/aws-sdk-go-v2/service/savingsplans"
+	savingsplanstypes "github.com/aws/aws-sdk-go-v2/service/savingsplans/types"
+	"github.com/aws/smithy-go"
+	"github.com/samber/lo"
+)
+
+// Watcher discovers the account's Savings Plans
+type Watcher struct {
+	savingsPlansAPI SDKSavingsPlansOps
+}
+
+// SDKSavingsPlansOps is an interface that combines the necessary Savings Plans SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKSavingsPlansOps interface {
+	DescribeSavingsPlans(context.Context, *awssavingsplans.DescribeSavingsPlansInput, ...func(*awssavingsplans.Options)) (*awssavingsplans.DescribeSavingsPlansOutput, error)
+}
+
+// SavingsPlan represents a Savings Plan
+// This is not the AWS SDK SavingsPlan type, but a wrapper around it so that we can add additional data
+type SavingsPlan struct {
+	savingsplanstypes.SavingsPlan
+}
+
+// NewWatcher creates a new Savings Plan Watcher
+func NewWatcher(savingsPlansAPI SDKSavingsPlansOps) Watcher {
+	return Watcher{
+		savingsPlansAPI: savingsPlansAPI,
+	}
+}
+
+// InstanceFamilies returns the active EC2 Instance Savings Plans of the region.
+// Compute Savings Plans are not returned since they apply to every instance type in every region.
+func (w Watcher) InstanceFamilies(ctx context.Context, region string) ([]SavingsPlan, error) {
+	var savingsPlans []SavingsPlan
+	input := &awssavingsplans.DescribeSavingsPlansInput{
+		States: []savingsplanstypes.SavingsPlanState{savingsplanstypes.SavingsPlanStateActive},
+		Filters: []savingsplanstypes.SavingsPlanFilter{
+			{Name: savingsplanstypes.SavingsPlansFilterNameRegion, Values: []string{region}},
+			{Name: savingsplanstypes.SavingsPlansFilterNameSavingsPlanType, Values: []string{string(savingsplanstypes.SavingsPlanTypeEc2Instance)}},
+		},
+	}
+	// the SDK does not provide a paginator for DescribeSavingsPlans
+	for {
+		out, err := w.savingsPlansAPI.DescribeSavingsPlans(ctx, input)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe savings plans: %w", err)
+		}
+		savingsPlans = append(savingsPlans, lo.Map(out.SavingsPlans, func(sdkSavingsPlan savingsplanstypes.SavingsPlan, _ int) SavingsPlan {
+			return SavingsPlan{sdkSavingsPlan}
+		})...)
+		if aws.ToString(out.NextToken) == "" {
+			return savingsPlans, nil
+		}
+		input.NextToken = out.NextToken
+	}
+}
+
+// Commitments sums the hourly commitments of the EC2 Instance Savings Plans by instance family
+func Commitments(savingsPlans []SavingsPlan) map[string]float64 {
+	commitments := map[string]float64{}
+	for _, savingsPlan := range savingsPlans {
+		if savingsPlan.SavingsPlanType != savingsplanstypes.SavingsPlanTypeEc2Instance || aws.ToString(savingsPlan.Ec2InstanceFamily) == "" {
+			continue
+		}
+		// the commitment is the amount spent per hour as a decimal string
+		commitment, _ := strconv.ParseFloat(aws.ToString(savingsPlan.Commitment), 64)
+		commitments[aws.ToString(savingsPlan.Ec2InstanceFamily)] += commitment
+	}
+	return commitments
+}
+
+// IsAccessDenied returns true if the error is a missing permission to describe Savings Plans
+func IsAccessDenied(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "AccessDeniedException"
+}
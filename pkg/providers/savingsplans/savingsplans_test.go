@@ -0,0 +1,82 @@
+package savingsplans_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awssavingsplans "github.com/aws/aws-sdk-go-v2/service/savingsplans"
+	savingsplanstypes "github.com/aws/aws-sdk-go-v2/service/savingsplans/types"
+	"github.com/aws/smithy-go"
+	"github.com/bwagner5/nimbus/pkg/providers/savingsplans"
+)
+
+type fakeSavingsPlans struct {
+	savingsplans.SDKSavingsPlansOps
+	// pages are returned by DescribeSavingsPlans a page at a time
+	pages  [][]savingsplanstypes.SavingsPlan
+	inputs []awssavingsplans.DescribeSavingsPlansInput
+	err    error
+}
+
+func (f *fakeSavingsPlans) DescribeSavingsPlans(_ context.Context, input *awssavingsplans.DescribeSavingsPlansInput, _ ...func(*awssavingsplans.Options)) (*awssavingsplans.DescribeSavingsPlansOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.inputs = append(f.inputs, *input)
+	page := 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(*input.NextToken)
+	}
+	out := &awssavingsplans.DescribeSavingsPlansOutput{SavingsPlans: f.pages[page]}
+	if page+1 < len(f.pages) {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+	return out, nil
+}
+
+func savingsPlan(family, commitment string) savingsplanstypes.SavingsPlan {
+	return savingsplanstypes.SavingsPlan{
+		SavingsPlanType:   savingsplanstypes.SavingsPlanTypeEc2Instance,
+		Ec2InstanceFamily: aws.String(family),
+		Commitment:        aws.String(commitment),
+	}
+}
+
+func TestInstanceFamilies(t *testing.T) {
+	savingsPlansAPI := &fakeSavingsPlans{pages: [][]savingsplanstypes.SavingsPlan{
+		{savingsPlan("m5", "1.5")},
+		{savingsPlan("c6g", "0.25"), savingsPlan("m5", "0.5")},
+	}}
+	savingsPlans, err := savingsplans.NewWatcher(savingsPlansAPI).InstanceFamilies(context.Background(), "us-west-2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(savingsPlans) != 3 {
+		t.Fatalf("expected the savings plans of every page, got %d", len(savingsPlans))
+	}
+	input := savingsPlansAPI.inputs[0]
+	if len(input.States) != 1 || input.States[0] != savingsplanstypes.SavingsPlanStateActive || len(input.Filters) != 2 || input.Filters[0].Values[0] != "us-west-2" {
+		t.Errorf("expected the active savings plans of the region, got %+v", input)
+	}
+
+	commitments := savingsplans.Commitments(savingsPlans)
+	if len(commitments) != 2 || commitments["m5"] != 2 || commitments["c6g"] != 0.25 {
+		t.Errorf("expected the commitments to be summed by family, got %v", commitments)
+	}
+}
+
+func TestCommitmentsIgnoresComputeSavingsPlans(t *testing.T) {
+	compute := savingsplanstypes.SavingsPlan{SavingsPlanType: savingsplanstypes.SavingsPlanTypeCompute, Commitment: aws.String("10")}
+	if commitments := savingsplans.Commitments([]savingsplans.SavingsPlan{{SavingsPlan: compute}}); len(commitments) != 0 {
+		t.Errorf("expected compute savings plans to be ignored, got %v", commitments)
+	}
+}
+
+func TestIsAccessDenied(t *testing.T) {
+	savingsPlansAPI := &fakeSavingsPlans{err: &smithy.GenericAPIError{Code: "AccessDeniedException"}}
+	if _, err := savingsplans.NewWatcher(savingsPlansAPI).InstanceFamilies(context.Background(), "us-west-2"); !savingsplans.IsAccessDenied(err) {
+		t.Errorf("expected an access denied error, got %v", err)
+	}
+}
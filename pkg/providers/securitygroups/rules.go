@@ -0,0 +1,214 @@
+package securitygroups
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/samber/lo"
+)
+
+const (
+	DirectionIngress = "ingress"
+	DirectionEgress  = "egress"
+)
+
+// RuleSelector is a struct that represents a security group rule selector
+type RuleSelector struct {
+	SecurityGroupID string
+	// Direction is ingress or egress, rules of both directions are selected if it is empty
+	Direction string
+	// Port selects the tcp and udp rules whose port range includes it and the rules that allow all protocols
+	Port *int32
+}
+
+// Rule represents an AWS Security Group Rule
+// This is not the AWS SDK SecurityGroupRule type, but a wrapper around it so that we can add additional data
+type Rule struct {
+	ec2types.SecurityGroupRule
+}
+
+// PrettyRule represents a security group rule for UI elements like the static and TUI tables
+type PrettyRule struct {
+	SecurityGroup string `table:"Security Group"`
+	Direction     string `table:"Direction"`
+	Protocol      string `table:"Protocol"`
+	Ports         string `table:"Ports"`
+	// Peer is the CIDR, prefix list, or security group that traffic is allowed from for ingress rules or to for egress rules
+	Peer        string `table:"Peer"`
+	ID          string `table:"Rule ID,wide"`
+	Description string `table:"Description,wide"`
+}
+
+// ParseRuleSelectors parses a string of selectors into a slice of RuleSelector structs
+//
+// Example:
+//
+//	"sg-id:sg-0123456,direction:ingress,port:22"
+func ParseRuleSelectors(selectorStr string) ([]RuleSelector, error) {
+	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse security group rule selectors: %w", err)
+	}
+	ruleSelectors := make([]RuleSelector, 0, len(selectors))
+	for _, selector := range selectors {
+		if len(selector.Tags) != 0 {
+			return nil, fmt.Errorf("security group rule selectors do not support tags")
+		}
+		ruleSelector := RuleSelector{}
+		for k, v := range selector.KeyVals {
+			switch k {
+			case "sg-id":
+				ruleSelector.SecurityGroupID = v
+			case "direction":
+				if v != DirectionIngress && v != DirectionEgress {
+					return nil, fmt.Errorf("invalid security group rule direction %q, must be %s or %s", v, DirectionIngress, DirectionEgress)
+				}
+				ruleSelector.Direction = v
+			case "port":
+				port, err := strconv.ParseInt(v, 10, 32)
+				if err != nil {
+					return nil, fmt.Errorf("invalid port %s", v)
+				}
+				ruleSelector.Port = lo.ToPtr(int32(port))
+			default:
+				return nil, fmt.Errorf("invalid security group rule selector key: %s", k)
+			}
+		}
+		ruleSelectors = append(ruleSelectors, ruleSelector)
+	}
+	return ruleSelectors, nil
+}
+
+// ResolveRules returns a list of security group rules that match the provided selectors
+// Multiple calls to EC2 may be sent to resolve the selectors
+func (w Watcher) ResolveRules(ctx context.Context, selectors []RuleSelector) ([]Rule, error) {
+	var rules []Rule
+	for _, selector := range selectors {
+		var filters []ec2types.Filter
+		if selector.SecurityGroupID != "" {
+			filters = append(filters, ec2types.Filter{Name: aws.String("group-id"), Values: []string{selector.SecurityGroupID}})
+		}
+		pager := ec2.NewDescribeSecurityGroupRulesPaginator(w.sg, &ec2.DescribeSecurityGroupRulesInput{
+			Filters: filters,
+		})
+		for pager.HasMorePages() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe security group rules: %w", err)
+			}
+			for _, sdkRule := range page.SecurityGroupRules {
+				if rule := (Rule{sdkRule}); selector.Matches(rule) {
+					rules = append(rules, rule)
+				}
+			}
+		}
+	}
+	return lo.UniqBy(rules, func(rule Rule) string { return lo.FromPtr(rule.SecurityGroupRuleId) }), nil
+}
+
+// ResolveGroupRules returns the security groups with their ingress and egress rules, in the order that EC2 returns the rules
+func (w Watcher) ResolveGroupRules(ctx context.Context, securityGroups []SecurityGroup) ([]SecurityGroup, error) {
+	rulesByGroup := map[string][]Rule{}
+	// EC2 accepts up to 200 values per filter
+	for _, groupIDs := range lo.Chunk(lo.Map(securityGroups, func(sg SecurityGroup, _ int) string { return lo.FromPtr(sg.GroupId) }), 200) {
+		pager := ec2.NewDescribeSecurityGroupRulesPaginator(w.sg, &ec2.DescribeSecurityGroupRulesInput{
+			Filters: []ec2types.Filter{{Name: aws.String("group-id"), Values: groupIDs}},
+		})
+		for pager.HasMorePages() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe security group rules: %w", err)
+			}
+			for _, sdkRule := range page.SecurityGroupRules {
+				rulesByGroup[lo.FromPtr(sdkRule.GroupId)] = append(rulesByGroup[lo.FromPtr(sdkRule.GroupId)], Rule{sdkRule})
+			}
+		}
+	}
+	return lo.Map(securityGroups, func(sg SecurityGroup, _ int) SecurityGroup {
+		sg.Rules = rulesByGroup[lo.FromPtr(sg.GroupId)]
+		return sg
+	}), nil
+}
+
+// Matches returns true if the rule matches the selector's direction and port.
+// EC2 can not filter rules by either, so they are matched after the rules are described.
+func (s RuleSelector) Matches(rule Rule) bool {
+	if s.Direction != "" && s.Direction != rule.Direction() {
+		return false
+	}
+	if s.Port == nil {
+		return true
+	}
+	switch lo.FromPtr(rule.IpProtocol) {
+	case "-1":
+		return true
+	case "tcp", "udp", "6", "17":
+		fromPort, toPort := lo.FromPtr(rule.FromPort), lo.FromPtr(rule.ToPort)
+		return fromPort == -1 || (fromPort <= *s.Port && *s.Port <= toPort)
+	}
+	return false
+}
+
+// Direction returns whether the rule is an ingress or egress rule
+func (r Rule) Direction() string {
+	return lo.Ternary(lo.FromPtr(r.IsEgress), DirectionEgress, DirectionIngress)
+}
+
+// Ports returns the rule's port or port range, or all if the rule allows every port
+func (r Rule) Ports() string {
+	fromPort, toPort := lo.FromPtr(r.FromPort), lo.FromPtr(r.ToPort)
+	switch {
+	case lo.FromPtr(r.IpProtocol) == "-1" || fromPort == -1:
+		return "all"
+	case fromPort == toPort:
+		return strconv.Itoa(int(fromPort))
+	}
+	return fmt.Sprintf("%d-%d", fromPort, toPort)
+}
+
+// Peer returns the CIDR, prefix list, or security group that the rule allows traffic from or to
+func (r Rule) Peer() string {
+	switch {
+	case r.CidrIpv4 != nil:
+		return *r.CidrIpv4
+	case r.CidrIpv6 != nil:
+		return *r.CidrIpv6
+	case r.PrefixListId != nil:
+		return *r.PrefixListId
+	case r.ReferencedGroupInfo != nil:
+		return lo.FromPtr(r.ReferencedGroupInfo.GroupId)
+	}
+	return ""
+}
+
+// String summarizes the rule by its protocol, ports, and peer, e.g. "tcp:22 from 10.0.0.0/8" or "all to 0.0.0.0/0"
+func (r Rule) String() string {
+	protocol := lo.Ternary(lo.FromPtr(r.IpProtocol) == "-1", "all", lo.FromPtr(r.IpProtocol))
+	if ports := r.Ports(); ports != "all" {
+		protocol += ":" + ports
+	}
+	return fmt.Sprintf("%s %s %s", protocol, lo.Ternary(lo.FromPtr(r.IsEgress), "to", "from"), r.Peer())
+}
+
+// Prettify converts the rule into a PrettyRule
+func (r Rule) Prettify() PrettyRule {
+	return PrettyRule{
+		SecurityGroup: lo.FromPtr(r.GroupId),
+		Direction:     r.Direction(),
+		Protocol:      lo.Ternary(lo.FromPtr(r.IpProtocol) == "-1", "all", lo.FromPtr(r.IpProtocol)),
+		Ports:         r.Ports(),
+		Peer:          r.Peer(),
+		ID:            lo.FromPtr(r.SecurityGroupRuleId),
+		Description:   lo.FromPtr(r.Description),
+	}
+}
+
+// PrettifyRules converts security group rules into PrettyRules
+func PrettifyRules(rules []Rule) []PrettyRule {
+	return lo.Map(rules, func(rule Rule, _ int) PrettyRule { return rule.Prettify() })
+}
@@ -3,11 +3,16 @@ package securitygroups
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
@@ -24,25 +29,60 @@ type SDKSecurityGroupOps interface {
 	ec2.DescribeSecurityGroupRulesAPIClient
 	CreateSecurityGroup(context.Context, *ec2.CreateSecurityGroupInput, ...func(*ec2.Options)) (*ec2.CreateSecurityGroupOutput, error)
 	AuthorizeSecurityGroupIngress(context.Context, *ec2.AuthorizeSecurityGroupIngressInput, ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupIngressOutput, error)
+	AuthorizeSecurityGroupEgress(context.Context, *ec2.AuthorizeSecurityGroupEgressInput, ...func(*ec2.Options)) (*ec2.AuthorizeSecurityGroupEgressOutput, error)
+	RevokeSecurityGroupIngress(context.Context, *ec2.RevokeSecurityGroupIngressInput, ...func(*ec2.Options)) (*ec2.RevokeSecurityGroupIngressOutput, error)
 	DeleteSecurityGroup(context.Context, *ec2.DeleteSecurityGroupInput, ...func(*ec2.Options)) (*ec2.DeleteSecurityGroupOutput, error)
 }
 
 // Selector is a struct that represents a security group selector
 type Selector struct {
-	Tags map[string]string
-	Name string
-	ID   string
+	Tags  map[string]string
+	Name  string
+	ID    string
+	VPCID string
 }
 
 type CreateSecurityGroupOpts struct {
 	Name  string
 	VPCID string
+	// Tags are additional tags applied to the security group
+	Tags map[string]string
+	// DryRun only checks whether the caller is permitted to create the security group, EC2 returns a DryRunOperation error if it is
+	DryRun bool
+	// IPv6Egress allows all outbound IPv6 traffic in addition to the outbound IPv4 traffic that new security groups allow
+	IPv6Egress bool
+}
+
+// IngressRule allows inbound traffic from a CIDR, a security group, or the security group of a nimbus node group
+type IngressRule struct {
+	// Protocol is tcp, udp, icmp, or -1 for all protocols
+	Protocol string
+	FromPort int32
+	ToPort   int32
+	CIDR     string
+	// SecurityGroupID is the source security group
+	SecurityGroupID string
+	// Group is the name of a node group in the same plan. It must be resolved to the group's SecurityGroupID before the rule is authorized.
+	Group string
 }
 
 // SecurityGroup represent an AWS Security Group
 // This is not the AWS SDK SecurityGroup type, but a wrapper around it so that we can add additional data
 type SecurityGroup struct {
 	ec2types.SecurityGroup
+	// Rules are the ingress and egress rules of the security group, they are only set by ResolveGroupRules
+	Rules []Rule
+}
+
+// PrettySecurityGroup represents a security group for UI elements like the static and TUI tables
+type PrettySecurityGroup struct {
+	Name         string `table:"Name"`
+	ID           string `table:"ID"`
+	VPC          string `table:"VPC"`
+	Ingress      int    `table:"Ingress"`
+	Egress       int    `table:"Egress"`
+	IngressRules string `table:"Ingress-Rules,wide"`
+	EgressRules  string `table:"Egress-Rules,wide"`
 }
 
 // ParseSelectors parses a string of selectors into a slice of Selector structs
@@ -62,6 +102,8 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 				securityGroupSelector.ID = v
 			case "name":
 				securityGroupSelector.Name = v
+			case "vpc-id":
+				securityGroupSelector.VPCID = v
 			default:
 				return nil, fmt.Errorf("invalid security group selector key: %s", k)
 			}
@@ -71,6 +113,115 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 	return securityGroupSelectors, nil
 }
 
+// ParseIngressRules parses semicolon separated ingress rules of the form "allow <protocol>[:<ports>] from <source>"
+//
+// Protocol is one of tcp, udp, icmp, or all. Ports are a single port or a range, e.g. 8000-9000.
+// Source is one of group:<node group name>, sg:<security group id>, or cidr:<cidr>.
+//
+// Example:
+//
+//	"allow tcp:2379-2380 from group:controller;allow tcp:22 from cidr:10.0.0.0/8;allow all from sg:sg-0123456"
+func ParseIngressRules(rulesStr string) ([]IngressRule, error) {
+	var rules []IngressRule
+	for _, ruleStr := range strings.Split(rulesStr, ";") {
+		ruleStr = strings.TrimSpace(ruleStr)
+		if ruleStr == "" {
+			continue
+		}
+		rule, err := parseIngressRule(ruleStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ingress rule %q: %w", ruleStr, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseIngressRule(ruleStr string) (IngressRule, error) {
+	fields := strings.Fields(ruleStr)
+	if len(fields) != 4 || fields[0] != "allow" || fields[2] != "from" {
+		return IngressRule{}, fmt.Errorf("expected \"allow <protocol>[:<ports>] from <source>\"")
+	}
+	rule := IngressRule{}
+	protocol, ports, hasPorts := strings.Cut(fields[1], ":")
+	switch protocol {
+	case "tcp", "udp":
+		if !hasPorts {
+			return rule, fmt.Errorf("%s requires a port or port range", protocol)
+		}
+		fromPort, toPort, isRange := strings.Cut(ports, "-")
+		if !isRange {
+			toPort = fromPort
+		}
+		from, err := strconv.ParseInt(fromPort, 10, 32)
+		if err != nil {
+			return rule, fmt.Errorf("invalid port %s", fromPort)
+		}
+		to, err := strconv.ParseInt(toPort, 10, 32)
+		if err != nil {
+			return rule, fmt.Errorf("invalid port %s", toPort)
+		}
+		rule.Protocol, rule.FromPort, rule.ToPort = protocol, int32(from), int32(to)
+	case "icmp":
+		rule.Protocol, rule.FromPort, rule.ToPort = protocol, -1, -1
+	case "all":
+		rule.Protocol, rule.FromPort, rule.ToPort = "-1", -1, -1
+	default:
+		return rule, fmt.Errorf("unsupported protocol %s", protocol)
+	}
+	if protocol != "tcp" && protocol != "udp" && hasPorts {
+		return rule, fmt.Errorf("%s does not support ports", protocol)
+	}
+	sourceType, source, ok := strings.Cut(fields[3], ":")
+	if !ok || source == "" {
+		return rule, fmt.Errorf("invalid source %s", fields[3])
+	}
+	switch sourceType {
+	case "group":
+		rule.Group = source
+	case "sg":
+		rule.SecurityGroupID = source
+	case "cidr":
+		rule.CIDR = source
+	default:
+		return rule, fmt.Errorf("invalid source type %s", sourceType)
+	}
+	return rule, nil
+}
+
+// ingressPresets are the ports of well-known services that ingress rules can be created for by name
+var ingressPresets = map[string]IngressRule{
+	"ssh":   {Protocol: "tcp", FromPort: 22, ToPort: 22},
+	"http":  {Protocol: "tcp", FromPort: 80, ToPort: 80},
+	"https": {Protocol: "tcp", FromPort: 443, ToPort: 443},
+	"rdp":   {Protocol: "tcp", FromPort: 3389, ToPort: 3389},
+	"all":   {Protocol: "-1", FromPort: -1, ToPort: -1},
+}
+
+// IngressPresets returns the names of the ingress presets in alphabetical order
+func IngressPresets() []string {
+	presets := lo.Keys(ingressPresets)
+	slices.Sort(presets)
+	return presets
+}
+
+// PresetIngressRules returns an ingress rule from each of the CIDRs for each of the named presets, e.g. ssh or https
+func PresetIngressRules(presets []string, cidrs []string) ([]IngressRule, error) {
+	var rules []IngressRule
+	for _, preset := range presets {
+		presetRule, ok := ingressPresets[strings.ToLower(strings.TrimSpace(preset))]
+		if !ok {
+			return nil, fmt.Errorf("invalid ingress preset %q, must be one of %v", preset, IngressPresets())
+		}
+		for _, cidr := range cidrs {
+			rule := presetRule
+			rule.CIDR = cidr
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
 // NewWatcher creates a new Security Group Watcher
 func NewWatcher(sg SDKSecurityGroupOps) Watcher {
 	return Watcher{
@@ -93,34 +244,126 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]SecurityG
 			}
 
 			securityGroups = append(securityGroups, lo.Map(page.SecurityGroups, func(sdkSG ec2types.SecurityGroup, _ int) SecurityGroup {
-				return SecurityGroup{sdkSG}
+				return SecurityGroup{SecurityGroup: sdkSG}
 			})...)
 		}
 	}
 	return securityGroups, nil
 }
 
+// Prettify converts the security group into a PrettySecurityGroup, its rules are summarized if they were resolved
+func (sg SecurityGroup) Prettify() PrettySecurityGroup {
+	ingress, egress := lo.FilterReject(sg.Rules, func(rule Rule, _ int) bool { return rule.Direction() == DirectionIngress })
+	summarize := func(rules []Rule) string {
+		return strings.Join(lo.Map(rules, func(rule Rule, _ int) string { return rule.String() }), ", ")
+	}
+	return PrettySecurityGroup{
+		Name:         lo.FromPtr(sg.GroupName),
+		ID:           lo.FromPtr(sg.GroupId),
+		VPC:          lo.FromPtr(sg.VpcId),
+		Ingress:      len(ingress),
+		Egress:       len(egress),
+		IngressRules: summarize(ingress),
+		EgressRules:  summarize(egress),
+	}
+}
+
+// PrettifySecurityGroups converts security groups into PrettySecurityGroups
+func PrettifySecurityGroups(securityGroups []SecurityGroup) []PrettySecurityGroup {
+	return lo.Map(securityGroups, func(sg SecurityGroup, _ int) PrettySecurityGroup { return sg.Prettify() })
+}
+
 func (w Watcher) CreateSecurityGroup(ctx context.Context, namespace string, name string, createSecurityGroupOpts CreateSecurityGroupOpts) (string, error) {
 	sgOut, err := w.sg.CreateSecurityGroup(ctx, &ec2.CreateSecurityGroupInput{
 		GroupName:   &createSecurityGroupOpts.Name,
 		VpcId:       &createSecurityGroupOpts.VPCID,
 		Description: aws.String("nimbus generated security group"),
+		DryRun:      lo.Ternary(createSecurityGroupOpts.DryRun, aws.Bool(true), nil),
 		TagSpecifications: []ec2types.TagSpecification{{
 			ResourceType: ec2types.ResourceTypeSecurityGroup,
-			Tags:         tagutils.EC2NamespacedTags(namespace, name),
+			Tags:         tagutils.MapToEC2Tags(lo.Assign(createSecurityGroupOpts.Tags, tagutils.NamespacedTags(namespace, name))),
 		}},
 	})
 	if err != nil {
 		return "", err
 	}
+	if createSecurityGroupOpts.IPv6Egress {
+		if _, err := w.sg.AuthorizeSecurityGroupEgress(ctx, &ec2.AuthorizeSecurityGroupEgressInput{
+			GroupId: sgOut.GroupId,
+			IpPermissions: []ec2types.IpPermission{{
+				IpProtocol: aws.String("-1"),
+				Ipv6Ranges: []ec2types.Ipv6Range{{CidrIpv6: aws.String("::/0")}},
+			}},
+		}); err != nil {
+			return *sgOut.GroupId, fmt.Errorf("failed to allow IPv6 egress of security group %s: %w", *sgOut.GroupId, err)
+		}
+	}
 	return *sgOut.GroupId, nil
 }
 
-func (w Watcher) DeleteSecurityGroup(ctx context.Context, sgID string) error {
-	_, err := w.sg.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: &sgID})
+// AuthorizeIngress adds the ingress rules to the security group
+func (w Watcher) AuthorizeIngress(ctx context.Context, sgID string, rules []IngressRule) error {
+	permissions := make([]ec2types.IpPermission, 0, len(rules))
+	for _, rule := range rules {
+		permission := ec2types.IpPermission{
+			IpProtocol: aws.String(rule.Protocol),
+			FromPort:   aws.Int32(rule.FromPort),
+			ToPort:     aws.Int32(rule.ToPort),
+		}
+		switch {
+		case strings.Contains(rule.CIDR, ":"):
+			permission.Ipv6Ranges = []ec2types.Ipv6Range{{CidrIpv6: aws.String(rule.CIDR)}}
+		case rule.CIDR != "":
+			permission.IpRanges = []ec2types.IpRange{{CidrIp: aws.String(rule.CIDR)}}
+		case rule.SecurityGroupID != "":
+			permission.UserIdGroupPairs = []ec2types.UserIdGroupPair{{GroupId: aws.String(rule.SecurityGroupID)}}
+		default:
+			return fmt.Errorf("ingress rule from group %s was not resolved to a security group", rule.Group)
+		}
+		permissions = append(permissions, permission)
+	}
+	_, err := w.sg.AuthorizeSecurityGroupIngress(ctx, &ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       aws.String(sgID),
+		IpPermissions: permissions,
+	})
 	return err
 }
 
+// RevokeSecurityGroupReferences removes the security group's ingress rules that reference other security groups.
+// Security groups that are referenced by another group's rules cannot be deleted until the references are removed.
+func (w Watcher) RevokeSecurityGroupReferences(ctx context.Context, sgID string) error {
+	var ruleIDs []string
+	pager := ec2.NewDescribeSecurityGroupRulesPaginator(w.sg, &ec2.DescribeSecurityGroupRulesInput{
+		Filters: []ec2types.Filter{{Name: aws.String("group-id"), Values: []string{sgID}}},
+	})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to describe security group rules: %w", err)
+		}
+		for _, rule := range page.SecurityGroupRules {
+			if !lo.FromPtr(rule.IsEgress) && rule.ReferencedGroupInfo != nil {
+				ruleIDs = append(ruleIDs, *rule.SecurityGroupRuleId)
+			}
+		}
+	}
+	if len(ruleIDs) == 0 {
+		return nil
+	}
+	_, err := w.sg.RevokeSecurityGroupIngress(ctx, &ec2.RevokeSecurityGroupIngressInput{
+		GroupId:              aws.String(sgID),
+		SecurityGroupRuleIds: ruleIDs,
+	})
+	return err
+}
+
+func (w Watcher) DeleteSecurityGroup(ctx context.Context, sgID string) error {
+	return retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.sg.DeleteSecurityGroup(ctx, &ec2.DeleteSecurityGroupInput{GroupId: &sgID})
+		return err
+	})
+}
+
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
 // Each filter is executed as a separate list call.
 // Terms within a Selector are AND'd and between Selectors are OR'd
@@ -140,6 +383,12 @@ func filterSets(selectorList []Selector) [][]ec2types.Filter {
 				Values: []string{term.Name},
 			})
 		}
+		if term.VPCID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("vpc-id"),
+				Values: []string{term.VPCID},
+			})
+		}
 		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
 		filterResult = append(filterResult, filters)
 	}
@@ -0,0 +1,246 @@
+package securitygroups_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/samber/lo"
+)
+
+func TestParseIngressRules(t *testing.T) {
+	type testCases struct {
+		rulesStr    string
+		expected    []securitygroups.IngressRule
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			rulesStr: "allow tcp:2379 from group:controller",
+			expected: []securitygroups.IngressRule{{Protocol: "tcp", FromPort: 2379, ToPort: 2379, Group: "controller"}},
+		},
+		{
+			rulesStr: "allow udp:8000-9000 from cidr:10.0.0.0/8; allow all from sg:sg-123",
+			expected: []securitygroups.IngressRule{
+				{Protocol: "udp", FromPort: 8000, ToPort: 9000, CIDR: "10.0.0.0/8"},
+				{Protocol: "-1", FromPort: -1, ToPort: -1, SecurityGroupID: "sg-123"},
+			},
+		},
+		{
+			rulesStr: "allow tcp:22 from cidr:::/0",
+			expected: []securitygroups.IngressRule{{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: "::/0"}},
+		},
+		{
+			rulesStr: "allow icmp from group:worker",
+			expected: []securitygroups.IngressRule{{Protocol: "icmp", FromPort: -1, ToPort: -1, Group: "worker"}},
+		},
+		{
+			rulesStr:    "allow tcp from group:controller",
+			expectedErr: true,
+		},
+		{
+			rulesStr:    "allow all:22 from group:controller",
+			expectedErr: true,
+		},
+		{
+			rulesStr:    "deny tcp:22 from cidr:0.0.0.0/0",
+			expectedErr: true,
+		},
+		{
+			rulesStr:    "allow tcp:22 from host:bastion",
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.rulesStr, func(t *testing.T) {
+			rules, err := securitygroups.ParseIngressRules(tc.rulesStr)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, rules)
+			}
+		})
+	}
+}
+
+func TestPresetIngressRules(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		presets     []string
+		cidrs       []string
+		expected    []securitygroups.IngressRule
+		expectedErr bool
+	}{
+		{
+			name:     "ssh from anywhere",
+			presets:  []string{"ssh"},
+			cidrs:    []string{"0.0.0.0/0"},
+			expected: []securitygroups.IngressRule{{Protocol: "tcp", FromPort: 22, ToPort: 22, CIDR: "0.0.0.0/0"}},
+		},
+		{
+			name:    "web from IPv4 and IPv6",
+			presets: []string{"http", "HTTPS"},
+			cidrs:   []string{"0.0.0.0/0", "::/0"},
+			expected: []securitygroups.IngressRule{
+				{Protocol: "tcp", FromPort: 80, ToPort: 80, CIDR: "0.0.0.0/0"},
+				{Protocol: "tcp", FromPort: 80, ToPort: 80, CIDR: "::/0"},
+				{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "0.0.0.0/0"},
+				{Protocol: "tcp", FromPort: 443, ToPort: 443, CIDR: "::/0"},
+			},
+		},
+		{
+			name:     "all traffic",
+			presets:  []string{"all"},
+			cidrs:    []string{"203.0.113.7/32"},
+			expected: []securitygroups.IngressRule{{Protocol: "-1", FromPort: -1, ToPort: -1, CIDR: "203.0.113.7/32"}},
+		},
+		{
+			name:        "unknown preset",
+			presets:     []string{"telnet"},
+			cidrs:       []string{"0.0.0.0/0"},
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rules, err := securitygroups.PresetIngressRules(tc.presets, tc.cidrs)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(rules, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, rules)
+			}
+		})
+	}
+}
+
+func TestParseRuleSelectors(t *testing.T) {
+	for _, tc := range []struct {
+		selectorStr string
+		expected    []securitygroups.RuleSelector
+		expectedErr bool
+	}{
+		{
+			selectorStr: "sg-id:sg-123,direction:ingress,port:22",
+			expected:    []securitygroups.RuleSelector{{SecurityGroupID: "sg-123", Direction: "ingress", Port: lo.ToPtr(int32(22))}},
+		},
+		{
+			selectorStr: "direction:egress;port:443",
+			expected:    []securitygroups.RuleSelector{{Direction: "egress"}, {Port: lo.ToPtr(int32(443))}},
+		},
+		{
+			selectorStr: "direction:inbound",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "port:ssh",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "tag:Name=web",
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.selectorStr, func(t *testing.T) {
+			ruleSelectors, err := securitygroups.ParseRuleSelectors(tc.selectorStr)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(ruleSelectors, tc.expected) {
+				t.Errorf("expected %+v, got %+v", tc.expected, ruleSelectors)
+			}
+		})
+	}
+}
+
+func TestRuleSelectorMatches(t *testing.T) {
+	ssh := securitygroups.Rule{SecurityGroupRule: ec2types.SecurityGroupRule{IpProtocol: aws.String("tcp"), FromPort: aws.Int32(22), ToPort: aws.Int32(22), IsEgress: aws.Bool(false)}}
+	ephemeral := securitygroups.Rule{SecurityGroupRule: ec2types.SecurityGroupRule{IpProtocol: aws.String("udp"), FromPort: aws.Int32(1024), ToPort: aws.Int32(65535), IsEgress: aws.Bool(false)}}
+	allEgress := securitygroups.Rule{SecurityGroupRule: ec2types.SecurityGroupRule{IpProtocol: aws.String("-1"), FromPort: aws.Int32(-1), ToPort: aws.Int32(-1), IsEgress: aws.Bool(true)}}
+	icmp := securitygroups.Rule{SecurityGroupRule: ec2types.SecurityGroupRule{IpProtocol: aws.String("icmp"), FromPort: aws.Int32(8), ToPort: aws.Int32(0), IsEgress: aws.Bool(false)}}
+	for _, tc := range []struct {
+		name     string
+		selector securitygroups.RuleSelector
+		rule     securitygroups.Rule
+		expected bool
+	}{
+		{name: "port in range", selector: securitygroups.RuleSelector{Port: lo.ToPtr(int32(5000))}, rule: ephemeral, expected: true},
+		{name: "port out of range", selector: securitygroups.RuleSelector{Port: lo.ToPtr(int32(443))}, rule: ssh, expected: false},
+		{name: "all protocols match any port", selector: securitygroups.RuleSelector{Port: lo.ToPtr(int32(443))}, rule: allEgress, expected: true},
+		{name: "icmp has no ports", selector: securitygroups.RuleSelector{Port: lo.ToPtr(int32(8))}, rule: icmp, expected: false},
+		{name: "direction", selector: securitygroups.RuleSelector{Direction: "ingress"}, rule: allEgress, expected: false},
+		{name: "direction and port", selector: securitygroups.RuleSelector{Direction: "ingress", Port: lo.ToPtr(int32(22))}, rule: ssh, expected: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if matches := tc.selector.Matches(tc.rule); matches != tc.expected {
+				t.Errorf("expected %t, got %t", tc.expected, matches)
+			}
+		})
+	}
+}
+
+type fakeEC2 struct {
+	securitygroups.SDKSecurityGroupOps
+	rules []ec2types.SecurityGroupRule
+}
+
+func (f fakeEC2) DescribeSecurityGroupRules(_ context.Context, input *ec2.DescribeSecurityGroupRulesInput, _ ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupRulesOutput, error) {
+	groupIDs := input.Filters[0].Values
+	return &ec2.DescribeSecurityGroupRulesOutput{SecurityGroupRules: lo.Filter(f.rules, func(rule ec2types.SecurityGroupRule, _ int) bool {
+		return lo.Contains(groupIDs, lo.FromPtr(rule.GroupId))
+	})}, nil
+}
+
+func TestResolveGroupRules(t *testing.T) {
+	watcher := securitygroups.NewWatcher(fakeEC2{rules: []ec2types.SecurityGroupRule{
+		{GroupId: aws.String("sg-1"), IpProtocol: aws.String("tcp"), FromPort: aws.Int32(22), ToPort: aws.Int32(22), IsEgress: aws.Bool(false), CidrIpv4: aws.String("10.0.0.0/8")},
+		{GroupId: aws.String("sg-1"), IpProtocol: aws.String("tcp"), FromPort: aws.Int32(8000), ToPort: aws.Int32(9000), IsEgress: aws.Bool(false),
+			ReferencedGroupInfo: &ec2types.ReferencedSecurityGroup{GroupId: aws.String("sg-2")}},
+		{GroupId: aws.String("sg-1"), IpProtocol: aws.String("-1"), FromPort: aws.Int32(-1), ToPort: aws.Int32(-1), IsEgress: aws.Bool(true), CidrIpv4: aws.String("0.0.0.0/0")},
+		{GroupId: aws.String("sg-3"), IpProtocol: aws.String("-1"), IsEgress: aws.Bool(true), CidrIpv4: aws.String("0.0.0.0/0")},
+	}})
+	securityGroups, err := watcher.ResolveGroupRules(context.Background(), []securitygroups.SecurityGroup{
+		{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-1"), GroupName: aws.String("web"), VpcId: aws.String("vpc-1")}},
+		{SecurityGroup: ec2types.SecurityGroup{GroupId: aws.String("sg-2")}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(securityGroups[0].Rules) != 3 || len(securityGroups[1].Rules) != 0 {
+		t.Fatalf("expected 3 rules of sg-1 and none of sg-2, got %d and %d", len(securityGroups[0].Rules), len(securityGroups[1].Rules))
+	}
+	expected := securitygroups.PrettySecurityGroup{
+		Name:         "web",
+		ID:           "sg-1",
+		VPC:          "vpc-1",
+		Ingress:      2,
+		Egress:       1,
+		IngressRules: "tcp:22 from 10.0.0.0/8, tcp:8000-9000 from sg-2",
+		EgressRules:  "all to 0.0.0.0/0",
+	}
+	if pretty := securityGroups[0].Prettify(); pretty != expected {
+		t.Errorf("expected %+v, got %+v", expected, pretty)
+	}
+}
@@ -0,0 +1,88 @@
+package serialconsoles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+)
+
+const (
+	// sshName is the SSH client that serial console sessions are opened with
+	sshName = "ssh"
+	// sshKeygenName generates the one-time key of a serial console session
+	sshKeygenName = "ssh-keygen"
+)
+
+// Watcher opens EC2 serial console sessions on instances
+type Watcher struct {
+	instanceConnectAPI SDKInstanceConnectOps
+	region             string
+}
+
+// SDKInstanceConnectOps is the EC2 Instance Connect SDK client method that serial console sessions need
+type SDKInstanceConnectOps interface {
+	SendSerialConsoleSSHPublicKey(context.Context, *ec2instanceconnect.SendSerialConsoleSSHPublicKeyInput, ...func(*ec2instanceconnect.Options)) (*ec2instanceconnect.SendSerialConsoleSSHPublicKeyOutput, error)
+}
+
+// NewWatcher creates a new Serial Console Watcher
+func NewWatcher(awsCfg aws.Config, instanceConnectAPI SDKInstanceConnectOps) Watcher {
+	return Watcher{
+		instanceConnectAPI: instanceConnectAPI,
+		region:             awsCfg.Region,
+	}
+}
+
+// Attach connects the terminal to the serial port of the instance and blocks until the session exits.
+// A one-time key is generated and pushed with EC2 Instance Connect, which allows it for 60 seconds, and the session is opened with ssh.
+// The serial console does not need the instance's network or SSM agent, but logging in requires a user with a password on the instance.
+func (w Watcher) Attach(ctx context.Context, instanceID string, serialPort int32) error {
+	sshPath, err := exec.LookPath(sshName)
+	if err != nil {
+		return fmt.Errorf("%s is required to connect to serial consoles: %w", sshName, err)
+	}
+	sshKeygenPath, err := exec.LookPath(sshKeygenName)
+	if err != nil {
+		return fmt.Errorf("%s is required to connect to serial consoles: %w", sshKeygenName, err)
+	}
+	keyDir, err := os.MkdirTemp("", "nimbus-serial-console-")
+	if err != nil {
+		return fmt.Errorf("failed to create serial console key directory: %w", err)
+	}
+	defer os.RemoveAll(keyDir)
+	keyPath := filepath.Join(keyDir, "id_ed25519")
+	if out, err := exec.CommandContext(ctx, sshKeygenPath, "-q", "-t", "ed25519", "-N", "", "-f", keyPath).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to generate serial console key: %w: %s", err, strings.TrimSpace(string(out)))
+	}
+	publicKey, err := os.ReadFile(keyPath + ".pub")
+	if err != nil {
+		return fmt.Errorf("failed to read serial console key: %w", err)
+	}
+	if _, err := w.instanceConnectAPI.SendSerialConsoleSSHPublicKey(ctx, &ec2instanceconnect.SendSerialConsoleSSHPublicKeyInput{
+		InstanceId:   aws.String(instanceID),
+		SerialPort:   serialPort,
+		SSHPublicKey: aws.String(string(publicKey)),
+	}); err != nil {
+		return fmt.Errorf("failed to send serial console key to instance %s: %w", instanceID, err)
+	}
+	// ssh is not bound to ctx, interrupts are forwarded to the serial console rather than ending the session
+	session := exec.Command(sshPath, "-i", keyPath, "-o", "IdentitiesOnly=yes", Target(instanceID, serialPort, w.region))
+	session.Stdin, session.Stdout, session.Stderr = os.Stdin, os.Stdout, os.Stderr
+	signal.Ignore(os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+	if err := session.Run(); err != nil {
+		return fmt.Errorf("serial console session on instance %s failed: %w", instanceID, err)
+	}
+	return nil
+}
+
+// Target returns the ssh destination of the instance's serial port in the region
+func Target(instanceID string, serialPort int32, region string) string {
+	return fmt.Sprintf("%s.port%d@serial-console.ec2-instance-connect.%s.aws", instanceID, serialPort, region)
+}
@@ -0,0 +1,24 @@
+package serialconsoles_test
+
+import (
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/providers/serialconsoles"
+)
+
+func TestTarget(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		serialPort int32
+		expected   string
+	}{
+		{name: "default port", expected: "i-1.port0@serial-console.ec2-instance-connect.us-west-2.aws"},
+		{name: "second port", serialPort: 1, expected: "i-1.port1@serial-console.ec2-instance-connect.us-west-2.aws"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if target := serialconsoles.Target("i-1", tc.serialPort, "us-west-2"); target != tc.expected {
+				t.Errorf("expected %s, got %s", tc.expected, target)
+			}
+		})
+	}
+}
@@ -0,0 +1,399 @@
+package sessions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/samber/lo"
+)
+
+const (
+	// DocumentShellScript runs commands with sh on Linux and macOS instances
+	DocumentShellScript = "AWS-RunShellScript"
+	// DocumentPowerShellScript runs commands with PowerShell on Windows instances
+	DocumentPowerShellScript = "AWS-RunPowerShellScript"
+
+	// pluginName is the Session Manager plugin that the AWS CLI also uses to stream interactive sessions
+	pluginName = "session-manager-plugin"
+	// pluginInstallURL documents how to install the Session Manager plugin
+	pluginInstallURL = "https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html"
+	// commandPollInterval is how often command invocations are checked for completion
+	commandPollInterval = time.Second
+	// onlinePollInterval is how often instances are checked for registration with SSM
+	onlinePollInterval = 5 * time.Second
+	// maxCommentLength is the longest comment that SSM accepts for a command
+	maxCommentLength = 100
+	// maxInstancesPerCommand is the most instances that SSM accepts in a command
+	maxInstancesPerCommand = 50
+)
+
+// Watcher opens SSM sessions and runs SSM commands on instances
+type Watcher struct {
+	ssmAPI   SDKSSMOps
+	region   string
+	endpoint string
+}
+
+// SDKSSMOps is an interface that combines the necessary SSM SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKSSMOps interface {
+	StartSession(context.Context, *ssm.StartSessionInput, ...func(*ssm.Options)) (*ssm.StartSessionOutput, error)
+	TerminateSession(context.Context, *ssm.TerminateSessionInput, ...func(*ssm.Options)) (*ssm.TerminateSessionOutput, error)
+	SendCommand(context.Context, *ssm.SendCommandInput, ...func(*ssm.Options)) (*ssm.SendCommandOutput, error)
+	GetCommandInvocation(context.Context, *ssm.GetCommandInvocationInput, ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error)
+	ssm.ListCommandsAPIClient
+	DescribeInstanceInformation(context.Context, *ssm.DescribeInstanceInformationInput, ...func(*ssm.Options)) (*ssm.DescribeInstanceInformationOutput, error)
+	DescribeDocument(context.Context, *ssm.DescribeDocumentInput, ...func(*ssm.Options)) (*ssm.DescribeDocumentOutput, error)
+}
+
+// Command is a one-shot command to run on instances
+type Command struct {
+	InstanceIDs []string
+	// Script is run by the document's shell, it may span multiple lines
+	Script string
+	// Document is DocumentShellScript or DocumentPowerShellScript, defaults to DocumentShellScript
+	Document string
+	// Timeout is how long the script may run on the instance before it is stopped, defaults to the document's timeout of 1 hour
+	Timeout time.Duration
+	// Comment is recorded with the command in SSM's command history, which is how Invocations finds it, defaults to "nimbus exec"
+	Comment string
+	// MaxParallel is the most instances that run the script at once, zero runs it on every instance at once
+	MaxParallel int
+	// FailFast stops sending the command to more instances once it did not succeed on one.
+	// Instances that already run it finish, the instances it was not sent to are Cancelled.
+	FailFast bool
+}
+
+// CommandResult is the outcome of a command on a single instance
+type CommandResult struct {
+	InstanceID string
+	Status     ssmtypes.CommandInvocationStatus
+	// ExitCode is the exit code of the script, -1 if it did not run to completion
+	ExitCode int32
+	// Stdout and Stderr are truncated by SSM to their first 24,000 characters
+	Stdout string
+	Stderr string
+	// Duration is how long the script ran on the instance
+	Duration time.Duration
+}
+
+// Invocation is a command that was run on an instance, read back from SSM's command history
+type Invocation struct {
+	CommandResult
+	CommandID string
+	// Requested is when the command was sent
+	Requested time.Time
+}
+
+// NewWatcher creates a new Session Watcher
+func NewWatcher(awsCfg aws.Config, ssmAPI SDKSSMOps) Watcher {
+	return Watcher{
+		ssmAPI:   ssmAPI,
+		region:   awsCfg.Region,
+		endpoint: endpoint(awsCfg),
+	}
+}
+
+// Attach opens an interactive shell on the instance through SSM Session Manager and blocks until the shell exits.
+// The session is streamed by the Session Manager plugin, which is the same plugin that the AWS CLI requires.
+// Profile is passed to the plugin for sessions that are encrypted with KMS, it may be empty.
+func (w Watcher) Attach(ctx context.Context, instanceID string, profile string) error {
+	pluginPath, err := exec.LookPath(pluginName)
+	if err != nil {
+		return fmt.Errorf("%s is required to start sessions, see %s: %w", pluginName, pluginInstallURL, err)
+	}
+	out, err := w.ssmAPI.StartSession(ctx, &ssm.StartSessionInput{Target: aws.String(instanceID)})
+	if err != nil {
+		return fmt.Errorf("failed to start session on instance %s: %w", instanceID, err)
+	}
+	// the plugin terminates sessions that end normally, this cleans up after the plugin fails
+	defer func() {
+		_, _ = w.ssmAPI.TerminateSession(context.WithoutCancel(ctx), &ssm.TerminateSessionInput{SessionId: out.SessionId})
+	}()
+	session, err := json.Marshal(map[string]string{
+		"SessionId":  lo.FromPtr(out.SessionId),
+		"StreamUrl":  lo.FromPtr(out.StreamUrl),
+		"TokenValue": lo.FromPtr(out.TokenValue),
+	})
+	if err != nil {
+		return err
+	}
+	params, err := json.Marshal(map[string]string{"Target": instanceID})
+	if err != nil {
+		return err
+	}
+	// the plugin is not bound to ctx, interrupts are forwarded to the remote shell by the plugin rather than ending the session
+	plugin := exec.Command(pluginPath, string(session), w.region, "StartSession", profile, string(params), w.endpoint)
+	plugin.Stdin, plugin.Stdout, plugin.Stderr = os.Stdin, os.Stdout, os.Stderr
+	signal.Ignore(os.Interrupt)
+	defer signal.Reset(os.Interrupt)
+	if err := plugin.Run(); err != nil {
+		return fmt.Errorf("session on instance %s failed: %w", instanceID, err)
+	}
+	return nil
+}
+
+// Documents returns the SSM documents that commands are run with, they are managed by AWS so they do not need to be created
+func Documents() []string {
+	return []string{DocumentShellScript, DocumentPowerShellScript}
+}
+
+// CheckDocument returns an error if the SSM document that commands are run with is not available in the region
+func (w Watcher) CheckDocument(ctx context.Context, document string) error {
+	out, err := w.ssmAPI.DescribeDocument(ctx, &ssm.DescribeDocumentInput{Name: aws.String(document)})
+	if err != nil {
+		return fmt.Errorf("failed to describe SSM document %s: %w", document, err)
+	}
+	if out.Document == nil || out.Document.Status != ssmtypes.DocumentStatusActive {
+		return fmt.Errorf("SSM document %s is not active", document)
+	}
+	return nil
+}
+
+// RunCommand runs the command's script on its instances with SSM Run Command and waits for every instance to finish.
+// Instances must be managed by SSM, which requires the SSM agent and an instance profile that allows it.
+// Instances are sent the command in batches of at most 50, which is SSM's limit, and MaxParallel bounds the instances of the batches that run at once.
+// The results are in the order of the command's instances.
+func (w Watcher) RunCommand(ctx context.Context, command Command) ([]CommandResult, error) {
+	if len(command.InstanceIDs) == 0 {
+		return nil, nil
+	}
+	batchSize := maxInstancesPerCommand
+	if command.MaxParallel > 0 {
+		batchSize = min(batchSize, command.MaxParallel)
+	}
+	batches := lo.Chunk(command.InstanceIDs, batchSize)
+	slots := make(chan struct{}, lo.Ternary(command.MaxParallel > 0, max(1, command.MaxParallel/batchSize), len(batches)))
+	results := make([][]CommandResult, len(batches))
+	errs := make([]error, len(batches))
+	var failed atomic.Bool
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		slots <- struct{}{}
+		// batches wait for a slot, so a failure of a running batch is seen before the next one is sent
+		if command.FailFast && failed.Load() {
+			results[i] = lo.Map(batch, func(instanceID string, _ int) CommandResult {
+				return CommandResult{InstanceID: instanceID, Status: ssmtypes.CommandInvocationStatusCancelled, ExitCode: -1}
+			})
+			<-slots
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			results[i], errs[i] = w.runBatch(ctx, command, batch)
+			if errs[i] != nil || lo.SomeBy(results[i], func(result CommandResult) bool { return !result.Succeeded() }) {
+				failed.Store(true)
+			}
+		}()
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return lo.Flatten(results), nil
+}
+
+// runBatch sends the command to a batch of its instances and waits for every instance to finish
+func (w Watcher) runBatch(ctx context.Context, command Command, instanceIDs []string) ([]CommandResult, error) {
+	parameters := map[string][]string{"commands": {command.Script}}
+	if command.Timeout > 0 {
+		parameters["executionTimeout"] = []string{strconv.Itoa(int(command.Timeout.Seconds()))}
+	}
+	out, err := w.ssmAPI.SendCommand(ctx, &ssm.SendCommandInput{
+		DocumentName: aws.String(lo.CoalesceOrEmpty(command.Document, DocumentShellScript)),
+		InstanceIds:  instanceIDs,
+		Parameters:   parameters,
+		Comment:      aws.String(lo.Substring(lo.CoalesceOrEmpty(command.Comment, "nimbus exec"), 0, maxCommentLength)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to send command: %w", err)
+	}
+	results := make([]CommandResult, 0, len(instanceIDs))
+	for _, instanceID := range instanceIDs {
+		result, err := w.waitForInvocation(ctx, lo.FromPtr(out.Command.CommandId), instanceID)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// waitForInvocation polls the command's invocation on the instance until it completes
+func (w Watcher) waitForInvocation(ctx context.Context, commandID, instanceID string) (CommandResult, error) {
+	ticker := time.NewTicker(commandPollInterval)
+	defer ticker.Stop()
+	for {
+		out, err := w.ssmAPI.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+			CommandId:  aws.String(commandID),
+			InstanceId: aws.String(instanceID),
+		})
+		// invocations are created asynchronously, so they may not exist right after the command is sent
+		var notFound *ssmtypes.InvocationDoesNotExist
+		if err != nil && !errors.As(err, &notFound) {
+			return CommandResult{}, fmt.Errorf("failed to get command %s on instance %s: %w", commandID, instanceID, err)
+		}
+		if err == nil && Completed(out.Status) {
+			return commandResult(instanceID, out), nil
+		}
+		select {
+		case <-ctx.Done():
+			return CommandResult{}, fmt.Errorf("command %s on instance %s did not complete: %w", commandID, instanceID, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Invocations returns the invocations of the commands with the comment that were sent since the start time, ordered by when they were sent.
+// SSM keeps the command history for 30 days, invocations that have not been created on their instance yet are Pending.
+func (w Watcher) Invocations(ctx context.Context, comment string, start time.Time) ([]Invocation, error) {
+	var invocations []Invocation
+	paginator := ssm.NewListCommandsPaginator(w.ssmAPI, &ssm.ListCommandsInput{
+		Filters: []ssmtypes.CommandFilter{{Key: ssmtypes.CommandFilterKeyInvokedAfter, Value: aws.String(start.UTC().Format(time.RFC3339))}},
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list commands: %w", err)
+		}
+		// commands cannot be filtered by their comment
+		for _, command := range lo.Filter(out.Commands, func(command ssmtypes.Command, _ int) bool { return lo.FromPtr(command.Comment) == comment }) {
+			for _, instanceID := range command.InstanceIds {
+				invocation, err := w.invocation(ctx, lo.FromPtr(command.CommandId), instanceID)
+				if err != nil {
+					return nil, err
+				}
+				invocation.Requested = lo.FromPtr(command.RequestedDateTime)
+				invocations = append(invocations, invocation)
+			}
+		}
+	}
+	slices.SortStableFunc(invocations, func(a, b Invocation) int { return a.Requested.Compare(b.Requested) })
+	return invocations, nil
+}
+
+// invocation returns the command's invocation on the instance with its output
+func (w Watcher) invocation(ctx context.Context, commandID, instanceID string) (Invocation, error) {
+	invocation := Invocation{CommandID: commandID, CommandResult: CommandResult{InstanceID: instanceID, Status: ssmtypes.CommandInvocationStatusPending, ExitCode: -1}}
+	out, err := w.ssmAPI.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+		CommandId:  aws.String(commandID),
+		InstanceId: aws.String(instanceID),
+	})
+	var notFound *ssmtypes.InvocationDoesNotExist
+	if errors.As(err, &notFound) {
+		return invocation, nil
+	}
+	if err != nil {
+		return Invocation{}, fmt.Errorf("failed to get command %s on instance %s: %w", commandID, instanceID, err)
+	}
+	invocation.CommandResult = commandResult(instanceID, out)
+	return invocation, nil
+}
+
+// commandResult returns the result of an invocation on the instance
+func commandResult(instanceID string, out *ssm.GetCommandInvocationOutput) CommandResult {
+	result := CommandResult{
+		InstanceID: instanceID,
+		Status:     out.Status,
+		ExitCode:   out.ResponseCode,
+		Stdout:     lo.FromPtr(out.StandardOutputContent),
+		Stderr:     lo.FromPtr(out.StandardErrorContent),
+	}
+	// the execution times are strings, and the end time is empty until the invocation completes
+	start, startErr := time.Parse(time.RFC3339Nano, lo.FromPtr(out.ExecutionStartDateTime))
+	end, endErr := time.Parse(time.RFC3339Nano, lo.FromPtr(out.ExecutionEndDateTime))
+	if startErr == nil && endErr == nil {
+		result.Duration = end.Sub(start)
+	}
+	return result
+}
+
+// CommandComment returns the comment of the commands that exec runs on the instances of namespace/name
+func CommandComment(namespace, name string) string {
+	return fmt.Sprintf("nimbus exec %s/%s", namespace, name)
+}
+
+// WaitForOnline waits until every instance is registered with SSM and its agent is online, which is when sessions and commands can reach it
+func (w Watcher) WaitForOnline(ctx context.Context, instanceIDs []string) error {
+	pending := lo.Uniq(instanceIDs)
+	ticker := time.NewTicker(onlinePollInterval)
+	defer ticker.Stop()
+	for len(pending) != 0 {
+		online, err := w.onlineInstances(ctx, pending)
+		if err != nil {
+			return err
+		}
+		pending = lo.Without(pending, online...)
+		if len(pending) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("instances %s did not register with SSM: %w", strings.Join(pending, ", "), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// onlineInstances returns the IDs of the instances whose SSM agent is online
+func (w Watcher) onlineInstances(ctx context.Context, instanceIDs []string) ([]string, error) {
+	var online []string
+	paginator := ssm.NewDescribeInstanceInformationPaginator(w.ssmAPI, &ssm.DescribeInstanceInformationInput{
+		Filters: []ssmtypes.InstanceInformationStringFilter{{Key: aws.String("InstanceIds"), Values: instanceIDs}},
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe SSM instance information: %w", err)
+		}
+		for _, info := range out.InstanceInformationList {
+			if info.PingStatus == ssmtypes.PingStatusOnline {
+				online = append(online, lo.FromPtr(info.InstanceId))
+			}
+		}
+	}
+	return online, nil
+}
+
+// Completed is true when the invocation status is final
+func Completed(status ssmtypes.CommandInvocationStatus) bool {
+	return lo.Contains([]ssmtypes.CommandInvocationStatus{
+		ssmtypes.CommandInvocationStatusSuccess,
+		ssmtypes.CommandInvocationStatusFailed,
+		ssmtypes.CommandInvocationStatusCancelled,
+		ssmtypes.CommandInvocationStatusTimedOut,
+	}, status)
+}
+
+// Succeeded is true when the command ran to completion with a zero exit code
+func (r CommandResult) Succeeded() bool {
+	return r.Status == ssmtypes.CommandInvocationStatusSuccess
+}
+
+// endpoint returns the SSM endpoint of the config's region, or the config's base endpoint if it is set
+func endpoint(awsCfg aws.Config) string {
+	if awsCfg.BaseEndpoint != nil {
+		return *awsCfg.BaseEndpoint
+	}
+	if strings.HasPrefix(awsCfg.Region, "cn-") {
+		return fmt.Sprintf("https://ssm.%s.amazonaws.com.cn", awsCfg.Region)
+	}
+	return fmt.Sprintf("https://ssm.%s.amazonaws.com", awsCfg.Region)
+}
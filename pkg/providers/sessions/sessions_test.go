@@ -0,0 +1,203 @@
+package sessions_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
+)
+
+type fakeSSM struct {
+	sessions.SDKSSMOps
+	mu          sync.Mutex
+	sent        *ssm.SendCommandInput
+	batches     [][]string
+	invocations map[string][]*ssm.GetCommandInvocationOutput
+	commands    []ssmtypes.Command
+	listInput   *ssm.ListCommandsInput
+}
+
+func (f *fakeSSM) ListCommands(_ context.Context, input *ssm.ListCommandsInput, _ ...func(*ssm.Options)) (*ssm.ListCommandsOutput, error) {
+	f.listInput = input
+	return &ssm.ListCommandsOutput{Commands: f.commands}, nil
+}
+
+func (f *fakeSSM) SendCommand(_ context.Context, input *ssm.SendCommandInput, _ ...func(*ssm.Options)) (*ssm.SendCommandOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sent = input
+	f.batches = append(f.batches, input.InstanceIds)
+	return &ssm.SendCommandOutput{Command: &ssmtypes.Command{CommandId: aws.String("cmd-123")}}, nil
+}
+
+// GetCommandInvocation returns the instance's invocations in order, a nil invocation has not been created yet
+func (f *fakeSSM) GetCommandInvocation(_ context.Context, input *ssm.GetCommandInvocationInput, _ ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	invocations := f.invocations[*input.InstanceId]
+	invocation := invocations[0]
+	if len(invocations) > 1 {
+		f.invocations[*input.InstanceId] = invocations[1:]
+	}
+	if invocation == nil {
+		return nil, &ssmtypes.InvocationDoesNotExist{}
+	}
+	return invocation, nil
+}
+
+func TestRunCommand(t *testing.T) {
+	type testCases struct {
+		name        string
+		invocations map[string][]*ssm.GetCommandInvocationOutput
+		expected    []sessions.CommandResult
+	}
+
+	for _, tc := range []testCases{
+		{
+			name: "success",
+			invocations: map[string][]*ssm.GetCommandInvocationOutput{
+				"i-123": {{Status: ssmtypes.CommandInvocationStatusSuccess, StandardOutputContent: aws.String("ok\n")}},
+			},
+			expected: []sessions.CommandResult{{InstanceID: "i-123", Status: ssmtypes.CommandInvocationStatusSuccess, Stdout: "ok\n"}},
+		},
+		{
+			name: "waits for invocations to be created and complete",
+			invocations: map[string][]*ssm.GetCommandInvocationOutput{
+				"i-123": {nil, {Status: ssmtypes.CommandInvocationStatusInProgress}, {Status: ssmtypes.CommandInvocationStatusFailed, ResponseCode: 2, StandardErrorContent: aws.String("oops")}},
+				"i-456": {{Status: ssmtypes.CommandInvocationStatusSuccess}},
+			},
+			expected: []sessions.CommandResult{
+				{InstanceID: "i-123", Status: ssmtypes.CommandInvocationStatusFailed, ExitCode: 2, Stderr: "oops"},
+				{InstanceID: "i-456", Status: ssmtypes.CommandInvocationStatusSuccess},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			api := &fakeSSM{invocations: tc.invocations}
+			watcher := sessions.NewWatcher(aws.Config{Region: "us-east-1"}, api)
+			var instanceIDs []string
+			for _, result := range tc.expected {
+				instanceIDs = append(instanceIDs, result.InstanceID)
+			}
+			results, err := watcher.RunCommand(context.Background(), sessions.Command{InstanceIDs: instanceIDs, Script: "echo ok"})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if *api.sent.DocumentName != sessions.DocumentShellScript {
+				t.Errorf("expected document %s, got %s", sessions.DocumentShellScript, *api.sent.DocumentName)
+			}
+			if len(results) != len(tc.expected) {
+				t.Fatalf("expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, result := range results {
+				if result != tc.expected[i] {
+					t.Errorf("expected result %+v, got %+v", tc.expected[i], result)
+				}
+			}
+		})
+	}
+}
+
+func TestInvocations(t *testing.T) {
+	start := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	comment := sessions.CommandComment("dev", "web")
+	api := &fakeSSM{
+		commands: []ssmtypes.Command{
+			{CommandId: aws.String("cmd-2"), Comment: aws.String(comment), InstanceIds: []string{"i-456"}, RequestedDateTime: aws.Time(start.Add(2 * time.Minute))},
+			{CommandId: aws.String("cmd-other"), Comment: aws.String(sessions.CommandComment("dev", "api")), InstanceIds: []string{"i-789"}, RequestedDateTime: aws.Time(start)},
+			{CommandId: aws.String("cmd-1"), Comment: aws.String(comment), InstanceIds: []string{"i-123", "i-456"}, RequestedDateTime: aws.Time(start.Add(time.Minute))},
+		},
+		invocations: map[string][]*ssm.GetCommandInvocationOutput{
+			"i-123": {{Status: ssmtypes.CommandInvocationStatusSuccess, StandardOutputContent: aws.String("ok\n")}},
+			// the commands are read in the order they are listed, cmd-2 has not been created on i-456 yet
+			"i-456": {nil, {Status: ssmtypes.CommandInvocationStatusFailed, ResponseCode: 1, StandardErrorContent: aws.String("oops")}},
+		},
+	}
+	invocations, err := sessions.NewWatcher(aws.Config{Region: "us-east-1"}, api).Invocations(context.Background(), comment, start)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value := aws.ToString(api.listInput.Filters[0].Value); value != "2024-01-02T03:04:05Z" {
+		t.Errorf("expected commands invoked after 2024-01-02T03:04:05Z, got %s", value)
+	}
+	expected := []sessions.Invocation{
+		{CommandID: "cmd-1", Requested: start.Add(time.Minute), CommandResult: sessions.CommandResult{InstanceID: "i-123", Status: ssmtypes.CommandInvocationStatusSuccess, Stdout: "ok\n"}},
+		{CommandID: "cmd-1", Requested: start.Add(time.Minute), CommandResult: sessions.CommandResult{InstanceID: "i-456", Status: ssmtypes.CommandInvocationStatusFailed, ExitCode: 1, Stderr: "oops"}},
+		{CommandID: "cmd-2", Requested: start.Add(2 * time.Minute), CommandResult: sessions.CommandResult{InstanceID: "i-456", Status: ssmtypes.CommandInvocationStatusPending, ExitCode: -1}},
+	}
+	if len(invocations) != len(expected) {
+		t.Fatalf("expected %d invocations, got %d", len(expected), len(invocations))
+	}
+	for i, invocation := range invocations {
+		if invocation != expected[i] {
+			t.Errorf("expected invocation %+v, got %+v", expected[i], invocation)
+		}
+	}
+}
+
+func TestRunCommandBatches(t *testing.T) {
+	succeeded := &ssm.GetCommandInvocationOutput{Status: ssmtypes.CommandInvocationStatusSuccess}
+	failed := &ssm.GetCommandInvocationOutput{Status: ssmtypes.CommandInvocationStatusFailed, ResponseCode: 1}
+	instanceIDs := func(n int) []string {
+		ids := make([]string, n)
+		for i := range ids {
+			ids[i] = fmt.Sprintf("i-%03d", i)
+		}
+		return ids
+	}
+
+	t.Run("sends at most 50 instances per command", func(t *testing.T) {
+		api := &fakeSSM{invocations: map[string][]*ssm.GetCommandInvocationOutput{}}
+		for _, id := range instanceIDs(120) {
+			api.invocations[id] = []*ssm.GetCommandInvocationOutput{succeeded}
+		}
+		results, err := sessions.NewWatcher(aws.Config{}, api).RunCommand(context.Background(), sessions.Command{InstanceIDs: instanceIDs(120), Script: "true"})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(api.batches) != 3 {
+			t.Errorf("expected 3 commands, got %d", len(api.batches))
+		}
+		for i, result := range results {
+			if result.InstanceID != instanceIDs(120)[i] {
+				t.Fatalf("expected the results in the order of the instances, got %s at %d", result.InstanceID, i)
+			}
+		}
+	})
+	t.Run("fails fast", func(t *testing.T) {
+		api := &fakeSSM{invocations: map[string][]*ssm.GetCommandInvocationOutput{
+			"i-000": {succeeded},
+			"i-001": {failed},
+			"i-002": {succeeded},
+			"i-003": {succeeded},
+		}}
+		results, err := sessions.NewWatcher(aws.Config{}, api).RunCommand(context.Background(), sessions.Command{
+			InstanceIDs: instanceIDs(4),
+			Script:      "true",
+			MaxParallel: 1,
+			FailFast:    true,
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(api.batches) != 2 {
+			t.Errorf("expected the command to be sent to 2 instances one at a time, got %v", api.batches)
+		}
+		for i, status := range []ssmtypes.CommandInvocationStatus{
+			ssmtypes.CommandInvocationStatusSuccess,
+			ssmtypes.CommandInvocationStatusFailed,
+			ssmtypes.CommandInvocationStatusCancelled,
+			ssmtypes.CommandInvocationStatusCancelled,
+		} {
+			if results[i].Status != status {
+				t.Errorf("expected %s on %s, got %s", status, results[i].InstanceID, results[i].Status)
+			}
+		}
+	})
+}
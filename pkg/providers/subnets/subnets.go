@@ -3,13 +3,16 @@ package subnets
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
@@ -38,6 +41,8 @@ type Selector struct {
 	Tags  map[string]string
 	ID    string
 	VPCID string
+	// DefaultForAZ selects the default subnets of the default VPC
+	DefaultForAZ bool
 }
 
 // Subnet represent an AWS Subnet
@@ -46,11 +51,32 @@ type Subnet struct {
 	ec2types.Subnet
 }
 
+// IsPublic returns true if instances launched into the subnet get a public IP. IPv6-only subnets are public,
+// their instances get a globally unique IPv6 address and nimbus only creates them with a route to an Internet Gateway.
+func (s Subnet) IsPublic() bool {
+	return lo.FromPtr(s.MapPublicIpOnLaunch) || lo.FromPtr(s.Ipv6Native)
+}
+
+// IPv6CIDR returns the IPv6 CIDR block that is associated with the subnet, or an empty string if it has none
+func (s Subnet) IPv6CIDR() string {
+	association, _ := lo.Find(s.Ipv6CidrBlockAssociationSet, func(association ec2types.SubnetIpv6CidrBlockAssociation) bool {
+		return association.Ipv6CidrBlockState == nil || lo.Contains([]ec2types.SubnetCidrBlockStateCode{
+			ec2types.SubnetCidrBlockStateCodeAssociating, ec2types.SubnetCidrBlockStateCodeAssociated,
+		}, association.Ipv6CidrBlockState.State)
+	})
+	return lo.FromPtr(association.Ipv6CidrBlock)
+}
+
 // SubnetSpec is used to specify parameters for creating a subnet
 type SubnetSpec struct {
-	AZ     string
+	AZ string
+	// CIDR is the IPv4 CIDR block of the subnet, it is empty for IPv6-only subnets
 	CIDR   string
 	Public bool
+	// IPv6CIDR is the optional IPv6 CIDR block of the subnet, instances launched into the subnet are assigned an IPv6 address from it
+	IPv6CIDR string
+	// IPv6Only creates a subnet without an IPv4 CIDR block
+	IPv6Only bool
 }
 
 // ParseSelectors parses a string of selectors into a slice of Selector structs
@@ -68,6 +94,12 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 			switch k {
 			case "id":
 				subnetSelector.ID = v
+			case "default-for-az":
+				defaultForAZ, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid subnet default-for-az selector value %q: %w", v, err)
+				}
+				subnetSelector.DefaultForAZ = defaultForAZ
 			default:
 				return nil, fmt.Errorf("invalid subnet selector key: %s", k)
 			}
@@ -106,55 +138,60 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Subnet, e
 	return subnets, nil
 }
 
-func (w Watcher) Create(ctx context.Context, namespace, name string, vpc *vpcs.VPC, subnetSpecs []SubnetSpec) ([]Subnet, error) {
+// Create creates a subnet for each SubnetSpec tagged with the namespaced tags and the additional tags
+func (w Watcher) Create(ctx context.Context, namespace, name string, vpc *vpcs.VPC, subnetSpecs []SubnetSpec, tags map[string]string) ([]Subnet, error) {
 	if len(subnetSpecs) == 0 {
 		return nil, fmt.Errorf("no subnet specs received")
 	}
-	var subnetOutputs []*ec2.CreateSubnetOutput
-	// Create subnets
-	for _, subnet := range subnetSpecs {
-		subnetType := lo.Ternary(subnet.Public, subnetTypePublic, subnetTypePrivate)
+	var subnetList []Subnet
+	for _, subnetSpec := range subnetSpecs {
+		subnetType := lo.Ternary(subnetSpec.Public, subnetTypePublic, subnetTypePrivate)
 		subnetOutput, err := w.subnetAPI.CreateSubnet(ctx, &ec2.CreateSubnetInput{
 			VpcId:            vpc.VpcId,
-			AvailabilityZone: &subnet.AZ,
-			CidrBlock:        &subnet.CIDR,
+			AvailabilityZone: &subnetSpec.AZ,
+			CidrBlock:        lo.Ternary(subnetSpec.IPv6Only, nil, &subnetSpec.CIDR),
+			Ipv6CidrBlock:    lo.Ternary(subnetSpec.IPv6CIDR == "", nil, &subnetSpec.IPv6CIDR),
+			Ipv6Native:       lo.Ternary(subnetSpec.IPv6Only, aws.Bool(true), nil),
 			TagSpecifications: []types.TagSpecification{{
 				ResourceType: types.ResourceTypeSubnet,
-				Tags:         tagutils.EC2NamespacedTags(namespace, name),
+				Tags:         tagutils.MapToEC2Tags(lo.Assign(tags, tagutils.NamespacedTags(namespace, name))),
 			}},
 		})
 		if err != nil {
 			return nil, err
 		}
-		if subnetType == subnetTypePublic {
-			subnetOutput.Subnet.MapPublicIpOnLaunch = aws.Bool(true)
-		}
-		subnetOutputs = append(subnetOutputs, subnetOutput)
-	}
-	// Modify any subnet attributes that we can't set on creation
-	for _, subnet := range subnetOutputs {
-		subnetOpts, ok := lo.Find(subnetSpecs, func(subnetSpec SubnetSpec) bool { return subnetSpec.CIDR == *subnet.Subnet.CidrBlock })
-		if !ok {
-			return nil, fmt.Errorf("unable to find SubnetSpec for subnet %s - %s", *subnet.Subnet.AvailabilityZone, *subnet.Subnet.CidrBlock)
-		}
-		// Can only modify 1 subnet attribute at a time
-		if subnetOpts.Public {
+		subnet := Subnet{Subnet: *subnetOutput.Subnet}
+		// Modify any subnet attributes that we can't set on creation, only 1 subnet attribute can be modified at a time
+		if subnetType == subnetTypePublic && !subnetSpec.IPv6Only {
 			if _, err := w.subnetAPI.ModifySubnetAttribute(ctx, &ec2.ModifySubnetAttributeInput{
-				SubnetId:            subnet.Subnet.SubnetId,
+				SubnetId:            subnet.SubnetId,
 				MapPublicIpOnLaunch: &types.AttributeBooleanValue{Value: aws.Bool(true)},
 			}); err != nil {
 				return nil, err
 			}
+			subnet.MapPublicIpOnLaunch = aws.Bool(true)
 		}
+		if subnetSpec.IPv6CIDR != "" {
+			if _, err := w.subnetAPI.ModifySubnetAttribute(ctx, &ec2.ModifySubnetAttributeInput{
+				SubnetId:                    subnet.SubnetId,
+				AssignIpv6AddressOnCreation: &types.AttributeBooleanValue{Value: aws.Bool(true)},
+			}); err != nil {
+				return nil, err
+			}
+			subnet.AssignIpv6AddressOnCreation = aws.Bool(true)
+		}
+		subnetList = append(subnetList, subnet)
 	}
-	return lo.Map(subnetOutputs, func(out *ec2.CreateSubnetOutput, _ int) Subnet { return Subnet{Subnet: *out.Subnet} }), nil
+	return subnetList, nil
 }
 
 func (w Watcher) Delete(ctx context.Context, subnetID string) error {
-	_, err := w.subnetAPI.DeleteSubnet(ctx, &ec2.DeleteSubnetInput{
-		SubnetId: &subnetID,
+	return retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.subnetAPI.DeleteSubnet(ctx, &ec2.DeleteSubnetInput{
+			SubnetId: &subnetID,
+		})
+		return err
 	})
-	return err
 }
 
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
@@ -176,6 +213,12 @@ func filterSets(selectorList []Selector) [][]ec2types.Filter {
 				Values: []string{term.VPCID},
 			})
 		}
+		if term.DefaultForAZ {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("default-for-az"),
+				Values: []string{"true"},
+			})
+		}
 		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
 		filterResult = append(filterResult, filters)
 	}
@@ -0,0 +1,180 @@
+package tags
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// maxResourcesPerCall is the number of resource IDs sent in a single DescribeTags filter or CreateTags call
+	maxResourcesPerCall = 200
+)
+
+// Watcher discovers tagged EC2 resources of any type based on selectors
+type Watcher struct {
+	tagsAPI SDKTagsOps
+}
+
+// SDKTagsOps is an interface that combines the necessary EC2 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKTagsOps interface {
+	ec2.DescribeTagsAPIClient
+	CreateTags(context.Context, *ec2.CreateTagsInput, ...func(*ec2.Options)) (*ec2.CreateTagsOutput, error)
+}
+
+// Selector is a struct that represents a tagged resource selector
+type Selector struct {
+	Tags map[string]string
+	ID   string
+	// Type is an EC2 resource type, e.g. instance, security-group, or launch-template
+	Type string
+}
+
+// TaggedResource is an EC2 resource of any type and its tags
+type TaggedResource struct {
+	ID   string `table:"ID"`
+	Type string `table:"Type"`
+	Tags map[string]string
+}
+
+// ParseSelectors parses a string of selectors into a slice of Selector structs
+func ParseSelectors(selectorStr string) ([]Selector, error) {
+	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse tag selectors: %w", err)
+	}
+	tagSelectors := make([]Selector, 0, len(selectors))
+	for _, selector := range selectors {
+		tagSelector := Selector{
+			Tags: selector.Tags,
+		}
+		for k, v := range selector.KeyVals {
+			switch k {
+			case "id":
+				tagSelector.ID = v
+			case "type":
+				tagSelector.Type = v
+			default:
+				return nil, fmt.Errorf("invalid tag selector key: %s", k)
+			}
+		}
+		tagSelectors = append(tagSelectors, tagSelector)
+	}
+	return tagSelectors, nil
+}
+
+// NewWatcher creates a new Tags Watcher
+func NewWatcher(tagsAPI SDKTagsOps) Watcher {
+	return Watcher{
+		tagsAPI: tagsAPI,
+	}
+}
+
+// Resolve returns the resources that match the provided selectors with all of their tags
+// Every tag of a Selector is looked up with a separate call and the matching resources are intersected.
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]TaggedResource, error) {
+	resourceTypes := map[string]string{}
+	for _, selector := range selectors {
+		var matches map[string]string
+		for _, filters := range filterSets(selector) {
+			found, err := w.describeTags(ctx, filters)
+			if err != nil {
+				return nil, err
+			}
+			ids := lo.SliceToMap(found, func(tag ec2types.TagDescription) (string, string) {
+				return *tag.ResourceId, string(tag.ResourceType)
+			})
+			if matches == nil {
+				matches = ids
+				continue
+			}
+			matches = lo.PickByKeys(matches, lo.Keys(ids))
+		}
+		resourceTypes = lo.Assign(resourceTypes, matches)
+	}
+
+	ids := lo.Keys(resourceTypes)
+	sort.Strings(ids)
+	resourceTags := map[string]map[string]string{}
+	for _, chunk := range lo.Chunk(ids, maxResourcesPerCall) {
+		found, err := w.describeTags(ctx, []ec2types.Filter{{Name: aws.String("resource-id"), Values: chunk}})
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range found {
+			if resourceTags[*tag.ResourceId] == nil {
+				resourceTags[*tag.ResourceId] = map[string]string{}
+			}
+			resourceTags[*tag.ResourceId][*tag.Key] = lo.FromPtr(tag.Value)
+		}
+	}
+	return lo.Map(ids, func(id string, _ int) TaggedResource {
+		return TaggedResource{ID: id, Type: resourceTypes[id], Tags: resourceTags[id]}
+	}), nil
+}
+
+// Tag creates or overwrites the tags on the resources
+func (w Watcher) Tag(ctx context.Context, resourceIDs []string, tags map[string]string) error {
+	for _, chunk := range lo.Chunk(resourceIDs, maxResourcesPerCall) {
+		if _, err := w.tagsAPI.CreateTags(ctx, &ec2.CreateTagsInput{
+			Resources: chunk,
+			Tags:      tagutils.MapToEC2Tags(tags),
+		}); err != nil {
+			return fmt.Errorf("failed to tag resources: %w", err)
+		}
+	}
+	return nil
+}
+
+func (w Watcher) describeTags(ctx context.Context, filters []ec2types.Filter) ([]ec2types.TagDescription, error) {
+	var tags []ec2types.TagDescription
+	pager := ec2.NewDescribeTagsPaginator(w.tagsAPI, &ec2.DescribeTagsInput{
+		Filters: filters,
+	})
+	for pager.HasMorePages() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to describe tags: %w", err)
+		}
+		tags = append(tags, page.Tags...)
+	}
+	return tags, nil
+}
+
+// filterSets converts a selector into a slice of filters for use with the AWS SDK
+// Each filter is executed as a separate list call and the results are intersected since DescribeTags matches individual tags rather than resources.
+func filterSets(selector Selector) [][]ec2types.Filter {
+	var common []ec2types.Filter
+	if selector.ID != "" {
+		common = append(common, ec2types.Filter{
+			Name:   aws.String("resource-id"),
+			Values: []string{selector.ID},
+		})
+	}
+	if selector.Type != "" {
+		common = append(common, ec2types.Filter{
+			Name:   aws.String("resource-type"),
+			Values: []string{selector.Type},
+		})
+	}
+	if len(selector.Tags) == 0 {
+		return [][]ec2types.Filter{common}
+	}
+	var filterResult [][]ec2types.Filter
+	for _, key := range lo.Keys(selector.Tags) {
+		filters := append([]ec2types.Filter{
+			{Name: aws.String("key"), Values: []string{key}},
+			{Name: aws.String("value"), Values: []string{selector.Tags[key]}},
+		}, common...)
+		filterResult = append(filterResult, filters)
+	}
+	return filterResult
+}
@@ -0,0 +1,180 @@
+package trails
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	cttypes "github.com/aws/aws-sdk-go-v2/service/cloudtrail/types"
+	"github.com/samber/lo"
+)
+
+// Watcher looks up CloudTrail management events based on selectors
+type Watcher struct {
+	cloudTrailAPI SDKCloudTrailOps
+}
+
+// SDKCloudTrailOps is an interface that combines the necessary CloudTrail SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKCloudTrailOps interface {
+	cloudtrail.LookupEventsAPIClient
+}
+
+// Selector is a struct that represents a CloudTrail event selector
+// An event matches if it references one of the ResourceIDs or its request was tagged with all of the Tags, e.g. a RunInstances call with tag specifications.
+type Selector struct {
+	ResourceIDs []string
+	Tags        map[string]string
+	StartTime   time.Time
+	EndTime     time.Time
+}
+
+// Event represents a CloudTrail management event
+// This is not the AWS SDK Event type, but a wrapper around it so that we can add additional data parsed from the raw CloudTrail record
+type Event struct {
+	cttypes.Event
+	SourceIPAddress string
+	UserAgent       string
+	ErrorCode       string
+	RequestID       string
+	// RequestTags are the tags that were specified in the request parameters
+	RequestTags map[string]string
+}
+
+// PrettyEvent represents an event for UI elements like the static and TUI tables
+type PrettyEvent struct {
+	Time      string `table:"Time"`
+	User      string `table:"User"`
+	Event     string `table:"Event"`
+	Resources string `table:"Resources"`
+	Error     string `table:"Error"`
+	SourceIP  string `table:"Source-IP,wide"`
+	UserAgent string `table:"User-Agent,wide"`
+	RequestID string `table:"Request-ID,wide"`
+}
+
+// cloudTrailRecord is the subset of the raw CloudTrail record that is not part of the LookupEvents response
+type cloudTrailRecord struct {
+	SourceIPAddress   string `json:"sourceIPAddress"`
+	UserAgent         string `json:"userAgent"`
+	ErrorCode         string `json:"errorCode"`
+	RequestID         string `json:"requestID"`
+	RequestParameters any    `json:"requestParameters"`
+}
+
+// NewWatcher creates a new CloudTrail Watcher
+func NewWatcher(cloudTrailAPI SDKCloudTrailOps) Watcher {
+	return Watcher{
+		cloudTrailAPI: cloudTrailAPI,
+	}
+}
+
+// Resolve returns the mutating events within the selectors' time range that match the selectors, newest first
+// LookupEvents only supports a single lookup attribute per call, so all write events in the time range are listed and matched client side.
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Event, error) {
+	events := map[string]Event{}
+	for _, selector := range selectors {
+		pager := cloudtrail.NewLookupEventsPaginator(w.cloudTrailAPI, &cloudtrail.LookupEventsInput{
+			StartTime: lo.Ternary(selector.StartTime.IsZero(), nil, aws.Time(selector.StartTime)),
+			EndTime:   lo.Ternary(selector.EndTime.IsZero(), nil, aws.Time(selector.EndTime)),
+			LookupAttributes: []cttypes.LookupAttribute{{
+				AttributeKey:   cttypes.LookupAttributeKeyReadOnly,
+				AttributeValue: aws.String("false"),
+			}},
+		})
+		for pager.HasMorePages() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to lookup cloudtrail events: %w", err)
+			}
+			for _, sdkEvent := range page.Events {
+				event := newEvent(sdkEvent)
+				if event.Matches(selector) {
+					events[lo.FromPtr(event.EventId)] = event
+				}
+			}
+		}
+	}
+	eventList := lo.Values(events)
+	sort.Slice(eventList, func(i, j int) bool {
+		return lo.FromPtr(eventList[i].EventTime).After(lo.FromPtr(eventList[j].EventTime))
+	})
+	return eventList, nil
+}
+
+// Matches returns true if the event references one of the selector's resources or was tagged with all of the selector's tags
+func (e Event) Matches(selector Selector) bool {
+	for _, resource := range e.Resources {
+		if lo.Contains(selector.ResourceIDs, lo.FromPtr(resource.ResourceName)) {
+			return true
+		}
+	}
+	if len(selector.Tags) == 0 {
+		return false
+	}
+	for k, v := range selector.Tags {
+		if tagValue, ok := e.RequestTags[k]; !ok || tagValue != v {
+			return false
+		}
+	}
+	return true
+}
+
+func (e Event) Prettify() PrettyEvent {
+	return PrettyEvent{
+		Time:  lo.FromPtr(e.EventTime).Local().Format(time.RFC3339),
+		User:  lo.FromPtr(e.Username),
+		Event: lo.FromPtr(e.EventName),
+		Resources: strings.Join(lo.Uniq(lo.Map(e.Resources, func(resource cttypes.Resource, _ int) string {
+			return lo.FromPtr(resource.ResourceName)
+		})), ","),
+		Error:     e.ErrorCode,
+		SourceIP:  e.SourceIPAddress,
+		UserAgent: e.UserAgent,
+		RequestID: e.RequestID,
+	}
+}
+
+func newEvent(sdkEvent cttypes.Event) Event {
+	event := Event{Event: sdkEvent}
+	var record cloudTrailRecord
+	if err := json.Unmarshal([]byte(lo.FromPtr(sdkEvent.CloudTrailEvent)), &record); err != nil {
+		return event
+	}
+	event.SourceIPAddress = record.SourceIPAddress
+	event.UserAgent = record.UserAgent
+	event.ErrorCode = record.ErrorCode
+	event.RequestID = record.RequestID
+	event.RequestTags = map[string]string{}
+	collectTags(record.RequestParameters, event.RequestTags)
+	return event
+}
+
+// collectTags walks the request parameters of a CloudTrail record and collects every key/value tag pair.
+// EC2 records tags as {"key": "k", "value": "v"} items within tag specifications, while other APIs use "Key" and "Value".
+func collectTags(params any, tags map[string]string) {
+	switch p := params.(type) {
+	case map[string]any:
+		key, hasKey := p["key"].(string)
+		value, hasValue := p["value"].(string)
+		if !hasKey {
+			key, hasKey = p["Key"].(string)
+			value, hasValue = p["Value"].(string)
+		}
+		if hasKey && hasValue {
+			tags[key] = value
+		}
+		for _, v := range p {
+			collectTags(v, tags)
+		}
+	case []any:
+		for _, v := range p {
+			collectTags(v, tags)
+		}
+	}
+}
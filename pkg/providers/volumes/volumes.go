@@ -0,0 +1,291 @@
+package volumes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/retry"
+	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// defaultVolumeType is the volume type of created volumes that do not specify one
+	defaultVolumeType = ec2types.VolumeTypeGp3
+	// stateTimeout is how long to wait for a volume to become available or attached
+	stateTimeout = 5 * time.Minute
+)
+
+// Watcher discovers, creates, attaches, and deletes EBS volumes
+type Watcher struct {
+	ec2API SDKVolumesOps
+}
+
+// SDKVolumesOps is an interface that combines the necessary EC2 SDK client interfaces
+// AWS SDK for Go v2 does not provide a single interface that combines all the necessary methods
+type SDKVolumesOps interface {
+	ec2.DescribeVolumesAPIClient
+	CreateVolume(context.Context, *ec2.CreateVolumeInput, ...func(*ec2.Options)) (*ec2.CreateVolumeOutput, error)
+	AttachVolume(context.Context, *ec2.AttachVolumeInput, ...func(*ec2.Options)) (*ec2.AttachVolumeOutput, error)
+	DetachVolume(context.Context, *ec2.DetachVolumeInput, ...func(*ec2.Options)) (*ec2.DetachVolumeOutput, error)
+	DeleteVolume(context.Context, *ec2.DeleteVolumeInput, ...func(*ec2.Options)) (*ec2.DeleteVolumeOutput, error)
+	CreateSnapshot(context.Context, *ec2.CreateSnapshotInput, ...func(*ec2.Options)) (*ec2.CreateSnapshotOutput, error)
+}
+
+// Selector is a struct that represents an EBS volume selector
+type Selector struct {
+	Tags map[string]string
+	ID   string
+	AZ   string
+	// State is one of: creating | available | in-use | deleting | deleted | error
+	State string
+	// InstanceID selects the volumes attached to the instance
+	InstanceID string
+}
+
+// Volume represents an EBS volume
+// This is not the AWS SDK Volume type, but a wrapper around it so that we can add additional data
+type Volume struct {
+	ec2types.Volume
+}
+
+// CreateVolumeOptions are the options of a standalone EBS volume
+type CreateVolumeOptions struct {
+	AvailabilityZone string
+	// Size is the size of the volume in GiB
+	Size int32
+	// VolumeType defaults to gp3
+	VolumeType string
+	IOPS       int32
+	Throughput int32
+	Encrypted  bool
+	// KMSKeyID is the KMS key that encrypts the volume, the account's default EBS key is used if it is empty
+	KMSKeyID string
+	// Tags are additional tags applied to the volume
+	Tags   map[string]string
+	DryRun bool
+}
+
+// ParseSelectors parses a string of selectors into a slice of Selector structs
+func ParseSelectors(selectorStr string) ([]Selector, error) {
+	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse volume selectors: %w", err)
+	}
+	volumeSelectors := make([]Selector, 0, len(selectors))
+	for _, selector := range selectors {
+		volumeSelector := Selector{
+			Tags: selector.Tags,
+		}
+		for k, v := range selector.KeyVals {
+			switch k {
+			case "id":
+				volumeSelector.ID = v
+			case "az":
+				volumeSelector.AZ = v
+			case "state":
+				volumeSelector.State = v
+			case "instance-id":
+				volumeSelector.InstanceID = v
+			default:
+				return nil, fmt.Errorf("invalid volume selector key: %s", k)
+			}
+		}
+		volumeSelectors = append(volumeSelectors, volumeSelector)
+	}
+	return volumeSelectors, nil
+}
+
+// NewWatcher creates a new Volume Watcher
+func NewWatcher(ec2API SDKVolumesOps) Watcher {
+	return Watcher{
+		ec2API: ec2API,
+	}
+}
+
+// Resolve returns a list of volumes that match the provided selectors
+// Multiple calls to EC2 may be sent to resolve the selectors
+func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]Volume, error) {
+	var volumes []Volume
+	for _, filters := range filterSets(selectors) {
+		pager := ec2.NewDescribeVolumesPaginator(w.ec2API, &ec2.DescribeVolumesInput{
+			Filters: filters,
+		})
+		for pager.HasMorePages() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("failed to describe volumes: %w", err)
+			}
+			volumes = append(volumes, lo.Map(page.Volumes, func(sdkVolume ec2types.Volume, _ int) Volume {
+				return Volume{sdkVolume}
+			})...)
+		}
+	}
+	return lo.UniqBy(volumes, func(volume Volume) string { return lo.FromPtr(volume.VolumeId) }), nil
+}
+
+// Create creates a volume tagged with the namespace and name and waits for it to be available.
+// A DryRun create only checks permissions and returns the DryRunOperation error if they are sufficient.
+func (w Watcher) Create(ctx context.Context, namespace, name string, createOpts CreateVolumeOptions) (*Volume, error) {
+	volumeType := lo.CoalesceOrEmpty(ec2types.VolumeType(createOpts.VolumeType), defaultVolumeType)
+	out, err := w.ec2API.CreateVolume(ctx, &ec2.CreateVolumeInput{
+		AvailabilityZone: aws.String(createOpts.AvailabilityZone),
+		Size:             aws.Int32(createOpts.Size),
+		VolumeType:       volumeType,
+		Iops:             lo.EmptyableToPtr(createOpts.IOPS),
+		Throughput:       lo.EmptyableToPtr(createOpts.Throughput),
+		Encrypted:        aws.Bool(createOpts.Encrypted),
+		KmsKeyId:         lo.EmptyableToPtr(createOpts.KMSKeyID),
+		DryRun:           lo.EmptyableToPtr(createOpts.DryRun),
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeVolume,
+				Tags:         tagutils.MapToEC2Tags(lo.Assign(createOpts.Tags, tagutils.NamespacedTags(namespace, name))),
+			},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	volume := Volume{ec2types.Volume{
+		VolumeId:         out.VolumeId,
+		AvailabilityZone: out.AvailabilityZone,
+		Size:             out.Size,
+		VolumeType:       out.VolumeType,
+		Iops:             out.Iops,
+		Throughput:       out.Throughput,
+		Encrypted:        out.Encrypted,
+		KmsKeyId:         out.KmsKeyId,
+		State:            out.State,
+		Tags:             out.Tags,
+		CreateTime:       out.CreateTime,
+	}}
+	waiter := ec2.NewVolumeAvailableWaiter(w.ec2API)
+	if err := waiter.Wait(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{*out.VolumeId}}, stateTimeout); err != nil {
+		return &volume, fmt.Errorf("volume %s did not become available: %w", *out.VolumeId, err)
+	}
+	volume.State = ec2types.VolumeStateAvailable
+	return &volume, nil
+}
+
+// Attach attaches the volume to the instance as the device and waits for the volume to be in use
+func (w Watcher) Attach(ctx context.Context, volumeID, instanceID, deviceName string) error {
+	if _, err := w.ec2API.AttachVolume(ctx, &ec2.AttachVolumeInput{
+		VolumeId:   aws.String(volumeID),
+		InstanceId: aws.String(instanceID),
+		Device:     aws.String(deviceName),
+	}); err != nil {
+		return fmt.Errorf("failed to attach volume %s to instance %s: %w", volumeID, instanceID, err)
+	}
+	waiter := ec2.NewVolumeInUseWaiter(w.ec2API)
+	if err := waiter.Wait(ctx, &ec2.DescribeVolumesInput{VolumeIds: []string{volumeID}}, stateTimeout); err != nil {
+		return fmt.Errorf("volume %s was not attached to instance %s: %w", volumeID, instanceID, err)
+	}
+	return nil
+}
+
+// Detach detaches the volume from the instances it is attached to and waits for it to be available.
+// Force detaching skips flushing the instance's file system caches and may lose data.
+func (w Watcher) Detach(ctx context.Context, volumeID string, force bool) error {
+	if _, err := w.ec2API.DetachVolume(ctx, &ec2.DetachVolumeInput{
+		VolumeId: aws.String(volumeID),
+		Force:    lo.EmptyableToPtr(force),
+	}); err != nil {
+		return fmt.Errorf("failed to detach volume %s: %w", volumeID, err)
+	}
+	return w.WaitForAvailable(ctx, []string{volumeID}, stateTimeout)
+}
+
+// WaitForAvailable waits until the volumes are available, e.g. after the instances they were attached to terminated
+func (w Watcher) WaitForAvailable(ctx context.Context, volumeIDs []string, timeout time.Duration) error {
+	if len(volumeIDs) == 0 {
+		return nil
+	}
+	waiter := ec2.NewVolumeAvailableWaiter(w.ec2API)
+	if err := waiter.Wait(ctx, &ec2.DescribeVolumesInput{VolumeIds: volumeIDs}, timeout); err != nil {
+		return fmt.Errorf("volumes %v did not become available: %w", volumeIDs, err)
+	}
+	return nil
+}
+
+// Delete deletes the volume, it must not be attached to an instance
+func (w Watcher) Delete(ctx context.Context, volumeID string) error {
+	if err := retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.ec2API.DeleteVolume(ctx, &ec2.DeleteVolumeInput{
+			VolumeId: aws.String(volumeID),
+		})
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to delete volume %s: %w", volumeID, err)
+	}
+	return nil
+}
+
+// Snapshot starts a snapshot of the volume tagged with the tags and returns its ID.
+// The snapshot captures the volume's data at the time of the call, so the volume may be deleted while the snapshot is still pending.
+func (w Watcher) Snapshot(ctx context.Context, volumeID, description string, tags map[string]string) (string, error) {
+	out, err := w.ec2API.CreateSnapshot(ctx, &ec2.CreateSnapshotInput{
+		VolumeId:    aws.String(volumeID),
+		Description: lo.EmptyableToPtr(description),
+		TagSpecifications: []ec2types.TagSpecification{
+			{
+				ResourceType: ec2types.ResourceTypeSnapshot,
+				Tags:         tagutils.MapToEC2Tags(tags),
+			},
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to snapshot volume %s: %w", volumeID, err)
+	}
+	return lo.FromPtr(out.SnapshotId), nil
+}
+
+// AttachedInstanceIDs returns the IDs of the instances the volume is attached to
+func (v Volume) AttachedInstanceIDs() []string {
+	return lo.FilterMap(v.Attachments, func(attachment ec2types.VolumeAttachment, _ int) (string, bool) {
+		return lo.FromPtr(attachment.InstanceId), attachment.InstanceId != nil && attachment.State != ec2types.VolumeAttachmentStateDetached
+	})
+}
+
+// filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
+// Each filter is executed as a separate list call.
+// Terms within a Selector are AND'd and between Selectors are OR'd
+func filterSets(selectorList []Selector) [][]ec2types.Filter {
+	var filterResult [][]ec2types.Filter
+	for _, term := range selectorList {
+		filters := []ec2types.Filter{}
+		if term.ID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("volume-id"),
+				Values: []string{term.ID},
+			})
+		}
+		if term.AZ != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("availability-zone"),
+				Values: []string{term.AZ},
+			})
+		}
+		if term.State != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("status"),
+				Values: []string{term.State},
+			})
+		}
+		if term.InstanceID != "" {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("attachment.instance-id"),
+				Values: []string{term.InstanceID},
+			})
+		}
+		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
+		filterResult = append(filterResult, filters)
+	}
+	return filterResult
+}
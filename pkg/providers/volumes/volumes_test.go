@@ -0,0 +1,62 @@
+package volumes_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
+)
+
+func TestParseSelectors(t *testing.T) {
+	selectors, err := volumes.ParseSelectors("az:us-west-2a,state:available,tag:team=infra;id:vol-123")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(selectors) != 2 || selectors[0].AZ != "us-west-2a" || selectors[0].State != "available" || selectors[0].Tags["team"] != "infra" || selectors[1].ID != "vol-123" {
+		t.Errorf("unexpected selectors %+v", selectors)
+	}
+	if _, err := volumes.ParseSelectors("size:100"); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+}
+
+func TestAttachedInstanceIDs(t *testing.T) {
+	volume := volumes.Volume{Volume: ec2types.Volume{Attachments: []ec2types.VolumeAttachment{
+		{InstanceId: aws.String("i-123"), State: ec2types.VolumeAttachmentStateAttached},
+		{InstanceId: aws.String("i-456"), State: ec2types.VolumeAttachmentStateDetached},
+	}}}
+	if ids := volume.AttachedInstanceIDs(); len(ids) != 1 || ids[0] != "i-123" {
+		t.Errorf("expected [i-123], got %v", ids)
+	}
+}
+
+type fakeEC2 struct {
+	volumes.SDKVolumesOps
+	snapshots []*ec2.CreateSnapshotInput
+}
+
+func (f *fakeEC2) CreateSnapshot(_ context.Context, input *ec2.CreateSnapshotInput, _ ...func(*ec2.Options)) (*ec2.CreateSnapshotOutput, error) {
+	f.snapshots = append(f.snapshots, input)
+	return &ec2.CreateSnapshotOutput{SnapshotId: aws.String("snap-123"), VolumeId: input.VolumeId}, nil
+}
+
+func TestSnapshot(t *testing.T) {
+	fake := &fakeEC2{}
+	snapshotID, err := volumes.NewWatcher(fake).Snapshot(context.Background(), "vol-123", "final snapshot", map[string]string{"team": "infra"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snapshotID != "snap-123" {
+		t.Errorf("expected snap-123, got %s", snapshotID)
+	}
+	if len(fake.snapshots) != 1 || aws.ToString(fake.snapshots[0].VolumeId) != "vol-123" {
+		t.Fatalf("expected a snapshot of vol-123, got %+v", fake.snapshots)
+	}
+	tags := fake.snapshots[0].TagSpecifications
+	if len(tags) != 1 || tags[0].ResourceType != ec2types.ResourceTypeSnapshot || len(tags[0].Tags) != 1 {
+		t.Errorf("expected the snapshot to be tagged, got %+v", tags)
+	}
+}
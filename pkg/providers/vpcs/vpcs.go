@@ -2,13 +2,17 @@ package vpcs
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/retry"
 	"github.com/bwagner5/nimbus/pkg/selectors"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/bwagner5/vpcctl/pkg/vpc"
 	"github.com/samber/lo"
@@ -33,6 +37,8 @@ type SDKVPCsOps interface {
 type Selector struct {
 	Tags map[string]string
 	ID   string
+	// Default selects the account's default VPC in the region
+	Default bool
 }
 
 // VPC represent an AWS VPC
@@ -41,6 +47,17 @@ type VPC struct {
 	ec2types.Vpc
 }
 
+// errIPv6CIDRPending is returned while the Amazon-provided IPv6 CIDR block of a VPC is not assigned yet
+var errIPv6CIDRPending = errors.New("the IPv6 CIDR block of the VPC is not assigned yet")
+
+// IPv6CIDR returns the IPv6 CIDR block that is associated with the VPC, or an empty string if it has none
+func (v VPC) IPv6CIDR() string {
+	association, _ := lo.Find(v.Ipv6CidrBlockAssociationSet, func(association ec2types.VpcIpv6CidrBlockAssociation) bool {
+		return association.Ipv6CidrBlockState != nil && association.Ipv6CidrBlockState.State == ec2types.VpcCidrBlockStateCodeAssociated
+	})
+	return lo.FromPtr(association.Ipv6CidrBlock)
+}
+
 // ParseSelectors parses a string of selectors into a slice of Selector structs
 func ParseSelectors(selectorStr string) ([]Selector, error) {
 	selectors, err := selectors.ParseSelectorsTokens(selectorStr)
@@ -56,6 +73,12 @@ func ParseSelectors(selectorStr string) ([]Selector, error) {
 			switch k {
 			case "id":
 				vpcSelector.ID = v
+			case "default":
+				isDefault, err := strconv.ParseBool(v)
+				if err != nil {
+					return nil, fmt.Errorf("invalid vpc default selector value %q: %w", v, err)
+				}
+				vpcSelector.Default = isDefault
 			default:
 				return nil, fmt.Errorf("invalid vpc selector key: %s", k)
 			}
@@ -96,29 +119,61 @@ func (w Watcher) Resolve(ctx context.Context, selectors []Selector) ([]VPC, erro
 	return vpcs, nil
 }
 
-func (w Watcher) Create(ctx context.Context, namespace string, name string, cidr string) (*VPC, error) {
+// Create creates a VPC tagged with the namespaced tags and the additional tags.
+// If ipv6 is true, the VPC is also assigned an Amazon-provided IPv6 CIDR block and Create waits until it is associated.
+func (w Watcher) Create(ctx context.Context, namespace string, name string, cidr string, ipv6 bool, tags map[string]string) (*VPC, error) {
 	vpcOut, err := w.vpcAPI.CreateVpc(ctx, &ec2.CreateVpcInput{
-		CidrBlock: aws.String(cidr),
+		CidrBlock:                   aws.String(cidr),
+		AmazonProvidedIpv6CidrBlock: lo.Ternary(ipv6, aws.Bool(true), nil),
 		TagSpecifications: []types.TagSpecification{
 			{
 				ResourceType: types.ResourceTypeVpc,
-				Tags:         tagutils.EC2NamespacedTags(namespace, name),
+				Tags:         tagutils.MapToEC2Tags(lo.Assign(tags, tagutils.NamespacedTags(namespace, name))),
 			},
 		},
 	})
 	if err != nil {
 		return nil, err
 	}
-	return &VPC{Vpc: *vpcOut.Vpc}, nil
+	vpc := &VPC{Vpc: *vpcOut.Vpc}
+	if !ipv6 || vpc.IPv6CIDR() != "" {
+		return vpc, nil
+	}
+	if err := retry.Do(ctx, func(err error) bool { return errors.Is(err, errIPv6CIDRPending) }, func() error {
+		vpcs, err := w.Resolve(ctx, []Selector{{ID: *vpc.VpcId}})
+		if err != nil {
+			return err
+		}
+		if len(vpcs) == 0 || vpcs[0].IPv6CIDR() == "" {
+			return errIPv6CIDRPending
+		}
+		vpc = &vpcs[0]
+		return nil
+	}); err != nil {
+		return vpc, fmt.Errorf("failed to assign an IPv6 CIDR block to VPC %s: %w", *vpc.VpcId, err)
+	}
+	return vpc, nil
 }
 
-func (w Watcher) Delete(ctx context.Context, vpcID string) error {
-	_, err := w.vpcAPI.DeleteVpc(ctx, &ec2.DeleteVpcInput{
-		VpcId: &vpcID,
+// DryRunCreate checks whether the caller is permitted to create a VPC without creating it.
+// EC2 returns a DryRunOperation error if the VPC would have been created.
+func (w Watcher) DryRunCreate(ctx context.Context, cidr string) error {
+	_, err := w.vpcAPI.CreateVpc(ctx, &ec2.CreateVpcInput{
+		CidrBlock: aws.String(cidr),
+		DryRun:    aws.Bool(true),
 	})
 	return err
 }
 
+func (w Watcher) Delete(ctx context.Context, vpcID string) error {
+	return retry.Do(ctx, ec2utils.IsDependencyViolationErr, func() error {
+		_, err := w.vpcAPI.DeleteVpc(ctx, &ec2.DeleteVpcInput{
+			VpcId: &vpcID,
+		})
+		return err
+	})
+}
+
 // filterSets converts a slice of selectors into a slice of filters for use with the AWS SDK
 // Each filter is executed as a separate list call.
 // Terms within a Selector are AND'd and between Selectors are OR'd
@@ -126,6 +181,12 @@ func filterSets(selectorList []Selector) [][]ec2types.Filter {
 	var filterResult [][]ec2types.Filter
 	for _, term := range selectorList {
 		filters := []ec2types.Filter{}
+		if term.Default {
+			filters = append(filters, ec2types.Filter{
+				Name:   aws.String("is-default"),
+				Values: []string{"true"},
+			})
+		}
 		filters = append(filters, selectors.TagsToEC2Filters(term.Tags)...)
 		filterResult = append(filterResult, filters)
 	}
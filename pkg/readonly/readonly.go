@@ -0,0 +1,82 @@
+// Package readonly guards AWS clients so that only read-only API operations are allowed.
+//
+// Read-only mode lets auditors and CI preview jobs run any nimbus command knowing that nothing will change.
+package readonly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/aws/smithy-go/middleware"
+)
+
+const (
+	// EnvVar enables read-only mode when set to a true value
+	EnvVar = "NIMBUS_NO_MUTATE"
+
+	// middlewareID is the ID of the middleware that Apply adds to the serialize step
+	middlewareID = "NimbusReadOnly"
+)
+
+var (
+	// ErrReadOnly is returned by any mutating API call made in read-only mode
+	ErrReadOnly = errors.New("nimbus is in read-only mode")
+
+	// readOnlyPrefixes are the operation name prefixes of AWS APIs that do not change anything
+	readOnlyPrefixes = []string{"Describe", "Get", "List", "Lookup", "Search"}
+
+	// readOnlyOperations are the operations that do not change any resources but are not named with a read-only prefix
+	readOnlyOperations = map[string]bool{
+		// receiving only hides the messages until their visibility timeout expires, deleting them is still blocked
+		"ReceiveMessage": true,
+		// filtering only reads the events of a log group's streams
+		"FilterLogEvents": true,
+	}
+)
+
+// Enabled returns true if read-only mode is enabled by the NIMBUS_NO_MUTATE env var
+func Enabled() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(EnvVar))
+	return enabled
+}
+
+// IsReadOnlyOperation returns true if the AWS API operation does not change anything
+func IsReadOnlyOperation(operation string) bool {
+	if readOnlyOperations[operation] {
+		return true
+	}
+	for _, prefix := range readOnlyPrefixes {
+		if strings.HasPrefix(operation, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply makes every client created from the AWS config fail mutating API calls with ErrReadOnly before they are sent.
+// Presigning fails too, even for read-only operations, since a pre-signed URL lets whoever holds it make the call with the caller's credentials.
+func Apply(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		// presign clients swap the signing middleware for a presigning one before the API options are applied
+		_, presigning := stack.Finalize.Get((*v4.PresignHTTPRequestMiddleware)(nil).ID())
+		return stack.Serialize.Add(middleware.SerializeMiddlewareFunc(middlewareID, func(ctx context.Context, in middleware.SerializeInput, next middleware.SerializeHandler) (
+			middleware.SerializeOutput, middleware.Metadata, error,
+		) {
+			operation := awsmiddleware.GetOperationName(ctx)
+			if presigning {
+				return middleware.SerializeOutput{}, middleware.Metadata{}, fmt.Errorf("%w: presigning %s %s is not allowed", ErrReadOnly, awsmiddleware.GetServiceID(ctx), operation)
+			}
+			if !IsReadOnlyOperation(operation) {
+				return middleware.SerializeOutput{}, middleware.Metadata{}, fmt.Errorf("%w: %s %s is not allowed", ErrReadOnly, awsmiddleware.GetServiceID(ctx), operation)
+			}
+			return next.HandleSerialize(ctx, in)
+		}), middleware.Before)
+	})
+}
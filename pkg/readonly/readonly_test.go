@@ -0,0 +1,47 @@
+package readonly_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"github.com/bwagner5/nimbus/pkg/readonly"
+)
+
+func TestIsReadOnlyOperation(t *testing.T) {
+	for operation, expected := range map[string]bool{
+		"DescribeInstances": true,
+		"GetRole":           true,
+		"ListObjectsV2":     true,
+		"ReceiveMessage":    true,
+		"FilterLogEvents":   true,
+		"RunInstances":      false,
+		"DeleteMessage":     false,
+		"PutMetricData":     false,
+	} {
+		if got := readonly.IsReadOnlyOperation(operation); got != expected {
+			t.Errorf("IsReadOnlyOperation(%s) = %t, want %t", operation, got, expected)
+		}
+	}
+}
+
+func TestApplyPresign(t *testing.T) {
+	cfg := aws.Config{Region: "us-west-2", Credentials: credentials.NewStaticCredentialsProvider("AKID", "SECRET", "")}
+	presignAPI := s3.NewPresignClient(s3.NewFromConfig(cfg))
+	if _, err := presignAPI.PresignGetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	readonly.Apply(&cfg)
+	presignAPI = s3.NewPresignClient(s3.NewFromConfig(cfg))
+	if _, err := presignAPI.PresignGetObject(context.Background(), &s3.GetObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")}); !errors.Is(err, readonly.ErrReadOnly) {
+		t.Errorf("expected presigning a download to fail in read-only mode, got %v", err)
+	}
+	if _, err := presignAPI.PresignPutObject(context.Background(), &s3.PutObjectInput{Bucket: aws.String("bucket"), Key: aws.String("key")}); !errors.Is(err, readonly.ErrReadOnly) {
+		t.Errorf("expected presigning an upload to fail in read-only mode, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+)
+
+// DefaultBackoff retries for up to about 90 seconds, which covers how long EC2 usually takes to release the dependencies of terminated instances
+var DefaultBackoff = Backoff{
+	Attempts: 8,
+	Delay:    time.Second,
+	MaxDelay: 30 * time.Second,
+}
+
+type backoffCtxKey struct{}
+
+// Backoff configures retries with exponential backoff
+type Backoff struct {
+	// Attempts is the max number of attempts, including the first one. Less than 2 disables retries
+	Attempts int
+	// Delay is the delay before the first retry, it doubles for every further retry
+	Delay time.Duration
+	// MaxDelay caps the delay between retries
+	MaxDelay time.Duration
+}
+
+// ToContext returns a copy of ctx that operations retry with the backoff
+func ToContext(ctx context.Context, backoff Backoff) context.Context {
+	return context.WithValue(ctx, backoffCtxKey{}, backoff)
+}
+
+// FromContext returns the backoff of ctx, or DefaultBackoff if it has none
+func FromContext(ctx context.Context) Backoff {
+	backoff, ok := ctx.Value(backoffCtxKey{}).(Backoff)
+	if !ok {
+		return DefaultBackoff
+	}
+	return backoff
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable, or the attempts of ctx's backoff are exhausted.
+// The last error is returned, or ctx's error if ctx is done while waiting to retry.
+func Do(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	backoff := FromContext(ctx)
+	delay := backoff.Delay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= backoff.Attempts || !retryable(err) {
+			return err
+		}
+		timer := time.NewTimer(jitter(delay))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		delay = min(delay*2, max(backoff.MaxDelay, backoff.Delay))
+	}
+}
+
+// jitter returns a random delay between half and all of the delay so that concurrent retries of dependent resources spread out
+func jitter(delay time.Duration) time.Duration {
+	if delay <= 1 {
+		return delay
+	}
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,68 @@
+package retry_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/retry"
+)
+
+var (
+	errTransient = errors.New("transient")
+	errPermanent = errors.New("permanent")
+)
+
+func TestDo(t *testing.T) {
+	ctx := retry.ToContext(context.Background(), retry.Backoff{Attempts: 4, Delay: time.Millisecond, MaxDelay: 2 * time.Millisecond})
+	isTransient := func(err error) bool { return errors.Is(err, errTransient) }
+	testCases := []struct {
+		name             string
+		errs             []error
+		expectedErr      error
+		expectedAttempts int
+	}{
+		{name: "succeeds", errs: []error{nil}, expectedAttempts: 1},
+		{name: "retries transient errors", errs: []error{errTransient, errTransient, nil}, expectedAttempts: 3},
+		{name: "does not retry other errors", errs: []error{errTransient, errPermanent, nil}, expectedErr: errPermanent, expectedAttempts: 2},
+		{name: "gives up after the attempts", errs: []error{errTransient, errTransient, errTransient, errTransient, nil}, expectedErr: errTransient, expectedAttempts: 4},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			attempts := 0
+			err := retry.Do(ctx, isTransient, func() error {
+				attempts++
+				return tc.errs[attempts-1]
+			})
+			if !errors.Is(err, tc.expectedErr) || (tc.expectedErr == nil && err != nil) {
+				t.Errorf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if attempts != tc.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d", tc.expectedAttempts, attempts)
+			}
+		})
+	}
+}
+
+func TestDoCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(retry.ToContext(context.Background(), retry.Backoff{Attempts: 3, Delay: time.Hour}))
+	attempts := 0
+	err := retry.Do(ctx, func(error) bool { return true }, func() error {
+		attempts++
+		cancel()
+		return errTransient
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+	if attempts != 1 {
+		t.Errorf("expected 1 attempt, got %d", attempts)
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if backoff := retry.FromContext(context.Background()); backoff != retry.DefaultBackoff {
+		t.Errorf("expected the default backoff, got %+v", backoff)
+	}
+}
@@ -2,10 +2,13 @@ package selectors
 
 import (
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/samber/lo"
 )
 
 // GenericSelector is a struct that represents a set of selectors
@@ -52,41 +55,75 @@ type GenericSelector struct {
 //  2. id:resource-0123456 (OR'd together, so the resource must have the given ID)
 //
 // The resources selected will be the given resource ID and resources that have both tags "Name=fancyOS" and "Environment=dev"
+//
+// The values of list keywords are comma separated lists, a criterion without a keyword continues the list before it.
+// A criterion without a keyword after any other keyword is invalid:
+//
+// "exclude:t2*,t3*" parses into KeyVals{"exclude": "t2*,t3*"}, "id:ami-0123456,ami-0654321" is invalid
+//
+// Double quotes make the ; , : and = within them literal, and a backslash escapes the character after it, so tag keys and values
+// can contain them. A tag criterion that is quoted as a whole is split at its first =:
+//
+// `tag:Team="infra,eu"`, `tag:"Team=infra,eu"`, and `tag:Team=infra\,eu` all parse into Tags{"Team": "infra,eu"}
+//
+// Keywords are case-insensitive and spaces around keywords, tag keys, and values are trimmed. Underscores in keywords are read as dashes
+// and the dashes of well known keywords are optional:
+//
+// " ID : ami-0123456 ", "Vpc_Id:vpc-0123456", and "vpcid:vpc-0123456" parse into KeyVals{"id": "ami-0123456"} and KeyVals{"vpc-id": "vpc-0123456"}
 func ParseSelectorsTokens(selectors string) ([]GenericSelector, error) {
 	selectors = strings.TrimSpace(selectors)
-	selectorTerms := strings.Split(selectors, ";")
+	selectorTerms, err := split(selectors, ';')
+	if err != nil {
+		return nil, fmt.Errorf("invalid selector %s: %w", selectors, err)
+	}
 	genericSelectors := make([]GenericSelector, 0, len(selectorTerms))
 	for _, term := range selectorTerms {
 		if strings.TrimSpace(term) == "" {
 			continue
 		}
 		genericSelector := GenericSelector{}
-		components := strings.Split(term, ",")
+		components, _ := split(term, ',')
+		previousKeyword := ""
 		for _, c := range components {
-			keyword, value, found := strings.Cut(c, ":")
+			keyword, value, found := cut(c, ':')
 			if !found {
-				return nil, fmt.Errorf("invalid selector: %s", c)
+				if previousKeyword == "" {
+					return nil, fmt.Errorf("invalid selector: %s", c)
+				}
+				genericSelector.KeyVals[previousKeyword] += "," + unquote(strings.TrimSpace(c))
+				continue
 			}
+			keyword, value = canonicalKeyword(keyword), strings.TrimSpace(value)
+			previousKeyword = lo.Ternary(listKeywords[keyword], keyword, "")
 			if keyword == "tag" {
 				if genericSelector.Tags == nil {
 					genericSelector.Tags = make(map[string]string)
 				}
-				tagTokens := strings.Split(value, "=")
+				tagTokens, _ := split(value, '=')
+				for i := range tagTokens {
+					tagTokens[i] = strings.TrimSpace(tagTokens[i])
+				}
+				if len(tagTokens) == 1 && quoted(value) {
+					// the whole criterion is quoted, e.g. tag:"Team=infra,eu"
+					key, tagValue, _ := strings.Cut(unquote(value), "=")
+					genericSelector.Tags[strings.TrimSpace(key)] = strings.TrimSpace(tagValue)
+					continue
+				}
 				if len(tagTokens) > 2 {
-					return nil, fmt.Errorf("invalid tag selector: %s. Expected 0 or 1 \"=\", but found %d", value, len(tagTokens)-1)
+					return nil, fmt.Errorf("invalid tag selector: %s. Expected 0 or 1 \"=\", but found %d, quote values that contain \"=\"", value, len(tagTokens)-1)
 				}
 				// if only the tag key was given, then we set the value to the empty string and use it as a wildcard
 				if len(tagTokens) == 1 {
-					genericSelector.Tags[tagTokens[0]] = ""
+					genericSelector.Tags[unquote(tagTokens[0])] = ""
 				}
 				if len(tagTokens) == 2 {
-					genericSelector.Tags[tagTokens[0]] = tagTokens[1]
+					genericSelector.Tags[unquote(tagTokens[0])] = unquote(tagTokens[1])
 				}
 			} else {
 				if genericSelector.KeyVals == nil {
 					genericSelector.KeyVals = make(map[string]string)
 				}
-				genericSelector.KeyVals[strings.ToLower(keyword)] = value
+				genericSelector.KeyVals[keyword] = unquote(value)
 			}
 		}
 		genericSelectors = append(genericSelectors, genericSelector)
@@ -94,6 +131,149 @@ func ParseSelectorsTokens(selectors string) ([]GenericSelector, error) {
 	return genericSelectors, nil
 }
 
+// listKeywords are the selector keywords whose values are comma separated lists
+var listKeywords = map[string]bool{"types": true, "exclude": true}
+
+// dashedKeywords are the selector keywords with dashes, keyed by the keyword without them so that the dashes are optional
+var dashedKeywords = func() map[string]string {
+	keywords := map[string]string{}
+	for _, keyword := range []string{
+		"cpu-manufacturer", "creation-date", "default-for-az", "default-only", "device-index", "device-name", "gpu-manufacturer",
+		"gpu-model", "instance-id", "kms-key", "local-storage", "network-card", "public-ip", "security-group-id", "security-groups",
+		"sg-id", "sg-ids", "subnet-id", "vpc-id",
+	} {
+		keywords[strings.ReplaceAll(keyword, "-", "")] = keyword
+	}
+	return keywords
+}()
+
+// canonicalKeyword trims and lowercases a selector keyword, reads underscores as dashes, and adds the dashes of well known keywords
+func canonicalKeyword(keyword string) string {
+	keyword = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(keyword)), "_", "-")
+	if dashed, ok := dashedKeywords[strings.ReplaceAll(keyword, "-", "")]; ok {
+		return dashed
+	}
+	return keyword
+}
+
+// Canonicalize parses selectors and formats them back into their canonical form for display, see GenericSelector.String
+func Canonicalize(selectors string) (string, error) {
+	genericSelectors, err := ParseSelectorsTokens(selectors)
+	if err != nil {
+		return "", err
+	}
+	return Format(genericSelectors), nil
+}
+
+// Format formats selectors in their canonical form, terms separated by semicolons
+func Format(genericSelectors []GenericSelector) string {
+	terms := make([]string, 0, len(genericSelectors))
+	for _, genericSelector := range genericSelectors {
+		terms = append(terms, genericSelector.String())
+	}
+	return strings.Join(terms, ";")
+}
+
+// String formats the selector in its canonical form, which parses back into the same selector.
+// Keywords are sorted and lowercased and come before the tags, which are sorted by key, e.g. "id:ami-0123456,tag:Team=infra"
+func (s GenericSelector) String() string {
+	var criteria []string
+	for _, keyword := range slices.Sorted(maps.Keys(s.KeyVals)) {
+		value := s.KeyVals[keyword]
+		// comma separated lists stay readable, unless a list item would be read as a keyword
+		special := lo.Ternary(listKeywords[keyword] && !strings.Contains(value, ":"), `;"\`, `;,"\`)
+		criteria = append(criteria, keyword+":"+quote(value, special))
+	}
+	for _, key := range slices.Sorted(maps.Keys(s.Tags)) {
+		criterion := "tag:" + quote(key, `;,:="\`)
+		if value := s.Tags[key]; value != "" {
+			criterion += "=" + quote(value, `;,:="\`)
+		}
+		criteria = append(criteria, criterion)
+	}
+	return strings.Join(criteria, ",")
+}
+
+// quote double quotes s if it contains any of the special characters or spaces that would be trimmed, escaping its quotes and backslashes
+func quote(s, special string) string {
+	if !strings.ContainsAny(s, special) && strings.TrimSpace(s) == s {
+		return s
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// split splits s at every sep that is neither within double quotes nor escaped with a backslash.
+// The parts keep their quotes and escapes, and an error is returned if a quote is not closed.
+func split(s string, sep rune) ([]string, error) {
+	var parts []string
+	inQuotes, escaped := false, false
+	start := 0
+	for i, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == sep && !inQuotes:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	return append(parts, s[start:]), nil
+}
+
+// cut slices s around the first sep that is neither within double quotes nor escaped
+func cut(s string, sep rune) (string, string, bool) {
+	parts, err := split(s, sep)
+	if err != nil || len(parts) == 1 {
+		return s, "", false
+	}
+	return parts[0], s[len(parts[0])+1:], true
+}
+
+// quoted is true if s is a single double quoted string
+func quoted(s string) bool {
+	if len(s) < 2 || s[0] != '"' {
+		return false
+	}
+	escaped := false
+	for i, r := range s[1:] {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+			return i+2 == len(s)
+		}
+	}
+	return false
+}
+
+// unquote removes the double quotes and backslash escapes of s
+func unquote(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			b.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '"':
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
 func TagsToEC2Filters(tags map[string]string) []ec2types.Filter {
 	var filters []ec2types.Filter
 	for k, v := range tags {
@@ -111,3 +291,43 @@ func TagsToEC2Filters(tags map[string]string) []ec2types.Filter {
 	}
 	return filters
 }
+
+// ParseEC2Filters parses raw EC2 API filters in the AWS CLI shorthand syntax so that any EC2 filter can be passed through
+//
+// Example:
+//
+// "Name=instance-type,Values=m5.large,m5.xlarge"
+//
+// Returns:
+//
+//	ec2types.Filter{
+//		Name:   aws.String("instance-type"),
+//		Values: []string{"m5.large", "m5.xlarge"},
+//	}
+func ParseEC2Filters(filterStrs []string) ([]ec2types.Filter, error) {
+	filters := make([]ec2types.Filter, 0, len(filterStrs))
+	for _, filterStr := range filterStrs {
+		var filter ec2types.Filter
+		inValues := false
+		for _, token := range strings.Split(filterStr, ",") {
+			token = strings.TrimSpace(token)
+			switch {
+			case strings.HasPrefix(token, "Name="):
+				filter.Name = aws.String(strings.TrimPrefix(token, "Name="))
+				inValues = false
+			case strings.HasPrefix(token, "Values="):
+				filter.Values = append(filter.Values, strings.TrimPrefix(token, "Values="))
+				inValues = true
+			case inValues:
+				filter.Values = append(filter.Values, token)
+			default:
+				return nil, fmt.Errorf("invalid filter %q, expected Name=<filter-name>,Values=<value>[,<value>...]", filterStr)
+			}
+		}
+		if filter.Name == nil || *filter.Name == "" || len(filter.Values) == 0 {
+			return nil, fmt.Errorf("invalid filter %q, expected Name=<filter-name>,Values=<value>[,<value>...]", filterStr)
+		}
+		filters = append(filters, filter)
+	}
+	return filters, nil
+}
@@ -1,8 +1,12 @@
 package selectors_test
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/bwagner5/nimbus/pkg/selectors"
 )
 
@@ -68,6 +72,114 @@ func TestParseSelectors(t *testing.T) {
 				},
 			},
 		},
+		{
+			selectorStr: "exclude:t2*,t3*,arch:arm64",
+			expected: []selectors.GenericSelector{
+				{
+					KeyVals: map[string]string{
+						"exclude": "t2*,t3*",
+						"arch":    "arm64",
+					},
+				},
+			},
+		},
+		{
+			selectorStr: "tag:Name=foo,bar",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "Types:m5.*, c5.large;exclude:m5.metal",
+			expected: []selectors.GenericSelector{
+				{
+					KeyVals: map[string]string{
+						"types": "m5.*,c5.large",
+					},
+				},
+				{
+					KeyVals: map[string]string{
+						"exclude": "m5.metal",
+					},
+				},
+			},
+		},
+		{
+			selectorStr: "id:ami-0123456,ami-0654321",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "exclude:t2*,arch:arm64,typo",
+			expectedErr: true,
+		},
+		{
+			selectorStr: "tag:Name=foo,typo",
+			expectedErr: true,
+		},
+		{
+			selectorStr: `tag:Team="infra,eu",tag:"Cost=Center"="a=b;c";id:"r-1"`,
+			expected: []selectors.GenericSelector{
+				{
+					Tags: map[string]string{
+						"Team":        "infra,eu",
+						"Cost=Center": "a=b;c",
+					},
+				},
+				{
+					KeyVals: map[string]string{
+						"id": "r-1",
+					},
+				},
+			},
+		},
+		{
+			selectorStr: `tag:"Team=infra,eu",name:"My AMI: v2"`,
+			expected: []selectors.GenericSelector{
+				{
+					Tags: map[string]string{
+						"Team": "infra,eu",
+					},
+					KeyVals: map[string]string{
+						"name": "My AMI: v2",
+					},
+				},
+			},
+		},
+		{
+			selectorStr: `tag:Team=infra\,eu,tag:Quote=say \"hi\"`,
+			expected: []selectors.GenericSelector{
+				{
+					Tags: map[string]string{
+						"Team":  "infra,eu",
+						"Quote": `say "hi"`,
+					},
+				},
+			},
+		},
+		{
+			selectorStr: `tag:Team="infra,eu`,
+			expectedErr: true,
+		},
+		{
+			selectorStr: ` Tag: Team = infra , ID : ami-123 ,Vpc_Id:vpc-1, vpcid :vpc-2; DEFAULT-FOR-AZ:true,tag:Name=" padded "`,
+			expected: []selectors.GenericSelector{
+				{
+					Tags: map[string]string{
+						"Team": "infra",
+					},
+					KeyVals: map[string]string{
+						"id":     "ami-123",
+						"vpc-id": "vpc-2",
+					},
+				},
+				{
+					Tags: map[string]string{
+						"Name": " padded ",
+					},
+					KeyVals: map[string]string{
+						"default-for-az": "true",
+					},
+				},
+			},
+		},
 		{
 			selectorStr: "tag:Name,tag:Owner=bar",
 			expected: []selectors.GenericSelector{
@@ -118,3 +230,74 @@ func TestParseSelectors(t *testing.T) {
 		})
 	}
 }
+
+func TestCanonicalize(t *testing.T) {
+	for selectorStr, expected := range map[string]string{
+		" Tag:Owner = bar,tag:Name , ID:r-123 ;VPCID:vpc-1": "id:r-123,tag:Name,tag:Owner=bar;vpc-id:vpc-1",
+		"exclude: t2*, t3*":                                 "exclude:t2*,t3*",
+		`tag:"Team=infra,eu",tag:Note=" a;b "`:              `tag:Note=" a;b ",tag:Team="infra,eu"`,
+		`tag:Path=a\\b,creation-date:>2024-01-01T00:00:00Z`: `creation-date:>2024-01-01T00:00:00Z,tag:Path="a\\b"`,
+		`name:"a,b:c"`:                                      `name:"a,b:c"`,
+		`id:"ami-1,ami-2"`:                                  `id:"ami-1,ami-2"`,
+	} {
+		t.Run(selectorStr, func(t *testing.T) {
+			canonical, err := selectors.Canonicalize(selectorStr)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if canonical != expected {
+				t.Errorf("expected %s, got %s", expected, canonical)
+			}
+			// the canonical form is stable
+			if again, err := selectors.Canonicalize(canonical); err != nil || again != canonical {
+				t.Errorf("expected %s to canonicalize to itself, got %s (%v)", canonical, again, err)
+			}
+		})
+	}
+}
+
+func TestParseEC2Filters(t *testing.T) {
+	type testCases struct {
+		filterStrs  []string
+		expected    []ec2types.Filter
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			filterStrs: []string{"Name=instance-type,Values=m5.large"},
+			expected:   []ec2types.Filter{{Name: aws.String("instance-type"), Values: []string{"m5.large"}}},
+		},
+		{
+			filterStrs: []string{"Name=instance-type,Values=m5.large,m5.xlarge", "Values=running,Name=instance-state-name"},
+			expected: []ec2types.Filter{
+				{Name: aws.String("instance-type"), Values: []string{"m5.large", "m5.xlarge"}},
+				{Name: aws.String("instance-state-name"), Values: []string{"running"}},
+			},
+		},
+		{
+			filterStrs:  []string{"instance-type=m5.large"},
+			expectedErr: true,
+		},
+		{
+			filterStrs:  []string{"Name=instance-type"},
+			expectedErr: true,
+		},
+	} {
+		t.Run(strings.Join(tc.filterStrs, " "), func(t *testing.T) {
+			filters, err := selectors.ParseEC2Filters(tc.filterStrs)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(filters, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, filters)
+			}
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package console
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/vm"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// consoleModel follows the console output of an instance until it returns to the previous model
+type consoleModel struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	vmClient vm.VMI
+	prev     tea.Model
+	instance instances.Instance
+	viewport viewport.Model
+	output   string
+	outputs  <-chan instances.ConsoleOutput
+}
+
+type outputMsg struct {
+	output instances.ConsoleOutput
+}
+
+type followMsg struct {
+	outputs <-chan instances.ConsoleOutput
+}
+
+func NewConsole(ctx context.Context, vmClient vm.VMI, instance instances.Instance, prev tea.Model) *consoleModel {
+	ctx, cancel := context.WithCancel(ctx)
+	return &consoleModel{
+		ctx:      ctx,
+		cancel:   cancel,
+		vmClient: vmClient,
+		prev:     prev,
+		instance: instance,
+		viewport: viewport.New(0, 0),
+	}
+}
+
+func (m consoleModel) Init() tea.Cmd {
+	return func() tea.Msg {
+		outputs, err := m.vmClient.ConsoleOutput(m.ctx, m.instance.Namespace(), m.instance.Name(), []instances.Selector{{ID: *m.instance.InstanceId}}, true)
+		if err != nil {
+			logging.FromContext(m.ctx).Error("Unable to read console output", "instance-id", *m.instance.InstanceId, "error", err)
+			return nil
+		}
+		return followMsg{outputs: outputs}
+	}
+}
+
+// waitForOutput blocks until the next console output is read
+func (m consoleModel) waitForOutput() tea.Cmd {
+	if m.outputs == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		output, ok := <-m.outputs
+		if !ok {
+			return nil
+		}
+		return outputMsg{output: output}
+	}
+}
+
+func (m consoleModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmd tea.Cmd
+	switch msg := msg.(type) {
+
+	case tea.WindowSizeMsg:
+		m.viewport.Width = msg.Width
+		m.viewport.Height = msg.Height - 2
+
+	case followMsg:
+		m.outputs = msg.outputs
+		return m, m.waitForOutput()
+
+	case outputMsg:
+		// stay at the bottom to follow new output unless scrolled up
+		atBottom := m.viewport.AtBottom()
+		m.output += msg.output.Output
+		m.viewport.SetContent(m.output)
+		if atBottom {
+			m.viewport.GotoBottom()
+		}
+		return m, m.waitForOutput()
+
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "ctrl+c":
+			m.cancel()
+			return m, tea.Interrupt
+		case "q":
+			m.cancel()
+			return m, tea.Quit
+		case "esc":
+			m.cancel()
+			return m.prev, nil
+		}
+	}
+
+	m.viewport, cmd = m.viewport.Update(msg)
+	return m, cmd
+}
+
+func (m consoleModel) View() string {
+	return fmt.Sprintf("Console output of %s (%s)  esc: back\n%s", *m.instance.InstanceId, m.instance.Name(), m.viewport.View())
+}
@@ -8,8 +8,14 @@ type keyMap struct {
 	Down  key.Binding
 	Left  key.Binding
 	Right key.Binding
-	Help  key.Binding
-	Quit  key.Binding
+	// Instance lifecycle actions on the selected instance
+	Stop   key.Binding
+	Start  key.Binding
+	Reboot key.Binding
+	// Console shows the console output of the selected instance
+	Console key.Binding
+	Help    key.Binding
+	Quit    key.Binding
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
@@ -22,8 +28,9 @@ func (k keyMap) ShortHelp() []key.Binding {
 // key.Map interface.
 func (k keyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
-		{k.Up, k.Down, k.Left, k.Right}, // first column
-		{k.Help, k.Quit},                // second column
+		{k.Up, k.Down, k.Left, k.Right},        // first column
+		{k.Stop, k.Start, k.Reboot, k.Console}, // second column
+		{k.Help, k.Quit},                       // third column
 	}
 }
 
@@ -44,6 +51,22 @@ var keys = keyMap{
 		key.WithKeys("right", "l"),
 		key.WithHelp("→/l", "move right"),
 	),
+	Stop: key.NewBinding(
+		key.WithKeys("s"),
+		key.WithHelp("s", "stop"),
+	),
+	Start: key.NewBinding(
+		key.WithKeys("S"),
+		key.WithHelp("S", "start"),
+	),
+	Reboot: key.NewBinding(
+		key.WithKeys("r"),
+		key.WithHelp("r", "reboot"),
+	),
+	Console: key.NewBinding(
+		key.WithKeys("o"),
+		key.WithHelp("o", "console output"),
+	),
 	Help: key.NewBinding(
 		key.WithKeys("?"),
 		key.WithHelp("?", "toggle help"),
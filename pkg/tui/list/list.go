@@ -2,11 +2,13 @@ package list
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
 	"github.com/bwagner5/nimbus/pkg/logging"
 	"github.com/bwagner5/nimbus/pkg/pretty"
 	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/tui/console"
 	"github.com/bwagner5/nimbus/pkg/tui/launch"
 	"github.com/bwagner5/nimbus/pkg/vm"
 	"github.com/charmbracelet/bubbles/help"
@@ -20,6 +22,7 @@ type ListModel struct {
 	vmClient  vm.VMI
 	namesapce string
 	name      string
+	region    string
 	// window
 	height int
 	width  int
@@ -27,37 +30,80 @@ type ListModel struct {
 	table     table.Model
 	instances []instances.Instance
 	help      help.Model
+	events    <-chan vm.Event
 }
 
 type listMsg struct {
 	instances []instances.Instance
 }
 
+type watchMsg struct {
+	event vm.Event
+}
+
 type updatedMsg struct{}
 
 // type ListModel struct {
 // 	table.Model
 // }
 
-func NewList(ctx context.Context, vmClient vm.VMI, namespace, name string) *ListModel {
+func NewList(ctx context.Context, vmClient vm.VMI, namespace, name, region string) *ListModel {
+	events, err := vmClient.Watch(ctx, namespace)
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to watch instances, auto-refresh is disabled", "error", err)
+	}
 	return &ListModel{
+		events:    events,
 		ctx:       ctx,
 		vmClient:  vmClient,
 		namesapce: namespace,
 		name:      name,
+		region:    region,
 		help:      help.New(),
 	}
 }
 
 func (m ListModel) Init() tea.Cmd {
-	return func() tea.Msg {
+	return tea.Batch(func() tea.Msg {
 		instanceList, err := m.vmClient.List(m.ctx, m.namesapce, m.name)
 		if err != nil {
 			logging.FromContext(m.ctx).Error("Unable to list instances", "error", err)
 		}
 		logging.FromContext(m.ctx).Info("Listed VMs", "vms", len(instanceList))
 		return listMsg{instances: instanceList}
+	}, m.waitForEvent())
+}
+
+// waitForEvent blocks until the next watch event is received
+func (m ListModel) waitForEvent() tea.Cmd {
+	if m.events == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		event, ok := <-m.events
+		if !ok {
+			return nil
+		}
+		return watchMsg{event: event}
+	}
+}
+
+// applyEvent updates the instance list with a watch event
+func (m ListModel) applyEvent(event vm.Event) []instances.Instance {
+	if m.name != "" && event.Instance.Name() != m.name {
+		return m.instances
+	}
+	instanceList := lo.Reject(m.instances, func(instance instances.Instance, _ int) bool {
+		return *instance.InstanceId == *event.Instance.InstanceId
+	})
+	if event.Type == vm.EventDeleted {
+		return instanceList
+	}
+	index := lo.IndexOf(lo.Map(m.instances, func(instance instances.Instance, _ int) string { return *instance.InstanceId }), *event.Instance.InstanceId)
+	if index == -1 {
+		return append(instanceList, event.Instance)
 	}
+	return append(instanceList[:index], append([]instances.Instance{event.Instance}, instanceList[index:]...)...)
 }
 
 func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -75,6 +121,13 @@ func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.table = instancesToTable(msg.instances)
 		m.instances = msg.instances
 
+	case watchMsg:
+		cursor := m.table.Cursor()
+		m.instances = m.applyEvent(msg.event)
+		m.table = instancesToTable(m.instances)
+		m.table.SetCursor(min(cursor, max(len(m.instances)-1, 0)))
+		return m, m.waitForEvent()
+
 	case updatedMsg:
 		return m, nil
 
@@ -100,6 +153,27 @@ func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				return updatedMsg{}
 			}
+		// Stop, start, and reboot, the watch picks up the new instance state
+		case "s":
+			return m, m.transition("stop", func(instance instances.Instance) ([]instances.Instance, error) {
+				return m.vmClient.Stop(m.ctx, instance.Namespace(), instance.Name(), []instances.Selector{{ID: *instance.InstanceId}}, false)
+			})
+		case "S":
+			return m, m.transition("start", func(instance instances.Instance) ([]instances.Instance, error) {
+				return m.vmClient.Start(m.ctx, instance.Namespace(), instance.Name(), []instances.Selector{{ID: *instance.InstanceId}})
+			})
+		case "r":
+			return m, m.transition("reboot", func(instance instances.Instance) ([]instances.Instance, error) {
+				return m.vmClient.Reboot(m.ctx, instance.Namespace(), instance.Name(), []instances.Selector{{ID: *instance.InstanceId}})
+			})
+		// Console output of the selected instance
+		case "o":
+			if len(m.instances) == 0 {
+				return m, nil
+			}
+			consoleModel := console.NewConsole(m.ctx, m.vmClient, m.instances[m.table.Cursor()], m)
+			// bubbletea only initializes the first model, so the console starts following here
+			return consoleModel, tea.Batch(tea.WindowSize(), consoleModel.Init())
 		// Launch
 		case "l":
 			return launch.NewLaunch(m.ctx, m.vmClient, m), tea.WindowSize()
@@ -118,7 +192,23 @@ func (m ListModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, cmd
 }
 
+// transition applies a lifecycle action to the selected instance
+func (m ListModel) transition(action string, apply func(instances.Instance) ([]instances.Instance, error)) tea.Cmd {
+	if len(m.instances) == 0 {
+		return nil
+	}
+	selectedInstance := m.instances[m.table.Cursor()]
+	return func() tea.Msg {
+		if _, err := apply(selectedInstance); err != nil {
+			logging.FromContext(m.ctx).Error("Unable to transition instance", "action", action, "instance-id", *selectedInstance.InstanceId, "error", err)
+			return nil
+		}
+		return updatedMsg{}
+	}
+}
+
 func (m ListModel) View() string {
+	headerView := m.headerView()
 	tableView := m.table.View()
 	helpView := m.help.View(keys)
 
@@ -126,16 +216,19 @@ func (m ListModel) View() string {
 		return ""
 	}
 	// height between rendered models to position help at the bottom
-	height := m.height - strings.Count(tableView, "\n") - strings.Count(helpView, "\n") - 1
+	height := m.height - strings.Count(headerView, "\n") - strings.Count(tableView, "\n") - strings.Count(helpView, "\n") - 1
 
-	return tableView + strings.Repeat("\n", height) + helpView
+	return headerView + tableView + strings.Repeat("\n", height) + helpView
+}
+
+// headerView renders the active context so users know which namespace and region they are looking at
+func (m ListModel) headerView() string {
+	return fmt.Sprintf("Namespace: %s  Region: %s\n", lo.Ternary(m.namesapce == "", "<all>", m.namesapce), m.region)
 }
 
 func instancesToTable(instanceList []instances.Instance) table.Model {
 	t := table.New()
-	prettyInstances := lo.FilterMap(instanceList, func(instance instances.Instance, _ int) (instances.PrettyInstance, bool) {
-		return instance.Prettify(), true
-	})
+	prettyInstances := instances.PrettifyAll(instanceList)
 	headers, rows := pretty.HeadersAndRows(prettyInstances, false)
 	t.SetColumns(lo.Map(headers, func(header string, _ int) table.Column {
 		return table.Column{Title: header, Width: 20}
@@ -29,7 +29,7 @@ func Launch(ctx context.Context, vmClient vm.AWSVM, cmd, namespace, name string,
 	case "launch":
 		p = tea.NewProgram(launch.NewLaunch(ctx, vmClient, nil), tea.WithContext(ctx), tea.WithAltScreen())
 	default:
-		p = tea.NewProgram(list.NewList(ctx, vmClient, namespace, name), tea.WithContext(ctx), tea.WithAltScreen())
+		p = tea.NewProgram(list.NewList(ctx, vmClient, namespace, name, vmClient.Region()), tea.WithContext(ctx), tea.WithAltScreen())
 	}
 
 	if _, err := p.Run(); err != nil {
@@ -0,0 +1,67 @@
+package userconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/bwagner5/nimbus/pkg/maintenance"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PathEnvVar overrides the default location of the user config file
+	PathEnvVar = "NIMBUS_CONFIG"
+)
+
+// Config is the persisted user context that provides defaults for global CLI options,
+// analogous to a kubectl context.
+type Config struct {
+	Namespace string `yaml:"namespace,omitempty"`
+	Region    string `yaml:"region,omitempty"`
+	// Maintenance gates disruptive commands to maintenance windows
+	Maintenance maintenance.Config `yaml:"maintenance,omitempty"`
+}
+
+// DefaultPath returns the location of the user config file.
+// The NIMBUS_CONFIG env var takes precedence over ~/.nimbus/config
+func DefaultPath() (string, error) {
+	if path := os.Getenv(PathEnvVar); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("unable to determine home directory for nimbus config: %w", err)
+	}
+	return filepath.Join(home, ".nimbus", "config"), nil
+}
+
+// Load reads the user config from path. A missing file is not an error and returns an empty Config.
+func Load(path string) (Config, error) {
+	var cfg Config
+	configBytes, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("unable to read nimbus config %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(configBytes, &cfg); err != nil {
+		return cfg, fmt.Errorf("unable to parse nimbus config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Save writes the user config to path, creating the parent directory if needed
+func (c Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("unable to create nimbus config directory: %w", err)
+	}
+	configBytes, err := yaml.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, configBytes, 0o644)
+}
@@ -0,0 +1,173 @@
+package userdata
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strings"
+)
+
+const (
+	// MaxSize is the largest user-data that EC2 accepts, before it is base64 encoded
+	MaxSize = 16 * 1024
+	// mimeBoundary separates the parts of multi-part user-data. It is fixed so that the same parts always combine into the same user-data.
+	mimeBoundary = "==NIMBUS-BOUNDARY=="
+)
+
+// contentTypes are the cloud-init content types of parts, detected by the start of the part
+var contentTypes = []struct {
+	prefix      string
+	contentType string
+}{
+	{"#cloud-config", "text/cloud-config"},
+	{"#cloud-boothook", "text/cloud-boothook"},
+	{"#include", "text/x-include-url"},
+	{"#part-handler", "text/part-handler"},
+	{cloudInitJinjaHeader, "text/jinja2"},
+	{"#!", "text/x-shellscript"},
+}
+
+// Load returns the user-data of the sources, combining multiple sources into a MIME multi-part archive that cloud-init runs in order.
+// A source is inline user-data, a file:// URI or the path of an existing file, or an http:// or https:// URL that is downloaded.
+// Empty sources are skipped.
+func Load(ctx context.Context, sources ...string) (string, error) {
+	var parts []string
+	for _, source := range sources {
+		if strings.TrimSpace(source) == "" {
+			continue
+		}
+		part, err := loadSource(ctx, source)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+	}
+	switch len(parts) {
+	case 0:
+		return "", nil
+	case 1:
+		return parts[0], nil
+	}
+	return Combine(parts)
+}
+
+// loadSource reads a single source of user-data
+func loadSource(ctx context.Context, source string) (string, error) {
+	switch {
+	case strings.HasPrefix(source, "file://"):
+		return readFile(strings.TrimPrefix(source, "file://"))
+	case strings.HasPrefix(source, "https://"), strings.HasPrefix(source, "http://"):
+		return download(ctx, source)
+	}
+	// a single line that names a file is a path, anything else is inline user-data
+	if !strings.Contains(source, "\n") && !strings.HasPrefix(source, "#") {
+		if info, err := os.Stat(source); err == nil && !info.IsDir() {
+			return readFile(source)
+		}
+	}
+	return source, nil
+}
+
+func readFile(path string) (string, error) {
+	userData, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read user-data: %w", err)
+	}
+	return string(userData), nil
+}
+
+func download(ctx context.Context, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("unable to download user-data: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to download user-data: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to download user-data from %s: %s", url, resp.Status)
+	}
+	// anything larger than the limit of compressed user-data can not be launched anyway
+	userData, err := io.ReadAll(io.LimitReader(resp.Body, 16*MaxSize))
+	if err != nil {
+		return "", fmt.Errorf("unable to download user-data from %s: %w", url, err)
+	}
+	return string(userData), nil
+}
+
+// Combine returns a MIME multi-part archive of the parts, which cloud-init processes in order.
+// The content type of each part is detected from its first line, e.g. #cloud-config or a #! shebang.
+func Combine(parts []string) (string, error) {
+	var archive bytes.Buffer
+	fmt.Fprintf(&archive, "Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n", mimeBoundary)
+	writer := multipart.NewWriter(&archive)
+	if err := writer.SetBoundary(mimeBoundary); err != nil {
+		return "", err
+	}
+	for i, part := range parts {
+		contentType, err := detectContentType(part)
+		if err != nil {
+			return "", fmt.Errorf("user-data part %d: %w", i+1, err)
+		}
+		partWriter, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":        {contentType + `; charset="utf-8"`},
+			"Mime-Version":        {"1.0"},
+			"Content-Disposition": {fmt.Sprintf(`attachment; filename="part-%03d"`, i+1)},
+		})
+		if err != nil {
+			return "", err
+		}
+		if _, err := io.WriteString(partWriter, part); err != nil {
+			return "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return archive.String(), nil
+}
+
+// detectContentType returns the cloud-init content type of a part of multi-part user-data
+func detectContentType(part string) (string, error) {
+	for _, c := range contentTypes {
+		if strings.HasPrefix(part, c.prefix) {
+			return c.contentType, nil
+		}
+	}
+	if strings.HasPrefix(part, "Content-Type: multipart") {
+		return "", fmt.Errorf("multi-part user-data can not be combined again")
+	}
+	return "", fmt.Errorf("unable to detect the content type, parts must start with #cloud-config, a #! shebang, or another cloud-init header")
+}
+
+// Compress gzips user-data that is larger than MaxSize, cloud-init decompresses it at boot.
+// An error is returned if the user-data is still too large once it is compressed.
+func Compress(userData string) (string, error) {
+	if len(userData) <= MaxSize {
+		return userData, nil
+	}
+	var compressed bytes.Buffer
+	// the header has no name or modification time, so the same user-data always compresses to the same bytes
+	writer, err := gzip.NewWriterLevel(&compressed, gzip.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(writer, userData); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	if compressed.Len() > MaxSize {
+		return "", fmt.Errorf("user-data is %d bytes gzipped, larger than the %d bytes that EC2 accepts", compressed.Len(), MaxSize)
+	}
+	return compressed.String(), nil
+}
@@ -0,0 +1,105 @@
+package userdata_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/userdata"
+)
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "setup.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/bash\necho setup\n"), 0o600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cloud-config.yaml" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = io.WriteString(w, "#cloud-config\npackages: [git]\n")
+	}))
+	defer server.Close()
+
+	for _, tc := range []struct {
+		name        string
+		sources     []string
+		expected    string
+		contains    []string
+		expectedErr bool
+	}{
+		{name: "none", sources: []string{""}, expected: ""},
+		{name: "inline", sources: []string{"#!/bin/sh\necho hi\n"}, expected: "#!/bin/sh\necho hi\n"},
+		{name: "file URI", sources: []string{"file://" + script}, expected: "#!/bin/bash\necho setup\n"},
+		{name: "file path", sources: []string{script}, expected: "#!/bin/bash\necho setup\n"},
+		{name: "URL", sources: []string{server.URL + "/cloud-config.yaml"}, expected: "#cloud-config\npackages: [git]\n"},
+		{
+			name:    "multi-part",
+			sources: []string{server.URL + "/cloud-config.yaml", script},
+			contains: []string{
+				"Content-Type: multipart/mixed",
+				"Content-Type: text/cloud-config",
+				"Content-Type: text/x-shellscript",
+				`filename="part-002"`,
+				"echo setup",
+			},
+		},
+		{name: "missing file", sources: []string{"file://" + filepath.Join(dir, "missing.sh")}, expectedErr: true},
+		{name: "missing URL", sources: []string{server.URL + "/missing"}, expectedErr: true},
+		{name: "unknown part", sources: []string{"#!/bin/sh\n", "echo hi"}, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userData, err := userdata.Load(context.Background(), tc.sources...)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tc.contains == nil && userData != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, userData)
+			}
+			for _, s := range tc.contains {
+				if !strings.Contains(userData, s) {
+					t.Errorf("expected %q in %q", s, userData)
+				}
+			}
+		})
+	}
+}
+
+func TestCompress(t *testing.T) {
+	small := "#!/bin/sh\necho hi\n"
+	if compressed, err := userdata.Compress(small); err != nil || compressed != small {
+		t.Errorf("expected user-data within the limit to be unchanged, got %q, %v", compressed, err)
+	}
+
+	large := "#!/bin/sh\n" + strings.Repeat("echo hello world\n", userdata.MaxSize/10)
+	compressed, err := userdata.Compress(large)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	reader, err := gzip.NewReader(bytes.NewBufferString(compressed))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	decompressed, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(decompressed) != large {
+		t.Errorf("expected the gzipped user-data to decompress to the original")
+	}
+}
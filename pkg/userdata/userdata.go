@@ -0,0 +1,240 @@
+package userdata
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/samber/lo"
+)
+
+// Metadata is the data available to user-data templates
+//
+// Example:
+//
+//	#!/bin/bash
+//	echo "{{ .Namespace }}/{{ .Name }} in {{ .Region }} is {{ .Instance.ID }}, the {{ .Vars.role }}"
+//	echo "joining {{ (index .Groups "controller").PrivateIP }}"
+type Metadata struct {
+	Namespace string
+	Name      string
+	Group     string
+	Region    string
+	// Vars are user-supplied variables, referencing a variable that is not set is an error
+	Vars map[string]string
+	// Instance has placeholders for the metadata of the instance that runs the user-data
+	Instance InstanceMetadata
+	// Groups is keyed by node group name and contains the node groups that the rendered group depends on
+	Groups map[string]GroupMetadata
+}
+
+// InstanceMetadata renders placeholders for metadata that is only known once an instance boots.
+// The placeholders are shell command substitutions that query the instance metadata service with IMDSv2, so they require shell script user-data.
+type InstanceMetadata struct{}
+
+// ID is a placeholder for the instance's ID
+func (InstanceMetadata) ID() string { return imdsPlaceholder("instance-id") }
+
+// Type is a placeholder for the instance's type
+func (InstanceMetadata) Type() string { return imdsPlaceholder("instance-type") }
+
+// AZ is a placeholder for the instance's availability zone
+func (InstanceMetadata) AZ() string { return imdsPlaceholder("placement/availability-zone") }
+
+// PrivateIP is a placeholder for the instance's primary private IPv4 address
+func (InstanceMetadata) PrivateIP() string { return imdsPlaceholder("local-ipv4") }
+
+// imdsPlaceholder returns a shell command substitution that reads the instance metadata path with an IMDSv2 session token
+func imdsPlaceholder(metadataPath string) string {
+	return fmt.Sprintf(`$(curl -sf -H "X-aws-ec2-metadata-token: $(curl -sf -X PUT -H 'X-aws-ec2-metadata-token-ttl-seconds: 60' http://169.254.169.254/latest/api/token)" http://169.254.169.254/latest/meta-data/%s)`, metadataPath)
+}
+
+// GroupMetadata describes the launched instances of a node group
+type GroupMetadata struct {
+	InstanceIDs []string
+	PrivateIPs  []string
+	PublicIPs   []string
+}
+
+// NewGroupMetadata returns the GroupMetadata of the launched instances
+func NewGroupMetadata(instanceList []instances.Instance) GroupMetadata {
+	var metadata GroupMetadata
+	for _, instance := range instanceList {
+		metadata.InstanceIDs = append(metadata.InstanceIDs, lo.FromPtr(instance.InstanceId))
+		if instance.PrivateIpAddress != nil {
+			metadata.PrivateIPs = append(metadata.PrivateIPs, *instance.PrivateIpAddress)
+		}
+		if instance.PublicIpAddress != nil {
+			metadata.PublicIPs = append(metadata.PublicIPs, *instance.PublicIpAddress)
+		}
+	}
+	return metadata
+}
+
+// PrivateIP returns the private IP of the group's first instance
+func (g GroupMetadata) PrivateIP() string {
+	return lo.FirstOrEmpty(g.PrivateIPs)
+}
+
+// PublicIP returns the public IP of the group's first instance
+func (g GroupMetadata) PublicIP() string {
+	return lo.FirstOrEmpty(g.PublicIPs)
+}
+
+// ParseVars parses key=value user-data variables, values may contain = and commas
+func ParseVars(vars []string) (map[string]string, error) {
+	if len(vars) == 0 {
+		return nil, nil
+	}
+	parsed := make(map[string]string, len(vars))
+	for _, v := range vars {
+		key, value, ok := strings.Cut(v, "=")
+		if key = strings.TrimSpace(key); !ok || key == "" {
+			return nil, fmt.Errorf("invalid user-data variable %q, expected key=value", v)
+		}
+		parsed[key] = value
+	}
+	return parsed, nil
+}
+
+// Render executes userData as a go text/template with the metadata.
+// Referencing a group or variable that is not in the metadata is an error.
+// Cloud-init jinja templates are returned unchanged since they use the same delimiters and are rendered by cloud-init at boot.
+func Render(userData string, metadata Metadata) (string, error) {
+	if strings.HasPrefix(userData, cloudInitJinjaHeader) {
+		return userData, nil
+	}
+	tmpl, err := template.New("user-data").Option("missingkey=error").Parse(userData)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse user-data template: %w", err)
+	}
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, metadata); err != nil {
+		return "", fmt.Errorf("failed to render user-data template: %w", err)
+	}
+	return rendered.String(), nil
+}
+
+// WithShutdown schedules a shutdown of the instance after the TTL from when the user-data runs at boot.
+// The shutdown is added to the start of a shell script, or is the whole script if there is no user-data.
+// Other user-data formats like cloud-config are an error since the shutdown can not be combined with them.
+func WithShutdown(userData string, ttl time.Duration) (string, error) {
+	userData, err := prepend(userData, fmt.Sprintf("shutdown -h +%d\n", int(math.Ceil(ttl.Minutes()))))
+	if err != nil {
+		return "", fmt.Errorf("a TTL %w", err)
+	}
+	return userData, nil
+}
+
+// WithGPUDrivers installs the NVIDIA driver and CUDA toolkit from NVIDIA's Amazon Linux 2023 repository before the rest of the user-data runs.
+// The rest of the user-data runs after the install, even if it failed, and the install's output is logged to /var/log/nimbus-gpu-drivers.log.
+// Like WithShutdown, it requires shell script user-data.
+func WithGPUDrivers(userData string) (string, error) {
+	userData, err := prepend(userData, gpuDrivers)
+	if err != nil {
+		return "", fmt.Errorf("GPU driver installation %w", err)
+	}
+	return userData, nil
+}
+
+// WithArtifacts runs the commands that download the instance's artifacts before the rest of the user-data runs.
+// The rest of the user-data runs even if a download failed, and the downloads' output is logged to /var/log/nimbus-artifacts.log.
+// Like WithShutdown, it requires shell script user-data.
+func WithArtifacts(userData string, download string) (string, error) {
+	userData, err := prepend(userData, fmt.Sprintf("(\n  set -e\n%s) > /var/log/nimbus-artifacts.log 2>&1\n", indent(download)))
+	if err != nil {
+		return "", fmt.Errorf("artifacts %w", err)
+	}
+	return userData, nil
+}
+
+// WithLogShipping installs and starts the CloudWatch agent with the configuration before the rest of the user-data runs, see the logs package.
+// The agent is installed from the distribution's packages, and the rest of the user-data runs even if the install failed.
+// The install's output is logged to /var/log/nimbus-logs.log, which the configuration may ship itself. Like WithShutdown, it requires shell script user-data.
+func WithLogShipping(userData string, configPath string, agentConfig string) (string, error) {
+	// the configuration is base64 encoded so that it can not end a heredoc or be interpreted by the user-data shell
+	userData, err := prepend(userData, fmt.Sprintf(`(
+  set -e
+  dnf install -y amazon-cloudwatch-agent || yum install -y amazon-cloudwatch-agent
+  mkdir -p %[1]s
+  echo %[2]s | base64 -d > %[3]s
+  /opt/aws/amazon-cloudwatch-agent/bin/amazon-cloudwatch-agent-ctl -a fetch-config -m ec2 -s -c file:%[3]s
+) > /var/log/nimbus-logs.log 2>&1
+`, path.Dir(configPath), base64.StdEncoding.EncodeToString([]byte(agentConfig)), configPath))
+	if err != nil {
+		return "", fmt.Errorf("log shipping %w", err)
+	}
+	return userData, nil
+}
+
+// cloudInitJinjaHeader is the first line of user-data that cloud-init renders as a jinja template
+const cloudInitJinjaHeader = "## template: jinja"
+
+// JobDir is where the user-data of a job writes its script, output, and exit code
+const JobDir = "/var/log/nimbus-job"
+
+// Job returns shell script user-data that runs the script once at boot, writing its combined output to JobDir/output
+// and its exit code to JobDir/exit-code when it finishes. Scripts with a shebang run with its interpreter, others with sh.
+// The optional setup commands run before the script, which does not run if they fail, and the teardown commands run after it.
+// A failed setup or teardown is the job's exit code unless the script failed. The exit code is only written after the teardown,
+// so the job is not finished while it still runs, e.g. to publish outputs.
+func Job(script, setup, teardown string) string {
+	interpreter := lo.Ternary(strings.HasPrefix(script, "#!"), "", "sh ")
+	// the script is base64 encoded so that it can not end a heredoc or be interpreted by the user-data shell
+	return fmt.Sprintf(`#!/bin/bash
+mkdir -p %[1]s
+echo %[2]s | base64 -d > %[1]s/script
+chmod +x %[1]s/script
+cd /root
+: > %[1]s/output
+(
+  set -e
+%[4]s) >> %[1]s/output 2>&1 < /dev/null && %[3]s%[1]s/script >> %[1]s/output 2>&1 < /dev/null
+code=$?
+(
+  set -e
+%[5]s) >> %[1]s/output 2>&1 < /dev/null || { [ $code -ne 0 ] || code=$?; }
+echo $code > %[1]s/exit-code.tmp
+mv %[1]s/exit-code.tmp %[1]s/exit-code
+`, JobDir, base64.StdEncoding.EncodeToString([]byte(script)), interpreter, indent(setup), indent(teardown))
+}
+
+// indent indents every line of the commands for a subshell, ending with a newline
+func indent(commands string) string {
+	var indented strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(commands, "\n"), "\n") {
+		if line != "" {
+			indented.WriteString("  " + line + "\n")
+		}
+	}
+	return indented.String()
+}
+
+// gpuDrivers installs the open NVIDIA kernel modules with DKMS, which builds them for the running kernel, and the CUDA toolkit
+const gpuDrivers = `(
+  set -e
+  arch=$(uname -m); [ "$arch" = aarch64 ] && arch=sbsa
+  dnf install -y "kernel-devel-$(uname -r)" "kernel-headers-$(uname -r)" "kernel-modules-extra-$(uname -r)"
+  dnf config-manager --add-repo "https://developer.download.nvidia.com/compute/cuda/repos/amzn2023/$arch/cuda-amzn2023.repo"
+  dnf module install -y nvidia-driver:open-dkms
+  dnf install -y cuda-toolkit
+) > /var/log/nimbus-gpu-drivers.log 2>&1
+`
+
+// prepend adds the commands to the start of a shell script, or makes them the whole script if there is no user-data
+func prepend(userData string, commands string) (string, error) {
+	if strings.TrimSpace(userData) == "" {
+		return "#!/bin/sh\n" + commands, nil
+	}
+	shebang, script, _ := strings.Cut(userData, "\n")
+	if !lo.Contains([]string{"#!/bin/sh", "#!/bin/bash", "#!/usr/bin/env bash", "#!/usr/bin/env sh"}, strings.TrimSpace(shebang)) {
+		return "", fmt.Errorf("requires shell script user-data that starts with #!/bin/sh or #!/bin/bash")
+	}
+	return shebang + "\n" + commands + script, nil
+}
@@ -0,0 +1,160 @@
+package userdata_test
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/userdata"
+)
+
+func TestWithShutdown(t *testing.T) {
+	type testCases struct {
+		name        string
+		userData    string
+		ttl         time.Duration
+		expected    string
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{name: "no user-data", ttl: 8 * time.Hour, expected: "#!/bin/sh\nshutdown -h +480\n"},
+		{name: "shell script", userData: "#!/bin/bash\necho hi\n", ttl: 90 * time.Second, expected: "#!/bin/bash\nshutdown -h +2\necho hi\n"},
+		{name: "cloud-config", userData: "#cloud-config\npackages: [git]\n", ttl: time.Hour, expectedErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userData, err := userdata.WithShutdown(tc.userData, tc.ttl)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if userData != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, userData)
+			}
+		})
+	}
+}
+
+func TestWithGPUDrivers(t *testing.T) {
+	userData, err := userdata.WithGPUDrivers("#!/bin/bash\necho hi\n")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(userData, "#!/bin/bash\n(\n") || !strings.HasSuffix(userData, "/var/log/nimbus-gpu-drivers.log 2>&1\necho hi\n") {
+		t.Errorf("expected the driver install between the shebang and the script, got %q", userData)
+	}
+	if _, err := userdata.WithGPUDrivers("#cloud-config\npackages: [git]\n"); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+}
+
+func TestJob(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		script   string
+		setup    string
+		teardown string
+		expected string
+	}{
+		{name: "command", script: "echo hi", expected: " && sh /var/log/nimbus-job/script >> /var/log/nimbus-job/output 2>&1"},
+		{name: "shebang", script: "#!/usr/bin/env python3\nprint('hi')\n", expected: " && /var/log/nimbus-job/script >> /var/log/nimbus-job/output 2>&1"},
+		{name: "setup", script: "make", setup: "mkdir -p /data\ncurl -o /data/in x\n", expected: "  set -e\n  mkdir -p /data\n  curl -o /data/in x\n) >> /var/log/nimbus-job/output"},
+		{name: "teardown", script: "make", teardown: "aws s3 sync out s3://bucket/out", expected: "  set -e\n  aws s3 sync out s3://bucket/out\n) >> /var/log/nimbus-job/output 2>&1 < /dev/null || {"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			userData := userdata.Job(tc.script, tc.setup, tc.teardown)
+			if !strings.HasPrefix(userData, "#!/bin/bash\n") || !strings.Contains(userData, tc.expected) {
+				t.Errorf("expected job user-data to contain %q, got %s", tc.expected, userData)
+			}
+			if !strings.Contains(userData, base64.StdEncoding.EncodeToString([]byte(tc.script))) {
+				t.Errorf("expected job user-data to contain the encoded script, got %s", userData)
+			}
+			// the TTL shutdown is added to job user-data
+			if _, err := userdata.WithShutdown(userData, time.Hour); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestWithLogShipping(t *testing.T) {
+	config := `{"logs":{"logs_collected":{}}}`
+	userData, err := userdata.WithLogShipping("#!/bin/bash\necho hi\n", "/opt/aws/amazon-cloudwatch-agent/etc/nimbus.json", config)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(userData, "#!/bin/bash\n(\n") || !strings.HasSuffix(userData, "/var/log/nimbus-logs.log 2>&1\necho hi\n") {
+		t.Errorf("expected the agent install between the shebang and the script, got %q", userData)
+	}
+	if !strings.Contains(userData, base64.StdEncoding.EncodeToString([]byte(config))) || !strings.Contains(userData, "-c file:/opt/aws/amazon-cloudwatch-agent/etc/nimbus.json") {
+		t.Errorf("expected the agent to start with the encoded configuration, got %s", userData)
+	}
+	if _, err := userdata.WithLogShipping("#cloud-config\npackages: [git]\n", "/etc/agent.json", config); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+}
+
+func TestRender(t *testing.T) {
+	metadata := userdata.Metadata{
+		Namespace: "dev",
+		Name:      "web",
+		Region:    "us-west-2",
+		Vars:      map[string]string{"role": "frontend"},
+		Groups:    map[string]userdata.GroupMetadata{"controller": {PrivateIPs: []string{"10.0.0.5"}}},
+	}
+	for _, tc := range []struct {
+		name        string
+		userData    string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "built-in variables", userData: "#!/bin/sh\necho {{ .Namespace }}/{{ .Name }} {{ .Region }}\n", expected: "#!/bin/sh\necho dev/web us-west-2\n"},
+		{name: "user variables", userData: "#!/bin/sh\necho {{ .Vars.role }}\n", expected: "#!/bin/sh\necho frontend\n"},
+		{name: "group metadata", userData: `join {{ (index .Groups "controller").PrivateIP }}`, expected: "join 10.0.0.5"},
+		{name: "missing variable", userData: "echo {{ .Vars.env }}", expectedErr: true},
+		{name: "cloud-init jinja", userData: "## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n", expected: "## template: jinja\n#cloud-config\nhostname: {{ v1.local_hostname }}\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rendered, err := userdata.Render(tc.userData, metadata)
+			if tc.expectedErr {
+				if err == nil {
+					t.Errorf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if rendered != tc.expected {
+				t.Errorf("expected %q, got %q", tc.expected, rendered)
+			}
+		})
+	}
+
+	rendered, err := userdata.Render("echo {{ .Instance.ID }}", metadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(rendered, "X-aws-ec2-metadata-token") || !strings.HasSuffix(rendered, "/latest/meta-data/instance-id)") {
+		t.Errorf("expected an IMDSv2 placeholder for the instance ID, got %q", rendered)
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	vars, err := userdata.ParseVars([]string{"role=web", "opts=a=1,b=2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(vars) != 2 || vars["role"] != "web" || vars["opts"] != "a=1,b=2" {
+		t.Errorf("expected role and opts variables, got %v", vars)
+	}
+	if _, err := userdata.ParseVars([]string{"role"}); err == nil {
+		t.Errorf("expected an error, got none")
+	}
+}
@@ -0,0 +1,147 @@
+// Package awsutils loads and configures the AWS SDK config shared by nimbus and its plugins so that nimbus activity can be correlated in CloudTrail.
+package awsutils
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	ststypes "github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/readonly"
+	"github.com/samber/lo"
+)
+
+const (
+	// UserAgentKey is the user-agent key that every AWS API request made by nimbus is suffixed with, e.g. nimbus/v0.1.0
+	UserAgentKey = "nimbus"
+	// DefaultSessionName is the role session name used when assuming a role, it shows up in CloudTrail as part of the caller identity
+	DefaultSessionName = "nimbus"
+)
+
+// ConfigOptions are the options that the AWS config is loaded with
+type ConfigOptions struct {
+	Region  string
+	Profile string
+	// NoMutate makes every client fail any API call that would change resources
+	NoMutate bool
+	// RoleARN is an optional IAM role that is assumed for all AWS API calls
+	RoleARN string
+	// SessionTags are applied to the role session when RoleARN is assumed
+	SessionTags map[string]string
+	// Version is reported in the user-agent of AWS API calls as nimbus/<version>
+	Version string
+}
+
+// LoadConfig loads the default AWS config with the optional region, profile, role, and read-only mode applied.
+// Every API call is made with a nimbus/<version> user-agent and its request ID is logged at debug level.
+func LoadConfig(ctx context.Context, opts ConfigOptions) (*aws.Config, error) {
+	var options []func(*config.LoadOptions) error
+	if opts.Region != "" {
+		options = append(options, config.WithRegion(opts.Region))
+	}
+	if opts.Profile != "" {
+		options = append(options, config.WithSharedConfigProfile(opts.Profile))
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, err
+	}
+	ApplyUserAgent(&cfg, opts.Version)
+	ApplyRequestLogging(&cfg)
+	if err := ApplyAssumeRole(&cfg, opts.RoleARN, opts.SessionTags); err != nil {
+		return nil, err
+	}
+	if opts.NoMutate {
+		readonly.Apply(&cfg)
+	}
+	return &cfg, nil
+}
+
+// ApplyUserAgent appends nimbus/<version> to the user-agent of every client created from the AWS config
+func ApplyUserAgent(cfg *aws.Config, version string) {
+	cfg.APIOptions = append(cfg.APIOptions, awsmiddleware.AddUserAgentKeyValue(UserAgentKey, lo.Ternary(version == "", "unknown", version)))
+}
+
+// ApplyRequestLogging logs the request ID of every AWS API call at debug level so that it can be looked up in CloudTrail
+func ApplyRequestLogging(cfg *aws.Config) {
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("NimbusRequestLogging", func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (
+			middleware.DeserializeOutput, middleware.Metadata, error,
+		) {
+			out, metadata, err := next.HandleDeserialize(ctx, in)
+			attrs := []any{
+				"service", awsmiddleware.GetServiceID(ctx),
+				"operation", awsmiddleware.GetOperationName(ctx),
+				"request-id", RequestID(metadata),
+			}
+			if err != nil {
+				attrs = append(attrs, "error", err)
+			}
+			logging.FromContext(ctx).Debug("AWS API call", attrs...)
+			return out, metadata, err
+		}), middleware.Before)
+	})
+}
+
+// RequestID returns the AWS request ID from the result metadata of an API call or "unknown" if it is not available
+// Errors that nimbus builds from a successful response, e.g. partial failures, should include it since the SDK only adds it to API errors.
+func RequestID(metadata middleware.Metadata) string {
+	requestID, ok := awsmiddleware.GetRequestIDMetadata(metadata)
+	if !ok || requestID == "" {
+		return "unknown"
+	}
+	return requestID
+}
+
+// ApplyAssumeRole replaces the credentials of the AWS config with credentials for the role, tagging the role session with the session tags.
+// Session tags are recorded in CloudTrail and can be used in IAM policies as aws:PrincipalTag conditions.
+func ApplyAssumeRole(cfg *aws.Config, roleARN string, sessionTags map[string]string) error {
+	if roleARN == "" {
+		if len(sessionTags) != 0 {
+			return fmt.Errorf("session tags can only be applied when assuming a role")
+		}
+		return nil
+	}
+	keys := lo.Keys(sessionTags)
+	sort.Strings(keys)
+	provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(*cfg), roleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = DefaultSessionName
+		o.Tags = lo.Map(keys, func(key string, _ int) ststypes.Tag {
+			return ststypes.Tag{Key: aws.String(key), Value: aws.String(sessionTags[key])}
+		})
+	})
+	cfg.Credentials = aws.NewCredentialsCache(provider)
+	return nil
+}
+
+// ParseSessionTags parses comma separated key=value session tags, e.g. "team=infra,ticket=OPS-123"
+func ParseSessionTags(tagsStr string) (map[string]string, error) {
+	sessionTags := map[string]string{}
+	for _, tag := range strings.Split(tagsStr, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(tag, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid session tag %q, expected key=value", tag)
+		}
+		sessionTags[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return sessionTags, nil
+}
+
+// FormatSessionTags formats session tags as comma separated key=value pairs, the inverse of ParseSessionTags
+func FormatSessionTags(sessionTags map[string]string) string {
+	keys := lo.Keys(sessionTags)
+	sort.Strings(keys)
+	return strings.Join(lo.Map(keys, func(key string, _ int) string { return key + "=" + sessionTags[key] }), ",")
+}
@@ -0,0 +1,64 @@
+package awsutils_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bwagner5/nimbus/pkg/utils/awsutils"
+)
+
+func TestParseSessionTags(t *testing.T) {
+	type testCases struct {
+		name        string
+		tags        string
+		expected    map[string]string
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name:     "empty",
+			tags:     "",
+			expected: map[string]string{},
+		},
+		{
+			name:     "multiple tags with whitespace",
+			tags:     "team=infra, ticket = OPS-123",
+			expected: map[string]string{"team": "infra", "ticket": "OPS-123"},
+		},
+		{
+			name:     "empty value",
+			tags:     "team=",
+			expected: map[string]string{"team": ""},
+		},
+		{
+			name:        "missing value",
+			tags:        "team",
+			expectedErr: true,
+		},
+		{
+			name:        "missing key",
+			tags:        "=infra",
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			sessionTags, err := awsutils.ParseSessionTags(tc.tags)
+			if tc.expectedErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(sessionTags, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, sessionTags)
+			}
+			if roundTrip, _ := awsutils.ParseSessionTags(awsutils.FormatSessionTags(sessionTags)); !reflect.DeepEqual(roundTrip, tc.expected) {
+				t.Errorf("expected %v after formatting, got %v", tc.expected, roundTrip)
+			}
+		})
+	}
+}
@@ -22,10 +22,30 @@ func NormalizeCapacityType(capacityType string) string {
 	return ""
 }
 
+// IsDryRunOperationErr returns true if the error is EC2's response to a DryRun request that would have succeeded
+func IsDryRunOperationErr(err error) bool {
+	var ae smithy.APIError
+	return errors.As(err, &ae) && ae.ErrorCode() == "DryRunOperation"
+}
+
 func IsAlreadyExistsErr(err error) bool {
 	var ae smithy.APIError
 	errors.As(err, &ae)
 	return slices.Contains([]string{
 		"InvalidLaunchTemplateName.AlreadyExistsException",
+		"InvalidGroup.Duplicate",
+		"InvalidPermission.Duplicate",
+	}, ae.ErrorCode())
+}
+
+// IsDependencyViolationErr returns true if the error is EC2 refusing to delete a resource that is still in use by another resource.
+// Right after instances are terminated, EC2 can still report their network interfaces, volumes, and addresses as in use for a while.
+func IsDependencyViolationErr(err error) bool {
+	var ae smithy.APIError
+	return errors.As(err, &ae) && slices.Contains([]string{
+		"DependencyViolation",
+		"InvalidGroup.InUse",
+		"InvalidIPAddress.InUse",
+		"VolumeInUse",
 	}, ae.ErrorCode())
 }
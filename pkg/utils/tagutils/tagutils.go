@@ -2,6 +2,7 @@ package tagutils
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
@@ -13,6 +14,30 @@ var (
 	NamespaceTagKey = fmt.Sprintf("%s-Namespace", SystemPrefixKey)
 	NameTagKey      = fmt.Sprintf("%s-Name", SystemPrefixKey)
 	CreatedByTagKey = fmt.Sprintf("%s-CreatedBy", SystemPrefixKey)
+	// IndexTagKey records the placement index of an instance launched with a placement map
+	IndexTagKey = fmt.Sprintf("%s-Index", SystemPrefixKey)
+	// GroupTagKey records the node group of resources launched from a multi-group plan
+	GroupTagKey = fmt.Sprintf("%s-Group", SystemPrefixKey)
+	// NetworkPolicyTagKey records whether network infrastructure is shared by a namespace or isolated to a name
+	NetworkPolicyTagKey = fmt.Sprintf("%s-NetworkPolicy", SystemPrefixKey)
+	// SpecHashTagKey records a hash of a launch template's data so that launches with different specs never share a launch template
+	SpecHashTagKey = fmt.Sprintf("%s-SpecHash", SystemPrefixKey)
+	// GenerationTagKey records the generation of the launch plan that an instance was launched with
+	GenerationTagKey = fmt.Sprintf("%s-Generation", SystemPrefixKey)
+	// PlanIDTagKey records the ID of the executed launch plan that an instance was launched by
+	PlanIDTagKey = fmt.Sprintf("%s-PlanID", SystemPrefixKey)
+	// SpecChecksumTagKey records the checksum of the launch plan spec that an instance was launched with
+	SpecChecksumTagKey = fmt.Sprintf("%s-SpecChecksum", SystemPrefixKey)
+	// ExpiresAtTagKey records when an instance launched with a TTL terminates itself, in RFC 3339 format
+	ExpiresAtTagKey = fmt.Sprintf("%s-ExpiresAt", SystemPrefixKey)
+	// LogGroupTagKey records the CloudWatch Logs group that an instance ships its logs to
+	LogGroupTagKey = fmt.Sprintf("%s-LogGroup", SystemPrefixKey)
+	// FaultTagKey records the fault that a FIS experiment template created by nimbus injects
+	FaultTagKey = fmt.Sprintf("%s-Fault", SystemPrefixKey)
+	// DeletionPlanIDTagKey records the ID of the executed deletion plan that took a final snapshot
+	DeletionPlanIDTagKey = fmt.Sprintf("%s-DeletionPlanID", SystemPrefixKey)
+	// DeviceTagKey records the device name that a snapshotted volume was attached to, so that it can be restored to the same device
+	DeviceTagKey = fmt.Sprintf("%s-Device", SystemPrefixKey)
 )
 
 // NamespacedTags returns a map of tag key/value pairs in standardized way.
@@ -59,3 +84,25 @@ func MapToEC2Tags(tags map[string]string) []ec2types.Tag {
 	}
 	return ec2Tags
 }
+
+// ParseTags parses comma separated key=value tags, e.g. "team=infra,env=dev".
+// Keys with the nimbus prefix are reserved for tags that nimbus manages.
+func ParseTags(tagsStr string) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, tag := range strings.Split(tagsStr, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(tag, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid tag %q, expected key=value", tag)
+		}
+		if strings.HasPrefix(key, SystemPrefixKey+"-") {
+			return nil, fmt.Errorf("invalid tag %q, the %s- prefix is reserved", tag, SystemPrefixKey)
+		}
+		tags[key] = strings.TrimSpace(value)
+	}
+	return tags, nil
+}
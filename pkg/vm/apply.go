@@ -0,0 +1,73 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/samber/lo"
+)
+
+// Apply launches a plan that a dry-run resolved, e.g. one saved with launch --plan-out, exactly as it was reviewed.
+// The AMIs, instance types, roles, KMS keys, key pair, and existing network of the plan's status are used instead of resolving them again,
+// while the resources that the dry-run planned are created. The spec must not have changed since the dry-run.
+// A plan resolved in another region is resolved again in the client's region if its spec is region-agnostic, see LaunchSpec.RegionReferences.
+func (v AWSVM) Apply(ctx context.Context, launchPlan plans.LaunchPlan) (plans.LaunchPlan, error) {
+	logging.FromContext(ctx).Debug("Applying Launch Plan")
+	if !launchPlan.Status.DryRun || launchPlan.Status.SpecChecksum == "" {
+		return launchPlan, fmt.Errorf("only plans resolved by a dry-run can be applied, save one with launch --plan-out")
+	}
+	if launchPlan.Status.SpecChecksum != launchPlan.Spec.Checksum() {
+		return launchPlan, fmt.Errorf("the spec of the plan changed after it was resolved, save a new plan with launch --plan-out")
+	}
+	if launchPlan.Status.Region != v.awsCfg.Region {
+		if references := launchPlan.Spec.RegionReferences(); len(references) != 0 {
+			return launchPlan, fmt.Errorf("the plan was resolved in %s and cannot be applied in %s, its spec references resources of %s: %s", launchPlan.Status.Region, v.awsCfg.Region,
+				launchPlan.Status.Region, strings.Join(lo.Map(references, func(reference plans.RegionReference, _ int) string { return reference.String() }), ", "))
+		}
+		// the resolved AMIs, instance types, and network only exist in the plan's region, so the region-agnostic spec is resolved again
+		logging.FromContext(ctx).Info("Resolving the region-agnostic plan again", "resolved-region", launchPlan.Status.Region, "region", v.awsCfg.Region)
+		return v.Launch(ctx, false, launchPlan)
+	}
+	resolved := unflattenSingleNodeGroup(launchPlan).Status
+	if len(resolved.NodeGroups) != len(launchPlan.Spec.EffectiveNodeGroups()) {
+		return launchPlan, fmt.Errorf("the plan has %d resolved node groups but its spec has %d", len(resolved.NodeGroups), len(launchPlan.Spec.EffectiveNodeGroups()))
+	}
+	launchPlan.Status = plans.LaunchStatus{EstimatedCost: resolved.EstimatedCost}
+	return v.launch(ctx, false, launchPlan, &resolved)
+}
+
+// useResolution sets the status of the plan to what was resolved for it, the node groups only keep what was resolved before anything was created
+func useResolution(launchPlan *plans.LaunchPlan, resolved plans.LaunchStatus) {
+	launchPlan.Status.EBSKMSKey = resolved.EBSKMSKey
+	launchPlan.Status.BlockDeviceMappings = resolved.BlockDeviceMappings
+	launchPlan.Status.KeyPair = resolved.KeyPair
+	launchPlan.Status.Reservations = resolved.Reservations
+	launchPlan.Status.NodeGroups = lo.Map(resolved.NodeGroups, func(groupStatus plans.NodeGroupStatus, _ int) plans.NodeGroupStatus {
+		return plans.NodeGroupStatus{
+			Name:             groupStatus.Name,
+			AMIs:             groupStatus.AMIs,
+			ArchitectureAMIs: groupStatus.ArchitectureAMIs,
+			InstanceTypes:    groupStatus.InstanceTypes,
+			Role:             groupStatus.Role,
+		}
+	})
+}
+
+// unflattenSingleNodeGroup moves the top level status of a plan without node groups back to its implicit node group, it reverses flattenSingleNodeGroup
+func unflattenSingleNodeGroup(launchPlan plans.LaunchPlan) plans.LaunchPlan {
+	if len(launchPlan.Spec.NodeGroups) == 0 && len(launchPlan.Status.NodeGroups) == 0 {
+		launchPlan.Status.NodeGroups = []plans.NodeGroupStatus{{
+			AMIs:                  launchPlan.Status.AMIs,
+			ArchitectureAMIs:      launchPlan.Status.ArchitectureAMIs,
+			InstanceTypes:         launchPlan.Status.InstanceTypes,
+			LaunchTemplate:        launchPlan.Status.LaunchTemplate,
+			LaunchTemplateVersion: launchPlan.Status.LaunchTemplateVersion,
+			InstanceProfile:       launchPlan.Status.InstanceProfile,
+			Role:                  launchPlan.Status.Role,
+		}}
+	}
+	return launchPlan
+}
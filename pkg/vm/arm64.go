@@ -0,0 +1,198 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/amazon-ec2-instance-selector/v3/pkg/selector"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/samber/lo"
+)
+
+// hoursPerMonth is the average number of hours in a month that AWS uses for monthly prices
+const hoursPerMonth = 730
+
+// ARM64Migration recommends the Graviton instance type and AMI to relaunch an x86_64 instance with
+type ARM64Migration struct {
+	Instance      instances.Instance
+	InstanceType  string
+	OnDemandPrice float64
+	// ARM64InstanceType is empty if no arm64 instance type has the same vCPUs and at least the same memory
+	ARM64InstanceType  string
+	ARM64VCPUs         int32
+	ARM64MemoryMiB     int64
+	ARM64OnDemandPrice float64
+	// MonthlySavings is the on-demand price difference over a month, it is negative if the arm64 instance type is more expensive
+	MonthlySavings float64
+	// AMIAlias is the nimbus AMI alias of the instance's AMI, empty if the AMI was not launched from an alias
+	AMIAlias string
+	// ARM64AMIID is the arm64 build of the instance's AMI, empty if there is none
+	ARM64AMIID string
+	// LaunchFlags are the launch flags that relaunch the instance on the arm64 instance type and AMI
+	LaunchFlags string
+}
+
+// PrettyARM64Migration represents an ARM64 migration for UI elements like the static and TUI tables
+type PrettyARM64Migration struct {
+	Name              string `table:"Name"`
+	InstanceID        string `table:"ID"`
+	InstanceType      string `table:"Instance-Type"`
+	ARM64InstanceType string `table:"ARM64-Instance-Type"`
+	Savings           string `table:"Savings"`
+	MonthlySavings    string `table:"Monthly-Savings"`
+	AMIAlias          string `table:"AMI-Alias"`
+	ARM64AMI          string `table:"ARM64-AMI"`
+	Ready             string `table:"Ready"`
+	LaunchFlags       string `table:"Launch-Flags,wide"`
+}
+
+// ARM64Migrations examines the running x86_64 instances of namespace/name and recommends an arm64 instance type and AMI for each of them.
+// On-demand prices come from the AWS Pricing API, so savings are unknown in regions or partitions without pricing data.
+func (v AWSVM) ARM64Migrations(ctx context.Context, namespace, name string) ([]ARM64Migration, error) {
+	instanceList, err := v.List(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	instanceList = lo.Filter(instanceList, func(instance instances.Instance, _ int) bool {
+		return instance.State != nil && instance.State.Name == ec2types.InstanceStateNameRunning &&
+			instance.Architecture == ec2types.ArchitectureValuesX8664
+	})
+	if len(instanceList) == 0 {
+		return nil, nil
+	}
+
+	logging.FromContext(ctx).Debug("Resolving instance AMIs")
+	imageIDs := lo.Uniq(lo.Map(instanceList, func(instance instances.Instance, _ int) string { return *instance.ImageId }))
+	amiList, err := v.amiWatcher.Resolve(ctx, lo.Map(imageIDs, func(id string, _ int) amis.Selector { return amis.Selector{ID: id} }))
+	if err != nil {
+		return nil, err
+	}
+	arm64AMIs := map[string]amis.AMI{}
+	for _, ami := range amiList {
+		arm64AMI, ok, err := v.amiWatcher.ResolveARM64Variant(ctx, ami)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			arm64AMIs[*ami.ImageId] = arm64AMI
+		}
+	}
+	amisByID := lo.KeyBy(amiList, func(ami amis.AMI) string { return *ami.ImageId })
+
+	recommendations := map[string]ARM64Migration{}
+	prices := map[string]float64{}
+	var migrations []ARM64Migration
+	for _, instance := range instanceList {
+		instanceType := string(instance.InstanceType)
+		recommendation, ok := recommendations[instanceType]
+		if !ok {
+			recommendation, err = v.arm64InstanceType(ctx, instanceType, prices)
+			if err != nil {
+				return nil, err
+			}
+			recommendations[instanceType] = recommendation
+		}
+		migration := recommendation
+		migration.Instance = instance
+		if ami, ok := amisByID[*instance.ImageId]; ok {
+			migration.AMIAlias, _ = ami.Alias()
+		}
+		if arm64AMI, ok := arm64AMIs[*instance.ImageId]; ok {
+			migration.ARM64AMIID = *arm64AMI.ImageId
+		}
+		migration.LaunchFlags = migration.launchFlags()
+		migrations = append(migrations, migration)
+	}
+	return migrations, nil
+}
+
+// arm64InstanceType recommends the arm64 equivalent of the x86_64 instance type, prices are cached in the prices map
+func (v AWSVM) arm64InstanceType(ctx context.Context, instanceType string, prices map[string]float64) (ARM64Migration, error) {
+	migration := ARM64Migration{InstanceType: instanceType}
+	logging.FromContext(ctx).Debug("Resolving arm64 equivalents", "instance-type", instanceType)
+	current, err := v.instanceTypeWatcher.Resolve(ctx, []instancetypes.Selector{{Filters: selector.Filters{InstanceTypes: &[]string{instanceType}}}})
+	if err != nil {
+		return migration, err
+	}
+	if len(current) == 0 {
+		return migration, fmt.Errorf("instance type %s not found", instanceType)
+	}
+	candidates, err := v.instanceTypeWatcher.Resolve(ctx, []instancetypes.Selector{current[0].EquivalentSelector(ec2types.ArchitectureTypeArm64)})
+	if err != nil {
+		return migration, err
+	}
+	for _, it := range append([]instancetypes.InstanceType{current[0]}, candidates...) {
+		if _, ok := prices[string(it.InstanceType)]; ok {
+			continue
+		}
+		price, err := v.instanceTypeWatcher.OnDemandPrice(ctx, string(it.InstanceType))
+		if err != nil {
+			logging.FromContext(ctx).Debug("Unable to get on-demand price", "instance-type", it.InstanceType, "error", err)
+			continue
+		}
+		prices[string(it.InstanceType)] = price
+	}
+	best, ok := current[0].BestEquivalent(candidates, prices)
+	if !ok {
+		return migration, nil
+	}
+	migration.ARM64InstanceType = string(best.InstanceType)
+	migration.ARM64VCPUs = lo.FromPtr(best.VCpuInfo.DefaultVCpus)
+	migration.ARM64MemoryMiB = lo.FromPtr(best.MemoryInfo.SizeInMiB)
+	migration.OnDemandPrice = prices[instanceType]
+	migration.ARM64OnDemandPrice = prices[migration.ARM64InstanceType]
+	if migration.OnDemandPrice != 0 && migration.ARM64OnDemandPrice != 0 {
+		migration.MonthlySavings = (migration.OnDemandPrice - migration.ARM64OnDemandPrice) * hoursPerMonth
+	}
+	return migration, nil
+}
+
+// Ready is true when both an arm64 instance type and AMI were found
+func (m ARM64Migration) Ready() bool {
+	return m.ARM64InstanceType != "" && m.ARM64AMIID != ""
+}
+
+// launchFlags select arm64 instance types of the recommended size and the AMI's alias, or its arm64 build if it has no alias
+func (m ARM64Migration) launchFlags() string {
+	if !m.Ready() {
+		return ""
+	}
+	flags := []string{fmt.Sprintf("--instance-types 'arch:arm64,vcpus:%d,memory:%dMiB'", m.ARM64VCPUs, m.ARM64MemoryMiB)}
+	if m.AMIAlias != "" {
+		flags = append(flags, fmt.Sprintf("--amis 'alias:%s'", m.AMIAlias))
+	} else {
+		flags = append(flags, fmt.Sprintf("--amis 'id:%s'", m.ARM64AMIID))
+	}
+	return strings.Join(flags, " ")
+}
+
+// Prettify converts the migration into a PrettyARM64Migration
+func (m ARM64Migration) Prettify() PrettyARM64Migration {
+	prettyMigration := PrettyARM64Migration{
+		Name:              m.Instance.Name(),
+		InstanceID:        lo.FromPtr(m.Instance.InstanceId),
+		InstanceType:      m.InstanceType,
+		ARM64InstanceType: lo.Ternary(m.ARM64InstanceType == "", "none", m.ARM64InstanceType),
+		Savings:           "unknown",
+		MonthlySavings:    "unknown",
+		AMIAlias:          m.AMIAlias,
+		ARM64AMI:          lo.Ternary(m.ARM64AMIID == "", "none", m.ARM64AMIID),
+		Ready:             lo.Ternary(m.Ready(), "Yes", "No"),
+		LaunchFlags:       m.LaunchFlags,
+	}
+	if m.OnDemandPrice != 0 && m.ARM64OnDemandPrice != 0 {
+		prettyMigration.Savings = fmt.Sprintf("%.0f%%", 100*(m.OnDemandPrice-m.ARM64OnDemandPrice)/m.OnDemandPrice)
+		prettyMigration.MonthlySavings = fmt.Sprintf("$%.2f", m.MonthlySavings)
+	}
+	return prettyMigration
+}
+
+// PrettifyARM64Migrations converts migrations into PrettyARM64Migrations
+func PrettifyARM64Migrations(migrations []ARM64Migration) []PrettyARM64Migration {
+	return lo.Map(migrations, func(m ARM64Migration, _ int) PrettyARM64Migration { return m.Prettify() })
+}
@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/artifacts"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/samber/lo"
+)
+
+const (
+	// artifactsMaxExpires is the longest that pre-signed URLs are valid, S3 does not accept longer expirations.
+	// URLs signed with temporary credentials stop working when the credentials expire.
+	artifactsMaxExpires = 7 * 24 * time.Hour
+)
+
+// stageArtifacts uploads the spec's artifacts so that the launch templates download them at boot.
+// Pre-signed URLs are valid for the TTL of the instances, or as long as S3 allows if they have none.
+func (v AWSVM) stageArtifacts(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	if len(launchPlan.Spec.Artifacts.Inputs) == 0 {
+		return nil
+	}
+	logging.FromContext(ctx).Debug("Staging artifacts", "inputs", launchPlan.Spec.Artifacts.Inputs)
+	staging, err := v.artifactWatcher.Stage(ctx, v.awsCfg.Region, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, artifacts.Options{
+		Inputs:  launchPlan.Spec.Artifacts.Inputs,
+		Mode:    launchPlan.Spec.Artifacts.Mode,
+		Bucket:  launchPlan.Spec.Artifacts.Bucket,
+		Expires: artifactsExpires(launchPlan.Spec.TTL),
+	})
+	if err != nil {
+		return err
+	}
+	launchPlan.Status.Artifacts = staging
+	return nil
+}
+
+// planArtifacts records the objects that a dry-run launch would have uploaded, nothing is uploaded to check permissions
+func (v AWSVM) planArtifacts(launchPlan *plans.LaunchPlan) error {
+	inputs, err := artifacts.Plan(artifacts.Prefix(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name), launchPlan.Spec.Artifacts.Inputs)
+	if err != nil {
+		return err
+	}
+	for _, input := range inputs {
+		planResource(launchPlan, "S3Object", input.Key, nil)
+	}
+	return nil
+}
+
+// artifactsExpires returns how long pre-signed URLs of instances with the TTL are valid
+func artifactsExpires(ttl time.Duration) time.Duration {
+	return lo.Ternary(ttl > 0 && ttl < artifactsMaxExpires, ttl, artifactsMaxExpires)
+}
@@ -0,0 +1,30 @@
+package vm
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/tags"
+	"github.com/bwagner5/nimbus/pkg/providers/trails"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// AuditTrail returns the CloudTrail write events of the last since duration that acted on the resources of namespace/name.
+// name is optional. Events are matched by the IDs of the currently tagged resources and by the nimbus tags in the request,
+// so resources that were created and deleted within the time range are included as well.
+func (v AWSVM) AuditTrail(ctx context.Context, namespace, name string, since time.Duration) ([]trails.Event, error) {
+	namespacedTags := tagutils.NamespacedTags(namespace, name)
+	logging.FromContext(ctx).Debug("Resolving tagged resources", "namespace", namespace, "name", name)
+	resources, err := v.tagWatcher.Resolve(ctx, []tags.Selector{{Tags: namespacedTags}})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("Looking up CloudTrail events", "since", since, "resources", len(resources))
+	return v.trailWatcher.Resolve(ctx, []trails.Selector{{
+		ResourceIDs: lo.Map(resources, func(resource tags.TaggedResource, _ int) string { return resource.ID }),
+		Tags:        namespacedTags,
+		StartTime:   time.Now().Add(-since),
+	}})
+}
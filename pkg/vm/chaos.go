@@ -0,0 +1,184 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/fis"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// InterruptMethodFIS sends a real spot interruption with a FIS experiment, instances get the interruption notice first
+	InterruptMethodFIS = "fis"
+	// InterruptMethodTerminate terminates the instances without a notice
+	InterruptMethodTerminate = "terminate"
+
+	// experimentTimeout is how long an interruption experiment may take after the interruption notice is over
+	experimentTimeout = 5 * time.Minute
+)
+
+// InterruptOptions configure a simulated spot interruption
+type InterruptOptions struct {
+	// Count is how many spot instances are interrupted, defaults to 1
+	Count int
+	// RoleARN is the IAM role that FIS assumes to interrupt the instances, the instances are terminated instead if it is empty
+	RoleARN string
+	// Notice is how long FIS notifies the instances before interrupting them, defaults to 2 minutes
+	Notice time.Duration
+}
+
+// Interruption is the result of a simulated spot interruption
+type Interruption struct {
+	// Method is fis or terminate
+	Method    string
+	Instances []instances.Instance
+	// Experiment is the FIS experiment that interrupted the instances
+	Experiment *fis.Experiment `json:",omitempty" yaml:",omitempty"`
+}
+
+// Interrupt interrupts random running spot instances of namespace/name to test how the workload handles spot interruptions.
+// With a FIS role, a FIS experiment sends the instances an interruption notice and interrupts them once the notice is over, the same way
+// EC2 reclaims spot capacity, and Interrupt returns once the experiment is done. Otherwise the instances are terminated without a notice.
+func (v AWSVM) Interrupt(ctx context.Context, namespace, name string, opts InterruptOptions) (Interruption, error) {
+	instanceList, err := v.targetInstances(ctx, namespace, name, nil, "interrupt", ec2types.InstanceStateNameRunning)
+	if err != nil {
+		return Interruption{}, err
+	}
+	spotInstances := lo.Filter(instanceList, func(instance instances.Instance, _ int) bool {
+		return instance.InstanceLifecycle == ec2types.InstanceLifecycleTypeSpot
+	})
+	count := max(1, opts.Count)
+	if len(spotInstances) < count {
+		return Interruption{}, fmt.Errorf("%d spot instances to interrupt are running, %d were requested", len(spotInstances), count)
+	}
+	interruption := Interruption{Method: InterruptMethodTerminate, Instances: lo.Samples(spotInstances, count)}
+	instanceIDs := idsOf(interruption.Instances)
+
+	if opts.RoleARN == "" {
+		logging.FromContext(ctx).Info("Terminating spot instances", "instance-ids", instanceIDs)
+		return interruption, v.instanceWatcher.TerminateInstances(ctx, instanceIDs)
+	}
+
+	interruption.Method = InterruptMethodFIS
+	notice := lo.CoalesceOrEmpty(opts.Notice, fis.MinInterruptionNotice)
+	experiment, err := v.fisWatcher.InterruptSpotInstances(ctx, fis.InterruptOptions{
+		Region:      v.awsCfg.Region,
+		InstanceIDs: instanceIDs,
+		RoleARN:     opts.RoleARN,
+		Notice:      notice,
+		Tags:        tagutils.NamespacedTags(namespace, name),
+	})
+	if err != nil {
+		return interruption, err
+	}
+	logging.FromContext(ctx).Info("Started spot interruption experiment, waiting for the interruption", "experiment-id", experiment.ID, "instance-ids", instanceIDs, "notice", notice)
+	experiment, err = v.fisWatcher.WaitForExperiment(ctx, experiment.ID, notice+experimentTimeout)
+	interruption.Experiment = &experiment
+	// the template is deleted even if ctx is done so that it does not outlive the experiment
+	if deleteErr := v.fisWatcher.DeleteExperimentTemplate(context.WithoutCancel(ctx), experiment.ExperimentTemplateID); deleteErr != nil {
+		err = errors.Join(err, deleteErr)
+	}
+	if err != nil {
+		return interruption, err
+	}
+
+	// refresh the instances to return their interrupted state
+	refreshedInstances, err := v.instanceWatcher.Resolve(ctx, lo.Map(instanceIDs, func(id string, _ int) instances.Selector { return instances.Selector{ID: id} }))
+	if err != nil {
+		return interruption, err
+	}
+	interruption.Instances = refreshedInstances
+	return interruption, nil
+}
+
+// ExperimentOptions configure an experiment template that injects a fault into the running instances of a VM
+type ExperimentOptions struct {
+	// Fault is cpu-stress, network-latency, or instance-stop
+	Fault string
+	// RoleARN is the IAM role that FIS assumes to run the experiments
+	RoleARN string
+	// Count is how many of the running instances an experiment picks at random, 0 targets all of them
+	Count       int
+	Duration    time.Duration
+	LoadPercent int
+	Latency     time.Duration
+}
+
+// CreateExperiment creates a FIS experiment template that injects the fault into the running instances of namespace/name.
+// The template is tagged like the VM's other resources so that it is deleted with the VM.
+func (v AWSVM) CreateExperiment(ctx context.Context, namespace, name string, opts ExperimentOptions) (fis.ExperimentTemplate, error) {
+	template, err := v.fisWatcher.CreateExperimentTemplate(ctx, fis.ExperimentTemplateOptions{
+		Fault:       opts.Fault,
+		Region:      v.awsCfg.Region,
+		RoleARN:     opts.RoleARN,
+		TargetTags:  map[string]string{tagutils.NamespaceTagKey: namespace, tagutils.NameTagKey: name},
+		Count:       opts.Count,
+		Duration:    opts.Duration,
+		LoadPercent: opts.LoadPercent,
+		Latency:     opts.Latency,
+		Tags:        tagutils.NamespacedTags(namespace, name),
+	})
+	if err != nil {
+		return template, err
+	}
+	logging.FromContext(ctx).Info("Created experiment template", "experiment-template-id", template.ID, "fault", opts.Fault)
+	return template, nil
+}
+
+// ListExperiments returns the FIS experiment templates of namespace/name, or of the whole namespace if name is empty
+func (v AWSVM) ListExperiments(ctx context.Context, namespace, name string) ([]fis.ExperimentTemplate, error) {
+	return v.fisWatcher.ResolveExperimentTemplates(ctx, tagutils.NamespacedTags(namespace, name))
+}
+
+// StartExperiment starts an experiment from an experiment template of the namespace
+func (v AWSVM) StartExperiment(ctx context.Context, namespace, templateID string) (fis.Experiment, error) {
+	templates, err := v.ListExperiments(ctx, namespace, "")
+	if err != nil {
+		return fis.Experiment{}, err
+	}
+	template, ok := lo.Find(templates, func(template fis.ExperimentTemplate) bool { return template.ID == templateID })
+	if !ok {
+		return fis.Experiment{}, fmt.Errorf("experiment template %s not found in namespace %s", templateID, namespace)
+	}
+	experiment, err := v.fisWatcher.StartExperiment(ctx, template)
+	if err != nil {
+		return experiment, err
+	}
+	logging.FromContext(ctx).Info("Started experiment", "experiment-id", experiment.ID, "experiment-template-id", templateID)
+	return experiment, nil
+}
+
+// StopExperiment stops an experiment of the namespace
+func (v AWSVM) StopExperiment(ctx context.Context, namespace, experimentID string) (fis.Experiment, error) {
+	experiment, err := v.fisWatcher.GetExperiment(ctx, experimentID)
+	if err != nil {
+		return experiment, err
+	}
+	if experiment.Tags[tagutils.NamespaceTagKey] != namespace {
+		return fis.Experiment{}, fmt.Errorf("experiment %s not found in namespace %s", experimentID, namespace)
+	}
+	return v.fisWatcher.StopExperiment(ctx, experimentID)
+}
+
+// deleteExperimentTemplate stops the running experiments of the experiment template and deletes it, so that no fault is injected into the
+// instances while they are terminated
+func (v AWSVM) deleteExperimentTemplate(ctx context.Context, templateID string) error {
+	experiments, err := v.fisWatcher.ResolveExperiments(ctx, templateID)
+	if err != nil {
+		return err
+	}
+	for _, experiment := range lo.Reject(experiments, func(experiment fis.Experiment, _ int) bool { return experiment.IsDone() }) {
+		logging.FromContext(ctx).Debug("Stopping experiment", "experiment-id", experiment.ID)
+		if _, err := v.fisWatcher.StopExperiment(ctx, experiment.ID); err != nil {
+			return err
+		}
+	}
+	return v.fisWatcher.DeleteExperimentTemplate(ctx, templateID)
+}
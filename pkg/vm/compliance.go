@@ -0,0 +1,38 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+)
+
+// checkCompliance evaluates the plan's compliance policy before any resources are created and records the violations in the plan status.
+// The subnets to launch into are resolved ahead of the network step, which only creates resources when no subnets are selected.
+func (v AWSVM) checkCompliance(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	policy := launchPlan.Spec.CompliancePolicy
+	if policy.IsEmpty() {
+		return nil
+	}
+	logging.FromContext(ctx).Debug("Evaluating compliance policy")
+	var launchSubnets []subnets.Subnet
+	if len(launchPlan.Spec.SubnetSelectors) != 0 {
+		subnetList, err := v.subnetWatcher.Resolve(ctx, launchPlan.Spec.SubnetSelectors)
+		if err != nil {
+			return err
+		}
+		launchSubnets = subnetList
+	} else if launchPlan.Spec.UseDefaultVPC {
+		_, subnetList, err := v.resolveDefaultNetwork(ctx)
+		if err != nil {
+			return err
+		}
+		launchSubnets = subnetList
+	}
+	launchPlan.Status.Violations = policy.Evaluate(*launchPlan, v.awsCfg.Region, launchSubnets)
+	if len(launchPlan.Status.Violations) != 0 {
+		return plans.ComplianceError{Violations: launchPlan.Status.Violations}
+	}
+	return nil
+}
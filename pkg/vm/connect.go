@@ -0,0 +1,128 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
+	"github.com/samber/lo"
+)
+
+// commandLogsPollInterval is how often the command history is read when following command invocations
+const commandLogsPollInterval = 5 * time.Second
+
+// Connect opens an interactive shell through SSM Session Manager on the running instance of namespace/name that matches the selectors.
+// Exactly one instance must match, names with several instances need a selector like id:i-0123456 to choose one.
+// Profile is the AWS profile the Session Manager plugin uses, it may be empty.
+func (v AWSVM) Connect(ctx context.Context, namespace, name string, selectorList []instances.Selector, profile string) error {
+	instance, err := v.targetInstance(ctx, namespace, name, selectorList, "connect to")
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Debug("Starting session", "instance-id", *instance.InstanceId)
+	return v.sessionWatcher.Attach(ctx, *instance.InstanceId, profile)
+}
+
+// targetInstance resolves the single running instance of namespace/name that matches the selectors, sessions are opened on one instance
+func (v AWSVM) targetInstance(ctx context.Context, namespace, name string, selectorList []instances.Selector, action string) (instances.Instance, error) {
+	instanceList, err := v.targetInstances(ctx, namespace, name, selectorList, action, ec2types.InstanceStateNameRunning)
+	if err != nil {
+		return instances.Instance{}, err
+	}
+	if len(instanceList) > 1 {
+		return instances.Instance{}, fmt.Errorf("%d instances match, choose one with an instance selector: %s", len(instanceList), strings.Join(idsOf(instanceList), ", "))
+	}
+	return instanceList[0], nil
+}
+
+// Exec runs the command's script through SSM Run Command on every running instance of namespace/name that matches the selectors and waits for it to finish.
+// The script runs with sh on Linux instances and PowerShell on Windows instances, the command's instances, document, and comment are set by Exec.
+// The commands are recorded in SSM's command history with the namespace and name, so CommandLogs can read their output later.
+func (v AWSVM) Exec(ctx context.Context, namespace, name string, selectorList []instances.Selector, command sessions.Command) ([]sessions.CommandResult, error) {
+	instanceList, err := v.targetInstances(ctx, namespace, name, selectorList, "exec on", ec2types.InstanceStateNameRunning)
+	if err != nil {
+		return nil, err
+	}
+	var results []sessions.CommandResult
+	// a command runs a single document, so Linux and Windows instances are sent separate commands
+	for windows, platformInstances := range lo.GroupBy(instanceList, func(instance instances.Instance) bool {
+		return instance.Platform == ec2types.PlatformValuesWindows
+	}) {
+		if command.FailFast && lo.SomeBy(results, func(result sessions.CommandResult) bool { return !result.Succeeded() }) {
+			results = append(results, lo.Map(platformInstances, func(instance instances.Instance, _ int) sessions.CommandResult {
+				return sessions.CommandResult{InstanceID: *instance.InstanceId, Status: ssmtypes.CommandInvocationStatusCancelled, ExitCode: -1}
+			})...)
+			continue
+		}
+		logging.FromContext(ctx).Debug("Running command", "instance-ids", idsOf(platformInstances), "max-parallel", command.MaxParallel)
+		command.InstanceIDs = idsOf(platformInstances)
+		command.Document = lo.Ternary(windows, sessions.DocumentPowerShellScript, sessions.DocumentShellScript)
+		command.Comment = sessions.CommandComment(namespace, name)
+		platformResults, err := v.sessionWatcher.RunCommand(ctx, command)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, platformResults...)
+	}
+	return results, nil
+}
+
+// idsOf returns the IDs of the instances
+func idsOf(instanceList []instances.Instance) []string {
+	return lo.Map(instanceList, func(instance instances.Instance, _ int) string { return *instance.InstanceId })
+}
+
+// CommandLogs reads the output of the commands that Exec ran on the instances of namespace/name since the duration ago, from SSM's command history.
+// Without follow the completed invocations are emitted and the channel is closed, otherwise invocations are emitted as they complete until ctx is done.
+func (v AWSVM) CommandLogs(ctx context.Context, namespace, name string, since time.Duration, follow bool) (<-chan sessions.Invocation, error) {
+	start := time.Now().Add(-since)
+	comment := sessions.CommandComment(namespace, name)
+	// the first read is not retried, so errors like missing permissions are returned
+	invocations, err := v.sessionWatcher.Invocations(ctx, comment, start)
+	if err != nil {
+		return nil, err
+	}
+	invocationsChan := make(chan sessions.Invocation)
+	go func() {
+		defer close(invocationsChan)
+		emitted := map[string]bool{}
+		for {
+			for _, invocation := range invocations {
+				key := invocation.CommandID + "/" + invocation.InstanceID
+				if emitted[key] || !sessions.Completed(invocation.Status) {
+					continue
+				}
+				emitted[key] = true
+				select {
+				case <-ctx.Done():
+					return
+				case invocationsChan <- invocation:
+				}
+			}
+			if !follow {
+				if running := lo.CountBy(invocations, func(invocation sessions.Invocation) bool { return !sessions.Completed(invocation.Status) }); running != 0 {
+					logging.FromContext(ctx).Info("Commands are still running, follow them with --follow", "invocations", running)
+				}
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(commandLogsPollInterval):
+			}
+			if invocations, err = v.sessionWatcher.Invocations(ctx, comment, start); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logging.FromContext(ctx).Error("Unable to read command invocations", "error", err)
+			}
+		}
+	}()
+	return invocationsChan, nil
+}
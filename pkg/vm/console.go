@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+)
+
+// consolePollInterval is how often the console output is read when following, EC2 captures it every few seconds at most
+const consolePollInterval = 10 * time.Second
+
+// ConsoleOutput reads the console output of the instances of namespace/name that match the selectors, which shows why an instance
+// failed to boot or run its user data even when it is unreachable. Without follow the latest output of each instance is emitted and the
+// channel is closed, otherwise the output that is new since the last read is emitted until ctx is done.
+func (v AWSVM) ConsoleOutput(ctx context.Context, namespace, name string, selectorList []instances.Selector, follow bool) (<-chan instances.ConsoleOutput, error) {
+	instanceList, err := v.targetInstances(ctx, namespace, name, selectorList, "read the console output of",
+		ec2types.InstanceStateNamePending, ec2types.InstanceStateNameRunning, ec2types.InstanceStateNameShuttingDown,
+		ec2types.InstanceStateNameStopping, ec2types.InstanceStateNameStopped)
+	if err != nil {
+		return nil, err
+	}
+
+	outputChan := make(chan instances.ConsoleOutput)
+	go func() {
+		defer close(outputChan)
+		// previous is the last output read from each instance
+		previous := map[string]string{}
+		for {
+			for _, instanceID := range idsOf(instanceList) {
+				output, err := v.instanceWatcher.ConsoleOutput(ctx, instanceID)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					logging.FromContext(ctx).Error("Unable to read console output", "instance-id", instanceID, "error", err)
+					continue
+				}
+				current := output.Output
+				output.Output = instances.NewConsoleOutput(previous[instanceID], current)
+				previous[instanceID] = current
+				if output.Output == "" {
+					continue
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case outputChan <- output:
+				}
+			}
+			if !follow {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(consolePollInterval):
+			}
+		}
+	}()
+	return outputChan, nil
+}
+
+// Screenshot returns the ID of the running instance of namespace/name that matches the selectors and a JPG screenshot of its console
+func (v AWSVM) Screenshot(ctx context.Context, namespace, name string, selectorList []instances.Selector) (string, []byte, error) {
+	instance, err := v.targetInstance(ctx, namespace, name, selectorList, "screenshot")
+	if err != nil {
+		return "", nil, err
+	}
+	screenshot, err := v.instanceWatcher.Screenshot(ctx, *instance.InstanceId)
+	if err != nil {
+		return "", nil, err
+	}
+	return *instance.InstanceId, screenshot, nil
+}
+
+// SerialConsole connects the terminal to the serial console of the running instance of namespace/name that matches the selectors.
+// Serial console access is an account setting of the region, it is enabled if enableAccess is true and it is disabled, otherwise an error is returned.
+func (v AWSVM) SerialConsole(ctx context.Context, namespace, name string, selectorList []instances.Selector, enableAccess bool) error {
+	instance, err := v.targetInstance(ctx, namespace, name, selectorList, "connect to the serial console of")
+	if err != nil {
+		return err
+	}
+	enabled, err := v.instanceWatcher.SerialConsoleAccess(ctx)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		if !enableAccess {
+			return fmt.Errorf("serial console access is disabled in %s, enable it for the account with --enable-access", v.awsCfg.Region)
+		}
+		logging.FromContext(ctx).Info("Enabling serial console access for the account", "region", v.awsCfg.Region)
+		if err := v.instanceWatcher.EnableSerialConsoleAccess(ctx); err != nil {
+			return err
+		}
+	}
+	logging.FromContext(ctx).Debug("Starting serial console session", "instance-id", *instance.InstanceId)
+	// EC2 only supports the first serial port
+	return v.serialConsoleWatcher.Attach(ctx, *instance.InstanceId, 0)
+}
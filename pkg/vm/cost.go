@@ -0,0 +1,185 @@
+package vm
+
+import (
+	"context"
+	"math"
+	"slices"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/samber/lo"
+)
+
+// estimateCost estimates what the plan's node groups cost to run from the resolved instance types and AMIs of its status.
+// Spot groups are priced at the lowest spot price of any availability zone, and every other capacity type at the on-demand price.
+func (v AWSVM) estimateCost(ctx context.Context, launchPlan plans.LaunchPlan, nodeGroups []plans.NodeGroup) (plans.EstimatedCost, error) {
+	var spotTypes, onDemandTypes, volumeTypes []string
+	for i, group := range nodeGroups {
+		names := lo.Map(launchPlan.Status.NodeGroups[i].InstanceTypes, func(instanceType instancetypes.InstanceType, _ int) string {
+			return string(instanceType.InstanceType)
+		})
+		if isSpot(group.CapacityType) {
+			spotTypes = append(spotTypes, names...)
+		} else {
+			onDemandTypes = append(onDemandTypes, names...)
+		}
+		volumeTypes = append(volumeTypes, lo.Keys(instanceVolumes(launchPlan.Spec, launchPlan.Status.NodeGroups[i].AMIs))...)
+	}
+
+	logging.FromContext(ctx).Debug("Resolving prices to estimate the cost", "instance-types", len(spotTypes)+len(onDemandTypes), "volume-types", len(volumeTypes))
+	instancePrices, err := v.pricingWatcher.OnDemand(ctx, onDemandTypes)
+	if err != nil {
+		return plans.EstimatedCost{}, err
+	}
+	spotPrices, err := v.pricingWatcher.Spot(ctx, spotTypes)
+	if err != nil {
+		return plans.EstimatedCost{}, err
+	}
+	// the estimate assumes the cheapest availability zone of each spot instance type
+	spotPricesByType := lo.MapValues(spotPrices, func(zonePrices map[string]float64, _ string) float64 { return lo.Min(lo.Values(zonePrices)) })
+	volumePrices, err := v.pricingWatcher.EBS(ctx, volumeTypes)
+	if err != nil {
+		return plans.EstimatedCost{}, err
+	}
+
+	var estimate plans.EstimatedCost
+	reconciliation := launchPlan.Status.Reconciliation
+	estimate.Existing, err = v.instancesCost(ctx, append(slices.Clone(reconciliation.UpToDate), reconciliation.OutOfDate...))
+	if err != nil {
+		return plans.EstimatedCost{}, err
+	}
+	for i, group := range nodeGroups {
+		groupStatus := launchPlan.Status.NodeGroups[i]
+		prices := lo.Ternary(isSpot(group.CapacityType), spotPricesByType, instancePrices)
+		groupCost := plans.GroupCost{
+			Name:         group.Name,
+			CapacityType: group.CapacityType,
+			Instances:    group.Count,
+		}
+		// placements launch one instance each instead of the count
+		if len(launchPlan.Spec.Placements) != 0 {
+			groupCost.Instances = int32(len(launchPlan.Spec.Placements))
+		}
+		if instanceType, instances, ok := cheapestInstanceType(group, groupStatus.InstanceTypes, prices); ok {
+			groupCost.InstanceType = string(instanceType.InstanceType)
+			groupCost.InstancePrice = prices[groupCost.InstanceType]
+			if group.Capacity.Value != 0 {
+				groupCost.Instances = instances
+			}
+		}
+		volumesPriced := true
+		for volumeType, size := range instanceVolumes(launchPlan.Spec, groupStatus.AMIs) {
+			groupCost.VolumeGiB += size
+			price, ok := volumePrices[volumeType]
+			volumesPriced = volumesPriced && ok
+			// EBS storage is priced per GiB-month
+			groupCost.VolumePrice += float64(size) * price / hoursPerMonth
+		}
+		groupCost.Priced = groupCost.InstanceType != "" && volumesPriced
+		groupCost.Hourly = float64(groupCost.Instances) * (groupCost.InstancePrice + groupCost.VolumePrice)
+		groupCost.Monthly = groupCost.Hourly * hoursPerMonth
+		estimate.Add(groupCost)
+	}
+	return estimate, nil
+}
+
+// instancesCost prices running and pending instances by their instance type, spot instances at the spot price of their availability zone.
+// Stopped instances are not charged for, so they are left out.
+func (v AWSVM) instancesCost(ctx context.Context, instanceList []instances.Instance) (plans.InstancesCost, error) {
+	live := lo.Filter(instanceList, func(instance instances.Instance, _ int) bool {
+		return instance.State != nil && (instance.State.Name == ec2types.InstanceStateNameRunning || instance.State.Name == ec2types.InstanceStateNamePending)
+	})
+	var cost plans.InstancesCost
+	if len(live) == 0 {
+		return cost, nil
+	}
+	spot, onDemand := lo.FilterReject(live, func(instance instances.Instance, _ int) bool {
+		return instance.InstanceLifecycle == ec2types.InstanceLifecycleTypeSpot
+	})
+	instanceType := func(instance instances.Instance, _ int) string { return string(instance.InstanceType) }
+	onDemandPrices, err := v.pricingWatcher.OnDemand(ctx, lo.Map(onDemand, instanceType))
+	if err != nil {
+		return cost, err
+	}
+	spotPrices, err := v.pricingWatcher.Spot(ctx, lo.Map(spot, instanceType))
+	if err != nil {
+		return cost, err
+	}
+	for _, instance := range onDemand {
+		price, ok := onDemandPrices[string(instance.InstanceType)]
+		cost.Hourly += price
+		cost.Incomplete = cost.Incomplete || !ok
+	}
+	for _, instance := range spot {
+		price, ok := spotPrices[string(instance.InstanceType)][lo.FromPtr(instance.Placement.AvailabilityZone)]
+		cost.Hourly += price
+		cost.Incomplete = cost.Incomplete || !ok
+	}
+	return cost, nil
+}
+
+// cheapestInstanceType returns the instance type with the lowest price per unit of the group's capacity and the number of its instances
+// that reach the capacity, false if none of the instance types has a price
+func cheapestInstanceType(group plans.NodeGroup, instanceTypes []instancetypes.InstanceType, prices map[string]float64) (instancetypes.InstanceType, int32, bool) {
+	var cheapest instancetypes.InstanceType
+	var cheapestWeight, cheapestUnitPrice float64
+	for _, instanceType := range instanceTypes {
+		price, ok := prices[string(instanceType.InstanceType)]
+		if !ok || price == 0 {
+			continue
+		}
+		weight := lo.FromPtrOr(fleets.Weight(group.Capacity.Unit, instanceType), 1)
+		if weight <= 0 {
+			continue
+		}
+		if cheapestWeight == 0 || price/weight < cheapestUnitPrice {
+			cheapest, cheapestWeight, cheapestUnitPrice = instanceType, weight, price/weight
+		}
+	}
+	if cheapestWeight == 0 {
+		return cheapest, 0, false
+	}
+	return cheapest, int32(math.Ceil(float64(group.Capacity.Value) / cheapestWeight)), true
+}
+
+// instanceVolumes returns the GiB of EBS volumes that each instance of the plan has by volume type.
+// The root volume defaults to the largest root snapshot of the AMIs.
+func instanceVolumes(spec plans.LaunchSpec, amiList []amis.AMI) map[string]int32 {
+	rootVolume := spec.RootVolume
+	if mapping, ok := rootMapping(spec.BlockDeviceMappings); ok {
+		rootVolume = mapping
+	}
+	if rootVolume.VolumeSize == 0 {
+		rootVolume.VolumeSize = lo.Max(lo.Map(amiList, func(ami amis.AMI, _ int) int32 {
+			mapping, _ := lo.Find(ami.BlockDeviceMappings, func(mapping ec2types.BlockDeviceMapping) bool {
+				return lo.FromPtr(mapping.DeviceName) == lo.FromPtr(ami.RootDeviceName) && mapping.Ebs != nil
+			})
+			return lo.FromPtr(lo.FromPtr(mapping.Ebs).VolumeSize)
+		}))
+	}
+	sizes := map[string]int32{}
+	add := func(volume launchtemplates.BlockDevice) {
+		if volume.VolumeSize != 0 {
+			sizes[lo.CoalesceOrEmpty(volume.VolumeType, string(launchtemplates.DefaultVolumeType))] += volume.VolumeSize
+		}
+	}
+	add(rootVolume)
+	for _, volume := range append(slices.Clone(spec.BlockDeviceMappings), spec.Volumes...) {
+		if volume.DeviceName != launchtemplates.RootDevice {
+			add(volume)
+		}
+	}
+	return sizes
+}
+
+// isSpot is true if the capacity type is spot, an empty capacity type launches on-demand instances
+func isSpot(capacityType string) bool {
+	return ec2utils.NormalizeCapacityType(capacityType) == string(ec2types.DefaultTargetCapacityTypeSpot)
+}
@@ -0,0 +1,123 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/progress"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/samber/lo"
+)
+
+// deletionConcurrency is the max number of resources of a kind that are deleted at once, which keeps deletions of large plans below the EC2 API rate limits
+const deletionConcurrency = 8
+
+// deleteParallel runs the deletion steps of a tier of the deletion graph concurrently and waits for all of them.
+// Steps of a tier only depend on earlier tiers and each records its deletions in its own status map, so they do not have to be synchronized.
+// Every step runs to completion even if others fail so that a retry of the plan only has the failed deletions left, their errors are joined.
+func deleteParallel(steps ...func() error) error {
+	errs := make([]error, len(steps))
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = step()
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// deleteEach deletes the resources that are not marked as deleted in the status map, at most deletionConcurrency at once, and marks the deleted ones.
+// All deletions are attempted even if some fail, their errors are joined.
+func deleteEach[T any](ctx context.Context, kind, idKey string, resources []T, id func(T) string, deleted *map[string]bool, deleteResource func(T) error) error {
+	var pending []T
+	for _, resource := range resources {
+		if (*deleted)[id(resource)] {
+			logging.FromContext(ctx).Debug(fmt.Sprintf("Already deleted %s, skipping", kind), idKey, id(resource))
+			continue
+		}
+		pending = append(pending, resource)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	if *deleted == nil {
+		*deleted = map[string]bool{}
+	}
+	var mu sync.Mutex
+	var errs []error
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, deletionConcurrency)
+	for _, resource := range pending {
+		select {
+		case <-ctx.Done():
+			wg.Wait()
+			return errors.Join(append(errs, ctx.Err())...)
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			err := deleteResource(resource)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			logging.FromContext(ctx).Debug(fmt.Sprintf("Deleted %s", kind), idKey, id(resource))
+			(*deleted)[id(resource)] = true
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// pendingIDs returns the IDs that are not marked as deleted in the status map
+func pendingIDs(ids []string, deleted map[string]bool) []string {
+	return lo.Reject(ids, func(id string, _ int) bool { return deleted[id] })
+}
+
+// StopForDeletion stops the running instances of the deletion plan and waits for them to be stopped, so that their volumes can be
+// snapshotted while nothing writes to them before Delete terminates the instances.
+// The plan's instances are resolved again so that their states and attached volumes are current.
+func (v AWSVM) StopForDeletion(ctx context.Context, deletionPlan plans.DeletionPlan) (plans.DeletionPlan, error) {
+	instanceIDs := idsOf(deletionPlan.Spec.Instances)
+	if len(instanceIDs) == 0 {
+		return deletionPlan, nil
+	}
+	running := idsOf(lo.Filter(deletionPlan.Spec.Instances, func(instance instances.Instance, _ int) bool {
+		return instance.State != nil && (instance.State.Name == ec2types.InstanceStateNamePending || instance.State.Name == ec2types.InstanceStateNameRunning)
+	}))
+	for _, instanceID := range running {
+		logging.FromContext(ctx).Debug("Stopping EC2 instance before terminating it", "instance-id", instanceID)
+		if err := v.instanceWatcher.StopInstance(ctx, instanceID, false); err != nil {
+			return deletionPlan, err
+		}
+	}
+	done := progress.FromContext(ctx).Track(fmt.Sprintf("Stopping %d instances", len(running)), expectedTerminationDuration)
+	err := v.instanceWatcher.WaitForStopped(ctx, running, instanceStopTimeout)
+	done()
+	if err != nil {
+		return deletionPlan, err
+	}
+	instanceList, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{Filters: []ec2types.Filter{
+		{Name: aws.String("instance-id"), Values: instanceIDs},
+	}}})
+	if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.Instances = instanceList
+	return deletionPlan, nil
+}
@@ -0,0 +1,180 @@
+package vm
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	awsfis "github.com/aws/aws-sdk-go-v2/service/fis"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/bwagner5/nimbus/pkg/providers/eips"
+	"github.com/bwagner5/nimbus/pkg/providers/fis"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
+	"github.com/bwagner5/nimbus/pkg/providers/routetables"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
+	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// fakeEC2 serves the described resources that match the request filters, every other EC2 call panics
+type fakeEC2 struct {
+	*ec2.Client
+	instances      []ec2types.Instance
+	securityGroups []ec2types.SecurityGroup
+	subnets        []ec2types.Subnet
+	vpcs           []ec2types.Vpc
+}
+
+// matches returns true if the resource's tags and attributes satisfy every filter, attributes that a resource does not have never match
+func matches(filters []ec2types.Filter, tags []ec2types.Tag, attributes map[string][]string) bool {
+	return lo.EveryBy(filters, func(filter ec2types.Filter) bool {
+		name := lo.FromPtr(filter.Name)
+		if key, ok := strings.CutPrefix(name, "tag:"); ok {
+			return lo.ContainsBy(tags, func(tag ec2types.Tag) bool { return *tag.Key == key && lo.Contains(filter.Values, *tag.Value) })
+		}
+		return len(lo.Intersect(attributes[name], filter.Values)) != 0
+	})
+}
+
+func (f *fakeEC2) DescribeInstances(_ context.Context, input *ec2.DescribeInstancesInput, _ ...func(*ec2.Options)) (*ec2.DescribeInstancesOutput, error) {
+	return &ec2.DescribeInstancesOutput{Reservations: []ec2types.Reservation{{Instances: lo.Filter(f.instances, func(instance ec2types.Instance, _ int) bool {
+		return matches(input.Filters, instance.Tags, map[string][]string{
+			"instance-state-name": {string(instance.State.Name)},
+			"vpc-id":              {*instance.VpcId},
+			"subnet-id":           {*instance.SubnetId},
+			"instance.group-id":   lo.Map(instance.SecurityGroups, func(group ec2types.GroupIdentifier, _ int) string { return *group.GroupId }),
+		})
+	})}}}, nil
+}
+
+func (f *fakeEC2) DescribeSecurityGroups(_ context.Context, input *ec2.DescribeSecurityGroupsInput, _ ...func(*ec2.Options)) (*ec2.DescribeSecurityGroupsOutput, error) {
+	return &ec2.DescribeSecurityGroupsOutput{SecurityGroups: lo.Filter(f.securityGroups, func(group ec2types.SecurityGroup, _ int) bool {
+		return matches(input.Filters, group.Tags, map[string][]string{"vpc-id": {*group.VpcId}, "group-id": {*group.GroupId}})
+	})}, nil
+}
+
+func (f *fakeEC2) DescribeSubnets(_ context.Context, input *ec2.DescribeSubnetsInput, _ ...func(*ec2.Options)) (*ec2.DescribeSubnetsOutput, error) {
+	return &ec2.DescribeSubnetsOutput{Subnets: lo.Filter(f.subnets, func(subnet ec2types.Subnet, _ int) bool {
+		return matches(input.Filters, subnet.Tags, map[string][]string{"vpc-id": {*subnet.VpcId}, "subnet-id": {*subnet.SubnetId}})
+	})}, nil
+}
+
+func (f *fakeEC2) DescribeVpcs(_ context.Context, input *ec2.DescribeVpcsInput, _ ...func(*ec2.Options)) (*ec2.DescribeVpcsOutput, error) {
+	return &ec2.DescribeVpcsOutput{Vpcs: lo.Filter(f.vpcs, func(vpc ec2types.Vpc, _ int) bool {
+		return matches(input.Filters, vpc.Tags, map[string][]string{"vpc-id": {*vpc.VpcId}})
+	})}, nil
+}
+
+func (f *fakeEC2) DescribeFleets(context.Context, *ec2.DescribeFleetsInput, ...func(*ec2.Options)) (*ec2.DescribeFleetsOutput, error) {
+	return &ec2.DescribeFleetsOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeVolumes(context.Context, *ec2.DescribeVolumesInput, ...func(*ec2.Options)) (*ec2.DescribeVolumesOutput, error) {
+	return &ec2.DescribeVolumesOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeLaunchTemplates(context.Context, *ec2.DescribeLaunchTemplatesInput, ...func(*ec2.Options)) (*ec2.DescribeLaunchTemplatesOutput, error) {
+	return &ec2.DescribeLaunchTemplatesOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeNatGateways(context.Context, *ec2.DescribeNatGatewaysInput, ...func(*ec2.Options)) (*ec2.DescribeNatGatewaysOutput, error) {
+	return &ec2.DescribeNatGatewaysOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeAddresses(context.Context, *ec2.DescribeAddressesInput, ...func(*ec2.Options)) (*ec2.DescribeAddressesOutput, error) {
+	return &ec2.DescribeAddressesOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeInternetGateways(context.Context, *ec2.DescribeInternetGatewaysInput, ...func(*ec2.Options)) (*ec2.DescribeInternetGatewaysOutput, error) {
+	return &ec2.DescribeInternetGatewaysOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeEgressOnlyInternetGateways(context.Context, *ec2.DescribeEgressOnlyInternetGatewaysInput, ...func(*ec2.Options)) (*ec2.DescribeEgressOnlyInternetGatewaysOutput, error) {
+	return &ec2.DescribeEgressOnlyInternetGatewaysOutput{}, nil
+}
+
+func (f *fakeEC2) DescribeRouteTables(context.Context, *ec2.DescribeRouteTablesInput, ...func(*ec2.Options)) (*ec2.DescribeRouteTablesOutput, error) {
+	return &ec2.DescribeRouteTablesOutput{}, nil
+}
+
+type fakeIAM struct {
+	instanceprofiles.SDKIAMOps
+}
+
+func (fakeIAM) ListInstanceProfiles(context.Context, *iam.ListInstanceProfilesInput, ...func(*iam.Options)) (*iam.ListInstanceProfilesOutput, error) {
+	return &iam.ListInstanceProfilesOutput{}, nil
+}
+
+type fakeFIS struct {
+	fis.SDKFISOps
+}
+
+func (fakeFIS) ListExperimentTemplates(context.Context, *awsfis.ListExperimentTemplatesInput, ...func(*awsfis.Options)) (*awsfis.ListExperimentTemplatesOutput, error) {
+	return &awsfis.ListExperimentTemplatesOutput{}, nil
+}
+
+func newFakeAWSVM(ec2API *fakeEC2) AWSVM {
+	return AWSVM{
+		vpcWatcher:             vpcs.NewWatcher(aws.Config{}, ec2API),
+		subnetWatcher:          subnets.NewWatcher(ec2API),
+		igwWatcher:             igws.NewWatcher(ec2API),
+		routeTableWatcher:      routetables.NewWatcher(ec2API),
+		natGatewayWatcher:      natgws.NewWatcher(ec2API),
+		eipWatcher:             eips.NewWatcher(ec2API),
+		securityGroupWatcher:   securitygroups.NewWatcher(ec2API),
+		instanceWatcher:        instances.NewWatcher(ec2API),
+		launchTemplateWatcher:  launchtemplates.NewWatcher(ec2API),
+		fleetWatcher:           fleets.NewWatcher(ec2API),
+		volumeWatcher:          volumes.NewWatcher(ec2API),
+		instanceProfileWatcher: instanceprofiles.NewWatcher(fakeIAM{}),
+		fisWatcher:             fis.NewWatcher(fakeFIS{}, nil),
+	}
+}
+
+func TestDeletionPlanStoppedInstances(t *testing.T) {
+	tags := tagutils.MapToEC2Tags(tagutils.NamespacedTags("dev", "web"))
+	ec2API := &fakeEC2{
+		instances: []ec2types.Instance{{
+			InstanceId:     aws.String("i-1"),
+			State:          &ec2types.InstanceState{Name: ec2types.InstanceStateNameStopped},
+			VpcId:          aws.String("vpc-1"),
+			SubnetId:       aws.String("subnet-1"),
+			SecurityGroups: []ec2types.GroupIdentifier{{GroupId: aws.String("sg-1")}},
+			Tags:           tags,
+		}},
+		securityGroups: []ec2types.SecurityGroup{{GroupId: aws.String("sg-1"), GroupName: aws.String("dev-web"), VpcId: aws.String("vpc-1"), Tags: tags}},
+		subnets:        []ec2types.Subnet{{SubnetId: aws.String("subnet-1"), VpcId: aws.String("vpc-1"), Tags: tags}},
+		vpcs:           []ec2types.Vpc{{VpcId: aws.String("vpc-1"), Tags: tags}},
+	}
+	deletionPlan, err := newFakeAWSVM(ec2API).DeletionPlan(context.Background(), "dev", "web")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := lo.Map(deletionPlan.Spec.Instances, func(instance instances.Instance, _ int) string { return *instance.InstanceId }); !reflect.DeepEqual(got, []string{"i-1"}) {
+		t.Errorf("Spec.Instances = %v, want [i-1]", got)
+	}
+	if got := lo.Map(deletionPlan.Spec.SecurityGroups, func(group securitygroups.SecurityGroup, _ int) string { return *group.GroupId }); !reflect.DeepEqual(got, []string{"sg-1"}) {
+		t.Errorf("Spec.SecurityGroups = %v, want [sg-1]", got)
+	}
+	if got := lo.Map(deletionPlan.Spec.Subnets, func(subnet subnets.Subnet, _ int) string { return *subnet.SubnetId }); !reflect.DeepEqual(got, []string{"subnet-1"}) {
+		t.Errorf("Spec.Subnets = %v, want [subnet-1]", got)
+	}
+	if got := lo.Map(deletionPlan.Spec.VPCs, func(vpc vpcs.VPC, _ int) string { return *vpc.VpcId }); !reflect.DeepEqual(got, []string{"vpc-1"}) {
+		t.Errorf("Spec.VPCs = %v, want [vpc-1]", got)
+	}
+	if len(deletionPlan.Spec.Skipped) != 0 {
+		t.Errorf("Spec.Skipped = %v, want none", deletionPlan.Spec.Skipped)
+	}
+}
@@ -0,0 +1,134 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/samber/lo"
+)
+
+// PlanDiff is what would change if a launch plan was applied to its deployed VM
+type PlanDiff struct {
+	Namespace string
+	Name      string
+	// Action is the reconciliation action that applying the plan would take, nothing changes if it is none
+	Action     string
+	NodeGroups []NodeGroupDiff
+}
+
+// NodeGroupDiff is how the launch template data and fleet overrides of a node group differ from the ones of its most recent fleet
+type NodeGroupDiff struct {
+	Group string
+	// LaunchTemplate is the ID and version of the deployed launch template, e.g. lt-0123:2, empty if the group has not been launched
+	LaunchTemplate string
+	// Fleet is the ID of the most recent fleet of the group, empty if the group has not been launched
+	Fleet string
+	Diffs []pretty.FieldDiff
+}
+
+// Diff resolves the launch plan without launching anything and diffs the launch template data and fleet overrides that it would launch
+// with against the ones of the most recent fleet of each node group. The overrides have a line per instance type, subnet, and AMI.
+func (v AWSVM) Diff(ctx context.Context, launchPlan plans.LaunchPlan) (PlanDiff, error) {
+	resolved, err := v.Launch(ctx, true, launchPlan)
+	if err != nil {
+		return PlanDiff{}, err
+	}
+	planDiff := PlanDiff{
+		Namespace: launchPlan.Metadata.Namespace,
+		Name:      launchPlan.Metadata.Name,
+		Action:    resolved.Status.Reconciliation.Action,
+	}
+	if planDiff.Action == plans.ReconcileNone {
+		return planDiff, nil
+	}
+	resolved = unflattenSingleNodeGroup(resolved)
+	nodeGroups, err := plans.OrderNodeGroups(launchPlan.Spec.EffectiveNodeGroups())
+	if err != nil {
+		return PlanDiff{}, err
+	}
+	for i, group := range nodeGroups {
+		groupDiff, err := v.diffNodeGroup(ctx, resolved, group, resolved.Status.NodeGroups[i])
+		if err != nil {
+			return PlanDiff{}, err
+		}
+		planDiff.NodeGroups = append(planDiff.NodeGroups, groupDiff)
+	}
+	return planDiff, nil
+}
+
+// diffNodeGroup diffs the launch template options and fleet overrides of the resolved node group against the ones of its most recent fleet
+func (v AWSVM) diffNodeGroup(ctx context.Context, launchPlan plans.LaunchPlan, group plans.NodeGroup, groupStatus plans.NodeGroupStatus) (NodeGroupDiff, error) {
+	tags, groupTags := nodeGroupTags(launchPlan, group)
+	createOpts, err := nodeGroupLaunchTemplateOptions(launchPlan, group, groupStatus)
+	if err != nil {
+		return NodeGroupDiff{}, err
+	}
+	desiredOverrides, _, err := fleets.Overrides(fleetOptions(launchPlan, group, groupStatus, launchPlan.Status.Subnets, groupTags))
+	if err != nil {
+		return NodeGroupDiff{}, err
+	}
+
+	logging.FromContext(ctx).Debug("Resolving the deployed fleet", "group", group.Name)
+	fleetList, err := v.fleetWatcher.Resolve(ctx, []fleets.Selector{{Tags: tags}})
+	if err != nil {
+		return NodeGroupDiff{}, err
+	}
+	if group.Name == "" {
+		fleetList = lo.Reject(fleetList, func(fleet fleets.Fleet, _ int) bool { return hasGroupTag(fleet.Tags) })
+	}
+	groupDiff := NodeGroupDiff{Group: group.Name}
+	var liveData ec2types.ResponseLaunchTemplateData
+	var liveOverrides []fleets.Override
+	if len(fleetList) != 0 {
+		fleet := lo.MaxBy(fleetList, func(a, b fleets.Fleet) bool { return lo.FromPtr(a.CreateTime).After(lo.FromPtr(b.CreateTime)) })
+		groupDiff.Fleet = lo.FromPtr(fleet.FleetId)
+		liveOverrides = fleet.Overrides()
+		if len(fleet.LaunchTemplateConfigs) != 0 && fleet.LaunchTemplateConfigs[0].LaunchTemplateSpecification != nil {
+			spec := fleet.LaunchTemplateConfigs[0].LaunchTemplateSpecification
+			groupDiff.LaunchTemplate = fmt.Sprintf("%s:%s", lo.FromPtr(spec.LaunchTemplateId), lo.FromPtr(spec.Version))
+			data, err := v.launchTemplateData(ctx, lo.FromPtr(spec.LaunchTemplateId), lo.FromPtr(spec.Version))
+			if err != nil {
+				return NodeGroupDiff{}, err
+			}
+			liveData = data
+		}
+	}
+
+	groupDiff.Diffs = launchtemplates.FieldDiffs(liveData, createOpts)
+	live, desired := overrideLines(liveOverrides), overrideLines(desiredOverrides)
+	if live != desired {
+		groupDiff.Diffs = append(groupDiff.Diffs, pretty.FieldDiff{Field: "overrides", Live: live, Desired: desired})
+	}
+	return groupDiff, nil
+}
+
+// launchTemplateData returns the data of the launch template version, which is empty if the launch template no longer exists
+func (v AWSVM) launchTemplateData(ctx context.Context, launchTemplateID, version string) (ec2types.ResponseLaunchTemplateData, error) {
+	launchTemplates, err := v.launchTemplateWatcher.Resolve(ctx, []launchtemplates.Selector{{ID: launchTemplateID, Version: version}})
+	if err != nil {
+		return ec2types.ResponseLaunchTemplateData{}, err
+	}
+	if len(launchTemplates) == 0 {
+		return ec2types.ResponseLaunchTemplateData{}, nil
+	}
+	versions := launchTemplates[0].LaunchTemplateVersions
+	if len(versions) == 0 || versions[0].LaunchTemplateData == nil {
+		return ec2types.ResponseLaunchTemplateData{}, nil
+	}
+	return *versions[0].LaunchTemplateData, nil
+}
+
+// overrideLines returns the overrides sorted with a line per override
+func overrideLines(overrides []fleets.Override) string {
+	lines := lo.Map(overrides, func(override fleets.Override, _ int) string { return override.String() })
+	slices.Sort(lines)
+	return strings.Join(lines, "\n")
+}
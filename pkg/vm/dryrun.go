@@ -0,0 +1,191 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/naming"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/readonly"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// PermissionCheckPermitted is the permission check of a planned resource that EC2 confirmed the caller can create
+	PermissionCheckPermitted = "permitted"
+	// PermissionCheckUnchecked is the permission check of a planned resource that could not be checked,
+	// e.g. because it depends on another planned resource or nimbus is in read-only mode
+	PermissionCheckUnchecked = "unchecked"
+)
+
+// permissionCheck converts the error of an EC2 DryRun request to the permission check of a planned resource.
+// A nil error means the request was not sent, since a DryRun request always returns an error.
+func permissionCheck(err error) string {
+	switch {
+	case err == nil:
+		return PermissionCheckUnchecked
+	case ec2utils.IsDryRunOperationErr(err):
+		return PermissionCheckPermitted
+	case errors.Is(err, readonly.ErrReadOnly):
+		// read-only mode blocks DryRun requests too since they are create calls
+		return PermissionCheckUnchecked
+	default:
+		return err.Error()
+	}
+}
+
+// planResource records a resource that a dry-run launch would have created along with the result of its DryRun request
+func planResource(launchPlan *plans.LaunchPlan, resourceType string, name string, checkErr error) {
+	launchPlan.Status.PlannedResources = append(launchPlan.Status.PlannedResources, plans.PlannedResource{
+		Type:            resourceType,
+		Name:            name,
+		PermissionCheck: permissionCheck(checkErr),
+	})
+}
+
+// planNetwork records the VPC, subnets, internet gateway, route tables, NAT Gateway, and Egress-Only Internet Gateway that a launch would create for the network
+func (v AWSVM) planNetwork(ctx context.Context, launchPlan *plans.LaunchPlan, networkName string, topology string) error {
+	name := fmt.Sprintf("%s/%s", launchPlan.Metadata.Namespace, networkName)
+	network := launchPlan.Spec.Network.WithDefaults()
+	planResource(launchPlan, "VPC", name, v.vpcWatcher.DryRunCreate(ctx, network.VPCCIDR))
+
+	logging.FromContext(ctx).Debug("Resolving Availability Zones")
+	zones, err := v.networkAZs(ctx, launchPlan.Spec.Network)
+	if err != nil {
+		return err
+	}
+	// The rest of the network is created in the new VPC, so it cannot be checked
+	for _, az := range zones {
+		planResource(launchPlan, "Subnet", fmt.Sprintf("%s/%s", name, lo.FromPtr(az.ZoneName)), nil)
+	}
+	planResource(launchPlan, "InternetGateway", name, nil)
+	planResource(launchPlan, "RouteTable", name, nil)
+	if hasPrivateSubnets(topology) {
+		for _, az := range zones {
+			planResource(launchPlan, "Subnet", fmt.Sprintf("%s/%s/private", name, lo.FromPtr(az.ZoneName)), nil)
+		}
+		planResource(launchPlan, "NATGateway", name, nil)
+		if network.HasIPv6() {
+			planResource(launchPlan, "EgressOnlyInternetGateway", name, nil)
+		}
+		planResource(launchPlan, "RouteTable", name+"/private", nil)
+	}
+	return nil
+}
+
+// planPrivateSubnets records the private subnets, NAT Gateway, Egress-Only Internet Gateway, and route table that a launch would add
+// to an existing network without private subnets
+func planPrivateSubnets(launchPlan *plans.LaunchPlan, subnetList []subnets.Subnet, networkName string) {
+	publicSubnets := lo.Filter(subnetList, func(subnet subnets.Subnet, _ int) bool { return isPublicSubnet(subnet) })
+	if len(publicSubnets) != len(subnetList) {
+		return
+	}
+	name := fmt.Sprintf("%s/%s", launchPlan.Metadata.Namespace, networkName)
+	for _, subnet := range publicSubnets {
+		planResource(launchPlan, "Subnet", fmt.Sprintf("%s/%s/private", name, lo.FromPtr(subnet.AvailabilityZone)), nil)
+	}
+	planResource(launchPlan, "NATGateway", name, nil)
+	if hasIPv6Subnets(publicSubnets) {
+		planResource(launchPlan, "EgressOnlyInternetGateway", name, nil)
+	}
+	planResource(launchPlan, "RouteTable", name+"/private", nil)
+}
+
+// planSecurityGroup records a security group that a launch would create for the plan or the node group
+func (v AWSVM) planSecurityGroup(ctx context.Context, launchPlan *plans.LaunchPlan, groupName string, tags map[string]string) error {
+	sgName, err := resourceName(*launchPlan, naming.SecurityGroup, groupName, "")
+	if err != nil {
+		return err
+	}
+	vpcID := lo.FromPtr(launchPlan.Status.VPC.VpcId)
+	if vpcID == "" && len(launchPlan.Status.Subnets) != 0 {
+		vpcID = lo.FromPtr(launchPlan.Status.Subnets[0].VpcId)
+	}
+	var checkErr error
+	if vpcID != "" {
+		_, checkErr = v.securityGroupWatcher.CreateSecurityGroup(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, securitygroups.CreateSecurityGroupOpts{
+			Name:   sgName,
+			VPCID:  vpcID,
+			Tags:   tags,
+			DryRun: true,
+		})
+	}
+	planResource(launchPlan, "SecurityGroup", sgName, checkErr)
+	return nil
+}
+
+// planNodeGroup resolves the node group's existing launch template and records the launch template and fleets that a launch would create
+func (v AWSVM) planNodeGroup(ctx context.Context, launchPlan *plans.LaunchPlan, group plans.NodeGroup, index int) error {
+	tags, groupTags := nodeGroupTags(*launchPlan, group)
+	if err := v.planInstanceProfile(ctx, launchPlan, index); err != nil {
+		return err
+	}
+	createOpts, err := nodeGroupLaunchTemplateOptions(*launchPlan, group, launchPlan.Status.NodeGroups[index])
+	if err != nil {
+		return err
+	}
+	specHash := launchtemplates.SpecHash(createOpts)
+	logging.FromContext(ctx).Debug("Resolving Launch Template", "group", group.Name, "spec-hash", specHash)
+	launchTemplates, err := v.resolveNodeGroupLaunchTemplate(ctx, group, lo.Assign(tags, map[string]string{tagutils.SpecHashTagKey: specHash}))
+	if err != nil {
+		return err
+	}
+
+	fleetName := tags["Name"]
+	if group.Name != "" {
+		fleetName = fmt.Sprintf("%s/%s", fleetName, group.Name)
+	}
+	fleetNames := []string{fleetName}
+	if len(launchPlan.Spec.Placements) != 0 {
+		fleetNames = lo.Map(launchPlan.Status.Reconciliation.MissingPlacements, func(i int, _ int) string { return fleetName + "/" + strconv.Itoa(i) })
+	}
+	// only the missing instances of the group are launched, and an update launches none
+	group, launch := reconcileGroup(*launchPlan, group)
+	if !launch {
+		fleetNames = nil
+	}
+
+	var version int64
+	if len(launchTemplates) != 0 {
+		version = launchTemplateVersion(launchTemplates[0], specHash)
+	} else {
+		createOpts.LaunchTemplateName, err = resourceName(*launchPlan, naming.LaunchTemplate, group.Name, specHash)
+		if err != nil {
+			return err
+		}
+		createOpts.DryRun = true
+		launchTemplates, version, err = v.planLaunchTemplate(ctx, launchPlan, group, createOpts)
+		if err != nil {
+			return err
+		}
+		if len(launchTemplates) == 0 {
+			// Fleets reference the launch template, so they cannot be checked until it exists
+			for _, name := range fleetNames {
+				planResource(launchPlan, "Fleet", name, nil)
+			}
+			return nil
+		}
+	}
+	launchPlan.Status.NodeGroups[index].LaunchTemplate = launchTemplates[0]
+	launchPlan.Status.NodeGroups[index].LaunchTemplateVersion = version
+
+	var checkErr error
+	if len(launchPlan.Status.Subnets) != 0 && len(fleetNames) != 0 {
+		fleetOpts := fleetOptions(*launchPlan, group, launchPlan.Status.NodeGroups[index], launchPlan.Status.Subnets, groupTags)
+		fleetOpts.DryRun = true
+		warnDroppedInstanceTypes(ctx, group, fleetOpts)
+		_, _, checkErr = v.fleetWatcher.CreateFleet(ctx, fleetOpts)
+	}
+	for _, name := range fleetNames {
+		planResource(launchPlan, "Fleet", name, checkErr)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/events"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/readonly"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+const (
+	// eventsWaitTime is how long a receive long polls the event queue, the SQS maximum
+	eventsWaitTime = 20 * time.Second
+	// eventsDrainWaitTime is how long a receive waits for queued events when not watching
+	eventsDrainWaitTime = time.Second
+)
+
+// Events captures the EC2 spot interruption warnings, rebalance recommendations, and state changes of the namespace's instances,
+// or of the name's instances if name is set, and only of the instances launched by planID if it is set. The namespace's event rule and queue are created the first time, so only events after that are captured.
+// Without watch the events that are already queued are emitted and the channel is closed, otherwise it is closed when ctx is done.
+func (v AWSVM) Events(ctx context.Context, namespace, name, planID string, watch bool) (<-chan events.Event, error) {
+	logging.FromContext(ctx).Debug("Ensuring the event rule and queue", "namespace", namespace)
+	queue, err := v.eventWatcher.Ensure(ctx, namespace)
+	// read-only mode can not create the rule and queue, but it can receive the events of a queue that already exists
+	if errors.Is(err, readonly.ErrReadOnly) {
+		logging.FromContext(ctx).Debug("Read-only mode, receiving from the existing event queue", "namespace", namespace)
+		queue, err = v.eventWatcher.Resolve(ctx, namespace)
+	}
+	if err != nil {
+		return nil, err
+	}
+	eventsChan := make(chan events.Event)
+	go func() {
+		defer close(eventsChan)
+		for {
+			received, err := v.eventWatcher.Receive(ctx, queue, lo.Ternary(watch, eventsWaitTime, eventsDrainWaitTime))
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logging.FromContext(ctx).Error("Unable to receive events", "error", err)
+			}
+			if !watch && err == nil && len(received) == 0 {
+				return
+			}
+			if len(received) != 0 && !v.emitEvents(ctx, eventsChan, namespace, name, planID, received) {
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+	return eventsChan, nil
+}
+
+// emitEvents sends the events of the namespace's or name's instances in the order they happened.
+// It returns false if ctx was cancelled while sending.
+func (v AWSVM) emitEvents(ctx context.Context, eventsChan chan<- events.Event, namespace, name, planID string, received []events.Event) bool {
+	// the rule captures the events of every instance in the region, so they are filtered by the instances' tags
+	tags := tagutils.NamespacedTags(namespace, name)
+	if planID != "" {
+		tags[tagutils.PlanIDTagKey] = planID
+	}
+	instanceList, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{Tags: tags}})
+	if err != nil {
+		logging.FromContext(ctx).Error("Unable to resolve the instances of events", "error", err)
+		return ctx.Err() == nil
+	}
+	planIDs := lo.SliceToMap(instanceList, func(instance instances.Instance) (string, string) {
+		return lo.FromPtr(instance.InstanceId), instance.PlanID()
+	})
+	received = lo.FilterMap(received, func(event events.Event, _ int) (events.Event, bool) {
+		eventPlanID, ok := planIDs[event.InstanceID]
+		event.PlanID = eventPlanID
+		return event, ok
+	})
+	// SQS does not preserve the order of the events
+	slices.SortStableFunc(received, func(a, b events.Event) int { return a.Time.Compare(b.Time) })
+	for _, event := range received {
+		select {
+		case <-ctx.Done():
+			return false
+		case eventsChan <- event:
+		}
+	}
+	return true
+}
+
+// DeleteEvents deletes the namespace's event rule and queue
+func (v AWSVM) DeleteEvents(ctx context.Context, namespace string) error {
+	return v.eventWatcher.Delete(ctx, namespace)
+}
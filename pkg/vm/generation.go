@@ -0,0 +1,37 @@
+package vm
+
+import (
+	"strconv"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+)
+
+// planGeneration returns the generation of a launch plan from the existing instances of its namespace/name.
+// Relaunching the spec of the latest generation of namespace/name keeps the generation, while a changed spec starts the next generation.
+func planGeneration(existingInstances []instances.Instance, specChecksum string) int64 {
+	var latest instances.Instance
+	for _, instance := range existingInstances {
+		if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
+			continue
+		}
+		if instance.Generation() > latest.Generation() {
+			latest = instance
+		}
+	}
+	if latest.Generation() != 0 && latest.SpecChecksum() == specChecksum {
+		return latest.Generation()
+	}
+	return latest.Generation() + 1
+}
+
+// generationTags returns the tags that record the plan generation, spec checksum, and plan ID on launched instances
+func generationTags(launchPlan plans.LaunchPlan) map[string]string {
+	return map[string]string{
+		tagutils.GenerationTagKey:   strconv.FormatInt(launchPlan.Metadata.Generation, 10),
+		tagutils.SpecChecksumTagKey: launchPlan.Status.SpecChecksum,
+		tagutils.PlanIDTagKey:       launchPlan.Metadata.PlanID,
+	}
+}
@@ -0,0 +1,124 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
+	"github.com/samber/lo"
+)
+
+const (
+	// gpuDriversTimeout is how long to wait for nvidia-smi to succeed, installing the drivers builds kernel modules which takes several minutes
+	gpuDriversTimeout = 30 * time.Minute
+	// gpuDriversCheckInterval is how often nvidia-smi is run on instances that have not passed yet
+	gpuDriversCheckInterval = 30 * time.Second
+	// gpuDriversCheckTimeout is how long nvidia-smi may run on an instance
+	gpuDriversCheckTimeout = time.Minute
+)
+
+// validateGPUDrivers checks that the GPU drivers option of a launch spec is supported
+func validateGPUDrivers(gpuDrivers string) error {
+	if gpuDrivers != "" && gpuDrivers != plans.GPUDriversInstall && gpuDrivers != plans.GPUDriversDLAMI {
+		return fmt.Errorf("invalid GPU drivers %q, must be %s or %s", gpuDrivers, plans.GPUDriversInstall, plans.GPUDriversDLAMI)
+	}
+	return nil
+}
+
+// hasNVIDIAGPU is true if any of the instance types has NVIDIA GPUs
+func hasNVIDIAGPU(instanceTypes []instancetypes.InstanceType) bool {
+	return lo.SomeBy(instanceTypes, func(instanceType instancetypes.InstanceType) bool { return instanceType.HasNVIDIAGPU() })
+}
+
+// gpuAMISelectors returns the Deep Learning Base AMI selector for node groups with NVIDIA GPU instance types when the spec chooses the DLAMI,
+// otherwise the group's own AMI selectors
+func gpuAMISelectors(spec plans.LaunchSpec, group plans.NodeGroup, instanceTypes []instancetypes.InstanceType) []amis.Selector {
+	if spec.GPUDrivers != plans.GPUDriversDLAMI || !hasNVIDIAGPU(instanceTypes) {
+		return group.AMISelectors
+	}
+	return []amis.Selector{{Alias: amis.AliasDLAMIGPU}}
+}
+
+// installsGPUDrivers is true if the node group's user-data installs the NVIDIA drivers
+func installsGPUDrivers(spec plans.LaunchSpec, groupStatus plans.NodeGroupStatus) bool {
+	return spec.GPUDrivers == plans.GPUDriversInstall && hasNVIDIAGPU(groupStatus.InstanceTypes)
+}
+
+// warnGPUDriversAMIs warns about AMIs of a node group that installs the NVIDIA drivers which are not Amazon Linux 2023.
+// Custom AMIs may be based on it too, so they are not rejected.
+func warnGPUDriversAMIs(ctx context.Context, spec plans.LaunchSpec, groupStatus plans.NodeGroupStatus) {
+	if !installsGPUDrivers(spec, groupStatus) {
+		return
+	}
+	for _, ami := range groupStatus.AMIs {
+		if alias, _ := ami.Alias(); alias != "al2023" && alias != "al2023-minimal" {
+			logging.FromContext(ctx).Warn("GPU drivers are installed from NVIDIA's Amazon Linux 2023 repository, the AMI may not support them", "group", groupStatus.Name, "ami", lo.FromPtr(ami.ImageId))
+		}
+	}
+}
+
+// verifyGPUDrivers runs nvidia-smi over SSM on every NVIDIA GPU instance of the launch plan until it succeeds on all of them.
+// Node groups whose GPU instances all passed are marked as verified.
+func (v AWSVM) verifyGPUDrivers(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	ctx, cancel := context.WithTimeout(ctx, gpuDriversTimeout)
+	defer cancel()
+	for i, groupStatus := range launchPlan.Status.NodeGroups {
+		gpuInstanceTypes := lo.SliceToMap(lo.Filter(groupStatus.InstanceTypes, func(instanceType instancetypes.InstanceType, _ int) bool {
+			return instanceType.HasNVIDIAGPU()
+		}), func(instanceType instancetypes.InstanceType) (string, bool) {
+			return string(instanceType.InstanceType), true
+		})
+		if len(gpuInstanceTypes) == 0 {
+			continue
+		}
+		pending := idsOf(lo.Filter(groupStatus.Instances, func(instance instances.Instance, _ int) bool {
+			return gpuInstanceTypes[string(instance.InstanceType)]
+		}))
+		logging.FromContext(ctx).Debug("Waiting for GPU drivers", "group", groupStatus.Name, "instance-ids", pending)
+		if err := v.waitForNVIDIASMI(ctx, pending); err != nil {
+			return fmt.Errorf("GPU drivers of node group %s are not ready: %w", groupStatus.Name, err)
+		}
+		launchPlan.Status.NodeGroups[i].GPUDriversVerified = true
+	}
+	return nil
+}
+
+// waitForNVIDIASMI runs nvidia-smi on the instances until it exits successfully on every one of them or ctx is done
+func (v AWSVM) waitForNVIDIASMI(ctx context.Context, instanceIDs []string) error {
+	ticker := time.NewTicker(gpuDriversCheckInterval)
+	defer ticker.Stop()
+	var lastOutput string
+	for {
+		results, err := v.sessionWatcher.RunCommand(ctx, sessions.Command{
+			InstanceIDs: instanceIDs,
+			Script:      "nvidia-smi",
+			Timeout:     gpuDriversCheckTimeout,
+		})
+		if err != nil && ctx.Err() == nil {
+			return err
+		}
+		for _, result := range results {
+			if result.Status == ssmtypes.CommandInvocationStatusSuccess && result.ExitCode == 0 {
+				instanceIDs = lo.Without(instanceIDs, result.InstanceID)
+				continue
+			}
+			lastOutput = strings.TrimSpace(result.Stdout + result.Stderr)
+		}
+		if len(instanceIDs) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("nvidia-smi did not succeed on %s: %s", strings.Join(instanceIDs, ", "), lastOutput)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/metrics"
+	"github.com/samber/lo"
+)
+
+// IdleRecommendation is what to do with an instance whose average CPU utilization is below the idle threshold
+type IdleRecommendation string
+
+const (
+	// RecommendStop means the instance is barely used at all and can be stopped
+	RecommendStop IdleRecommendation = "Stop"
+	// RecommendDownsize means the instance still has network traffic, so it is in use, but needs fewer vCPUs
+	RecommendDownsize IdleRecommendation = "Downsize"
+)
+
+// IdleOptions are the thresholds an instance's utilization is compared against
+type IdleOptions struct {
+	// CPUThreshold is the average CPU utilization percentage below which an instance is idle
+	CPUThreshold float64
+	// NetworkThreshold is the average network bytes per second below which an idle instance can be stopped rather than downsized
+	NetworkThreshold bytesize.ByteSize
+	// Window is how far back utilization is averaged
+	Window time.Duration
+}
+
+// IdleInstance is a running instance whose average CPU utilization over the window is below the idle threshold
+type IdleInstance struct {
+	Instance       instances.Instance
+	Utilization    metrics.Utilization
+	Recommendation IdleRecommendation
+}
+
+// PrettyIdleInstance represents an idle instance for UI elements like the static and TUI tables
+type PrettyIdleInstance struct {
+	Name           string `table:"Name"`
+	InstanceID     string `table:"ID"`
+	InstanceType   string `table:"Instance-Type"`
+	CPUAverage     string `table:"CPU-Avg"`
+	CPUMaximum     string `table:"CPU-Max"`
+	Network        string `table:"Network"`
+	Recommendation string `table:"Recommendation"`
+	Age            string `table:"Age,wide,right"`
+	Datapoints     string `table:"Datapoints,wide"`
+}
+
+// Idle returns the running instances of namespace/name whose average CPU utilization over the window is below the CPU threshold.
+// Instances without metrics in the window, e.g. instances that were just launched, are never idle.
+func (v AWSVM) Idle(ctx context.Context, namespace, name string, idleOptions IdleOptions) ([]IdleInstance, error) {
+	if idleOptions.Window <= 0 {
+		return nil, fmt.Errorf("idle window must be positive, got %s", idleOptions.Window)
+	}
+	instanceList, err := v.List(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	instanceList = lo.Filter(instanceList, func(instance instances.Instance, _ int) bool {
+		return instance.State != nil && instance.State.Name == ec2types.InstanceStateNameRunning
+	})
+	if len(instanceList) == 0 {
+		return nil, nil
+	}
+
+	end := time.Now()
+	logging.FromContext(ctx).Debug("Resolving instance utilization", "instances", len(instanceList), "window", idleOptions.Window)
+	utilizations, err := v.metricsWatcher.Resolve(ctx, lo.Map(instanceList, func(instance instances.Instance, _ int) metrics.Selector {
+		return metrics.Selector{InstanceID: *instance.InstanceId, StartTime: end.Add(-idleOptions.Window), EndTime: end}
+	}))
+	if err != nil {
+		return nil, err
+	}
+
+	var idle []IdleInstance
+	for i, utilization := range utilizations {
+		recommendation, ok := idleOptions.Recommend(utilization)
+		if !ok {
+			continue
+		}
+		idle = append(idle, IdleInstance{Instance: instanceList[i], Utilization: utilization, Recommendation: recommendation})
+	}
+	return idle, nil
+}
+
+// Recommend returns what to do with the instance of the utilization, and false if the instance is not idle
+func (o IdleOptions) Recommend(utilization metrics.Utilization) (IdleRecommendation, bool) {
+	if utilization.Datapoints == 0 || utilization.CPUAverage >= o.CPUThreshold {
+		return "", false
+	}
+	if utilization.NetworkAverage < o.NetworkThreshold.Bytes() {
+		return RecommendStop, true
+	}
+	return RecommendDownsize, true
+}
+
+// Prettify converts the idle instance into a PrettyIdleInstance
+func (i IdleInstance) Prettify() PrettyIdleInstance {
+	prettyInstance := i.Instance.Prettify()
+	return PrettyIdleInstance{
+		Name:           prettyInstance.Name,
+		InstanceID:     prettyInstance.InstanceID,
+		InstanceType:   prettyInstance.InstanceType,
+		CPUAverage:     fmt.Sprintf("%.1f%%", i.Utilization.CPUAverage),
+		CPUMaximum:     fmt.Sprintf("%.1f%%", i.Utilization.CPUMaximum),
+		Network:        pretty.Bytes(bytesize.ByteSize(i.Utilization.NetworkAverage)) + "/s",
+		Recommendation: string(i.Recommendation),
+		Age:            prettyInstance.Age,
+		Datapoints:     strconv.Itoa(i.Utilization.Datapoints),
+	}
+}
+
+// PrettifyIdle converts idle instances into PrettyIdleInstances
+func PrettifyIdle(idle []IdleInstance) []PrettyIdleInstance {
+	return lo.Map(idle, func(i IdleInstance, _ int) PrettyIdleInstance { return i.Prettify() })
+}
@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/amis"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
+	"github.com/bwagner5/nimbus/pkg/userdata"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// ImageBuild bakes an AMI from the instance of a launch plan after a job provisions it
+type ImageBuild struct {
+	// ImageName is the name of the AMI, which must be unique in the account and region
+	ImageName   string
+	Description string
+	// Job provisions the builder instance, the AMI is only created if its script exits with 0.
+	// The builder is deleted once the AMI is available, unless the job keeps it.
+	Job Job
+	// ImageTimeout is how long the AMI may take to become available after it is created
+	ImageTimeout time.Duration
+}
+
+// ImageBuildResult is the outcome of an image build
+type ImageBuildResult struct {
+	JobResult
+	// ImageID is empty if the provisioning job failed
+	ImageID   string
+	ImageName string
+}
+
+// ResolveAlias returns the AMIs that a launch with the alias would use in the region, one per architecture
+func (v AWSVM) ResolveAlias(ctx context.Context, alias string) ([]amis.AMI, error) {
+	logging.FromContext(ctx).Debug("Resolving AMI alias", "alias", alias)
+	amiList, err := v.amiWatcher.ResolveAlias(ctx, alias)
+	if err != nil {
+		return nil, err
+	}
+	return amis.PerArchitecture(amiList), nil
+}
+
+// BuildImage runs the build's job on a single instance of the launch plan like Run, creates an AMI from the instance once the job succeeds,
+// and deletes the builder VM when the AMI is available or the build fails. The AMI and its snapshots are tagged with the plan's namespace and name.
+func (v AWSVM) BuildImage(ctx context.Context, launchPlan plans.LaunchPlan, build ImageBuild, logs io.Writer) (_ ImageBuildResult, err error) {
+	result := ImageBuildResult{ImageName: build.ImageName}
+	if build.ImageName == "" {
+		return result, fmt.Errorf("an image build requires an image name")
+	}
+	if build.ImageTimeout <= 0 {
+		return result, fmt.Errorf("an image build requires a positive image timeout")
+	}
+	// the builder outlives the job until the AMI is available
+	keep := build.Job.Keep
+	build.Job.Keep = true
+	launchPlan.Spec.TTL = build.Job.Timeout + build.ImageTimeout + jobShutdownMargin
+	launchPlan, result.JobResult, err = v.Run(ctx, launchPlan, build.Job, logs)
+	if !keep {
+		defer func() {
+			// the VM is deleted even if ctx was cancelled, e.g. by an interrupt
+			if deleteErr := v.deleteJob(context.WithoutCancel(ctx), launchPlan); deleteErr != nil {
+				err = errors.Join(err, deleteErr)
+			}
+		}()
+	}
+	if err != nil {
+		return result, err
+	}
+	if result.ExitCode != 0 {
+		return result, fmt.Errorf("the provisioning job exited with code %d, no image was created", result.ExitCode)
+	}
+
+	// the job's script and output are not baked into the image
+	logging.FromContext(ctx).Debug("Removing the job's files from the builder", "instance-id", result.InstanceID)
+	cleanup, err := v.sessionWatcher.RunCommand(ctx, sessions.Command{
+		InstanceIDs: []string{result.InstanceID},
+		Script:      fmt.Sprintf("rm -rf %s", userdata.JobDir),
+		Timeout:     time.Minute,
+	})
+	if err != nil {
+		return result, err
+	}
+	if len(cleanup) != 1 || !cleanup[0].Succeeded() {
+		return result, fmt.Errorf("failed to remove the job's files on %s", result.InstanceID)
+	}
+
+	tags := lo.Assign(tagutils.NamespacedTags(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name), map[string]string{
+		tagutils.PlanIDTagKey: launchPlan.Metadata.PlanID,
+	})
+	logging.FromContext(ctx).Info("Creating image", "instance-id", result.InstanceID, "image-name", build.ImageName)
+	result.ImageID, err = v.amiWatcher.CreateImage(ctx, result.InstanceID, build.ImageName, build.Description, tags)
+	if err != nil {
+		return result, err
+	}
+	logging.FromContext(ctx).Info("Waiting for image to become available", "image-id", result.ImageID)
+	return result, v.amiWatcher.WaitForAvailable(ctx, result.ImageID, build.ImageTimeout)
+}
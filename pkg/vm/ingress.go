@@ -0,0 +1,85 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// validateIngressRules returns an error if the spec's ingress rules can not be authorized on the security group that nimbus creates for the plan
+func validateIngressRules(spec plans.LaunchSpec) error {
+	if len(spec.IngressRules) == 0 {
+		return nil
+	}
+	if len(spec.SecurityGroupSelectors) != 0 {
+		return fmt.Errorf("ingress rules are only authorized on the security group that nimbus creates, they can not be used with a security group selector")
+	}
+	for _, rule := range spec.IngressRules {
+		if rule.Group != "" {
+			return fmt.Errorf("ingress rule from node group %s must be specified on a node group", rule.Group)
+		}
+		if rule.CIDR == "" && rule.SecurityGroupID == "" {
+			return fmt.Errorf("ingress rule for protocol %s must allow a CIDR or a security group", rule.Protocol)
+		}
+	}
+	return nil
+}
+
+// authorizeIngressRules authorizes the spec's ingress rules on the plan's security groups.
+// Rules that a security group already has are skipped so that relaunching a plan adds only the new rules.
+func (v AWSVM) authorizeIngressRules(ctx context.Context, launchPlan plans.LaunchPlan) error {
+	for _, securityGroup := range launchPlan.Status.SecurityGroups {
+		for _, rule := range launchPlan.Spec.IngressRules {
+			logging.FromContext(ctx).Debug("Authorizing ingress rule", "security-group", lo.FromPtr(securityGroup.GroupId), "protocol", rule.Protocol,
+				"from-port", rule.FromPort, "to-port", rule.ToPort)
+			if err := v.securityGroupWatcher.AuthorizeIngress(ctx, lo.FromPtr(securityGroup.GroupId), []securitygroups.IngressRule{rule}); err != nil && !ec2utils.IsAlreadyExistsErr(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// SecurityGroupRules returns the rules of the security groups that nimbus created in the namespace, or for the plan if name is set, that match the selectors.
+// Selectors with a security group ID select the rules of that security group even if nimbus did not create it.
+func (v AWSVM) SecurityGroupRules(ctx context.Context, namespace, name string, selectorList []securitygroups.RuleSelector) ([]securitygroups.Rule, error) {
+	if len(selectorList) == 0 {
+		selectorList = []securitygroups.RuleSelector{{}}
+	}
+	securityGroups, err := v.securityGroupWatcher.Resolve(ctx, []securitygroups.Selector{{Tags: tagutils.NamespacedTags(namespace, name)}})
+	if err != nil {
+		return nil, err
+	}
+	var ruleSelectors []securitygroups.RuleSelector
+	for _, selector := range selectorList {
+		if selector.SecurityGroupID != "" {
+			ruleSelectors = append(ruleSelectors, selector)
+			continue
+		}
+		for _, securityGroup := range securityGroups {
+			selector.SecurityGroupID = lo.FromPtr(securityGroup.GroupId)
+			ruleSelectors = append(ruleSelectors, selector)
+		}
+	}
+	if len(ruleSelectors) == 0 {
+		return nil, nil
+	}
+	logging.FromContext(ctx).Debug("Resolving security group rules", "security-groups", len(securityGroups))
+	return v.securityGroupWatcher.ResolveRules(ctx, ruleSelectors)
+}
+
+// SecurityGroups returns the security groups that nimbus created in the namespace, or for the plan if name is set, with their ingress and egress rules
+func (v AWSVM) SecurityGroups(ctx context.Context, namespace, name string) ([]securitygroups.SecurityGroup, error) {
+	securityGroups, err := v.securityGroupWatcher.Resolve(ctx, []securitygroups.Selector{{Tags: tagutils.NamespacedTags(namespace, name)}})
+	if err != nil || len(securityGroups) == 0 {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("Resolving security group rules", "security-groups", len(securityGroups))
+	return v.securityGroupWatcher.ResolveGroupRules(ctx, securityGroups)
+}
@@ -0,0 +1,136 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/bwagner5/nimbus/pkg/artifacts"
+	"github.com/bwagner5/nimbus/pkg/events"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+)
+
+const (
+	// DefaultInstanceRoleName is the IAM role that Init creates for VMs, which lets them use SSM when they are launched with it
+	DefaultInstanceRoleName = "NimbusInstanceRole"
+
+	// Kinds of the prerequisites that Init provisions
+	PrerequisiteBucket       = "S3Bucket"
+	PrerequisiteDocument     = "SSMDocument"
+	PrerequisiteEventRule    = "EventBridgeRule"
+	PrerequisiteInstanceRole = "IAMRole"
+
+	// Statuses of the prerequisites after Init or DestroyInit
+	PrerequisiteCreated   = "Created"
+	PrerequisiteAvailable = "Available"
+	PrerequisiteDeleted   = "Deleted"
+	PrerequisiteNotFound  = "NotFound"
+	PrerequisiteKept      = "Kept"
+)
+
+// defaultInstanceRolePolicies are the AWS managed policies of the default instance role, SSM is what ssh, exec, and wait use to reach VMs
+var defaultInstanceRolePolicies = []string{"AmazonSSMManagedInstanceCore"}
+
+// Prerequisite is an account resource that nimbus features share, rather than one that belongs to a VM
+type Prerequisite struct {
+	Kind   string `table:"Kind"`
+	Name   string `table:"Name"`
+	Status string `table:"Status,status"`
+	Reason string `table:"Reason"`
+}
+
+// Init provisions the prerequisites of nimbus's advanced features in the account and region: the artifacts bucket that jobs stage
+// their inputs and outputs in, the event rule and queue of the namespace that watch and the TUI receive EC2 events from, and the default
+// instance role. The SSM documents that exec runs are managed by AWS, so they are only checked. Init is idempotent, existing prerequisites
+// are kept as they are, and the prerequisites provisioned before a failure are returned with the error.
+func (v AWSVM) Init(ctx context.Context, namespace string) ([]Prerequisite, error) {
+	var prerequisites []Prerequisite
+	logging.FromContext(ctx).Debug("Ensuring artifacts bucket")
+	bucket, created, err := v.artifactWatcher.EnsureBucket(ctx, v.awsCfg.Region)
+	if err != nil {
+		return prerequisites, err
+	}
+	prerequisites = append(prerequisites, provisioned(PrerequisiteBucket, bucket, created, "stages the inputs and outputs of jobs"))
+
+	for _, document := range sessions.Documents() {
+		logging.FromContext(ctx).Debug("Checking SSM document", "document", document)
+		if err := v.sessionWatcher.CheckDocument(ctx, document); err != nil {
+			return prerequisites, err
+		}
+		prerequisites = append(prerequisites, provisioned(PrerequisiteDocument, document, false, "runs exec commands, managed by AWS"))
+	}
+
+	logging.FromContext(ctx).Debug("Ensuring event rule", "namespace", namespace)
+	if _, err := v.eventWatcher.Ensure(ctx, namespace); err != nil {
+		return prerequisites, err
+	}
+	prerequisites = append(prerequisites, provisioned(PrerequisiteEventRule, events.Name(namespace), false, "forwards the namespace's EC2 events to its queue"))
+
+	logging.FromContext(ctx).Debug("Ensuring default instance role", "role", DefaultInstanceRoleName)
+	role, created, err := v.instanceProfileWatcher.EnsureRole(ctx, DefaultInstanceRoleName, "Default role of nimbus VMs, which allows them to be managed by SSM",
+		defaultInstanceRolePolicies, tagutils.NamespacedTags("", ""))
+	if err != nil {
+		return prerequisites, err
+	}
+	prerequisites = append(prerequisites, provisioned(PrerequisiteInstanceRole, role.RoleName, created, "launch VMs with --iam-role "+role.RoleName))
+	return prerequisites, nil
+}
+
+// DestroyInit deletes the prerequisites that Init provisioned. Prerequisites that are still in use are kept: the artifacts bucket if it has
+// artifacts, which outlive the VMs, and the default instance role if VMs were launched with it. The SSM documents are managed by AWS.
+func (v AWSVM) DestroyInit(ctx context.Context, namespace string) ([]Prerequisite, error) {
+	var prerequisites []Prerequisite
+	logging.FromContext(ctx).Debug("Deleting default instance role", "role", DefaultInstanceRoleName)
+	deleted, err := v.instanceProfileWatcher.DeleteRole(ctx, DefaultInstanceRoleName, defaultInstanceRolePolicies)
+	switch {
+	case instanceprofiles.IsDeleteConflict(err):
+		prerequisites = append(prerequisites, Prerequisite{Kind: PrerequisiteInstanceRole, Name: DefaultInstanceRoleName, Status: PrerequisiteKept,
+			Reason: "VMs still use it, delete them first"})
+	case err != nil:
+		return prerequisites, err
+	default:
+		prerequisites = append(prerequisites, destroyed(PrerequisiteInstanceRole, DefaultInstanceRoleName, deleted))
+	}
+
+	logging.FromContext(ctx).Debug("Deleting event rule", "namespace", namespace)
+	if err := v.eventWatcher.Delete(ctx, namespace); err != nil {
+		return prerequisites, err
+	}
+	prerequisites = append(prerequisites, destroyed(PrerequisiteEventRule, events.Name(namespace), true))
+
+	for _, document := range sessions.Documents() {
+		prerequisites = append(prerequisites, Prerequisite{Kind: PrerequisiteDocument, Name: document, Status: PrerequisiteKept, Reason: "managed by AWS"})
+	}
+
+	logging.FromContext(ctx).Debug("Deleting artifacts bucket")
+	bucket, deleted, err := v.artifactWatcher.DeleteBucket(ctx, v.awsCfg.Region)
+	switch {
+	case artifacts.IsBucketNotEmpty(err):
+		prerequisites = append(prerequisites, Prerequisite{Kind: PrerequisiteBucket, Name: bucket, Status: PrerequisiteKept,
+			Reason: "it has artifacts, delete them to delete the bucket"})
+	case err != nil:
+		return prerequisites, err
+	default:
+		prerequisites = append(prerequisites, destroyed(PrerequisiteBucket, bucket, deleted))
+	}
+	return prerequisites, nil
+}
+
+// provisioned returns a prerequisite that Init created or that already existed
+func provisioned(kind, name string, created bool, reason string) Prerequisite {
+	status := PrerequisiteAvailable
+	if created {
+		status = PrerequisiteCreated
+	}
+	return Prerequisite{Kind: kind, Name: name, Status: status, Reason: reason}
+}
+
+// destroyed returns a prerequisite that DestroyInit deleted or that did not exist
+func destroyed(kind, name string, deleted bool) Prerequisite {
+	status := PrerequisiteNotFound
+	if deleted {
+		status = PrerequisiteDeleted
+	}
+	return Prerequisite{Kind: kind, Name: name, Status: status}
+}
@@ -0,0 +1,74 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
+)
+
+// instanceProfilePropagationDelay is how long to wait after creating an instance profile, EC2 rejects it until IAM propagated it
+const instanceProfilePropagationDelay = 10 * time.Second
+
+// resolveRole resolves the node group's IAM role so that a missing role fails the launch before anything is created
+func (v AWSVM) resolveRole(ctx context.Context, group plans.NodeGroup) (instanceprofiles.Role, error) {
+	if group.IAMRole == "" {
+		return instanceprofiles.Role{}, nil
+	}
+	logging.FromContext(ctx).Debug("Resolving IAM role", "group", group.Name, "role", group.IAMRole)
+	return v.instanceProfileWatcher.ResolveRole(ctx, group.IAMRole)
+}
+
+// ensureInstanceProfile returns the instance profile of the node group's role, creating it the first time the plan is launched with the role
+func (v AWSVM) ensureInstanceProfile(ctx context.Context, launchPlan plans.LaunchPlan, groupStatus plans.NodeGroupStatus) (instanceprofiles.InstanceProfile, error) {
+	if groupStatus.Role.RoleName == "" {
+		return instanceprofiles.InstanceProfile{}, nil
+	}
+	profile, created, err := v.instanceProfileWatcher.Ensure(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, groupStatus.Role)
+	if err != nil {
+		return profile, err
+	}
+	if created {
+		logging.FromContext(ctx).Debug("Created instance profile, waiting for it to propagate", "instance-profile", profile.InstanceProfileName, "role", groupStatus.Role.RoleName)
+		select {
+		case <-ctx.Done():
+			return profile, ctx.Err()
+		case <-time.After(instanceProfilePropagationDelay):
+		}
+	}
+	return profile, nil
+}
+
+// planInstanceProfile records the instance profile that a launch would create for the node group's role, or uses the existing one.
+// IAM has no dry-run, so the permission to create it is not checked.
+func (v AWSVM) planInstanceProfile(ctx context.Context, launchPlan *plans.LaunchPlan, index int) error {
+	groupStatus := launchPlan.Status.NodeGroups[index]
+	if groupStatus.Role.RoleName == "" {
+		return nil
+	}
+	profile, found, err := v.instanceProfileWatcher.Get(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, groupStatus.Role)
+	if err != nil {
+		return err
+	}
+	if found {
+		launchPlan.Status.NodeGroups[index].InstanceProfile = profile
+		return nil
+	}
+	planResource(launchPlan, "InstanceProfile", instanceprofiles.ProfileName(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, groupStatus.Role.RoleName), nil)
+	return nil
+}
+
+// deleteInstanceProfiles deletes the instance profiles that nimbus created for the plan, their roles are not deleted
+func (v AWSVM) deleteInstanceProfiles(ctx context.Context, deletionPlan *plans.DeletionPlan) error {
+	return deleteEach(ctx, "instance profile", "instance-profile", deletionPlan.Spec.InstanceProfiles,
+		func(profile instanceprofiles.InstanceProfile) string { return profile.InstanceProfileName }, &deletionPlan.Status.InstanceProfiles,
+		func(profile instanceprofiles.InstanceProfile) error {
+			if err := v.instanceProfileWatcher.Delete(ctx, profile); err != nil {
+				return fmt.Errorf("unable to delete instance profile: %w", err)
+			}
+			return nil
+		})
+}
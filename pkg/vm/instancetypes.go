@@ -0,0 +1,174 @@
+package vm
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/samber/lo"
+)
+
+// InstanceTypeDetails is an instance type with its hourly prices
+type InstanceTypeDetails struct {
+	instancetypes.InstanceType
+	// OnDemandPrice is 0 if the prices were not resolved or the Pricing API has no price for the instance type in the region
+	OnDemandPrice float64
+	// SpotPrice is the lowest spot price of the instance type's availability zones, 0 if the prices were not resolved or it has none
+	SpotPrice float64
+}
+
+// PrettyInstanceTypeDetails represents an instance type for UI elements like the static and TUI tables
+type PrettyInstanceTypeDetails struct {
+	InstanceType  string `table:"Instance-Type"`
+	VCPUs         int32  `table:"vCPUs"`
+	Memory        string `table:"Memory"`
+	Architectures string `table:"Arch"`
+	Network       string `table:"Network,wide"`
+	EBSBandwidth  string `table:"EBS-Bandwidth,wide"`
+	NVMe          string `table:"EBS-NVMe,wide"`
+	Accelerators  string `table:"Accelerators,wide"`
+	OnDemandPrice string `table:"On-Demand,wide"`
+	SpotPrice     string `table:"Spot,wide"`
+}
+
+// InstanceTypes returns the instance types that the selectors resolve to ordered by vCPUs, memory, and name.
+// withPrices resolves their Linux on-demand and lowest spot prices, which takes a Pricing API call per instance type.
+func (v AWSVM) InstanceTypes(ctx context.Context, selectors []instancetypes.Selector, withPrices bool) ([]InstanceTypeDetails, error) {
+	instanceTypeList, err := v.instanceTypeWatcher.Resolve(ctx, selectors)
+	if err != nil {
+		return nil, err
+	}
+	details := lo.Map(instanceTypeList, func(instanceType instancetypes.InstanceType, _ int) InstanceTypeDetails {
+		return InstanceTypeDetails{InstanceType: instanceType}
+	})
+	slices.SortFunc(details, func(a, b InstanceTypeDetails) int {
+		return cmp.Or(
+			cmp.Compare(lo.FromPtr(lo.FromPtr(a.VCpuInfo).DefaultVCpus), lo.FromPtr(lo.FromPtr(b.VCpuInfo).DefaultVCpus)),
+			cmp.Compare(lo.FromPtr(lo.FromPtr(a.MemoryInfo).SizeInMiB), lo.FromPtr(lo.FromPtr(b.MemoryInfo).SizeInMiB)),
+			cmp.Compare(a.InstanceType.InstanceType, b.InstanceType.InstanceType),
+		)
+	})
+	if !withPrices || len(details) == 0 {
+		return details, nil
+	}
+
+	names := lo.Map(details, func(instanceType InstanceTypeDetails, _ int) string {
+		return string(instanceType.InstanceType.InstanceType)
+	})
+	logging.FromContext(ctx).Debug("Resolving on-demand prices", "instance-types", len(names))
+	onDemandPrices, err := v.pricingWatcher.OnDemand(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("Resolving spot prices", "instance-types", len(names))
+	spotPrices, err := v.pricingWatcher.Spot(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	for i := range details {
+		name := string(details[i].InstanceType.InstanceType)
+		details[i].OnDemandPrice = onDemandPrices[name]
+		if zonePrices := lo.Values(spotPrices[name]); len(zonePrices) != 0 {
+			details[i].SpotPrice = lo.Min(zonePrices)
+		}
+	}
+	return details, nil
+}
+
+// Prettify converts the instance type into a PrettyInstanceTypeDetails
+func (d InstanceTypeDetails) Prettify() PrettyInstanceTypeDetails {
+	prettyDetails := PrettyInstanceTypeDetails{
+		InstanceType:  string(d.InstanceType.InstanceType),
+		Network:       "unknown",
+		EBSBandwidth:  "unknown",
+		NVMe:          "unknown",
+		Accelerators:  strings.Join(d.accelerators(), ", "),
+		OnDemandPrice: "unknown",
+		SpotPrice:     "none",
+	}
+	if d.VCpuInfo != nil {
+		prettyDetails.VCPUs = lo.FromPtr(d.VCpuInfo.DefaultVCpus)
+	}
+	if d.MemoryInfo != nil {
+		prettyDetails.Memory = pretty.Bytes(bytesize.ByteSize(lo.FromPtr(d.MemoryInfo.SizeInMiB)) << 20)
+	}
+	if d.ProcessorInfo != nil {
+		prettyDetails.Architectures = strings.Join(lo.Map(d.ProcessorInfo.SupportedArchitectures, func(arch ec2types.ArchitectureType, _ int) string { return string(arch) }), ",")
+	}
+	if d.NetworkInfo != nil && d.NetworkInfo.NetworkPerformance != nil {
+		prettyDetails.Network = *d.NetworkInfo.NetworkPerformance
+	}
+	if d.EbsInfo != nil {
+		prettyDetails.NVMe = string(d.EbsInfo.NvmeSupport)
+		if optimized := d.EbsInfo.EbsOptimizedInfo; optimized != nil && optimized.MaximumThroughputInMBps != nil {
+			maximum := ebsThroughput(*optimized.MaximumThroughputInMBps)
+			prettyDetails.EBSBandwidth = lo.Ternary(lo.FromPtr(optimized.BaselineThroughputInMBps) < *optimized.MaximumThroughputInMBps, "Up to "+maximum, maximum)
+		}
+	}
+	if prettyDetails.Accelerators == "" {
+		prettyDetails.Accelerators = "none"
+	}
+	if d.OnDemandPrice != 0 {
+		prettyDetails.OnDemandPrice = pretty.HourlyPrice(d.OnDemandPrice)
+	}
+	if d.SpotPrice != 0 {
+		prettyDetails.SpotPrice = pretty.HourlyPrice(d.SpotPrice)
+	}
+	return prettyDetails
+}
+
+// accelerators describes the GPUs, inference accelerators, Neuron devices, media accelerators, and FPGAs of the instance type,
+// e.g. 4x NVIDIA A10G 24 GiB
+func (d InstanceTypeDetails) accelerators() []string {
+	var accelerators []string
+	describe := func(count int32, manufacturer, name string, memoryMiB int32) {
+		accelerator := strings.TrimSpace(fmt.Sprintf("%dx %s %s", count, manufacturer, name))
+		if memoryMiB != 0 {
+			accelerator += " " + pretty.Bytes(bytesize.ByteSize(memoryMiB)<<20)
+		}
+		accelerators = append(accelerators, accelerator)
+	}
+	if d.GpuInfo != nil {
+		for _, gpu := range d.GpuInfo.Gpus {
+			describe(lo.FromPtr(gpu.Count), lo.FromPtr(gpu.Manufacturer), lo.FromPtr(gpu.Name), lo.FromPtr(lo.FromPtr(gpu.MemoryInfo).SizeInMiB))
+		}
+	}
+	if d.InferenceAcceleratorInfo != nil {
+		for _, accelerator := range d.InferenceAcceleratorInfo.Accelerators {
+			describe(lo.FromPtr(accelerator.Count), lo.FromPtr(accelerator.Manufacturer), lo.FromPtr(accelerator.Name), lo.FromPtr(lo.FromPtr(accelerator.MemoryInfo).SizeInMiB))
+		}
+	}
+	if d.NeuronInfo != nil {
+		for _, device := range d.NeuronInfo.NeuronDevices {
+			describe(lo.FromPtr(device.Count), "AWS", lo.FromPtr(device.Name), lo.FromPtr(lo.FromPtr(device.MemoryInfo).SizeInMiB))
+		}
+	}
+	if d.MediaAcceleratorInfo != nil {
+		for _, accelerator := range d.MediaAcceleratorInfo.Accelerators {
+			describe(lo.FromPtr(accelerator.Count), lo.FromPtr(accelerator.Manufacturer), lo.FromPtr(accelerator.Name), lo.FromPtr(lo.FromPtr(accelerator.MemoryInfo).SizeInMiB))
+		}
+	}
+	if d.FpgaInfo != nil {
+		for _, fpga := range d.FpgaInfo.Fpgas {
+			describe(lo.FromPtr(fpga.Count), lo.FromPtr(fpga.Manufacturer), lo.FromPtr(fpga.Name), lo.FromPtr(lo.FromPtr(fpga.MemoryInfo).SizeInMiB))
+		}
+	}
+	return accelerators
+}
+
+// ebsThroughput formats the EBS throughput of an instance type, which EC2 reports in MB/s
+func ebsThroughput(megabytesPerSecond float64) string {
+	return pretty.Bytes(bytesize.ByteSize(megabytesPerSecond*1e6)) + "/s"
+}
+
+// PrettifyInstanceTypeDetails converts instance types into PrettyInstanceTypeDetails
+func PrettifyInstanceTypeDetails(details []InstanceTypeDetails) []PrettyInstanceTypeDetails {
+	return lo.Map(details, func(d InstanceTypeDetails, _ int) PrettyInstanceTypeDetails { return d.Prettify() })
+}
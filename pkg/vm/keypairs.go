@@ -0,0 +1,57 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/keypairs"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// KeyPairs returns the key pairs that nimbus created or imported in the namespace and that match any of the selectors
+func (v AWSVM) KeyPairs(ctx context.Context, namespace string, selectorList []keypairs.Selector) ([]keypairs.KeyPair, error) {
+	if len(selectorList) == 0 {
+		selectorList = []keypairs.Selector{{}}
+	}
+	return v.keyPairWatcher.Resolve(ctx, lo.Map(selectorList, func(selector keypairs.Selector, _ int) keypairs.Selector {
+		selector.Tags = lo.Assign(selector.Tags, tagutils.NamespacedTags(namespace, ""))
+		return selector
+	}))
+}
+
+// CreateKeyPair creates a key pair in the namespace and returns it with its private key, which can not be retrieved again
+func (v AWSVM) CreateKeyPair(ctx context.Context, namespace, keyName, keyType string) (keypairs.KeyPair, error) {
+	if keyName == "" {
+		return keypairs.KeyPair{}, fmt.Errorf("a key name is required to create a key pair")
+	}
+	logging.FromContext(ctx).Debug("Creating key pair", "key-name", keyName, "key-type", keyType)
+	return v.keyPairWatcher.Create(ctx, keypairs.CreateKeyPairOptions{Name: keyName, Namespace: namespace, KeyType: keyType})
+}
+
+// ImportKeyPair imports a local OpenSSH public key file as a key pair in the namespace
+func (v AWSVM) ImportKeyPair(ctx context.Context, namespace, keyName, publicKeyPath string) (keypairs.KeyPair, error) {
+	if keyName == "" {
+		return keypairs.KeyPair{}, fmt.Errorf("a key name is required to import a key pair")
+	}
+	logging.FromContext(ctx).Debug("Importing key pair", "key-name", keyName, "public-key", publicKeyPath)
+	return v.keyPairWatcher.ImportFile(ctx, keypairs.CreateKeyPairOptions{Name: keyName, Namespace: namespace}, publicKeyPath)
+}
+
+// DeleteKeyPair deletes a key pair that nimbus created or imported in the namespace.
+// Key pairs that nimbus does not manage are never deleted since other tools may depend on them.
+func (v AWSVM) DeleteKeyPair(ctx context.Context, namespace, keyName string) (keypairs.KeyPair, error) {
+	keyPairs, err := v.KeyPairs(ctx, namespace, []keypairs.Selector{{Name: keyName}})
+	if err != nil {
+		return keypairs.KeyPair{}, err
+	}
+	if len(keyPairs) != 1 {
+		return keypairs.KeyPair{}, fmt.Errorf("expected 1 nimbus key pair named %s in namespace %s, but found %d", keyName, namespace, len(keyPairs))
+	}
+	logging.FromContext(ctx).Debug("Deleting key pair", "key-name", keyName, "key-pair-id", *keyPairs[0].KeyPairId)
+	if err := v.keyPairWatcher.Delete(ctx, *keyPairs[0].KeyPairId); err != nil {
+		return keypairs.KeyPair{}, err
+	}
+	return keyPairs[0], nil
+}
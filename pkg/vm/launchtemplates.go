@@ -0,0 +1,131 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/naming"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// ensureLaunchTemplate returns the node group's launch template with the options' data and the version that has it.
+// A launch template tagged with the options' spec hash is reused. Otherwise one is created, or if the plan's node group already has
+// a launch template with the name, e.g. with a naming template without the hash, a version is added to it when its latest data differs.
+func (v AWSVM) ensureLaunchTemplate(ctx context.Context, launchPlan plans.LaunchPlan, group plans.NodeGroup, createOpts launchtemplates.CreateLaunchTemplateOptions,
+	tags map[string]string) (launchtemplates.LaunchTemplate, int64, error) {
+	specHash := launchtemplates.SpecHash(createOpts)
+	logging.FromContext(ctx).Debug("Resolving Launch Template", "group", group.Name, "spec-hash", specHash)
+	launchTemplates, err := v.resolveNodeGroupLaunchTemplate(ctx, group, lo.Assign(tags, map[string]string{tagutils.SpecHashTagKey: specHash}))
+	if err != nil {
+		return launchtemplates.LaunchTemplate{}, 0, err
+	}
+	if len(launchTemplates) != 0 {
+		return launchTemplates[0], launchTemplateVersion(launchTemplates[0], specHash), nil
+	}
+
+	logging.FromContext(ctx).Debug("Creating Launch Template", "group", group.Name, "spec-hash", specHash)
+	createOpts.LaunchTemplateName, err = resourceName(launchPlan, naming.LaunchTemplate, group.Name, specHash)
+	if err != nil {
+		return launchtemplates.LaunchTemplate{}, 0, err
+	}
+	launchTemplateID, err := v.launchTemplateWatcher.CreateLaunchTemplate(ctx, createOpts)
+	if ec2utils.IsAlreadyExistsErr(err) {
+		return v.versionLaunchTemplate(ctx, launchPlan, group, createOpts)
+	}
+	if err != nil {
+		return launchtemplates.LaunchTemplate{}, 0, err
+	}
+	launchTemplates, err = v.launchTemplateWatcher.Resolve(ctx, []launchtemplates.Selector{{ID: launchTemplateID}})
+	if err != nil {
+		return launchtemplates.LaunchTemplate{}, 0, err
+	}
+	if len(launchTemplates) == 0 {
+		return launchtemplates.LaunchTemplate{}, 0, fmt.Errorf("could not find launch template details for launch template %s", launchTemplateID)
+	}
+	return launchTemplates[0], lo.FromPtr(launchTemplates[0].LatestVersionNumber), nil
+}
+
+// versionLaunchTemplate adds a version with the options' data to the existing launch template with their name if its latest version differs,
+// and tags it with the options' spec hash so that later launches of the spec reuse it. A concurrent launch of the same spec may have
+// created the launch template first, in which case its latest version already has the data.
+func (v AWSVM) versionLaunchTemplate(ctx context.Context, launchPlan plans.LaunchPlan, group plans.NodeGroup,
+	createOpts launchtemplates.CreateLaunchTemplateOptions) (launchtemplates.LaunchTemplate, int64, error) {
+	launchTemplate, found, err := v.namedLaunchTemplate(ctx, launchPlan, group, createOpts.LaunchTemplateName)
+	if err != nil {
+		return launchtemplates.LaunchTemplate{}, 0, err
+	}
+	if !found {
+		return launchtemplates.LaunchTemplate{}, 0, fmt.Errorf("launch template %s already exists but could not be found", createOpts.LaunchTemplateName)
+	}
+	version, created, err := v.launchTemplateWatcher.CreateLaunchTemplateVersion(ctx, lo.FromPtr(launchTemplate.LaunchTemplateId), createOpts)
+	if err != nil {
+		return launchtemplates.LaunchTemplate{}, 0, err
+	}
+	if !created {
+		logging.FromContext(ctx).Debug("Launch Template is up to date", "launch-template", createOpts.LaunchTemplateName, "version", lo.FromPtr(version.VersionNumber))
+		return launchTemplate, lo.FromPtr(version.VersionNumber), nil
+	}
+	logging.FromContext(ctx).Debug("Created Launch Template version", "launch-template", createOpts.LaunchTemplateName, "version", lo.FromPtr(version.VersionNumber))
+	specHash := launchtemplates.SpecHash(createOpts)
+	if err := v.tagWatcher.Tag(ctx, []string{lo.FromPtr(launchTemplate.LaunchTemplateId)}, map[string]string{tagutils.SpecHashTagKey: specHash}); err != nil {
+		return launchtemplates.LaunchTemplate{}, 0, err
+	}
+	launchTemplate.LatestVersionNumber = version.VersionNumber
+	launchTemplate.LaunchTemplateVersions = append(launchTemplate.LaunchTemplateVersions, version)
+	return launchTemplate, lo.FromPtr(version.VersionNumber), nil
+}
+
+// planLaunchTemplate checks the permission to create the options' launch template, or to add a version to the existing launch template with their name.
+// The existing launch template and the version with the options' data are returned if its latest version has the data, otherwise none is returned.
+func (v AWSVM) planLaunchTemplate(ctx context.Context, launchPlan *plans.LaunchPlan, group plans.NodeGroup,
+	createOpts launchtemplates.CreateLaunchTemplateOptions) ([]launchtemplates.LaunchTemplate, int64, error) {
+	launchTemplate, found, err := v.namedLaunchTemplate(ctx, *launchPlan, group, createOpts.LaunchTemplateName)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !found {
+		_, checkErr := v.launchTemplateWatcher.CreateLaunchTemplate(ctx, createOpts)
+		planResource(launchPlan, "LaunchTemplate", createOpts.LaunchTemplateName, checkErr)
+		return nil, 0, nil
+	}
+	version, created, checkErr := v.launchTemplateWatcher.CreateLaunchTemplateVersion(ctx, lo.FromPtr(launchTemplate.LaunchTemplateId), createOpts)
+	if checkErr == nil && !created {
+		return []launchtemplates.LaunchTemplate{launchTemplate}, lo.FromPtr(version.VersionNumber), nil
+	}
+	planResource(launchPlan, "LaunchTemplateVersion", createOpts.LaunchTemplateName, checkErr)
+	return nil, 0, nil
+}
+
+// namedLaunchTemplate returns the launch template with the name and its latest version, false if it does not exist.
+// A launch template with the name must belong to the plan's node group.
+func (v AWSVM) namedLaunchTemplate(ctx context.Context, launchPlan plans.LaunchPlan, group plans.NodeGroup, name string) (launchtemplates.LaunchTemplate, bool, error) {
+	launchTemplates, err := v.launchTemplateWatcher.Resolve(ctx, []launchtemplates.Selector{{Name: name, Version: launchtemplates.VersionLatest}})
+	if err != nil {
+		return launchtemplates.LaunchTemplate{}, false, err
+	}
+	if len(launchTemplates) == 0 {
+		return launchtemplates.LaunchTemplate{}, false, nil
+	}
+	tags := tagutils.EC2TagsToMap(launchTemplates[0].Tags)
+	if tags[tagutils.NamespaceTagKey] != launchPlan.Metadata.Namespace || tags[tagutils.NameTagKey] != launchPlan.Metadata.Name || tags[tagutils.GroupTagKey] != group.Name {
+		return launchtemplates.LaunchTemplate{}, false, fmt.Errorf("launch template %s already exists and belongs to another VM", name)
+	}
+	return launchTemplates[0], true, nil
+}
+
+// launchTemplateVersion returns the latest version of the launch template that was created with the spec hash.
+// Versions created before versions were described by their spec hash fall back to the latest version.
+func launchTemplateVersion(launchTemplate launchtemplates.LaunchTemplate, specHash string) int64 {
+	var version int64
+	for _, ltVersion := range launchTemplate.LaunchTemplateVersions {
+		if lo.FromPtr(ltVersion.VersionDescription) == specHash {
+			version = max(version, lo.FromPtr(ltVersion.VersionNumber))
+		}
+	}
+	return lo.CoalesceOrEmpty(version, lo.FromPtr(launchTemplate.LatestVersionNumber))
+}
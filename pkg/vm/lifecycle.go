@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// Stop stops the running instances of namespace/name that match any of the selectors, hibernating them if hibernate is true.
+// Instances that are already stopping or stopped are skipped.
+func (v AWSVM) Stop(ctx context.Context, namespace, name string, selectorList []instances.Selector, hibernate bool) ([]instances.Instance, error) {
+	return v.transitionInstances(ctx, namespace, name, selectorList, "stop", func(instanceID string) error {
+		return v.instanceWatcher.StopInstance(ctx, instanceID, hibernate)
+	}, ec2types.InstanceStateNamePending, ec2types.InstanceStateNameRunning)
+}
+
+// Start starts the stopped instances of namespace/name that match any of the selectors
+func (v AWSVM) Start(ctx context.Context, namespace, name string, selectorList []instances.Selector) ([]instances.Instance, error) {
+	return v.transitionInstances(ctx, namespace, name, selectorList, "start", func(instanceID string) error {
+		return v.instanceWatcher.StartInstance(ctx, instanceID)
+	}, ec2types.InstanceStateNameStopped)
+}
+
+// Reboot reboots the running instances of namespace/name that match any of the selectors
+func (v AWSVM) Reboot(ctx context.Context, namespace, name string, selectorList []instances.Selector) ([]instances.Instance, error) {
+	return v.transitionInstances(ctx, namespace, name, selectorList, "reboot", func(instanceID string) error {
+		return v.instanceWatcher.RebootInstance(ctx, instanceID)
+	}, ec2types.InstanceStateNameRunning)
+}
+
+// transitionInstances applies the action to every nimbus instance of namespace/name matching the selectors that is in one of the states.
+// The acted on instances are resolved again after the action so that their new state is returned.
+func (v AWSVM) transitionInstances(ctx context.Context, namespace, name string, selectorList []instances.Selector, action string, apply func(instanceID string) error, states ...ec2types.InstanceStateName) ([]instances.Instance, error) {
+	instanceList, err := v.targetInstances(ctx, namespace, name, selectorList, action, states...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, instance := range instanceList {
+		logging.FromContext(ctx).Debug("Transitioning instance", "action", action, "instance-id", *instance.InstanceId)
+		if err := apply(*instance.InstanceId); err != nil {
+			return nil, err
+		}
+		ids = append(ids, *instance.InstanceId)
+	}
+	return v.instanceWatcher.Resolve(ctx, lo.Map(ids, func(id string, _ int) instances.Selector {
+		return instances.Selector{ID: id}
+	}))
+}
+
+// targetInstances resolves the nimbus instances of namespace/name matching the selectors that are in one of the states.
+// An error is returned if there are no such instances, since there is nothing to act on.
+func (v AWSVM) targetInstances(ctx context.Context, namespace, name string, selectorList []instances.Selector, action string, states ...ec2types.InstanceStateName) ([]instances.Instance, error) {
+	if name == "" && len(selectorList) == 0 {
+		return nil, fmt.Errorf("a name or selectors are required to %s instances", action)
+	}
+	if len(selectorList) == 0 {
+		selectorList = []instances.Selector{{}}
+	}
+	// selectors only match instances created by nimbus in the namespace
+	selectorList = lo.Map(selectorList, func(selector instances.Selector, _ int) instances.Selector {
+		selector.Tags = lo.Assign(selector.Tags, tagutils.NamespacedTags(namespace, name))
+		return selector
+	})
+	instanceList, err := v.instanceWatcher.Resolve(ctx, selectorList)
+	if err != nil {
+		return nil, err
+	}
+	instanceList = lo.UniqBy(instanceList, func(instance instances.Instance) string { return *instance.InstanceId })
+	instanceList = lo.Filter(instanceList, func(instance instances.Instance, _ int) bool {
+		return instance.State != nil && lo.Contains(states, instance.State.Name)
+	})
+	if len(instanceList) == 0 {
+		return nil, fmt.Errorf("no instances to %s", action)
+	}
+	return instanceList, nil
+}
@@ -0,0 +1,80 @@
+package vm
+
+import (
+	"context"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/locks"
+)
+
+// lockRenewInterval is how often a held lock's lease is renewed, a fraction of the lease so a slow renewal does not let it expire
+const lockRenewInterval = locks.DefaultLease / 3
+
+// Lock acquires the advisory lock of namespace/name for the operation so that no other nimbus command changes it concurrently.
+// The lock's lease is renewed until the returned unlock func releases it, so an interrupted command's lock is stale after the lease.
+// If the lock is lost while it is held, because it was forced to unlock or could not be renewed before its lease expired,
+// renewing stops and unlock returns a locks.LostError since another operation may have changed namespace/name concurrently.
+// forceUnlock releases the lock of another operation first, e.g. when its holder crashed and the lease has not expired yet.
+func (v AWSVM) Lock(ctx context.Context, namespace, name, operation string, forceUnlock bool) (func() error, error) {
+	if forceUnlock {
+		forced, unlocked, err := v.lockWatcher.ForceUnlock(ctx, namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		if unlocked {
+			logging.FromContext(ctx).Warn("Forced the lock to unlock", "namespace", namespace, "name", name, "holder", forced.Holder, "operation", forced.Operation,
+				"acquired", forced.AcquiredAt.Format(time.RFC3339))
+		}
+	}
+	lock, err := v.lockWatcher.Acquire(ctx, namespace, name, operation, locks.DefaultLease)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("Acquired lock", "namespace", namespace, "name", name, "operation", operation, "parameter", locks.ParameterName(namespace, name))
+
+	renewCtx, stopRenewing := context.WithCancel(context.WithoutCancel(ctx))
+	renewed := make(chan struct{})
+	var lost error
+	go func() {
+		defer close(renewed)
+		ticker := time.NewTicker(lockRenewInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-renewCtx.Done():
+				return
+			case <-ticker.C:
+				renewedLock, err := v.lockWatcher.Renew(renewCtx, lock, locks.DefaultLease)
+				switch {
+				case err == nil:
+					lock = renewedLock
+				case renewCtx.Err() != nil:
+					return
+				case locks.IsLost(err):
+					logging.FromContext(ctx).Error("Lost lock, another operation may change the VM concurrently", "namespace", namespace, "name", name, "error", err)
+					lost = err
+					return
+				default:
+					// the renewal is retried on the next tick, the lock is lost if it is not renewed before its lease expires
+					logging.FromContext(ctx).Warn("Unable to renew lock", "namespace", namespace, "name", name, "error", err)
+				}
+			}
+		}
+	}()
+	return func() error {
+		stopRenewing()
+		<-renewed
+		// a lost lock is not released, it may be held by another operation
+		if lost != nil {
+			return lost
+		}
+		// the lock is released even if the command was interrupted
+		if err := v.lockWatcher.Release(context.WithoutCancel(ctx), lock); err != nil {
+			logging.FromContext(ctx).Warn("Unable to release lock, it is stale once its lease expires", "namespace", namespace, "name", name, "error", err)
+			return nil
+		}
+		logging.FromContext(ctx).Debug("Released lock", "namespace", namespace, "name", name)
+		return nil
+	}, nil
+}
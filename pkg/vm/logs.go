@@ -0,0 +1,140 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/logs"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// logsPollInterval is how often new log events are read when following, the CloudWatch agent ships every 5 seconds by default
+const logsPollInterval = 5 * time.Second
+
+// validateShipLogs checks that every node group has an IAM role to ship its logs with
+func validateShipLogs(spec plans.LaunchSpec, nodeGroups []plans.NodeGroup) error {
+	if spec.ShipLogs.LogGroup == "" {
+		return nil
+	}
+	for _, group := range nodeGroups {
+		if group.IAMRole == "" {
+			return fmt.Errorf("shipping logs requires an IAM role, the CloudWatch agent ships with the instances' credentials")
+		}
+	}
+	return nil
+}
+
+// ensureLogGroup creates the spec's log group if it does not exist. Log groups are shared by the VMs that ship to them,
+// so they are tagged with the namespace only and are not deleted with the VM.
+func (v AWSVM) ensureLogGroup(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	if launchPlan.Spec.ShipLogs.LogGroup == "" {
+		return nil
+	}
+	logging.FromContext(ctx).Debug("Ensuring log group", "log-group", launchPlan.Spec.ShipLogs.LogGroup)
+	logGroup, err := v.logWatcher.Ensure(ctx, launchPlan.Spec.ShipLogs, tagutils.NamespacedTags(launchPlan.Metadata.Namespace, ""))
+	if err != nil {
+		return err
+	}
+	launchPlan.Status.LogGroup = logGroup
+	return nil
+}
+
+// planLogGroup records the log group that a launch would create, or uses the existing one.
+// CloudWatch Logs has no dry-run, so the permission to create it is not checked.
+func (v AWSVM) planLogGroup(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	if launchPlan.Spec.ShipLogs.LogGroup == "" {
+		return nil
+	}
+	logGroup, found, err := v.logWatcher.Get(ctx, launchPlan.Spec.ShipLogs.LogGroup)
+	if err != nil {
+		return err
+	}
+	if found {
+		launchPlan.Status.LogGroup = logGroup
+		return nil
+	}
+	planResource(launchPlan, "LogGroup", launchPlan.Spec.ShipLogs.LogGroup, nil)
+	return nil
+}
+
+// allowLogShipping allows the instance profile's role to ship to the plan's log group with a policy that is deleted with the instance profile
+func (v AWSVM) allowLogShipping(ctx context.Context, launchPlan plans.LaunchPlan, profile instanceprofiles.InstanceProfile) error {
+	if launchPlan.Status.LogGroup.Arn == "" || profile.InstanceProfileName == "" {
+		return nil
+	}
+	logging.FromContext(ctx).Debug("Allowing the IAM role to ship logs", "instance-profile", profile.InstanceProfileName, "log-group", launchPlan.Status.LogGroup.LogGroupName)
+	return v.instanceProfileWatcher.PutPolicy(ctx, profile, logs.Policy(launchPlan.Status.LogGroup.Arn))
+}
+
+// logTags returns the tag of the log group that instances ship to, nil if the spec does not ship logs
+func logTags(launchPlan plans.LaunchPlan) map[string]string {
+	if launchPlan.Spec.ShipLogs.LogGroup == "" {
+		return nil
+	}
+	return map[string]string{tagutils.LogGroupTagKey: launchPlan.Spec.ShipLogs.LogGroup}
+}
+
+// Logs reads the logs that the name's instances shipped since the duration ago, from the log group they are tagged with.
+// Without follow the events are emitted and the channel is closed, otherwise new events are emitted until ctx is done.
+func (v AWSVM) Logs(ctx context.Context, namespace, name string, since time.Duration, follow bool) (<-chan logs.Event, error) {
+	instanceList, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{Tags: tagutils.NamespacedTags(namespace, name)}})
+	if err != nil {
+		return nil, err
+	}
+	logGroups := lo.Uniq(lo.FilterMap(instanceList, func(instance instances.Instance, _ int) (string, bool) {
+		logGroup, ok := tagutils.EC2TagsToMap(instance.Tags)[tagutils.LogGroupTagKey]
+		return logGroup, ok
+	}))
+	if len(logGroups) == 0 {
+		return nil, fmt.Errorf("%s/%s does not ship its logs, launch it with --ship-logs", namespace, name)
+	}
+	if len(logGroups) > 1 {
+		logging.FromContext(ctx).Warn("Instances ship to different log groups, only reading the first", "log-groups", logGroups)
+	}
+
+	eventsChan := make(chan logs.Event)
+	go func() {
+		defer close(eventsChan)
+		start := time.Now().Add(-since)
+		// events at the start time of the next read were already emitted, the start time is inclusive
+		emitted := map[string]bool{}
+		for {
+			events, err := v.logWatcher.Events(ctx, logGroups[0], logs.StreamPrefix(namespace, name), start)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logging.FromContext(ctx).Error("Unable to read logs", "error", err)
+			}
+			for _, event := range events {
+				if emitted[event.ID] {
+					continue
+				}
+				if event.Time.After(start) {
+					start, emitted = event.Time, map[string]bool{}
+				}
+				emitted[event.ID] = true
+				select {
+				case <-ctx.Done():
+					return
+				case eventsChan <- event:
+				}
+			}
+			if !follow && err == nil {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(logsPollInterval):
+			}
+		}
+	}()
+	return eventsChan, nil
+}
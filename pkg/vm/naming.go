@@ -0,0 +1,43 @@
+package vm
+
+import (
+	"strings"
+
+	"github.com/bwagner5/nimbus/pkg/naming"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+)
+
+// resourceName renders the AWS name of a resource created for the plan, and optionally a node group, with the plan's naming strategy.
+// hash is the resource's spec hash, if it has one.
+func resourceName(launchPlan plans.LaunchPlan, resourceType naming.ResourceType, group, hash string) (string, error) {
+	strategy, err := naming.NewStrategy(launchPlan.Spec.Naming)
+	if err != nil {
+		return "", err
+	}
+	return strategy.Name(resourceType, naming.Fields{
+		Namespace: launchPlan.Metadata.Namespace,
+		Name:      launchPlan.Metadata.Name,
+		Group:     group,
+		Hash:      hash,
+	})
+}
+
+// validateNaming renders every name the plan could create so that an invalid naming strategy fails before any resources are created
+func validateNaming(launchPlan plans.LaunchPlan, nodeGroups []plans.NodeGroup) error {
+	placeholderHashes := map[naming.ResourceType]string{
+		naming.LaunchTemplate: strings.Repeat("0", launchtemplates.SpecHashLength),
+		naming.SecurityGroup:  "",
+	}
+	for resourceType, hash := range placeholderHashes {
+		if _, err := resourceName(launchPlan, resourceType, "", hash); err != nil {
+			return err
+		}
+		for _, group := range nodeGroups {
+			if _, err := resourceName(launchPlan, resourceType, group.Name, hash); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
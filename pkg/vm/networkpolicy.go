@@ -0,0 +1,109 @@
+package vm
+
+import (
+	"context"
+
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/eips"
+	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
+	"github.com/bwagner5/nimbus/pkg/providers/routetables"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// networkOwnership returns the name and additional tags that network infrastructure is created with for the network policy.
+// Shared networks belong to the namespace rather than a name, so they are not resolved by a name's deletion plan.
+func networkOwnership(policy, namespace, name string) (string, map[string]string) {
+	if policy == plans.NetworkPolicyIsolated {
+		return name, map[string]string{tagutils.NetworkPolicyTagKey: plans.NetworkPolicyIsolated}
+	}
+	return "", map[string]string{
+		"Name":                       namespace,
+		tagutils.NetworkPolicyTagKey: plans.NetworkPolicyShared,
+	}
+}
+
+// sharedNetworkTags returns the tags of a namespace's shared network infrastructure
+func sharedNetworkTags(namespace string) map[string]string {
+	return map[string]string{
+		tagutils.NamespaceTagKey:     namespace,
+		tagutils.NetworkPolicyTagKey: plans.NetworkPolicyShared,
+	}
+}
+
+// resolveNetworkPolicyVPCs returns the existing VPCs that a launch with the network policy should reuse.
+// VPCs created before network policies existed are only tagged with the namespace and are treated as shared.
+func (v AWSVM) resolveNetworkPolicyVPCs(ctx context.Context, policy, namespace, name string) ([]vpcs.VPC, error) {
+	if policy == plans.NetworkPolicyIsolated {
+		return v.vpcWatcher.Resolve(ctx, []vpcs.Selector{{
+			Tags: lo.Assign(tagutils.NamespacedTags(namespace, name), map[string]string{tagutils.NetworkPolicyTagKey: plans.NetworkPolicyIsolated}),
+		}})
+	}
+	namespaceVPCs, err := v.vpcWatcher.Resolve(ctx, []vpcs.Selector{{
+		Tags: map[string]string{tagutils.NamespaceTagKey: namespace},
+	}})
+	if err != nil {
+		return nil, err
+	}
+	sharedVPCs := lo.Filter(namespaceVPCs, func(vpc vpcs.VPC, _ int) bool {
+		return tagutils.EC2TagsToMap(vpc.Tags)[tagutils.NetworkPolicyTagKey] == plans.NetworkPolicyShared
+	})
+	legacyVPCs := lo.Filter(namespaceVPCs, func(vpc vpcs.VPC, _ int) bool {
+		_, ok := tagutils.EC2TagsToMap(vpc.Tags)[tagutils.NetworkPolicyTagKey]
+		return !ok
+	})
+	return append(sharedVPCs, legacyVPCs...), nil
+}
+
+// planSharedNetworkDeletion adds the namespace's shared network to the deletion plan.
+// The shared network is excluded again by excludeInUseResources if other names still use it.
+func (v AWSVM) planSharedNetworkDeletion(ctx context.Context, deletionPlan *plans.DeletionPlan) error {
+	tags := sharedNetworkTags(deletionPlan.Metadata.Namespace)
+	sharedVPCs, err := v.vpcWatcher.Resolve(ctx, []vpcs.Selector{{Tags: tags}})
+	if err != nil {
+		return err
+	}
+	for _, vpc := range sharedVPCs {
+		sharedSubnets, err := v.subnetWatcher.Resolve(ctx, []subnets.Selector{{VPCID: *vpc.VpcId, Tags: tags}})
+		if err != nil {
+			return err
+		}
+		sharedIGWs, err := v.igwWatcher.Resolve(ctx, []igws.Selector{{VPCID: *vpc.VpcId, Tags: tags}})
+		if err != nil {
+			return err
+		}
+		sharedEgressOnlyIGWs, err := v.igwWatcher.ResolveEgressOnly(ctx, tags, *vpc.VpcId)
+		if err != nil {
+			return err
+		}
+		sharedRouteTables, err := v.routeTableWatcher.Resolve(ctx, []routetables.Selector{{VPCID: *vpc.VpcId, Tags: tags}})
+		if err != nil {
+			return err
+		}
+		sharedNATGateways, err := v.natGatewayWatcher.Resolve(ctx, []natgws.Selector{{VPCID: *vpc.VpcId, Tags: tags}})
+		if err != nil {
+			return err
+		}
+		deletionPlan.Spec.VPCs = append(deletionPlan.Spec.VPCs, vpc)
+		deletionPlan.Spec.NATGateways = append(deletionPlan.Spec.NATGateways, lo.Reject(sharedNATGateways, func(natGateway natgws.NATGateway, _ int) bool {
+			return natGateway.IsDeleted()
+		})...)
+		deletionPlan.Spec.Subnets = append(deletionPlan.Spec.Subnets, sharedSubnets...)
+		deletionPlan.Spec.InternetGateways = append(deletionPlan.Spec.InternetGateways, sharedIGWs...)
+		deletionPlan.Spec.EgressOnlyInternetGateways = append(deletionPlan.Spec.EgressOnlyInternetGateways, sharedEgressOnlyIGWs...)
+		deletionPlan.Spec.RouteTables = append(deletionPlan.Spec.RouteTables, sharedRouteTables...)
+	}
+	if len(sharedVPCs) == 0 {
+		return nil
+	}
+	// the Elastic IPs of the shared network's NAT Gateways are tagged with the network, not a VPC
+	sharedEIPs, err := v.eipWatcher.Resolve(ctx, []eips.Selector{{Tags: tags}})
+	if err != nil {
+		return err
+	}
+	deletionPlan.Spec.ElasticIPs = lo.UniqBy(append(deletionPlan.Spec.ElasticIPs, sharedEIPs...), func(eip eips.ElasticIP) string { return *eip.AllocationId })
+	return nil
+}
@@ -0,0 +1,209 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/progress"
+	"github.com/bwagner5/nimbus/pkg/providers/azs"
+	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/samber/lo"
+)
+
+// validateNetworkTopology returns an error if the topology is not public, private, or public-private
+func validateNetworkTopology(topology string) error {
+	switch topology {
+	case plans.NetworkTopologyPublic, plans.NetworkTopologyPrivate, plans.NetworkTopologyPublicPrivate:
+		return nil
+	}
+	return fmt.Errorf("invalid network topology %q, must be %s, %s, or %s", topology,
+		plans.NetworkTopologyPublic, plans.NetworkTopologyPrivate, plans.NetworkTopologyPublicPrivate)
+}
+
+// hasPrivateSubnets returns true if the topology needs private subnets and a NAT Gateway
+func hasPrivateSubnets(topology string) bool {
+	return topology == plans.NetworkTopologyPrivate || topology == plans.NetworkTopologyPublicPrivate
+}
+
+// validateIPFamily returns an error if the network topology can not be created with the IP family of the network spec.
+// Private subnets reach the internet through a NAT Gateway, which needs a public IPv4 subnet, so IPv6-only networks are public.
+func validateIPFamily(network plans.NetworkSpec, topology string) error {
+	if network.WithDefaults().IPFamily == plans.IPFamilyIPv6 && hasPrivateSubnets(topology) {
+		return fmt.Errorf("the %s network topology is not supported by the %s IP family, use %s or the %s network topology",
+			topology, plans.IPFamilyIPv6, plans.IPFamilyDualStack, plans.NetworkTopologyPublic)
+	}
+	return nil
+}
+
+// hasIPv6Subnets returns true if any of the subnets has an IPv6 CIDR block
+func hasIPv6Subnets(subnetList []subnets.Subnet) bool {
+	return lo.SomeBy(subnetList, func(subnet subnets.Subnet) bool { return subnet.IPv6CIDR() != "" })
+}
+
+// isPublicSubnet returns true if instances launched into the subnet get a public IP
+func isPublicSubnet(subnet subnets.Subnet) bool {
+	return subnet.IsPublic()
+}
+
+// topologySubnets returns the subnets of a network created by nimbus that instances are launched into for the topology
+func topologySubnets(topology string, subnetList []subnets.Subnet) []subnets.Subnet {
+	if topology == plans.NetworkTopologyPrivate {
+		return lo.Reject(subnetList, func(subnet subnets.Subnet, _ int) bool { return isPublicSubnet(subnet) })
+	}
+	return lo.Filter(subnetList, func(subnet subnets.Subnet, _ int) bool { return isPublicSubnet(subnet) })
+}
+
+// networkAZs returns the availability zones of the region that a network created with the network spec spans
+func (v AWSVM) networkAZs(ctx context.Context, network plans.NetworkSpec) ([]azs.AvailabilityZone, error) {
+	availabilityZones, err := v.azWatcher.Resolve(ctx, []azs.Selector{{Region: v.awsCfg.Region}})
+	if err != nil {
+		return nil, err
+	}
+	if len(network.AZs) == 0 {
+		azCount := network.WithDefaults().AZCount
+		// the default AZ count spans fewer availability zones in smaller regions, an explicit one must be met
+		if network.AZCount != 0 && len(availabilityZones) < azCount {
+			return nil, fmt.Errorf("region %s has %d availability zones, %d were requested", v.awsCfg.Region, len(availabilityZones), azCount)
+		}
+		return lo.Subset(availabilityZones, 0, uint(azCount)), nil
+	}
+	var networkAZs []azs.AvailabilityZone
+	for _, zone := range network.AZs {
+		az, ok := lo.Find(availabilityZones, func(az azs.AvailabilityZone) bool {
+			return lo.FromPtr(az.ZoneName) == zone || lo.FromPtr(az.ZoneId) == zone
+		})
+		if !ok {
+			return nil, fmt.Errorf("availability zone %s not found in region %s", zone, v.awsCfg.Region)
+		}
+		networkAZs = append(networkAZs, az)
+	}
+	return networkAZs, nil
+}
+
+// publicSubnetSpecs returns the public subnet of every availability zone of a network created with the network spec
+func publicSubnetSpecs(network plans.NetworkSpec, availabilityZones []azs.AvailabilityZone) ([]subnets.SubnetSpec, error) {
+	var subnetSpecs []subnets.SubnetSpec
+	for i, az := range availabilityZones {
+		cidr, err := network.SubnetCIDR(i, false)
+		if err != nil {
+			return nil, err
+		}
+		subnetSpecs = append(subnetSpecs, subnets.SubnetSpec{AZ: *az.ZoneName, CIDR: cidr, Public: true})
+	}
+	return subnetSpecs, nil
+}
+
+// withIPv6CIDRs returns the subnet specs with the IPv6 CIDR blocks of their index in the IPv6 CIDR block of the VPC.
+// The subnet specs of an IPv6-only network do not get an IPv4 CIDR block.
+func withIPv6CIDRs(subnetSpecs []subnets.SubnetSpec, vpcIPv6CIDR string, private bool, ipv6Only bool) ([]subnets.SubnetSpec, error) {
+	for i := range subnetSpecs {
+		ipv6CIDR, err := plans.IPv6SubnetCIDR(vpcIPv6CIDR, i, private)
+		if err != nil {
+			return nil, err
+		}
+		subnetSpecs[i].IPv6CIDR = ipv6CIDR
+		subnetSpecs[i].IPv6Only = ipv6Only
+	}
+	return subnetSpecs, nil
+}
+
+// addPrivateSubnets creates a private subnet in the availability zone of every public subnet of the network, a NAT Gateway in a public subnet,
+// and a private route table that routes the internet traffic of the private subnets through the NAT Gateway.
+// If the VPC has an IPv6 CIDR block, the private subnets also get IPv6 CIDR blocks and route their IPv6 traffic through a new Egress-Only Internet Gateway.
+// It returns the network's subnets including the private subnets, and does nothing if the network already has private subnets.
+func (v AWSVM) addPrivateSubnets(ctx context.Context, launchPlan *plans.LaunchPlan, vpc *vpcs.VPC, subnetList []subnets.Subnet, networkName string, networkTags map[string]string) ([]subnets.Subnet, error) {
+	publicSubnets := lo.Filter(subnetList, func(subnet subnets.Subnet, _ int) bool { return isPublicSubnet(subnet) })
+	if len(publicSubnets) != len(subnetList) {
+		return subnetList, v.resolveNATGateway(ctx, launchPlan, *vpc)
+	}
+	if len(publicSubnets) == 0 {
+		return nil, fmt.Errorf("no public subnets in VPC %s to create a NAT Gateway in", *vpc.VpcId)
+	}
+	if publicSubnets[0].CidrBlock == nil {
+		return nil, fmt.Errorf("VPC %s is an IPv6-only network, it can not have private subnets", *vpc.VpcId)
+	}
+	// the private subnets are laid out in the second half of the VPC CIDR with the size of the public subnets, so that networks created
+	// with a different network spec are extended consistently
+	publicPrefix, err := netip.ParsePrefix(lo.FromPtr(publicSubnets[0].CidrBlock))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the CIDR of subnet %s: %w", *publicSubnets[0].SubnetId, err)
+	}
+	network := plans.NetworkSpec{VPCCIDR: lo.FromPtr(vpc.CidrBlock), SubnetPrefixLength: publicPrefix.Bits(), AZCount: len(publicSubnets)}
+	var subnetSpecs []subnets.SubnetSpec
+	for i, subnet := range publicSubnets {
+		cidr, err := network.SubnetCIDR(i, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lay out the private subnets of VPC %s: %w", *vpc.VpcId, err)
+		}
+		subnetSpecs = append(subnetSpecs, subnets.SubnetSpec{AZ: *subnet.AvailabilityZone, CIDR: cidr})
+	}
+	if vpc.IPv6CIDR() != "" {
+		if subnetSpecs, err = withIPv6CIDRs(subnetSpecs, vpc.IPv6CIDR(), true, false); err != nil {
+			return nil, fmt.Errorf("failed to lay out the private subnets of VPC %s: %w", *vpc.VpcId, err)
+		}
+	}
+
+	logging.FromContext(ctx).Debug("Creating private subnets")
+	privateSubnets, err := v.subnetWatcher.Create(ctx, launchPlan.Metadata.Namespace, networkName, vpc, subnetSpecs, networkTags)
+	if err != nil {
+		return nil, err
+	}
+	subnetList = append(subnetList, privateSubnets...)
+
+	logging.FromContext(ctx).Debug("Creating NAT Gateway")
+	done := progress.FromContext(ctx).Track("Creating NAT Gateway", expectedNATGatewayDuration)
+	natGateway, err := v.natGatewayWatcher.Create(ctx, launchPlan.Metadata.Namespace, networkName, subnetList, networkTags)
+	done()
+	if natGateway != nil {
+		launchPlan.Status.NATGateway = *natGateway
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var egressOnlyIGW *igws.EgressOnlyInternetGateway
+	if vpc.IPv6CIDR() != "" {
+		logging.FromContext(ctx).Debug("Creating Egress-Only Internet Gateway")
+		egressOnlyIGW, err = v.igwWatcher.CreateEgressOnly(ctx, launchPlan.Metadata.Namespace, networkName, *vpc, networkTags)
+		if err != nil {
+			return nil, err
+		}
+		launchPlan.Status.EgressOnlyInternetGateway = *egressOnlyIGW
+	}
+
+	logging.FromContext(ctx).Debug("Creating private route table")
+	_, privateRouteTable, err := v.routeTableWatcher.Create(ctx, launchPlan.Metadata.Namespace, networkName, privateSubnets, nil, natGateway, egressOnlyIGW, networkTags)
+	if err != nil {
+		return nil, err
+	}
+	launchPlan.Status.RouteTables = append(launchPlan.Status.RouteTables, *privateRouteTable)
+	return subnetList, nil
+}
+
+// resolveNATGateway records the NAT Gateway and Egress-Only Internet Gateway of an existing network with private subnets in the launch status
+func (v AWSVM) resolveNATGateway(ctx context.Context, launchPlan *plans.LaunchPlan, vpc vpcs.VPC) error {
+	natGateways, err := v.natGatewayWatcher.Resolve(ctx, []natgws.Selector{{VPCID: *vpc.VpcId}})
+	if err != nil {
+		return err
+	}
+	if natGateway, ok := lo.Find(natGateways, func(natGateway natgws.NATGateway) bool { return !natGateway.IsDeleted() }); ok {
+		launchPlan.Status.NATGateway = natGateway
+	}
+	if vpc.IPv6CIDR() == "" {
+		return nil
+	}
+	egressOnlyIGWs, err := v.igwWatcher.ResolveEgressOnly(ctx, nil, *vpc.VpcId)
+	if err != nil {
+		return err
+	}
+	if len(egressOnlyIGWs) != 0 {
+		launchPlan.Status.EgressOnlyInternetGateway = egressOnlyIGWs[0]
+	}
+	return nil
+}
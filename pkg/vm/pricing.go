@@ -0,0 +1,126 @@
+package vm
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/bwagner5/nimbus/pkg/bytesize"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/pretty"
+	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/samber/lo"
+)
+
+// InstanceTypePrice is the hourly on-demand price of an instance type and its spot price in an availability zone
+type InstanceTypePrice struct {
+	InstanceType string
+	// AvailabilityZone is empty if the instance type has no spot price in any availability zone
+	AvailabilityZone string
+	VCPUs            int32
+	MemoryMiB        int64
+	// OnDemandPrice is 0 if the Pricing API has no price for the instance type in the region
+	OnDemandPrice float64
+	// SpotPrice is 0 if the instance type has no spot price in the availability zone
+	SpotPrice float64
+}
+
+// PrettyInstanceTypePrice represents an instance type price for UI elements like the static and TUI tables
+type PrettyInstanceTypePrice struct {
+	InstanceType     string `table:"Instance-Type"`
+	AvailabilityZone string `table:"Zone"`
+	VCPUs            int32  `table:"vCPUs"`
+	Memory           string `table:"Memory"`
+	OnDemandPrice    string `table:"On-Demand"`
+	SpotPrice        string `table:"Spot"`
+	SpotSavings      string `table:"Spot-Savings"`
+	MonthlyOnDemand  string `table:"Monthly-On-Demand,wide"`
+	MonthlySpot      string `table:"Monthly-Spot,wide"`
+}
+
+// Prices returns the on-demand and spot prices of the instance types that the selectors resolve to, with a price per availability zone.
+// Prices are for Linux instances. The cheapest instance types by on-demand price are first, followed by those without an on-demand price.
+func (v AWSVM) Prices(ctx context.Context, selectors []instancetypes.Selector) ([]InstanceTypePrice, error) {
+	instanceTypeList, err := v.instanceTypeWatcher.Resolve(ctx, selectors)
+	if err != nil {
+		return nil, err
+	}
+	if len(instanceTypeList) == 0 {
+		return nil, nil
+	}
+	names := lo.Map(instanceTypeList, func(instanceType instancetypes.InstanceType, _ int) string { return string(instanceType.InstanceType) })
+	logging.FromContext(ctx).Debug("Resolving on-demand prices", "instance-types", len(names))
+	onDemandPrices, err := v.pricingWatcher.OnDemand(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Debug("Resolving spot prices", "instance-types", len(names))
+	spotPrices, err := v.pricingWatcher.Spot(ctx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	var prices []InstanceTypePrice
+	for _, instanceType := range instanceTypeList {
+		price := InstanceTypePrice{
+			InstanceType:  string(instanceType.InstanceType),
+			VCPUs:         lo.FromPtr(instanceType.VCpuInfo.DefaultVCpus),
+			MemoryMiB:     lo.FromPtr(instanceType.MemoryInfo.SizeInMiB),
+			OnDemandPrice: onDemandPrices[string(instanceType.InstanceType)],
+		}
+		zonePrices := spotPrices[price.InstanceType]
+		if len(zonePrices) == 0 {
+			prices = append(prices, price)
+			continue
+		}
+		for zone, spotPrice := range zonePrices {
+			price.AvailabilityZone = zone
+			price.SpotPrice = spotPrice
+			prices = append(prices, price)
+		}
+	}
+	slices.SortFunc(prices, func(a, b InstanceTypePrice) int {
+		if (a.OnDemandPrice == 0) != (b.OnDemandPrice == 0) {
+			return lo.Ternary(a.OnDemandPrice == 0, 1, -1)
+		}
+		return cmp.Or(
+			cmp.Compare(a.OnDemandPrice, b.OnDemandPrice),
+			cmp.Compare(a.InstanceType, b.InstanceType),
+			cmp.Compare(a.AvailabilityZone, b.AvailabilityZone),
+		)
+	})
+	return prices, nil
+}
+
+// Prettify converts the price into a PrettyInstanceTypePrice
+func (p InstanceTypePrice) Prettify() PrettyInstanceTypePrice {
+	prettyPrice := PrettyInstanceTypePrice{
+		InstanceType:     p.InstanceType,
+		AvailabilityZone: lo.Ternary(p.AvailabilityZone == "", "none", p.AvailabilityZone),
+		VCPUs:            p.VCPUs,
+		Memory:           pretty.Bytes(bytesize.ByteSize(p.MemoryMiB) << 20),
+		OnDemandPrice:    "unknown",
+		SpotPrice:        "none",
+		SpotSavings:      "unknown",
+		MonthlyOnDemand:  "unknown",
+		MonthlySpot:      "none",
+	}
+	if p.OnDemandPrice != 0 {
+		prettyPrice.OnDemandPrice = pretty.HourlyPrice(p.OnDemandPrice)
+		prettyPrice.MonthlyOnDemand = pretty.MonthlyPrice(p.OnDemandPrice * hoursPerMonth)
+	}
+	if p.SpotPrice != 0 {
+		prettyPrice.SpotPrice = pretty.HourlyPrice(p.SpotPrice)
+		prettyPrice.MonthlySpot = pretty.MonthlyPrice(p.SpotPrice * hoursPerMonth)
+	}
+	if p.OnDemandPrice != 0 && p.SpotPrice != 0 {
+		prettyPrice.SpotSavings = fmt.Sprintf("%.0f%%", 100*(p.OnDemandPrice-p.SpotPrice)/p.OnDemandPrice)
+	}
+	return prettyPrice
+}
+
+// PrettifyInstanceTypePrices converts prices into PrettyInstanceTypePrices
+func PrettifyInstanceTypePrices(prices []InstanceTypePrice) []PrettyInstanceTypePrice {
+	return lo.Map(prices, func(p InstanceTypePrice, _ int) PrettyInstanceTypePrice { return p.Prettify() })
+}
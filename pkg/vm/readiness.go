@@ -0,0 +1,114 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/userdata"
+	"github.com/samber/lo"
+)
+
+const (
+	// defaultReadinessTimeout is how long to wait for a node group to become ready when its probe does not specify a timeout
+	defaultReadinessTimeout = 10 * time.Minute
+	// readinessProbeInterval is how often a readiness probe's TCP port is dialed
+	readinessProbeInterval = 5 * time.Second
+)
+
+// userDataMetadata returns the metadata that the group's user-data is rendered with, without the metadata of the groups it depends on
+func userDataMetadata(launchPlan plans.LaunchPlan, group plans.NodeGroup) userdata.Metadata {
+	return userdata.Metadata{
+		Namespace: launchPlan.Metadata.Namespace,
+		Name:      launchPlan.Metadata.Name,
+		Group:     group.Name,
+		Region:    launchPlan.Status.Region,
+		Vars:      launchPlan.Spec.UserDataVars,
+	}
+}
+
+// renderDependentUserData waits for the group's dependencies to be ready and renders the group's user-data with their metadata.
+// The dependencies' status in the launch plan is updated with their readiness and refreshed instances.
+func (v AWSVM) renderDependentUserData(ctx context.Context, launchPlan *plans.LaunchPlan, nodeGroups []plans.NodeGroup, group plans.NodeGroup) (string, error) {
+	metadata := userDataMetadata(*launchPlan, group)
+	metadata.Groups = map[string]userdata.GroupMetadata{}
+	for _, dependency := range group.DependsOn {
+		_, i, ok := lo.FindIndexOf(nodeGroups, func(nodeGroup plans.NodeGroup) bool { return nodeGroup.Name == dependency })
+		if !ok {
+			return "", fmt.Errorf("node group %s depends on unknown node group %s", group.Name, dependency)
+		}
+		if !launchPlan.Status.NodeGroups[i].Ready {
+			logging.FromContext(ctx).Debug("Waiting for node group to be ready", "group", dependency, "dependent", group.Name)
+			groupStatus, err := v.waitForNodeGroupReady(ctx, nodeGroups[i], launchPlan.Status.NodeGroups[i])
+			launchPlan.Status.NodeGroups[i] = groupStatus
+			if err != nil {
+				return "", fmt.Errorf("node group %s is not ready: %w", dependency, err)
+			}
+		}
+		metadata.Groups[dependency] = userdata.NewGroupMetadata(launchPlan.Status.NodeGroups[i].Instances)
+	}
+	return userdata.Render(group.UserData, metadata)
+}
+
+// waitForNodeGroupReady waits for the group's instances to pass EC2 status checks and the group's readiness probe
+func (v AWSVM) waitForNodeGroupReady(ctx context.Context, group plans.NodeGroup, groupStatus plans.NodeGroupStatus) (plans.NodeGroupStatus, error) {
+	timeout := group.ReadinessProbe.Timeout
+	if timeout == 0 {
+		timeout = defaultReadinessTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	instanceIDs := lo.Map(groupStatus.Instances, func(instance instances.Instance, _ int) string { return *instance.InstanceId })
+	if err := v.instanceWatcher.WaitForStatusOK(ctx, instanceIDs, timeout); err != nil {
+		return groupStatus, err
+	}
+
+	// Refresh the instances to pick up addresses that were assigned after launch
+	refreshedInstances, err := v.instanceWatcher.Resolve(ctx, lo.Map(instanceIDs, func(id string, _ int) instances.Selector {
+		return instances.Selector{ID: id}
+	}))
+	if err != nil {
+		return groupStatus, err
+	}
+	groupStatus.Instances = refreshedInstances
+
+	if group.ReadinessProbe.Port != 0 {
+		for _, instance := range groupStatus.Instances {
+			ip := lo.FromPtr(instance.PublicIpAddress)
+			if ip == "" {
+				ip = lo.FromPtr(instance.PrivateIpAddress)
+			}
+			address := net.JoinHostPort(ip, strconv.Itoa(int(group.ReadinessProbe.Port)))
+			logging.FromContext(ctx).Debug("Probing instance", "instance", *instance.InstanceId, "address", address)
+			if err := probeTCP(ctx, address); err != nil {
+				return groupStatus, err
+			}
+		}
+	}
+	groupStatus.Ready = true
+	return groupStatus, nil
+}
+
+// probeTCP dials the address until it accepts a connection or ctx is done
+func probeTCP(ctx context.Context, address string) error {
+	dialer := net.Dialer{Timeout: readinessProbeInterval}
+	ticker := time.NewTicker(readinessProbeInterval)
+	defer ticker.Stop()
+	for {
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err == nil {
+			return conn.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s did not accept connections: %w", address, err)
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/progress"
+	"github.com/bwagner5/nimbus/pkg/providers/fleets"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// reconcileGroup returns the node group reduced to the instances it is missing and false if none of its instances are launched.
+// An update only creates launch templates, and placements are launched for the missing placement indexes.
+func reconcileGroup(launchPlan plans.LaunchPlan, group plans.NodeGroup) (plans.NodeGroup, bool) {
+	reconciliation := launchPlan.Status.Reconciliation
+	missing := reconciliation.Missing[group.Name]
+	if reconciliation.Action == plans.ReconcileUpdate || missing == 0 {
+		return group, false
+	}
+	if group.Capacity.Value == 0 && len(launchPlan.Spec.Placements) == 0 {
+		group.Count = missing
+	}
+	return group, true
+}
+
+// upToDateInstances returns the existing instances of the node group that were launched with the spec
+func upToDateInstances(launchPlan plans.LaunchPlan, group plans.NodeGroup) []instances.Instance {
+	return lo.Filter(launchPlan.Status.Reconciliation.UpToDate, func(instance instances.Instance, _ int) bool {
+		return tagutils.EC2TagsToMap(instance.Tags)[tagutils.GroupTagKey] == group.Name
+	})
+}
+
+// newInstances returns the instances of the plan's status that the launch launched, without the existing instances it kept
+func newInstances(launchPlan plans.LaunchPlan) []instances.Instance {
+	existing := lo.SliceToMap(idsOf(launchPlan.Status.Reconciliation.UpToDate), func(id string) (string, bool) { return id, true })
+	return lo.Reject(launchPlan.Status.Instances, func(instance instances.Instance, _ int) bool { return existing[lo.FromPtr(instance.InstanceId)] })
+}
+
+// alreadyReconciled returns the plan with the status of its existing instances when they already run the spec, nothing is resolved or launched
+func alreadyReconciled(launchPlan plans.LaunchPlan, nodeGroups []plans.NodeGroup) plans.LaunchPlan {
+	launchPlan.Status.Instances = launchPlan.Status.Reconciliation.UpToDate
+	if len(launchPlan.Spec.NodeGroups) != 0 {
+		launchPlan.Status.NodeGroups = lo.Map(nodeGroups, func(group plans.NodeGroup, _ int) plans.NodeGroupStatus {
+			return plans.NodeGroupStatus{Name: group.Name, Instances: upToDateInstances(launchPlan, group)}
+		})
+	}
+	launchPlan.Status.Conditions.Set(plans.ConditionFleetLaunched, plans.ConditionTrue,
+		fmt.Sprintf("%d instances already run the spec, nothing was launched", len(launchPlan.Status.Instances)))
+	setInstancesRunningCondition(&launchPlan)
+	return launchPlan
+}
+
+// terminateOutOfDate terminates the existing instances that were launched with an earlier spec and waits for them to be terminated.
+// The maintain fleets that launched them are deleted first, since they would otherwise replace the terminated instances.
+func (v AWSVM) terminateOutOfDate(ctx context.Context, launchPlan plans.LaunchPlan) error {
+	instanceIDs := idsOf(launchPlan.Status.Reconciliation.OutOfDate)
+	if err := v.deleteMaintainFleets(ctx, launchPlan.Status.Reconciliation.OutOfDate); err != nil {
+		return fmt.Errorf("failed to delete the fleets of replaced instances: %w", err)
+	}
+	for _, instance := range launchPlan.Status.Reconciliation.OutOfDate {
+		logging.FromContext(ctx).Debug("Terminating replaced instance", "instance-id", lo.FromPtr(instance.InstanceId), "generation", instance.Generation())
+	}
+	if err := v.instanceWatcher.TerminateInstances(ctx, instanceIDs); err != nil {
+		return fmt.Errorf("failed to terminate replaced instances: %w", err)
+	}
+	defer progress.FromContext(ctx).Track(fmt.Sprintf("Terminating %d replaced instances", len(instanceIDs)), expectedTerminationDuration)()
+	_, err := v.waitForTerminated(ctx, instanceIDs, false)
+	return err
+}
+
+// deleteMaintainFleets deletes the active maintain fleets that launched the instances, which terminates the instances they launched
+func (v AWSVM) deleteMaintainFleets(ctx context.Context, instanceList []instances.Instance) error {
+	fleetIDs := lo.Uniq(lo.Compact(lo.Map(instanceList, func(instance instances.Instance, _ int) string {
+		return tagutils.EC2TagsToMap(instance.Tags)[fleets.FleetIDTagKey]
+	})))
+	if len(fleetIDs) == 0 {
+		return nil
+	}
+	fleetList, err := v.fleetWatcher.Resolve(ctx, lo.Map(fleetIDs, func(fleetID string, _ int) fleets.Selector {
+		return fleets.Selector{ID: fleetID, Type: string(ec2types.FleetTypeMaintain)}
+	}))
+	if err != nil {
+		return err
+	}
+	for _, fleet := range lo.Filter(fleetList, func(fleet fleets.Fleet, _ int) bool { return fleet.IsActive() }) {
+		logging.FromContext(ctx).Debug("Deleting maintain fleet of replaced instances", "fleet", lo.FromPtr(fleet.FleetId))
+		if err := v.fleetWatcher.DeleteFleet(ctx, lo.FromPtr(fleet.FleetId)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/eips"
+	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
+	"github.com/bwagner5/nimbus/pkg/providers/routetables"
+	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
+	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/samber/lo"
+)
+
+// excludeInUseResources removes security groups and network infrastructure from the deletion plan that are still used outside of the plan,
+// e.g. a VPC shared with other names in the namespace. Excluded resources are recorded in the plan's Spec.Skipped.
+func (v AWSVM) excludeInUseResources(ctx context.Context, deletionPlan *plans.DeletionPlan) error {
+	var securityGroups []securitygroups.SecurityGroup
+	for _, securityGroup := range deletionPlan.Spec.SecurityGroups {
+		users, err := v.instanceUsers(ctx, *deletionPlan, instances.Selector{SecurityGroupID: *securityGroup.GroupId})
+		if err != nil {
+			return err
+		}
+		if len(users) != 0 {
+			skip(ctx, deletionPlan, *securityGroup.GroupId, "SecurityGroup", users)
+			continue
+		}
+		securityGroups = append(securityGroups, securityGroup)
+	}
+	deletionPlan.Spec.SecurityGroups = securityGroups
+
+	// Volumes attached to instances outside of the plan are still in use
+	plannedInstances := lo.SliceToMap(deletionPlan.Spec.Instances, func(instance instances.Instance) (string, bool) { return *instance.InstanceId, true })
+	deletionPlan.Spec.Volumes = lo.Filter(deletionPlan.Spec.Volumes, func(volume volumes.Volume, _ int) bool {
+		users := lo.Reject(volume.AttachedInstanceIDs(), func(instanceID string, _ int) bool { return plannedInstances[instanceID] })
+		if len(users) != 0 {
+			skip(ctx, deletionPlan, *volume.VolumeId, "Volume", users)
+			return false
+		}
+		return true
+	})
+
+	var subnetList []subnets.Subnet
+	for _, subnet := range deletionPlan.Spec.Subnets {
+		users, err := v.instanceUsers(ctx, *deletionPlan, instances.Selector{SubnetID: *subnet.SubnetId})
+		if err != nil {
+			return err
+		}
+		if len(users) != 0 {
+			skip(ctx, deletionPlan, *subnet.SubnetId, "Subnet", users)
+			continue
+		}
+		subnetList = append(subnetList, subnet)
+	}
+	deletionPlan.Spec.Subnets = subnetList
+
+	// A VPC that is still in use keeps all of its network infrastructure so that its remaining users are not disrupted
+	var vpcList []vpcs.VPC
+	for _, vpc := range deletionPlan.Spec.VPCs {
+		users, err := v.vpcUsers(ctx, *deletionPlan, *vpc.VpcId)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			vpcList = append(vpcList, vpc)
+			continue
+		}
+		skip(ctx, deletionPlan, *vpc.VpcId, "VPC", users)
+		reason := []string{*vpc.VpcId}
+		deletionPlan.Spec.Subnets = lo.Filter(deletionPlan.Spec.Subnets, func(subnet subnets.Subnet, _ int) bool {
+			if *subnet.VpcId != *vpc.VpcId {
+				return true
+			}
+			skip(ctx, deletionPlan, *subnet.SubnetId, "Subnet", reason)
+			return false
+		})
+		deletionPlan.Spec.InternetGateways = lo.Filter(deletionPlan.Spec.InternetGateways, func(igw igws.InternetGateway, _ int) bool {
+			if !lo.ContainsBy(igw.Attachments, func(attachment ec2types.InternetGatewayAttachment) bool {
+				return lo.FromPtr(attachment.VpcId) == *vpc.VpcId
+			}) {
+				return true
+			}
+			skip(ctx, deletionPlan, *igw.InternetGatewayId, "InternetGateway", reason)
+			return false
+		})
+		deletionPlan.Spec.EgressOnlyInternetGateways = lo.Filter(deletionPlan.Spec.EgressOnlyInternetGateways, func(eigw igws.EgressOnlyInternetGateway, _ int) bool {
+			if !lo.ContainsBy(eigw.Attachments, func(attachment ec2types.InternetGatewayAttachment) bool {
+				return lo.FromPtr(attachment.VpcId) == *vpc.VpcId
+			}) {
+				return true
+			}
+			skip(ctx, deletionPlan, *eigw.EgressOnlyInternetGatewayId, "EgressOnlyInternetGateway", reason)
+			return false
+		})
+		deletionPlan.Spec.RouteTables = lo.Filter(deletionPlan.Spec.RouteTables, func(routeTable routetables.RouteTable, _ int) bool {
+			if lo.FromPtr(routeTable.VpcId) != *vpc.VpcId {
+				return true
+			}
+			skip(ctx, deletionPlan, *routeTable.RouteTableId, "RouteTable", reason)
+			return false
+		})
+		deletionPlan.Spec.NATGateways = lo.Filter(deletionPlan.Spec.NATGateways, func(natGateway natgws.NATGateway, _ int) bool {
+			if lo.FromPtr(natGateway.VpcId) != *vpc.VpcId {
+				return true
+			}
+			skip(ctx, deletionPlan, *natGateway.NatGatewayId, "NATGateway", reason)
+			return false
+		})
+	}
+	deletionPlan.Spec.VPCs = vpcList
+
+	// Elastic IPs are released once the plan's instances and NAT Gateways no longer use them, other associations are still in use
+	plannedAllocations := lo.SliceToMap(lo.FlatMap(deletionPlan.Spec.NATGateways, func(natGateway natgws.NATGateway, _ int) []string {
+		return natGateway.AllocationIDs()
+	}), func(allocationID string) (string, bool) { return allocationID, true })
+	deletionPlan.Spec.ElasticIPs = lo.Filter(deletionPlan.Spec.ElasticIPs, func(eip eips.ElasticIP, _ int) bool {
+		if eip.AssociationId == nil || plannedAllocations[*eip.AllocationId] || (eip.InstanceId != nil && plannedInstances[*eip.InstanceId]) {
+			return true
+		}
+		skip(ctx, deletionPlan, *eip.AllocationId, "ElasticIP", []string{lo.CoalesceOrEmpty(lo.FromPtr(eip.InstanceId), lo.FromPtr(eip.NetworkInterfaceId), *eip.AssociationId)})
+		return false
+	})
+	return nil
+}
+
+// skip records that the resource is excluded from the deletion plan because it is in use by the users
+func skip(ctx context.Context, deletionPlan *plans.DeletionPlan, id, resourceType string, users []string) {
+	logging.FromContext(ctx).Debug("Resource is still in use, excluding it from the deletion plan", "id", id, "type", resourceType, "used-by", users)
+	deletionPlan.Spec.Skipped = append(deletionPlan.Spec.Skipped, plans.SkippedResource{
+		ID:     id,
+		Type:   resourceType,
+		Reason: fmt.Sprintf("in use by %s", strings.Join(users, ", ")),
+	})
+}
+
+// instanceUsers returns the IDs of instances that match the selector, are not terminating, and are not part of the deletion plan
+func (v AWSVM) instanceUsers(ctx context.Context, deletionPlan plans.DeletionPlan, selector instances.Selector) ([]string, error) {
+	matchingInstances, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{selector})
+	if err != nil {
+		return nil, err
+	}
+	plannedInstances := lo.SliceToMap(deletionPlan.Spec.Instances, func(instance instances.Instance) (string, bool) { return *instance.InstanceId, true })
+	return lo.FilterMap(matchingInstances, func(instance instances.Instance, _ int) (string, bool) {
+		gone := instance.State != nil && (instance.State.Name == ec2types.InstanceStateNameTerminated || instance.State.Name == ec2types.InstanceStateNameShuttingDown)
+		return *instance.InstanceId, !gone && !plannedInstances[*instance.InstanceId]
+	}), nil
+}
+
+// vpcUsers returns the IDs of instances, security groups, and subnets in the VPC that are not part of the deletion plan
+func (v AWSVM) vpcUsers(ctx context.Context, deletionPlan plans.DeletionPlan, vpcID string) ([]string, error) {
+	users, err := v.instanceUsers(ctx, deletionPlan, instances.Selector{VPCID: vpcID})
+	if err != nil {
+		return nil, err
+	}
+
+	vpcSecurityGroups, err := v.securityGroupWatcher.Resolve(ctx, []securitygroups.Selector{{VPCID: vpcID}})
+	if err != nil {
+		return nil, err
+	}
+	plannedSecurityGroups := lo.SliceToMap(deletionPlan.Spec.SecurityGroups, func(securityGroup securitygroups.SecurityGroup) (string, bool) {
+		return *securityGroup.GroupId, true
+	})
+	for _, securityGroup := range vpcSecurityGroups {
+		// The default security group is deleted with the VPC
+		if lo.FromPtr(securityGroup.GroupName) == "default" || plannedSecurityGroups[*securityGroup.GroupId] {
+			continue
+		}
+		users = append(users, *securityGroup.GroupId)
+	}
+
+	vpcSubnets, err := v.subnetWatcher.Resolve(ctx, []subnets.Selector{{VPCID: vpcID}})
+	if err != nil {
+		return nil, err
+	}
+	plannedSubnets := lo.SliceToMap(deletionPlan.Spec.Subnets, func(subnet subnets.Subnet) (string, bool) { return *subnet.SubnetId, true })
+	for _, subnet := range vpcSubnets {
+		if !plannedSubnets[*subnet.SubnetId] {
+			users = append(users, *subnet.SubnetId)
+		}
+	}
+	return users, nil
+}
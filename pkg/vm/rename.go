@@ -0,0 +1,83 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/tags"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// Rename rewrites the nimbus tags of every resource of namespace/name so that it is managed as newNamespace/newName.
+// Resources are retagged with one call per distinct Name tag, and the rename is refused if newNamespace/newName already has resources.
+// AWS resource names such as launch template and security group names are immutable and keep the old name.
+// A namespace's shared network is not moved since it is owned by the namespace rather than the name.
+func (v AWSVM) Rename(ctx context.Context, namespace, name, newNamespace, newName string) ([]tags.TaggedResource, error) {
+	if newNamespace == "" {
+		newNamespace = namespace
+	}
+	if newName == "" {
+		newName = name
+	}
+	if name == "" || newName == "" {
+		return nil, fmt.Errorf("a name is required to rename")
+	}
+	if namespace == newNamespace && name == newName {
+		return nil, fmt.Errorf("%s/%s is already named %s/%s", namespace, name, newNamespace, newName)
+	}
+
+	logging.FromContext(ctx).Debug("Checking that the new name is not in use", "namespace", newNamespace, "name", newName)
+	existing, err := v.taggedResources(ctx, newNamespace, newName)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) != 0 {
+		return nil, fmt.Errorf("%s/%s already has %d resources", newNamespace, newName, len(existing))
+	}
+
+	logging.FromContext(ctx).Debug("Resolving resources to rename", "namespace", namespace, "name", name)
+	resources, err := v.taggedResources(ctx, namespace, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(resources) == 0 {
+		return nil, fmt.Errorf("no resources found for %s/%s", namespace, name)
+	}
+
+	// Resources with a suffixed Name tag, like route tables, keep their suffix
+	oldNameTag := tagutils.NamespacedTags(namespace, name)["Name"]
+	newTags := tagutils.NamespacedTags(newNamespace, newName)
+	byNameTag := lo.GroupBy(resources, func(resource tags.TaggedResource) string {
+		return newTags["Name"] + strings.TrimPrefix(resource.Tags["Name"], oldNameTag)
+	})
+	for nameTag, group := range byNameTag {
+		ids := lo.Map(group, func(resource tags.TaggedResource, _ int) string { return resource.ID })
+		logging.FromContext(ctx).Debug("Retagging resources", "name-tag", nameTag, "resource-ids", ids)
+		if err := v.tagWatcher.Tag(ctx, ids, lo.Assign(newTags, map[string]string{"Name": nameTag})); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// taggedResources returns every resource with the namespaced tags of namespace/name, excluding terminated instances
+func (v AWSVM) taggedResources(ctx context.Context, namespace, name string) ([]tags.TaggedResource, error) {
+	resources, err := v.tagWatcher.Resolve(ctx, []tags.Selector{{Tags: tagutils.NamespacedTags(namespace, name)}})
+	if err != nil {
+		return nil, err
+	}
+	terminatedInstances, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{
+		Tags:  tagutils.NamespacedTags(namespace, name),
+		State: string(ec2types.InstanceStateNameTerminated),
+	}})
+	if err != nil {
+		return nil, err
+	}
+	terminated := lo.SliceToMap(terminatedInstances, func(instance instances.Instance) (string, bool) { return *instance.InstanceId, true })
+	return lo.Reject(resources, func(resource tags.TaggedResource, _ int) bool { return terminated[resource.ID] }), nil
+}
@@ -0,0 +1,94 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/samber/lo"
+)
+
+// rebootGracePeriod is how long a rebooted batch is given to go down before it is checked, EC2 reboots asynchronously and rebooting instances stay running
+const rebootGracePeriod = 30 * time.Second
+
+// RestartOptions configure a rolling restart
+type RestartOptions struct {
+	// BatchSize is how many instances are restarted at once, defaults to 1
+	BatchSize int
+	// Pause is how long to wait after a batch is healthy before restarting the next one
+	Pause time.Duration
+	// StopStart stops and starts the instances instead of rebooting them, which moves them to new hardware and loses instance store volumes
+	StopStart bool
+	// Probe is checked against every instance of a batch before the next batch is restarted, instances always have to pass EC2 status checks
+	Probe plans.ReadinessProbe
+}
+
+// Restart restarts the running instances of namespace/name that match the selectors in batches. A batch is restarted once the previous batch
+// is healthy again, and the restart stops at the first batch that does not become healthy within the probe's timeout so the rest keep serving.
+// The restarted instances are returned, including those of a batch that did not become healthy.
+func (v AWSVM) Restart(ctx context.Context, namespace, name string, selectorList []instances.Selector, opts RestartOptions) ([]instances.Instance, error) {
+	instanceList, err := v.targetInstances(ctx, namespace, name, selectorList, "restart", ec2types.InstanceStateNameRunning)
+	if err != nil {
+		return nil, err
+	}
+	timeout := lo.CoalesceOrEmpty(opts.Probe.Timeout, defaultReadinessTimeout)
+	var restarted []instances.Instance
+	batches := lo.Chunk(instanceList, max(1, opts.BatchSize))
+	for i, batch := range batches {
+		ids := idsOf(batch)
+		logging.FromContext(ctx).Info("Restarting batch", "batch", fmt.Sprintf("%d/%d", i+1, len(batches)), "instance-ids", ids)
+		if err := v.restartBatch(ctx, ids, opts.StopStart, timeout); err != nil {
+			return restarted, err
+		}
+		groupStatus, err := v.waitForNodeGroupReady(ctx, plans.NodeGroup{ReadinessProbe: opts.Probe}, plans.NodeGroupStatus{Instances: batch})
+		restarted = append(restarted, groupStatus.Instances...)
+		if err != nil {
+			return restarted, fmt.Errorf("batch %d/%d did not become healthy, stopping the restart: %w", i+1, len(batches), err)
+		}
+		if i == len(batches)-1 || opts.Pause == 0 {
+			continue
+		}
+		logging.FromContext(ctx).Debug("Pausing before the next batch", "pause", opts.Pause)
+		select {
+		case <-ctx.Done():
+			return restarted, ctx.Err()
+		case <-time.After(opts.Pause):
+		}
+	}
+	return restarted, nil
+}
+
+// restartBatch reboots, or stops and starts, the instances and waits until they are running again
+func (v AWSVM) restartBatch(ctx context.Context, instanceIDs []string, stopStart bool, timeout time.Duration) error {
+	if !stopStart {
+		for _, instanceID := range instanceIDs {
+			if err := v.instanceWatcher.RebootInstance(ctx, instanceID); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(rebootGracePeriod):
+		}
+		return nil
+	}
+	for _, instanceID := range instanceIDs {
+		if err := v.instanceWatcher.StopInstance(ctx, instanceID, false); err != nil {
+			return err
+		}
+	}
+	if err := v.instanceWatcher.WaitForStopped(ctx, instanceIDs, timeout); err != nil {
+		return err
+	}
+	for _, instanceID := range instanceIDs {
+		if err := v.instanceWatcher.StartInstance(ctx, instanceID); err != nil {
+			return err
+		}
+	}
+	return v.instanceWatcher.WaitForRunning(ctx, instanceIDs, timeout)
+}
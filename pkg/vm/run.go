@@ -0,0 +1,157 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/artifacts"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
+	"github.com/bwagner5/nimbus/pkg/userdata"
+)
+
+const (
+	// jobLogPollInterval is how often the output of a running job is read
+	jobLogPollInterval = 2 * time.Second
+	// jobLogChunkSize is the most bytes of job output read at once, SSM truncates command output to its first 24,000 characters
+	jobLogChunkSize = 16 * 1024
+	// jobShutdownMargin is added to the job timeout for the instance's TTL, so it terminates itself if nimbus is interrupted
+	jobShutdownMargin = 15 * time.Minute
+)
+
+// Job is a script that runs once on a launched instance, which is deleted when the script finishes
+type Job struct {
+	Script string
+	// Timeout is how long the job may run after the instance is reachable by SSM
+	Timeout time.Duration
+	// Keep keeps the VM after the job finishes instead of deleting it
+	Keep bool
+	// Artifacts are downloaded before the script runs and its outputs are published after it finishes, before the job is finished
+	Artifacts artifacts.Options
+}
+
+// JobResult is the outcome of a job
+type JobResult struct {
+	InstanceID string
+	// ExitCode is the exit code of the job's script
+	ExitCode int
+	Duration time.Duration
+	// Outputs is the S3 URI of the published outputs, empty if the job's outputs are not published
+	Outputs string
+}
+
+// Run launches a single instance of the launch plan that runs the job's script from its user-data, streams the script's output to logs,
+// and deletes the VM when the script exits, fails to launch, or times out, unless the job keeps it.
+// The output is read through SSM, so the plan's IAM role must allow the instance to be managed by SSM.
+// The instance terminates itself after the job's timeout plus a margin, or the plan's TTL if it is longer, in case Run is interrupted before it deletes the VM.
+func (v AWSVM) Run(ctx context.Context, launchPlan plans.LaunchPlan, job Job, logs io.Writer) (_ plans.LaunchPlan, result JobResult, err error) {
+	if len(launchPlan.Spec.NodeGroups) != 0 || launchPlan.Spec.Count > 1 || launchPlan.Spec.Capacity.Value != 0 {
+		return launchPlan, JobResult{}, fmt.Errorf("a job runs on a single instance, node groups, counts, and capacities are not supported")
+	}
+	if job.Timeout <= 0 {
+		return launchPlan, JobResult{}, fmt.Errorf("a job requires a positive timeout")
+	}
+	if len(launchPlan.Spec.Artifacts.Inputs) != 0 {
+		return launchPlan, JobResult{}, fmt.Errorf("a job downloads the inputs of its own artifacts, not those of the launch plan")
+	}
+	launchPlan.Spec.TTL = max(launchPlan.Spec.TTL, job.Timeout+jobShutdownMargin)
+	launchPlan.Spec.WaitForBootstrap = true
+	var staging artifacts.Staging
+	if len(job.Artifacts.Inputs) != 0 || job.Artifacts.Outputs {
+		job.Artifacts.Expires = artifactsExpires(launchPlan.Spec.TTL)
+		staging, err = v.artifactWatcher.Stage(ctx, v.awsCfg.Region, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, job.Artifacts)
+		if err != nil {
+			return launchPlan, JobResult{}, err
+		}
+	}
+	launchPlan.Spec.UserData = userdata.Job(job.Script, staging.Download, staging.Upload)
+
+	launchPlan, err = v.Launch(ctx, false, launchPlan)
+	if !job.Keep {
+		defer func() {
+			// the VM is deleted even if ctx was cancelled, e.g. by an interrupt
+			if deleteErr := v.deleteJob(context.WithoutCancel(ctx), launchPlan); deleteErr != nil {
+				err = errors.Join(err, deleteErr)
+			}
+		}()
+	}
+	if err != nil {
+		return launchPlan, JobResult{}, err
+	}
+	if len(launchPlan.Status.Instances) == 0 {
+		return launchPlan, JobResult{}, fmt.Errorf("no instance was launched for the job")
+	}
+
+	result.InstanceID = *launchPlan.Status.Instances[0].InstanceId
+	result.Outputs = staging.Outputs
+	started := time.Now()
+	jobCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+	result.ExitCode, err = v.followJob(jobCtx, result.InstanceID, logs)
+	result.Duration = time.Since(started)
+	return launchPlan, result, err
+}
+
+// followJob copies the output of the instance's job to logs as it is written and returns the job's exit code once it finished
+func (v AWSVM) followJob(ctx context.Context, instanceID string, logs io.Writer) (int, error) {
+	var offset int
+	for {
+		// the exit code is read before the output, so all output has been written when it is set
+		results, err := v.sessionWatcher.RunCommand(ctx, sessions.Command{
+			InstanceIDs: []string{instanceID},
+			Script: fmt.Sprintf(`code=$(cat %[1]s/exit-code 2>/dev/null)
+tail -c +%[2]d %[1]s/output 2>/dev/null | head -c %[3]d
+echo "$code" >&2`, userdata.JobDir, offset+1, jobLogChunkSize),
+			Timeout: time.Minute,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, fmt.Errorf("job did not finish: %w", ctx.Err())
+			}
+			return 0, err
+		}
+		if len(results) != 1 || !results[0].Succeeded() {
+			return 0, fmt.Errorf("failed to read the job's output on %s", instanceID)
+		}
+		output, code := results[0].Stdout, strings.TrimSpace(results[0].Stderr)
+		if _, err := io.WriteString(logs, output); err != nil {
+			return 0, err
+		}
+		offset += len(output)
+		// more output is read right away if the chunk was full
+		if len(output) >= jobLogChunkSize {
+			continue
+		}
+		if code != "" {
+			exitCode, err := strconv.Atoi(code)
+			if err != nil {
+				return 0, fmt.Errorf("failed to parse the job's exit code %q: %w", code, err)
+			}
+			return exitCode, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("job did not finish: %w", ctx.Err())
+		case <-time.After(jobLogPollInterval):
+		}
+	}
+}
+
+// deleteJob deletes the VM of a job's launch plan
+func (v AWSVM) deleteJob(ctx context.Context, launchPlan plans.LaunchPlan) error {
+	logging.FromContext(ctx).Debug("Deleting the job's VM", "namespace", launchPlan.Metadata.Namespace, "name", launchPlan.Metadata.Name)
+	deletionPlan, err := v.DeletionPlan(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
+	if err != nil {
+		return fmt.Errorf("failed to delete the job's VM: %w", err)
+	}
+	if _, err := v.Delete(ctx, deletionPlan); err != nil {
+		return fmt.Errorf("failed to delete the job's VM: %w", err)
+	}
+	return nil
+}
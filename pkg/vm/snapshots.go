@@ -0,0 +1,37 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// createFinalSnapshots snapshots every volume attached to the plan's instances before they are terminated.
+// Volumes that already have a final snapshot from an earlier execution of the plan are not snapshotted again.
+func (v AWSVM) createFinalSnapshots(ctx context.Context, deletionPlan *plans.DeletionPlan) error {
+	if deletionPlan.Status.Snapshots == nil {
+		deletionPlan.Status.Snapshots = map[string]string{}
+	}
+	for _, volume := range deletionPlan.AttachedVolumes() {
+		if _, ok := deletionPlan.Status.Snapshots[volume.VolumeID]; ok {
+			continue
+		}
+		tags := lo.Assign(tagutils.NamespacedTags(deletionPlan.Metadata.Namespace, deletionPlan.Metadata.Name), map[string]string{
+			tagutils.DeletionPlanIDTagKey: deletionPlan.Metadata.PlanID,
+			tagutils.DeviceTagKey:         volume.DeviceName,
+		})
+		description := fmt.Sprintf("Final snapshot of %s attached to %s as %s", volume.VolumeID, volume.InstanceID, volume.DeviceName)
+		logging.FromContext(ctx).Debug("Creating final snapshot", "volume-id", volume.VolumeID, "instance-id", volume.InstanceID, "device", volume.DeviceName)
+		snapshotID, err := v.volumeWatcher.Snapshot(ctx, volume.VolumeID, description, tags)
+		if err != nil {
+			return err
+		}
+		deletionPlan.Status.Snapshots[volume.VolumeID] = snapshotID
+		logging.FromContext(ctx).Info("Created final snapshot", "volume-id", volume.VolumeID, "snapshot-id", snapshotID)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/samber/lo"
+)
+
+const (
+	// stragglerThreshold is how long instances may be shutting down or stopping before they are stragglers that are forced to terminate
+	stragglerThreshold = 5 * time.Minute
+	// stragglerGuidance is what to do about stragglers that do not terminate when they are forced to
+	stragglerGuidance = "EC2 usually terminates instances stuck in shutting-down within a few hours, contact AWS Support if it does not"
+)
+
+// errStragglers is returned when instances are still stuck terminating after they were forced to terminate
+var errStragglers = errors.New("instances are stuck terminating")
+
+// waitForTerminated waits for the instances to be terminated. Instances that are still shutting down or stopping after the straggler threshold
+// are stragglers: the stopping ones are force stopped and all of them are terminated again. If skipStragglers is true, the stragglers that
+// do not terminate within another straggler threshold are returned instead of waiting for them until the termination timeout.
+func (v AWSVM) waitForTerminated(ctx context.Context, instanceIDs []string, skipStragglers bool) ([]instances.Instance, error) {
+	err := v.instanceWatcher.WaitForTerminated(ctx, instanceIDs, stragglerThreshold)
+	if err == nil {
+		return nil, nil
+	}
+	stragglers, resolveErr := v.instanceWatcher.ResolveStragglers(ctx, instanceIDs)
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	if len(stragglers) == 0 {
+		// the instances are not stuck, e.g. the waiter failed on an instance that was still pending
+		return nil, v.instanceWatcher.WaitForTerminated(ctx, instanceIDs, instanceTerminationTimeout-stragglerThreshold)
+	}
+	if err := v.forceTerminate(ctx, stragglers); err != nil {
+		return nil, err
+	}
+	if !skipStragglers {
+		if err := v.instanceWatcher.WaitForTerminated(ctx, instanceIDs, instanceTerminationTimeout-stragglerThreshold); err != nil {
+			return nil, fmt.Errorf("%w: %w, %s", errStragglers, err, stragglerGuidance)
+		}
+		return nil, nil
+	}
+	if err := v.instanceWatcher.WaitForTerminated(ctx, instanceIDs, stragglerThreshold); err == nil {
+		return nil, nil
+	}
+	stragglers, err = v.instanceWatcher.ResolveStragglers(ctx, instanceIDs)
+	if err != nil {
+		return nil, err
+	}
+	if len(stragglers) != 0 {
+		logging.FromContext(ctx).Warn("Skipping instances that are stuck terminating", "instance-ids", idsOf(stragglers), "guidance", stragglerGuidance)
+	}
+	return stragglers, nil
+}
+
+// forceTerminate force stops the stragglers that are stuck in stopping and terminates all of them again
+func (v AWSVM) forceTerminate(ctx context.Context, stragglers []instances.Instance) error {
+	logging.FromContext(ctx).Warn("Instances are stuck terminating, forcing them to terminate", "instance-ids", idsOf(stragglers),
+		"states", strings.Join(lo.Map(stragglers, func(instance instances.Instance, _ int) string { return string(instance.State.Name) }), ","))
+	stopping := lo.Filter(stragglers, func(instance instances.Instance, _ int) bool {
+		return instance.State.Name == ec2types.InstanceStateNameStopping
+	})
+	if err := v.instanceWatcher.ForceStopInstances(ctx, idsOf(stopping)); err != nil {
+		return err
+	}
+	return v.instanceWatcher.TerminateInstances(ctx, idsOf(stragglers))
+}
@@ -0,0 +1,143 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/progress"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/providers/metrics"
+	"github.com/samber/lo"
+)
+
+const (
+	// bootstrapTimeout is how long to wait for launched instances to be running and to register with SSM
+	bootstrapTimeout = 10 * time.Minute
+	// launchPhaseDurationMetric is the custom metric of each launch phase's duration, dimensioned by namespace, name, and phase
+	launchPhaseDurationMetric = "LaunchPhaseDuration"
+	// launchDurationMetric is the custom metric of the total launch duration, dimensioned by namespace and name
+	launchDurationMetric = "LaunchDuration"
+)
+
+// phaseTimer records the timings of consecutive launch phases, each phase starts when the previous one finished
+type phaseTimer struct {
+	timings *plans.Timings
+	started time.Time
+}
+
+// newPhaseTimer starts timing the first phase
+func newPhaseTimer(timings *plans.Timings) *phaseTimer {
+	return &phaseTimer{timings: timings, started: time.Now()}
+}
+
+// finish records the phase as finished now and starts the next phase
+func (p *phaseTimer) finish(phase plans.Phase) {
+	now := time.Now()
+	p.timings.Record(phase, p.started, now)
+	p.started = now
+}
+
+// waitForBootstrap waits for the launched instances to be running, registered with SSM, running nvidia-smi if GPU drivers were set up,
+// and passing their node group's readiness probe, recording the timing of each phase.
+// The GPU and probe phases are only recorded when a node group has NVIDIA GPU instance types or a readiness probe.
+func (v AWSVM) waitForBootstrap(ctx context.Context, launchPlan *plans.LaunchPlan, nodeGroups []plans.NodeGroup, timer *phaseTimer) error {
+	// existing instances that the launch kept already bootstrapped
+	instanceIDs := idsOf(newInstances(*launchPlan))
+
+	logging.FromContext(ctx).Debug("Waiting for instances to be running", "instance-ids", instanceIDs)
+	done := progress.FromContext(ctx).Track(fmt.Sprintf("Waiting for %d instances to be running", len(instanceIDs)), expectedRunningDuration)
+	err := v.instanceWatcher.WaitForRunning(ctx, instanceIDs, bootstrapTimeout)
+	done()
+	if err != nil {
+		return err
+	}
+	if err := v.refreshLaunchedInstances(ctx, launchPlan); err != nil {
+		return err
+	}
+	timer.finish(plans.PhaseRunning)
+
+	logging.FromContext(ctx).Debug("Waiting for instances to register with SSM", "instance-ids", instanceIDs)
+	ssmCtx, cancel := context.WithTimeout(ctx, bootstrapTimeout)
+	defer cancel()
+	if err := v.sessionWatcher.WaitForOnline(ssmCtx, instanceIDs); err != nil {
+		return err
+	}
+	timer.finish(plans.PhaseSSMReady)
+
+	if launchPlan.Spec.GPUDrivers != "" && lo.SomeBy(launchPlan.Status.NodeGroups, func(groupStatus plans.NodeGroupStatus) bool {
+		return hasNVIDIAGPU(groupStatus.InstanceTypes)
+	}) {
+		if err := v.verifyGPUDrivers(ctx, launchPlan); err != nil {
+			return err
+		}
+		timer.finish(plans.PhaseGPUReady)
+	}
+
+	if !lo.SomeBy(nodeGroups, func(group plans.NodeGroup) bool { return group.ReadinessProbe.Port != 0 }) {
+		return nil
+	}
+	for i, group := range nodeGroups {
+		if group.ReadinessProbe.Port == 0 || launchPlan.Status.NodeGroups[i].Ready {
+			continue
+		}
+		logging.FromContext(ctx).Debug("Waiting for node group to be ready", "group", group.Name)
+		groupStatus, err := v.waitForNodeGroupReady(ctx, group, launchPlan.Status.NodeGroups[i])
+		launchPlan.Status.NodeGroups[i] = groupStatus
+		if err != nil {
+			return fmt.Errorf("node group %s is not ready: %w", group.Name, err)
+		}
+	}
+	timer.finish(plans.PhaseProbePassed)
+	return nil
+}
+
+// refreshLaunchedInstances re-reads the instances of every node group to pick up their state and addresses after launch
+func (v AWSVM) refreshLaunchedInstances(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	for i, groupStatus := range launchPlan.Status.NodeGroups {
+		if len(groupStatus.Instances) == 0 {
+			continue
+		}
+		refreshedInstances, err := v.instanceWatcher.Resolve(ctx, lo.Map(idsOf(groupStatus.Instances), func(id string, _ int) instances.Selector {
+			return instances.Selector{ID: id}
+		}))
+		if err != nil {
+			return err
+		}
+		launchPlan.Status.NodeGroups[i].Instances = refreshedInstances
+	}
+	launchPlan.Status.Instances = lo.FlatMap(launchPlan.Status.NodeGroups, func(groupStatus plans.NodeGroupStatus, _ int) []instances.Instance {
+		return groupStatus.Instances
+	})
+	return nil
+}
+
+// publishTimings publishes the launch's phase timings and total duration as custom metrics to the spec's timing metrics namespace
+func (v AWSVM) publishTimings(ctx context.Context, launchPlan plans.LaunchPlan) error {
+	if launchPlan.Spec.TimingMetricsNamespace == "" || len(launchPlan.Status.Timings) == 0 {
+		return nil
+	}
+	dimensions := []metrics.Dimension{
+		{Name: "Namespace", Value: launchPlan.Metadata.Namespace},
+		{Name: "Name", Value: launchPlan.Metadata.Name},
+	}
+	durations := lo.Map(launchPlan.Status.Timings, func(timing plans.PhaseTiming, _ int) metrics.Duration {
+		return metrics.Duration{
+			MetricName: launchPhaseDurationMetric,
+			Dimensions: append(slices.Clone(dimensions), metrics.Dimension{Name: "Phase", Value: string(timing.Phase)}),
+			Value:      timing.Duration,
+			Timestamp:  timing.Started.Add(timing.Duration),
+		}
+	})
+	durations = append(durations, metrics.Duration{
+		MetricName: launchDurationMetric,
+		Dimensions: dimensions,
+		Value:      launchPlan.Status.Timings.Total(),
+		Timestamp:  time.Now(),
+	})
+	logging.FromContext(ctx).Debug("Publishing launch timings", "namespace", launchPlan.Spec.TimingMetricsNamespace, "metrics", len(durations))
+	return v.metricsWatcher.PutDurations(ctx, launchPlan.Spec.TimingMetricsNamespace, durations)
+}
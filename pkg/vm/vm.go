@@ -2,88 +2,315 @@ package vm
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudtrail"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/aws/aws-sdk-go-v2/service/eventbridge"
+	awsfis "github.com/aws/aws-sdk-go-v2/service/fis"
+	"github.com/aws/aws-sdk-go-v2/service/iam"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	awspricing "github.com/aws/aws-sdk-go-v2/service/pricing"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	awssavingsplans "github.com/aws/aws-sdk-go-v2/service/savingsplans"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/bwagner5/nimbus/pkg/artifacts"
+	"github.com/bwagner5/nimbus/pkg/events"
 	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/naming"
 	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/progress"
 	"github.com/bwagner5/nimbus/pkg/providers/amis"
 	"github.com/bwagner5/nimbus/pkg/providers/azs"
+	"github.com/bwagner5/nimbus/pkg/providers/eips"
+	"github.com/bwagner5/nimbus/pkg/providers/enis"
+	"github.com/bwagner5/nimbus/pkg/providers/fis"
 	"github.com/bwagner5/nimbus/pkg/providers/fleets"
 	"github.com/bwagner5/nimbus/pkg/providers/igws"
+	"github.com/bwagner5/nimbus/pkg/providers/instanceprofiles"
 	"github.com/bwagner5/nimbus/pkg/providers/instances"
 	"github.com/bwagner5/nimbus/pkg/providers/instancetypes"
+	"github.com/bwagner5/nimbus/pkg/providers/keypairs"
+	"github.com/bwagner5/nimbus/pkg/providers/kmskeys"
 	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/locks"
+	"github.com/bwagner5/nimbus/pkg/providers/logs"
+	"github.com/bwagner5/nimbus/pkg/providers/metrics"
+	"github.com/bwagner5/nimbus/pkg/providers/natgws"
+	"github.com/bwagner5/nimbus/pkg/providers/pricing"
+	"github.com/bwagner5/nimbus/pkg/providers/reservations"
 	"github.com/bwagner5/nimbus/pkg/providers/routetables"
+	"github.com/bwagner5/nimbus/pkg/providers/savingsplans"
 	"github.com/bwagner5/nimbus/pkg/providers/securitygroups"
+	"github.com/bwagner5/nimbus/pkg/providers/serialconsoles"
+	"github.com/bwagner5/nimbus/pkg/providers/sessions"
 	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/tags"
+	"github.com/bwagner5/nimbus/pkg/providers/trails"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
 	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+	"github.com/bwagner5/nimbus/pkg/userdata"
 	"github.com/bwagner5/nimbus/pkg/utils/ec2utils"
 	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
 	"github.com/samber/lo"
 )
 
+const (
+	// eniReleaseTimeout is the max time to wait for network interfaces of terminated instances to be released before deleting subnets and security groups
+	eniReleaseTimeout = 5 * time.Minute
+	// instanceTerminationTimeout is the max time to wait for instances to be terminated before deleting the resources they use
+	instanceTerminationTimeout = 10 * time.Minute
+	// expectedRunningDuration, expectedTerminationDuration, and expectedNATGatewayDuration are typical durations that progress indicators estimate the time remaining from
+	expectedRunningDuration     = 30 * time.Second
+	expectedTerminationDuration = time.Minute
+	expectedNATGatewayDuration  = 2 * time.Minute
+	// instanceStopTimeout is the max time to wait for the instances of a deletion plan to be stopped before they are terminated
+	instanceStopTimeout = 10 * time.Minute
+)
+
 type VMI interface {
-	List(ctx context.Context, namespace string, name string) ([]instances.Instance, error)
+	List(ctx context.Context, namespace string, name string, filters ...ec2types.Filter) ([]instances.Instance, error)
 	Launch(context.Context, bool, plans.LaunchPlan) (plans.LaunchPlan, error)
+	Apply(context.Context, plans.LaunchPlan) (plans.LaunchPlan, error)
 	DeletionPlan(ctx context.Context, namespace, name string) (plans.DeletionPlan, error)
 	Delete(context.Context, plans.DeletionPlan) (plans.DeletionPlan, error)
+	StopForDeletion(ctx context.Context, deletionPlan plans.DeletionPlan) (plans.DeletionPlan, error)
+	KeepVolumes(ctx context.Context, deletionPlan plans.DeletionPlan, kept []plans.AttachedVolume) (plans.DeletionPlan, error)
+	Watch(ctx context.Context, namespace string) (<-chan Event, error)
+	Events(ctx context.Context, namespace, name, planID string, watch bool) (<-chan events.Event, error)
+	DeleteEvents(ctx context.Context, namespace string) error
+	Init(ctx context.Context, namespace string) ([]Prerequisite, error)
+	DestroyInit(ctx context.Context, namespace string) ([]Prerequisite, error)
+	Rename(ctx context.Context, namespace, name, newNamespace, newName string) ([]tags.TaggedResource, error)
+	Stop(ctx context.Context, namespace, name string, selectorList []instances.Selector, hibernate bool) ([]instances.Instance, error)
+	Start(ctx context.Context, namespace, name string, selectorList []instances.Selector) ([]instances.Instance, error)
+	Reboot(ctx context.Context, namespace, name string, selectorList []instances.Selector) ([]instances.Instance, error)
+	Restart(ctx context.Context, namespace, name string, selectorList []instances.Selector, opts RestartOptions) ([]instances.Instance, error)
+	Idle(ctx context.Context, namespace, name string, idleOptions IdleOptions) ([]IdleInstance, error)
+	ARM64Migrations(ctx context.Context, namespace, name string) ([]ARM64Migration, error)
+	Prices(ctx context.Context, selectors []instancetypes.Selector) ([]InstanceTypePrice, error)
+	InstanceTypes(ctx context.Context, selectors []instancetypes.Selector, withPrices bool) ([]InstanceTypeDetails, error)
+	ResolveAlias(ctx context.Context, alias string) ([]amis.AMI, error)
+	BuildImage(ctx context.Context, launchPlan plans.LaunchPlan, build ImageBuild, logs io.Writer) (ImageBuildResult, error)
+	KeyPairs(ctx context.Context, namespace string, selectorList []keypairs.Selector) ([]keypairs.KeyPair, error)
+	CreateKeyPair(ctx context.Context, namespace, keyName, keyType string) (keypairs.KeyPair, error)
+	ImportKeyPair(ctx context.Context, namespace, keyName, publicKeyPath string) (keypairs.KeyPair, error)
+	DeleteKeyPair(ctx context.Context, namespace, keyName string) (keypairs.KeyPair, error)
+	SecurityGroupRules(ctx context.Context, namespace, name string, selectorList []securitygroups.RuleSelector) ([]securitygroups.Rule, error)
+	SecurityGroups(ctx context.Context, namespace, name string) ([]securitygroups.SecurityGroup, error)
+	Connect(ctx context.Context, namespace, name string, selectorList []instances.Selector, profile string) error
+	Exec(ctx context.Context, namespace, name string, selectorList []instances.Selector, command sessions.Command) ([]sessions.CommandResult, error)
+	Run(ctx context.Context, launchPlan plans.LaunchPlan, job Job, logs io.Writer) (plans.LaunchPlan, JobResult, error)
+	AuditTrail(ctx context.Context, namespace, name string, since time.Duration) ([]trails.Event, error)
+	Logs(ctx context.Context, namespace, name string, since time.Duration, follow bool) (<-chan logs.Event, error)
+	CommandLogs(ctx context.Context, namespace, name string, since time.Duration, follow bool) (<-chan sessions.Invocation, error)
+	ConsoleOutput(ctx context.Context, namespace, name string, selectorList []instances.Selector, follow bool) (<-chan instances.ConsoleOutput, error)
+	Screenshot(ctx context.Context, namespace, name string, selectorList []instances.Selector) (string, []byte, error)
+	SerialConsole(ctx context.Context, namespace, name string, selectorList []instances.Selector, enableAccess bool) error
+	Interrupt(ctx context.Context, namespace, name string, opts InterruptOptions) (Interruption, error)
+	CreateExperiment(ctx context.Context, namespace, name string, opts ExperimentOptions) (fis.ExperimentTemplate, error)
+	ListExperiments(ctx context.Context, namespace, name string) ([]fis.ExperimentTemplate, error)
+	StartExperiment(ctx context.Context, namespace, templateID string) (fis.Experiment, error)
+	StopExperiment(ctx context.Context, namespace, experimentID string) (fis.Experiment, error)
+	Diff(ctx context.Context, launchPlan plans.LaunchPlan) (PlanDiff, error)
+	Lock(ctx context.Context, namespace, name, operation string, forceUnlock bool) (func() error, error)
 }
 
 type AWSVM struct {
-	awsCfg                *aws.Config
-	vpcWatcher            vpcs.Watcher
-	subnetWatcher         subnets.Watcher
-	azWatcher             azs.Watcher
-	igwWatcher            igws.Watcher
-	routeTableWatcher     routetables.Watcher
-	securityGroupWatcher  securitygroups.Watcher
-	amiWatcher            amis.Watcher
-	instanceTypeWatcher   instancetypes.Watcher
-	instanceWatcher       instances.Watcher
-	launchTemplateWatcher launchtemplates.Watcher
-	fleetWatcher          fleets.Watcher
+	awsCfg                 *aws.Config
+	vpcWatcher             vpcs.Watcher
+	subnetWatcher          subnets.Watcher
+	azWatcher              azs.Watcher
+	igwWatcher             igws.Watcher
+	routeTableWatcher      routetables.Watcher
+	natGatewayWatcher      natgws.Watcher
+	eipWatcher             eips.Watcher
+	securityGroupWatcher   securitygroups.Watcher
+	amiWatcher             amis.Watcher
+	instanceTypeWatcher    instancetypes.Watcher
+	instanceWatcher        instances.Watcher
+	launchTemplateWatcher  launchtemplates.Watcher
+	fleetWatcher           fleets.Watcher
+	eniWatcher             enis.Watcher
+	tagWatcher             tags.Watcher
+	trailWatcher           trails.Watcher
+	kmsKeyWatcher          kmskeys.Watcher
+	keyPairWatcher         keypairs.Watcher
+	metricsWatcher         metrics.Watcher
+	sessionWatcher         sessions.Watcher
+	serialConsoleWatcher   serialconsoles.Watcher
+	reservationWatcher     reservations.Watcher
+	savingsPlanWatcher     savingsplans.Watcher
+	volumeWatcher          volumes.Watcher
+	instanceProfileWatcher instanceprofiles.Watcher
+	eventWatcher           events.Watcher
+	pricingWatcher         pricing.Watcher
+	artifactWatcher        artifacts.Watcher
+	logWatcher             logs.Watcher
+	fisWatcher             fis.Watcher
+	lockWatcher            locks.Watcher
 }
 
 func New(awsCfg *aws.Config) AWSVM {
 	ec2API := ec2.NewFromConfig(*awsCfg)
 	ssmAPI := ssm.NewFromConfig(*awsCfg)
+	s3Client := s3.NewFromConfig(*awsCfg)
+	pricingAPI := awspricing.NewFromConfig(*awsCfg, func(o *awspricing.Options) { o.Region = pricing.Region(awsCfg.Region) })
 	return AWSVM{
-		awsCfg:                awsCfg,
-		vpcWatcher:            vpcs.NewWatcher(*awsCfg, ec2API),
-		subnetWatcher:         subnets.NewWatcher(ec2API),
-		azWatcher:             azs.NewWatcher(ec2API),
-		igwWatcher:            igws.NewWatcher(ec2API),
-		routeTableWatcher:     routetables.NewWatcher(ec2API),
-		securityGroupWatcher:  securitygroups.NewWatcher(ec2API),
-		amiWatcher:            amis.NewWatcher(ec2API, ssmAPI),
-		instanceWatcher:       instances.NewWatcher(ec2API),
-		instanceTypeWatcher:   instancetypes.NewWatcher(*awsCfg),
-		launchTemplateWatcher: launchtemplates.NewWatcher(ec2API),
-		fleetWatcher:          fleets.NewWatcher(ec2API),
+		awsCfg:                 awsCfg,
+		vpcWatcher:             vpcs.NewWatcher(*awsCfg, ec2API),
+		subnetWatcher:          subnets.NewWatcher(ec2API),
+		azWatcher:              azs.NewWatcher(ec2API),
+		igwWatcher:             igws.NewWatcher(ec2API),
+		routeTableWatcher:      routetables.NewWatcher(ec2API),
+		natGatewayWatcher:      natgws.NewWatcher(ec2API),
+		eipWatcher:             eips.NewWatcher(ec2API),
+		securityGroupWatcher:   securitygroups.NewWatcher(ec2API),
+		amiWatcher:             amis.NewWatcher(ec2API, ssmAPI),
+		instanceWatcher:        instances.NewWatcher(ec2API),
+		instanceTypeWatcher:    instancetypes.NewWatcher(*awsCfg),
+		launchTemplateWatcher:  launchtemplates.NewWatcher(ec2API),
+		fleetWatcher:           fleets.NewWatcher(ec2API),
+		eniWatcher:             enis.NewWatcher(ec2API),
+		tagWatcher:             tags.NewWatcher(ec2API),
+		trailWatcher:           trails.NewWatcher(cloudtrail.NewFromConfig(*awsCfg)),
+		kmsKeyWatcher:          kmskeys.NewWatcher(kms.NewFromConfig(*awsCfg)),
+		keyPairWatcher:         keypairs.NewWatcher(ec2API),
+		metricsWatcher:         metrics.NewWatcher(cloudwatch.NewFromConfig(*awsCfg)),
+		sessionWatcher:         sessions.NewWatcher(*awsCfg, ssmAPI),
+		serialConsoleWatcher:   serialconsoles.NewWatcher(*awsCfg, ec2instanceconnect.NewFromConfig(*awsCfg)),
+		reservationWatcher:     reservations.NewWatcher(ec2API),
+		savingsPlanWatcher:     savingsplans.NewWatcher(awssavingsplans.NewFromConfig(*awsCfg)),
+		volumeWatcher:          volumes.NewWatcher(ec2API),
+		instanceProfileWatcher: instanceprofiles.NewWatcher(iam.NewFromConfig(*awsCfg)),
+		eventWatcher:           events.NewWatcher(eventbridge.NewFromConfig(*awsCfg), sqs.NewFromConfig(*awsCfg)),
+		pricingWatcher:         pricing.NewWatcher(awsCfg.Region, pricingAPI, ec2API),
+		artifactWatcher:        artifacts.NewWatcher(s3Client, s3.NewPresignClient(s3Client), sts.NewFromConfig(*awsCfg)),
+		logWatcher:             logs.NewWatcher(cloudwatchlogs.NewFromConfig(*awsCfg)),
+		fisWatcher:             fis.NewWatcher(awsfis.NewFromConfig(*awsCfg), sts.NewFromConfig(*awsCfg)),
+		lockWatcher:            locks.NewWatcher(ssmAPI),
 	}
 }
 
+// Region returns the AWS region the client operates in
+func (v AWSVM) Region() string {
+	return v.awsCfg.Region
+}
+
 func (v AWSVM) Launch(ctx context.Context, dryRun bool, launchPlan plans.LaunchPlan) (plans.LaunchPlan, error) {
 	logging.FromContext(ctx).Debug("Executing Launch Plan")
-	launchPlan.Status = plans.LaunchStatus{}
+	launchPlan.Status = plans.LaunchStatus{DryRun: dryRun}
+	return v.launch(ctx, dryRun, launchPlan, nil)
+}
 
-	logging.FromContext(ctx).Debug("Resolving AMIs")
-	amis, err := v.amiWatcher.Resolve(ctx, launchPlan.Spec.AMISelectors)
-	if err != nil {
+// launch validates and executes the plan. The AMIs, instance types, roles, keys, and existing network of the resolved status are used
+// instead of resolving them again if it is not nil, see Apply.
+func (v AWSVM) launch(ctx context.Context, dryRun bool, launchPlan plans.LaunchPlan, resolved *plans.LaunchStatus) (result plans.LaunchPlan, err error) {
+	launchPlan.Status.Region = v.awsCfg.Region
+	launchPlan.Metadata.PlanID = plans.NewPlanID()
+	ctx = logging.ToContext(ctx, logging.FromContext(ctx).With("plan-id", launchPlan.Metadata.PlanID))
+	timer := newPhaseTimer(&launchPlan.Status.Timings)
+	defer func() {
+		if err != nil {
+			result.Status.Conditions.FailInProgress(err)
+		}
+	}()
+
+	if err := launchPlan.Spec.ValidateNodeGroups(); err != nil {
+		return launchPlan, err
+	}
+	if launchPlan.Spec.Count < 0 {
+		return launchPlan, fmt.Errorf("count must not be negative, got %d", launchPlan.Spec.Count)
+	}
+	if launchPlan.Spec.Count != 0 && len(launchPlan.Spec.Placements) != 0 && int(launchPlan.Spec.Count) != len(launchPlan.Spec.Placements) {
+		return launchPlan, fmt.Errorf("count of %d does not match the %d placements, one instance is launched per placement", launchPlan.Spec.Count, len(launchPlan.Spec.Placements))
+	}
+	if launchPlan.Spec.TTL < 0 {
+		return launchPlan, fmt.Errorf("ttl must not be negative, got %s", launchPlan.Spec.TTL)
+	}
+	if launchPlan.Spec.Capacity.Value != 0 && launchPlan.Spec.Count != 0 {
+		return launchPlan, fmt.Errorf("count and capacity are mutually exclusive")
+	}
+	if launchPlan.Spec.Capacity.Value != 0 && len(launchPlan.Spec.Placements) != 0 {
+		return launchPlan, fmt.Errorf("capacity is not supported with placements, one instance is launched per placement")
+	}
+	if err := launchPlan.Spec.RootVolume.Validate(); err != nil {
+		return launchPlan, fmt.Errorf("invalid root volume: %w", err)
+	}
+	if err := launchtemplates.ValidateBlockDevices(launchPlan.Spec.BlockDeviceMappings); err != nil {
+		return launchPlan, fmt.Errorf("invalid block device mappings: %w", err)
+	}
+	if err := validateVolumes(launchPlan.Spec); err != nil {
+		return launchPlan, fmt.Errorf("invalid volumes: %w", err)
+	}
+	if _, ok := rootMapping(launchPlan.Spec.BlockDeviceMappings); ok && launchPlan.Spec.RootVolume != (launchtemplates.BlockDevice{}) {
+		return launchPlan, fmt.Errorf("the root volume is configured by both the root volume and a %s block device mapping", launchtemplates.RootDevice)
+	}
+	if err := validateGPUDrivers(launchPlan.Spec.GPUDrivers); err != nil {
+		return launchPlan, err
+	}
+	if err := launchPlan.Spec.MetadataOptions.Validate(); err != nil {
+		return launchPlan, err
+	}
+	if err := launchtemplates.ValidateNetworkInterfaces(launchPlan.Spec.NetworkInterfaces); err != nil {
+		return launchPlan, err
+	}
+	if _, err := plans.ParseWaitCondition(string(launchPlan.Spec.Wait.For)); err != nil {
+		return launchPlan, err
+	}
+	if err := artifacts.ValidateMode(launchPlan.Spec.Artifacts.Mode); err != nil {
 		return launchPlan, err
 	}
-	launchPlan.Status.AMIs = amis
 
-	logging.FromContext(ctx).Debug("Resolving EC2 Instances")
-	instanceTypes, err := v.instanceTypeWatcher.Resolve(ctx, launchPlan.Spec.InstanceTypeSelectors)
+	nodeGroups, err := plans.OrderNodeGroups(launchPlan.Spec.EffectiveNodeGroups())
+	if err != nil {
+		return launchPlan, err
+	}
+	if err := validateShipLogs(launchPlan.Spec, nodeGroups); err != nil {
+		return launchPlan, err
+	}
+	for _, group := range nodeGroups {
+		if err := group.Allocation.Validate(group.CapacityType); err != nil {
+			return launchPlan, fmt.Errorf("invalid allocation of node group %q: %w", group.Name, err)
+		}
+	}
+	launchPlan.Status.SpecChecksum = launchPlan.Spec.Checksum()
+	existingInstances, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{
+		Tags: tagutils.NamespacedTags(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name),
+	}})
 	if err != nil {
 		return launchPlan, err
 	}
-	launchPlan.Status.InstanceTypes = instanceTypes
+	launchPlan.Metadata.Generation = planGeneration(existingInstances, launchPlan.Status.SpecChecksum)
+	launchPlan.Status.Reconciliation = plans.Reconcile(launchPlan.Spec, nodeGroups, existingInstances)
+	logging.FromContext(ctx).Debug("Reconciling existing instances", "action", launchPlan.Status.Reconciliation.Action,
+		"up-to-date", len(launchPlan.Status.Reconciliation.UpToDate), "out-of-date", len(launchPlan.Status.Reconciliation.OutOfDate))
+	if launchPlan.Status.Reconciliation.Action == plans.ReconcileNone {
+		return alreadyReconciled(launchPlan, nodeGroups), nil
+	}
+
+	launchPlan.Status.Conditions.Set(plans.ConditionAMIsResolved, plans.ConditionUnknown, "Resolving AMIs and instance types")
+	if resolved != nil {
+		useResolution(&launchPlan, *resolved)
+	} else if err := v.resolve(ctx, dryRun, &launchPlan, nodeGroups); err != nil {
+		return launchPlan, err
+	}
+	launchPlan.Status.Conditions.Set(plans.ConditionAMIsResolved, plans.ConditionTrue, fmt.Sprintf("Resolved AMIs and instance types for %d node groups", len(nodeGroups)))
+	timer.finish(plans.PhaseResolution)
 
 	// Validate that if either of SubnetSelectors or SecurityGroupSelectors are not specified, then BOTH should not be specified
 	// IF a SubnetSelector is not specified, that means there is no place to launch instances, so we try to create new network infra (VPC, IGW, Subnets, Route Table, and Security Group)
@@ -94,72 +321,129 @@ func (v AWSVM) Launch(ctx context.Context, dryRun bool, launchPlan plans.LaunchP
 	if len(launchPlan.Spec.SubnetSelectors) != 0 && len(launchPlan.Spec.SecurityGroupSelectors) == 0 {
 		return launchPlan, fmt.Errorf("subnet selector was specified without a security group selector")
 	}
+	if len(launchPlan.Spec.SubnetSelectors) != 0 && launchPlan.Spec.UseDefaultVPC {
+		return launchPlan, fmt.Errorf("default VPC was requested along with a subnet selector")
+	}
+	if err := validateIngressRules(launchPlan.Spec); err != nil {
+		return launchPlan, err
+	}
+	networkPolicy := lo.CoalesceOrEmpty(launchPlan.Spec.NetworkPolicy, plans.NetworkPolicyShared)
+	if networkPolicy != plans.NetworkPolicyShared && networkPolicy != plans.NetworkPolicyIsolated {
+		return launchPlan, fmt.Errorf("invalid network policy %q, must be %s or %s", networkPolicy, plans.NetworkPolicyShared, plans.NetworkPolicyIsolated)
+	}
+	networkTopology := lo.CoalesceOrEmpty(launchPlan.Spec.NetworkTopology, plans.NetworkTopologyPublic)
+	if err := validateNetworkTopology(networkTopology); err != nil {
+		return launchPlan, err
+	}
+	if err := launchPlan.Spec.Network.Validate(); err != nil {
+		return launchPlan, err
+	}
+	if err := validateIPFamily(launchPlan.Spec.Network, networkTopology); err != nil {
+		return launchPlan, err
+	}
+	if err := validateNaming(launchPlan, nodeGroups); err != nil {
+		return launchPlan, err
+	}
+	if err := v.checkCompliance(ctx, &launchPlan); err != nil {
+		return launchPlan, err
+	}
 
+	launchPlan.Status.Conditions.Set(plans.ConditionNetworkReady, plans.ConditionUnknown, "Resolving network")
 	var vpc *vpcs.VPC
 	var subnetList []subnets.Subnet
 	var securityGroups []securitygroups.SecurityGroup
-	if len(launchPlan.Spec.SubnetSelectors) != 0 {
+	if resolved != nil && len(resolved.Subnets) != 0 {
+		logging.FromContext(ctx).Debug("Using the resolved network of the plan")
+		launchPlan.Status.VPC = resolved.VPC
+		launchPlan.Status.Subnets = resolved.Subnets
+		subnetList = resolved.Subnets
+		if resolved.VPC.VpcId != nil {
+			vpc = &launchPlan.Status.VPC
+		}
+	} else if len(launchPlan.Spec.SubnetSelectors) != 0 {
 		logging.FromContext(ctx).Debug("Resolving Subnets")
 		subnetList, err = v.subnetWatcher.Resolve(ctx, launchPlan.Spec.SubnetSelectors)
 		if err != nil {
 			return launchPlan, err
 		}
 		launchPlan.Status.Subnets = subnetList
+	} else if launchPlan.Spec.UseDefaultVPC {
+		logging.FromContext(ctx).Debug("No subnet selectors specified, resolving the default VPC")
+		vpc, subnetList, err = v.resolveDefaultNetwork(ctx)
+		if err != nil {
+			return launchPlan, err
+		}
+		launchPlan.Status.VPC = *vpc
+		launchPlan.Status.Subnets = subnetList
 	} else {
-		logging.FromContext(ctx).Debug("No subnet selectors specified, checking if a VPC already exists")
-		existingVPCs, err := v.vpcWatcher.Resolve(ctx, []vpcs.Selector{{
-			Tags: map[string]string{
-				tagutils.NamespaceTagKey: launchPlan.Metadata.Namespace,
-			},
-		}})
+		logging.FromContext(ctx).Debug("No subnet selectors specified, checking if a VPC already exists", "network-policy", networkPolicy, "network-topology", networkTopology)
+		existingVPCs, err := v.resolveNetworkPolicyVPCs(ctx, networkPolicy, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
 		if err != nil {
 			return launchPlan, err
 		}
+		networkName, networkTags := networkOwnership(networkPolicy, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
 
-		if len(existingVPCs) == 0 {
+		if len(existingVPCs) == 0 && dryRun {
+			logging.FromContext(ctx).Debug("No existing VPC found, planning a new network")
+			if err := v.planNetwork(ctx, &launchPlan, networkName, networkTopology); err != nil {
+				return launchPlan, err
+			}
+		} else if len(existingVPCs) == 0 {
 			logging.FromContext(ctx).Debug("No existing VPC found, constructing a new network")
-			logging.FromContext(ctx).Debug("Creating a VPC")
-			vpc, err = v.vpcWatcher.Create(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, "10.0.0.0/16")
+			network := launchPlan.Spec.Network.WithDefaults()
+			logging.FromContext(ctx).Debug("Resolving Availability Zones")
+			availabilityZones, err := v.networkAZs(ctx, launchPlan.Spec.Network)
 			if err != nil {
 				return launchPlan, err
 			}
-			launchPlan.Status.VPC = *vpc
-
-			logging.FromContext(ctx).Debug("Resolving Availability Zones")
-			availabilityZones, err := v.azWatcher.Resolve(ctx, []azs.Selector{{Region: v.awsCfg.Region}})
+			subnetSpecs, err := publicSubnetSpecs(network, availabilityZones)
 			if err != nil {
 				return launchPlan, err
 			}
 
-			subnetSpecs := lo.Map(lo.Subset(availabilityZones, 0, 3), func(az azs.AvailabilityZone, i int) subnets.SubnetSpec {
-				return subnets.SubnetSpec{
-					AZ:     *az.ZoneName,
-					CIDR:   fmt.Sprintf("10.0.%d.0/24", i),
-					Public: true,
+			logging.FromContext(ctx).Debug("Creating a VPC", "cidr", network.VPCCIDR, "ip-family", network.IPFamily)
+			vpc, err = v.vpcWatcher.Create(ctx, launchPlan.Metadata.Namespace, networkName, network.VPCCIDR, network.HasIPv6(), networkTags)
+			if vpc != nil {
+				launchPlan.Status.VPC = *vpc
+			}
+			if err != nil {
+				return launchPlan, err
+			}
+			if network.HasIPv6() {
+				subnetSpecs, err = withIPv6CIDRs(subnetSpecs, vpc.IPv6CIDR(), false, network.IPFamily == plans.IPFamilyIPv6)
+				if err != nil {
+					return launchPlan, err
 				}
-			})
+			}
 
 			logging.FromContext(ctx).Debug("Creating subnets")
-			subnetList, err = v.subnetWatcher.Create(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, vpc, subnetSpecs)
+			subnetList, err = v.subnetWatcher.Create(ctx, launchPlan.Metadata.Namespace, networkName, vpc, subnetSpecs, networkTags)
 			if err != nil {
 				return launchPlan, err
 			}
-			launchPlan.Status.Subnets = subnetList
 
 			logging.FromContext(ctx).Debug("Creating Internet Gateway")
-			igw, err := v.igwWatcher.Create(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, *vpc)
+			igw, err := v.igwWatcher.Create(ctx, launchPlan.Metadata.Namespace, networkName, *vpc, networkTags)
 			if err != nil {
 				return launchPlan, err
 			}
 			launchPlan.Status.InternetGateway = *igw
 
 			logging.FromContext(ctx).Debug("Creating public route table")
-			publicRouteTable, _, err := v.routeTableWatcher.Create(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, subnetList, igw, nil)
+			publicRouteTable, _, err := v.routeTableWatcher.Create(ctx, launchPlan.Metadata.Namespace, networkName, subnetList, igw, nil, nil, networkTags)
 			if err != nil {
 				return launchPlan, err
 			}
 			launchPlan.Status.RouteTables = append(launchPlan.Status.RouteTables, *publicRouteTable)
 
+			if hasPrivateSubnets(networkTopology) {
+				subnetList, err = v.addPrivateSubnets(ctx, &launchPlan, vpc, subnetList, networkName, networkTags)
+				if err != nil {
+					return launchPlan, err
+				}
+			}
+			subnetList = topologySubnets(networkTopology, subnetList)
+			launchPlan.Status.Subnets = subnetList
 		} else {
 			logging.FromContext(ctx).Debug("Found existing VPC")
 			vpc = &existingVPCs[0]
@@ -171,19 +455,56 @@ func (v AWSVM) Launch(ctx context.Context, dryRun bool, launchPlan plans.LaunchP
 				return launchPlan, err
 			}
 			launchPlan.Status.VPC = *vpc
+			if hasPrivateSubnets(networkTopology) && dryRun {
+				planPrivateSubnets(&launchPlan, subnetList, networkName)
+			} else if hasPrivateSubnets(networkTopology) {
+				subnetList, err = v.addPrivateSubnets(ctx, &launchPlan, vpc, subnetList, networkName, networkTags)
+				if err != nil {
+					return launchPlan, err
+				}
+			}
+			subnetList = topologySubnets(networkTopology, subnetList)
+			if len(subnetList) == 0 && !dryRun {
+				return launchPlan, fmt.Errorf("no subnets for the %s network topology in VPC %s", networkTopology, *vpc.VpcId)
+			}
+			launchPlan.Status.Subnets = subnetList
 		}
+	}
 
+	if resolved != nil && len(resolved.SecurityGroups) != 0 {
+		launchPlan.Status.SecurityGroups = resolved.SecurityGroups
+	} else if len(launchPlan.Spec.SubnetSelectors) == 0 {
 		logging.FromContext(ctx).Debug("Resolving Security Groups")
 		securityGroups, err = v.securityGroupWatcher.Resolve(ctx, []securitygroups.Selector{{
 			Tags: tagutils.NamespacedTags(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name),
 		}})
+		if err != nil {
+			return launchPlan, err
+		}
+		securityGroups = lo.Reject(securityGroups, func(securityGroup securitygroups.SecurityGroup, _ int) bool {
+			return hasGroupTag(securityGroup.Tags)
+		})
 
-		if len(securityGroups) == 0 {
+		if len(securityGroups) == 0 && dryRun {
+			logging.FromContext(ctx).Debug("No Security Groups found, planning a Security Group")
+			if err := v.planSecurityGroup(ctx, &launchPlan, "", nil); err != nil {
+				return launchPlan, err
+			}
+		} else if len(securityGroups) == 0 {
 			logging.FromContext(ctx).Debug("No Security Groups found")
 			logging.FromContext(ctx).Debug("Creating Security Group")
+			sgName, err := resourceName(launchPlan, naming.SecurityGroup, "", "")
+			if err != nil {
+				return launchPlan, err
+			}
+			vpcID, err := launchVPCID(launchPlan)
+			if err != nil {
+				return launchPlan, err
+			}
 			sgID, err := v.securityGroupWatcher.CreateSecurityGroup(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, securitygroups.CreateSecurityGroupOpts{
-				Name:  fmt.Sprintf("%s/%s", launchPlan.Metadata.Namespace, launchPlan.Metadata.Name),
-				VPCID: *vpc.VpcId,
+				Name:       sgName,
+				VPCID:      vpcID,
+				IPv6Egress: hasIPv6Subnets(subnetList),
 			})
 			if err != nil {
 				return launchPlan, err
@@ -198,7 +519,7 @@ func (v AWSVM) Launch(ctx context.Context, dryRun bool, launchPlan plans.LaunchP
 		launchPlan.Status.SecurityGroups = securityGroups
 	}
 
-	if len(launchPlan.Spec.SecurityGroupSelectors) != 0 {
+	if len(launchPlan.Spec.SecurityGroupSelectors) != 0 && len(launchPlan.Status.SecurityGroups) == 0 {
 		logging.FromContext(ctx).Debug("Resolving Security Groups")
 		securityGroups, err = v.securityGroupWatcher.Resolve(ctx, launchPlan.Spec.SecurityGroupSelectors)
 		if err != nil {
@@ -207,50 +528,584 @@ func (v AWSVM) Launch(ctx context.Context, dryRun bool, launchPlan plans.LaunchP
 		launchPlan.Status.SecurityGroups = securityGroups
 	}
 
-	logging.FromContext(ctx).Debug("Creating Launch Template")
-	launchTemplateID, err := v.launchTemplateWatcher.CreateLaunchTemplate(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, launchPlan.Spec.UserData, launchPlan.Status.SecurityGroups)
-	if err != nil && !ec2utils.IsAlreadyExistsErr(err) {
+	if len(launchPlan.Spec.IngressRules) != 0 && !dryRun {
+		if err := v.authorizeIngressRules(ctx, launchPlan); err != nil {
+			return launchPlan, err
+		}
+	}
+	if lo.SomeBy(nodeGroups, func(group plans.NodeGroup) bool { return len(group.IngressRules) != 0 }) {
+		if err := v.createNodeGroupSecurityGroups(ctx, &launchPlan, nodeGroups); err != nil {
+			return launchPlan, err
+		}
+	}
+	if dryRun && len(launchPlan.Status.PlannedResources) != 0 {
+		launchPlan.Status.Conditions.Set(plans.ConditionNetworkReady, plans.ConditionFalse,
+			fmt.Sprintf("Dry-run, %d network resources would be created", len(launchPlan.Status.PlannedResources)))
+	} else {
+		launchPlan.Status.Conditions.Set(plans.ConditionNetworkReady, plans.ConditionTrue,
+			fmt.Sprintf("%d subnets and %d security groups are ready", len(launchPlan.Status.Subnets), len(launchPlan.Status.SecurityGroups)))
+	}
+	timer.finish(plans.PhaseNetwork)
+
+	if dryRun {
+		for i, group := range nodeGroups {
+			if err := v.planNodeGroup(ctx, &launchPlan, group, i); err != nil {
+				return launchPlan, err
+			}
+		}
+		v.planVolumes(ctx, &launchPlan, nodeGroups)
+		if err := v.planArtifacts(&launchPlan); err != nil {
+			return launchPlan, err
+		}
+		if err := v.planLogGroup(ctx, &launchPlan); err != nil {
+			return launchPlan, err
+		}
+		launchPlan.Status.Conditions.Set(plans.ConditionFleetLaunched, plans.ConditionFalse,
+			fmt.Sprintf("Dry-run, %d resources would be created", len(launchPlan.Status.PlannedResources)))
+		logging.FromContext(ctx).Debug("Completed Launch Plan Dry-Run Successfully")
+		return flattenSingleNodeGroup(launchPlan), nil
+	}
+
+	if err := v.stageArtifacts(ctx, &launchPlan); err != nil {
+		return launchPlan, err
+	}
+	if err := v.ensureLogGroup(ctx, &launchPlan); err != nil {
+		return launchPlan, err
+	}
+	launchPlan.Status.Conditions.Set(plans.ConditionFleetLaunched, plans.ConditionUnknown, "Launching fleets")
+	for i, group := range nodeGroups {
+		if len(group.DependsOn) != 0 {
+			group.UserData, err = v.renderDependentUserData(ctx, &launchPlan, nodeGroups, group)
+			if err != nil {
+				return launchPlan, err
+			}
+		}
+		groupStatus, err := v.launchNodeGroup(ctx, launchPlan, group, launchPlan.Status.NodeGroups[i])
+		launchPlan.Status.NodeGroups[i] = groupStatus
+		launchPlan.Status.Instances = lo.FlatMap(launchPlan.Status.NodeGroups, func(groupStatus plans.NodeGroupStatus, _ int) []instances.Instance {
+			return groupStatus.Instances
+		})
+		launchPlan.Status.LaunchFailures = lo.FlatMap(launchPlan.Status.NodeGroups, func(groupStatus plans.NodeGroupStatus, _ int) []fleets.LaunchFailure {
+			return groupStatus.LaunchFailures
+		})
+		if fleets.IsNoInstancesLaunched(err) {
+			launchPlan.Status.Conditions.Set(plans.ConditionFleetLaunched, plans.ConditionFalse, err.Error())
+		}
+		if err != nil {
+			return launchPlan, err
+		}
+	}
+
+	if launchPlan.Status.Reconciliation.Action == plans.ReconcileUpdate {
+		launchPlan.Status.Conditions.Set(plans.ConditionFleetLaunched, plans.ConditionFalse,
+			fmt.Sprintf("Created the launch templates of the changed spec and kept %d instances of an earlier spec, replace them to launch the spec", len(launchPlan.Status.Reconciliation.OutOfDate)))
+		logging.FromContext(ctx).Debug("Completed Launch Plan Update Successfully")
+		return flattenSingleNodeGroup(launchPlan), nil
+	}
+	launchPlan.Status.Conditions.Set(plans.ConditionFleetLaunched, plans.ConditionTrue, fmt.Sprintf("Launched %d instances", len(newInstances(launchPlan))))
+	timer.finish(plans.PhaseFleet)
+	if err := v.createVolumes(ctx, &launchPlan); err != nil {
+		return launchPlan, err
+	}
+	if launchPlan.Spec.WaitForBootstrap {
+		if err := v.waitForBootstrap(ctx, &launchPlan, nodeGroups, timer); err != nil {
+			return launchPlan, err
+		}
+	}
+	if err := v.waitFor(ctx, &launchPlan); err != nil {
 		return launchPlan, err
 	}
+	setInstancesRunningCondition(&launchPlan)
+	// the instances of the earlier spec are only terminated once their replacements launched successfully
+	if launchPlan.Status.Reconciliation.Action == plans.ReconcileReplace {
+		if err := v.terminateOutOfDate(ctx, launchPlan); err != nil {
+			return launchPlan, err
+		}
+	}
 
-	launchTemplates, err := v.launchTemplateWatcher.Resolve(ctx, []launchtemplates.Selector{{
-		Tags: tagutils.NamespacedTags(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name),
-	}})
+	// the launch succeeded, so failing to publish its timings is only a warning
+	if err := v.publishTimings(ctx, launchPlan); err != nil {
+		logging.FromContext(ctx).Warn("Failed to publish launch timings", "error", err)
+	}
+
+	logging.FromContext(ctx).Debug("Completed Launch Plan Execution Successfully")
+	return flattenSingleNodeGroup(launchPlan), nil
+}
+
+// resolve resolves the KMS keys, key pair, and reservations of the spec and the instance types, AMIs, and role of every node group.
+// Dry-runs and verbose launches also estimate the cost of the node groups.
+func (v AWSVM) resolve(ctx context.Context, dryRun bool, launchPlan *plans.LaunchPlan, nodeGroups []plans.NodeGroup) error {
+	var err error
+	launchPlan.Status.EBSKMSKey, err = v.resolveEBSKMSKey(ctx, launchPlan.Spec.EBSKMSKey)
 	if err != nil {
-		return launchPlan, err
+		return err
 	}
-	if len(launchTemplates) > 1 {
-		return launchPlan, fmt.Errorf("expected 1 launch template resolved by ID, but found %d", len(launchTemplates))
+	launchPlan.Status.BlockDeviceMappings, err = v.resolveBlockDeviceMappings(ctx, launchPlan.Spec.BlockDeviceMappings)
+	if err != nil {
+		return err
+	}
+	// volumes are created after instances launch, so their KMS keys are checked before anything is launched
+	if _, err := v.resolveBlockDeviceMappings(ctx, launchPlan.Spec.Volumes); err != nil {
+		return err
 	}
-	if len(launchTemplates) == 0 {
-		return launchPlan, fmt.Errorf("could not find launch template details for launch template %s", launchTemplateID)
+	launchPlan.Status.KeyPair, err = v.resolveKeyPair(ctx, launchPlan.Spec)
+	if err != nil {
+		return err
+	}
+	if launchPlan.Spec.PreferReservations {
+		launchPlan.Status.Reservations, err = v.resolveReservations(ctx)
+		if err != nil {
+			return err
+		}
 	}
-	launchPlan.Status.LaunchTemplate = launchTemplates[0]
 
-	logging.FromContext(ctx).Debug("Creating EC2 Fleet")
-	fleetID, err := v.fleetWatcher.CreateFleet(ctx, fleets.CreateFleetOptions{
-		Name:           launchPlan.Metadata.Name,
-		Namespace:      launchPlan.Metadata.Namespace,
-		LaunchTemplate: launchPlan.Status.LaunchTemplate,
-		InstanceTypes:  launchPlan.Status.InstanceTypes,
-		Subnets:        launchPlan.Status.Subnets,
-		AMIs:           launchPlan.Status.AMIs,
-		IAMRole:        launchPlan.Spec.IAMRole,
-		CapacityType:   launchPlan.Spec.CapacityType,
+	for _, group := range nodeGroups {
+		logging.FromContext(ctx).Debug("Resolving EC2 Instances", "group", group.Name)
+		instanceTypes, err := v.instanceTypeWatcher.Resolve(ctx, group.InstanceTypeSelectors)
+		if err != nil {
+			return err
+		}
+
+		// AMIs are resolved after instance types since GPU instance types may choose the Deep Learning AMI
+		logging.FromContext(ctx).Debug("Resolving AMIs", "group", group.Name)
+		amiList, err := v.amiWatcher.Resolve(ctx, gpuAMISelectors(launchPlan.Spec, group, instanceTypes))
+		if err != nil {
+			return err
+		}
+		role, err := v.resolveRole(ctx, group)
+		if err != nil {
+			return err
+		}
+		groupStatus := plans.NodeGroupStatus{
+			Name:             group.Name,
+			AMIs:             amiList,
+			ArchitectureAMIs: amis.ArchitectureIDs(amiList),
+			InstanceTypes:    instanceTypes,
+			Role:             role,
+		}
+		warnGPUDriversAMIs(ctx, launchPlan.Spec, groupStatus)
+		launchPlan.Status.NodeGroups = append(launchPlan.Status.NodeGroups, groupStatus)
+	}
+
+	// the estimate resolves the price of every instance type, so it is only made when it is shown
+	if dryRun || logging.FromContext(ctx).Enabled(ctx, slog.LevelDebug) {
+		estimate, err := v.estimateCost(ctx, *launchPlan, nodeGroups)
+		if err != nil {
+			// the estimate is informational, e.g. the caller may not be allowed to use the Pricing API
+			logging.FromContext(ctx).Warn("Unable to estimate the cost of the launch", "error", err)
+		}
+		launchPlan.Status.EstimatedCost = estimate
+	}
+	return nil
+}
+
+// flattenSingleNodeGroup moves the status of the implicit node group of a plan without node groups to the top level of the status
+func flattenSingleNodeGroup(launchPlan plans.LaunchPlan) plans.LaunchPlan {
+	if len(launchPlan.Spec.NodeGroups) == 0 {
+		launchPlan.Status.AMIs = launchPlan.Status.NodeGroups[0].AMIs
+		launchPlan.Status.ArchitectureAMIs = launchPlan.Status.NodeGroups[0].ArchitectureAMIs
+		launchPlan.Status.InstanceTypes = launchPlan.Status.NodeGroups[0].InstanceTypes
+		launchPlan.Status.LaunchTemplate = launchPlan.Status.NodeGroups[0].LaunchTemplate
+		launchPlan.Status.LaunchTemplateVersion = launchPlan.Status.NodeGroups[0].LaunchTemplateVersion
+		launchPlan.Status.InstanceProfile = launchPlan.Status.NodeGroups[0].InstanceProfile
+		launchPlan.Status.Role = launchPlan.Status.NodeGroups[0].Role
+		launchPlan.Status.NodeGroups = nil
+	}
+	return launchPlan
+}
+
+// setInstancesRunningCondition sets the InstancesRunning condition from the state of the launched instances
+func setInstancesRunningCondition(launchPlan *plans.LaunchPlan) {
+	running := lo.CountBy(launchPlan.Status.Instances, func(instance instances.Instance) bool {
+		return instance.State != nil && instance.State.Name == ec2types.InstanceStateNameRunning
 	})
+	total := len(launchPlan.Status.Instances)
+	launchPlan.Status.Conditions.Set(plans.ConditionInstancesRunning, lo.Ternary(running == total, plans.ConditionTrue, plans.ConditionFalse),
+		fmt.Sprintf("%d of %d instances are running", running, total))
+}
+
+// launchNodeGroup creates the node group's launch template and launches its instances into the plan's resolved network
+func (v AWSVM) launchNodeGroup(ctx context.Context, launchPlan plans.LaunchPlan, group plans.NodeGroup, groupStatus plans.NodeGroupStatus) (plans.NodeGroupStatus, error) {
+	tags, groupTags := nodeGroupTags(launchPlan, group)
+	instanceProfile, err := v.ensureInstanceProfile(ctx, launchPlan, groupStatus)
 	if err != nil {
-		return launchPlan, err
+		return groupStatus, err
+	}
+	groupStatus.InstanceProfile = instanceProfile
+	if err := v.allowLogShipping(ctx, launchPlan, instanceProfile); err != nil {
+		return groupStatus, err
+	}
+	createOpts, err := nodeGroupLaunchTemplateOptions(launchPlan, group, groupStatus)
+	if err != nil {
+		return groupStatus, err
+	}
+	groupStatus.LaunchTemplate, groupStatus.LaunchTemplateVersion, err = v.ensureLaunchTemplate(ctx, launchPlan, group, createOpts, tags)
+	if err != nil {
+		return groupStatus, err
+	}
+
+	// the group keeps its up to date instances and only launches the missing ones
+	groupStatus.Instances = upToDateInstances(launchPlan, group)
+	group, launch := reconcileGroup(launchPlan, group)
+	if !launch {
+		return groupStatus, nil
+	}
+	if len(launchPlan.Spec.Placements) == 0 {
+		launchedInstances, failures, err := v.launchFleet(ctx, launchPlan, group, groupStatus, launchPlan.Status.Subnets, groupTags)
+		groupStatus.LaunchFailures = append(groupStatus.LaunchFailures, failures...)
+		if err != nil {
+			return groupStatus, err
+		}
+		groupStatus.Instances = append(groupStatus.Instances, launchedInstances...)
+	}
+	for i, placement := range launchPlan.Spec.Placements {
+		if !slices.Contains(launchPlan.Status.Reconciliation.MissingPlacements, i) {
+			continue
+		}
+		placementSubnets := lo.Filter(launchPlan.Status.Subnets, func(subnet subnets.Subnet, _ int) bool { return placement.Matches(subnet) })
+		if len(placementSubnets) == 0 {
+			return groupStatus, fmt.Errorf("placement for instance %d does not match any resolved subnets", i)
+		}
+		logging.FromContext(ctx).Debug("Launching placed instance", "index", i, "subnets", len(placementSubnets))
+		placedGroup := group
+		placedGroup.Count = 1
+		launchedInstances, failures, err := v.launchFleet(ctx, launchPlan, placedGroup, groupStatus, placementSubnets, map[string]string{tagutils.IndexTagKey: strconv.Itoa(i)})
+		groupStatus.LaunchFailures = append(groupStatus.LaunchFailures, failures...)
+		if err != nil {
+			return groupStatus, err
+		}
+		groupStatus.Instances = append(groupStatus.Instances, launchedInstances...)
+	}
+	return groupStatus, nil
+}
+
+// nodeGroupTags returns the tags of the node group's resources and the group tags that are only set for named node groups
+func nodeGroupTags(launchPlan plans.LaunchPlan, group plans.NodeGroup) (map[string]string, map[string]string) {
+	tags := tagutils.NamespacedTags(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
+	var groupTags map[string]string
+	if group.Name != "" {
+		groupTags = map[string]string{tagutils.GroupTagKey: group.Name}
+		tags = lo.Assign(tags, groupTags)
+	}
+	return tags, groupTags
+}
+
+// nodeGroupLaunchTemplateOptions returns the options of the node group's launch template, which are hashed to find an existing launch template
+func nodeGroupLaunchTemplateOptions(launchPlan plans.LaunchPlan, group plans.NodeGroup, groupStatus plans.NodeGroupStatus) (launchtemplates.CreateLaunchTemplateOptions, error) {
+	securityGroups := launchPlan.Status.SecurityGroups
+	if groupStatus.SecurityGroup.GroupId != nil {
+		securityGroups = append(slices.Clone(securityGroups), groupStatus.SecurityGroup)
 	}
 
-	fleets, err := v.fleetWatcher.Resolve(ctx, []fleets.Selector{{ID: fleetID}})
+	rootVolume := launchPlan.Spec.RootVolume
+	if mapping, ok := rootMapping(launchPlan.Status.BlockDeviceMappings); ok {
+		rootVolume = mapping
+	}
+	rootVolume, err := rootBlockDevice(rootVolume, groupStatus.AMIs)
 	if err != nil {
-		return launchPlan, err
+		return launchtemplates.CreateLaunchTemplateOptions{}, fmt.Errorf("node group %s: %w", group.Name, err)
+	}
+	blockDevices := []launchtemplates.BlockDevice{rootVolume}
+	for _, mapping := range launchPlan.Status.BlockDeviceMappings {
+		if mapping.DeviceName != launchtemplates.RootDevice {
+			blockDevices = append(blockDevices, mapping)
+		}
+	}
+	// volumes without their own key are encrypted with the plan's EBS KMS key
+	for i := range blockDevices {
+		if blockDevices[i].IsEncrypted() {
+			blockDevices[i].KMSKeyID = lo.CoalesceOrEmpty(blockDevices[i].KMSKeyID, lo.FromPtr(launchPlan.Status.EBSKMSKey.Arn))
+		}
+	}
+
+	createOpts := launchtemplates.CreateLaunchTemplateOptions{
+		Namespace:          launchPlan.Metadata.Namespace,
+		Name:               launchPlan.Metadata.Name,
+		Group:              group.Name,
+		UserData:           group.UserData,
+		SecurityGroups:     securityGroups,
+		BlockDevices:       blockDevices,
+		KeyName:            lo.FromPtr(launchPlan.Status.KeyPair.KeyName),
+		InstanceProfileArn: groupStatus.InstanceProfile.Arn,
+		MetadataOptions:    launchPlan.Spec.MetadataOptions,
+		NetworkInterfaces:  launchPlan.Spec.NetworkInterfaces,
+	}
+	// the user-data of groups with dependencies is rendered when their dependencies are ready
+	if len(group.DependsOn) == 0 {
+		createOpts.UserData, err = userdata.Render(createOpts.UserData, userDataMetadata(launchPlan, group))
+		if err != nil {
+			return launchtemplates.CreateLaunchTemplateOptions{}, fmt.Errorf("node group %s: %w", group.Name, err)
+		}
+	}
+	if launchPlan.Status.Artifacts.Download != "" {
+		createOpts.UserData, err = userdata.WithArtifacts(createOpts.UserData, launchPlan.Status.Artifacts.Download)
+		if err != nil {
+			return launchtemplates.CreateLaunchTemplateOptions{}, fmt.Errorf("node group %s: %w", group.Name, err)
+		}
+	}
+	if installsGPUDrivers(launchPlan.Spec, groupStatus) {
+		createOpts.UserData, err = userdata.WithGPUDrivers(createOpts.UserData)
+		if err != nil {
+			return launchtemplates.CreateLaunchTemplateOptions{}, fmt.Errorf("node group %s: %w", group.Name, err)
+		}
+	}
+	if launchPlan.Spec.ShipLogs.LogGroup != "" {
+		agentConfig := logs.AgentConfig(launchPlan.Spec.ShipLogs.LogGroup, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
+		createOpts.UserData, err = userdata.WithLogShipping(createOpts.UserData, logs.AgentConfigPath, agentConfig)
+		if err != nil {
+			return launchtemplates.CreateLaunchTemplateOptions{}, fmt.Errorf("node group %s: %w", group.Name, err)
+		}
+	}
+	// instances with a TTL shut themselves down, which terminates them.
+	// The shutdown is scheduled before anything else in the user-data runs, so it is added last.
+	if launchPlan.Spec.TTL > 0 {
+		createOpts.UserData, err = userdata.WithShutdown(createOpts.UserData, launchPlan.Spec.TTL)
+		if err != nil {
+			return launchtemplates.CreateLaunchTemplateOptions{}, fmt.Errorf("node group %s: %w", group.Name, err)
+		}
+		createOpts.ShutdownBehavior = string(ec2types.ShutdownBehaviorTerminate)
+	}
+	// compressing is last since the user-data can not be changed once it is gzipped
+	createOpts.UserData, err = userdata.Compress(createOpts.UserData)
+	if err != nil {
+		return launchtemplates.CreateLaunchTemplateOptions{}, fmt.Errorf("node group %s: %w", group.Name, err)
 	}
-	if len(fleets) == 0 {
-		return launchPlan, fmt.Errorf("could not find fleet for %s", fleetID)
+	return createOpts, nil
+}
+
+// rootBlockDevice returns the root volume for a launch template shared by the AMIs.
+// Fleet may launch any of the AMIs from the launch template, so they must have the same root device name for the volume to replace their root volume.
+func rootBlockDevice(rootVolume launchtemplates.BlockDevice, amiList []amis.AMI) (launchtemplates.BlockDevice, error) {
+	rootDeviceNames := lo.Uniq(lo.Map(amiList, func(ami amis.AMI, _ int) string { return lo.FromPtr(ami.RootDeviceName) }))
+	if len(rootDeviceNames) != 1 || rootDeviceNames[0] == "" {
+		return rootVolume, fmt.Errorf("expected the AMIs to have a single root device name, but found %v", rootDeviceNames)
 	}
+	rootVolume.DeviceName = rootDeviceNames[0]
+	return rootVolume, nil
+}
+
+// rootMapping returns the block device mapping that configures the root volume
+func rootMapping(mappings []launchtemplates.BlockDevice) (launchtemplates.BlockDevice, bool) {
+	return lo.Find(mappings, func(mapping launchtemplates.BlockDevice) bool {
+		return mapping.DeviceName == launchtemplates.RootDevice
+	})
+}
 
-	instanceIDSelectors := lo.FlatMap(fleets[0].Instances, func(fleet ec2types.DescribeFleetsInstances, _ int) []instances.Selector {
+// resolveBlockDeviceMappings resolves the KMS keys of the block device mappings to the key ARNs that launch templates require
+func (v AWSVM) resolveBlockDeviceMappings(ctx context.Context, mappings []launchtemplates.BlockDevice) ([]launchtemplates.BlockDevice, error) {
+	resolved := slices.Clone(mappings)
+	for i, mapping := range resolved {
+		if mapping.KMSKeyID == "" {
+			continue
+		}
+		key, err := v.resolveEBSKMSKey(ctx, mapping.KMSKeyID)
+		if err != nil {
+			return nil, fmt.Errorf("device %s: %w", mapping.DeviceName, err)
+		}
+		resolved[i].KMSKeyID = lo.FromPtr(key.Arn)
+	}
+	return resolved, nil
+}
+
+// resolveEBSKMSKey resolves the KMS key that volumes are encrypted with and checks that EBS can use it in the region.
+// An empty key is returned for the account's default EBS key, which EC2 uses when no key is specified.
+func (v AWSVM) resolveEBSKMSKey(ctx context.Context, keySelector string) (kmskeys.Key, error) {
+	if keySelector == "" || keySelector == plans.EBSKMSKeyDefault {
+		return kmskeys.Key{}, nil
+	}
+	logging.FromContext(ctx).Debug("Resolving EBS KMS key", "key", keySelector)
+	keys, err := v.kmsKeyWatcher.Resolve(ctx, []kmskeys.Selector{{KeyID: keySelector}})
+	if err != nil {
+		return kmskeys.Key{}, err
+	}
+	if len(keys) != 1 {
+		return kmskeys.Key{}, fmt.Errorf("expected 1 kms key for %s, but found %d", keySelector, len(keys))
+	}
+	if err := keys[0].ValidateForEBS(v.awsCfg.Region); err != nil {
+		return kmskeys.Key{}, err
+	}
+	return keys[0], nil
+}
+
+// resolveKeyPair resolves the key pair that instances are launched with from the spec's KeyName or KeyPairSelectors.
+// An empty key pair is returned if the spec has neither.
+func (v AWSVM) resolveKeyPair(ctx context.Context, spec plans.LaunchSpec) (keypairs.KeyPair, error) {
+	if spec.KeyName != "" && len(spec.KeyPairSelectors) != 0 {
+		return keypairs.KeyPair{}, fmt.Errorf("a key name and key pair selectors cannot both be specified")
+	}
+	selectorList := spec.KeyPairSelectors
+	if spec.KeyName != "" {
+		selectorList = []keypairs.Selector{{Name: spec.KeyName}}
+	}
+	if len(selectorList) == 0 {
+		return keypairs.KeyPair{}, nil
+	}
+	logging.FromContext(ctx).Debug("Resolving key pair")
+	keyPairs, err := v.keyPairWatcher.Resolve(ctx, selectorList)
+	if err != nil {
+		return keypairs.KeyPair{}, err
+	}
+	if len(keyPairs) != 1 {
+		return keypairs.KeyPair{}, fmt.Errorf("expected 1 key pair, but found %d", len(keyPairs))
+	}
+	return keyPairs[0], nil
+}
+
+// resolveReservations returns the capacity of the account's active reserved instances that running instances do not use
+// and the instance families of the region's EC2 Instance Savings Plans
+func (v AWSVM) resolveReservations(ctx context.Context) (reservations.Coverage, error) {
+	logging.FromContext(ctx).Debug("Resolving reserved instance coverage")
+	runningInstances, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{State: string(ec2types.InstanceStateNameRunning)}})
+	if err != nil {
+		return reservations.Coverage{}, err
+	}
+	coverage, err := v.reservationWatcher.Coverage(ctx, runningInstances)
+	if err != nil {
+		return reservations.Coverage{}, err
+	}
+	logging.FromContext(ctx).Debug("Resolving savings plan coverage")
+	savingsPlans, err := v.savingsPlanWatcher.InstanceFamilies(ctx, v.awsCfg.Region)
+	if err != nil {
+		if !savingsplans.IsAccessDenied(err) {
+			return reservations.Coverage{}, err
+		}
+		// reserved instances are still preferred, e.g. when the caller is only allowed to describe EC2 resources
+		logging.FromContext(ctx).Warn("Unable to describe savings plans, only reserved instances are preferred", "error", err)
+		return coverage, nil
+	}
+	coverage.SavingsPlans = savingsplans.Commitments(savingsPlans)
+	return coverage, nil
+}
+
+// resolveNodeGroupLaunchTemplate returns the launch template of the node group that matches the tags.
+// Ungrouped launch templates are matched by the namespaced tags alone, so grouped launch templates of the same plan are excluded.
+func (v AWSVM) resolveNodeGroupLaunchTemplate(ctx context.Context, group plans.NodeGroup, tags map[string]string) ([]launchtemplates.LaunchTemplate, error) {
+	launchTemplates, err := v.launchTemplateWatcher.Resolve(ctx, []launchtemplates.Selector{{Tags: tags}})
+	if err != nil {
+		return nil, err
+	}
+	if group.Name == "" {
+		launchTemplates = lo.Reject(launchTemplates, func(lt launchtemplates.LaunchTemplate, _ int) bool { return hasGroupTag(lt.Tags) })
+	}
+	if len(launchTemplates) > 1 {
+		return nil, fmt.Errorf("expected 1 launch template resolved by tags, but found %d", len(launchTemplates))
+	}
+	return launchTemplates, nil
+}
+
+// launchVPCID returns the VPC ID of the launch plan's network.
+// The VPC is not resolved when subnets are selected or resolved by a previous plan, so the VPC of the subnets is used instead.
+func launchVPCID(launchPlan plans.LaunchPlan) (string, error) {
+	if vpcID := lo.FromPtr(launchPlan.Status.VPC.VpcId); vpcID != "" {
+		return vpcID, nil
+	}
+	if len(launchPlan.Status.Subnets) != 0 && lo.FromPtr(launchPlan.Status.Subnets[0].VpcId) != "" {
+		return *launchPlan.Status.Subnets[0].VpcId, nil
+	}
+	return "", fmt.Errorf("unable to determine the VPC of %s/%s, no VPC or subnets were resolved", launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
+}
+
+// createNodeGroupSecurityGroups creates a security group for every node group and authorizes the groups' ingress rules.
+// Rules from a node group are resolved to that group's security group.
+func (v AWSVM) createNodeGroupSecurityGroups(ctx context.Context, launchPlan *plans.LaunchPlan, nodeGroups []plans.NodeGroup) error {
+	vpcID, err := launchVPCID(*launchPlan)
+	if err != nil {
+		return err
+	}
+	groupSecurityGroupIDs := map[string]string{}
+	for i, group := range nodeGroups {
+		groupTags := map[string]string{tagutils.GroupTagKey: group.Name}
+		logging.FromContext(ctx).Debug("Resolving node group Security Group", "group", group.Name)
+		securityGroups, err := v.securityGroupWatcher.Resolve(ctx, []securitygroups.Selector{{
+			Tags: lo.Assign(tagutils.NamespacedTags(launchPlan.Metadata.Namespace, launchPlan.Metadata.Name), groupTags),
+		}})
+		if err != nil {
+			return err
+		}
+		if len(securityGroups) == 0 && launchPlan.Status.DryRun {
+			logging.FromContext(ctx).Debug("Planning node group Security Group", "group", group.Name)
+			if err := v.planSecurityGroup(ctx, launchPlan, group.Name, groupTags); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(securityGroups) == 0 {
+			logging.FromContext(ctx).Debug("Creating node group Security Group", "group", group.Name)
+			sgName, err := resourceName(*launchPlan, naming.SecurityGroup, group.Name, "")
+			if err != nil {
+				return err
+			}
+			sgID, err := v.securityGroupWatcher.CreateSecurityGroup(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, securitygroups.CreateSecurityGroupOpts{
+				Name:       sgName,
+				VPCID:      vpcID,
+				Tags:       groupTags,
+				IPv6Egress: hasIPv6Subnets(launchPlan.Status.Subnets),
+			})
+			if err != nil {
+				return err
+			}
+			securityGroups, err = v.securityGroupWatcher.Resolve(ctx, []securitygroups.Selector{{ID: sgID}})
+			if err != nil {
+				return err
+			}
+			if len(securityGroups) == 0 {
+				return fmt.Errorf("could not find security group %s for node group %s", sgID, group.Name)
+			}
+		}
+		launchPlan.Status.NodeGroups[i].SecurityGroup = securityGroups[0]
+		groupSecurityGroupIDs[group.Name] = *securityGroups[0].GroupId
+	}
+	if launchPlan.Status.DryRun {
+		// Ingress rules are authorized on the security groups when they are created
+		return nil
+	}
+
+	for _, group := range nodeGroups {
+		for _, rule := range group.IngressRules {
+			if rule.Group != "" {
+				rule.SecurityGroupID = groupSecurityGroupIDs[rule.Group]
+			}
+			logging.FromContext(ctx).Debug("Authorizing ingress rule", "group", group.Name, "protocol", rule.Protocol, "from-port", rule.FromPort, "to-port", rule.ToPort)
+			if err := v.securityGroupWatcher.AuthorizeIngress(ctx, groupSecurityGroupIDs[group.Name], []securitygroups.IngressRule{rule}); err != nil && !ec2utils.IsAlreadyExistsErr(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hasGroupTag returns true if the tags belong to a resource created for a node group
+func hasGroupTag(tags []ec2types.Tag) bool {
+	_, ok := tagutils.EC2TagsToMap(tags)[tagutils.GroupTagKey]
+	return ok
+}
+
+// launchFleet creates an instant EC2 Fleet that launches the node group's instances into the subnets and returns the launched instances
+func (v AWSVM) launchFleet(ctx context.Context, launchPlan plans.LaunchPlan, group plans.NodeGroup, groupStatus plans.NodeGroupStatus, subnetList []subnets.Subnet, tags map[string]string) ([]instances.Instance, []fleets.LaunchFailure, error) {
+	logging.FromContext(ctx).Debug("Creating EC2 Fleet", "group", group.Name, "count", group.Count, "capacity", group.Capacity.Value, "capacity-unit", group.Capacity.Unit)
+	createOpts := fleetOptions(launchPlan, group, groupStatus, subnetList, tags)
+	warnDroppedInstanceTypes(ctx, group, createOpts)
+	fleetID, failures, err := v.fleetWatcher.CreateFleet(ctx, createOpts)
+	if err != nil {
+		return nil, failures, err
+	}
+	if len(failures) != 0 {
+		logging.FromContext(ctx).Warn("EC2 Fleet could not launch some of its overrides", "fleet", fleetID, "group", group.Name, "failures", len(failures))
+	}
+	if createOpts.CapacityRebalance != "" {
+		launchedInstances, err := v.maintainFleetInstances(ctx, fleetID)
+		return launchedInstances, failures, err
+	}
+
+	fleetList, err := v.fleetWatcher.Resolve(ctx, []fleets.Selector{{ID: fleetID}})
+	if err != nil {
+		return nil, failures, err
+	}
+	if len(fleetList) == 0 {
+		return nil, failures, fmt.Errorf("could not find fleet for %s", fleetID)
+	}
+
+	instanceIDSelectors := lo.FlatMap(fleetList[0].Instances, func(fleet ec2types.DescribeFleetsInstances, _ int) []instances.Selector {
 		selectors := make([]instances.Selector, 0, len(fleet.InstanceIds))
 		for _, instanceID := range fleet.InstanceIds {
 			selectors = append(selectors, instances.Selector{ID: instanceID})
@@ -260,17 +1115,106 @@ func (v AWSVM) Launch(ctx context.Context, dryRun bool, launchPlan plans.LaunchP
 
 	logging.FromContext(ctx).Debug("Resolving EC2 Instance")
 	launchedInstances, err := v.instanceWatcher.Resolve(ctx, instanceIDSelectors)
+	return launchedInstances, failures, err
+}
+
+// maintainFleetInstances waits for a maintain fleet to launch its instances and resolves them.
+// A fleet that launched no instances is deleted so that it does not launch them after the launch failed.
+func (v AWSVM) maintainFleetInstances(ctx context.Context, fleetID string) ([]instances.Instance, error) {
+	logging.FromContext(ctx).Debug("Waiting for maintain fleet to fulfill its target capacity", "fleet", fleetID)
+	instanceIDs, err := v.fleetWatcher.WaitForInstances(ctx, fleetID, fleets.DefaultFulfillmentTimeout)
+	if fleets.IsNoInstancesLaunched(err) {
+		if deleteErr := v.fleetWatcher.DeleteFleet(ctx, fleetID); deleteErr != nil {
+			return nil, fmt.Errorf("%w, and failed to delete the fleet: %w", err, deleteErr)
+		}
+	}
 	if err != nil {
-		return launchPlan, nil
+		return nil, err
 	}
-	launchPlan.Status.Instances = launchedInstances
-	logging.FromContext(ctx).Debug("Completed Launch Plan Execution Successfully")
-	return launchPlan, nil
+	logging.FromContext(ctx).Debug("Resolving EC2 Instance")
+	return v.instanceWatcher.Resolve(ctx, lo.Map(instanceIDs, func(instanceID string, _ int) instances.Selector { return instances.Selector{ID: instanceID} }))
+}
+
+// warnDroppedInstanceTypes warns about the instance types that the fleet does not launch to stay under EC2 Fleet's override limit
+func warnDroppedInstanceTypes(ctx context.Context, group plans.NodeGroup, createOpts fleets.CreateFleetOptions) {
+	// an error is returned by CreateFleet, which builds the same overrides
+	if _, dropped, err := fleets.Overrides(createOpts); err == nil && len(dropped) != 0 {
+		logging.FromContext(ctx).Warn("Too many instance type and subnet combinations for EC2 Fleet, the least preferred instance types are not launched",
+			"group", group.Name, "max-overrides", fleets.MaxOverrides, "dropped", len(dropped), "instance-types", strings.Join(dropped, ","))
+	}
+}
+
+// fleetOptions returns the options of a fleet that launches the node group's instances into the subnets
+func fleetOptions(launchPlan plans.LaunchPlan, group plans.NodeGroup, groupStatus plans.NodeGroupStatus, subnetList []subnets.Subnet, tags map[string]string) fleets.CreateFleetOptions {
+	return fleets.CreateFleetOptions{
+		Name:           launchPlan.Metadata.Name,
+		Namespace:      launchPlan.Metadata.Namespace,
+		LaunchTemplate: groupStatus.LaunchTemplate,
+		// pinning the version launches the data that the group resolved even if another launch adds a version
+		LaunchTemplateVersion:       groupStatus.LaunchTemplateVersion,
+		InstanceTypes:               groupStatus.InstanceTypes,
+		Subnets:                     subnetList,
+		AMIs:                        groupStatus.AMIs,
+		IAMRole:                     group.IAMRole,
+		CapacityType:                group.CapacityType,
+		TargetCapacity:              lo.Ternary(group.Capacity.Value != 0, group.Capacity.Value, group.Count),
+		CapacityUnit:                lo.Ternary(group.Capacity.Value != 0, group.Capacity.Unit, fleets.CapacityUnitInstances),
+		Tags:                        lo.Assign(launchPlan.Spec.Tags, tags, generationTags(launchPlan), expiryTags(launchPlan), logTags(launchPlan)),
+		Reservations:                launchPlan.Status.Reservations,
+		SpotAllocationStrategy:      group.Allocation.SpotAllocationStrategy,
+		SpotMaxPrice:                group.Allocation.SpotMaxPrice,
+		OnDemandBaseCapacity:        group.Allocation.OnDemandBaseCapacity,
+		OnDemandPercentageAboveBase: group.Allocation.OnDemandPercentageAboveBase,
+		CapacityRebalance:           group.Allocation.CapacityRebalance,
+		TerminationDelay:            group.Allocation.EffectiveTerminationDelay(),
+	}
+}
+
+// expiryTags returns the tag of when instances launched now with the spec's TTL expire, nil if the spec has no TTL
+func expiryTags(launchPlan plans.LaunchPlan) map[string]string {
+	if launchPlan.Spec.TTL <= 0 {
+		return nil
+	}
+	return map[string]string{tagutils.ExpiresAtTagKey: time.Now().Add(launchPlan.Spec.TTL).UTC().Format(time.RFC3339)}
+}
+
+// resolveDefaultNetwork returns the account's default VPC in the region and its default subnets
+func (v AWSVM) resolveDefaultNetwork(ctx context.Context) (*vpcs.VPC, []subnets.Subnet, error) {
+	defaultVPCs, err := v.vpcWatcher.Resolve(ctx, []vpcs.Selector{{Default: true}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(defaultVPCs) == 0 {
+		return nil, nil, fmt.Errorf("no default VPC found in region %s", v.awsCfg.Region)
+	}
+	defaultSubnets, err := v.subnetWatcher.Resolve(ctx, []subnets.Selector{{
+		VPCID:        *defaultVPCs[0].VpcId,
+		DefaultForAZ: true,
+	}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(defaultSubnets) == 0 {
+		return nil, nil, fmt.Errorf("no default subnets found in default VPC %s", *defaultVPCs[0].VpcId)
+	}
+	return &defaultVPCs[0], defaultSubnets, nil
+}
+
+// ResolveSubnets returns the subnets matching the selectors
+func (v AWSVM) ResolveSubnets(ctx context.Context, selectors []subnets.Selector) ([]subnets.Subnet, error) {
+	return v.subnetWatcher.Resolve(ctx, selectors)
+}
+
+// ResolveSecurityGroups returns the security groups matching the selectors
+func (v AWSVM) ResolveSecurityGroups(ctx context.Context, selectors []securitygroups.Selector) ([]securitygroups.SecurityGroup, error) {
+	return v.securityGroupWatcher.Resolve(ctx, selectors)
 }
 
-func (v AWSVM) List(ctx context.Context, namespace string, name string) ([]instances.Instance, error) {
+// List returns the instances of namespace/name. Optional raw EC2 filters further narrow down the instances.
+func (v AWSVM) List(ctx context.Context, namespace string, name string, filters ...ec2types.Filter) ([]instances.Instance, error) {
 	return v.instanceWatcher.Resolve(ctx, []instances.Selector{{
-		Tags: tagutils.NamespacedTags(namespace, name),
+		Tags:    tagutils.NamespacedTags(namespace, name),
+		Filters: filters,
 	}})
 }
 
@@ -286,16 +1230,43 @@ func (v AWSVM) DeletionPlan(ctx context.Context, namespace, name string) (plans.
 		Spec:   plans.DeletionSpec{},
 		Status: plans.DeletionStatus{},
 	}
+	logging.FromContext(ctx).Debug("Resolving Fleets")
+	fleetList, err := v.fleetWatcher.Resolve(ctx, []fleets.Selector{{
+		Tags: tagutils.NamespacedTags(namespace, name),
+	}})
+	if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.Fleets = lo.Filter(fleetList, func(fleet fleets.Fleet, _ int) bool { return fleet.IsActive() })
+
 	logging.FromContext(ctx).Debug("Resolving EC2 Instances")
+	// stopped instances still hold on to their volumes, security groups, and subnets, so they are deleted along with running ones
 	instances, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{
-		Tags:  tagutils.NamespacedTags(namespace, name),
-		State: "running",
+		Tags: tagutils.NamespacedTags(namespace, name),
+		Filters: []ec2types.Filter{{
+			Name: aws.String("instance-state-name"),
+			Values: []string{
+				string(ec2types.InstanceStateNamePending),
+				string(ec2types.InstanceStateNameRunning),
+				string(ec2types.InstanceStateNameStopping),
+				string(ec2types.InstanceStateNameStopped),
+			},
+		}},
 	}})
 	if err != nil {
 		return deletionPlan, err
 	}
 	deletionPlan.Spec.Instances = instances
 
+	logging.FromContext(ctx).Debug("Resolving Volumes")
+	volumeList, err := v.volumeWatcher.Resolve(ctx, []volumes.Selector{{
+		Tags: tagutils.NamespacedTags(namespace, name),
+	}})
+	if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.Volumes = volumeList
+
 	logging.FromContext(ctx).Debug("Resolving Launch Templates")
 	launchTemplates, err := v.launchTemplateWatcher.Resolve(ctx, []launchtemplates.Selector{{
 		Tags: tagutils.NamespacedTags(namespace, name),
@@ -305,6 +1276,35 @@ func (v AWSVM) DeletionPlan(ctx context.Context, namespace, name string) (plans.
 	}
 	deletionPlan.Spec.LaunchTemplates = launchTemplates
 
+	logging.FromContext(ctx).Debug("Resolving Instance Profiles")
+	instanceProfiles, err := v.instanceProfileWatcher.Resolve(ctx, namespace, name)
+	if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.InstanceProfiles = instanceProfiles
+
+	logging.FromContext(ctx).Debug("Resolving FIS Experiment Templates")
+	experimentTemplates, err := v.fisWatcher.ResolveExperimentTemplates(ctx, tagutils.NamespacedTags(namespace, name))
+	// experiment templates only exist if the chaos commands were used, so a role without FIS permissions can still delete everything else
+	if fis.IsAccessDenied(err) {
+		logging.FromContext(ctx).Warn("Not allowed to list FIS experiment templates, they are not deleted", "error", err)
+	} else if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.ExperimentTemplates = experimentTemplates
+
+	logging.FromContext(ctx).Debug("Checking for active fleets referencing Launch Templates")
+	fleetReferences, err := v.fleetWatcher.ResolveLaunchTemplateReferences(ctx, launchTemplateIDs(launchTemplates))
+	if err != nil {
+		return deletionPlan, err
+	}
+	for _, launchTemplate := range launchTemplates {
+		if referencingFleets := fleetReferences[*launchTemplate.LaunchTemplateId]; len(referencingFleets) > 0 {
+			logging.FromContext(ctx).Warn("Launch Template is referenced by active fleets and will be deleted last if the fleets are gone, otherwise skipped",
+				"launch-template-id", *launchTemplate.LaunchTemplateId, "fleet-ids", fleetIDs(referencingFleets))
+		}
+	}
+
 	logging.FromContext(ctx).Debug("Resolving Security Groups")
 	securityGroups, err := v.securityGroupWatcher.Resolve(ctx, []securitygroups.Selector{{
 		Tags: tagutils.NamespacedTags(namespace, name),
@@ -314,6 +1314,24 @@ func (v AWSVM) DeletionPlan(ctx context.Context, namespace, name string) (plans.
 	}
 	deletionPlan.Spec.SecurityGroups = securityGroups
 
+	logging.FromContext(ctx).Debug("Resolving NAT Gateways")
+	natGateways, err := v.natGatewayWatcher.Resolve(ctx, []natgws.Selector{{
+		Tags: tagutils.NamespacedTags(namespace, name),
+	}})
+	if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.NATGateways = lo.Reject(natGateways, func(natGateway natgws.NATGateway, _ int) bool { return natGateway.IsDeleted() })
+
+	logging.FromContext(ctx).Debug("Resolving Elastic IPs")
+	elasticIPs, err := v.eipWatcher.Resolve(ctx, []eips.Selector{{
+		Tags: tagutils.NamespacedTags(namespace, name),
+	}})
+	if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.ElasticIPs = elasticIPs
+
 	logging.FromContext(ctx).Debug("Resolving Internet Gateways")
 	internetGateways, err := v.igwWatcher.Resolve(ctx, []igws.Selector{{
 		Tags: tagutils.NamespacedTags(namespace, name),
@@ -323,6 +1341,13 @@ func (v AWSVM) DeletionPlan(ctx context.Context, namespace, name string) (plans.
 	}
 	deletionPlan.Spec.InternetGateways = internetGateways
 
+	logging.FromContext(ctx).Debug("Resolving Egress-Only Internet Gateways")
+	egressOnlyIGWs, err := v.igwWatcher.ResolveEgressOnly(ctx, tagutils.NamespacedTags(namespace, name), "")
+	if err != nil {
+		return deletionPlan, err
+	}
+	deletionPlan.Spec.EgressOnlyInternetGateways = egressOnlyIGWs
+
 	logging.FromContext(ctx).Debug("Resolving Route Tables")
 	routeTables, err := v.routeTableWatcher.Resolve(ctx, []routetables.Selector{{
 		Tags: tagutils.NamespacedTags(namespace, name),
@@ -350,124 +1375,345 @@ func (v AWSVM) DeletionPlan(ctx context.Context, namespace, name string) (plans.
 	}
 	deletionPlan.Spec.VPCs = vpcs
 
+	logging.FromContext(ctx).Debug("Resolving the namespace's shared network")
+	if err := v.planSharedNetworkDeletion(ctx, &deletionPlan); err != nil {
+		return deletionPlan, err
+	}
+
+	logging.FromContext(ctx).Debug("Checking for resources still in use outside of the plan")
+	if err := v.excludeInUseResources(ctx, &deletionPlan); err != nil {
+		return deletionPlan, err
+	}
+
+	// the estimate is informational, e.g. the caller may not be allowed to use the Pricing API
+	if deletionPlan.Status.EstimatedCost, err = v.instancesCost(ctx, deletionPlan.Spec.Instances); err != nil {
+		logging.FromContext(ctx).Warn("Unable to estimate the cost of the instances", "error", err)
+		deletionPlan.Status.EstimatedCost.Incomplete = true
+	}
+
 	logging.FromContext(ctx).Debug("Deletion Plan construction completed")
 	return deletionPlan, nil
 }
 
 // Delete executes a DeletionPlan. It is idempotent by keeping track of deletions in the DeletionPlan.Status
-func (v AWSVM) Delete(ctx context.Context, deletionPlan plans.DeletionPlan) (plans.DeletionPlan, error) {
+// Resources are deleted in tiers of their dependency graph, the kinds of a tier and the resources of a kind are deleted concurrently.
+// A tier is only started once the tiers it depends on are complete.
+func (v AWSVM) Delete(ctx context.Context, deletionPlan plans.DeletionPlan) (result plans.DeletionPlan, err error) {
+	deletionPlan.Metadata.PlanID = plans.NewPlanID()
+	ctx = logging.ToContext(ctx, logging.FromContext(ctx).With("plan-id", deletionPlan.Metadata.PlanID))
 	logging.FromContext(ctx).Debug("Executing Deletion Plan")
-	logging.FromContext(ctx).Debug("Terminating EC2 instances...")
-	for _, instance := range deletionPlan.Spec.Instances {
-		if deletionPlan.Status.Instances[*instance.InstanceId] {
-			logging.FromContext(ctx).Debug("Already terminated EC2 instance, skipping", "instance-id", *instance.InstanceId)
-			continue
-		}
-		if err := v.instanceWatcher.TerminateInstance(ctx, *instance.InstanceId); err != nil {
-			return deletionPlan, err
-		}
-		if deletionPlan.Status.Instances == nil {
-			deletionPlan.Status.Instances = map[string]bool{}
+	defer func() {
+		if err != nil {
+			result.Status.Conditions.FailInProgress(err)
 		}
-		logging.FromContext(ctx).Debug("Terminated EC2 instance", "instance-id", *instance.InstanceId)
-		deletionPlan.Status.Instances[*instance.InstanceId] = true
+	}()
+	status := &deletionPlan.Status
+
+	// NAT Gateways take minutes to delete, so their deletion starts with the fleets' and runs while the instances terminate.
+	// They hold public addresses in the VPC, so the internet gateways are only detached once they are deleted.
+	logging.FromContext(ctx).Debug("Deleting Fleets, FIS Experiment Templates, and NAT Gateways...")
+	status.Conditions.Set(plans.ConditionFleetsDeleted, plans.ConditionUnknown, "Deleting fleets")
+	status.Conditions.Set(plans.ConditionNetworkDeleted, plans.ConditionUnknown, "Deleting network")
+	natGatewaysDeleted := make(chan error, 1)
+	go func() {
+		natGatewaysDeleted <- deleteEach(ctx, "NAT Gateway", "nat-gateway-id", deletionPlan.Spec.NATGateways,
+			func(natGateway natgws.NATGateway) string { return *natGateway.NatGatewayId }, &status.NATGateways,
+			func(natGateway natgws.NATGateway) error {
+				return v.natGatewayWatcher.Delete(ctx, *natGateway.NatGatewayId)
+			})
+	}()
+	status.Conditions.Set(plans.ConditionExperimentsDeleted, plans.ConditionUnknown, "Deleting experiment templates")
+	if err := deleteParallel(
+		func() error {
+			return deleteEach(ctx, "fleet", "fleet-id", deletionPlan.Spec.Fleets, func(fleet fleets.Fleet) string { return *fleet.FleetId }, &status.Fleets,
+				func(fleet fleets.Fleet) error { return v.fleetWatcher.DeleteFleet(ctx, *fleet.FleetId) })
+		},
+		func() error {
+			return deleteEach(ctx, "experiment template", "experiment-template-id", deletionPlan.Spec.ExperimentTemplates,
+				func(template fis.ExperimentTemplate) string { return template.ID }, &status.ExperimentTemplates,
+				func(template fis.ExperimentTemplate) error { return v.deleteExperimentTemplate(ctx, template.ID) })
+		},
+	); err != nil {
+		return deletionPlan, errors.Join(err, <-natGatewaysDeleted)
 	}
+	status.Conditions.Set(plans.ConditionFleetsDeleted, plans.ConditionTrue, fmt.Sprintf("Deleted %d fleets", len(deletionPlan.Spec.Fleets)))
+	status.Conditions.Set(plans.ConditionExperimentsDeleted, plans.ConditionTrue, fmt.Sprintf("Deleted %d experiment templates", len(deletionPlan.Spec.ExperimentTemplates)))
 
-	logging.FromContext(ctx).Debug("Deleting Launch Templates...")
-	for _, launchTemplate := range deletionPlan.Spec.LaunchTemplates {
-		if deletionPlan.Status.LaunchTemplates[*launchTemplate.LaunchTemplateId] {
-			logging.FromContext(ctx).Debug("Already deleted launch template, skipping", "launch-template-id", *launchTemplate.LaunchTemplateId)
-			continue
-		}
-		if err := v.launchTemplateWatcher.DeleteLaunchTemplate(ctx, *launchTemplate.LaunchTemplateId); err != nil {
-			return deletionPlan, err
+	// the final snapshots are taken once the fleets are deleted, so that they do not launch instances without one
+	if deletionPlan.Spec.FinalSnapshot {
+		logging.FromContext(ctx).Debug("Creating final snapshots of attached volumes...")
+		status.Conditions.Set(plans.ConditionSnapshotsCreated, plans.ConditionUnknown, "Creating final snapshots")
+		if err := v.createFinalSnapshots(ctx, &deletionPlan); err != nil {
+			return deletionPlan, errors.Join(err, <-natGatewaysDeleted)
 		}
-		if deletionPlan.Status.LaunchTemplates == nil {
-			deletionPlan.Status.LaunchTemplates = map[string]bool{}
-		}
-		logging.FromContext(ctx).Debug("Deleted Launch Template", "launch-template-id", *launchTemplate.LaunchTemplateId)
-		deletionPlan.Status.LaunchTemplates[*launchTemplate.LaunchTemplateId] = true
+		status.Conditions.Set(plans.ConditionSnapshotsCreated, plans.ConditionTrue, fmt.Sprintf("Created %d final snapshots", len(status.Snapshots)))
 	}
 
-	logging.FromContext(ctx).Debug("Deleting Security Groups...")
-	for _, securityGroup := range deletionPlan.Spec.SecurityGroups {
-		if deletionPlan.Status.SecurityGroups[*securityGroup.GroupId] {
-			logging.FromContext(ctx).Debug("Already deleted security group, skipping", "security-group-id", *securityGroup.GroupId)
-			continue
-		}
-		if err := v.securityGroupWatcher.DeleteSecurityGroup(ctx, *securityGroup.GroupId); err != nil {
-			return deletionPlan, err
-		}
-		if deletionPlan.Status.SecurityGroups == nil {
-			deletionPlan.Status.SecurityGroups = map[string]bool{}
-		}
-		logging.FromContext(ctx).Debug("Deleted security group", "security-group-id", *securityGroup.GroupId)
-		deletionPlan.Status.SecurityGroups[*securityGroup.GroupId] = true
+	logging.FromContext(ctx).Debug("Terminating EC2 instances and deleting Launch Templates...")
+	status.Conditions.Set(plans.ConditionInstancesTerminated, plans.ConditionUnknown, "Terminating instances")
+	status.Conditions.Set(plans.ConditionLaunchTemplatesDeleted, plans.ConditionUnknown, "Deleting launch templates")
+	// Launch Templates still referenced by an active maintain or request fleet cannot be deleted.
+	// They are deferred until the rest of the plan is executed and skipped if the reference still exists.
+	var deferredLaunchTemplates []launchtemplates.LaunchTemplate
+	if err := deleteParallel(
+		func() error { return v.terminateInstances(ctx, &deletionPlan) },
+		func() error {
+			var err error
+			deferredLaunchTemplates, err = v.deleteLaunchTemplates(ctx, &deletionPlan)
+			return err
+		},
+	); err != nil {
+		return deletionPlan, errors.Join(err, <-natGatewaysDeleted)
+	}
+	// the network interfaces and volumes of stragglers are still in use, so the rest of the plan is left for the next deletion
+	if stragglers := lo.CountBy(status.Skipped, func(skipped plans.SkippedResource) bool { return skipped.Type == "Instance" }); stragglers != 0 {
+		status.Conditions.Set(plans.ConditionInstancesTerminated, plans.ConditionFalse,
+			fmt.Sprintf("Skipped %d instances that are stuck terminating, delete again once they are terminated", stragglers))
+		logging.FromContext(ctx).Warn("Stopped the deletion since instances are stuck terminating, delete again once they are terminated", "instances", stragglers)
+		return deletionPlan, <-natGatewaysDeleted
+	}
+	status.Conditions.Set(plans.ConditionInstancesTerminated, plans.ConditionTrue, fmt.Sprintf("Terminated %d instances", len(deletionPlan.Spec.Instances)))
+	if len(deferredLaunchTemplates) == 0 {
+		status.Conditions.Set(plans.ConditionLaunchTemplatesDeleted, plans.ConditionTrue, fmt.Sprintf("Deleted %d launch templates", len(deletionPlan.Spec.LaunchTemplates)))
+	} else {
+		status.Conditions.Set(plans.ConditionLaunchTemplatesDeleted, plans.ConditionUnknown,
+			fmt.Sprintf("%d launch templates are referenced by active fleets and are deleted last", len(deferredLaunchTemplates)))
+	}
+
+	logging.FromContext(ctx).Debug("Deleting Volumes, Security Groups, and Elastic IPs...")
+	status.Conditions.Set(plans.ConditionVolumesDeleted, plans.ConditionUnknown, "Deleting volumes")
+	status.Conditions.Set(plans.ConditionSecurityGroupsDeleted, plans.ConditionUnknown, "Deleting security groups")
+	if err := deleteParallel(
+		func() error { return v.deleteVolumes(ctx, &deletionPlan) },
+		func() error { return v.deleteSecurityGroups(ctx, &deletionPlan) },
+		func() error {
+			// Elastic IPs of the plan's NAT Gateways are only released by EC2 once the NAT Gateways are deleted
+			if err := <-natGatewaysDeleted; err != nil {
+				return err
+			}
+			return deleteEach(ctx, "Elastic IP", "allocation-id", deletionPlan.Spec.ElasticIPs, func(eip eips.ElasticIP) string { return *eip.AllocationId }, &status.ElasticIPs,
+				func(eip eips.ElasticIP) error { return v.eipWatcher.Release(ctx, *eip.AllocationId) })
+		},
+	); err != nil {
+		return deletionPlan, err
+	}
+	status.Conditions.Set(plans.ConditionVolumesDeleted, plans.ConditionTrue, fmt.Sprintf("Deleted %d volumes", len(deletionPlan.Spec.Volumes)))
+	status.Conditions.Set(plans.ConditionSecurityGroupsDeleted, plans.ConditionTrue, fmt.Sprintf("Deleted %d security groups", len(deletionPlan.Spec.SecurityGroups)))
+
+	logging.FromContext(ctx).Debug("Deleting Internet Gateways, Egress-Only Internet Gateways, and Route Tables...")
+	if err := deleteParallel(
+		func() error {
+			return deleteEach(ctx, "Internet Gateway", "internet-gateway-id", deletionPlan.Spec.InternetGateways,
+				func(igw igws.InternetGateway) string { return *igw.InternetGatewayId }, &status.InternetGateways,
+				func(igw igws.InternetGateway) error { return v.igwWatcher.Delete(ctx, igw) })
+		},
+		func() error {
+			return deleteEach(ctx, "Egress-Only Internet Gateway", "egress-only-internet-gateway-id", deletionPlan.Spec.EgressOnlyInternetGateways,
+				func(eigw igws.EgressOnlyInternetGateway) string { return *eigw.EgressOnlyInternetGatewayId }, &status.EgressOnlyInternetGateways,
+				func(eigw igws.EgressOnlyInternetGateway) error { return v.igwWatcher.DeleteEgressOnly(ctx, eigw) })
+		},
+		func() error {
+			return deleteEach(ctx, "Route Table", "route-table-id", deletionPlan.Spec.RouteTables,
+				func(routeTable routetables.RouteTable) string { return *routeTable.RouteTableId }, &status.RouteTables,
+				func(routeTable routetables.RouteTable) error { return v.routeTableWatcher.Delete(ctx, routeTable) })
+		},
+	); err != nil {
+		return deletionPlan, err
+	}
+
+	logging.FromContext(ctx).Debug("Deleting Subnets...")
+	if err := deleteEach(ctx, "subnet", "subnet-id", deletionPlan.Spec.Subnets, func(subnet subnets.Subnet) string { return *subnet.SubnetId }, &status.Subnets,
+		func(subnet subnets.Subnet) error { return v.subnetWatcher.Delete(ctx, *subnet.SubnetId) }); err != nil {
+		return deletionPlan, err
 	}
 
-	logging.FromContext(ctx).Debug("Deleting Internet Gateways...")
-	for _, igw := range deletionPlan.Spec.InternetGateways {
-		if deletionPlan.Status.InternetGateways[*igw.InternetGatewayId] {
-			logging.FromContext(ctx).Debug("Already deleted Internet Gateway, skipping", "internet-gateway-id", *igw.InternetGatewayId)
+	logging.FromContext(ctx).Debug("Deleting VPCs...")
+	if err := deleteEach(ctx, "VPC", "vpc-id", deletionPlan.Spec.VPCs, func(vpc vpcs.VPC) string { return *vpc.VpcId }, &status.VPCs,
+		func(vpc vpcs.VPC) error { return v.vpcWatcher.Delete(ctx, *vpc.VpcId) }); err != nil {
+		return deletionPlan, err
+	}
+	status.Conditions.Set(plans.ConditionNetworkDeleted, plans.ConditionTrue, fmt.Sprintf("Deleted %d VPCs", len(deletionPlan.Spec.VPCs)))
+
+	if len(deferredLaunchTemplates) > 0 {
+		logging.FromContext(ctx).Debug("Deleting deferred Launch Templates...")
+	}
+	deferredReferences, err := v.fleetWatcher.ResolveLaunchTemplateReferences(ctx, launchTemplateIDs(deferredLaunchTemplates))
+	if err != nil {
+		return deletionPlan, err
+	}
+	for _, launchTemplate := range deferredLaunchTemplates {
+		if referencingFleets := deferredReferences[*launchTemplate.LaunchTemplateId]; len(referencingFleets) > 0 {
+			logging.FromContext(ctx).Warn("Skipping Launch Template deletion since it is still referenced by active fleets", "launch-template-id", *launchTemplate.LaunchTemplateId, "fleet-ids", fleetIDs(referencingFleets))
+			status.Skipped = append(status.Skipped, plans.SkippedResource{
+				ID:     *launchTemplate.LaunchTemplateId,
+				Type:   "LaunchTemplate",
+				Reason: fmt.Sprintf("referenced by active fleets: %s", strings.Join(fleetIDs(referencingFleets), ", ")),
+			})
 			continue
 		}
-		if err := v.igwWatcher.Delete(ctx, igw); err != nil {
+		if err := v.deleteLaunchTemplate(ctx, &deletionPlan, launchTemplate); err != nil {
 			return deletionPlan, err
 		}
-		if deletionPlan.Status.InternetGateways == nil {
-			deletionPlan.Status.InternetGateways = map[string]bool{}
-		}
-		logging.FromContext(ctx).Debug("Deleted Internet Gateway", "internet-gateway-id", *igw.InternetGatewayId)
-		deletionPlan.Status.InternetGateways[*igw.InternetGatewayId] = true
+	}
+	if len(deferredLaunchTemplates) > 0 {
+		status.Conditions.Set(plans.ConditionLaunchTemplatesDeleted, plans.ConditionTrue,
+			fmt.Sprintf("Deleted %d launch templates, %d skipped", len(status.LaunchTemplates),
+				lo.CountBy(status.Skipped, func(skipped plans.SkippedResource) bool { return skipped.Type == "LaunchTemplate" })))
 	}
 
-	logging.FromContext(ctx).Debug("Deleting Route Tables...")
-	for _, routeTable := range deletionPlan.Spec.RouteTables {
-		if deletionPlan.Status.RouteTables[*routeTable.RouteTableId] {
-			logging.FromContext(ctx).Debug("Already deleted Route Table, skipping", "route-table-id", *routeTable.RouteTableId)
-			continue
+	// active fleets relaunch instances from the skipped launch templates, which still need their instance profiles
+	if lo.SomeBy(status.Skipped, func(skipped plans.SkippedResource) bool { return skipped.Type == "LaunchTemplate" }) {
+		for _, profile := range deletionPlan.Spec.InstanceProfiles {
+			status.Skipped = append(status.Skipped, plans.SkippedResource{
+				ID:     profile.InstanceProfileName,
+				Type:   "InstanceProfile",
+				Reason: "launch templates referenced by active fleets may use it",
+			})
 		}
-		if err := v.routeTableWatcher.Delete(ctx, routeTable); err != nil {
+		status.Conditions.Set(plans.ConditionInstanceProfilesDeleted, plans.ConditionFalse,
+			fmt.Sprintf("Skipped %d instance profiles of launch templates referenced by active fleets", len(deletionPlan.Spec.InstanceProfiles)))
+	} else {
+		logging.FromContext(ctx).Debug("Deleting Instance Profiles...")
+		status.Conditions.Set(plans.ConditionInstanceProfilesDeleted, plans.ConditionUnknown, "Deleting instance profiles")
+		if err := v.deleteInstanceProfiles(ctx, &deletionPlan); err != nil {
 			return deletionPlan, err
 		}
-		if deletionPlan.Status.RouteTables == nil {
-			deletionPlan.Status.RouteTables = map[string]bool{}
-		}
-		logging.FromContext(ctx).Debug("Deleted Route Table", "route-table-id", *routeTable.RouteTableId)
-		deletionPlan.Status.RouteTables[*routeTable.RouteTableId] = true
+		status.Conditions.Set(plans.ConditionInstanceProfilesDeleted, plans.ConditionTrue, fmt.Sprintf("Deleted %d instance profiles", len(deletionPlan.Spec.InstanceProfiles)))
 	}
+	logging.FromContext(ctx).Debug("Deletion Plan Completed Successfully")
+	return deletionPlan, nil
+}
 
-	logging.FromContext(ctx).Debug("Deleting Subnets...")
-	for _, subnet := range deletionPlan.Spec.Subnets {
-		if deletionPlan.Status.Subnets[*subnet.SubnetId] {
-			logging.FromContext(ctx).Debug("Already deleted Subnet", "subnet-id", *subnet.SubnetId)
+// terminateInstances terminates the plan's instances that are not terminated yet in a single call and waits for all of them to be terminated
+func (v AWSVM) terminateInstances(ctx context.Context, deletionPlan *plans.DeletionPlan) error {
+	instanceIDs := pendingIDs(idsOf(deletionPlan.Spec.Instances), deletionPlan.Status.Instances)
+	if len(instanceIDs) == 0 {
+		return nil
+	}
+	if err := v.instanceWatcher.TerminateInstances(ctx, instanceIDs); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Debug("Waiting for EC2 instances to terminate...", "instance-ids", instanceIDs)
+	done := progress.FromContext(ctx).Track(fmt.Sprintf("Terminating %d instances", len(instanceIDs)), expectedTerminationDuration)
+	stragglers, err := v.waitForTerminated(ctx, instanceIDs, deletionPlan.Spec.SkipStragglers)
+	done()
+	if errors.Is(err, errStragglers) {
+		return fmt.Errorf("%w, delete with --skip-stragglers to delete everything that does not depend on them", err)
+	}
+	if err != nil {
+		return err
+	}
+	if deletionPlan.Status.Instances == nil {
+		deletionPlan.Status.Instances = map[string]bool{}
+	}
+	stragglerIDs := idsOf(stragglers)
+	for _, instanceID := range instanceIDs {
+		if lo.Contains(stragglerIDs, instanceID) {
+			deletionPlan.Status.Skipped = append(deletionPlan.Status.Skipped, plans.SkippedResource{
+				ID:     instanceID,
+				Type:   "Instance",
+				Reason: "stuck terminating, delete again once it is terminated to delete the resources that it uses",
+			})
 			continue
 		}
-		if err := v.subnetWatcher.Delete(ctx, *subnet.SubnetId); err != nil {
-			return deletionPlan, err
+		logging.FromContext(ctx).Debug("Terminated EC2 instance", "instance-id", instanceID)
+		deletionPlan.Status.Instances[instanceID] = true
+	}
+	return nil
+}
+
+// deleteLaunchTemplates deletes the plan's launch templates that are not referenced by active fleets and returns the referenced ones
+func (v AWSVM) deleteLaunchTemplates(ctx context.Context, deletionPlan *plans.DeletionPlan) ([]launchtemplates.LaunchTemplate, error) {
+	var deferredLaunchTemplates []launchtemplates.LaunchTemplate
+	var unreferenced []launchtemplates.LaunchTemplate
+	pending := lo.Filter(deletionPlan.Spec.LaunchTemplates, func(launchTemplate launchtemplates.LaunchTemplate, _ int) bool {
+		if deletionPlan.Status.LaunchTemplates[*launchTemplate.LaunchTemplateId] {
+			logging.FromContext(ctx).Debug("Already deleted launch template, skipping", "launch-template-id", *launchTemplate.LaunchTemplateId)
+			return false
 		}
-		if deletionPlan.Status.Subnets == nil {
-			deletionPlan.Status.Subnets = map[string]bool{}
+		return true
+	})
+	fleetReferences, err := v.fleetWatcher.ResolveLaunchTemplateReferences(ctx, launchTemplateIDs(pending))
+	if err != nil {
+		return nil, err
+	}
+	for _, launchTemplate := range pending {
+		if referencingFleets := fleetReferences[*launchTemplate.LaunchTemplateId]; len(referencingFleets) > 0 {
+			logging.FromContext(ctx).Debug("Launch Template is referenced by active fleets, deferring deletion", "launch-template-id", *launchTemplate.LaunchTemplateId, "fleet-ids", fleetIDs(referencingFleets))
+			deferredLaunchTemplates = append(deferredLaunchTemplates, launchTemplate)
+			continue
 		}
-		logging.FromContext(ctx).Debug("Deleted subnet", "subnet-id", *subnet.SubnetId)
-		deletionPlan.Status.Subnets[*subnet.SubnetId] = true
+		unreferenced = append(unreferenced, launchTemplate)
 	}
+	return deferredLaunchTemplates, deleteEach(ctx, "Launch Template", "launch-template-id", unreferenced,
+		func(launchTemplate launchtemplates.LaunchTemplate) string { return *launchTemplate.LaunchTemplateId }, &deletionPlan.Status.LaunchTemplates,
+		func(launchTemplate launchtemplates.LaunchTemplate) error {
+			return v.launchTemplateWatcher.DeleteLaunchTemplate(ctx, *launchTemplate.LaunchTemplateId)
+		})
+}
 
-	logging.FromContext(ctx).Debug("Deleting VPCs...")
-	for _, vpc := range deletionPlan.Spec.VPCs {
-		if deletionPlan.Status.VPCs[*vpc.VpcId] {
-			logging.FromContext(ctx).Debug("Already deleted VPC, skipping", "vpc-id", *vpc.VpcId)
+// deleteSecurityGroups waits for the network interfaces of the plan's terminated instances to be released and deletes the plan's security groups
+func (v AWSVM) deleteSecurityGroups(ctx context.Context, deletionPlan *plans.DeletionPlan) error {
+	if err := v.waitForENIRelease(ctx, *deletionPlan); err != nil {
+		return err
+	}
+
+	// Rules between node group security groups must be removed before either group can be deleted
+	for _, securityGroup := range deletionPlan.Spec.SecurityGroups {
+		if deletionPlan.Status.SecurityGroups[*securityGroup.GroupId] {
 			continue
 		}
-		if err := v.vpcWatcher.Delete(ctx, *vpc.VpcId); err != nil {
-			return deletionPlan, err
+		if err := v.securityGroupWatcher.RevokeSecurityGroupReferences(ctx, *securityGroup.GroupId); err != nil {
+			return err
 		}
-		if deletionPlan.Status.VPCs == nil {
-			deletionPlan.Status.VPCs = map[string]bool{}
-		}
-		logging.FromContext(ctx).Debug("Deleted VPC", "vpc-id", *vpc.VpcId)
-		deletionPlan.Status.VPCs[*vpc.VpcId] = true
 	}
-	logging.FromContext(ctx).Debug("Deletion Plan Completed Successfully")
-	return deletionPlan, nil
+	return deleteEach(ctx, "security group", "security-group-id", deletionPlan.Spec.SecurityGroups,
+		func(securityGroup securitygroups.SecurityGroup) string { return *securityGroup.GroupId }, &deletionPlan.Status.SecurityGroups,
+		func(securityGroup securitygroups.SecurityGroup) error {
+			return v.securityGroupWatcher.DeleteSecurityGroup(ctx, *securityGroup.GroupId)
+		})
+}
+
+func (v AWSVM) deleteLaunchTemplate(ctx context.Context, deletionPlan *plans.DeletionPlan, launchTemplate launchtemplates.LaunchTemplate) error {
+	if err := v.launchTemplateWatcher.DeleteLaunchTemplate(ctx, *launchTemplate.LaunchTemplateId); err != nil {
+		return err
+	}
+	if deletionPlan.Status.LaunchTemplates == nil {
+		deletionPlan.Status.LaunchTemplates = map[string]bool{}
+	}
+	logging.FromContext(ctx).Debug("Deleted Launch Template", "launch-template-id", *launchTemplate.LaunchTemplateId)
+	deletionPlan.Status.LaunchTemplates[*launchTemplate.LaunchTemplateId] = true
+	return nil
+}
+
+// waitForENIRelease waits for network interfaces of the plan's terminated instances to be released from the plan's subnets and security groups.
+// If the network interfaces are not released within the timeout, deletion continues and EC2 will report any dependency violations.
+func (v AWSVM) waitForENIRelease(ctx context.Context, deletionPlan plans.DeletionPlan) error {
+	instanceIDs := lo.Map(deletionPlan.Spec.Instances, func(instance instances.Instance, _ int) string { return *instance.InstanceId })
+	eniSelectors := lo.Map(deletionPlan.Spec.Subnets, func(subnet subnets.Subnet, _ int) enis.Selector {
+		return enis.Selector{SubnetID: *subnet.SubnetId}
+	})
+	eniSelectors = append(eniSelectors, lo.Map(deletionPlan.Spec.SecurityGroups, func(securityGroup securitygroups.SecurityGroup, _ int) enis.Selector {
+		return enis.Selector{SecurityGroupID: *securityGroup.GroupId}
+	})...)
+	logging.FromContext(ctx).Debug("Waiting for network interfaces of terminated instances to be released...")
+	pending, err := v.eniWatcher.WaitForRelease(ctx, eniSelectors, instanceIDs, eniReleaseTimeout)
+	if err != nil {
+		return err
+	}
+	if len(pending) > 0 {
+		logging.FromContext(ctx).Warn("Network interfaces were not released in time, continuing with deletion", "timeout", eniReleaseTimeout,
+			"network-interface-ids", lo.Map(pending, func(eni enis.NetworkInterface, _ int) string { return *eni.NetworkInterfaceId }))
+	}
+	return nil
+}
+
+func fleetIDs(fleetList []fleets.Fleet) []string {
+	return lo.Map(fleetList, func(fleet fleets.Fleet, _ int) string { return lo.FromPtr(fleet.FleetId) })
+}
+
+func launchTemplateIDs(launchTemplateList []launchtemplates.LaunchTemplate) []string {
+	return lo.Map(launchTemplateList, func(launchTemplate launchtemplates.LaunchTemplate, _ int) string {
+		return lo.FromPtr(launchTemplate.LaunchTemplateId)
+	})
 }
@@ -0,0 +1,53 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/subnets"
+	"github.com/bwagner5/nimbus/pkg/providers/vpcs"
+)
+
+func TestLaunchVPCID(t *testing.T) {
+	type testCases struct {
+		name        string
+		status      plans.LaunchStatus
+		expected    string
+		expectedErr bool
+	}
+
+	for _, tc := range []testCases{
+		{
+			name: "resolved VPC",
+			status: plans.LaunchStatus{
+				VPC:     vpcs.VPC{Vpc: ec2types.Vpc{VpcId: aws.String("vpc-1")}},
+				Subnets: []subnets.Subnet{{Subnet: ec2types.Subnet{SubnetId: aws.String("subnet-1"), VpcId: aws.String("vpc-2")}}},
+			},
+			expected: "vpc-1",
+		},
+		{
+			name: "resolved subnets without a VPC",
+			status: plans.LaunchStatus{
+				Subnets: []subnets.Subnet{{Subnet: ec2types.Subnet{SubnetId: aws.String("subnet-1"), VpcId: aws.String("vpc-2")}}},
+			},
+			expected: "vpc-2",
+		},
+		{
+			name:        "nothing resolved",
+			expectedErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			launchPlan := plans.LaunchPlan{Metadata: plans.LaunchMetadata{Namespace: "dev", Name: "web"}, Status: tc.status}
+			vpcID, err := launchVPCID(launchPlan)
+			if tc.expectedErr != (err != nil) {
+				t.Fatalf("launchVPCID() error = %v, expectedErr %v", err, tc.expectedErr)
+			}
+			if vpcID != tc.expected {
+				t.Errorf("launchVPCID() = %q, want %q", vpcID, tc.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/providers/launchtemplates"
+	"github.com/bwagner5/nimbus/pkg/providers/volumes"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+	"github.com/samber/lo"
+)
+
+// volumeReleaseTimeout is how long to wait for the volumes of terminated instances to be detached before deleting them
+const volumeReleaseTimeout = 5 * time.Minute
+
+// validateVolumes checks that the standalone volumes are valid block devices that do not replace the root volume or a block device mapping
+func validateVolumes(spec plans.LaunchSpec) error {
+	if err := launchtemplates.ValidateBlockDevices(spec.Volumes); err != nil {
+		return err
+	}
+	for _, volume := range spec.Volumes {
+		if volume.DeviceName == launchtemplates.RootDevice {
+			return fmt.Errorf("the root volume cannot be a standalone volume, configure it with a %s block device mapping", launchtemplates.RootDevice)
+		}
+		if lo.ContainsBy(spec.BlockDeviceMappings, func(mapping launchtemplates.BlockDevice) bool { return mapping.DeviceName == volume.DeviceName }) {
+			return fmt.Errorf("device %s is both a block device mapping and a standalone volume", volume.DeviceName)
+		}
+	}
+	return nil
+}
+
+// volumeOptions returns the options of a standalone volume in the availability zone.
+// Volumes are encrypted unless they opt out, with their own KMS key or the plan's EBS KMS key.
+func volumeOptions(launchPlan plans.LaunchPlan, volume launchtemplates.BlockDevice, zone string, tags map[string]string) volumes.CreateVolumeOptions {
+	encrypted := volume.IsEncrypted()
+	return volumes.CreateVolumeOptions{
+		AvailabilityZone: zone,
+		Size:             volume.VolumeSize,
+		VolumeType:       volume.VolumeType,
+		IOPS:             volume.IOPS,
+		Throughput:       volume.Throughput,
+		Encrypted:        encrypted,
+		KMSKeyID:         lo.Ternary(encrypted, lo.CoalesceOrEmpty(volume.KMSKeyID, lo.FromPtr(launchPlan.Status.EBSKMSKey.Arn)), ""),
+		Tags:             lo.Assign(launchPlan.Spec.Tags, tags),
+	}
+}
+
+// createVolumes creates the spec's standalone volumes for every launched instance and attaches them.
+// Instances must be running or stopped to attach volumes, so this waits for them to be running first.
+func (v AWSVM) createVolumes(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	if len(launchPlan.Spec.Volumes) == 0 {
+		return nil
+	}
+	// existing instances that the launch kept already have their volumes
+	launched := newInstances(*launchPlan)
+	if err := v.instanceWatcher.WaitForRunning(ctx, idsOf(launched), bootstrapTimeout); err != nil {
+		return err
+	}
+	launchedIDs := lo.SliceToMap(idsOf(launched), func(id string) (string, bool) { return id, true })
+	for _, groupStatus := range launchPlan.Status.NodeGroups {
+		tags := map[string]string{}
+		if groupStatus.Name != "" {
+			tags[tagutils.GroupTagKey] = groupStatus.Name
+		}
+		for _, instance := range groupStatus.Instances {
+			if !launchedIDs[*instance.InstanceId] {
+				continue
+			}
+			zone := lo.FromPtr(lo.FromPtr(instance.Placement).AvailabilityZone)
+			for _, volumeSpec := range launchPlan.Spec.Volumes {
+				logging.FromContext(ctx).Debug("Creating volume", "instance-id", *instance.InstanceId, "device", volumeSpec.DeviceName, "az", zone)
+				volume, err := v.volumeWatcher.Create(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, volumeOptions(*launchPlan, volumeSpec, zone, tags))
+				if volume != nil {
+					launchPlan.Status.Volumes = append(launchPlan.Status.Volumes, *volume)
+				}
+				if err != nil {
+					return err
+				}
+				logging.FromContext(ctx).Debug("Attaching volume", "volume-id", *volume.VolumeId, "instance-id", *instance.InstanceId, "device", volumeSpec.DeviceName)
+				if err := v.volumeWatcher.Attach(ctx, *volume.VolumeId, *instance.InstanceId, volumeSpec.DeviceName); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// planVolumes records the standalone volumes that a launch would create, one of each per instance of every node group
+func (v AWSVM) planVolumes(ctx context.Context, launchPlan *plans.LaunchPlan, nodeGroups []plans.NodeGroup) {
+	if len(launchPlan.Spec.Volumes) == 0 {
+		return
+	}
+	zone := ""
+	if len(launchPlan.Status.Subnets) != 0 {
+		zone = lo.FromPtr(launchPlan.Status.Subnets[0].AvailabilityZone)
+	}
+	for _, group := range nodeGroups {
+		name := fmt.Sprintf("%s/%s", launchPlan.Metadata.Namespace, launchPlan.Metadata.Name)
+		if group.Name != "" {
+			name = fmt.Sprintf("%s/%s", name, group.Name)
+		}
+		for _, volumeSpec := range launchPlan.Spec.Volumes {
+			// the zones of instances are chosen by the fleet, any zone of the network checks the permission to create the volume
+			var checkErr error
+			if zone != "" {
+				createOpts := volumeOptions(*launchPlan, volumeSpec, zone, nil)
+				createOpts.DryRun = true
+				_, checkErr = v.volumeWatcher.Create(ctx, launchPlan.Metadata.Namespace, launchPlan.Metadata.Name, createOpts)
+			}
+			planResource(launchPlan, "Volume", fmt.Sprintf("%s:%s", name, volumeSpec.DeviceName), checkErr)
+		}
+	}
+}
+
+// deleteVolumes waits for the volumes of the plan's terminated instances to be detached and deletes them
+func (v AWSVM) deleteVolumes(ctx context.Context, deletionPlan *plans.DeletionPlan) error {
+	pending := lo.Filter(deletionPlan.Spec.Volumes, func(volume volumes.Volume, _ int) bool { return !deletionPlan.Status.Volumes[*volume.VolumeId] })
+	if len(pending) == 0 {
+		return nil
+	}
+	logging.FromContext(ctx).Debug("Waiting for volumes of terminated instances to be detached...")
+	if err := v.volumeWatcher.WaitForAvailable(ctx, lo.Map(pending, func(volume volumes.Volume, _ int) string { return *volume.VolumeId }), volumeReleaseTimeout); err != nil {
+		return err
+	}
+	return deleteEach(ctx, "volume", "volume-id", pending, func(volume volumes.Volume) string { return *volume.VolumeId }, &deletionPlan.Status.Volumes,
+		func(volume volumes.Volume) error { return v.volumeWatcher.Delete(ctx, *volume.VolumeId) })
+}
+
+// KeepVolumes keeps the attached volumes when the deletion plan is executed: the instances no longer delete them on termination,
+// and kept standalone volumes are skipped. The returned plan reflects the changed attributes.
+func (v AWSVM) KeepVolumes(ctx context.Context, deletionPlan plans.DeletionPlan, kept []plans.AttachedVolume) (plans.DeletionPlan, error) {
+	for _, volume := range kept {
+		if volume.Standalone || !volume.DeleteOnTermination {
+			continue
+		}
+		logging.FromContext(ctx).Debug("Keeping volume after termination", "instance-id", volume.InstanceID, "volume-id", volume.VolumeID, "device", volume.DeviceName)
+		if err := v.instanceWatcher.SetDeleteOnTermination(ctx, volume.InstanceID, volume.DeviceName, false); err != nil {
+			return deletionPlan, err
+		}
+	}
+	return deletionPlan.KeepVolumes(kept), nil
+}
@@ -0,0 +1,42 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/plans"
+	"github.com/bwagner5/nimbus/pkg/progress"
+)
+
+// waitFor waits until the launched instances reach the condition of the spec's Wait or its timeout expires.
+// The instances are refreshed once they are running to pick up their state and addresses.
+func (v AWSVM) waitFor(ctx context.Context, launchPlan *plans.LaunchPlan) error {
+	wait := launchPlan.Spec.Wait
+	// existing instances that the launch kept already reached the condition when they were launched
+	instanceIDs := idsOf(newInstances(*launchPlan))
+	if !wait.Enabled() || len(instanceIDs) == 0 {
+		return nil
+	}
+	timeout := wait.EffectiveTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	logging.FromContext(ctx).Debug("Waiting for instances", "condition", wait.For, "timeout", timeout, "instance-ids", instanceIDs)
+	done := progress.FromContext(ctx).Track(fmt.Sprintf("Waiting for %d instances to be %s", len(instanceIDs), wait.For), expectedRunningDuration)
+	defer done()
+	if err := v.instanceWatcher.WaitForRunning(ctx, instanceIDs, timeout); err != nil {
+		return fmt.Errorf("instances are not %s: %w", wait.For, err)
+	}
+	switch wait.For {
+	case plans.WaitStatusChecksPassed:
+		if err := v.instanceWatcher.WaitForStatusOK(ctx, instanceIDs, timeout); err != nil {
+			return fmt.Errorf("instances are not %s: %w", wait.For, err)
+		}
+	case plans.WaitSSMManaged:
+		if err := v.sessionWatcher.WaitForOnline(ctx, instanceIDs); err != nil {
+			return fmt.Errorf("instances are not %s: %w", wait.For, err)
+		}
+	}
+	return v.refreshLaunchedInstances(ctx, launchPlan)
+}
@@ -0,0 +1,103 @@
+package vm
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/bwagner5/nimbus/pkg/logging"
+	"github.com/bwagner5/nimbus/pkg/providers/instances"
+	"github.com/bwagner5/nimbus/pkg/utils/tagutils"
+)
+
+const (
+	// watchPollInterval is how often instances are described to detect changes
+	watchPollInterval = 10 * time.Second
+)
+
+// EventType describes the kind of change observed for an instance
+type EventType string
+
+const (
+	EventAdded    EventType = "Added"
+	EventModified EventType = "Modified"
+	EventDeleted  EventType = "Deleted"
+)
+
+// Event is a change to a nimbus instance observed by Watch
+type Event struct {
+	Type     EventType
+	Instance instances.Instance
+}
+
+// Watch polls the namespace's instances and emits an Event for every instance that is added, modified, or deleted.
+// Instances that exist when the watch starts are emitted as Added. Instances that reach the terminated state are emitted as Deleted.
+// The returned channel is closed when ctx is done.
+func (v AWSVM) Watch(ctx context.Context, namespace string) (<-chan Event, error) {
+	events := make(chan Event)
+	go func() {
+		defer close(events)
+		known := map[string]instances.Instance{}
+		ticker := time.NewTicker(watchPollInterval)
+		defer ticker.Stop()
+		for {
+			current, err := v.instanceWatcher.Resolve(ctx, []instances.Selector{{
+				Tags: tagutils.NamespacedTags(namespace, ""),
+			}})
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				logging.FromContext(ctx).Error("Unable to list instances for watch", "error", err)
+			} else if !v.emitChanges(ctx, events, known, current) {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return events, nil
+}
+
+// emitChanges diffs current against known, sends the resulting events, and updates known in place.
+// It returns false if ctx was cancelled while sending.
+func (v AWSVM) emitChanges(ctx context.Context, events chan<- Event, known map[string]instances.Instance, current []instances.Instance) bool {
+	seen := map[string]bool{}
+	var pending []Event
+	for _, instance := range current {
+		id := *instance.InstanceId
+		if instance.State != nil && instance.State.Name == ec2types.InstanceStateNameTerminated {
+			continue
+		}
+		seen[id] = true
+		previous, ok := known[id]
+		switch {
+		case !ok:
+			pending = append(pending, Event{Type: EventAdded, Instance: instance})
+		case !reflect.DeepEqual(previous.Instance, instance.Instance):
+			pending = append(pending, Event{Type: EventModified, Instance: instance})
+		default:
+			continue
+		}
+		known[id] = instance
+	}
+	for id, instance := range known {
+		if seen[id] {
+			continue
+		}
+		delete(known, id)
+		pending = append(pending, Event{Type: EventDeleted, Instance: instance})
+	}
+	for _, event := range pending {
+		select {
+		case <-ctx.Done():
+			return false
+		case events <- event:
+		}
+	}
+	return true
+}